@@ -0,0 +1,111 @@
+package httpsim
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/romshark/httpsim/config"
+)
+
+// replaceBody returns the replacement body of c, or of variant if not nil,
+// with the templates expanded, the contents of its body file or its decoded
+// base64 body. Returns nil if there's no body.
+func (m *Middleware) replaceBody(
+	c *config.Replace, variant *config.Variant, captures map[string]string,
+) ([]byte, error) {
+	body, file, b64 := c.Body, c.BodyFile, c.BodyBase64
+	if variant != nil {
+		body, file, b64 = variant.Body, variant.BodyFile, variant.BodyBase64
+	}
+	switch {
+	case body != nil:
+		return []byte(ExpandTemplate(*body, captures)), nil
+	case file != nil:
+		return m.files.load(*file)
+	case b64 != nil:
+		return b64.Bytes(), nil
+	}
+	return nil, nil
+}
+
+// SetLimits makes the middleware enforce l.MaxBodyBytes on body files,
+// which are read at request time, instead of config.DefaultLimits.
+// The other limits are enforced when loading and validating configs.
+// SetLimits is safe for concurrent use at runtime.
+func (m *Middleware) SetLimits(l config.Limits) {
+	m.files.maxBytes.Store(l.MaxBodyBytes)
+}
+
+// fileCache caches file contents and reloads them when files change.
+type fileCache struct {
+	lock     sync.Mutex
+	files    map[string]cachedFile
+	maxBytes atomic.Int64 // Zero for unlimited.
+}
+
+type cachedFile struct {
+	modTime  time.Time
+	size     int64
+	contents []byte
+}
+
+func newFileCache() *fileCache {
+	c := &fileCache{files: map[string]cachedFile{}}
+	c.maxBytes.Store(config.DefaultLimits.MaxBodyBytes)
+	return c
+}
+
+// load returns the contents of the file at path, reading it only if it's not
+// cached yet or its modification time or size changed since it was cached.
+// Returns config.ErrBodyTooLarge if the file exceeds the size limit.
+func (c *fileCache) load(path string) ([]byte, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading body file: %w", err)
+	}
+	max := c.maxBytes.Load()
+	if max > 0 && info.Size() > max {
+		return nil, fmt.Errorf("reading body file %s: %w: %d bytes exceeds %d",
+			path, config.ErrBodyTooLarge, info.Size(), max)
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if f, ok := c.files[path]; ok &&
+		f.modTime.Equal(info.ModTime()) && f.size == info.Size() {
+		return f.contents, nil
+	}
+	contents, err := readFile(path, max)
+	if err != nil {
+		return nil, fmt.Errorf("reading body file: %w", err)
+	}
+	c.files[path] = cachedFile{
+		modTime: info.ModTime(), size: info.Size(), contents: contents,
+	}
+	return contents, nil
+}
+
+// readFile reads the file at path, which must not grow beyond max bytes
+// while it's being read unless max is zero.
+func readFile(path string, max int64) ([]byte, error) {
+	if max < 1 {
+		return os.ReadFile(path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	contents, err := io.ReadAll(io.LimitReader(f, max+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(contents)) > max {
+		return nil, fmt.Errorf("%s: %w: exceeds %d bytes",
+			path, config.ErrBodyTooLarge, max)
+	}
+	return contents, nil
+}
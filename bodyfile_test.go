@@ -0,0 +1,113 @@
+package httpsim_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim/config"
+)
+
+func TestBodyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "body.json")
+	write := func(contents string, modTime time.Time) {
+		t.Helper()
+		require.NoError(t, os.WriteFile(path, []byte(contents), 0o644))
+		require.NoError(t, os.Chtimes(path, modTime, modTime))
+	}
+	_, s := NewSimulator(t, config.Config{
+		Resources: []config.Resource{{Effect: &config.Effect{
+			Replace: &config.Replace{
+				StatusCode: http.StatusOK,
+				BodyFile:   &path,
+				Headers: map[config.HeaderName]string{
+					"Content-Type": "application/json",
+				},
+			},
+		}}},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("next handler must not be invoked")
+	})
+	check := func(expectCode int, expectBody string) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody))
+		require.Equal(t, expectCode, rec.Code)
+		require.Equal(t, expectBody, rec.Body.String())
+	}
+
+	t0 := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	write(`{"v":1}`, t0)
+	check(http.StatusOK, `{"v":1}`)
+
+	// Same size and modification time, the cached contents are served.
+	write(`{"v":2}`, t0)
+	check(http.StatusOK, `{"v":1}`)
+
+	// Changed files are reloaded.
+	write(`{"v":3}`, t0.Add(time.Second))
+	check(http.StatusOK, `{"v":3}`)
+	write(`{"v":40}`, t0.Add(time.Second))
+	check(http.StatusOK, `{"v":40}`)
+
+	require.NoError(t, os.Remove(path))
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody))
+	require.Equal(t, http.StatusInternalServerError, rec.Code)
+	require.Contains(t, rec.Body.String(), "reading body file")
+}
+
+func TestBodyFileTooLarge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "body.txt")
+	require.NoError(t, os.WriteFile(path, []byte("abcde"), 0o644))
+	_, s := NewSimulator(t, config.Config{
+		Resources: []config.Resource{{Effect: &config.Effect{
+			Replace: &config.Replace{StatusCode: http.StatusOK, BodyFile: &path},
+		}}},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("next handler must not be invoked")
+	})
+	check := func(expectCode int, expectBody string) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody))
+		require.Equal(t, expectCode, rec.Code)
+		require.Contains(t, rec.Body.String(), expectBody)
+	}
+
+	check(http.StatusOK, "abcde")
+
+	s.SetLimits(config.Limits{MaxBodyBytes: 4})
+	check(http.StatusInternalServerError, "body too large")
+
+	s.SetLimits(config.Limits{MaxBodyBytes: 5})
+	check(http.StatusOK, "abcde")
+}
+
+func TestBodyBase64(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n")
+	body := config.NewBase64(png)
+	_, s := NewSimulator(t, config.Config{
+		Resources: []config.Resource{{Effect: &config.Effect{
+			Replace: &config.Replace{
+				StatusCode: http.StatusOK,
+				BodyBase64: &body,
+				Headers: map[config.HeaderName]string{
+					"Content-Type": "image/png",
+				},
+			},
+		}}},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("next handler must not be invoked")
+	})
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "image/png", rec.Header().Get("Content-Type"))
+	require.Equal(t, png, rec.Body.Bytes())
+}
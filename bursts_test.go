@@ -0,0 +1,67 @@
+package httpsim_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim"
+	"github.com/romshark/httpsim/config"
+	"github.com/romshark/httpsim/internal/rand"
+)
+
+// ClockSleep is a MockSleep that advances its clock by every sleep.
+type ClockSleep struct{ MockSleep }
+
+var _ httpsim.Clock = new(ClockSleep)
+
+func (s *ClockSleep) Now() time.Time { return time.Unix(0, 0).Add(s.Cumulative) }
+
+func TestBursts(t *testing.T) {
+	f := func(b config.Bursts, at []time.Duration, expect []int) {
+		t.Helper()
+		conf := config.Config{
+			Resources: []config.Resource{{Effect: &config.Effect{
+				Bursts: &b,
+				Replace: &config.Replace{
+					StatusCode: http.StatusInternalServerError,
+				},
+			}}},
+		}
+		require.NoError(t, config.Validate(conf))
+		clock := new(ClockSleep)
+		rnd := rand.NewSourceChaCha8(rand.NewSeed("fedcba9876543210fedcba9876543210"))
+		s := httpsim.NewMiddleware(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			},
+		), conf, clock, rnd)
+		actual := make([]int, len(at))
+		for i, at := range at {
+			clock.Cumulative = at
+			rec := httptest.NewRecorder()
+			s.ServeHTTP(rec, NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody))
+			actual[i] = rec.Code
+		}
+		require.Equal(t, expect, actual)
+	}
+
+	// 30s of 500s every 10 minutes.
+	f(config.Bursts{Duration: 30 * time.Second, Every: 10 * time.Minute},
+		[]time.Duration{
+			0, 29 * time.Second, 30 * time.Second, 9 * time.Minute,
+			10 * time.Minute, 10*time.Minute + 29*time.Second, 11 * time.Minute,
+		},
+		[]int{500, 500, 200, 200, 500, 500, 200})
+
+	// One-shot outage at T+2m.
+	f(config.Bursts{Start: 2 * time.Minute, Duration: time.Minute},
+		[]time.Duration{
+			0, 2*time.Minute - 1, 2 * time.Minute, 3*time.Minute - 1,
+			3 * time.Minute, 13 * time.Minute,
+		},
+		[]int{200, 200, 500, 500, 200, 200})
+}
@@ -0,0 +1,62 @@
+package httpsim
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/romshark/httpsim/config"
+)
+
+// cacheHeaders sets the caching headers of c for body and returns true
+// if r is a GET or HEAD request whose validators match them.
+func (m *Middleware) cacheHeaders(
+	h http.Header, r *http.Request, c *config.CacheHeaders, status int, body []byte,
+) (notModified bool) {
+	var etag string
+	if c.ETag || c.WeakETag {
+		sum := sha256.Sum256(body)
+		etag = `"` + hex.EncodeToString(sum[:8]) + `"`
+		if c.WeakETag {
+			etag = "W/" + etag
+		}
+		h.Set("ETag", etag)
+	}
+	var lastModified time.Time
+	if c.LastModified > 0 {
+		lastModified = m.state.Load().start.Add(-c.LastModified).Truncate(time.Second)
+		h.Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+	if v := c.CacheControl(); v != "" {
+		h.Set("Cache-Control", v)
+	}
+
+	if status != http.StatusOK ||
+		(r.Method != http.MethodGet && r.Method != http.MethodHead) {
+		return false
+	}
+	if v := r.Header.Get("If-None-Match"); v != "" {
+		// If-None-Match takes precedence over If-Modified-Since.
+		return etag != "" && etagListMatch(v, etag)
+	}
+	if v := r.Header.Get("If-Modified-Since"); v != "" && !lastModified.IsZero() {
+		t, err := http.ParseTime(v)
+		return err == nil && !lastModified.After(t)
+	}
+	return false
+}
+
+// etagListMatch returns true if the If-None-Match value list
+// matches etag using the weak comparison.
+func etagListMatch(list, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, tag := range strings.Split(list, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
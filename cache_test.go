@@ -0,0 +1,79 @@
+package httpsim_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim/config"
+)
+
+func TestCacheHeaders(t *testing.T) {
+	body := "hello"
+	newSimulator := func(t *testing.T, c config.CacheHeaders) http.Handler {
+		t.Helper()
+		_, s := NewSimulator(t, config.Config{
+			Resources: []config.Resource{{Effect: &config.Effect{
+				Replace: &config.Replace{
+					StatusCode: http.StatusOK, Body: &body, CacheHeaders: &c,
+				},
+			}}},
+		}, func(w http.ResponseWriter, r *http.Request) {
+			t.Fatal("next handler must not be invoked")
+		})
+		return s
+	}
+	serve := func(t *testing.T, s http.Handler, method string, header ...string) *httptest.ResponseRecorder {
+		t.Helper()
+		r := NewRequest(t, method, "https://host.io/", http.NoBody)
+		for i := 0; i+1 < len(header); i += 2 {
+			r.Header.Set(header[i], header[i+1])
+		}
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, r)
+		return rec
+	}
+
+	s := newSimulator(t, config.CacheHeaders{
+		ETag: true, Profile: config.CacheProfilePublic, MaxAge: time.Minute,
+	})
+	rec := serve(t, s, http.MethodGet)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, body, rec.Body.String())
+	etag := rec.Header().Get("ETag")
+	require.Regexp(t, `^"[0-9a-f]{16}"$`, etag)
+	require.Equal(t, "public, max-age=60", rec.Header().Get("Cache-Control"))
+	require.Empty(t, rec.Header().Get("Last-Modified"))
+
+	// The entity tag is stable and validates.
+	rec = serve(t, s, http.MethodGet, "If-None-Match", `"other", W/`+etag)
+	require.Equal(t, http.StatusNotModified, rec.Code)
+	require.Zero(t, rec.Body.Len())
+	require.Equal(t, etag, rec.Header().Get("ETag"))
+	rec = serve(t, s, http.MethodGet, "If-None-Match", `"other"`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	rec = serve(t, s, http.MethodPost, "If-None-Match", etag)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	s = newSimulator(t, config.CacheHeaders{
+		WeakETag: true, LastModified: time.Hour, Profile: config.CacheProfileImmutable,
+	})
+	rec = serve(t, s, http.MethodGet)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Regexp(t, `^W/"[0-9a-f]{16}"$`, rec.Header().Get("ETag"))
+	require.Equal(t, "public, max-age=31536000, immutable",
+		rec.Header().Get("Cache-Control"))
+	lastModified, err := http.ParseTime(rec.Header().Get("Last-Modified"))
+	require.NoError(t, err)
+	require.WithinDuration(t, time.Now().Add(-time.Hour), lastModified, time.Minute)
+
+	rec = serve(t, s, http.MethodHead,
+		"If-Modified-Since", lastModified.Format(http.TimeFormat))
+	require.Equal(t, http.StatusNotModified, rec.Code)
+	rec = serve(t, s, http.MethodGet,
+		"If-Modified-Since", lastModified.Add(-time.Second).Format(http.TimeFormat))
+	require.Equal(t, http.StatusOK, rec.Code)
+}
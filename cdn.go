@@ -0,0 +1,283 @@
+package httpsim
+
+import (
+	"container/list"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/romshark/httpsim/config"
+)
+
+// Cache statuses of the CDN effect.
+const (
+	cacheHit    = "HIT"
+	cacheStale  = "STALE"
+	cacheMiss   = "MISS"
+	cacheBypass = "BYPASS"
+)
+
+// cdnEntry is a cached response.
+type cdnEntry struct {
+	url    string
+	stored time.Time
+	status int
+	header http.Header
+	body   []byte
+
+	// vary maps the names of the headers the response varies by
+	// to the values of the request it was cached for.
+	vary map[string][]string
+}
+
+// matches returns true if e was cached for a request equivalent to r.
+func (e *cdnEntry) matches(r *http.Request) bool {
+	for name, values := range e.vary {
+		if strings.Join(r.Header.Values(name), ",") != strings.Join(values, ",") {
+			return false
+		}
+	}
+	return true
+}
+
+// cdnCache holds the responses cached by a CDN effect.
+type cdnCache struct {
+	lock  sync.Mutex
+	order list.List // Of *cdnEntry, most recently used first.
+	byURL map[string]*list.Element
+	bytes uint64
+}
+
+// get returns the response cached for url or nil if there's none.
+func (c *cdnCache) get(url string, now time.Time, conf *config.CDN) *cdnEntry {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	e, ok := c.byURL[url]
+	if !ok {
+		return nil
+	}
+	if cdnEvictable(e.Value.(*cdnEntry), now, conf) {
+		c.remove(e)
+		return nil
+	}
+	c.order.MoveToFront(e)
+	return e.Value.(*cdnEntry)
+}
+
+// put caches entry replacing the response cached for the same URL
+// and evicts the least recently used responses beyond the limits of conf.
+func (c *cdnCache) put(entry *cdnEntry, now time.Time, conf *config.CDN) {
+	maxEntries, maxBytes := int(conf.MaxEntries), conf.MaxBytes
+	if maxEntries == 0 {
+		maxEntries = config.DefaultCDNMaxEntries
+	}
+	if maxBytes == 0 {
+		maxBytes = config.DefaultCDNMaxBytes
+	}
+	size := uint64(len(entry.body))
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if e, ok := c.byURL[entry.url]; ok {
+		c.remove(e)
+	}
+	if size > maxBytes {
+		return
+	}
+	for e := c.order.Back(); e != nil; e = c.order.Back() {
+		if !cdnEvictable(e.Value.(*cdnEntry), now, conf) &&
+			c.order.Len() < maxEntries && c.bytes+size <= maxBytes {
+			break
+		}
+		c.remove(e)
+	}
+	if c.byURL == nil {
+		c.byURL = map[string]*list.Element{}
+	}
+	c.byURL[entry.url] = c.order.PushFront(entry)
+	c.bytes += size
+}
+
+func (c *cdnCache) remove(e *list.Element) {
+	entry := e.Value.(*cdnEntry)
+	c.order.Remove(e)
+	delete(c.byURL, entry.url)
+	c.bytes -= uint64(len(entry.body))
+}
+
+// cdnEvictable returns true if e can no longer be served, neither fresh
+// nor stale, at now.
+func cdnEvictable(e *cdnEntry, now time.Time, c *config.CDN) bool {
+	age := now.Sub(e.stored)
+	if age < c.TTL {
+		return false
+	}
+	return c.Stale == 0 || (c.MaxStale != 0 && age >= c.TTL+c.MaxStale)
+}
+
+// cdnWriter serves a cached response or caches the response
+// written to it once finished.
+type cdnWriter struct {
+	http.ResponseWriter
+	r      *http.Request
+	conf   *config.CDN
+	header string
+	age    time.Duration
+	cache  *cdnCache
+	url    string
+	now    time.Time
+
+	// status is the cache status.
+	status string
+
+	// cached is the response to serve or nil if there's none.
+	cached *cdnEntry
+
+	wroteHeader bool
+	entry       *cdnEntry // Nil if the response isn't cached.
+}
+
+// newCDNWriter looks up the cached response of r in the cache of effect
+// in caches.
+func (m *Middleware) newCDNWriter(
+	w http.ResponseWriter, r *http.Request, c *config.CDN,
+	effect *config.Effect, caches *sync.Map,
+) *cdnWriter {
+	v, _ := caches.LoadOrStore(effect, new(cdnCache))
+	cw := &cdnWriter{
+		ResponseWriter: w, r: r, conf: c, header: "X-Cache",
+		cache: v.(*cdnCache), url: r.Host + r.URL.RequestURI(), now: m.now(),
+	}
+	if c.Header != nil {
+		cw.header = string(*c.Header)
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		cw.status = cacheBypass
+		return cw
+	}
+	cw.status = cacheMiss
+	e := cw.cache.get(cw.url, cw.now, c)
+	if e == nil || !e.matches(r) {
+		return cw
+	}
+	cw.age = cw.now.Sub(e.stored)
+	if cw.age < c.TTL {
+		cw.status, cw.cached = cacheHit, e
+	} else if m.rand.Float64() < float64(c.Stale) {
+		cw.status, cw.cached = cacheStale, e
+	}
+	return cw
+}
+
+// serve writes the cached response and returns true if there is one.
+func (w *cdnWriter) serve() bool {
+	if w.cached == nil {
+		return false
+	}
+	h := w.ResponseWriter.Header()
+	for name, values := range w.cached.header {
+		h[name] = values
+	}
+	h.Set("Age", strconv.FormatInt(int64(w.age/time.Second), 10))
+	h.Set(w.header, w.status)
+	w.ResponseWriter.WriteHeader(w.cached.status)
+	_, _ = w.ResponseWriter.Write(w.cached.body)
+	return true
+}
+
+func (w *cdnWriter) WriteHeader(statusCode int) {
+	if !w.wroteHeader && statusCode >= 200 {
+		w.wroteHeader = true
+		h := w.Header()
+		if w.status == cacheMiss && w.r.Method == http.MethodGet &&
+			cacheable(statusCode, h) {
+			w.entry = &cdnEntry{
+				url: w.url, stored: w.now, status: statusCode, header: h.Clone(),
+			}
+			for _, v := range h.Values("Vary") {
+				for _, name := range strings.Split(v, ",") {
+					name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+					if name == "" {
+						continue
+					}
+					if w.entry.vary == nil {
+						w.entry.vary = map[string][]string{}
+					}
+					w.entry.vary[name] = w.r.Header.Values(name)
+				}
+			}
+		}
+		h.Set(w.header, w.status)
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *cdnWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.entry != nil {
+		w.entry.body = append(w.entry.body, b...)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap allows http.ResponseController to access the underlying writer.
+func (w *cdnWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+// finish caches the response if it's cacheable.
+func (w *cdnWriter) finish() {
+	if w.cached != nil {
+		return // Served from the cache.
+	}
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.entry != nil {
+		w.cache.put(w.entry, w.now, w.conf)
+	}
+}
+
+// cacheable returns true if a shared cache may store a response
+// with status code and header h.
+func cacheable(statusCode int, h http.Header) bool {
+	switch statusCode {
+	case http.StatusOK, http.StatusNonAuthoritativeInfo, http.StatusNoContent,
+		http.StatusMultipleChoices, http.StatusMovedPermanently,
+		http.StatusPermanentRedirect, http.StatusNotFound,
+		http.StatusMethodNotAllowed, http.StatusGone,
+		http.StatusRequestURITooLong, http.StatusNotImplemented:
+	default:
+		return false
+	}
+	for _, v := range h.Values("Cache-Control") {
+		for _, d := range strings.Split(v, ",") {
+			d, _, _ = strings.Cut(strings.TrimSpace(d), "=")
+			switch strings.ToLower(d) {
+			case "no-store", "no-cache", "private":
+				return false
+			}
+		}
+	}
+	for _, v := range h.Values("Vary") {
+		if strings.TrimSpace(v) == "*" {
+			return false
+		}
+	}
+	return true
+}
+
+// cdnWriterOf returns the cdnWriter of the writer chain of w.
+func cdnWriterOf(w http.ResponseWriter) (*cdnWriter, bool) {
+	for {
+		if cw, ok := w.(*cdnWriter); ok {
+			return cw, true
+		}
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return nil, false
+		}
+		w = u.Unwrap()
+	}
+}
@@ -0,0 +1,187 @@
+package httpsim_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim"
+	"github.com/romshark/httpsim/config"
+	"github.com/romshark/httpsim/internal/rand"
+)
+
+// NewCDN returns a simulator with effect e whose next handler responds
+// with the number of times it was invoked.
+func NewCDN(t *testing.T, e *config.Effect) (*ClockSleep, *httpsim.Middleware) {
+	t.Helper()
+	conf := config.Config{Resources: []config.Resource{{Effect: e}}}
+	require.NoError(t, config.Validate(conf))
+	clock := new(ClockSleep)
+	rnd := rand.NewSourceChaCha8(rand.NewSeed("fedcba9876543210fedcba9876543210"))
+	var calls int
+	s := httpsim.NewMiddleware(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			w.Header().Set("Vary", "Accept-Language")
+			_, _ = w.Write([]byte(strconv.Itoa(calls)))
+		},
+	), conf, clock, rnd)
+	return clock, s
+}
+
+func ServeCDN(
+	t *testing.T, s http.Handler, method, url string, header http.Header,
+) *httptest.ResponseRecorder {
+	t.Helper()
+	r := NewRequest(t, method, url, http.NoBody)
+	for name, values := range header {
+		r.Header[name] = values
+	}
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, r)
+	return rec
+}
+
+func TestCDN(t *testing.T) {
+	clock, s := NewCDN(t, &config.Effect{CDN: &config.CDN{TTL: time.Minute}})
+	f := func(
+		at time.Duration, method, url string, header http.Header,
+		expectStatus, expectAge, expectBody string,
+	) {
+		t.Helper()
+		clock.Cumulative = at
+		rec := ServeCDN(t, s, method, url, header)
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Equal(t, expectStatus, rec.Header().Get("X-Cache"))
+		require.Equal(t, expectAge, rec.Header().Get("Age"))
+		require.Equal(t, expectBody, rec.Body.String())
+	}
+	de := http.Header{"Accept-Language": {"de"}}
+
+	f(0, "GET", "https://host.io/a", nil, "MISS", "", "1")
+	f(10*time.Second, "GET", "https://host.io/a", nil, "HIT", "10", "1")
+	f(20*time.Second, "HEAD", "https://host.io/a", nil, "HIT", "20", "1")
+	f(20*time.Second, "GET", "https://host.io/a?q=1", nil, "MISS", "", "2")
+	f(30*time.Second, "POST", "https://host.io/a", nil, "BYPASS", "", "3")
+	// The response varies by Accept-Language.
+	f(30*time.Second, "GET", "https://host.io/a", de, "MISS", "", "4")
+	f(40*time.Second, "GET", "https://host.io/a", de, "HIT", "10", "4")
+	// Expired.
+	f(100*time.Second, "GET", "https://host.io/a", de, "MISS", "", "5")
+	f(110*time.Second, "GET", "https://host.io/a", de, "HIT", "10", "5")
+}
+
+func TestCDNStale(t *testing.T) {
+	clock, s := NewCDN(t, &config.Effect{CDN: &config.CDN{TTL: time.Minute, Stale: 1}})
+	rec := ServeCDN(t, s, "GET", "https://host.io/", nil)
+	require.Equal(t, "MISS", rec.Header().Get("X-Cache"))
+
+	clock.Cumulative = 5 * time.Minute
+	rec = ServeCDN(t, s, "GET", "https://host.io/", nil)
+	require.Equal(t, "STALE", rec.Header().Get("X-Cache"))
+	require.Equal(t, "300", rec.Header().Get("Age"))
+	require.Equal(t, "1", rec.Body.String())
+}
+
+func TestCDNMaxStale(t *testing.T) {
+	clock, s := NewCDN(t, &config.Effect{CDN: &config.CDN{
+		TTL: time.Minute, Stale: 1, MaxStale: time.Minute,
+	}})
+	f := func(at time.Duration, expectStatus, expectBody string) {
+		t.Helper()
+		clock.Cumulative = at
+		rec := ServeCDN(t, s, "GET", "https://host.io/", nil)
+		require.Equal(t, expectStatus, rec.Header().Get("X-Cache"))
+		require.Equal(t, expectBody, rec.Body.String())
+	}
+	f(0, "MISS", "1")
+	f(90*time.Second, "STALE", "1")
+	f(2*time.Minute, "MISS", "2") // Evicted.
+}
+
+func TestCDNLimits(t *testing.T) {
+	f := func(c *config.CDN) {
+		t.Helper()
+		_, s := NewCDN(t, &config.Effect{CDN: c})
+		serve := func(url, expectStatus string) {
+			t.Helper()
+			rec := ServeCDN(t, s, "GET", url, nil)
+			require.Equal(t, expectStatus, rec.Header().Get("X-Cache"))
+		}
+		serve("https://host.io/a", "MISS")
+		serve("https://host.io/b", "MISS")
+		serve("https://host.io/a", "HIT")
+
+		// b is the least recently used response and is evicted.
+		serve("https://host.io/c", "MISS")
+		serve("https://host.io/a", "HIT")
+		serve("https://host.io/c", "HIT")
+		serve("https://host.io/b", "MISS")
+	}
+	f(&config.CDN{TTL: time.Minute, MaxEntries: 2})
+	// The bodies are 1 byte each.
+	f(&config.CDN{TTL: time.Minute, MaxBytes: 2})
+
+	// Responses exceeding the limit aren't cached.
+	_, s := NewCDN(t, &config.Effect{CDN: &config.CDN{TTL: time.Minute, MaxBytes: 1}})
+	for i := range 9 {
+		ServeCDN(t, s, "GET", "https://host.io/"+strconv.Itoa(i), nil)
+	}
+	rec := ServeCDN(t, s, "GET", "https://host.io/", nil)
+	require.Equal(t, "10", rec.Body.String())
+	rec = ServeCDN(t, s, "GET", "https://host.io/", nil)
+	require.Equal(t, "MISS", rec.Header().Get("X-Cache"))
+	require.Equal(t, "11", rec.Body.String())
+}
+
+func TestCDNReplace(t *testing.T) {
+	body := `{"items":[]}`
+	delay := time.Second
+	header := config.HeaderName("CF-Cache-Status")
+	clock, s := NewCDN(t, &config.Effect{
+		CDN:   &config.CDN{TTL: time.Minute, Header: &header},
+		Delay: &config.DurRange{Min: delay, Max: delay},
+		Replace: &config.Replace{
+			StatusCode: http.StatusOK,
+			Headers:    map[config.HeaderName]string{"Content-Type": "application/json"},
+			Body:       &body,
+		},
+	})
+	rec := ServeCDN(t, s, "GET", "https://host.io/", nil)
+	require.Equal(t, "MISS", rec.Header().Get("CF-Cache-Status"))
+	require.Equal(t, body, rec.Body.String())
+	require.Equal(t, delay, clock.Cumulative)
+
+	// Hits don't reach the backend.
+	rec = ServeCDN(t, s, "GET", "https://host.io/", nil)
+	require.Equal(t, "HIT", rec.Header().Get("CF-Cache-Status"))
+	require.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	require.Equal(t, body, rec.Body.String())
+	require.Equal(t, delay, clock.Cumulative)
+}
+
+func TestCDNUncacheable(t *testing.T) {
+	f := func(status int, cacheControl string) {
+		t.Helper()
+		body := "x"
+		_, s := NewCDN(t, &config.Effect{
+			CDN: &config.CDN{TTL: time.Minute},
+			Replace: &config.Replace{
+				StatusCode: config.StatusCode(status),
+				Headers:    map[config.HeaderName]string{"Cache-Control": cacheControl},
+				Body:       &body,
+			},
+		})
+		for range 2 {
+			rec := ServeCDN(t, s, "GET", "https://host.io/", nil)
+			require.Equal(t, "MISS", rec.Header().Get("X-Cache"))
+		}
+	}
+	f(http.StatusInternalServerError, "")
+	f(http.StatusOK, "private, max-age=60")
+	f(http.StatusOK, "no-store")
+}
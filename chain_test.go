@@ -0,0 +1,71 @@
+package httpsim_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim"
+	"github.com/romshark/httpsim/config"
+)
+
+func TestEffectChain(t *testing.T) {
+	never := config.Probability(0)
+	var info httpsim.CtxInfo
+	mockSleep, s := NewSimulator(t, config.Config{
+		Resources: []config.Resource{{
+			Effects: []config.Effect{
+				{Delay: &config.DurRange{Min: time.Second, Max: time.Second}},
+				{
+					Probability: &never,
+					Delay:       &config.DurRange{Min: time.Hour, Max: time.Hour},
+				},
+				{ResponseHeaders: &config.HeaderMutation{
+					Set: map[config.HeaderName]string{"X-Cache": "MISS"},
+				}},
+				{Delay: &config.DurRange{Min: time.Second, Max: time.Second}},
+			},
+		}},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		info = r.Context().Value(httpsim.CtxKeyInfo).(httpsim.CtxInfo)
+		w.WriteHeader(http.StatusOK)
+	})
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "MISS", rec.Header().Get("X-Cache"))
+	require.Equal(t, 2*time.Second, mockSleep.Cumulative)
+	require.Equal(t, 2*time.Second, info.Delay)
+}
+
+func TestEffectChainReplaced(t *testing.T) {
+	always := config.Probability(1)
+	mockSleep, s := NewSimulator(t, config.Config{
+		Resources: []config.Resource{{
+			Effects: []config.Effect{
+				{Delay: &config.DurRange{Min: time.Second, Max: time.Second}},
+				{ResponseHeaders: &config.HeaderMutation{
+					Set: map[config.HeaderName]string{"X-Cache": "MISS"},
+				}},
+				{
+					Probability: &always,
+					Replace: &config.Replace{
+						StatusCode: http.StatusServiceUnavailable,
+					},
+				},
+				// Skipped since the response was replaced.
+				{Delay: &config.DurRange{Min: time.Hour, Max: time.Hour}},
+			},
+		}},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("next handler must not be invoked")
+	})
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody))
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	require.Equal(t, "MISS", rec.Header().Get("X-Cache"))
+	require.Equal(t, time.Second, mockSleep.Cumulative)
+}
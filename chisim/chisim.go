@@ -0,0 +1,21 @@
+// Package chisim adapts httpsim.Middleware to the chi router.
+package chisim
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/romshark/httpsim"
+)
+
+// Middleware returns a chi middleware simulating the requests of every
+// router it's used with by m. The next handler receives the request
+// with the httpsim.CtxInfo, see httpsim.CtxInfoValue.
+// m may be created with a nil next handler.
+func Middleware(m *httpsim.Middleware) func(http.Handler) http.Handler {
+	return m.Wrap
+}
+
+// Use is a shorthand for r.Use(Middleware(m)).
+func Use(r chi.Router, m *httpsim.Middleware) { r.Use(Middleware(m)) }
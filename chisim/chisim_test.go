@@ -0,0 +1,54 @@
+package chisim_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim"
+	"github.com/romshark/httpsim/chisim"
+)
+
+func TestMiddleware(t *testing.T) {
+	c, err := httpsim.LoadConfig(strings.NewReader(`
+resources:
+  - path: /fail
+    effect:
+      replace:
+        status-code: 503
+  - path: /ok
+    effect:
+      response-headers:
+        set:
+          X-Simulated: "1"
+`))
+	require.NoError(t, err)
+	m := httpsim.NewMiddleware(nil, *c, httpsim.DefaultSleep, nil)
+
+	r := chi.NewRouter()
+	chisim.Use(r, m)
+	var info httpsim.CtxInfo
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		info = httpsim.CtxInfoValue(r.Context())
+		_, _ = w.Write([]byte("ok"))
+	}
+	r.Get("/fail", handler)
+	r.Get("/ok", handler)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/fail", http.NoBody))
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ok", http.NoBody))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "ok", rec.Body.String())
+	require.Equal(t, "1", rec.Header().Get("X-Simulated"))
+	require.Equal(t, 1, info.MatchedResourceIndex)
+
+	require.Equal(t, []uint64{1, 1}, m.MatchCounts())
+}
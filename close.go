@@ -0,0 +1,59 @@
+package httpsim
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrClosed is returned by the config watchers when the middleware is closed.
+var ErrClosed = errors.New("httpsim: middleware closed")
+
+// Close shuts the middleware down. Requests served after Close are passed
+// through to the next handler untouched. Injected delays of in-flight
+// requests end immediately and the requests continue as if the delays had
+// elapsed, hangs abort their requests, and the connections closed by
+// WebSocket effects are closed right away. The config watchers of the
+// middleware return ErrClosed.
+//
+// Close doesn't wait for in-flight requests, recordings are saved as soon
+// as their responses are complete. Call Close before http.Server.Shutdown
+// such that the shutdown doesn't wait for injected delays.
+// Close waits for the goroutines started by the middleware to exit,
+// is idempotent and always returns nil.
+func (m *Middleware) Close() error {
+	m.backgroundLock.Lock()
+	m.close()
+	m.backgroundLock.Unlock()
+	m.background.Wait()
+	return nil
+}
+
+// goBackground runs f in a goroutine Close waits for.
+// If m is already closed f is run synchronously instead.
+func (m *Middleware) goBackground(f func()) {
+	m.backgroundLock.Lock()
+	if m.isClosed() {
+		m.backgroundLock.Unlock()
+		f()
+		return
+	}
+	m.background.Add(1)
+	m.backgroundLock.Unlock()
+	go func() {
+		defer m.background.Done()
+		f()
+	}()
+}
+
+// closing returns a copy of ctx that is also canceled when m is closed.
+func (m *Middleware) closing(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(m.closed, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// isClosed returns true if m was closed.
+func (m *Middleware) isClosed() bool { return m.closed.Err() != nil }
@@ -0,0 +1,121 @@
+package httpsim_test
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim"
+	"github.com/romshark/httpsim/config"
+)
+
+func TestClose(t *testing.T) {
+	conf := config.Config{
+		Resources: []config.Resource{
+			{Path: NewGlobExpression(t, "/delay"), Effect: &config.Effect{
+				Delay: &config.DurRange{Min: time.Hour, Max: time.Hour},
+			}},
+			{Path: NewGlobExpression(t, "/replace"), Effect: &config.Effect{
+				Replace: &config.Replace{StatusCode: http.StatusServiceUnavailable},
+			}},
+		},
+	}
+	require.NoError(t, config.Validate(conf))
+	s := httpsim.NewMiddleware(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) { _, _ = w.Write([]byte("ok")) },
+	), conf, httpsim.DefaultSleep, nil)
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, NewRequest(t, http.MethodGet, "https://host.io"+path, http.NoBody))
+		return rec
+	}
+
+	start := time.Now()
+	done := make(chan *httptest.ResponseRecorder, 1)
+	go func() { done <- serve("/delay") }()
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(t, s.Close())
+
+	// The in-flight request continues as if the delay had elapsed.
+	rec := <-done
+	require.Less(t, time.Since(start), time.Minute)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "ok", rec.Body.String())
+
+	// Requests are passed through after Close.
+	rec = serve("/replace")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "ok", rec.Body.String())
+
+	require.NoError(t, s.Close())
+}
+
+func TestCloseHang(t *testing.T) {
+	conf := config.Config{
+		Resources: []config.Resource{
+			{Effect: &config.Effect{Hang: &config.Hang{IgnoreDisconnect: true}}},
+		},
+	}
+	_, s := NewSimulator(t, conf, func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("next handler must not be invoked")
+	})
+	time.AfterFunc(10*time.Millisecond, func() { _ = s.Close() })
+	require.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		s.ServeHTTP(httptest.NewRecorder(),
+			NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody))
+	})
+}
+
+func TestCloseWebSocket(t *testing.T) {
+	conf := config.Config{
+		Resources: []config.Resource{{Effect: &config.Effect{
+			WebSocket: &config.WebSocket{
+				CloseAfter: &config.DurRange{Min: time.Hour, Max: time.Hour},
+			},
+		}}},
+	}
+	require.NoError(t, config.Validate(conf))
+	s := httpsim.NewMiddleware(
+		http.HandlerFunc(upgrade), conf, httpsim.DefaultSleep, nil,
+	)
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	require.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+	req := NewWebSocketUpgradeRequest(t, srv.URL)
+	require.NoError(t, req.Write(conn))
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+
+	// Close closes the connection and waits for the goroutine closing it.
+	start := time.Now()
+	require.NoError(t, s.Close())
+	_, err = io.ReadAll(br)
+	require.NoError(t, err)
+	require.Less(t, time.Since(start), time.Minute)
+}
+
+func TestCloseWatchConfigFile(t *testing.T) {
+	_, s := NewSimulator(t, config.Config{}, func(w http.ResponseWriter, r *http.Request) {})
+	file := filepath.Join(t.TempDir(), "httpsim.yaml")
+	done := make(chan error, 1)
+	go func() {
+		done <- httpsim.WatchConfigFile(context.Background(), file, s, nil)
+	}()
+	require.NoError(t, s.Close())
+	require.ErrorIs(t, <-done, httpsim.ErrClosed)
+}
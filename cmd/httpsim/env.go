@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/romshark/httpsim"
+	"github.com/romshark/httpsim/config"
+)
+
+// Environment variables configuring the mock and proxy commands
+// in place of their flags, such that the command can run as a container
+// sidecar without mounting files. Flags take precedence.
+const (
+	// EnvConfig is the inline YAML config used if no config file is given.
+	EnvConfig = "HTTPSIM_CONFIG"
+
+	// EnvListen is the address to listen on.
+	EnvListen = "HTTPSIM_LISTEN"
+
+	// EnvUpstream is the URL of the upstream of the proxy command.
+	EnvUpstream = "HTTPSIM_UPSTREAM"
+)
+
+// envConfigName identifies EnvConfig in errors.
+const envConfigName = "$" + EnvConfig
+
+// envOr returns the value of the environment variable name
+// or fallback if it's empty.
+func envOr(name, fallback string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// errNoConfig is returned by loadConfig if neither a config file
+// nor EnvConfig is set.
+var errNoConfig = errors.New("no config")
+
+// loadConfig loads file, or the inline config of EnvConfig if file is
+// empty, and returns the name of the loaded source for reporting errors.
+func loadConfig(file string) (c *config.Config, name string, err error) {
+	switch inline := os.Getenv(EnvConfig); {
+	case file != "":
+		c, err = httpsim.LoadConfigFile(file, loadOptions...)
+		return c, file, err
+	case inline != "":
+		c, err = httpsim.LoadConfig(strings.NewReader(inline), loadOptions...)
+		return c, envConfigName, err
+	}
+	return nil, "", errNoConfig
+}
+
+// parseUpstream parses the URL of an upstream.
+func parseUpstream(s string) (*url.URL, error) {
+	u, err := url.Parse(s)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid upstream URL: %q", s)
+	}
+	return u, nil
+}
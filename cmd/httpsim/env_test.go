@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnvOr(t *testing.T) {
+	t.Setenv(EnvListen, "")
+	require.Equal(t, ":9090", envOr(EnvListen, ":9090"))
+	t.Setenv(EnvListen, ":8080")
+	require.Equal(t, ":8080", envOr(EnvListen, ":9090"))
+}
+
+func TestLoadConfig(t *testing.T) {
+	t.Setenv(EnvConfig, "")
+	_, _, err := loadConfig("")
+	require.ErrorIs(t, err, errNoConfig)
+
+	t.Setenv(EnvConfig, "resources: [{name: inline, path: /a}]")
+	c, name, err := loadConfig("")
+	require.NoError(t, err)
+	require.Equal(t, "$HTTPSIM_CONFIG", name)
+	require.Equal(t, "inline", c.Resources[0].Name)
+
+	// Config files take precedence.
+	_, name, err = loadConfig("missing.yaml")
+	require.Error(t, err)
+	require.Equal(t, "missing.yaml", name)
+}
+
+func TestRunFromEnv(t *testing.T) {
+	f := func(t *testing.T, upstream string, expectCode int, expectStderr string) {
+		t.Helper()
+		t.Setenv(EnvConfig, "version: 999")
+		t.Setenv(EnvUpstream, upstream)
+		var stdout, stderr bytes.Buffer
+		require.Equal(t, expectCode, run(nil, nil, &stdout, &stderr))
+		require.Empty(t, stdout.String())
+		require.Contains(t, stderr.String(), expectStderr)
+	}
+	// Without an upstream the mock command runs, otherwise the proxy command.
+	f(t, "", 1, "$HTTPSIM_CONFIG:1:10: version: unsupported config version")
+	f(t, "http://localhost", 1, "$HTTPSIM_CONFIG:1:10: version: unsupported config version")
+	f(t, "localhost", 2, `invalid upstream URL: "localhost"`)
+
+	t.Setenv(EnvConfig, "")
+	var stdout, stderr bytes.Buffer
+	require.Equal(t, 2, run(nil, nil, &stdout, &stderr))
+	require.Contains(t, stderr.String(), "Usage: httpsim [flags]")
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/romshark/httpsim"
+)
+
+// headerFlags are repeated "Name: value" flags.
+type headerFlags []string
+
+func (h *headerFlags) String() string { return strings.Join(*h, ", ") }
+
+func (h *headerFlags) Set(s string) error {
+	if name, _, ok := strings.Cut(s, ":"); !ok || strings.TrimSpace(name) == "" {
+		return fmt.Errorf("invalid header %q, want Name: value", s)
+	}
+	*h = append(*h, s)
+	return nil
+}
+
+// runExplain prints why each resource of a config
+// did or didn't match a single request.
+func runExplain(args []string, _ io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("httpsim explain", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: httpsim explain -config <file> -path <path> [flags]\n\n"+
+			"Prints, matcher by matcher, why each resource of the config\n"+
+			"did or didn't match the request.\n\n")
+		flags.PrintDefaults()
+	}
+	configFile := flags.String("config", "", "config file (required)")
+	method := flags.String("method", http.MethodGet, "request method")
+	path := flags.String("path", "", "request path including the query (required)")
+	host := flags.String("host", "", "request host")
+	body := flags.String("body", "", "request body")
+	var headers headerFlags
+	flags.Var(&headers, "H", "request header as `Name: value`, repeatable")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *configFile == "" || *path == "" || flags.NArg() > 0 {
+		flags.Usage()
+		return 2
+	}
+	c, err := httpsim.LoadConfigFile(*configFile, loadOptions...)
+	if err != nil {
+		fmt.Fprintln(stderr, formatError(*configFile, err))
+		return 1
+	}
+	u, err := url.ParseRequestURI(*path)
+	if err != nil {
+		fmt.Fprintf(stderr, "invalid path: %v\n", err)
+		return 2
+	}
+	r, err := http.NewRequest(*method, u.String(), strings.NewReader(*body))
+	if err != nil {
+		fmt.Fprintf(stderr, "invalid request: %v\n", err)
+		return 2
+	}
+	r.Host = *host
+	for _, h := range headers {
+		name, value, _ := strings.Cut(h, ":")
+		r.Header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+
+	selected := -1
+	for _, ex := range httpsim.Explain(r, c) {
+		id := fmt.Sprintf("#%d", ex.Index)
+		if ex.Name != "" {
+			id += " " + ex.Name
+		}
+		switch {
+		case ex.Matched && selected < 0:
+			selected = ex.Index
+			fmt.Fprintf(stdout, "%s: matched, selected\n", id)
+		case ex.Matched:
+			fmt.Fprintf(stdout, "%s: matched, shadowed by #%d\n", id, selected)
+		default:
+			fmt.Fprintf(stdout, "%s: not matched\n", id)
+		}
+		if len(ex.Matchers) == 0 {
+			fmt.Fprintf(stdout, "    ok    (no matchers, matches any request)\n")
+		}
+		for _, m := range ex.Matchers {
+			if m.Matched {
+				fmt.Fprintf(stdout, "    ok    %s\n", m.Matcher)
+			} else {
+				fmt.Fprintf(stdout, "    fail  %s: %s\n", m.Matcher, m.Reason)
+			}
+		}
+	}
+	if selected < 0 {
+		fmt.Fprintln(stdout, "no resource matched")
+	}
+	return 0
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExplain(t *testing.T) {
+	conf := filepath.Join(t.TempDir(), "sim.yaml")
+	require.NoError(t, os.WriteFile(conf, []byte(`
+resources:
+  - name: create-order
+    methods: [POST]
+    path: /orders
+    headers:
+      X-Tenant: [acme]
+  - path: /orders*
+    host: "*.example.com"
+  - path: /orders
+`), 0o600))
+
+	f := func(t *testing.T, args []string, expectStdout string) {
+		t.Helper()
+		var stdout, stderr bytes.Buffer
+		code := run(append([]string{"explain", "-config", conf}, args...),
+			nil, &stdout, &stderr)
+		require.Equal(t, 0, code, stderr.String())
+		require.Empty(t, stderr.String())
+		require.Equal(t, expectStdout, stdout.String())
+	}
+	f(t, []string{"-method", "POST", "-path", "/orders?x=1", "-H", "X-Tenant: other"}, ""+
+		"#0 create-order: not matched\n"+
+		"    ok    methods\n"+
+		"    ok    path\n"+
+		`    fail  headers: values of header X-Tenant ["other"] don't match`+"\n"+
+		"#1: not matched\n"+
+		"    ok    path\n"+
+		`    fail  host: "" doesn't match "*.example.com"`+"\n"+
+		"#2: matched, selected\n"+
+		"    ok    path\n")
+	f(t, []string{"-path", "/orders", "-host", "shop.example.com"}, ""+
+		"#0 create-order: not matched\n"+
+		"    fail  methods: GET isn't any of [POST]\n"+
+		"    ok    path\n"+
+		"    ok    headers\n"+
+		"#1: matched, selected\n"+
+		"    ok    path\n"+
+		"    ok    host\n"+
+		"#2: matched, shadowed by #1\n"+
+		"    ok    path\n")
+	f(t, []string{"-path", "/users"}, ""+
+		"#0 create-order: not matched\n"+
+		"    fail  methods: GET isn't any of [POST]\n"+
+		`    fail  path: "/users" doesn't match "/orders"`+"\n"+
+		"    ok    headers\n"+
+		"#1: not matched\n"+
+		`    fail  path: "/users" doesn't match "/orders*"`+"\n"+
+		`    fail  host: "" doesn't match "*.example.com"`+"\n"+
+		"#2: not matched\n"+
+		`    fail  path: "/users" doesn't match "/orders"`+"\n"+
+		"no resource matched\n")
+}
+
+func TestExplainErr(t *testing.T) {
+	f := func(t *testing.T, args []string, expectCode int, expectStderr string) {
+		t.Helper()
+		var stdout, stderr bytes.Buffer
+		require.Equal(t, expectCode, run(append([]string{"explain"}, args...),
+			nil, &stdout, &stderr))
+		require.Empty(t, stdout.String())
+		require.Contains(t, stderr.String(), expectStderr)
+	}
+	f(t, nil, 2, "Usage: httpsim explain")
+	f(t, []string{"-config", "sim.yaml"}, 2, "Usage: httpsim explain")
+	f(t, []string{"-config", "sim.yaml", "-path", "/", "-H", "bad"}, 2,
+		`invalid header "bad", want Name: value`)
+	f(t, []string{"-config", "missing.yaml", "-path", "/"}, 1, "missing.yaml: opening")
+}
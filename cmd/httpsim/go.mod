@@ -0,0 +1,35 @@
+module github.com/romshark/httpsim/cmd/httpsim
+
+go 1.22.6
+
+// Releases require the httpsim releases they're tagged with,
+// within the repository the module is built against their sources.
+replace (
+	github.com/romshark/httpsim => ../../
+	github.com/romshark/httpsim/starlarksim => ../../starlarksim
+)
+
+require (
+	github.com/romshark/httpsim v0.1.0
+	github.com/romshark/httpsim/starlarksim v0.1.0
+	github.com/stretchr/testify v1.9.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/fsnotify/fsnotify v1.7.0 // indirect
+	github.com/gabriel-vasile/mimetype v1.4.5 // indirect
+	github.com/go-playground/locales v0.14.1 // indirect
+	github.com/go-playground/universal-translator v0.18.1 // indirect
+	github.com/go-playground/validator/v10 v10.22.0 // indirect
+	github.com/gobwas/glob v0.2.3 // indirect
+	github.com/leodido/go-urn v1.4.0 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/romshark/yamagiconf v1.0.0 // indirect
+	go.starlark.net v0.0.0-20240725214946-42030a7cedce // indirect
+	golang.org/x/crypto v0.26.0 // indirect
+	golang.org/x/net v0.28.0 // indirect
+	golang.org/x/sys v0.24.0 // indirect
+	golang.org/x/text v0.17.0 // indirect
+)
@@ -0,0 +1,110 @@
+// Command httpsim provides tooling for httpsim config files
+// and serves them as standalone simulators.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/romshark/httpsim/config"
+	"github.com/romshark/httpsim/starlarksim"
+)
+
+func main() { os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr)) }
+
+// loadOptions are passed to all loaders such that the scripts,
+// which are run by starlarksim, are validated when loading.
+var loadOptions = []config.LoadOption{
+	config.WithScriptValidator(starlarksim.Validate),
+}
+
+// command is a subcommand such as "httpsim validate".
+type command struct {
+	name    string
+	summary string
+	run     func(args []string, stdin io.Reader, stdout, stderr io.Writer) int
+}
+
+var commands = []command{
+	{"validate", "validate config files", runValidate},
+	{"mock", "serve a config without an upstream", runMock},
+	{"proxy", "serve a config in front of an upstream", runProxy},
+	{"record", "record an upstream into a config", runRecord},
+	{"replay", "serve a recorded config with its latencies", runReplay},
+	{"scaffold", "generate a config from an OpenAPI spec", runScaffold},
+	{"test", "test which resources sample requests match", runTest},
+	{"explain", "explain why resources match a request", runExplain},
+}
+
+// run executes the command with args and returns the exit code.
+// Without args it runs the proxy command if EnvConfig and EnvUpstream
+// are set and the mock command if only EnvConfig is set, such that
+// the command can be configured from the environment alone.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 && os.Getenv(EnvConfig) != "" {
+		if os.Getenv(EnvUpstream) != "" {
+			return runProxy(nil, stdin, stdout, stderr)
+		}
+		return runMock(nil, stdin, stdout, stderr)
+	}
+	if len(args) > 0 {
+		for _, c := range commands {
+			if c.name == args[0] {
+				return c.run(args[1:], stdin, stdout, stderr)
+			}
+		}
+	}
+	fs := flag.NewFlagSet("httpsim", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: httpsim [flags]\n       httpsim <command> [arguments]\n\nCommands:\n")
+		for _, c := range commands {
+			fmt.Fprintf(stderr, "  %-10s %s\n", c.name, c.summary)
+		}
+		fmt.Fprintf(stderr, "\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	jsonSchema := fs.Bool("json-schema", false,
+		"print the JSON Schema of the config format and exit")
+	fromCurl := fs.String("from-curl", "",
+		"print a config with a resource matching the given curl command, "+
+			"- reads it from stdin")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *jsonSchema {
+		if _, err := fmt.Fprintf(stdout, "%s\n", config.JSONSchema()); err != nil {
+			return 1
+		}
+		return 0
+	}
+	if *fromCurl != "" {
+		command := *fromCurl
+		if command == "-" {
+			b, err := io.ReadAll(stdin)
+			if err != nil {
+				fmt.Fprintf(stderr, "reading stdin: %v\n", err)
+				return 1
+			}
+			command = string(b)
+		}
+		c, err := config.FromCurl(command)
+		if err != nil {
+			fmt.Fprintf(stderr, "%v\n", err)
+			return 1
+		}
+		b, err := config.Dump(*c)
+		if err != nil {
+			fmt.Fprintf(stderr, "%v\n", err)
+			return 1
+		}
+		if _, err := stdout.Write(b); err != nil {
+			return 1
+		}
+		return 0
+	}
+	fs.Usage()
+	return 2
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONSchema(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	require.Equal(t, 0, run([]string{"-json-schema"}, nil, &stdout, &stderr))
+	require.True(t, json.Valid(stdout.Bytes()))
+	require.Empty(t, stderr.String())
+}
+
+func TestUsage(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	require.Equal(t, 2, run(nil, nil, &stdout, &stderr))
+	require.Empty(t, stdout.String())
+	require.Contains(t, stderr.String(), "-json-schema")
+}
+
+func TestFromCurl(t *testing.T) {
+	const command = `curl -X POST 'https://example.com/orders?id=1' -H 'X-Tenant: acme'`
+	f := func(t *testing.T, args []string, stdin string) {
+		t.Helper()
+		var stdout, stderr bytes.Buffer
+		require.Equal(t, 0, run(args, strings.NewReader(stdin), &stdout, &stderr))
+		require.Empty(t, stderr.String())
+		require.Contains(t, stdout.String(), "path: /orders")
+		require.Contains(t, stdout.String(), "X-Tenant")
+		require.Contains(t, stdout.String(), "POST")
+	}
+	f(t, []string{"-from-curl", command}, "")
+	f(t, []string{"-from-curl", "-"}, command)
+
+	var stdout, stderr bytes.Buffer
+	require.Equal(t, 1, run([]string{"-from-curl", "wget x"}, nil, &stdout, &stderr))
+	require.Empty(t, stdout.String())
+	require.Contains(t, stderr.String(), "invalid curl command")
+}
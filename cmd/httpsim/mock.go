@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/romshark/httpsim"
+	"github.com/romshark/httpsim/config"
+	"github.com/romshark/httpsim/starlarksim"
+)
+
+// runMock serves requests from the effects of a config file alone,
+// responding to requests that aren't replaced with a default response.
+func runMock(args []string, _ io.Reader, _, stderr io.Writer) int {
+	flags := flag.NewFlagSet("httpsim mock", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: httpsim mock -config <file> [flags]\n\n"+
+			"Serves the responses of the config without an upstream.\n"+
+			"Requests that aren't replaced get the default response.\n"+
+			"The config defaults to the inline YAML of $%s and\n"+
+			"the listen address to $%s.\n\n", EnvConfig, EnvListen)
+		flags.PrintDefaults()
+	}
+	configFile := flags.String("config", "", "config file to serve")
+	listen := flags.String("listen", envOr(EnvListen, ":9090"), "address to listen on")
+	defaultStatus := flags.Int("default-status", http.StatusNotFound,
+		"status code of requests that aren't replaced")
+	defaultBody := flags.String("default-body", "",
+		"body of requests that aren't replaced, defaults to the status text")
+	watch := flags.Bool("watch", false, "reload the config file when it changes")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() > 0 {
+		flags.Usage()
+		return 2
+	}
+	if *defaultStatus < 100 || *defaultStatus > 999 {
+		fmt.Fprintf(stderr, "invalid default status code: %d\n", *defaultStatus)
+		return 2
+	}
+	if *watch && *configFile == "" {
+		fmt.Fprintln(stderr, "-watch requires -config")
+		return 2
+	}
+	c, name, err := loadConfig(*configFile)
+	if errors.Is(err, errNoConfig) {
+		flags.Usage()
+		return 2
+	} else if err != nil {
+		fmt.Fprintln(stderr, formatError(name, err))
+		return 1
+	}
+
+	return serveConfig(stderr, *c, serveOptions{
+		configName: name, configFile: *configFile, listen: *listen, watch: *watch,
+		next:    defaultHandler(*defaultStatus, *defaultBody),
+		sleeper: httpsim.DefaultSleep,
+	})
+}
+
+// serveOptions are the options of serveConfig.
+type serveOptions struct {
+	// configName identifies the config in the logs.
+	configName string
+
+	configFile, listen string
+	watch              bool
+	next               http.Handler
+	sleeper            httpsim.Sleeper
+}
+
+// serveConfig serves a middleware simulating c, which was loaded from
+// o.configFile if set, logging every request to stderr until interrupted.
+func serveConfig(stderr io.Writer, c config.Config, o serveOptions) int {
+	m := httpsim.NewMiddleware(o.next, c, o.sleeper, httpsim.DefaultRand)
+	m.SetScriptEngine(starlarksim.Engine{})
+	logger := slog.New(slog.NewTextHandler(stderr, nil))
+	m.SetLogger(logger, httpsim.LogLevels{
+		NoMatch: slog.LevelInfo, Match: slog.LevelInfo, Effect: slog.LevelInfo,
+	})
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if o.watch {
+		go func() {
+			err := httpsim.WatchConfigFile(ctx, o.configFile, m, func(err error) {
+				logger.Error("reloading config", slog.String("err", err.Error()))
+			}, loadOptions...)
+			if ctx.Err() == nil && !errors.Is(err, httpsim.ErrClosed) {
+				logger.Error("watching config", slog.String("err", err.Error()))
+			}
+		}()
+	}
+	ln, err := net.Listen("tcp", o.listen)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	logger.Info("listening", slog.String("addr", ln.Addr().String()),
+		slog.String("config", o.configName))
+	if err := serve(ctx, ln, m); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// defaultHandler responds with status and body,
+// or the text of status if body is empty.
+func defaultHandler(status int, body string) http.Handler {
+	if body == "" {
+		body = http.StatusText(status)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(status)
+		_, _ = io.WriteString(w, body)
+	})
+}
+
+// shutdownTimeout limits how long serve waits for active requests
+// to complete after ctx is canceled.
+const shutdownTimeout = 5 * time.Second
+
+// serve serves h on ln until ctx is canceled and shuts down gracefully.
+// If h is an io.Closer, such as *httpsim.Middleware, it's closed before
+// the shutdown such that injected delays don't hold it up.
+func serve(ctx context.Context, ln net.Listener, h http.Handler) error {
+	srv := &http.Server{Handler: h, ReadHeaderTimeout: 10 * time.Second}
+	errs := make(chan error, 1)
+	go func() { errs <- srv.Serve(ln) }()
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+	if c, ok := h.(io.Closer); ok {
+		_ = c.Close()
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errs; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim"
+)
+
+func TestMockUsage(t *testing.T) {
+	dir := t.TempDir()
+	invalid := filepath.Join(dir, "invalid.yaml")
+	require.NoError(t, os.WriteFile(invalid, []byte("version: 999\n"), 0o600))
+
+	f := func(t *testing.T, args []string, expectCode int, expectStderr string) {
+		t.Helper()
+		var stdout, stderr bytes.Buffer
+		require.Equal(t, expectCode, run(append([]string{"mock"}, args...),
+			nil, &stdout, &stderr))
+		require.Empty(t, stdout.String())
+		require.Contains(t, stderr.String(), expectStderr)
+	}
+	f(t, nil, 2, "Usage: httpsim mock")
+	f(t, []string{"-config", invalid, "extra"}, 2, "Usage: httpsim mock")
+	f(t, []string{"-config", invalid, "-default-status", "42"}, 2,
+		"invalid default status code: 42")
+	f(t, []string{"-config", invalid}, 1, invalid+":1:10: version:")
+}
+
+func TestMockServe(t *testing.T) {
+	c, err := httpsim.LoadConfig(strings.NewReader(`
+resources:
+  - path: /users
+    methods: [GET]
+    effect:
+      replace:
+        status-code: 200
+        headers:
+          Content-Type: application/json
+        body: '[{"id":1}]'
+  - path: /slow
+    effect:
+      delay: 1ms
+`))
+	require.NoError(t, err)
+	m := httpsim.NewMiddleware(
+		defaultHandler(http.StatusNotImplemented, ""), *c, nil, nil,
+	)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() { errs <- serve(ctx, ln, m) }()
+
+	get := func(path string) (int, string) {
+		t.Helper()
+		resp, err := http.Get("http://" + ln.Addr().String() + path)
+		require.NoError(t, err)
+		defer func() { _ = resp.Body.Close() }()
+		b, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(b)
+	}
+	status, body := get("/users")
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, `[{"id":1}]`, body)
+
+	status, body = get("/slow")
+	require.Equal(t, http.StatusNotImplemented, status)
+	require.Equal(t, "Not Implemented", body)
+
+	status, _ = get("/unknown")
+	require.Equal(t, http.StatusNotImplemented, status)
+
+	cancel()
+	require.NoError(t, <-errs)
+}
+
+func TestDefaultHandler(t *testing.T) {
+	rec := httptest.NewRecorder()
+	defaultHandler(http.StatusTeapot, "nope").ServeHTTP(rec,
+		httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+	require.Equal(t, http.StatusTeapot, rec.Code)
+	require.Equal(t, "nope", rec.Body.String())
+}
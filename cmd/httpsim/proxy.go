@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+
+	"github.com/romshark/httpsim"
+)
+
+// runProxy serves a config in front of an upstream.
+func runProxy(args []string, _ io.Reader, _, stderr io.Writer) int {
+	flags := flag.NewFlagSet("httpsim proxy", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: httpsim proxy -config <file> -upstream <url> [flags]\n\n"+
+			"Simulates the config in front of the upstream, passing requests\n"+
+			"that aren't replaced through to it. The config defaults to the\n"+
+			"inline YAML of $%s, the upstream to $%s and the listen\n"+
+			"address to $%s.\n\n", EnvConfig, EnvUpstream, EnvListen)
+		flags.PrintDefaults()
+	}
+	configFile := flags.String("config", "", "config file to serve")
+	upstream := flags.String("upstream", envOr(EnvUpstream, ""), "URL of the upstream")
+	listen := flags.String("listen", envOr(EnvListen, ":9090"), "address to listen on")
+	watch := flags.Bool("watch", false, "reload the config file when it changes")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *upstream == "" || flags.NArg() > 0 {
+		flags.Usage()
+		return 2
+	}
+	u, err := parseUpstream(*upstream)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	if *watch && *configFile == "" {
+		fmt.Fprintln(stderr, "-watch requires -config")
+		return 2
+	}
+	c, name, err := loadConfig(*configFile)
+	if errors.Is(err, errNoConfig) {
+		flags.Usage()
+		return 2
+	} else if err != nil {
+		fmt.Fprintln(stderr, formatError(name, err))
+		return 1
+	}
+
+	return serveConfig(stderr, *c, serveOptions{
+		configName: name, configFile: *configFile, listen: *listen, watch: *watch,
+		next: upstreamProxy(u), sleeper: httpsim.DefaultSleep,
+	})
+}
+
+// upstreamProxy returns a reverse proxy forwarding requests to upstream.
+func upstreamProxy(upstream *url.URL) http.Handler {
+	return &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(upstream)
+			pr.SetXForwarded()
+		},
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim"
+)
+
+func TestProxyUsage(t *testing.T) {
+	f := func(t *testing.T, args []string, expectCode int, expectStderr string) {
+		t.Helper()
+		var stdout, stderr bytes.Buffer
+		require.Equal(t, expectCode, run(append([]string{"proxy"}, args...),
+			nil, &stdout, &stderr))
+		require.Empty(t, stdout.String())
+		require.Contains(t, stderr.String(), expectStderr)
+	}
+	f(t, nil, 2, "Usage: httpsim proxy")
+	f(t, []string{"-upstream", "localhost"}, 2, `invalid upstream URL: "localhost"`)
+	f(t, []string{"-upstream", "http://localhost"}, 2, "Usage: httpsim proxy")
+	f(t, []string{"-upstream", "http://localhost", "-watch"}, 2, "-watch requires -config")
+	f(t, []string{"-upstream", "http://localhost", "-config", "missing.yaml"}, 1,
+		"missing.yaml: opening")
+}
+
+func TestUpstreamProxy(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "upstream "+r.URL.Path)
+	}))
+	t.Cleanup(upstream.Close)
+	u, err := url.Parse(upstream.URL)
+	require.NoError(t, err)
+
+	c, err := httpsim.LoadConfig(strings.NewReader(`
+resources:
+  - path: /down
+    effect:
+      replace:
+        status-code: 503
+`))
+	require.NoError(t, err)
+	m := httpsim.NewMiddleware(upstreamProxy(u), *c, nil, nil)
+	serve := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		m.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, http.NoBody))
+		return rec
+	}
+	require.Equal(t, "upstream /users", serve("/users").Body.String())
+	require.Equal(t, http.StatusServiceUnavailable, serve("/down").Code)
+}
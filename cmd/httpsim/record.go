@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/romshark/httpsim/config"
+)
+
+// runRecord proxies requests to an upstream and writes a config
+// replaying the recorded responses when it's interrupted.
+func runRecord(args []string, _ io.Reader, _, stderr io.Writer) int {
+	flags := flag.NewFlagSet("httpsim record", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: httpsim record -upstream <url> -out <file> [flags]\n\n"+
+			"Proxies requests to the upstream and, once interrupted, writes\n"+
+			"a config whose resources replay the recorded responses.\n\n")
+		flags.PrintDefaults()
+	}
+	upstream := flags.String("upstream", "", "URL of the upstream to record (required)")
+	out := flags.String("out", "", "config file to write (required)")
+	listen := flags.String("listen", ":9090", "address to listen on")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *upstream == "" || *out == "" || flags.NArg() > 0 {
+		flags.Usage()
+		return 2
+	}
+	u, err := parseUpstream(*upstream)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+
+	logger := slog.New(slog.NewTextHandler(stderr, nil))
+	rec := newRecorder(u, logger)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ln, err := net.Listen("tcp", *listen)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	logger.Info("recording", slog.String("addr", ln.Addr().String()),
+		slog.String("upstream", u.String()))
+	if err := serve(ctx, ln, rec); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	if err := rec.write(*out); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	logger.Info("recording written", slog.String("file", *out))
+	return 0
+}
+
+// recorder is a reverse proxy recording the exchanges with the upstream.
+type recorder struct {
+	proxy *httputil.ReverseProxy
+
+	lock      sync.Mutex
+	exchanges []config.Exchange
+}
+
+// recordedRequest is the incoming request and the time it arrived at.
+type recordedRequest struct {
+	*http.Request
+	start time.Time
+}
+
+type ctxKeyRecordedRequest struct{}
+
+func newRecorder(upstream *url.URL, logger *slog.Logger) *recorder {
+	rec := &recorder{}
+	rec.proxy = &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(upstream)
+			pr.SetXForwarded()
+			// Let the transport negotiate compression and decode
+			// the response since the bodies are recorded decoded.
+			pr.Out.Header.Del("Accept-Encoding")
+		},
+		ModifyResponse: func(resp *http.Response) error {
+			r := rec.request(resp)
+			wait := time.Since(r.start)
+			body, err := io.ReadAll(resp.Body)
+			_ = resp.Body.Close()
+			if err != nil {
+				return fmt.Errorf("reading response body: %w", err)
+			}
+			resp.Body = io.NopCloser(bytes.NewReader(body))
+			rec.lock.Lock()
+			rec.exchanges = append(rec.exchanges, config.Exchange{
+				Method: r.Method, URL: r.URL,
+				StatusCode: resp.StatusCode, Header: resp.Header.Clone(),
+				Body: body, Wait: wait,
+			})
+			rec.lock.Unlock()
+			logger.Info("recorded",
+				slog.String("method", r.Method), slog.String("url", r.URL.String()),
+				slog.Int("status", resp.StatusCode))
+			return nil
+		},
+	}
+	return rec
+}
+
+func (rec *recorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := context.WithValue(r.Context(), ctxKeyRecordedRequest{}, recordedRequest{
+		Request: r, start: time.Now(),
+	})
+	rec.proxy.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// request returns the incoming request resp responds to.
+func (rec *recorder) request(resp *http.Response) recordedRequest {
+	return resp.Request.Context().Value(ctxKeyRecordedRequest{}).(recordedRequest)
+}
+
+// write writes the config replaying the recorded exchanges to file.
+func (rec *recorder) write(file string) error {
+	rec.lock.Lock()
+	defer rec.lock.Unlock()
+	c, err := config.FromExchanges(rec.exchanges)
+	if err != nil {
+		return err
+	}
+	b, err := config.Dump(*c)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, b, 0o644)
+}
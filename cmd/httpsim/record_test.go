@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim"
+)
+
+func TestRecordUsage(t *testing.T) {
+	f := func(t *testing.T, args []string, expectStderr string) {
+		t.Helper()
+		var stdout, stderr bytes.Buffer
+		require.Equal(t, 2, run(append([]string{"record"}, args...), nil, &stdout, &stderr))
+		require.Empty(t, stdout.String())
+		require.Contains(t, stderr.String(), expectStderr)
+	}
+	f(t, nil, "Usage: httpsim record")
+	f(t, []string{"-upstream", "http://localhost"}, "Usage: httpsim record")
+	f(t, []string{"-out", "x.yaml"}, "Usage: httpsim record")
+	f(t, []string{"-upstream", "localhost:8080", "-out", "x.yaml"},
+		`invalid upstream URL: "localhost:8080"`)
+}
+
+func TestRecord(t *testing.T) {
+	calls := 0
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/users":
+			calls++
+			if calls > 1 {
+				http.Error(w, "down", http.StatusServiceUnavailable)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = io.WriteString(w, `[{"id":1}]`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(upstream.Close)
+	u, err := url.Parse(upstream.URL + "/api")
+	require.NoError(t, err)
+
+	rec := newRecorder(u, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() { errs <- serve(ctx, ln, rec) }()
+
+	get := func(path string) (int, string) {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, "http://"+ln.Addr().String()+path, http.NoBody)
+		require.NoError(t, err)
+		req.Header.Set("Accept-Encoding", "gzip")
+		resp, err := http.DefaultTransport.RoundTrip(req)
+		require.NoError(t, err)
+		defer func() { _ = resp.Body.Close() }()
+		b, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(b)
+	}
+	status, body := get("/users?page=1")
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, `[{"id":1}]`, body)
+	status, _ = get("/users?page=1")
+	require.Equal(t, http.StatusServiceUnavailable, status)
+	status, _ = get("/missing")
+	require.Equal(t, http.StatusNotFound, status)
+	cancel()
+	require.NoError(t, <-errs)
+
+	out := filepath.Join(t.TempDir(), "recorded.yaml")
+	require.NoError(t, rec.write(out))
+
+	// The recorded config replays the session without the upstream.
+	c, err := httpsim.LoadConfigFile(out)
+	require.NoError(t, err)
+	require.Len(t, c.Resources, 2)
+	m := httpsim.NewMiddleware(defaultHandler(http.StatusTeapot, ""), *c, nil, nil)
+	replay := func(path string) (int, string) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, http.NoBody))
+		return w.Code, w.Body.String()
+	}
+	status, body = replay("/users?page=1")
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, `[{"id":1}]`, body)
+	status, body = replay("/users?page=1")
+	require.Equal(t, http.StatusServiceUnavailable, status)
+	require.Equal(t, "down", strings.TrimSpace(body))
+	status, _ = replay("/missing")
+	require.Equal(t, http.StatusNotFound, status)
+	status, _ = replay("/users?page=2")
+	require.Equal(t, http.StatusTeapot, status)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/romshark/httpsim"
+)
+
+// runReplay serves a recorded config reproducing the recorded latencies
+// scaled by a speed factor.
+func runReplay(args []string, _ io.Reader, _, stderr io.Writer) int {
+	flags := flag.NewFlagSet("httpsim replay", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: httpsim replay [flags] <file>\n\n"+
+			"Serves the responses of a config, such as one written by\n"+
+			"httpsim record, reproducing the recorded latencies.\n"+
+			"Requests that aren't replaced get 404.\n\n")
+		flags.PrintDefaults()
+	}
+	listen := flags.String("listen", ":9090", "address to listen on")
+	speed := flags.Float64("speed", 1,
+		"speed factor the latencies are divided by, 2 replays twice as fast")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+	if *speed <= 0 {
+		fmt.Fprintf(stderr, "invalid speed: %v, must be positive\n", *speed)
+		return 2
+	}
+	file := flags.Arg(0)
+	c, err := httpsim.LoadConfigFile(file, loadOptions...)
+	if err != nil {
+		fmt.Fprintln(stderr, formatError(file, err))
+		return 1
+	}
+	return serveConfig(stderr, *c, serveOptions{
+		configName: file, configFile: file, listen: *listen,
+		next:    defaultHandler(http.StatusNotFound, ""),
+		sleeper: scaledSleep(*speed),
+	})
+}
+
+// scaledSleep sleeps for durations divided by its speed factor.
+type scaledSleep float64
+
+var _ httpsim.CtxSleeper = scaledSleep(1)
+
+func (s scaledSleep) scale(d time.Duration) time.Duration {
+	return time.Duration(float64(d) / float64(s))
+}
+
+func (s scaledSleep) Sleep(d time.Duration) { httpsim.DefaultSleep.Sleep(s.scale(d)) }
+
+func (s scaledSleep) SleepCtx(ctx context.Context, d time.Duration) error {
+	return httpsim.DefaultSleep.SleepCtx(ctx, s.scale(d))
+}
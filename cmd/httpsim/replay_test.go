@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim"
+)
+
+func TestReplayUsage(t *testing.T) {
+	valid := filepath.Join(t.TempDir(), "valid.yaml")
+	require.NoError(t, os.WriteFile(valid, []byte("resources: []\n"), 0o600))
+
+	f := func(t *testing.T, args []string, expectCode int, expectStderr string) {
+		t.Helper()
+		var stdout, stderr bytes.Buffer
+		require.Equal(t, expectCode, run(append([]string{"replay"}, args...),
+			nil, &stdout, &stderr))
+		require.Empty(t, stdout.String())
+		require.Contains(t, stderr.String(), expectStderr)
+	}
+	f(t, nil, 2, "Usage: httpsim replay")
+	f(t, []string{valid, valid}, 2, "Usage: httpsim replay")
+	f(t, []string{"-speed", "0", valid}, 2, "invalid speed: 0")
+	f(t, []string{"missing.yaml"}, 1, "missing.yaml: opening")
+}
+
+func TestScaledSleep(t *testing.T) {
+	c, err := httpsim.LoadConfig(strings.NewReader(`
+resources:
+  - path: /slow
+    effect:
+      delay: 2s
+`))
+	require.NoError(t, err)
+	m := httpsim.NewMiddleware(defaultHandler(http.StatusNotFound, ""), *c,
+		scaledSleep(100), nil)
+
+	start := time.Now()
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/slow", http.NoBody))
+	elapsed := time.Since(start)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+	require.GreaterOrEqual(t, elapsed, 20*time.Millisecond)
+	require.Less(t, elapsed, time.Second)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.ErrorIs(t, scaledSleep(0.5).SleepCtx(ctx, time.Second), context.Canceled)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/romshark/httpsim/config"
+)
+
+// runScaffold prints a starter config generated from an OpenAPI spec.
+func runScaffold(args []string, _ io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("httpsim scaffold", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: httpsim scaffold <openapi-spec>\n\n"+
+			"Prints a config with one resource per operation of the OpenAPI 3\n"+
+			"spec replacing responses with the examples of the spec.\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+	file := flags.Arg(0)
+	c, err := config.FromOpenAPI(file)
+	if err != nil {
+		fmt.Fprintln(stderr, formatError(file, err))
+		return 1
+	}
+	b, err := config.Dump(*c)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	if _, err := stdout.Write(b); err != nil {
+		return 1
+	}
+	return 0
+}
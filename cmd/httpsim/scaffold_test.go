@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim"
+)
+
+func TestScaffold(t *testing.T) {
+	spec := filepath.Join(t.TempDir(), "openapi.yaml")
+	require.NoError(t, os.WriteFile(spec, []byte(`
+openapi: 3.0.3
+info: {title: Shop, version: "1"}
+paths:
+  /orders/{id}:
+    get:
+      operationId: getOrder
+      responses:
+        "200":
+          description: OK
+          content:
+            application/json:
+              example: {id: 1, total: 42}
+`), 0o600))
+
+	var stdout, stderr bytes.Buffer
+	require.Equal(t, 0, run([]string{"scaffold", spec}, nil, &stdout, &stderr))
+	require.Empty(t, stderr.String())
+	require.Contains(t, stdout.String(), "name: getOrder")
+
+	// The output is a valid config.
+	c, err := httpsim.LoadConfig(strings.NewReader(stdout.String()))
+	require.NoError(t, err)
+	require.Len(t, c.Resources, 1)
+	require.JSONEq(t, `{"id":1,"total":42}`, *c.Resources[0].Effect.Replace.Body)
+}
+
+func TestScaffoldErr(t *testing.T) {
+	f := func(t *testing.T, args []string, expectCode int, expectStderr string) {
+		t.Helper()
+		var stdout, stderr bytes.Buffer
+		require.Equal(t, expectCode, run(append([]string{"scaffold"}, args...),
+			nil, &stdout, &stderr))
+		require.Empty(t, stdout.String())
+		require.Contains(t, stderr.String(), expectStderr)
+	}
+	f(t, nil, 2, "Usage: httpsim scaffold")
+	f(t, []string{"a.yaml", "b.yaml"}, 2, "Usage: httpsim scaffold")
+	f(t, []string{"missing.yaml"}, 1, "missing.yaml: opening")
+
+	notOpenAPI := filepath.Join(t.TempDir(), "swagger.yaml")
+	require.NoError(t, os.WriteFile(notOpenAPI, []byte("swagger: '2.0'\n"), 0o600))
+	f(t, []string{notOpenAPI}, 1, notOpenAPI+": ")
+}
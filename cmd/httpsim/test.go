@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/romshark/httpsim"
+	"github.com/romshark/httpsim/config"
+)
+
+// fixture is a sample request and the resource it's expected to match.
+type fixture struct {
+	// Method defaults to GET.
+	Method  string            `yaml:"method"`
+	URL     string            `yaml:"url"`
+	Headers map[string]string `yaml:"headers"`
+	Body    string            `yaml:"body"`
+
+	// Expect is the name or index of the resource the request is expected
+	// to match or "none". If nil, the match is reported but not checked.
+	Expect *string `yaml:"expect"`
+}
+
+// expectNone is the expectation of requests matching no resource.
+const expectNone = "none"
+
+// runTest matches sample requests against a config
+// and fails if any doesn't match the expected resource.
+func runTest(args []string, _ io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("httpsim test", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: httpsim test -config <file> -requests <file>\n\n"+
+			"Matches the sample requests against the config and reports the\n"+
+			"resource each matches. Fails if any request doesn't match the\n"+
+			"resource it expects. The requests file is a YAML list of:\n\n"+
+			"  - method: POST # Optional, defaults to GET.\n"+
+			"    url: https://example.com/orders?id=1\n"+
+			"    headers: {X-Tenant: acme} # Optional.\n"+
+			"    body: '{}' # Optional.\n"+
+			"    expect: orders # Name or index of the resource, or none.\n\n")
+		flags.PrintDefaults()
+	}
+	configFile := flags.String("config", "", "config file to test (required)")
+	requestsFile := flags.String("requests", "", "requests file (required)")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *configFile == "" || *requestsFile == "" || flags.NArg() > 0 {
+		flags.Usage()
+		return 2
+	}
+	c, err := httpsim.LoadConfigFile(*configFile, loadOptions...)
+	if err != nil {
+		fmt.Fprintln(stderr, formatError(*configFile, err))
+		return 1
+	}
+	fixtures, err := loadFixtures(*requestsFile)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %v\n", *requestsFile, err)
+		return 1
+	}
+
+	var passed, failed int
+	for i, f := range fixtures {
+		r, err := f.request()
+		if err != nil {
+			fmt.Fprintf(stderr, "%s: [%d]: %v\n", *requestsFile, i, err)
+			return 1
+		}
+		matched := resourceID(c, httpsim.Match(r, c))
+		switch {
+		case f.Expect == nil:
+			fmt.Fprintf(stdout, "     %s %s matched %s\n", r.Method, f.URL, matched)
+		case expectationMet(c, *f.Expect, matched):
+			passed++
+			fmt.Fprintf(stdout, "PASS %s %s matched %s\n", r.Method, f.URL, matched)
+		default:
+			failed++
+			fmt.Fprintf(stdout, "FAIL %s %s matched %s, expected %s\n",
+				r.Method, f.URL, matched, *f.Expect)
+		}
+	}
+	fmt.Fprintf(stdout, "%d passed, %d failed\n", passed, failed)
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+func loadFixtures(file string) ([]fixture, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var fixtures []fixture
+	d := yaml.NewDecoder(bytes.NewReader(b))
+	d.KnownFields(true)
+	if err := d.Decode(&fixtures); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("decoding YAML: %w", err)
+	}
+	return fixtures, nil
+}
+
+func (f *fixture) request() (*http.Request, error) {
+	method := f.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	r, err := http.NewRequest(method, f.URL, strings.NewReader(f.Body))
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range f.Headers {
+		r.Header.Set(name, value)
+	}
+	return r, nil
+}
+
+// resourceID returns the name of resource i of c if it has one,
+// otherwise its index, or "none" if i is -1.
+func resourceID(c *config.Config, i int) string {
+	switch {
+	case i < 0:
+		return expectNone
+	case c.Resources[i].Name != "":
+		return c.Resources[i].Name
+	}
+	return strconv.Itoa(i)
+}
+
+// expectationMet returns true if the resource identified by matched
+// is the one identified by expect.
+func expectationMet(c *config.Config, expect, matched string) bool {
+	if expect == matched {
+		return true
+	}
+	// Named resources can also be expected by index.
+	i, err := strconv.Atoi(expect)
+	return err == nil && i >= 0 && i < len(c.Resources) &&
+		resourceID(c, i) == matched
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTest(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		t.Helper()
+		file := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(file, []byte(content), 0o600))
+		return file
+	}
+	conf := write("sim.yaml", `
+resources:
+  - name: create-order
+    path: /orders
+    methods: [POST]
+    headers:
+      X-Tenant: [acme]
+  - path: /orders/*
+`)
+
+	f := func(t *testing.T, requests string, expectCode int, expectStdout string) {
+		t.Helper()
+		var stdout, stderr bytes.Buffer
+		code := run([]string{
+			"test", "-config", conf, "-requests", write("requests.yaml", requests),
+		}, nil, &stdout, &stderr)
+		require.Equal(t, expectCode, code, stderr.String())
+		require.Empty(t, stderr.String())
+		require.Equal(t, expectStdout, stdout.String())
+	}
+
+	f(t, `
+- method: POST
+  url: https://shop.example.com/orders
+  headers: {X-Tenant: acme}
+  expect: create-order
+- method: POST
+  url: /orders
+  headers: {X-Tenant: acme}
+  expect: "0"
+- url: /orders/42
+  expect: "1"
+- url: /users
+  expect: none
+- url: /orders/1
+`, 0, ""+
+		"PASS POST https://shop.example.com/orders matched create-order\n"+
+		"PASS POST /orders matched create-order\n"+
+		"PASS GET /orders/42 matched 1\n"+
+		"PASS GET /users matched none\n"+
+		"     GET /orders/1 matched 1\n"+
+		"4 passed, 0 failed\n")
+
+	f(t, `
+- method: POST
+  url: /orders
+  headers: {X-Tenant: other}
+  expect: create-order
+- url: /orders/42
+  expect: none
+- url: /orders/42
+  expect: "1"
+`, 1, ""+
+		"FAIL POST /orders matched none, expected create-order\n"+
+		"FAIL GET /orders/42 matched 1, expected none\n"+
+		"PASS GET /orders/42 matched 1\n"+
+		"1 passed, 2 failed\n")
+
+	f(t, "", 0, "0 passed, 0 failed\n")
+}
+
+func TestTestErr(t *testing.T) {
+	dir := t.TempDir()
+	conf := filepath.Join(dir, "sim.yaml")
+	require.NoError(t, os.WriteFile(conf, []byte("resources: []\n"), 0o600))
+	requests := filepath.Join(dir, "requests.yaml")
+
+	f := func(t *testing.T, args []string, requestsContent string,
+		expectCode int, expectStderr string,
+	) {
+		t.Helper()
+		require.NoError(t, os.WriteFile(requests, []byte(requestsContent), 0o600))
+		var stdout, stderr bytes.Buffer
+		require.Equal(t, expectCode, run(append([]string{"test"}, args...),
+			nil, &stdout, &stderr))
+		require.Empty(t, stdout.String())
+		require.Contains(t, stderr.String(), expectStderr)
+	}
+	f(t, nil, "", 2, "Usage: httpsim test")
+	f(t, []string{"-config", conf}, "", 2, "Usage: httpsim test")
+	f(t, []string{"-config", "missing.yaml", "-requests", requests}, "",
+		1, "missing.yaml: opening")
+	f(t, []string{"-config", conf, "-requests", requests}, "- uri: /\n",
+		1, requests+": decoding YAML: yaml: unmarshal errors:\n  line 1: field uri not found")
+	f(t, []string{"-config", conf, "-requests", requests}, "- url: \"%\"\n",
+		1, requests+": [0]: parse")
+}
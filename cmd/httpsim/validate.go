@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/romshark/httpsim/config"
+)
+
+// runValidate loads and validates the config files in args,
+// reports every invalid one to stderr and exits with 1 if any is invalid.
+func runValidate(args []string, stdin io.Reader, _, stderr io.Writer) int {
+	flags := flag.NewFlagSet("httpsim validate", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: httpsim validate <file...>\n\n"+
+			"Loads and validates config files including their includes.\n"+
+			"A file named - is read from stdin.\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() < 1 {
+		flags.Usage()
+		return 2
+	}
+	code := 0
+	for _, file := range flags.Args() {
+		var err error
+		if file == "-" {
+			_, err = config.Load(stdin, loadOptions...)
+		} else {
+			_, err = config.LoadFile(file, loadOptions...)
+		}
+		if err != nil {
+			fmt.Fprintln(stderr, formatError(file, err))
+			code = 1
+		}
+	}
+	return code
+}
+
+// formatError formats err of loading file as "file:line:column: message"
+// or "file: message" if the location is unknown, which is understood
+// by editors and CI annotations.
+func formatError(file string, err error) string {
+	if file == "-" {
+		file = "<stdin>"
+	}
+	var errValidation *config.ErrValidation
+	if errors.As(err, &errValidation) {
+		if errValidation.File != "" {
+			file = errValidation.File
+		}
+		msg := errValidation.Err.Error()
+		if errValidation.Path != "" {
+			at := errValidation.Path
+			if errValidation.ResourceName != "" {
+				at += fmt.Sprintf(" (resource %q)", errValidation.ResourceName)
+			}
+			msg = at + ": " + msg
+		}
+		if errValidation.Line > 0 {
+			return fmt.Sprintf("%s:%d:%d: %s", file, errValidation.Line, errValidation.Column, msg)
+		}
+		return file + ": " + msg
+	}
+	return fmt.Sprintf("%s: %v", file, err)
+}
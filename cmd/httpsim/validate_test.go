@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		t.Helper()
+		file := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(file, []byte(content), 0o600))
+		return file
+	}
+	valid := write("valid.yaml", `
+resources:
+  - path: /a
+    effect:
+      delay: 1s
+`)
+	invalid := write("invalid.yaml", `
+resources:
+  - name: orders
+    path: /orders
+    effect:
+      replace:
+        status-code: 42
+`)
+	invalidScript := write("invalid-script.yaml", `
+resources:
+  - effect:
+      script:
+        source: "def simulate(request)"
+`)
+	broken := write("broken.yaml", "resources: [\n")
+	includesMissing := write("includes-missing.yaml", "include: [missing.yaml]\n")
+	includesInvalid := write("includes-invalid.yaml", "include: [invalid.yaml]\n")
+
+	f := func(t *testing.T, args []string, stdin string, expectCode int, expectStderr ...string) {
+		t.Helper()
+		var stdout, stderr bytes.Buffer
+		code := run(append([]string{"validate"}, args...),
+			strings.NewReader(stdin), &stdout, &stderr)
+		require.Equal(t, expectCode, code, stderr.String())
+		require.Empty(t, stdout.String())
+		if len(expectStderr) == 0 {
+			require.Empty(t, stderr.String())
+		}
+		for _, s := range expectStderr {
+			require.Contains(t, stderr.String(), s)
+		}
+	}
+
+	f(t, []string{valid}, "", 0)
+	f(t, []string{valid, invalid}, "", 1,
+		invalid+`:7:22: resources[0].effect.replace.status-code (resource "orders"): `+
+			"invalid HTTP response status code: 42")
+	f(t, []string{broken, valid, invalid}, "", 1,
+		broken+": decoding YAML:", invalid+":7:22:")
+	f(t, []string{includesMissing}, "", 1,
+		includesMissing+": include[0]: include matches no files")
+	f(t, []string{includesInvalid}, "", 1, invalid+":7:22:")
+	f(t, []string{invalidScript}, "", 1,
+		invalidScript+":5:9: resources[0].effect.script: invalid script:")
+	f(t, []string{filepath.Join(dir, "nonexistent.yaml")}, "", 1, "nonexistent.yaml: opening")
+	f(t, []string{"-"}, "resources: [{path: /a}]", 0)
+	f(t, []string{"-"}, "version: 999", 1, "<stdin>:1:10: version: unsupported config version")
+	f(t, nil, "", 2, "Usage: httpsim validate")
+}
@@ -0,0 +1,73 @@
+package httpsim
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/romshark/httpsim/config"
+)
+
+// compress returns body compressed as defined by c and sets Content-Encoding
+// on h, or returns body as is if the request doesn't accept the encoding
+// and c respects Accept-Encoding.
+func compress(
+	h http.Header, r *http.Request, c *config.Compress, body []byte,
+) ([]byte, error) {
+	if c.RespectAcceptEncoding {
+		h.Add("Vary", "Accept-Encoding")
+		if !acceptsEncoding(r, string(c.Encoding)) {
+			return body, nil
+		}
+	}
+	var buf bytes.Buffer
+	var cw io.WriteCloser
+	switch c.Encoding {
+	case config.ContentEncodingGzip:
+		cw = gzip.NewWriter(&buf)
+	case config.ContentEncodingDeflate:
+		// HTTP deflate is the zlib format (RFC 9110 section 8.4.1.2),
+		// not raw DEFLATE.
+		var err error
+		if cw, err = zlib.NewWriterLevel(&buf, zlib.DefaultCompression); err != nil {
+			return nil, fmt.Errorf("compressing body: %w", err)
+		}
+	}
+	if _, err := cw.Write(body); err != nil {
+		return nil, fmt.Errorf("compressing body: %w", err)
+	}
+	if err := cw.Close(); err != nil {
+		return nil, fmt.Errorf("compressing body: %w", err)
+	}
+	h.Set("Content-Encoding", string(c.Encoding))
+	return buf.Bytes(), nil
+}
+
+// acceptsEncoding returns true if the Accept-Encoding header of r
+// accepts encoding either explicitly or via "*" with a non-zero quality.
+func acceptsEncoding(r *http.Request, encoding string) bool {
+	wildcard := false
+	for _, h := range r.Header.Values("Accept-Encoding") {
+		for _, e := range strings.Split(h, ",") {
+			name, params, _ := strings.Cut(strings.TrimSpace(e), ";")
+			q := 1.0
+			if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+				if f, err := strconv.ParseFloat(v, 64); err == nil {
+					q = f
+				}
+			}
+			switch name = strings.TrimSpace(name); {
+			case strings.EqualFold(name, encoding):
+				return q > 0
+			case name == "*":
+				wildcard = q > 0
+			}
+		}
+	}
+	return wildcard
+}
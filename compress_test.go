@@ -0,0 +1,101 @@
+package httpsim_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim/config"
+)
+
+func TestCompress(t *testing.T) {
+	body := `{"message":"compressed"}`
+	f := func(
+		c config.Compress, acceptEncoding string,
+		expectEncoding string, expectVary bool,
+	) {
+		t.Helper()
+		_, s := NewSimulator(t, config.Config{
+			Resources: []config.Resource{{Effect: &config.Effect{
+				Replace: &config.Replace{
+					StatusCode: http.StatusOK,
+					Body:       &body,
+					Compress:   &c,
+				},
+			}}},
+		}, func(w http.ResponseWriter, r *http.Request) {
+			t.Fatal("next handler must not be invoked")
+		})
+		req := NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody)
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Equal(t, expectEncoding, rec.Header().Get("Content-Encoding"))
+		if expectVary {
+			require.Equal(t, "Accept-Encoding", rec.Header().Get("Vary"))
+		} else {
+			require.Empty(t, rec.Header().Get("Vary"))
+		}
+
+		var r io.Reader = rec.Body
+		switch expectEncoding {
+		case "gzip":
+			gr, err := gzip.NewReader(r)
+			require.NoError(t, err)
+			r = gr
+		case "deflate":
+			zr, err := zlib.NewReader(r)
+			require.NoError(t, err)
+			r = zr
+		}
+		decoded, err := io.ReadAll(r)
+		require.NoError(t, err)
+		require.Equal(t, body, string(decoded))
+	}
+
+	gzipEncoding := config.Compress{Encoding: config.ContentEncodingGzip}
+	f(gzipEncoding, "", "gzip", false)
+	f(gzipEncoding, "identity", "gzip", false) // Not respected.
+	f(config.Compress{Encoding: config.ContentEncodingDeflate}, "", "deflate", false)
+
+	respect := config.Compress{
+		Encoding: config.ContentEncodingGzip, RespectAcceptEncoding: true,
+	}
+	f(respect, "gzip", "gzip", true)
+	f(respect, "br, GZIP;q=0.5", "gzip", true)
+	f(respect, "*", "gzip", true)
+	f(respect, "", "", true)
+	f(respect, "br, deflate", "", true)
+	f(respect, "gzip;q=0, *", "", true)
+	f(respect, "*;q=0", "", true)
+}
+
+func TestCompressDelayBody(t *testing.T) {
+	body := string(bytes.Repeat([]byte("a"), 1000))
+	_, s := NewSimulator(t, config.Config{
+		Resources: []config.Resource{{Effect: &config.Effect{
+			DelayBody: &config.DurRange{Min: 1, Max: 1},
+			Replace: &config.Replace{
+				StatusCode: http.StatusOK,
+				Body:       &body,
+				Compress:   &config.Compress{Encoding: config.ContentEncodingGzip},
+			},
+		}}},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("next handler must not be invoked")
+	})
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody))
+	// Content-Length is the length of the compressed body.
+	require.Less(t, rec.Body.Len(), len(body))
+	require.Equal(t, rec.Body.Len(), int(rec.Result().ContentLength))
+}
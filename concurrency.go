@@ -0,0 +1,52 @@
+package httpsim
+
+import (
+	"context"
+	"time"
+
+	"github.com/romshark/httpsim/config"
+)
+
+// newSemaphores creates a semaphore for every effect of r
+// that limits concurrency.
+func newSemaphores(r *config.Resource) map[*config.Effect]chan struct{} {
+	s := map[*config.Effect]chan struct{}{}
+	add := func(e *config.Effect) {
+		if e != nil && e.MaxConcurrent != nil {
+			s[e] = make(chan struct{}, e.MaxConcurrent.Limit)
+		}
+	}
+	for _, e := range r.Chain() {
+		add(e)
+	}
+	if r.Sequence != nil {
+		for i := range r.Sequence.Steps {
+			add(r.Sequence.Steps[i].Effect)
+		}
+	}
+	return s
+}
+
+// acquire returns true once a slot in sem is free, or false if none frees up
+// within the queue timeout of c or ctx is canceled.
+func acquire(
+	ctx context.Context, sem chan struct{}, c *config.MaxConcurrent,
+) bool {
+	select {
+	case sem <- struct{}{}:
+		return true
+	default:
+	}
+	if c.QueueTimeout == 0 {
+		return false
+	}
+	t := time.NewTimer(c.QueueTimeout)
+	defer t.Stop()
+	select {
+	case sem <- struct{}{}:
+		return true
+	case <-t.C:
+	case <-ctx.Done():
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+package httpsim_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim/config"
+)
+
+func TestMaxConcurrent(t *testing.T) {
+	f := func(queueTimeout time.Duration, releaseWhileQueued bool, expectCode int) {
+		t.Helper()
+		entered, release := make(chan struct{}), make(chan struct{})
+		_, s := NewSimulator(t, config.Config{
+			Resources: []config.Resource{{Effect: &config.Effect{
+				MaxConcurrent: &config.MaxConcurrent{
+					Limit: 2, QueueTimeout: queueTimeout,
+				},
+			}}},
+		}, func(w http.ResponseWriter, r *http.Request) {
+			entered <- struct{}{}
+			<-release
+			w.WriteHeader(http.StatusOK)
+		})
+
+		// Occupy all slots.
+		var wg sync.WaitGroup
+		codes := make([]int, 2)
+		for i := range codes {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				rec := httptest.NewRecorder()
+				s.ServeHTTP(rec, NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody))
+				codes[i] = rec.Code
+			}()
+			<-entered
+		}
+
+		if releaseWhileQueued {
+			go func() {
+				time.Sleep(10 * time.Millisecond)
+				release <- struct{}{}
+				<-entered // Wait for the queued request.
+				close(release)
+			}()
+		}
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody))
+		require.Equal(t, expectCode, rec.Code)
+
+		if !releaseWhileQueued {
+			close(release)
+		}
+		wg.Wait()
+		require.Equal(t, []int{http.StatusOK, http.StatusOK}, codes)
+	}
+
+	f(0, false, http.StatusServiceUnavailable)
+	f(time.Millisecond, false, http.StatusServiceUnavailable)
+	f(time.Minute, true, http.StatusOK)
+}
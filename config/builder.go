@@ -0,0 +1,238 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// Builder builds configs fluently, for example:
+//
+//	c, err := config.NewBuilder().
+//		Resource("orders").Path("/orders/*").Methods(http.MethodGet).
+//		Delay(100*time.Millisecond, time.Second).
+//		Replace(http.StatusServiceUnavailable, "unavailable").
+//		Build()
+//
+// The first error encountered is returned by Build.
+type Builder struct {
+	config Config
+	err    error
+}
+
+// NewBuilder creates a new empty config builder.
+func NewBuilder() *Builder { return new(Builder) }
+
+// CaseInsensitiveMethods sets Config.CaseInsensitiveMethods.
+func (b *Builder) CaseInsensitiveMethods() *Builder {
+	b.config.CaseInsensitiveMethods = true
+	return b
+}
+
+// Resource appends a new resource with the given name,
+// which may be empty, and returns its builder.
+func (b *Builder) Resource(name string) *ResourceBuilder {
+	b.config.Resources = append(b.config.Resources, Resource{Name: name})
+	return &ResourceBuilder{b: b, index: len(b.config.Resources) - 1}
+}
+
+// Build returns the validated config.
+func (b *Builder) Build() (Config, error) {
+	if b.err != nil {
+		return Config{}, b.err
+	}
+	if err := Validate(b.config); err != nil {
+		return Config{}, err
+	}
+	return b.config, nil
+}
+
+// ResourceBuilder builds a single resource of a Builder.
+type ResourceBuilder struct {
+	b     *Builder
+	index int
+}
+
+func (r *ResourceBuilder) resource() *Resource { return &r.b.config.Resources[r.index] }
+
+// effect returns the effect of the resource, creating it if necessary.
+func (r *ResourceBuilder) effect() *Effect {
+	res := r.resource()
+	if res.Effect == nil {
+		res.Effect = new(Effect)
+	}
+	return res.Effect
+}
+
+// replace returns the replacement of the resource's effect,
+// creating it if necessary.
+func (r *ResourceBuilder) replace() *Replace {
+	e := r.effect()
+	if e.Replace == nil {
+		e.Replace = new(Replace)
+	}
+	return e.Replace
+}
+
+func (r *ResourceBuilder) fail(err error) *ResourceBuilder {
+	if r.b.err == nil {
+		r.b.err = fmt.Errorf("resource %d (%q): %w", r.index, r.resource().Name, err)
+	}
+	return r
+}
+
+func (r *ResourceBuilder) glob(expression string) (GlobExpression, bool) {
+	g, err := NewGlobExpression(expression)
+	if err != nil {
+		r.fail(err)
+		return GlobExpression{}, false
+	}
+	return g, true
+}
+
+func (r *ResourceBuilder) globs(expressions []string) ([]GlobExpression, bool) {
+	globs := make([]GlobExpression, len(expressions))
+	for i, e := range expressions {
+		g, ok := r.glob(e)
+		if !ok {
+			return nil, false
+		}
+		globs[i] = g
+	}
+	return globs, true
+}
+
+// Resource appends another resource to the config being built.
+func (r *ResourceBuilder) Resource(name string) *ResourceBuilder {
+	return r.b.Resource(name)
+}
+
+// Build returns the validated config.
+func (r *ResourceBuilder) Build() (Config, error) { return r.b.Build() }
+
+// Path sets the path glob expression.
+func (r *ResourceBuilder) Path(expression string) *ResourceBuilder {
+	if g, ok := r.glob(expression); ok {
+		r.resource().Path = g
+	}
+	return r
+}
+
+// PathRegexp sets the path regular expression.
+func (r *ResourceBuilder) PathRegexp(expression string) *ResourceBuilder {
+	re, err := NewRegexp(expression)
+	if err != nil {
+		return r.fail(err)
+	}
+	r.resource().PathRegexp = re
+	return r
+}
+
+// Methods appends methods.
+func (r *ResourceBuilder) Methods(methods ...string) *ResourceBuilder {
+	res := r.resource()
+	for _, m := range methods {
+		var h HTTPMethod
+		if err := h.UnmarshalText([]byte(m)); err != nil {
+			return r.fail(err)
+		}
+		res.Methods = append(res.Methods, h)
+	}
+	return r
+}
+
+// Header requires headers matching the name glob
+// to match any of the value globs.
+func (r *ResourceBuilder) Header(name string, values ...string) *ResourceBuilder {
+	n, ok := r.glob(name)
+	if !ok {
+		return r
+	}
+	v, ok := r.globs(values)
+	if !ok {
+		return r
+	}
+	res := r.resource()
+	if res.Headers == nil {
+		res.Headers = GlobMap[[]GlobExpression]{}
+	}
+	res.Headers[n] = v
+	return r
+}
+
+// Query requires query parameters matching the parameter glob
+// to match any of the value globs.
+func (r *ResourceBuilder) Query(parameter string, values ...string) *ResourceBuilder {
+	p, ok := r.glob(parameter)
+	if !ok {
+		return r
+	}
+	v, ok := r.globs(values)
+	if !ok {
+		return r
+	}
+	res := r.resource()
+	if res.Query == nil {
+		res.Query = GlobMap[[]GlobExpression]{}
+	}
+	res.Query[p] = v
+	return r
+}
+
+// QueryRequired sets Resource.QueryRequired.
+func (r *ResourceBuilder) QueryRequired() *ResourceBuilder {
+	r.resource().QueryRequired = true
+	return r
+}
+
+// Probability sets the probability of the effect being applied.
+func (r *ResourceBuilder) Probability(p float64) *ResourceBuilder {
+	v := Probability(p)
+	r.effect().Probability = &v
+	return r
+}
+
+// Delay sets a uniformly distributed delay.
+func (r *ResourceBuilder) Delay(min, max time.Duration) *ResourceBuilder {
+	r.effect().Delay = &DurRange{Min: min, Max: max}
+	return r
+}
+
+// Replace replaces the response with the given status code and body.
+// An empty body is not written.
+func (r *ResourceBuilder) Replace(statusCode int, body string) *ResourceBuilder {
+	rep := r.replace()
+	rep.StatusCode = StatusCode(statusCode)
+	if body != "" {
+		rep.Body = &body
+	}
+	return r
+}
+
+// ReplaceHeader sets a header of the replacement response.
+func (r *ResourceBuilder) ReplaceHeader(name, value string) *ResourceBuilder {
+	rep := r.replace()
+	if rep.Headers == nil {
+		rep.Headers = map[HeaderName]string{}
+	}
+	rep.Headers[HeaderName(name)] = value
+	return r
+}
+
+// Throughput limits the response body transfer rate.
+func (r *ResourceBuilder) Throughput(bytesPerSecond uint64) *ResourceBuilder {
+	r.effect().Throughput = &Throughput{BytesPerSecond: bytesPerSecond}
+	return r
+}
+
+// Hang never responds, waiting at most max if max > 0.
+func (r *ResourceBuilder) Hang(max time.Duration) *ResourceBuilder {
+	r.effect().Hang = &Hang{Max: max}
+	return r
+}
+
+// Effect sets the effect, overwriting any effect configured so far.
+// Use it for effects the builder has no dedicated methods for.
+func (r *ResourceBuilder) Effect(e Effect) *ResourceBuilder {
+	r.resource().Effect = &e
+	return r
+}
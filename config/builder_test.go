@@ -0,0 +1,89 @@
+package config_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim/config"
+)
+
+func TestBuilder(t *testing.T) {
+	c, err := config.NewBuilder().
+		Resource("orders").
+		Path("/orders/*").
+		Methods(http.MethodGet, "!"+http.MethodHead).
+		Header("X-Tenant", "acme", "globex").
+		Query("page", "*").QueryRequired().
+		Probability(0.5).
+		Delay(100*time.Millisecond, time.Second).
+		Replace(http.StatusServiceUnavailable, "unavailable").
+		ReplaceHeader("Retry-After", "5").
+		Resource("").
+		PathRegexp(`^/users/(?P<id>\d+)$`).
+		Hang(time.Minute).
+		Build()
+	require.NoError(t, err)
+
+	require.Len(t, c.Resources, 2)
+
+	r := c.Resources[0]
+	require.Equal(t, "orders", r.Name)
+	require.Equal(t, "/orders/*", r.Path.String())
+	require.Equal(t, []config.HTTPMethod{"GET", "!HEAD"}, r.Methods)
+	require.Equal(t, map[string][]string{"X-Tenant": {"acme", "globex"}},
+		globMapStrings(r.Headers))
+	require.Equal(t, map[string][]string{"page": {"*"}}, globMapStrings(r.Query))
+	require.True(t, r.QueryRequired)
+	p := config.Probability(0.5)
+	body := "unavailable"
+	require.Equal(t, &config.Effect{
+		Probability: &p,
+		Delay:       &config.DurRange{Min: 100 * time.Millisecond, Max: time.Second},
+		Replace: &config.Replace{
+			StatusCode: http.StatusServiceUnavailable,
+			Body:       &body,
+			Headers:    map[config.HeaderName]string{"Retry-After": "5"},
+		},
+	}, r.Effect)
+
+	r = c.Resources[1]
+	require.Zero(t, r.Name)
+	require.Equal(t, `^/users/(?P<id>\d+)$`, r.PathRegexp.String())
+	require.Equal(t, &config.Effect{Hang: &config.Hang{Max: time.Minute}}, r.Effect)
+}
+
+func globMapStrings(m config.GlobMap[[]config.GlobExpression]) map[string][]string {
+	s := make(map[string][]string, len(m))
+	for k, v := range m {
+		for _, g := range v {
+			s[k.String()] = append(s[k.String()], g.String())
+		}
+	}
+	return s
+}
+
+func TestBuilderErr(t *testing.T) {
+	f := func(b *config.ResourceBuilder, expect error) {
+		t.Helper()
+		c, err := b.Build()
+		require.Error(t, err)
+		if expect != nil {
+			require.ErrorIs(t, err, expect)
+		}
+		require.Zero(t, c)
+	}
+
+	f(config.NewBuilder().Resource("").Path("[").Delay(time.Millisecond, time.Second), nil)
+	f(config.NewBuilder().Resource("").PathRegexp("(").Delay(time.Millisecond, time.Second), nil)
+	f(config.NewBuilder().Resource("").Methods("get").Delay(time.Millisecond, time.Second),
+		config.ErrInvalidHTTPMethod)
+	f(config.NewBuilder().Resource("").Replace(0, ""), config.ErrInvalidStatusCode)
+	f(config.NewBuilder().Resource("").Probability(2).Delay(time.Millisecond, time.Second),
+		config.ErrInvalidProbability)
+	f(config.NewBuilder().
+		Resource("a").Delay(time.Millisecond, time.Second).
+		Resource("a").Delay(time.Millisecond, time.Second), config.ErrDuplicateResourceName)
+}
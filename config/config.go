@@ -1,12 +1,23 @@
 package config
 
 import (
+	"bytes"
+	"cmp"
 	"encoding"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
+	"net/url"
 	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 	"unicode"
 
@@ -16,15 +27,413 @@ import (
 )
 
 type Config struct {
+	// Version is the version of the config format, see CurrentVersion.
+	// Configs of older versions are migrated at load time.
+	// Zero means CurrentVersion.
+	Version uint32 `yaml:"version"`
+
+	// Enabled set to false turns the middleware into a pure pass-through.
+	// Nil means enabled.
+	Enabled *bool `yaml:"enabled"`
+
+	// DryRun makes the middleware match requests and select effects
+	// without applying them. The effects that would have been applied
+	// are reflected in the CtxInfo passed to the next handler,
+	// the stats and the logs, except for max-concurrent and CDN effects,
+	// which depend on the state applied effects build up and aren't
+	// accounted for. Connection faults aren't applied either.
+	DryRun bool `yaml:"dry-run"`
+
+	// DebugHeaders annotates the responses to matched requests with
+	// the X-Httpsim-Resource, X-Httpsim-Delay and X-Httpsim-Replaced headers.
+	DebugHeaders bool `yaml:"debug-headers"`
+
+	// CaseInsensitiveMethods makes Load accept methods in any case
+	// normalizing them to upper case.
+	CaseInsensitiveMethods bool `yaml:"case-insensitive-methods"`
+
+	// Include lists globs of config files whose resources are appended
+	// to Resources at load time in the order of inclusion.
+	// Relative globs are resolved relative to the including file.
+	// Load and LoadFile clear Include once the includes are resolved.
+	Include []string `yaml:"include"`
+
+	// Defaults are inherited by resources that don't override them.
+	Defaults *Defaults `yaml:"defaults"`
+
+	// GlobalEffect applies to all requests, including unmatched ones,
+	// before the effects of the matched resource. The effects of the
+	// matched resource are skipped if the global effect replaced the response.
+	GlobalEffect *Effect `yaml:"global-effect"`
+
 	Resources []Resource `yaml:"resources"`
+
+	// Scenario attaches effects to named resources, including resources
+	// of included files, see Attachment.
+	Scenario []Attachment `yaml:"scenario"`
+
+	// Connections degrades the connections accepted by listeners
+	// wrapped with WrapListener. Ignored by the middleware.
+	Connections *Connections `yaml:"connections"`
+}
+
+// IsEnabled returns false if c is explicitly disabled.
+func (c *Config) IsEnabled() bool { return c.Enabled == nil || *c.Enabled }
+
+// Defaults are inherited by the effects of all resources,
+// including their chains and sequence steps, unless overridden.
+// Defaults of included files apply to the resources they include.
+type Defaults struct {
+	Probability *Probability `yaml:"probability"`
+
+	// Delay applies to effects that don't define any kind of delay.
+	Delay *DurRange `yaml:"delay"`
+
+	// ReplaceHeaders are set on replacement responses
+	// unless they set the header themselves.
+	ReplaceHeaders map[HeaderName]string `yaml:"replace-headers"`
+}
+
+// WithDefaults returns c with its defaults applied to all resources.
+// c isn't modified.
+func (c Config) WithDefaults() Config {
+	d := c.Defaults
+	if d == nil {
+		return c
+	}
+	c.Resources = slices.Clone(c.Resources)
+	for i := range c.Resources {
+		r := &c.Resources[i]
+		if r.Effect != nil {
+			r.Effect = d.apply(r.Effect)
+		}
+		if r.Effects != nil {
+			effects := make([]Effect, len(r.Effects))
+			for j := range r.Effects {
+				effects[j] = *d.apply(&r.Effects[j])
+			}
+			r.Effects = effects
+		}
+		if r.Sequence != nil {
+			seq := *r.Sequence
+			seq.Steps = slices.Clone(seq.Steps)
+			for j := range seq.Steps {
+				if seq.Steps[j].Effect != nil {
+					seq.Steps[j].Effect = d.apply(seq.Steps[j].Effect)
+				}
+			}
+			r.Sequence = &seq
+		}
+	}
+	return c
+}
+
+// apply returns a copy of e with the defaults applied.
+func (d *Defaults) apply(e *Effect) *Effect {
+	c := *e
+	if c.Probability == nil {
+		c.Probability = d.Probability
+	}
+	if c.Delay == nil && c.DelayHeaders == nil &&
+		c.DelayBody == nil && c.DelayAfterHeaders == nil {
+		c.Delay = d.Delay
+	}
+	if len(d.ReplaceHeaders) > 0 {
+		if c.Replace != nil {
+			c.Replace = d.applyReplace(c.Replace)
+		}
+		if c.ReplaceWeighted != nil {
+			c.ReplaceWeighted = slices.Clone(c.ReplaceWeighted)
+			for i := range c.ReplaceWeighted {
+				c.ReplaceWeighted[i].Replace = *d.applyReplace(
+					&c.ReplaceWeighted[i].Replace,
+				)
+			}
+		}
+	}
+	return &c
+}
+
+func (d *Defaults) applyReplace(r *Replace) *Replace {
+	c := *r
+	c.Headers = make(map[HeaderName]string, len(r.Headers)+len(d.ReplaceHeaders))
+	for name, value := range d.ReplaceHeaders {
+		c.Headers[name] = value
+	}
+	for name, value := range r.Headers {
+		// Remove default headers of the same name in different case.
+		for n := range c.Headers {
+			if strings.EqualFold(string(n), string(name)) {
+				delete(c.Headers, n)
+			}
+		}
+		c.Headers[name] = value
+	}
+	return &c
 }
 
 type Resource struct {
+	// Name optionally identifies the resource and must be unique.
+	Name string `yaml:"name"`
+
 	Methods []HTTPMethod              `yaml:"methods"`
 	Path    GlobExpression            `yaml:"path"`
 	Headers GlobMap[[]GlobExpression] `yaml:"headers"`
 	Query   GlobMap[[]GlobExpression] `yaml:"query"`
-	Effect  *Effect                   `yaml:"effect"`
+
+	// Host matches the host name of the request without the port,
+	// which is most useful for outgoing requests sent through RoundTripper.
+	Host GlobExpression `yaml:"host"`
+
+	// QueryRequired requires every parameter listed in Query to be present
+	// in the request, otherwise absent parameters are ignored.
+	QueryRequired bool `yaml:"query-required"`
+
+	// PathRegexp, if set, must additionally match the request path.
+	// Named capture groups are substituted into replacement templates.
+	PathRegexp Regexp `yaml:"path-regexp"`
+
+	// HeadersRegexp maps header name globs to regular expressions
+	// every value of the matching headers must match.
+	// Named capture groups are substituted into replacement templates.
+	HeadersRegexp GlobMap[Regexp] `yaml:"headers-regexp"`
+
+	// HeaderCount matches the total number of request header values.
+	HeaderCount *Uint64Range `yaml:"header-count"`
+
+	// HeaderBytes matches the cumulative size of all request header
+	// names and values in bytes.
+	HeaderBytes *Uint64Range `yaml:"header-bytes"`
+
+	// GraphQL, if set, additionally matches the GraphQL operation
+	// of the request.
+	GraphQL *GraphQL `yaml:"graphql"`
+
+	// GRPC, if set, additionally matches the gRPC service and method.
+	GRPC *GRPC `yaml:"grpc"`
+
+	// RangeRequest, if set, matches only requests with a Range header
+	// when true and only requests without one when false.
+	RangeRequest *bool `yaml:"range-request"`
+
+	// Conditional, if set, matches only conditional requests carrying
+	// an If-None-Match or If-Modified-Since header when true and only
+	// unconditional requests when false.
+	Conditional *bool `yaml:"conditional"`
+
+	Effect *Effect `yaml:"effect"`
+
+	// Effects is a chain of effects applied in order.
+	// Each effect is gated by its own probability and bursts.
+	// Effects following an effect that replaced the response are skipped.
+	// Mutually exclusive with Effect.
+	Effects []Effect `yaml:"effects"`
+
+	// Sequence applies a different effect to each consecutive matched request.
+	// Once the sequence is exhausted Effect or Effects apply, unless it loops.
+	Sequence *Sequence `yaml:"sequence"`
+
+	// SLO declares the expected service level of the resource.
+	SLO *SLO `yaml:"slo"`
+
+	// KeyBy tracks match counts, sequences and concurrency limits
+	// per client instead of globally.
+	KeyBy *KeyBy `yaml:"key-by"`
+}
+
+var ErrEffectAndEffects = errors.New("effect and effects are mutually exclusive")
+
+func (r *Resource) Validate() error {
+	if r.Effect != nil && len(r.Effects) > 0 {
+		return ErrEffectAndEffects
+	}
+	return nil
+}
+
+// Chain returns the effects of the resource in the order they're applied.
+func (r *Resource) Chain() []*Effect {
+	if r.Effect != nil {
+		return []*Effect{r.Effect}
+	}
+	if len(r.Effects) < 1 {
+		return nil
+	}
+	c := make([]*Effect, len(r.Effects))
+	for i := range r.Effects {
+		c[i] = &r.Effects[i]
+	}
+	return c
+}
+
+// KeyBy identifies the client a request belongs to.
+// Exactly one of the fields must be set. Requests lacking the identity
+// (such as a missing header) share the same anonymous client state.
+// The state of each client is kept for as long as the configuration is
+// unless more than MaxClients clients are tracked, in which case the state
+// of the least recently seen client is evicted. A returning evicted client
+// starts over as a new client with its match counts and sequences reset.
+type KeyBy struct {
+	// ClientIP identifies clients by the IP address of the remote address.
+	ClientIP bool `yaml:"client-ip"`
+
+	// Header identifies clients by the value of the header, such as X-API-Key.
+	Header *HeaderName `yaml:"header"`
+
+	// Cookie identifies clients by the value of the cookie.
+	Cookie string `yaml:"cookie"`
+
+	// MaxClients limits the number of clients tracked at a time
+	// since client identities are controlled by clients.
+	// Defaults to DefaultMaxClients.
+	MaxClients uint32 `yaml:"max-clients"`
+}
+
+// DefaultMaxClients is used if KeyBy.MaxClients is zero.
+const DefaultMaxClients = 10_000
+
+var ErrInvalidKeyBy = errors.New(
+	"exactly one of client-ip, header and cookie must be set",
+)
+
+func (k *KeyBy) Validate() error {
+	n := 0
+	for _, set := range [...]bool{k.ClientIP, k.Header != nil, k.Cookie != ""} {
+		if set {
+			n++
+		}
+	}
+	if n != 1 {
+		return ErrInvalidKeyBy
+	}
+	return nil
+}
+
+// GraphQL matches GraphQL requests, either POST requests with a JSON
+// or application/graphql body or GET requests with query parameters,
+// by the operation they execute. Other requests, batched requests
+// and requests with bodies over 1 MiB don't match.
+type GraphQL struct {
+	// OperationName matches the name of the executed operation,
+	// which is empty for anonymous operations.
+	OperationName GlobExpression `yaml:"operation-name"`
+
+	// OperationType matches the type of the executed operation.
+	// Any type matches if empty.
+	OperationType GraphQLOperationType `yaml:"operation-type"`
+
+	// Fields requires any top-level field of the executed operation
+	// to match any of the globs, such as "createOrder".
+	Fields []GlobExpression `yaml:"fields"`
+}
+
+type GraphQLOperationType string
+
+const (
+	GraphQLQuery        GraphQLOperationType = "query"
+	GraphQLMutation     GraphQLOperationType = "mutation"
+	GraphQLSubscription GraphQLOperationType = "subscription"
+)
+
+var ErrInvalidGraphQLOperationType = errors.New("invalid GraphQL operation type")
+
+func (t GraphQLOperationType) Validate() error {
+	switch t {
+	case "", GraphQLQuery, GraphQLMutation, GraphQLSubscription:
+		return nil
+	}
+	return fmt.Errorf("%w: %q", ErrInvalidGraphQLOperationType, string(t))
+}
+
+// GRPC matches gRPC requests, which are POST requests with a Content-Type
+// of application/grpc (including application/grpc+proto and the like)
+// to paths of the form /package.Service/Method.
+type GRPC struct {
+	// Service matches the fully qualified service name, such as "shop.v1.Orders".
+	Service GlobExpression `yaml:"service"`
+
+	// Method matches the method name, such as "CreateOrder".
+	Method GlobExpression `yaml:"method"`
+}
+
+// Sequence is a list of steps applied in order to consecutive matched requests,
+// such as two 503 responses followed by pass-through.
+type Sequence struct {
+	Steps []SequenceStep `yaml:"steps"`
+
+	// Loop restarts the sequence once it's exhausted.
+	Loop bool `yaml:"loop"`
+}
+
+var ErrEmptySequence = errors.New("empty sequence")
+
+func (s *Sequence) Validate() error {
+	if len(s.Steps) < 1 {
+		return ErrEmptySequence
+	}
+	return nil
+}
+
+// Len returns the total number of requests the sequence applies to.
+func (s *Sequence) Len() uint64 {
+	var n uint64
+	for _, step := range s.Steps {
+		n += step.Len()
+	}
+	return n
+}
+
+// Step returns the step for the n-th (zero-based) request and true,
+// or false if the sequence is exhausted.
+func (s *Sequence) Step(n uint64) (*SequenceStep, bool) {
+	if s.Loop {
+		n %= s.Len()
+	}
+	for i := range s.Steps {
+		if l := s.Steps[i].Len(); n >= l {
+			n -= l
+			continue
+		}
+		return &s.Steps[i], true
+	}
+	return nil, false
+}
+
+type SequenceStep struct {
+	// Times is the number of consecutive requests the step applies to.
+	// Zero is equivalent to 1.
+	Times uint32 `yaml:"times"`
+
+	// Effect is applied to the requests. Nil means pass-through.
+	Effect *Effect `yaml:"effect"`
+}
+
+// Len returns the number of requests the step applies to.
+func (s *SequenceStep) Len() uint64 { return uint64(max(s.Times, 1)) }
+
+// SLO is a service level objective the middleware tracks breaches of.
+type SLO struct {
+	// Latency is the maximum expected latency of a single request
+	// including the injected delay. Zero disables latency tracking.
+	Latency time.Duration `yaml:"latency"`
+
+	// ErrorBudget is the maximum expected fraction of responses
+	// with a status code of 500 or higher.
+	ErrorBudget Probability `yaml:"error-budget"`
+}
+
+var (
+	ErrNegativeLatency    = errors.New("negative latency")
+	ErrInvalidErrorBudget = errors.New("error budget must be within 0.0 and 1.0")
+)
+
+func (s *SLO) Validate() error {
+	if s.Latency < 0 {
+		return ErrNegativeLatency
+	}
+	if s.ErrorBudget < 0 || s.ErrorBudget > 1 {
+		return ErrInvalidErrorBudget
+	}
+	return nil
 }
 
 // Headers and Query were previously implemented as slices of structs
@@ -49,17 +458,26 @@ func (h GlobMap[T]) Validate() error {
 
 type HTTPMethod string
 
+// MethodAny matches any HTTP method.
+const MethodAny HTTPMethod = "*"
+
 var ErrInvalidHTTPMethod = errors.New("invalid HTTP method")
 
 // HTTPMethod must implement TextUnmarshaler for YAML decoding.
 var _ encoding.TextUnmarshaler = new(HTTPMethod)
 
+// UnmarshalText accepts upper case methods, MethodAny and
+// negated methods prefixed with "!" such as "!OPTIONS".
 func (m *HTTPMethod) UnmarshalText(text []byte) error {
 	*m = HTTPMethod(text)
-	if *m == "" {
+	if *m == MethodAny {
+		return nil
+	}
+	name, _ := m.Negated()
+	if name == "" {
 		return ErrInvalidHTTPMethod
 	}
-	for _, char := range string(*m) {
+	for _, char := range string(name) {
 		if char > unicode.MaxASCII || !unicode.IsLetter(char) || unicode.IsLower(char) {
 			return ErrInvalidHTTPMethod
 		}
@@ -67,134 +485,2859 @@ func (m *HTTPMethod) UnmarshalText(text []byte) error {
 	return nil
 }
 
+// Negated returns the method name without the "!" prefix
+// and true if m is negated.
+func (m HTTPMethod) Negated() (name HTTPMethod, negated bool) {
+	if s, ok := strings.CutPrefix(string(m), "!"); ok {
+		return HTTPMethod(s), true
+	}
+	return m, false
+}
+
+// MatchMethod returns true if method matches methods.
+// Empty methods match any method. Negated methods exclude
+// a method, methods consisting of negations only match any other method.
+func MatchMethod(methods []HTTPMethod, method string) bool {
+	matched, hasPositive := false, false
+	for _, m := range methods {
+		name, negated := m.Negated()
+		if negated {
+			if string(name) == method {
+				return false
+			}
+			continue
+		}
+		hasPositive = true
+		if name == MethodAny || string(name) == method {
+			matched = true
+		}
+	}
+	return matched || !hasPositive
+}
+
 type Replace struct {
 	StatusCode StatusCode            `yaml:"status-code"`
 	Body       *string               `yaml:"body"`
 	Headers    map[HeaderName]string `yaml:"headers"`
-}
 
-type Effect struct {
-	Delay   *DurRange `yaml:"delay"`
-	Replace *Replace  `yaml:"replace"`
+	// BodyFile is the path to a file used verbatim as the body instead of Body.
+	// The file is read on first use and reloaded whenever it changes.
+	// LoadFile resolves relative paths against the directory of the file
+	// declaring them, otherwise they're relative to the working directory.
+	BodyFile *string `yaml:"body-file"`
+
+	// BodyBase64 is a base64 encoded binary body used instead of Body.
+	BodyBase64 *Base64 `yaml:"body-base64"`
+
+	// Compress compresses the body and sets Content-Encoding.
+	Compress *Compress `yaml:"compress"`
+
+	// Cookies are set using one Set-Cookie header per cookie.
+	Cookies []Cookie `yaml:"cookies"`
+
+	// CacheHeaders generates validators and caching headers for the body.
+	CacheHeaders *CacheHeaders `yaml:"cache-headers"`
+
+	// Variants are bodies negotiated by the Accept header of the request
+	// used instead of Body. The first variant is the default for requests
+	// accepting none of them.
+	Variants []Variant `yaml:"variants"`
+
+	// AllowNonstandard permits status codes that aren't defined by the RFCs
+	// such as 420, 499 or 599 as long as they're within range 100-999.
+	AllowNonstandard bool `yaml:"allow-nonstandard"`
 }
 
-var ErrNoEffect = errors.New("no effect")
+var ErrMultipleBodies = errors.New(
+	"body, body-file, body-base64 and variants are mutually exclusive",
+)
 
-func (e *Effect) Validate() error {
-	if e == nil {
-		return nil
+func (r *Replace) Validate() error {
+	bodies := 0
+	for _, set := range [...]bool{
+		r.Body != nil, r.BodyFile != nil, r.BodyBase64 != nil, len(r.Variants) > 0,
+	} {
+		if set {
+			bodies++
+		}
 	}
-	if (e.Delay == nil || e.Delay != nil && e.Delay.Min == 0) && e.Replace == nil {
-		return ErrNoEffect
+	if bodies > 1 {
+		return ErrMultipleBodies
+	}
+	if r.Compress != nil && bodies < 1 {
+		return ErrCompressWithoutBody
+	}
+	for i := range r.Variants {
+		t := r.Variants[i].ContentType.Essence()
+		if t == "" {
+			continue // Invalid, reported by MediaType.Validate.
+		}
+		for j := range r.Variants[:i] {
+			if r.Variants[j].ContentType.Essence() == t {
+				return fmt.Errorf("%w: %q", ErrDuplicateVariant, t)
+			}
+		}
+	}
+	if r.AllowNonstandard && (r.StatusCode < 100 || r.StatusCode > 999) {
+		return fmt.Errorf("%w: %d", ErrInvalidStatusCode, r.StatusCode)
 	}
 	return nil
 }
 
-type DurRange struct {
-	Min time.Duration `yaml:"min"`
-	Max time.Duration `yaml:"max"`
+var ErrCompressWithoutBody = errors.New("compress requires a body")
+
+// Variant is a replacement body for a content type.
+type Variant struct {
+	// ContentType is the media type the variant is selected for
+	// and sent as Content-Type, such as "application/json".
+	ContentType MediaType `yaml:"content-type"`
+
+	Body       *string `yaml:"body"`
+	BodyFile   *string `yaml:"body-file"`
+	BodyBase64 *Base64 `yaml:"body-base64"`
 }
 
-var ErrMinGreaterMax = errors.New("min greater than max")
+var ErrDuplicateVariant = errors.New("duplicate variant content type")
 
-func (r DurRange) Validate() error {
-	if r.Min > r.Max {
-		return ErrMinGreaterMax
+func (v *Variant) Validate() error {
+	bodies := 0
+	for _, set := range [...]bool{
+		v.Body != nil, v.BodyFile != nil, v.BodyBase64 != nil,
+	} {
+		if set {
+			bodies++
+		}
+	}
+	if bodies > 1 {
+		return ErrMultipleBodies
 	}
 	return nil
 }
 
-type StatusCode int32
+// MediaType is a media type with optional parameters
+// such as "text/html; charset=utf-8".
+type MediaType string
 
-var ErrInvalidStatusCode = errors.New("invalid HTTP response status code")
+var ErrInvalidMediaType = errors.New("invalid media type")
 
-func (c StatusCode) Validate() error {
-	if http.StatusText(int(c)) == "" {
-		return fmt.Errorf("%w: %d", ErrInvalidStatusCode, c)
+func (t MediaType) Validate() error {
+	mediaType, _, err := mime.ParseMediaType(string(t))
+	typ, subtype, ok := strings.Cut(mediaType, "/")
+	if err != nil || !ok || typ == "" || subtype == "" ||
+		strings.Contains(mediaType, "*") {
+		return fmt.Errorf("%w: %q", ErrInvalidMediaType, string(t))
 	}
 	return nil
 }
 
-type HeaderName string
+// Essence returns the lowercase type/subtype of t without parameters.
+func (t MediaType) Essence() string {
+	mediaType, _, _ := mime.ParseMediaType(string(t))
+	return mediaType
+}
 
-var ErrInvalidHeaderName = errors.New("invalid header name")
+// CacheHeaders generates realistic caching headers for a replacement body.
+// GET and HEAD requests whose If-None-Match or If-Modified-Since validators
+// match the generated ones are responded to with 304 Not Modified.
+type CacheHeaders struct {
+	// ETag sets an entity tag computed from the (compressed) body.
+	ETag bool `yaml:"etag"`
 
-func (n HeaderName) Validate() error {
-	if n == "" {
-		return ErrInvalidHeaderName
-	}
-	for _, char := range n {
-		if !(char == '-' ||
-			unicode.IsLetter(char) ||
-			unicode.IsDigit(char)) ||
-			char > unicode.MaxASCII {
-			return ErrInvalidHeaderName
-		}
+	// WeakETag makes the entity tag weak, such as W/"1a2b".
+	WeakETag bool `yaml:"weak-etag"`
+
+	// LastModified sets Last-Modified to the given duration before
+	// the middleware was created. Zero omits Last-Modified.
+	LastModified time.Duration `yaml:"last-modified"`
+
+	// Profile sets Cache-Control. Omitted if empty and MaxAge is zero.
+	Profile CacheProfile `yaml:"profile"`
+
+	// MaxAge is the max-age of the public and private profiles
+	// and overrides the max-age of the immutable profile.
+	MaxAge time.Duration `yaml:"max-age"`
+}
+
+func (c *CacheHeaders) Validate() error {
+	if c.LastModified < 0 || c.MaxAge < 0 {
+		return ErrNegativeDuration
 	}
 	return nil
 }
 
-type GlobExpression struct {
-	// glob is a pointer to make the struct comparable
-	// and allow it to be used as map key.
-	glob *glob.Glob
+// CacheControl returns the value of Cache-Control or "" if there's none.
+func (c *CacheHeaders) CacheControl() string {
+	maxAge := fmt.Sprintf("max-age=%d", int64(c.MaxAge.Seconds()))
+	switch c.Profile {
+	case CacheProfileNoStore:
+		return "no-store"
+	case CacheProfileNoCache:
+		return "no-cache"
+	case CacheProfilePrivate:
+		return "private, " + maxAge
+	case CacheProfilePublic:
+		return "public, " + maxAge
+	case CacheProfileImmutable:
+		if c.MaxAge == 0 {
+			maxAge = "max-age=31536000" // One year.
+		}
+		return "public, " + maxAge + ", immutable"
+	}
+	if c.MaxAge > 0 {
+		return maxAge
+	}
+	return ""
 }
 
-func (e GlobExpression) String() string { return fmt.Sprintf("%v", e.glob) }
+type CacheProfile string
 
-func NewGlobExpression(expression string) (GlobExpression, error) {
-	g, err := glob.Compile(expression)
-	if err != nil {
-		return GlobExpression{}, err
+const (
+	// CacheProfileNoStore forbids caching.
+	CacheProfileNoStore CacheProfile = "no-store"
+
+	// CacheProfileNoCache requires revalidation before every use.
+	CacheProfileNoCache CacheProfile = "no-cache"
+
+	// CacheProfilePrivate allows caching in the client only.
+	CacheProfilePrivate CacheProfile = "private"
+
+	// CacheProfilePublic allows caching in shared caches.
+	CacheProfilePublic CacheProfile = "public"
+
+	// CacheProfileImmutable allows caching for a year without revalidation.
+	CacheProfileImmutable CacheProfile = "immutable"
+)
+
+var ErrInvalidCacheProfile = errors.New("invalid cache profile")
+
+func (p CacheProfile) Validate() error {
+	switch p {
+	case "", CacheProfileNoStore, CacheProfileNoCache,
+		CacheProfilePrivate, CacheProfilePublic, CacheProfileImmutable:
+		return nil
 	}
-	return GlobExpression{glob: &g}, nil
+	return fmt.Errorf("%w: %q", ErrInvalidCacheProfile, string(p))
 }
 
-// GlobExpression must implement TextUnmarshaler for YAML decoding.
-var (
-	_ encoding.TextUnmarshaler = new(GlobExpression)
-	_ glob.Glob                = new(GlobExpression)
-)
+// Cookie is a cookie set by the response.
+// Value may contain ${name} placeholders just like headers.
+type Cookie struct {
+	Name   string `yaml:"name"`
+	Value  string `yaml:"value"`
+	Path   string `yaml:"path"`
+	Domain string `yaml:"domain"`
 
-func (g *GlobExpression) UnmarshalText(text []byte) (err error) {
-	c, err := glob.Compile(string(text))
-	if err != nil {
-		return err
+	// MaxAge is rounded down to seconds. Zero omits the Max-Age attribute,
+	// negative values delete the cookie.
+	MaxAge time.Duration `yaml:"max-age"`
+
+	Secure   bool     `yaml:"secure"`
+	HTTPOnly bool     `yaml:"http-only"`
+	SameSite SameSite `yaml:"same-site"`
+}
+
+var ErrInvalidCookie = errors.New("invalid cookie")
+
+func (c *Cookie) Validate() error {
+	if err := c.HTTPCookie(nil).Valid(); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidCookie, err)
 	}
-	g.glob = &c
 	return nil
 }
 
-func (g *GlobExpression) Match(s string) bool {
-	if g.glob == nil {
-		return true
+// HTTPCookie returns c as *http.Cookie with the placeholders
+// in the value expanded using expand, which is ignored if nil.
+func (c *Cookie) HTTPCookie(expand func(string) string) *http.Cookie {
+	h := &http.Cookie{
+		Name:     c.Name,
+		Value:    c.Value,
+		Path:     c.Path,
+		Domain:   c.Domain,
+		Secure:   c.Secure,
+		HttpOnly: c.HTTPOnly,
 	}
-	return (*g.glob).Match(s)
+	if expand != nil {
+		h.Value = expand(c.Value)
+	}
+	switch {
+	case c.MaxAge < 0:
+		h.MaxAge = -1
+	case c.MaxAge > 0:
+		h.MaxAge = int(c.MaxAge / time.Second)
+	}
+	switch c.SameSite {
+	case SameSiteLax:
+		h.SameSite = http.SameSiteLaxMode
+	case SameSiteStrict:
+		h.SameSite = http.SameSiteStrictMode
+	case SameSiteNone:
+		h.SameSite = http.SameSiteNoneMode
+	}
+	return h
 }
 
-// Validate returns an error if c is invalid, otherwise returns nil.
-func Validate(c Config) error { return yamagiconf.Validate(c) }
+// SameSite is the SameSite cookie attribute. Empty omits the attribute.
+type SameSite string
 
-// Load loads config from arbitrary reader.
-func Load(src io.Reader) (*Config, error) {
-	var c Config
-	// Use standard YAML decoder but utilize yamagiconf validation.
-	d := yaml.NewDecoder(src)
-	d.KnownFields(true)
-	if err := d.Decode(&c); err != nil {
-		return nil, fmt.Errorf("decoding YAML: %w", err)
-	}
-	if err := Validate(c); err != nil {
-		return nil, fmt.Errorf("validating: %w", err)
+const (
+	SameSiteLax    SameSite = "lax"
+	SameSiteStrict SameSite = "strict"
+	SameSiteNone   SameSite = "none"
+)
+
+var ErrInvalidSameSite = errors.New("invalid same-site")
+
+func (s SameSite) Validate() error {
+	switch s {
+	case "", SameSiteLax, SameSiteStrict, SameSiteNone:
+		return nil
 	}
-	return &c, nil
+	return fmt.Errorf("%w: %q", ErrInvalidSameSite, string(s))
 }
 
-// LoadFile loads config from file.
-func LoadFile(file string) (*Config, error) {
-	f, err := os.OpenFile(file, os.O_RDONLY, 0o644)
-	if err != nil {
-		return nil, fmt.Errorf("opening file: %w", err)
+// Compress defines the compression of the replacement body.
+type Compress struct {
+	Encoding ContentEncoding `yaml:"encoding"`
+
+	// RespectAcceptEncoding sends the body uncompressed if the request's
+	// Accept-Encoding header doesn't accept Encoding.
+	RespectAcceptEncoding bool `yaml:"respect-accept-encoding"`
+}
+
+type ContentEncoding string
+
+const (
+	ContentEncodingGzip    ContentEncoding = "gzip"
+	ContentEncodingDeflate ContentEncoding = "deflate"
+)
+
+var ErrInvalidContentEncoding = errors.New("invalid content encoding")
+
+func (e ContentEncoding) Validate() error {
+	switch e {
+	case ContentEncodingGzip, ContentEncodingDeflate:
+		return nil
 	}
-	return Load(f)
+	return fmt.Errorf("%w: %q", ErrInvalidContentEncoding, string(e))
+}
+
+// HasBody returns true if either Body, BodyFile, BodyBase64 or Variants is set.
+func (r *Replace) HasBody() bool {
+	return r.Body != nil || r.BodyFile != nil || r.BodyBase64 != nil ||
+		len(r.Variants) > 0
+}
+
+// skipValidation skips the RFC status code check if nonstandard codes are allowed.
+func (r *Replace) skipValidation(field string) bool {
+	return field == "status-code" && r.AllowNonstandard
+}
+
+type Effect struct {
+	// Probability is the probability of the effect being applied
+	// to a matched request. Requests the effect isn't applied to
+	// pass through untouched. Nil means always.
+	Probability *Probability `yaml:"probability"`
+
+	// AfterMatches restricts the effect to requests after the resource
+	// was matched the given number of times, such as staying healthy
+	// for the first 100 requests and failing afterwards.
+	// Zero means always.
+	AfterMatches uint64 `yaml:"after-matches"`
+
+	// Bursts restricts the effect to recurring time windows.
+	// Requests outside of the windows pass through untouched.
+	// Nil means always.
+	Bursts *Bursts `yaml:"bursts"`
+
+	// Delay is applied before the request is handled.
+	Delay *DurRange `yaml:"delay"`
+
+	// DelayHeaders is applied before the response status and headers
+	// are written (time to first byte).
+	DelayHeaders *DurRange `yaml:"delay-headers"`
+
+	// DelayBody is the total delay spread across response body writes.
+	DelayBody *DurRange `yaml:"delay-body"`
+
+	// DelayAfterHeaders is applied after the response status and headers
+	// are written and flushed but before the body is written.
+	DelayAfterHeaders *DurRange `yaml:"delay-after-headers"`
+
+	Replace *Replace `yaml:"replace"`
+
+	// ReplaceWeighted is a list of candidate replacements of which one is
+	// picked per request with a probability proportional to its weight.
+	// Mutually exclusive with Replace.
+	ReplaceWeighted []WeightedReplace `yaml:"replace-weighted"`
+
+	// Redirect responds with a redirect instead of passing through.
+	// Mutually exclusive with Replace and ReplaceWeighted.
+	Redirect *Redirect `yaml:"redirect"`
+
+	// GRPCStatus responds with a gRPC status instead of passing through.
+	// Mutually exclusive with Replace, Redirect and Proxy.
+	GRPCStatus *GRPCStatus `yaml:"grpc-status"`
+
+	// Proxy forwards the request to an alternate upstream
+	// instead of passing it through to the next handler.
+	Proxy *Proxy `yaml:"proxy"`
+
+	// Record persists the responses of the next handler.
+	Record *Record `yaml:"record"`
+
+	// Replay serves recorded responses instead of passing through.
+	// Mutually exclusive with Record.
+	Replay *Replay `yaml:"replay"`
+
+	// WebSocket applies faults to WebSocket handshakes only.
+	WebSocket *WebSocket `yaml:"websocket"`
+
+	// CORS responds to CORS preflight requests instead of passing
+	// them through and adds CORS headers to other cross-origin responses.
+	CORS *CORS `yaml:"cors"`
+
+	// ResponseHeaders mutates the response headers
+	// without replacing the response.
+	ResponseHeaders *HeaderMutation `yaml:"response-headers"`
+
+	// Rewrite modifies the response of the next handler.
+	Rewrite *Rewrite `yaml:"rewrite"`
+
+	// Pad pads the response body with filler.
+	Pad *Pad `yaml:"pad"`
+
+	// Throughput limits the rate at which the response body is written.
+	Throughput *Throughput `yaml:"throughput"`
+
+	// Stream sends the replacement body in chunks.
+	Stream *Stream `yaml:"stream"`
+
+	// PartialContent serves the byte ranges of the replacement body
+	// requested by the Range header.
+	PartialContent *PartialContent `yaml:"partial-content"`
+
+	// Hang blocks without writing anything and then aborts the connection.
+	Hang *Hang `yaml:"hang"`
+
+	// Drop discards a share of the requests without any response.
+	Drop *Drop `yaml:"drop"`
+
+	// NotModified responds to a share of the conditional requests
+	// with 304 Not Modified instead of passing through.
+	NotModified *NotModified `yaml:"not-modified"`
+
+	// Malformed writes a deliberately broken HTTP/1.1 response
+	// using Replace for the status code, headers and body if set.
+	Malformed *Malformed `yaml:"malformed"`
+
+	// Reset aborts the response mid-flight.
+	Reset *Reset `yaml:"reset"`
+
+	// KeepAlive closes the connection after a share of the responses
+	// to cause connection pool churn in clients.
+	KeepAlive *KeepAlive `yaml:"keep-alive"`
+
+	// CDN serves responses from a simulated shared cache
+	// in front of the backend.
+	CDN *CDN `yaml:"cdn"`
+
+	// RetryStorm escalates the failure for clients rapidly retrying
+	// identical requests.
+	RetryStorm *RetryStorm `yaml:"retry-storm"`
+
+	// Script computes the delay and the replacement response
+	// with a script run by the script engine of the middleware.
+	Script *Script `yaml:"script"`
+
+	// MaxConcurrent limits the number of in-flight requests
+	// the effect is applied to.
+	MaxConcurrent *MaxConcurrent `yaml:"max-concurrent"`
+
+	// DNS simulates slow or failing host name resolution
+	// of outgoing requests sent through RoundTripper.
+	// Ignored by the server-side middleware.
+	DNS *DNS `yaml:"dns"`
+
+	// Dial simulates slow, refused or timing out connection attempts
+	// of outgoing requests sent through RoundTripper.
+	// Ignored by the server-side middleware.
+	Dial *Dial `yaml:"dial"`
+
+	// TLS simulates slow or failing TLS handshakes of outgoing
+	// HTTPS requests sent through RoundTripper.
+	// Ignored by the server-side middleware.
+	TLS *TLS `yaml:"tls"`
+}
+
+var ErrNoEffect = errors.New("no effect")
+
+func (e *Effect) Validate() error {
+	if e == nil {
+		return nil
+	}
+	if (e.Delay == nil || e.Delay.isNoop()) &&
+		(e.DelayHeaders == nil || e.DelayHeaders.isNoop()) &&
+		(e.DelayBody == nil || e.DelayBody.isNoop()) &&
+		(e.DelayAfterHeaders == nil || e.DelayAfterHeaders.isNoop()) &&
+		e.Replace == nil &&
+		len(e.ReplaceWeighted) < 1 &&
+		e.Redirect == nil &&
+		e.GRPCStatus == nil &&
+		e.Proxy == nil &&
+		e.Record == nil &&
+		e.Replay == nil &&
+		e.WebSocket == nil &&
+		e.CORS == nil &&
+		e.ResponseHeaders == nil &&
+		e.Rewrite == nil &&
+		e.Pad == nil &&
+		e.Throughput == nil &&
+		e.PartialContent == nil &&
+		e.Hang == nil &&
+		e.Drop == nil &&
+		e.NotModified == nil &&
+		e.Malformed == nil &&
+		e.Reset == nil &&
+		e.KeepAlive == nil &&
+		e.CDN == nil &&
+		e.RetryStorm == nil &&
+		e.Script == nil &&
+		e.MaxConcurrent == nil &&
+		e.DNS == nil &&
+		e.Dial == nil &&
+		e.TLS == nil {
+		return ErrNoEffect
+	}
+	if e.Replace != nil && len(e.ReplaceWeighted) > 0 {
+		return ErrReplaceAndReplaceWeighted
+	}
+	if e.Redirect != nil && (e.Replace != nil || len(e.ReplaceWeighted) > 0) {
+		return ErrRedirectAndReplace
+	}
+	if e.Proxy != nil &&
+		(e.Replace != nil || len(e.ReplaceWeighted) > 0 || e.Redirect != nil) {
+		return ErrProxyAndReplace
+	}
+	if e.GRPCStatus != nil && (e.Replace != nil || len(e.ReplaceWeighted) > 0 ||
+		e.Redirect != nil || e.Proxy != nil) {
+		return ErrGRPCStatusAndReplace
+	}
+	if e.Record != nil && e.Replay != nil {
+		return ErrRecordAndReplay
+	}
+	// Hanging and dropping every request prevent any response.
+	// Malformed responses are made of the replacement.
+	var responses []string // Fields writing a response.
+	for _, f := range [...]struct {
+		name string
+		set  bool
+	}{
+		{"replace", e.Replace != nil},
+		{"replace-weighted", len(e.ReplaceWeighted) > 0},
+		{"redirect", e.Redirect != nil},
+		{"proxy", e.Proxy != nil},
+	} {
+		if f.set {
+			responses = append(responses, f.name)
+		}
+	}
+	abortErr := func(abort, response string) error {
+		return fmt.Errorf("%w: %s and %s are mutually exclusive",
+			ErrAbortAndReplace, abort, response)
+	}
+	switch {
+	case len(responses) < 1:
+	case e.Hang != nil:
+		return abortErr("hang", responses[0])
+	case e.Drop != nil && e.Drop.Rate >= 1:
+		return abortErr("drop", responses[0])
+	case e.Malformed != nil && e.Redirect != nil:
+		return abortErr("malformed", "redirect")
+	case e.Malformed != nil && e.Proxy != nil:
+		return abortErr("malformed", "proxy")
+	}
+	if e.Stream != nil && !e.hasReplaceBody() {
+		return ErrStreamWithoutBody
+	}
+	if e.PartialContent != nil && !e.hasReplaceBody() {
+		return ErrPartialContentWithoutBody
+	}
+	return nil
+}
+
+// hasReplaceBody returns true if every replacement of e has a body.
+func (e *Effect) hasReplaceBody() bool {
+	if len(e.ReplaceWeighted) > 0 {
+		for _, c := range e.ReplaceWeighted {
+			if !c.Replace.HasBody() {
+				return false
+			}
+		}
+		return true
+	}
+	return e.Replace != nil && e.Replace.HasBody()
+}
+
+var (
+	ErrReplaceAndReplaceWeighted = errors.New(
+		"replace and replace-weighted are mutually exclusive",
+	)
+	ErrRedirectAndReplace = errors.New(
+		"redirect and replace are mutually exclusive",
+	)
+	ErrProxyAndReplace = errors.New(
+		"proxy is mutually exclusive with replace and redirect",
+	)
+	ErrGRPCStatusAndReplace = errors.New(
+		"grpc-status is mutually exclusive with replace, redirect and proxy",
+	)
+	// ErrAbortAndReplace is returned for effects combining hang, drop
+	// (at rate 1) or malformed with a response they prevent,
+	// wrapped with the names of both fields.
+	ErrAbortAndReplace = errors.New("aborted requests aren't responded to")
+)
+
+var ErrRecordAndReplay = errors.New("record and replay are mutually exclusive")
+
+// Record captures the responses of the next handler and persists them
+// in Dir as one JSON file per request fingerprint.
+type Record struct {
+	Dir         string      `yaml:"dir"`
+	Fingerprint Fingerprint `yaml:"fingerprint"`
+}
+
+var ErrEmptyDir = errors.New("empty dir")
+
+func (r *Record) Validate() error {
+	if r.Dir == "" {
+		return ErrEmptyDir
+	}
+	return nil
+}
+
+// Replay serves the responses recorded in Dir without calling
+// the next handler. Requests without a recording are responded to
+// with 404 unless PassThroughMissing is set.
+type Replay struct {
+	Dir         string      `yaml:"dir"`
+	Fingerprint Fingerprint `yaml:"fingerprint"`
+
+	// PassThroughMissing passes requests without a recording through.
+	PassThroughMissing bool `yaml:"pass-through-missing"`
+}
+
+func (r *Replay) Validate() error {
+	if r.Dir == "" {
+		return ErrEmptyDir
+	}
+	return nil
+}
+
+// Fingerprint defines what identifies a request in addition to
+// its method, path and query parameters.
+// Recording and replaying must use the same fingerprint.
+type Fingerprint struct {
+	// Headers are included in the fingerprint.
+	Headers []HeaderName `yaml:"headers"`
+
+	// Body includes the request body in the fingerprint.
+	Body bool `yaml:"body"`
+}
+
+// Proxy forwards requests to the upstream at URL using a reverse proxy,
+// such as a real staging backend. The path of URL is joined with the
+// request path and X-Forwarded-* headers are set.
+type Proxy struct {
+	URL URL `yaml:"url"`
+
+	// PreserveHost keeps the Host header of the request
+	// instead of using the host of URL.
+	PreserveHost bool `yaml:"preserve-host"`
+}
+
+var ErrEmptyURL = errors.New("empty url")
+
+func (p *Proxy) Validate() error {
+	if p.URL.URL() == nil {
+		return ErrEmptyURL
+	}
+	return nil
+}
+
+// WebSocket simulates faults of WebSocket handshakes for testing
+// the reconnection logic of realtime clients. Requests that aren't
+// WebSocket upgrades aren't affected.
+type WebSocket struct {
+	// UpgradeDelay delays the handshake before it's passed through.
+	UpgradeDelay *DurRange `yaml:"upgrade-delay"`
+
+	// Reject rejects the handshake with the status code if set.
+	Reject *StatusCode `yaml:"reject"`
+
+	// CloseAfter closes the upgraded connection once the duration
+	// elapsed after it was hijacked by the next handler.
+	CloseAfter *DurRange `yaml:"close-after"`
+}
+
+var (
+	ErrNoWebSocketEffect   = errors.New("no websocket effect")
+	ErrInvalidRejectStatus = errors.New("reject status code must be 300 or greater")
+)
+
+func (w *WebSocket) Validate() error {
+	if w.UpgradeDelay == nil && w.Reject == nil && w.CloseAfter == nil {
+		return ErrNoWebSocketEffect
+	}
+	if w.Reject != nil && *w.Reject < 300 {
+		return fmt.Errorf("%w: %d", ErrInvalidRejectStatus, *w.Reject)
+	}
+	return nil
+}
+
+// Redirect redirects the client to Location.
+// Location may contain the placeholders ${request.path}, ${request.query}
+// (raw query without "?") and ${request.uri} (path and query)
+// as well as placeholders of named capture groups.
+// Relative locations are resolved against the request path.
+type Redirect struct {
+	// StatusCode must be one of 301, 302, 303, 307 or 308.
+	StatusCode StatusCode `yaml:"status-code"`
+	Location   string     `yaml:"location"`
+}
+
+var (
+	ErrInvalidRedirectStatus = errors.New("invalid redirect status code")
+	ErrEmptyLocation         = errors.New("empty location")
+)
+
+func (r *Redirect) Validate() error {
+	switch r.StatusCode {
+	case http.StatusMovedPermanently,
+		http.StatusFound,
+		http.StatusSeeOther,
+		http.StatusTemporaryRedirect,
+		http.StatusPermanentRedirect:
+	default:
+		return fmt.Errorf("%w: %d", ErrInvalidRedirectStatus, r.StatusCode)
+	}
+	if r.Location == "" {
+		return ErrEmptyLocation
+	}
+	return nil
+}
+
+// GRPCStatus is a gRPC response without messages carrying the status
+// in the grpc-status and grpc-message trailers, such that gRPC clients
+// see a proper status instead of an HTTP error.
+type GRPCStatus struct {
+	// Code is the gRPC status code, such as 14 (UNAVAILABLE).
+	Code GRPCCode `yaml:"code"`
+
+	// Message is the optional status message.
+	// It may contain ${name} placeholders just like Replace headers.
+	Message string `yaml:"message"`
+}
+
+// GRPCCode is a gRPC status code between 0 (OK) and 16 (UNAUTHENTICATED).
+type GRPCCode uint32
+
+const (
+	GRPCCodeOK                 GRPCCode = 0
+	GRPCCodeCanceled           GRPCCode = 1
+	GRPCCodeUnknown            GRPCCode = 2
+	GRPCCodeInvalidArgument    GRPCCode = 3
+	GRPCCodeDeadlineExceeded   GRPCCode = 4
+	GRPCCodeNotFound           GRPCCode = 5
+	GRPCCodeAlreadyExists      GRPCCode = 6
+	GRPCCodePermissionDenied   GRPCCode = 7
+	GRPCCodeResourceExhausted  GRPCCode = 8
+	GRPCCodeFailedPrecondition GRPCCode = 9
+	GRPCCodeAborted            GRPCCode = 10
+	GRPCCodeOutOfRange         GRPCCode = 11
+	GRPCCodeUnimplemented      GRPCCode = 12
+	GRPCCodeInternal           GRPCCode = 13
+	GRPCCodeUnavailable        GRPCCode = 14
+	GRPCCodeDataLoss           GRPCCode = 15
+	GRPCCodeUnauthenticated    GRPCCode = 16
+)
+
+var ErrInvalidGRPCCode = errors.New("invalid gRPC status code")
+
+func (c GRPCCode) Validate() error {
+	if c > GRPCCodeUnauthenticated {
+		return fmt.Errorf("%w: %d", ErrInvalidGRPCCode, uint32(c))
+	}
+	return nil
+}
+
+// CORS simulates the CORS policy of a backend. Preflight requests
+// (OPTIONS requests with Origin and Access-Control-Request-Method) from
+// allowed origins are responded to with 204 and the configured policy,
+// preflight requests from other origins with 403 without CORS headers.
+// Other requests from allowed origins pass through with
+// Access-Control-Allow-Origin, -Credentials and -Expose-Headers added.
+type CORS struct {
+	// AllowOrigins matches the allowed origins, such as
+	// "https://*.example.com". Any origin is allowed if empty.
+	AllowOrigins []GlobExpression `yaml:"allow-origins"`
+
+	// AllowMethods are the allowed methods. The requested method
+	// is allowed if empty.
+	AllowMethods []string `yaml:"allow-methods"`
+
+	// AllowHeaders are the allowed request headers. The requested
+	// headers are allowed if empty.
+	AllowHeaders []HeaderName `yaml:"allow-headers"`
+
+	// ExposeHeaders are the response headers exposed to scripts.
+	ExposeHeaders []HeaderName `yaml:"expose-headers"`
+
+	// AllowCredentials allows requests with credentials.
+	AllowCredentials bool `yaml:"allow-credentials"`
+
+	// MaxAge is how long preflight responses may be cached.
+	// Zero omits Access-Control-Max-Age.
+	MaxAge time.Duration `yaml:"max-age"`
+
+	// Deny denies all origins simulating a backend without CORS support.
+	Deny bool `yaml:"deny"`
+}
+
+func (c *CORS) Validate() error {
+	if c.MaxAge < 0 {
+		return ErrNegativeDuration
+	}
+	return nil
+}
+
+// HeaderMutation modifies individual headers.
+// The mutations are applied in the order: remove, set, add, append.
+// Values may contain ${name} placeholders just like Replace headers.
+type HeaderMutation struct {
+	// Remove deletes the headers.
+	Remove []HeaderName `yaml:"remove"`
+
+	// Set replaces all values of the headers.
+	Set map[HeaderName]string `yaml:"set"`
+
+	// Add sets the headers only if they're absent.
+	Add map[HeaderName]string `yaml:"add"`
+
+	// Append adds a value to the headers, keeping existing values.
+	Append map[HeaderName]string `yaml:"append"`
+}
+
+var ErrNoHeaderMutation = errors.New("no header mutation")
+
+func (m *HeaderMutation) Validate() error {
+	if len(m.Remove) < 1 && len(m.Set) < 1 && len(m.Add) < 1 && len(m.Append) < 1 {
+		return ErrNoHeaderMutation
+	}
+	return nil
+}
+
+// Rewrite modifies the status code, headers and body of the response
+// before it reaches the client. Rewriting the body requires buffering
+// the entire response, rewriting only the status code and headers doesn't.
+type Rewrite struct {
+	// StatusCode replaces the status code if set.
+	StatusCode *StatusCode `yaml:"status-code"`
+
+	Headers *HeaderMutation `yaml:"headers"`
+
+	// Body is a list of substitutions applied to the body in order.
+	Body []BodySubstitution `yaml:"body"`
+}
+
+var ErrNoRewrite = errors.New("no rewrite")
+
+func (r *Rewrite) Validate() error {
+	if r.StatusCode == nil && r.Headers == nil && len(r.Body) < 1 {
+		return ErrNoRewrite
+	}
+	return nil
+}
+
+// BodySubstitution replaces all matches of Pattern with Replacement.
+// Replacement may refer to capture groups of Pattern
+// using $1 or ${name} as in regexp.Regexp.Expand.
+type BodySubstitution struct {
+	Pattern     Regexp `yaml:"pattern"`
+	Replacement string `yaml:"replacement"`
+}
+
+var ErrEmptyPattern = errors.New("empty pattern")
+
+func (s *BodySubstitution) Validate() error {
+	if s.Pattern.Regexp() == nil || s.Pattern.String() == "" {
+		return ErrEmptyPattern
+	}
+	return nil
+}
+
+// Pad pads the response body, replaced or not, either to TargetSize
+// or by Append bytes. Content-Length is adjusted if set.
+// Responses that have no body (HEAD, 204 and 304) aren't padded.
+type Pad struct {
+	// TargetSize is the minimum size of the body in bytes.
+	TargetSize uint64 `yaml:"target-size"`
+
+	// Append is the number of bytes appended to the body.
+	Append uint64 `yaml:"append"`
+
+	// Filler is repeated to produce the padding. Defaults to a space.
+	Filler string `yaml:"filler"`
+}
+
+var (
+	ErrNoPadding           = errors.New("either target-size or append must be set")
+	ErrTargetSizeAndAppend = errors.New("target-size and append are mutually exclusive")
+)
+
+func (p *Pad) Validate() error {
+	switch {
+	case p.TargetSize == 0 && p.Append == 0:
+		return ErrNoPadding
+	case p.TargetSize != 0 && p.Append != 0:
+		return ErrTargetSizeAndAppend
+	}
+	return nil
+}
+
+// Size returns the padding size for a body of the given size.
+func (p *Pad) Size(body uint64) uint64 {
+	if p.Append != 0 {
+		return p.Append
+	}
+	if body >= p.TargetSize {
+		return 0
+	}
+	return p.TargetSize - body
+}
+
+// WeightedReplace is a candidate replacement.
+type WeightedReplace struct {
+	// Weight is the relative likelihood of the candidate being picked.
+	Weight uint32 `yaml:"weight"`
+
+	Replace Replace `yaml:"replace"`
+}
+
+var ErrZeroWeight = errors.New("zero weight")
+
+func (w *WeightedReplace) Validate() error {
+	if w.Weight < 1 {
+		return ErrZeroWeight
+	}
+	return nil
+}
+
+// Hang blocks the request until the client disconnects or,
+// if IgnoreDisconnect is set, forever. The connection is aborted
+// without writing a response once Max elapses.
+type Hang struct {
+	// Max caps the hang duration. Zero means no cap.
+	Max time.Duration `yaml:"max"`
+
+	// IgnoreDisconnect keeps hanging even after the client disconnected.
+	IgnoreDisconnect bool `yaml:"ignore-disconnect"`
+}
+
+func (h *Hang) Validate() error {
+	if h.Max < 0 {
+		return ErrNegativeDuration
+	}
+	return nil
+}
+
+var ErrNegativeDuration = errors.New("negative duration")
+
+// Drop models packet loss and load balancer blackholing by discarding
+// requests without writing a response.
+type Drop struct {
+	// Rate is the share of the requests that are dropped.
+	Rate Probability `yaml:"rate"`
+
+	// Mode defines how requests are dropped. Defaults to DropModeClose.
+	Mode DropMode `yaml:"mode"`
+}
+
+type DropMode string
+
+const (
+	// DropModeClose closes the connection immediately.
+	DropModeClose DropMode = "close"
+
+	// DropModeHang never responds and aborts the connection
+	// once the client disconnects.
+	DropModeHang DropMode = "hang"
+)
+
+var ErrInvalidDropMode = errors.New("invalid drop mode")
+
+func (d DropMode) Validate() error {
+	switch d {
+	case "", DropModeClose, DropModeHang:
+		return nil
+	}
+	return fmt.Errorf("%w: %q", ErrInvalidDropMode, string(d))
+}
+
+// CDN simulates a shared cache such as a CDN in front of the backend.
+// Responses to GET requests are cached by URL and served to GET and HEAD
+// requests with an Age header and a cache status header reading
+// HIT, STALE, MISS or BYPASS. Responses are cached if their status code
+// is cacheable by default and Cache-Control doesn't forbid it.
+// The Vary header is respected.
+type CDN struct {
+	// TTL is how long a cached response is fresh.
+	TTL time.Duration `yaml:"ttl"`
+
+	// Stale is the share of the requests for expired responses
+	// that are served the expired response instead of being refreshed.
+	Stale Probability `yaml:"stale"`
+
+	// Header is the name of the cache status header.
+	// Defaults to X-Cache.
+	Header *HeaderName `yaml:"header"`
+
+	// MaxStale is how long an expired response is kept to be served stale.
+	// Expired responses are evicted right away if Stale is zero.
+	// Zero keeps them until they're refreshed or evicted beyond the limits.
+	MaxStale time.Duration `yaml:"max-stale"`
+
+	// MaxEntries limits the number of cached responses since URLs
+	// are controlled by clients. Beyond it or MaxBytes the least
+	// recently used response is evicted.
+	// Defaults to DefaultCDNMaxEntries.
+	MaxEntries uint32 `yaml:"max-entries"`
+
+	// MaxBytes limits the total size of the cached response bodies.
+	// Larger responses aren't cached. Defaults to DefaultCDNMaxBytes.
+	MaxBytes uint64 `yaml:"max-bytes"`
+}
+
+const (
+	// DefaultCDNMaxEntries is used if CDN.MaxEntries is zero.
+	DefaultCDNMaxEntries = 10_000
+
+	// DefaultCDNMaxBytes is used if CDN.MaxBytes is zero.
+	DefaultCDNMaxBytes = 64 << 20
+)
+
+func (c *CDN) Validate() error {
+	if c.TTL <= 0 {
+		return ErrZeroDuration
+	}
+	if c.MaxStale < 0 {
+		return ErrNegativeDuration
+	}
+	return nil
+}
+
+// KeepAlive disrupts persistent connections.
+type KeepAlive struct {
+	// Rate is the share of the responses after which
+	// the connection is closed.
+	Rate Probability `yaml:"rate"`
+
+	// Mode defines how the connection is closed.
+	// Defaults to KeepAliveModeHeader.
+	Mode KeepAliveMode `yaml:"mode"`
+}
+
+type KeepAliveMode string
+
+const (
+	// KeepAliveModeHeader sets Connection: close, which makes the server
+	// close the connection after the response, or send GOAWAY over HTTP/2.
+	KeepAliveModeHeader KeepAliveMode = "header"
+
+	// KeepAliveModeClose closes the connection after the response
+	// without announcing it such that clients find out when they
+	// reuse the connection. Falls back to KeepAliveModeHeader over HTTP/2.
+	KeepAliveModeClose KeepAliveMode = "close"
+)
+
+var ErrInvalidKeepAliveMode = errors.New("invalid keep-alive mode")
+
+func (m KeepAliveMode) Validate() error {
+	switch m {
+	case "", KeepAliveModeHeader, KeepAliveModeClose:
+		return nil
+	}
+	return fmt.Errorf("%w: %q", ErrInvalidKeepAliveMode, string(m))
+}
+
+// NotModified simulates a cache validation hit for testing HTTP caching
+// layers. Unconditional requests are never responded to with 304.
+type NotModified struct {
+	// Rate is the share of the conditional requests responded to with 304.
+	Rate Probability `yaml:"rate"`
+
+	// ETag is the entity tag sent as validator, such as "v1" (with quotes)
+	// or W/"v1". Defaults to the first entity tag of If-None-Match.
+	// Last-Modified always echoes If-Modified-Since.
+	ETag string `yaml:"etag"`
+}
+
+var ErrInvalidETag = errors.New("invalid entity tag")
+
+func (n *NotModified) Validate() error {
+	if n.ETag == "" {
+		return nil
+	}
+	tag := strings.TrimPrefix(n.ETag, "W/")
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return fmt.Errorf("%w: %q", ErrInvalidETag, n.ETag)
+	}
+	for _, c := range []byte(tag[1 : len(tag)-1]) {
+		if c < 0x21 || c == '"' || c == 0x7f {
+			return fmt.Errorf("%w: %q", ErrInvalidETag, n.ETag)
+		}
+	}
+	return nil
+}
+
+// Malformed produces broken HTTP responses for testing client hardening.
+// The response is written directly to the hijacked connection,
+// which is closed afterwards.
+type Malformed struct {
+	Kind MalformedKind `yaml:"kind"`
+}
+
+type MalformedKind string
+
+const (
+	// MalformedContentLength declares a Content-Length
+	// greater than the actual body length.
+	MalformedContentLength MalformedKind = "wrong-content-length"
+
+	// MalformedTruncatedChunked ends a chunked body mid-chunk
+	// without the terminating zero-length chunk.
+	MalformedTruncatedChunked MalformedKind = "truncated-chunked"
+
+	// MalformedStatusLine writes an unparsable status line.
+	MalformedStatusLine MalformedKind = "garbage-status-line"
+
+	// MalformedDuplicateHeaders writes conflicting duplicate
+	// Content-Length headers.
+	MalformedDuplicateHeaders MalformedKind = "duplicate-headers"
+)
+
+var ErrInvalidMalformedKind = errors.New("invalid malformed kind")
+
+func (k MalformedKind) Validate() error {
+	switch k {
+	case MalformedContentLength,
+		MalformedTruncatedChunked,
+		MalformedStatusLine,
+		MalformedDuplicateHeaders:
+		return nil
+	}
+	return fmt.Errorf("%w: %q", ErrInvalidMalformedKind, string(k))
+}
+
+// Reset aborts the response once AfterBytes of the body are written
+// or when the response is complete, whichever comes first.
+// HTTP/2 servers reset the stream (RST_STREAM),
+// HTTP/1 servers close the connection.
+type Reset struct {
+	// AfterBytes is the number of body bytes written before the reset.
+	// Zero resets right after the headers.
+	AfterBytes uint64 `yaml:"after-bytes"`
+
+	// GoAway additionally terminates the connection.
+	// HTTP/2 servers send GOAWAY and close the connection once it's idle,
+	// simulating a server shutting down with requests in-flight.
+	GoAway bool `yaml:"go-away"`
+}
+
+// DNS simulates the resolution of the host name of outgoing requests.
+type DNS struct {
+	// Delay is the duration of the lookup.
+	Delay *DurRange `yaml:"delay"`
+
+	// Failure fails the lookup after Delay. The lookup succeeds if empty.
+	Failure DNSFailure `yaml:"failure"`
+
+	// Failover periodically moves the host name to a new IP while
+	// lookups keep returning the previous, dead one for a while.
+	Failover *DNSFailover `yaml:"failover"`
+}
+
+var ErrNoDNSFault = errors.New("dns requires a delay, a failure or a failover")
+
+func (d *DNS) Validate() error {
+	if d.Delay == nil && d.Failure == "" && d.Failover == nil {
+		return ErrNoDNSFault
+	}
+	return nil
+}
+
+type DNSFailure string
+
+const (
+	// DNSFailureNXDomain fails with "no such host".
+	DNSFailureNXDomain DNSFailure = "nxdomain"
+
+	// DNSFailureTimeout fails with a timeout.
+	DNSFailureTimeout DNSFailure = "timeout"
+
+	// DNSFailureServFail fails with a temporary "server misbehaving" error.
+	DNSFailureServFail DNSFailure = "servfail"
+)
+
+var ErrInvalidDNSFailure = errors.New("invalid dns failure")
+
+func (f DNSFailure) Validate() error {
+	switch f {
+	case "", DNSFailureNXDomain, DNSFailureTimeout, DNSFailureServFail:
+		return nil
+	}
+	return fmt.Errorf("%w: %q", ErrInvalidDNSFailure, string(f))
+}
+
+// DNSFailover simulates DNS failover events during which stale records
+// send connections to a blackholed IP. The first failover happens
+// after Every has elapsed since the start of the simulator.
+type DNSFailover struct {
+	// Every is the interval between failovers.
+	Every time.Duration `yaml:"every"`
+
+	// Stale is how long lookups may return the dead IP after a failover.
+	// Must not exceed Every.
+	Stale time.Duration `yaml:"stale"`
+
+	// Rate is the share of the connections going to the dead IP
+	// while the records are stale. Defaults to 1.
+	Rate *Probability `yaml:"rate"`
+
+	// Timeout is the duration of the connection attempt to the dead IP
+	// until it times out. If nil the attempt stalls until the request
+	// is canceled.
+	Timeout *DurRange `yaml:"timeout"`
+}
+
+func (f *DNSFailover) Validate() error {
+	if f.Every <= 0 || f.Stale <= 0 {
+		return ErrZeroDuration
+	}
+	if f.Stale > f.Every {
+		return ErrWindowOverlaps
+	}
+	return nil
+}
+
+// Failover returns the number of the failover whose records are still
+// stale at the elapsed time since the start, or 0 if they're fresh.
+func (f *DNSFailover) Failover(elapsed time.Duration) int64 {
+	b := Bursts{Start: f.Every, Duration: f.Stale, Every: f.Every}
+	if !b.Active(elapsed) {
+		return 0
+	}
+	return int64(elapsed / f.Every)
+}
+
+// Dial simulates establishing the connection of outgoing requests.
+// Every request is considered to establish a new connection.
+type Dial struct {
+	// Delay is the duration of the connection attempt.
+	Delay *DurRange `yaml:"delay"`
+
+	// Failure fails the connection attempt after Delay.
+	// A timeout without Delay stalls until the request is canceled.
+	// The connection is established if empty.
+	Failure DialFailure `yaml:"failure"`
+}
+
+var ErrNoDialFault = errors.New("dial requires a delay or a failure")
+
+func (d *Dial) Validate() error {
+	if d.Delay == nil && d.Failure == "" {
+		return ErrNoDialFault
+	}
+	return nil
+}
+
+type DialFailure string
+
+const (
+	// DialFailureRefused fails with "connection refused" (ECONNREFUSED).
+	DialFailureRefused DialFailure = "refused"
+
+	// DialFailureTimeout fails with an i/o timeout.
+	DialFailureTimeout DialFailure = "timeout"
+
+	// DialFailureUnreachable fails with "no route to host" (EHOSTUNREACH).
+	DialFailureUnreachable DialFailure = "unreachable"
+)
+
+var ErrInvalidDialFailure = errors.New("invalid dial failure")
+
+func (f DialFailure) Validate() error {
+	switch f {
+	case "", DialFailureRefused, DialFailureTimeout, DialFailureUnreachable:
+		return nil
+	}
+	return fmt.Errorf("%w: %q", ErrInvalidDialFailure, string(f))
+}
+
+// TLS simulates the TLS handshake of outgoing HTTPS requests.
+type TLS struct {
+	// Delay is the duration of the handshake.
+	Delay *DurRange `yaml:"delay"`
+
+	// Failure fails the handshake after Delay.
+	// The handshake succeeds if empty.
+	Failure TLSFailure `yaml:"failure"`
+}
+
+var ErrNoTLSFault = errors.New("tls requires a delay or a failure")
+
+func (t *TLS) Validate() error {
+	if t.Delay == nil && t.Failure == "" {
+		return ErrNoTLSFault
+	}
+	return nil
+}
+
+type TLSFailure string
+
+const (
+	// TLSFailureUnknownAuthority fails the certificate verification
+	// because it's signed by an unknown authority.
+	TLSFailureUnknownAuthority TLSFailure = "unknown-authority"
+
+	// TLSFailureExpired fails the certificate verification
+	// because the certificate expired.
+	TLSFailureExpired TLSFailure = "expired"
+
+	// TLSFailureHostnameMismatch fails the certificate verification
+	// because the certificate isn't valid for the host name.
+	TLSFailureHostnameMismatch TLSFailure = "hostname-mismatch"
+
+	// TLSFailureProtocolVersion fails with a protocol_version alert
+	// sent by the server not supporting any of the offered versions.
+	TLSFailureProtocolVersion TLSFailure = "protocol-version"
+
+	// TLSFailureHandshake fails with a handshake_failure alert
+	// sent by the server, such as for lacking common cipher suites.
+	TLSFailureHandshake TLSFailure = "handshake-failure"
+
+	// TLSFailureTimeout fails with a TLS handshake timeout.
+	TLSFailureTimeout TLSFailure = "timeout"
+)
+
+var ErrInvalidTLSFailure = errors.New("invalid tls failure")
+
+func (f TLSFailure) Validate() error {
+	switch f {
+	case "", TLSFailureUnknownAuthority, TLSFailureExpired,
+		TLSFailureHostnameMismatch, TLSFailureProtocolVersion,
+		TLSFailureHandshake, TLSFailureTimeout:
+		return nil
+	}
+	return fmt.Errorf("%w: %q", ErrInvalidTLSFailure, string(f))
+}
+
+// Connections simulates link-level faults of accepted connections
+// that HTTP-layer effects can't express. The byte thresholds count
+// the bytes written to the connection.
+type Connections struct {
+	// Probability is the probability of an accepted connection
+	// being degraded. Defaults to 1.
+	Probability *Probability `yaml:"probability"`
+
+	// Throughput limits the rate at which data is written.
+	Throughput *Throughput `yaml:"throughput"`
+
+	// ReadThroughput limits the rate at which data is read.
+	ReadThroughput *Throughput `yaml:"read-throughput"`
+
+	// Latency delays every read and write.
+	Latency *DurRange `yaml:"latency"`
+
+	// Stalls randomly pause reads and writes.
+	Stalls *Stalls `yaml:"stalls"`
+
+	// Stall pauses writing once.
+	Stall *ConnStall `yaml:"stall"`
+
+	// HalfClose closes the write side of the connection
+	// while it can still be read from.
+	HalfClose *ConnCutoff `yaml:"half-close"`
+
+	// Reset aborts the connection with a TCP RST.
+	Reset *ConnCutoff `yaml:"reset"`
+}
+
+var ErrNoConnectionFault = errors.New("no connection fault")
+
+func (c *Connections) Validate() error {
+	if c.Throughput == nil && c.ReadThroughput == nil &&
+		c.Latency == nil && c.Stalls == nil && c.Stall == nil &&
+		c.HalfClose == nil && c.Reset == nil {
+		return ErrNoConnectionFault
+	}
+	return nil
+}
+
+// Stalls randomly pause reads and writes of a connection.
+type Stalls struct {
+	// Probability is the probability of each read and write stalling.
+	Probability Probability `yaml:"probability"`
+
+	// Duration is the duration of a stall.
+	Duration DurRange `yaml:"duration"`
+}
+
+// ConnStall pauses writing to a connection.
+type ConnStall struct {
+	// AfterBytes is the number of bytes written before the stall.
+	AfterBytes uint64 `yaml:"after-bytes"`
+
+	// Duration is the duration of the stall.
+	Duration DurRange `yaml:"duration"`
+}
+
+// ConnCutoff cuts a connection off.
+type ConnCutoff struct {
+	// AfterBytes is the number of bytes written before the cutoff.
+	// Zero cuts off before anything is written.
+	AfterBytes uint64 `yaml:"after-bytes"`
+}
+
+// Bursts is a schedule of outage windows relative to the start
+// of the middleware, such as 30s every 10 minutes or a single
+// window 2 minutes after the start.
+type Bursts struct {
+	// Start is the offset of the first window.
+	Start time.Duration `yaml:"start"`
+
+	// Duration is the length of each window.
+	Duration time.Duration `yaml:"duration"`
+
+	// Every is the interval between the starts of consecutive windows.
+	// Zero means there's only one window.
+	Every time.Duration `yaml:"every"`
+}
+
+var (
+	ErrZeroDuration   = errors.New("zero duration")
+	ErrWindowOverlaps = errors.New("window duration exceeds interval")
+)
+
+func (b *Bursts) Validate() error {
+	if b.Start < 0 || b.Every < 0 {
+		return ErrNegativeDuration
+	}
+	if b.Duration <= 0 {
+		return ErrZeroDuration
+	}
+	if b.Every != 0 && b.Duration > b.Every {
+		return ErrWindowOverlaps
+	}
+	return nil
+}
+
+// Active returns true if elapsed time since the start is within a window.
+func (b *Bursts) Active(elapsed time.Duration) bool {
+	if elapsed < b.Start {
+		return false
+	}
+	elapsed -= b.Start
+	if b.Every != 0 {
+		elapsed %= b.Every
+	}
+	return elapsed < b.Duration
+}
+
+// MaxConcurrent simulates an upstream with an exhausted thread pool.
+// Requests exceeding Limit wait up to QueueTimeout for a free slot
+// and are rejected with 503 Service Unavailable if none frees up.
+type MaxConcurrent struct {
+	Limit uint32 `yaml:"limit"`
+
+	// QueueTimeout is the maximum time to wait for a free slot.
+	// Zero rejects immediately.
+	QueueTimeout time.Duration `yaml:"queue-timeout"`
+}
+
+var ErrZeroLimit = errors.New("zero limit")
+
+func (c *MaxConcurrent) Validate() error {
+	if c.Limit < 1 {
+		return ErrZeroLimit
+	}
+	if c.QueueTimeout < 0 {
+		return ErrNegativeDuration
+	}
+	return nil
+}
+
+// RetryStorm simulates the load shedding of an overloaded backend
+// punishing clients that amplify outages by retrying too aggressively.
+// Requests with the same method, path and idempotency key are retries
+// of each other if they arrive within Window of the previous one.
+// Retries beyond Threshold are rejected with 503 Service Unavailable
+// and a Retry-After doubling with every further retry.
+type RetryStorm struct {
+	// Window is the maximum interval between retries of a request.
+	Window time.Duration `yaml:"window"`
+
+	// Threshold is the number of retries tolerated before escalating.
+	Threshold uint32 `yaml:"threshold"`
+
+	// KeyHeader is the name of the idempotency key header.
+	// Defaults to Idempotency-Key.
+	KeyHeader *HeaderName `yaml:"key-header"`
+
+	// RetryAfter is the Retry-After of the first rejected retry.
+	// Defaults to 1s.
+	RetryAfter time.Duration `yaml:"retry-after"`
+
+	// MaxRetryAfter caps the Retry-After. Zero means no cap.
+	MaxRetryAfter time.Duration `yaml:"max-retry-after"`
+
+	// DropAfter is the number of retries after which the connection
+	// is dropped without a response. Zero never drops.
+	DropAfter uint32 `yaml:"drop-after"`
+
+	// MaxKeys limits the number of requests tracked at a time
+	// since request keys are controlled by clients. Beyond it the least
+	// recently seen request is forgotten and its retries start over.
+	// Defaults to DefaultMaxRetryKeys.
+	MaxKeys uint32 `yaml:"max-keys"`
+}
+
+// DefaultMaxRetryKeys is used if RetryStorm.MaxKeys is zero.
+const DefaultMaxRetryKeys = 10_000
+
+func (s *RetryStorm) Validate() error {
+	if s.Window <= 0 {
+		return ErrZeroDuration
+	}
+	if s.RetryAfter < 0 || s.MaxRetryAfter < 0 {
+		return ErrNegativeDuration
+	}
+	return nil
+}
+
+// Stream drip-feeds the replacement body to the client.
+// The status code and headers are flushed immediately,
+// then each chunk is written and flushed after a delay.
+type Stream struct {
+	// ChunkSize is the number of bytes written per chunk.
+	ChunkSize uint64 `yaml:"chunk-size"`
+
+	// ChunkDelay is the delay before each chunk.
+	ChunkDelay DurRange `yaml:"chunk-delay"`
+}
+
+var (
+	ErrStreamWithoutBody = errors.New("stream requires a replacement body")
+	ErrZeroChunkSize     = errors.New("zero chunk size")
+)
+
+func (s *Stream) Validate() error {
+	if s.ChunkSize == 0 {
+		return ErrZeroChunkSize
+	}
+	return nil
+}
+
+// PartialContent serves a single byte range of a 200 replacement body
+// with 206 Partial Content and Content-Range, or 416 Range Not Satisfiable
+// if the range is beyond the body, for testing resumable downloads.
+// Requests without a Range header, with multiple ranges or an invalid
+// Range header receive the entire body.
+type PartialContent struct {
+	// Mode defines how ranges are responded to. Defaults to PartialContentServe.
+	Mode PartialContentMode `yaml:"mode"`
+}
+
+var ErrPartialContentWithoutBody = errors.New(
+	"partial-content requires a replacement body",
+)
+
+type PartialContentMode string
+
+const (
+	// PartialContentServe serves the requested range.
+	PartialContentServe PartialContentMode = "serve"
+
+	// PartialContentIgnore ignores the Range header
+	// and responds with 200 and the entire body.
+	PartialContentIgnore PartialContentMode = "ignore"
+
+	// PartialContentUnsatisfiable responds to range requests with 416.
+	PartialContentUnsatisfiable PartialContentMode = "unsatisfiable"
+)
+
+var ErrInvalidPartialContentMode = errors.New("invalid partial content mode")
+
+func (m PartialContentMode) Validate() error {
+	switch m {
+	case "", PartialContentServe, PartialContentIgnore, PartialContentUnsatisfiable:
+		return nil
+	}
+	return fmt.Errorf("%w: %q", ErrInvalidPartialContentMode, string(m))
+}
+
+// Probability is a probability within 0.0 and 1.0.
+// See ParseProbability for the accepted syntax.
+type Probability float64
+
+var ErrInvalidProbability = errors.New("probability must be within 0.0 and 1.0")
+
+// ParseProbability parses a probability given either as a fraction ("0.05"),
+// a percentage ("5%") or a ratio ("1/20").
+func ParseProbability(s string) (Probability, error) {
+	s = strings.TrimSpace(s)
+	var v float64
+	var err error
+	if percentage, ok := strings.CutSuffix(s, "%"); ok {
+		v, err = strconv.ParseFloat(strings.TrimSpace(percentage), 64)
+		v /= 100
+	} else if num, den, ok := strings.Cut(s, "/"); ok {
+		var n, d float64
+		if n, err = strconv.ParseFloat(strings.TrimSpace(num), 64); err == nil {
+			d, err = strconv.ParseFloat(strings.TrimSpace(den), 64)
+		}
+		if err == nil && d == 0 {
+			err = errors.New("zero denominator")
+		}
+		v = n / d
+	} else {
+		v, err = strconv.ParseFloat(s, 64)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidProbability, s)
+	}
+	p := Probability(v)
+	return p, p.Validate()
+}
+
+// Probability must implement TextUnmarshaler for YAML decoding.
+var _ encoding.TextUnmarshaler = new(Probability)
+
+func (p *Probability) UnmarshalText(text []byte) (err error) {
+	*p, err = ParseProbability(string(text))
+	return err
+}
+
+func (p Probability) Validate() error {
+	if p < 0 || p > 1 {
+		return fmt.Errorf("%w: %v", ErrInvalidProbability, float64(p))
+	}
+	return nil
+}
+
+// Throughput paces response body writes to simulate a slow connection.
+type Throughput struct {
+	// BytesPerSecond is the maximum transfer rate.
+	BytesPerSecond uint64 `yaml:"bytes-per-second"`
+
+	// Burst is the number of bytes written before throttling begins.
+	Burst uint64 `yaml:"burst"`
+}
+
+var ErrZeroThroughput = errors.New("zero bytes per second")
+
+func (t *Throughput) Validate() error {
+	if t.BytesPerSecond == 0 {
+		return ErrZeroThroughput
+	}
+	return nil
+}
+
+// DurRange is a random duration range.
+// For distributions other than uniform, Min and Max clamp the sampled
+// duration and Max 0 means unbounded.
+type DurRange struct {
+	Min time.Duration `yaml:"min"`
+	Max time.Duration `yaml:"max"`
+
+	// Distribution is the probability distribution durations are sampled from.
+	// Defaults to DistributionUniform.
+	Distribution Distribution `yaml:"distribution"`
+
+	// Mean is the mean of DistributionNormal and the mean of the duration
+	// added to Min for DistributionExponential.
+	Mean time.Duration `yaml:"mean"`
+
+	// StdDev is the standard deviation of DistributionNormal.
+	StdDev time.Duration `yaml:"stddev"`
+
+	// Median and Sigma (the standard deviation of the
+	// natural logarithm) parameterize DistributionLogNormal.
+	Median time.Duration `yaml:"median"`
+	Sigma  float64       `yaml:"sigma"`
+
+	// Shape is the shape parameter (alpha) of DistributionPareto.
+	// The scale parameter is Min.
+	Shape float64 `yaml:"shape"`
+
+	// Percentiles is the latency profile of DistributionPercentiles,
+	// such as {p50: 20ms, p95: 200ms, p99: 2s}. Durations are linearly
+	// interpolated between Min at p0, the given percentiles and Max at p100,
+	// or the highest percentile if Max is 0.
+	Percentiles map[Percentile]time.Duration `yaml:"percentiles"`
+}
+
+// Percentile is a percentile such as "p50" or "p99.9".
+type Percentile float64
+
+var ErrInvalidPercentile = errors.New("invalid percentile")
+
+// Percentile must implement TextUnmarshaler for YAML decoding
+// and yaml.Marshaler for encoding.
+var (
+	_ encoding.TextUnmarshaler = new(Percentile)
+	_ yaml.Marshaler           = Percentile(0)
+)
+
+func (p Percentile) MarshalYAML() (any, error) { return p.String(), nil }
+
+func (p *Percentile) UnmarshalText(text []byte) error {
+	s, ok := strings.CutPrefix(string(text), "p")
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrInvalidPercentile, string(text))
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return fmt.Errorf("%w: %q", ErrInvalidPercentile, string(text))
+	}
+	*p = Percentile(v)
+	return p.Validate()
+}
+
+func (p Percentile) Validate() error {
+	if p <= 0 || p >= 100 {
+		return fmt.Errorf("%w: p%v must be within p0 and p100", ErrInvalidPercentile, float64(p))
+	}
+	return nil
+}
+
+func (p Percentile) String() string {
+	return "p" + strconv.FormatFloat(float64(p), 'f', -1, 64)
+}
+
+type Distribution string
+
+const (
+	DistributionUniform     Distribution = "uniform"
+	DistributionNormal      Distribution = "normal"
+	DistributionExponential Distribution = "exponential"
+	DistributionLogNormal   Distribution = "log-normal"
+	DistributionPareto      Distribution = "pareto"
+	DistributionPercentiles Distribution = "percentiles"
+)
+
+var ErrInvalidDistribution = errors.New("invalid distribution")
+
+func (d Distribution) Validate() error {
+	switch d {
+	case "",
+		DistributionUniform,
+		DistributionNormal,
+		DistributionExponential,
+		DistributionLogNormal,
+		DistributionPareto,
+		DistributionPercentiles:
+		return nil
+	}
+	return fmt.Errorf("%w: %q", ErrInvalidDistribution, string(d))
+}
+
+// IsUniform returns true for DistributionUniform and the zero value.
+func (d Distribution) IsUniform() bool { return d == "" || d == DistributionUniform }
+
+var (
+	ErrMinGreaterMax            = errors.New("min greater than max")
+	ErrInvalidDistributionParam = errors.New("invalid distribution parameter")
+)
+
+var ErrInvalidDurRange = errors.New("invalid duration range")
+
+// ParseDurRange parses the shorthand syntax of uniform duration ranges,
+// which is either "<min>-<max>", such as "200ms-2s", or a fixed duration
+// such as "500ms". The shorthand is accepted anywhere a duration range is.
+func ParseDurRange(s string) (DurRange, error) {
+	minStr, maxStr, isRange := strings.Cut(strings.TrimSpace(s), "-")
+	if !isRange {
+		maxStr = minStr
+	}
+	min, err := time.ParseDuration(strings.TrimSpace(minStr))
+	if err != nil {
+		return DurRange{}, fmt.Errorf("%w: %q", ErrInvalidDurRange, s)
+	}
+	max, err := time.ParseDuration(strings.TrimSpace(maxStr))
+	if err != nil {
+		return DurRange{}, fmt.Errorf("%w: %q", ErrInvalidDurRange, s)
+	}
+	return DurRange{Min: min, Max: max}, nil
+}
+
+func (r DurRange) Validate() error {
+	if err := r.Distribution.Validate(); err != nil {
+		return err
+	}
+	if r.Min > r.Max && (r.Distribution.IsUniform() || r.Max != 0) {
+		return ErrMinGreaterMax
+	}
+	switch r.Distribution {
+	case DistributionNormal:
+		if r.StdDev < 0 {
+			return fmt.Errorf("%w: negative stddev", ErrInvalidDistributionParam)
+		}
+	case DistributionExponential:
+		if r.Mean <= 0 {
+			return fmt.Errorf("%w: mean must be positive", ErrInvalidDistributionParam)
+		}
+	case DistributionLogNormal:
+		if r.Median <= 0 {
+			return fmt.Errorf("%w: median must be positive", ErrInvalidDistributionParam)
+		}
+		if r.Sigma < 0 {
+			return fmt.Errorf("%w: negative sigma", ErrInvalidDistributionParam)
+		}
+	case DistributionPareto:
+		if r.Min <= 0 {
+			return fmt.Errorf("%w: min (scale) must be positive",
+				ErrInvalidDistributionParam)
+		}
+		if r.Shape <= 0 {
+			return fmt.Errorf("%w: shape must be positive", ErrInvalidDistributionParam)
+		}
+	case DistributionPercentiles:
+		if len(r.Percentiles) < 1 {
+			return fmt.Errorf("%w: no percentiles", ErrInvalidDistributionParam)
+		}
+		previous := r.Min
+		for _, p := range r.SortedPercentiles() {
+			if err := p.Percentile.Validate(); err != nil {
+				return err
+			}
+			if p.Duration < previous {
+				return fmt.Errorf("%w: %v must not be lower than "+
+					"previous percentiles and min",
+					ErrInvalidDistributionParam, p.Percentile)
+			}
+			if r.Max != 0 && p.Duration > r.Max {
+				return fmt.Errorf("%w: %v greater than max",
+					ErrInvalidDistributionParam, p.Percentile)
+			}
+			previous = p.Duration
+		}
+	}
+	return nil
+}
+
+// PercentileDuration is a single entry of a percentile latency profile.
+type PercentileDuration struct {
+	Percentile Percentile
+	Duration   time.Duration
+}
+
+// SortedPercentiles returns Percentiles sorted in ascending order.
+func (r *DurRange) SortedPercentiles() []PercentileDuration {
+	s := make([]PercentileDuration, 0, len(r.Percentiles))
+	for p, d := range r.Percentiles {
+		s = append(s, PercentileDuration{Percentile: p, Duration: d})
+	}
+	slices.SortFunc(s, func(a, b PercentileDuration) int {
+		return cmp.Compare(a.Percentile, b.Percentile)
+	})
+	return s
+}
+
+// isNoop returns true if r always yields a zero duration.
+func (r *DurRange) isNoop() bool { return r.Distribution.IsUniform() && r.Min == 0 }
+
+// Uint64Range is an inclusive range. Max 0 means unbounded.
+type Uint64Range struct {
+	Min uint64 `yaml:"min"`
+	Max uint64 `yaml:"max"`
+}
+
+func (r Uint64Range) Validate() error {
+	if r.Max != 0 && r.Min > r.Max {
+		return ErrMinGreaterMax
+	}
+	return nil
+}
+
+// Contains returns true if v is within r, otherwise returns false.
+func (r Uint64Range) Contains(v uint64) bool {
+	return v >= r.Min && (r.Max == 0 || v <= r.Max)
+}
+
+type StatusCode int32
+
+var ErrInvalidStatusCode = errors.New("invalid HTTP response status code")
+
+func (c StatusCode) Validate() error {
+	if http.StatusText(int(c)) == "" {
+		return fmt.Errorf("%w: %d", ErrInvalidStatusCode, c)
+	}
+	return nil
+}
+
+type HeaderName string
+
+var ErrInvalidHeaderName = errors.New("invalid header name")
+
+func (n HeaderName) Validate() error {
+	if n == "" {
+		return ErrInvalidHeaderName
+	}
+	for _, char := range n {
+		if !(char == '-' ||
+			unicode.IsLetter(char) ||
+			unicode.IsDigit(char)) ||
+			char > unicode.MaxASCII {
+			return ErrInvalidHeaderName
+		}
+	}
+	return nil
+}
+
+type GlobExpression struct {
+	// glob is a pointer to make the struct comparable
+	// and allow it to be used as map key.
+	glob *glob.Glob
+
+	// expression is the source the glob was compiled from.
+	expression string
+}
+
+func (e GlobExpression) String() string { return e.expression }
+
+func NewGlobExpression(expression string) (GlobExpression, error) {
+	g, err := glob.Compile(expression)
+	if err != nil {
+		return GlobExpression{}, err
+	}
+	return GlobExpression{glob: &g, expression: expression}, nil
+}
+
+// GlobExpression must implement TextUnmarshaler for YAML decoding
+// and yaml.Marshaler for encoding.
+var (
+	_ encoding.TextUnmarshaler = new(GlobExpression)
+	_ yaml.Marshaler           = GlobExpression{}
+	_ glob.Glob                = new(GlobExpression)
+)
+
+func (g GlobExpression) MarshalYAML() (any, error) { return g.expression, nil }
+
+func (g *GlobExpression) UnmarshalText(text []byte) (err error) {
+	c, err := glob.Compile(string(text))
+	if err != nil {
+		return err
+	}
+	g.glob, g.expression = &c, string(text)
+	return nil
+}
+
+func (g *GlobExpression) Match(s string) bool {
+	if g.glob == nil {
+		return true
+	}
+	return (*g.glob).Match(s)
+}
+
+// Base64 is binary data encoded in standard base64 (RFC 4648).
+type Base64 struct{ data []byte }
+
+func NewBase64(data []byte) Base64 { return Base64{data: data} }
+
+// Base64 must implement TextUnmarshaler for YAML decoding
+// and yaml.Marshaler for encoding.
+var (
+	_ encoding.TextUnmarshaler = new(Base64)
+	_ yaml.Marshaler           = Base64{}
+)
+
+func (b Base64) MarshalYAML() (any, error) { return b.String(), nil }
+
+func (b *Base64) UnmarshalText(text []byte) (err error) {
+	b.data, err = base64.StdEncoding.DecodeString(string(text))
+	return err
+}
+
+func (b Base64) String() string { return base64.StdEncoding.EncodeToString(b.data) }
+
+// Bytes returns the decoded data.
+func (b Base64) Bytes() []byte { return b.data }
+
+// URL is an absolute HTTP or HTTPS URL.
+type URL struct{ u *url.URL }
+
+var ErrInvalidURL = errors.New("invalid url, must be absolute http or https")
+
+func NewURL(s string) (URL, error) {
+	var u URL
+	err := u.UnmarshalText([]byte(s))
+	return u, err
+}
+
+// URL must implement TextUnmarshaler for YAML decoding
+// and yaml.Marshaler for encoding.
+var (
+	_ encoding.TextUnmarshaler = new(URL)
+	_ yaml.Marshaler           = URL{}
+)
+
+func (u URL) MarshalYAML() (any, error) { return u.String(), nil }
+
+func (u *URL) UnmarshalText(text []byte) error {
+	p, err := url.Parse(string(text))
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidURL, err)
+	}
+	if (p.Scheme != "http" && p.Scheme != "https") || p.Host == "" {
+		return fmt.Errorf("%w: %q", ErrInvalidURL, string(text))
+	}
+	u.u = p
+	return nil
+}
+
+func (u URL) String() string {
+	if u.u == nil {
+		return ""
+	}
+	return u.u.String()
+}
+
+// URL returns the parsed URL or nil if u is uninitialized.
+func (u URL) URL() *url.URL { return u.u }
+
+// Regexp is a regular expression in RE2 syntax.
+type Regexp struct{ re *regexp.Regexp }
+
+func (e Regexp) String() string {
+	if e.re == nil {
+		return ""
+	}
+	return e.re.String()
+}
+
+func NewRegexp(expression string) (Regexp, error) {
+	re, err := regexp.Compile(expression)
+	if err != nil {
+		return Regexp{}, err
+	}
+	return Regexp{re: re}, nil
+}
+
+// Regexp must implement TextUnmarshaler for YAML decoding
+// and yaml.Marshaler for encoding.
+var (
+	_ encoding.TextUnmarshaler = new(Regexp)
+	_ yaml.Marshaler           = Regexp{}
+)
+
+func (e Regexp) MarshalYAML() (any, error) { return e.String(), nil }
+
+func (e *Regexp) UnmarshalText(text []byte) (err error) {
+	e.re, err = regexp.Compile(string(text))
+	return err
+}
+
+// Regexp returns the compiled regular expression or nil if e is uninitialized.
+func (e Regexp) Regexp() *regexp.Regexp { return e.re }
+
+// Limits bounds the size of configs to protect servers from
+// accidentally loading huge simulations. Zero disables a limit.
+type Limits struct {
+	// MaxBodyBytes is the maximum size of inline replacement bodies
+	// (body and body-base64). Body files aren't checked.
+	MaxBodyBytes int64
+
+	// MaxResources is the maximum number of resources
+	// including the resources of included files.
+	MaxResources int
+
+	// MaxGlobComplexity is the maximum number of wildcards,
+	// character classes and alternatives in a glob expression.
+	MaxGlobComplexity int
+
+	// MaxSourceBytes is the maximum size of the YAML source read by Load
+	// and LoadURL, such as configs fetched from a server.
+	// Files, including included files, aren't checked.
+	MaxSourceBytes int64
+}
+
+// DefaultLimits are the limits enforced by Validate and by the loaders
+// unless WithLimits is passed. DefaultLimits must not be modified,
+// use ValidateWithLimits and WithLimits to enforce other limits.
+var DefaultLimits = Limits{
+	MaxBodyBytes:      10 << 20,
+	MaxResources:      10_000,
+	MaxGlobComplexity: 64,
+	MaxSourceBytes:    64 << 20,
+}
+
+var (
+	ErrBodyTooLarge     = errors.New("body too large")
+	ErrTooManyResources = errors.New("too many resources")
+	ErrGlobTooComplex   = errors.New("glob too complex")
+	ErrSourceTooLarge   = errors.New("source too large")
+)
+
+// checks are the checks validateRecursively performs
+// in addition to calling the Validate methods.
+type checks struct {
+	limits Limits
+	script func(*Script) error // Optional.
+}
+
+// check returns an error if v fails the checks and the name of
+// the offending field of v, if any.
+func (c checks) check(v reflect.Value) (field string, err error) {
+	if field, err := c.limits.check(v); err != nil {
+		return field, err
+	}
+	if s, ok := v.Interface().(*Script); ok && c.script != nil {
+		return "", c.script(s)
+	}
+	return "", nil
+}
+
+// check returns an error if v exceeds l and the name of
+// the offending field of v, if any.
+func (l Limits) check(v reflect.Value) (field string, err error) {
+	if v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+	switch x := v.Interface().(type) {
+	case GlobExpression:
+		if c := globComplexity(x.expression); l.MaxGlobComplexity > 0 &&
+			c > l.MaxGlobComplexity {
+			return "", fmt.Errorf("%w: complexity %d exceeds %d",
+				ErrGlobTooComplex, c, l.MaxGlobComplexity)
+		}
+	case Replace:
+		if l.MaxBodyBytes <= 0 {
+			return "", nil
+		}
+		if x.Body != nil && int64(len(*x.Body)) > l.MaxBodyBytes {
+			return "body", fmt.Errorf("%w: %d bytes exceeds %d",
+				ErrBodyTooLarge, len(*x.Body), l.MaxBodyBytes)
+		}
+		if x.BodyBase64 != nil && int64(len(x.BodyBase64.data)) > l.MaxBodyBytes {
+			return "body-base64", fmt.Errorf("%w: %d bytes exceeds %d",
+				ErrBodyTooLarge, len(x.BodyBase64.data), l.MaxBodyBytes)
+		}
+	}
+	return "", nil
+}
+
+// globComplexity returns the number of wildcards, character classes
+// and alternatives in glob expression. Consecutive stars count once.
+func globComplexity(expression string) (complexity int) {
+	var braces int
+	for i := 0; i < len(expression); i++ {
+		switch expression[i] {
+		case '\\':
+			i++ // Skip the escaped character.
+		case '*':
+			if i < 1 || expression[i-1] != '*' {
+				complexity++
+			}
+		case '?':
+			complexity++
+		case '[':
+			complexity++
+			if end := strings.IndexByte(expression[i:], ']'); end > 0 {
+				i += end
+			}
+		case '{':
+			braces++
+			complexity++
+		case ',':
+			if braces > 0 {
+				complexity++
+			}
+		case '}':
+			if braces > 0 {
+				braces--
+			}
+		}
+	}
+	return complexity
+}
+
+// Validate returns an *ErrValidation if c is invalid or exceeds
+// DefaultLimits, otherwise returns nil.
+func Validate(c Config) error { return ValidateWithLimits(c, DefaultLimits) }
+
+// ValidateWithLimits is like Validate but enforces l instead of
+// DefaultLimits. Limits set to zero aren't enforced.
+func ValidateWithLimits(c Config, l Limits) error {
+	return validate(c, checks{limits: l})
+}
+
+func validate(c Config, x checks) error {
+	if err := yamagiconf.ValidateType[Config](); err != nil {
+		return &ErrValidation{ResourceIndex: -1, Err: err}
+	}
+	if c.Version > CurrentVersion {
+		return &ErrValidation{
+			ResourceIndex: -1, Path: "version",
+			Err: fmt.Errorf("%w: %d", ErrUnsupportedVersion, c.Version),
+		}
+	}
+	if max := x.limits.MaxResources; max > 0 && len(c.Resources) > max {
+		return &ErrValidation{
+			ResourceIndex: -1, Path: "resources",
+			Err: fmt.Errorf("%w: %d exceeds %d",
+				ErrTooManyResources, len(c.Resources), max),
+		}
+	}
+	path, err := validateRecursively("", reflect.ValueOf(&c), x)
+	if err != nil {
+		e := &ErrValidation{ResourceIndex: -1, Path: path, Err: err}
+		_, _ = fmt.Sscanf(path, "resources[%d]", &e.ResourceIndex)
+		if e.ResourceIndex >= 0 && e.ResourceIndex < len(c.Resources) {
+			e.ResourceName = c.Resources[e.ResourceIndex].Name
+		}
+		return e
+	}
+	names := make(map[string]struct{}, len(c.Resources))
+	for i, r := range c.Resources {
+		if r.Name == "" {
+			continue
+		}
+		if _, ok := names[r.Name]; ok {
+			return &ErrValidation{
+				ResourceIndex: i,
+				ResourceName:  r.Name,
+				Path:          fmt.Sprintf("resources[%d].name", i),
+				Err:           fmt.Errorf("%w: %q", ErrDuplicateResourceName, r.Name),
+			}
+		}
+		names[r.Name] = struct{}{}
+	}
+	return validateScenario(c)
+}
+
+var ErrDuplicateResourceName = errors.New("duplicate resource name")
+
+type validator interface{ Validate() error }
+
+// validationSkipper is implemented by types that validate
+// some of their fields in their own Validate method.
+type validationSkipper interface{ skipValidation(field string) bool }
+
+// validateRecursively invokes the Validate method of v and
+// all values reachable from it, performs the checks x on them and returns
+// the YAML path of the first value that failed validation.
+func validateRecursively(path string, v reflect.Value, x checks) (string, error) {
+	if v.Kind() == reflect.Pointer && v.IsNil() {
+		return "", nil
+	}
+	if vd, ok := v.Interface().(validator); ok {
+		if err := vd.Validate(); err != nil {
+			return path, err
+		}
+	} else if v.CanAddr() {
+		if vd, ok := v.Addr().Interface().(validator); ok {
+			if err := vd.Validate(); err != nil {
+				return path, err
+			}
+		}
+	}
+	if field, err := x.check(v); err != nil {
+		if field != "" {
+			path += "." + field
+		}
+		return path, err
+	}
+	if v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		var skipper validationSkipper
+		if v.CanAddr() {
+			skipper, _ = v.Addr().Interface().(validationSkipper)
+		}
+		t := v.Type()
+		for i := range t.NumField() {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+			if skipper != nil && skipper.skipValidation(name) {
+				continue
+			}
+			if path != "" {
+				name = path + "." + name
+			}
+			if p, err := validateRecursively(name, v.Field(i), x); err != nil {
+				return p, err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := range v.Len() {
+			p := fmt.Sprintf("%s[%d]", path, i)
+			if p, err := validateRecursively(p, v.Index(i), x); err != nil {
+				return p, err
+			}
+		}
+	case reflect.Map:
+		keys := v.MapKeys()
+		slices.SortFunc(keys, func(a, b reflect.Value) int {
+			return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+		})
+		for _, k := range keys {
+			p := fmt.Sprintf("%s[%v]", path, k)
+			if p, err := validateRecursively(p, k, x); err != nil {
+				return p, err
+			}
+			if p, err := validateRecursively(p, v.MapIndex(k), x); err != nil {
+				return p, err
+			}
+		}
+	}
+	return "", nil
+}
+
+// Merge returns base with overlays applied in order and validates the result.
+// An overlay resource replaces the resource of the same name in place.
+// The remaining overlay resources are placed before the resources of
+// the config being overlaid preserving their order, such that they take
+// precedence when matching. Includes and scenarios are concatenated.
+// DryRun, DebugHeaders and CaseInsensitiveMethods are enabled if they're
+// enabled in any of the configs, the version is the highest of all configs.
+// Enabled, Defaults, GlobalEffect and Connections of an overlay replace
+// those of the config being overlaid unless they're nil.
+// Neither base nor overlays are modified, but the merged config shares
+// effects and other referenced values with them.
+func Merge(base Config, overlays ...Config) (Config, error) {
+	merged := base
+	merged.Include = slices.Clone(base.Include)
+	merged.Resources = slices.Clone(base.Resources)
+	merged.Scenario = slices.Clone(base.Scenario)
+	for _, o := range overlays {
+		merged.Version = max(merged.Version, o.Version)
+		if o.Enabled != nil {
+			merged.Enabled = o.Enabled
+		}
+		merged.DryRun = merged.DryRun || o.DryRun
+		merged.DebugHeaders = merged.DebugHeaders || o.DebugHeaders
+		merged.CaseInsensitiveMethods = merged.CaseInsensitiveMethods ||
+			o.CaseInsensitiveMethods
+		if o.Defaults != nil {
+			merged.Defaults = o.Defaults
+		}
+		if o.GlobalEffect != nil {
+			merged.GlobalEffect = o.GlobalEffect
+		}
+		if o.Connections != nil {
+			merged.Connections = o.Connections
+		}
+		merged.Include = append(merged.Include, o.Include...)
+		merged.Scenario = append(merged.Scenario, o.Scenario...)
+
+		added := make([]Resource, 0, len(o.Resources))
+	OVERLAY:
+		for _, r := range o.Resources {
+			if r.Name != "" {
+				for i := range merged.Resources {
+					if merged.Resources[i].Name == r.Name {
+						merged.Resources[i] = r
+						continue OVERLAY
+					}
+				}
+			}
+			added = append(added, r)
+		}
+		merged.Resources = append(added, merged.Resources...)
+	}
+	if err := Validate(merged); err != nil {
+		return Config{}, err
+	}
+	return merged, nil
+}
+
+// LoadOption configures Load, LoadFile and LoadURL.
+type LoadOption func(*loadOptions)
+
+type loadOptions struct{ checks checks }
+
+func newLoadOptions(opts []LoadOption) loadOptions {
+	o := loadOptions{checks: checks{limits: DefaultLimits}}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithLimits makes the loader enforce l instead of DefaultLimits
+// on the config and all of its included files.
+func WithLimits(l Limits) LoadOption {
+	return func(o *loadOptions) { o.checks.limits = l }
+}
+
+// WithScriptValidator makes the loader validate the source of all scripts
+// using validate, which is specific to the script engine, such as
+// starlarksim.Validate. Its errors are reported as *ErrValidation.
+func WithScriptValidator(validate func(*Script) error) LoadOption {
+	return func(o *loadOptions) { o.checks.script = validate }
+}
+
+// Load loads config from arbitrary reader.
+// Includes are resolved relative to the working directory.
+// Returns *ErrDecode if src isn't valid YAML or exceeds the MaxSourceBytes
+// limit and *ErrValidation if the decoded config is invalid.
+func Load(src io.Reader, opts ...LoadOption) (*Config, error) {
+	o := newLoadOptions(opts)
+	if max := o.checks.limits.MaxSourceBytes; max > 0 {
+		// Read one more byte to tell whether src exceeds max.
+		src = io.LimitReader(src, max+1)
+	}
+	b, err := io.ReadAll(src)
+	if err != nil {
+		return nil, &ErrDecode{Err: err}
+	}
+	if max := o.checks.limits.MaxSourceBytes; max > 0 && int64(len(b)) > max {
+		return nil, &ErrDecode{Err: ErrSourceTooLarge}
+	}
+	c, root, err := decode(b)
+	if err != nil {
+		return nil, err
+	}
+	if err := resolveIncludes(c, ".", nil, map[string]bool{}, o.checks); err != nil {
+		return nil, err
+	}
+	if err := validateNode(*c, root, "", o.checks); err != nil {
+		return nil, err
+	}
+	c.Include = nil // Resolved, the config can be dumped and loaded again.
+	return c, nil
+}
+
+// LoadFile loads config from file.
+// Returns *ErrOpen if the file or any of its includes can't be opened,
+// otherwise behaves like Load.
+func LoadFile(file string, opts ...LoadOption) (*Config, error) {
+	o := newLoadOptions(opts)
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return nil, &ErrOpen{File: file, Err: err}
+	}
+	b, err := os.ReadFile(abs)
+	if err != nil {
+		return nil, &ErrOpen{File: file, Err: err}
+	}
+	c, root, err := decode(b)
+	if err != nil {
+		return nil, err
+	}
+	resolveBodyFiles(reflect.ValueOf(c), filepath.Dir(abs))
+	stack := []string{abs}
+	err = resolveIncludes(c, filepath.Dir(abs), stack, map[string]bool{abs: true}, o.checks)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateNode(*c, root, "", o.checks); err != nil {
+		return nil, err
+	}
+	c.Include = nil // Resolved, the config can be dumped and loaded again.
+	return c, nil
+}
+
+// FetchTimeout bounds LoadURL and every fetch of httpsim.WatchConfigURL
+// including reading the response body.
+const FetchTimeout = 30 * time.Second
+
+var fetchClient = &http.Client{Timeout: FetchTimeout}
+
+// LoadURL loads config from url, timing out after FetchTimeout.
+// Returns *ErrFetch if the request fails or the response status isn't 200,
+// otherwise behaves like Load.
+func LoadURL(url string, opts ...LoadOption) (*Config, error) {
+	resp, err := fetchClient.Get(url)
+	if err != nil {
+		return nil, &ErrFetch{URL: url, Err: err}
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, &ErrFetch{URL: url, StatusCode: resp.StatusCode}
+	}
+	return Load(resp.Body, opts...)
+}
+
+// decode decodes a single config file without validating it
+// and returns its node tree for locating validation errors.
+func decode(b []byte) (*Config, *yaml.Node, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(b, &root); err != nil {
+		return nil, nil, &ErrDecode{Err: err}
+	}
+	if len(root.Content) > 0 {
+		migrated, err := migrate(&root)
+		if err != nil {
+			return nil, nil, err
+		}
+		normalized := normalizeMethods(&root)
+		expanded, err := expandDurRanges(root.Content[0], reflect.TypeFor[Config]())
+		if err != nil {
+			return nil, nil, &ErrDecode{Err: err}
+		}
+		if migrated || normalized || expanded {
+			// Decode the rewritten nodes, which retain their original
+			// location, such that errors are located in the source.
+			var c Config
+			errs := unknownFields(root.Content[0], reflect.TypeOf(c))
+			if err := root.Decode(&c); err != nil {
+				var e *yaml.TypeError
+				if !errors.As(err, &e) {
+					return nil, nil, &ErrDecode{Err: err}
+				}
+				errs = append(errs, e.Errors...)
+			}
+			if errs != nil {
+				return nil, nil, &ErrDecode{Err: &yaml.TypeError{Errors: errs}}
+			}
+			return &c, &root, nil
+		}
+	}
+	var c Config
+	// Use standard YAML decoder but utilize yamagiconf validation.
+	d := yaml.NewDecoder(bytes.NewReader(b))
+	d.KnownFields(true)
+	if err := d.Decode(&c); err != nil {
+		return nil, nil, &ErrDecode{Err: err}
+	}
+	return &c, &root, nil
+}
+
+// validateNode validates c performing the checks x and sets the location
+// of the invalid value in file, which root was decoded from,
+// on the returned *ErrValidation.
+func validateNode(c Config, root *yaml.Node, file string, x checks) error {
+	err := validate(c, x)
+	if e, ok := err.(*ErrValidation); ok {
+		e.File = file
+		if n := locate(root, e.Path); n != nil {
+			e.Line, e.Column = n.Line, n.Column
+		}
+	}
+	return err
+}
+
+// locate returns the node at YAML path, such as "resources[1].headers[X-A]",
+// or nil if there's no such node.
+func locate(root *yaml.Node, path string) *yaml.Node {
+	if root == nil || root.Kind != yaml.DocumentNode || len(root.Content) < 1 {
+		return nil
+	}
+	n := root.Content[0]
+	for path != "" && n != nil {
+		var segment string
+		if strings.HasPrefix(path, "[") {
+			end := strings.IndexByte(path, ']')
+			if end < 0 {
+				return nil
+			}
+			segment, path = path[1:end], path[end+1:]
+		} else {
+			path = strings.TrimPrefix(path, ".")
+			end := strings.IndexAny(path, ".[")
+			if end < 0 {
+				end = len(path)
+			}
+			segment, path = path[:end], path[end:]
+		}
+		switch n.Kind {
+		case yaml.SequenceNode:
+			i, err := strconv.Atoi(segment)
+			if err != nil || i < 0 || i >= len(n.Content) {
+				return nil
+			}
+			n = n.Content[i]
+		case yaml.MappingNode:
+			n = mappingValue(n, segment)
+		default:
+			return nil
+		}
+	}
+	return n
+}
+
+var (
+	ErrIncludeCycle   = errors.New("include cycle")
+	ErrIncludeNoMatch = errors.New("include matches no files")
+)
+
+// resolveIncludes appends the resources of the files included by c
+// relative to dir. stack holds the files currently being included
+// to detect cycles and included holds all files included so far,
+// each file is included only once. Included files are validated
+// performing the checks x.
+func resolveIncludes(
+	c *Config, dir string, stack []string, included map[string]bool, x checks,
+) error {
+	for i, pattern := range c.Include {
+		path := fmt.Sprintf("include[%d]", i)
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(dir, pattern)
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return &ErrValidation{ResourceIndex: -1, Path: path, Err: err}
+		}
+		if len(matches) < 1 {
+			return &ErrValidation{
+				ResourceIndex: -1, Path: path,
+				Err: fmt.Errorf("%w: %q", ErrIncludeNoMatch, pattern),
+			}
+		}
+		for _, file := range matches { // Glob returns matches sorted.
+			if file, err = filepath.Abs(file); err != nil {
+				return &ErrOpen{File: file, Err: err}
+			}
+			if slices.Contains(stack, file) {
+				return &ErrValidation{
+					ResourceIndex: -1, Path: path,
+					Err: fmt.Errorf("%w: %s", ErrIncludeCycle,
+						strings.Join(append(stack, file), " -> ")),
+				}
+			}
+			if included[file] {
+				continue
+			}
+			included[file] = true
+			b, err := os.ReadFile(file)
+			if err != nil {
+				return &ErrOpen{File: file, Err: err}
+			}
+			inc, node, err := decode(b)
+			if err != nil {
+				switch err := err.(type) {
+				case *ErrDecode:
+					err.File = file
+				case *ErrValidation:
+					err.File = file
+				}
+				return err
+			}
+			resolveBodyFiles(reflect.ValueOf(inc), filepath.Dir(file))
+			err = resolveIncludes(inc, filepath.Dir(file), append(stack, file), included, x)
+			if err != nil {
+				return err
+			}
+			if err := validateNode(*inc, node, file, x); err != nil {
+				// Validate before applying defaults to report their errors.
+				return err
+			}
+			c.Resources = append(c.Resources,
+				inc.WithScenario().WithDefaults().Resources...)
+		}
+	}
+	return nil
+}
+
+// resolveBodyFiles joins dir with the relative body files
+// of all replacements and variants in v.
+func resolveBodyFiles(v reflect.Value, dir string) {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if !v.IsNil() {
+			resolveBodyFiles(v.Elem(), dir)
+		}
+	case reflect.Struct:
+		if v.CanAddr() {
+			switch x := v.Addr().Interface().(type) {
+			case *Replace:
+				x.BodyFile = resolvePath(x.BodyFile, dir)
+			case *Variant:
+				x.BodyFile = resolvePath(x.BodyFile, dir)
+			}
+		}
+		for i := range v.NumField() {
+			if v.Type().Field(i).IsExported() {
+				resolveBodyFiles(v.Field(i), dir)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := range v.Len() {
+			resolveBodyFiles(v.Index(i), dir)
+		}
+	case reflect.Map:
+		for iter := v.MapRange(); iter.Next(); {
+			resolveBodyFiles(iter.Value(), dir)
+		}
+	}
+}
+
+func resolvePath(path *string, dir string) *string {
+	if path == nil || filepath.IsAbs(*path) {
+		return path
+	}
+	p := filepath.Join(dir, *path)
+	return &p
+}
+
+// normalizeMethods upper-cases all resource methods in document root
+// if case-insensitive-methods is enabled and returns true if it did.
+func normalizeMethods(root *yaml.Node) bool {
+	var enabled bool
+	opt := mappingValue(root.Content[0], "case-insensitive-methods")
+	if opt == nil || opt.Decode(&enabled) != nil || !enabled {
+		// Decoding errors are reported by the strict decoder.
+		return false
+	}
+	resources := mappingValue(root.Content[0], "resources")
+	if resources == nil || resources.Kind != yaml.SequenceNode {
+		return false
+	}
+	for _, r := range resources.Content {
+		methods := mappingValue(r, "methods")
+		if methods == nil || methods.Kind != yaml.SequenceNode {
+			continue
+		}
+		for _, m := range methods.Content {
+			if m.Kind == yaml.ScalarNode {
+				m.Value = strings.ToUpper(m.Value)
+			}
+		}
+	}
+	return true
+}
+
+// expandDurRanges replaces the scalar nodes of duration ranges in n,
+// which is decoded into a value of type t, with the equivalent mapping
+// nodes and returns true if any was replaced.
+// See ParseDurRange for the accepted shorthand syntax.
+func expandDurRanges(n *yaml.Node, t reflect.Type) (bool, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == reflect.TypeFor[DurRange]() {
+		if n.Kind != yaml.ScalarNode || n.Tag == "!!null" {
+			return false, nil
+		}
+		r, err := ParseDurRange(n.Value)
+		if err != nil {
+			return false, fmt.Errorf("line %d: %w", n.Line, err)
+		}
+		scalar := func(v string) *yaml.Node {
+			return &yaml.Node{
+				Kind: yaml.ScalarNode, Tag: "!!str", Value: v,
+				Line: n.Line, Column: n.Column,
+			}
+		}
+		*n = yaml.Node{
+			Kind: yaml.MappingNode, Tag: "!!map", Line: n.Line, Column: n.Column,
+			Content: []*yaml.Node{
+				scalar("min"), scalar(r.Min.String()),
+				scalar("max"), scalar(r.Max.String()),
+			},
+		}
+		return true, nil
+	}
+	var expanded bool
+	expand := func(n *yaml.Node, t reflect.Type) error {
+		e, err := expandDurRanges(n, t)
+		expanded = expanded || e
+		return err
+	}
+	switch {
+	case t.Kind() == reflect.Struct && n.Kind == yaml.MappingNode:
+		for i := range t.NumField() {
+			f := t.Field(i)
+			name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+			if v := mappingValue(n, name); f.IsExported() && v != nil {
+				if err := expand(v, f.Type); err != nil {
+					return false, err
+				}
+			}
+		}
+	case t.Kind() == reflect.Slice && n.Kind == yaml.SequenceNode:
+		for _, item := range n.Content {
+			if err := expand(item, t.Elem()); err != nil {
+				return false, err
+			}
+		}
+	case t.Kind() == reflect.Map && n.Kind == yaml.MappingNode:
+		for i := 1; i < len(n.Content); i += 2 {
+			if err := expand(n.Content[i], t.Elem()); err != nil {
+				return false, err
+			}
+		}
+	}
+	return expanded, nil
+}
+
+// unknownFields returns the errors for the keys of the mappings in n,
+// which is decoded into a value of type t, that aren't fields of the struct
+// they're decoded into. It's the equivalent of the KnownFields option
+// of yaml.Decoder for decoding nodes.
+func unknownFields(n *yaml.Node, t reflect.Type) (errs []string) {
+	if n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t.Kind() == reflect.Struct && n.Kind == yaml.MappingNode:
+	KEYS:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, value := n.Content[i], n.Content[i+1]
+			if key.Tag == "!!merge" {
+				if value.Kind == yaml.SequenceNode {
+					for _, v := range value.Content {
+						errs = append(errs, unknownFields(v, t)...)
+					}
+				} else {
+					errs = append(errs, unknownFields(value, t)...)
+				}
+				continue
+			}
+			for j := range t.NumField() {
+				f := t.Field(j)
+				name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+				if f.IsExported() && name == key.Value {
+					errs = append(errs, unknownFields(value, f.Type)...)
+					continue KEYS
+				}
+			}
+			errs = append(errs, fmt.Sprintf(
+				"line %d: field %s not found in type %s", key.Line, key.Value, t,
+			))
+		}
+	case t.Kind() == reflect.Slice && n.Kind == yaml.SequenceNode:
+		for _, item := range n.Content {
+			errs = append(errs, unknownFields(item, t.Elem())...)
+		}
+	case t.Kind() == reflect.Map && n.Kind == yaml.MappingNode:
+		for i := 1; i < len(n.Content); i += 2 {
+			errs = append(errs, unknownFields(n.Content[i], t.Elem())...)
+		}
+	}
+	return errs
+}
+
+// mappingValue returns the value node of key in mapping node n,
+// or nil if n isn't a mapping or doesn't contain key.
+func mappingValue(n *yaml.Node, key string) *yaml.Node {
+	if n == nil || n.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+	return nil
 }
@@ -1,11 +1,15 @@
 package config_test
 
 import (
+	"fmt"
+	"io"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 	"unicode/utf8"
 
 	"github.com/romshark/yamagiconf"
@@ -38,6 +42,8 @@ func TestHTTPMethod(t *testing.T) {
 	f(http.MethodOptions, require.NoError)
 	f(http.MethodTrace, require.NoError)
 	f("CUSTOM", require.NoError)
+	f("*", require.NoError)
+	f("!OPTIONS", require.NoError)
 
 	f("invalid", require.Error)
 	f("", require.Error)
@@ -46,105 +52,2023 @@ func TestHTTPMethod(t *testing.T) {
 	f("IN-VALID", require.Error)
 	f("IN_VALID", require.Error)
 	f("IN\nVALID", require.Error)
+	f("!", require.Error)
+	f("!*", require.Error)
+	f("!!GET", require.Error)
+	f("**", require.Error)
+}
+
+func TestMatchMethod(t *testing.T) {
+	f := func(methods []config.HTTPMethod, method string, expect bool) {
+		t.Helper()
+		require.Equal(t, expect, config.MatchMethod(methods, method))
+	}
+
+	f(nil, http.MethodGet, true)
+	f([]config.HTTPMethod{config.MethodAny}, http.MethodGet, true)
+	f([]config.HTTPMethod{http.MethodGet}, http.MethodGet, true)
+	f([]config.HTTPMethod{"!OPTIONS"}, http.MethodGet, true)
+	f([]config.HTTPMethod{"*", "!OPTIONS"}, http.MethodPost, true)
+
+	f([]config.HTTPMethod{http.MethodGet}, http.MethodPost, false)
+	f([]config.HTTPMethod{"!OPTIONS"}, http.MethodOptions, false)
+	f([]config.HTTPMethod{"*", "!OPTIONS"}, http.MethodOptions, false)
+	f([]config.HTTPMethod{"GET", "!GET"}, http.MethodGet, false)
+}
+
+func TestLoadFileCaseInsensitiveMethods(t *testing.T) {
+	p := TmpFile(t, `
+case-insensitive-methods: true
+resources:
+  - path: /a
+    methods: [get, Post, "!options"]
+  - path: /b
+    methods:
+      - delete
+`)
+	c, err := config.LoadFile(p)
+	require.NoError(t, err)
+	require.Equal(t, []config.HTTPMethod{"GET", "POST", "!OPTIONS"},
+		c.Resources[0].Methods)
+	require.Equal(t, []config.HTTPMethod{"DELETE"}, c.Resources[1].Methods)
+}
+
+func TestLoadFileErrLowercaseMethods(t *testing.T) {
+	p := TmpFile(t, `
+resources:
+  - path: /a
+    methods: [get]
+`)
+	c, err := config.LoadFile(p)
+	require.ErrorIs(t, err, config.ErrInvalidHTTPMethod)
+	require.Nil(t, c)
+}
+
+func TestLoadFileInclude(t *testing.T) {
+	dir := TmpFiles(t, map[string]string{
+		"root.yaml": `
+include: [services/*.yaml, shared.yaml]
+resources:
+  - name: root
+    path: /root
+`,
+		"services/a.yaml": `
+case-insensitive-methods: true
+resources:
+  - name: a
+    path: /a
+    methods: [get]
+`,
+		"services/b.yaml": `
+include: [../shared.yaml] # Included only once.
+resources:
+  - name: b
+    path: /b
+`,
+		"shared.yaml": `
+resources:
+  - name: shared
+    path: /shared
+`,
+	})
+	c, err := config.LoadFile(filepath.Join(dir, "root.yaml"))
+	require.NoError(t, err)
+	names := make([]string, len(c.Resources))
+	for i, r := range c.Resources {
+		names[i] = r.Name
+	}
+	require.Equal(t, []string{"root", "a", "b", "shared"}, names)
+	require.Equal(t, []config.HTTPMethod{"GET"}, c.Resources[1].Methods)
+	require.Nil(t, c.Include)
+}
+
+func TestLoadFileIncludeErr(t *testing.T) {
+	f := func(files map[string]string, check func(t *testing.T, err error)) {
+		t.Helper()
+		dir := TmpFiles(t, files)
+		c, err := config.LoadFile(filepath.Join(dir, "root.yaml"))
+		require.Nil(t, c)
+		check(t, err)
+	}
+	validationErr := func(path string, expect error) func(*testing.T, error) {
+		return func(t *testing.T, err error) {
+			t.Helper()
+			require.ErrorIs(t, err, expect)
+			var v *config.ErrValidation
+			require.ErrorAs(t, err, &v)
+			require.Equal(t, path, v.Path)
+		}
+	}
+
+	f(map[string]string{
+		"root.yaml": "include: [root.yaml]",
+	}, validationErr("include[0]", config.ErrIncludeCycle))
+	f(map[string]string{
+		"root.yaml": "include: [a.yaml]",
+		"a.yaml":    "include: [b.yaml]",
+		"b.yaml":    "include: [a.yaml]",
+	}, validationErr("include[0]", config.ErrIncludeCycle))
+	f(map[string]string{
+		"root.yaml": "include: [missing/*.yaml]",
+	}, validationErr("include[0]", config.ErrIncludeNoMatch))
+	f(map[string]string{
+		"root.yaml": `
+include: [a.yaml]
+resources:
+  - name: x
+    path: /root
+`,
+		"a.yaml": `
+resources:
+  - name: x
+    path: /a
+`,
+	}, validationErr("resources[1].name", config.ErrDuplicateResourceName))
+	f(map[string]string{
+		"root.yaml": "include: [a.yaml]",
+		"a.yaml":    "unknown: field",
+	}, func(t *testing.T, err error) {
+		var d *config.ErrDecode
+		require.ErrorAs(t, err, &d)
+		require.Equal(t, "a.yaml", filepath.Base(d.File))
+	})
+	f(map[string]string{
+		"root.yaml":  "include: [dir.yaml]",
+		"dir.yaml/x": "", // A directory can't be read.
+	}, func(t *testing.T, err error) {
+		var o *config.ErrOpen
+		require.ErrorAs(t, err, &o)
+		require.Equal(t, "dir.yaml", filepath.Base(o.File))
+		require.Contains(t, err.Error(), "opening file: "+o.File+": ")
+	})
+}
+
+func TestMerge(t *testing.T) {
+	resource := func(name, path string) config.Resource {
+		return config.Resource{Name: name, Path: NewGlobExpression(t, path)}
+	}
+	paths := func(c config.Config) []string {
+		p := make([]string, len(c.Resources))
+		for i, r := range c.Resources {
+			p[i] = r.Path.String()
+		}
+		return p
+	}
+
+	base := config.Config{Resources: []config.Resource{
+		resource("a", "/a"), resource("b", "/b"), resource("", "/*"),
+	}}
+	m, err := config.Merge(base,
+		config.Config{Resources: []config.Resource{
+			resource("b", "/b/overlay"), resource("c", "/c"),
+		}},
+		config.Config{
+			CaseInsensitiveMethods: true,
+			Resources: []config.Resource{
+				resource("", "/d"), resource("c", "/c/overlay"),
+			},
+		},
+	)
+	require.NoError(t, err)
+	require.True(t, m.CaseInsensitiveMethods)
+	require.Equal(t, []string{"/d", "/c/overlay", "/a", "/b/overlay", "/*"}, paths(m))
+
+	// The base config isn't modified.
+	require.Equal(t, []string{"/a", "/b", "/*"}, paths(base))
+
+	m, err = config.Merge(base)
+	require.NoError(t, err)
+	require.Equal(t, paths(base), paths(m))
+
+	_, err = config.Merge(base, config.Config{Resources: []config.Resource{
+		{Path: NewGlobExpression(t, "/x"), Effect: &config.Effect{}},
+	}})
+	require.ErrorIs(t, err, config.ErrNoEffect)
+}
+
+func TestMergeFields(t *testing.T) {
+	f := func(base, overlay config.Config, check func(t *testing.T, m config.Config)) {
+		t.Helper()
+		m, err := config.Merge(base, overlay)
+		require.NoError(t, err)
+		check(t, m)
+	}
+	enabled, disabled := true, false
+	replace := &config.Effect{Replace: &config.Replace{StatusCode: 500}}
+	hang := &config.Effect{Hang: &config.Hang{}}
+	half, quarter := config.Probability(0.5), config.Probability(0.25)
+	latency := &config.Connections{Latency: &config.DurRange{Min: time.Second, Max: time.Second}}
+
+	// Version.
+	f(config.Config{Version: 1}, config.Config{}, func(t *testing.T, m config.Config) {
+		require.Equal(t, uint32(1), m.Version)
+	})
+	f(config.Config{}, config.Config{Version: 1}, func(t *testing.T, m config.Config) {
+		require.Equal(t, uint32(1), m.Version)
+	})
+
+	// Enabled.
+	f(config.Config{Enabled: &disabled}, config.Config{}, func(t *testing.T, m config.Config) {
+		require.False(t, m.IsEnabled())
+	})
+	f(config.Config{Enabled: &disabled}, config.Config{Enabled: &enabled},
+		func(t *testing.T, m config.Config) { require.True(t, m.IsEnabled()) })
+	f(config.Config{}, config.Config{Enabled: &disabled}, func(t *testing.T, m config.Config) {
+		require.False(t, m.IsEnabled())
+	})
+
+	// DryRun.
+	f(config.Config{DryRun: true}, config.Config{}, func(t *testing.T, m config.Config) {
+		require.True(t, m.DryRun)
+	})
+	f(config.Config{}, config.Config{DryRun: true}, func(t *testing.T, m config.Config) {
+		require.True(t, m.DryRun)
+	})
+
+	// DebugHeaders.
+	f(config.Config{DebugHeaders: true}, config.Config{}, func(t *testing.T, m config.Config) {
+		require.True(t, m.DebugHeaders)
+	})
+	f(config.Config{}, config.Config{DebugHeaders: true}, func(t *testing.T, m config.Config) {
+		require.True(t, m.DebugHeaders)
+	})
+
+	// Defaults.
+	d1 := &config.Defaults{Probability: &half}
+	d2 := &config.Defaults{Probability: &quarter}
+	f(config.Config{Defaults: d1}, config.Config{}, func(t *testing.T, m config.Config) {
+		require.Same(t, d1, m.Defaults)
+	})
+	f(config.Config{Defaults: d1}, config.Config{Defaults: d2}, func(t *testing.T, m config.Config) {
+		require.Same(t, d2, m.Defaults)
+	})
+
+	// GlobalEffect.
+	f(config.Config{GlobalEffect: replace}, config.Config{}, func(t *testing.T, m config.Config) {
+		require.Same(t, replace, m.GlobalEffect)
+	})
+	f(config.Config{GlobalEffect: replace}, config.Config{GlobalEffect: hang},
+		func(t *testing.T, m config.Config) { require.Same(t, hang, m.GlobalEffect) })
+
+	// Connections.
+	f(config.Config{Connections: latency}, config.Config{}, func(t *testing.T, m config.Config) {
+		require.Same(t, latency, m.Connections)
+	})
+	other := &config.Connections{Reset: &config.ConnCutoff{}}
+	f(config.Config{Connections: latency}, config.Config{Connections: other},
+		func(t *testing.T, m config.Config) { require.Same(t, other, m.Connections) })
+}
+
+func TestWithDefaults(t *testing.T) {
+	c, err := config.Load(strings.NewReader(`
+defaults:
+  probability: 0.5
+  delay:
+    min: 100ms
+    max: 200ms
+  replace-headers:
+    Content-Type: application/json
+    X-Simulated: "true"
+resources:
+  - path: /inherit
+    effect:
+      replace:
+        status-code: 500
+  - path: /override
+    effect:
+      probability: 1
+      delay-headers:
+        min: 1s
+        max: 1s
+      replace:
+        status-code: 500
+        headers:
+          content-type: text/plain
+  - path: /chain
+    effects:
+      - replace-weighted:
+          - weight: 1
+            replace:
+              status-code: 502
+  - path: /sequence
+    sequence:
+      steps:
+        - effect:
+            hang: {}
+`))
+	require.NoError(t, err)
+	before, err := config.Dump(*c)
+	require.NoError(t, err)
+
+	d := c.WithDefaults()
+
+	after, err := config.Dump(*c)
+	require.NoError(t, err)
+	require.Equal(t, string(before), string(after), "must not modify c")
+
+	half, one := config.Probability(0.5), config.Probability(1)
+	delay := &config.DurRange{Min: 100 * time.Millisecond, Max: 200 * time.Millisecond}
+	headers := map[config.HeaderName]string{
+		"Content-Type": "application/json", "X-Simulated": "true",
+	}
+
+	e := d.Resources[0].Effect
+	require.Equal(t, &half, e.Probability)
+	require.Equal(t, delay, e.Delay)
+	require.Equal(t, headers, e.Replace.Headers)
+
+	e = d.Resources[1].Effect
+	require.Equal(t, &one, e.Probability)
+	require.Nil(t, e.Delay)
+	require.Equal(t, map[config.HeaderName]string{
+		"content-type": "text/plain", "X-Simulated": "true",
+	}, e.Replace.Headers)
+
+	e = &d.Resources[2].Effects[0]
+	require.Equal(t, &half, e.Probability)
+	require.Equal(t, delay, e.Delay)
+	require.Equal(t, headers, e.ReplaceWeighted[0].Replace.Headers)
+
+	e = d.Resources[3].Sequence.Steps[0].Effect
+	require.Equal(t, &half, e.Probability)
+	require.Equal(t, delay, e.Delay)
+
+	// Without defaults c is returned as is.
+	c.Defaults = nil
+	require.Equal(t, *c, c.WithDefaults())
+}
+
+func TestLoadFileIncludeDefaults(t *testing.T) {
+	dir := TmpFiles(t, map[string]string{
+		"root.yaml": `
+include: [service.yaml]
+defaults:
+  probability: 0.5
+  delay: {min: 1s, max: 1s}
+`,
+		"service.yaml": `
+defaults:
+  delay: {min: 2s, max: 2s}
+resources:
+  - path: /service
+    effect:
+      hang: {}
+`,
+	})
+	c, err := config.LoadFile(filepath.Join(dir, "root.yaml"))
+	require.NoError(t, err)
+	e := c.WithDefaults().Resources[0].Effect
+	half := config.Probability(0.5)
+	require.Equal(t, &half, e.Probability)
+	require.Equal(t, &config.DurRange{Min: 2 * time.Second, Max: 2 * time.Second}, e.Delay)
+}
+
+func TestLoadURL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/valid.yaml":
+				_, _ = io.WriteString(w, "resources:\n  - path: /a\n")
+			case "/invalid.yaml":
+				_, _ = io.WriteString(w, "resources:\n  - effect: {}\n")
+			default:
+				http.NotFound(w, r)
+			}
+		},
+	))
+	defer server.Close()
+
+	c, err := config.LoadURL(server.URL + "/valid.yaml")
+	require.NoError(t, err)
+	require.Equal(t, "/a", c.Resources[0].Path.String())
+
+	_, err = config.LoadURL(server.URL + "/invalid.yaml")
+	require.ErrorIs(t, err, config.ErrNoEffect)
+
+	_, err = config.LoadURL(server.URL + "/missing.yaml")
+	var errFetch *config.ErrFetch
+	require.ErrorAs(t, err, &errFetch)
+	require.Equal(t, http.StatusNotFound, errFetch.StatusCode)
+	require.Equal(t, server.URL+"/missing.yaml", errFetch.URL)
+
+	_, err = config.LoadURL("http://127.0.0.1:1/unreachable.yaml")
+	require.ErrorAs(t, err, &errFetch)
+	require.Zero(t, errFetch.StatusCode)
+	require.Error(t, errFetch.Err)
+
+	_, err = config.LoadURL(server.URL+"/valid.yaml",
+		config.WithLimits(config.Limits{MaxSourceBytes: 8}))
+	var errDecode *config.ErrDecode
+	require.ErrorAs(t, err, &errDecode)
+	require.ErrorIs(t, err, config.ErrSourceTooLarge)
+}
+
+func TestParseDurRange(t *testing.T) {
+	f := func(input string, expect config.DurRange) {
+		t.Helper()
+		r, err := config.ParseDurRange(input)
+		require.NoError(t, err)
+		require.Equal(t, expect, r)
+	}
+	f("500ms", config.DurRange{Min: 500 * time.Millisecond, Max: 500 * time.Millisecond})
+	f("200ms-2s", config.DurRange{Min: 200 * time.Millisecond, Max: 2 * time.Second})
+	f(" 1m30s - 2m ", config.DurRange{Min: 90 * time.Second, Max: 2 * time.Minute})
+	f("0-1s", config.DurRange{Max: time.Second})
+
+	fErr := func(input string) {
+		t.Helper()
+		_, err := config.ParseDurRange(input)
+		require.ErrorIs(t, err, config.ErrInvalidDurRange)
+	}
+	fErr("")
+	fErr("500")
+	fErr("1s-")
+	fErr("-1s")
+	fErr("1s-2s-3s")
+	fErr("fast")
+}
+
+func TestLoadDurRangeShorthand(t *testing.T) {
+	c, err := config.Load(strings.NewReader(`
+defaults:
+  delay: 10ms-20ms
+global-effect:
+  delay: 5ms
+resources:
+  - effect:
+      delay: 200ms-2s
+      delay-body: {min: 1s, max: 2s}
+  - effects:
+      - hang:
+          max: 1s
+        delay-headers: 100ms
+    sequence:
+      steps:
+        - effect:
+            delay-after-headers: 1s-3s
+  - effect:
+      websocket:
+        close-after: 30s-1m
+`))
+	require.NoError(t, err)
+	ms := time.Millisecond
+	require.Equal(t, &config.DurRange{Min: 10 * ms, Max: 20 * ms}, c.Defaults.Delay)
+	require.Equal(t, &config.DurRange{Min: 5 * ms, Max: 5 * ms}, c.GlobalEffect.Delay)
+	e := c.Resources[0].Effect
+	require.Equal(t, &config.DurRange{Min: 200 * ms, Max: 2 * time.Second}, e.Delay)
+	require.Equal(t, &config.DurRange{Min: time.Second, Max: 2 * time.Second}, e.DelayBody)
+	require.Equal(t, &config.DurRange{Min: 100 * ms, Max: 100 * ms},
+		c.Resources[1].Effects[0].DelayHeaders)
+	require.Equal(t, &config.DurRange{Min: time.Second, Max: 3 * time.Second},
+		c.Resources[1].Sequence.Steps[0].Effect.DelayAfterHeaders)
+	require.Equal(t, &config.DurRange{Min: 30 * time.Second, Max: time.Minute},
+		c.Resources[2].Effect.WebSocket.CloseAfter)
+
+	_, err = config.Load(strings.NewReader(`
+resources:
+  - effect:
+      delay: fast
+`))
+	var errDecode *config.ErrDecode
+	require.ErrorAs(t, err, &errDecode)
+	require.ErrorIs(t, err, config.ErrInvalidDurRange)
+	require.Contains(t, err.Error(), "line 4")
+
+	// Shorthand ranges are validated like regular ones.
+	_, err = config.Load(strings.NewReader(`
+resources:
+  - effect:
+      delay: 2s-1s
+`))
+	var errValidation *config.ErrValidation
+	require.ErrorAs(t, err, &errValidation)
+	require.Equal(t, "resources[0].effect.delay", errValidation.Path)
+	require.Equal(t, 4, errValidation.Line)
+
+	// Decoding errors are located in the original source
+	// even though shorthand ranges are rewritten.
+	_, err = config.Load(strings.NewReader(`
+# Comments and blank lines don't survive rewriting.
+resources:
+
+  - effect:
+      delay: 1s-2s # Rewritten.
+
+      unknown: field
+  - effect:
+      delay: 1s
+      replace:
+        status-code: invalid
+`))
+	require.ErrorAs(t, err, &errDecode)
+	require.ErrorContains(t, err, "line 8: field unknown not found in type config.Effect")
+	require.ErrorContains(t, err, "line 12: ")
+}
+
+func TestParseProbability(t *testing.T) {
+	f := func(input string, expect config.Probability) {
+		t.Helper()
+		p, err := config.ParseProbability(input)
+		require.NoError(t, err)
+		require.InDelta(t, float64(expect), float64(p), 1e-12)
+	}
+	f("0", 0)
+	f("1", 1)
+	f("0.05", 0.05)
+	f("5%", 0.05)
+	f(" 12.5 % ", 0.125)
+	f("100%", 1)
+	f("1/20", 0.05)
+	f("3 / 4", 0.75)
+
+	fErr := func(input string) {
+		t.Helper()
+		_, err := config.ParseProbability(input)
+		require.ErrorIs(t, err, config.ErrInvalidProbability)
+	}
+	fErr("")
+	fErr("five")
+	fErr("%")
+	fErr("101%")
+	fErr("-1%")
+	fErr("1.5")
+	fErr("1/0")
+	fErr("2/1")
+	fErr("1/x")
+}
+
+func TestLoadProbabilitySyntax(t *testing.T) {
+	c, err := config.Load(strings.NewReader(`
+defaults:
+  probability: 1/2
+resources:
+  - effect:
+      probability: 5%
+      drop:
+        rate: 0.25
+    slo:
+      error-budget: 1%
+  - effect:
+      probability: 1
+      hang: {}
+`))
+	require.NoError(t, err)
+	require.InDelta(t, 0.5, float64(*c.Defaults.Probability), 1e-12)
+	require.InDelta(t, 0.05, float64(*c.Resources[0].Effect.Probability), 1e-12)
+	require.InDelta(t, 0.25, float64(c.Resources[0].Effect.Drop.Rate), 1e-12)
+	require.InDelta(t, 0.01, float64(c.Resources[0].SLO.ErrorBudget), 1e-12)
+	require.Equal(t, config.Probability(1), *c.Resources[1].Effect.Probability)
+
+	_, err = config.Load(strings.NewReader(`
+resources:
+  - effect:
+      probability: 150%
+      hang: {}
+`))
+	require.ErrorIs(t, err, config.ErrInvalidProbability)
+}
+
+func TestLoadVersion(t *testing.T) {
+	f := func(src string, expectVersion uint32) {
+		t.Helper()
+		c, err := config.Load(strings.NewReader(src))
+		require.NoError(t, err)
+		require.Equal(t, expectVersion, c.Version)
+	}
+	f("resources: []", 0)
+	f("version: 0", 0)
+	f("version: 1", 1)
+	f(fmt.Sprintf("version: %d", config.CurrentVersion), config.CurrentVersion)
+
+	_, err := config.Load(strings.NewReader(`
+resources: []
+version: 999
+`))
+	require.ErrorIs(t, err, config.ErrUnsupportedVersion)
+	var errValidation *config.ErrValidation
+	require.ErrorAs(t, err, &errValidation)
+	require.Equal(t, "version", errValidation.Path)
+	require.Equal(t, 3, errValidation.Line)
+	require.Equal(t, 10, errValidation.Column)
+
+	dir := TmpFiles(t, map[string]string{
+		"root.yaml":   "include: [future.yaml]",
+		"future.yaml": "version: 999",
+	})
+	_, err = config.LoadFile(filepath.Join(dir, "root.yaml"))
+	require.ErrorIs(t, err, config.ErrUnsupportedVersion)
+	require.ErrorAs(t, err, &errValidation)
+	require.Equal(t, "future.yaml", filepath.Base(errValidation.File))
+
+	err = config.Validate(config.Config{Version: config.CurrentVersion + 1})
+	require.ErrorIs(t, err, config.ErrUnsupportedVersion)
+}
+
+func TestLoadEnabled(t *testing.T) {
+	f := func(src string, expect bool) {
+		t.Helper()
+		c, err := config.Load(strings.NewReader(src))
+		require.NoError(t, err)
+		require.Equal(t, expect, c.IsEnabled())
+	}
+	f("resources: []", true)
+	f("enabled: true", true)
+	f("enabled: false", false)
+}
+
+func TestLimits(t *testing.T) {
+	limits := config.Limits{
+		MaxBodyBytes: 4, MaxResources: 2, MaxGlobComplexity: 3,
+	}
+
+	f := func(src string, expectErr error, expectPath string) {
+		t.Helper()
+		_, err := config.Load(strings.NewReader(src), config.WithLimits(limits))
+		if expectErr == nil {
+			require.NoError(t, err)
+			return
+		}
+		require.ErrorIs(t, err, expectErr)
+		var e *config.ErrValidation
+		require.ErrorAs(t, err, &e)
+		require.Equal(t, expectPath, e.Path)
+	}
+
+	const replace = `
+resources:
+  - effect:
+      replace:
+        status-code: 200
+`
+	f(replace+"        body: abcd\n", nil, "")
+	f(replace+"        body: abcde\n", config.ErrBodyTooLarge,
+		"resources[0].effect.replace.body")
+	f(replace+"        body-base64: YWJjZA==\n", nil, "")
+	f(replace+"        body-base64: YWJjZGU=\n", config.ErrBodyTooLarge,
+		"resources[0].effect.replace.body-base64")
+	f(`
+global-effect:
+  replace-weighted:
+    - weight: 1
+      replace:
+        status-code: 200
+        body: abcde
+`, config.ErrBodyTooLarge, "global-effect.replace-weighted[0].replace.body")
+
+	const effect = "    effect: {delay: {min: 1s, max: 1s}}\n"
+	f("resources:\n  - path: /a\n"+effect+"  - path: /b\n"+effect, nil, "")
+	f("resources:\n  - path: /a\n"+effect+"  - path: /b\n"+effect+
+		"  - path: /c\n"+effect, config.ErrTooManyResources, "resources")
+
+	f("resources:\n  - path: /*/?/[ab]\n"+effect, nil, "")
+	f("resources:\n  - path: /**/{a,b}\n"+effect, nil, "")
+	f("resources:\n  - path: /*/?/[ab]/*\n"+effect,
+		config.ErrGlobTooComplex, "resources[0].path")
+	f("resources:\n  - path: /{a,b,c,d}\n"+effect,
+		config.ErrGlobTooComplex, "resources[0].path")
+	f("resources:\n  - path: /\\*\\?\\[ab]\\{a,b}\n"+effect, nil, "")
+	f("resources:\n  - headers: {'*-*-*-*': [x]}\n"+effect,
+		config.ErrGlobTooComplex, "resources[0].headers[*-*-*-*]")
+
+	limits = config.Limits{}
+	f(replace+"        body: abcde\n", nil, "")
+
+	// Sources are checked before decoding.
+	src := "resources:\n  - path: /a\n" + effect
+	limits = config.Limits{MaxSourceBytes: int64(len(src))}
+	f(src, nil, "")
+	limits.MaxSourceBytes--
+	_, err := config.Load(strings.NewReader(src), config.WithLimits(limits))
+	require.ErrorIs(t, err, config.ErrSourceTooLarge)
+}
+
+func TestLimitsInclude(t *testing.T) {
+	dir := TmpFiles(t, map[string]string{
+		"main.yaml": "include: [inc.yaml]\n",
+		"inc.yaml": `
+resources:
+  - effect:
+      replace:
+        status-code: 200
+        body: abcde
+`,
+	})
+	file := filepath.Join(dir, "main.yaml")
+	_, err := config.LoadFile(file)
+	require.NoError(t, err)
+
+	_, err = config.LoadFile(file, config.WithLimits(config.Limits{MaxBodyBytes: 4}))
+	require.ErrorIs(t, err, config.ErrBodyTooLarge)
+	var e *config.ErrValidation
+	require.ErrorAs(t, err, &e)
+	require.Equal(t, filepath.Join(dir, "inc.yaml"), e.File)
+}
+
+func TestValidateWithLimits(t *testing.T) {
+	effect := &config.Effect{Delay: &config.DurRange{Min: time.Second, Max: time.Second}}
+	c := config.Config{Resources: []config.Resource{{Effect: effect}, {Effect: effect}}}
+	require.NoError(t, config.Validate(c))
+	require.NoError(t, config.ValidateWithLimits(c, config.Limits{MaxResources: 2}))
+	err := config.ValidateWithLimits(c, config.Limits{MaxResources: 1})
+	require.ErrorIs(t, err, config.ErrTooManyResources)
 }
 
 func TestHTTPStatusCode(t *testing.T) {
 	f := func(input int, fn require.ErrorAssertionFunc) {
 		t.Helper()
-		err := config.StatusCode(input).Validate()
-		fn(t, err)
+		err := config.StatusCode(input).Validate()
+		fn(t, err)
+	}
+
+	f(http.StatusContinue, require.NoError)
+	f(http.StatusSwitchingProtocols, require.NoError)
+	f(http.StatusProcessing, require.NoError)
+	f(http.StatusEarlyHints, require.NoError)
+	f(http.StatusOK, require.NoError)
+	f(http.StatusCreated, require.NoError)
+	f(http.StatusAccepted, require.NoError)
+	f(http.StatusNonAuthoritativeInfo, require.NoError)
+	f(http.StatusNoContent, require.NoError)
+	f(http.StatusResetContent, require.NoError)
+	f(http.StatusPartialContent, require.NoError)
+	f(http.StatusMultiStatus, require.NoError)
+	f(http.StatusAlreadyReported, require.NoError)
+	f(http.StatusIMUsed, require.NoError)
+	f(http.StatusMultipleChoices, require.NoError)
+	f(http.StatusMovedPermanently, require.NoError)
+	f(http.StatusFound, require.NoError)
+	f(http.StatusSeeOther, require.NoError)
+	f(http.StatusNotModified, require.NoError)
+	f(http.StatusUseProxy, require.NoError)
+	f(http.StatusTemporaryRedirect, require.NoError)
+	f(http.StatusPermanentRedirect, require.NoError)
+	f(http.StatusBadRequest, require.NoError)
+	f(http.StatusUnauthorized, require.NoError)
+	f(http.StatusPaymentRequired, require.NoError)
+	f(http.StatusForbidden, require.NoError)
+	f(http.StatusNotFound, require.NoError)
+	f(http.StatusMethodNotAllowed, require.NoError)
+	f(http.StatusNotAcceptable, require.NoError)
+	f(http.StatusProxyAuthRequired, require.NoError)
+	f(http.StatusRequestTimeout, require.NoError)
+	f(http.StatusConflict, require.NoError)
+	f(http.StatusGone, require.NoError)
+	f(http.StatusLengthRequired, require.NoError)
+	f(http.StatusPreconditionFailed, require.NoError)
+	f(http.StatusRequestEntityTooLarge, require.NoError)
+	f(http.StatusRequestURITooLong, require.NoError)
+	f(http.StatusUnsupportedMediaType, require.NoError)
+	f(http.StatusRequestedRangeNotSatisfiable, require.NoError)
+	f(http.StatusExpectationFailed, require.NoError)
+	f(http.StatusTeapot, require.NoError)
+	f(http.StatusMisdirectedRequest, require.NoError)
+	f(http.StatusUnprocessableEntity, require.NoError)
+	f(http.StatusLocked, require.NoError)
+	f(http.StatusFailedDependency, require.NoError)
+	f(http.StatusTooEarly, require.NoError)
+	f(http.StatusUpgradeRequired, require.NoError)
+	f(http.StatusPreconditionRequired, require.NoError)
+	f(http.StatusTooManyRequests, require.NoError)
+	f(http.StatusRequestHeaderFieldsTooLarge, require.NoError)
+	f(http.StatusUnavailableForLegalReasons, require.NoError)
+	f(http.StatusInternalServerError, require.NoError)
+	f(http.StatusNotImplemented, require.NoError)
+	f(http.StatusBadGateway, require.NoError)
+	f(http.StatusServiceUnavailable, require.NoError)
+	f(http.StatusGatewayTimeout, require.NoError)
+	f(http.StatusHTTPVersionNotSupported, require.NoError)
+	f(http.StatusVariantAlsoNegotiates, require.NoError)
+	f(http.StatusInsufficientStorage, require.NoError)
+	f(http.StatusLoopDetected, require.NoError)
+	f(http.StatusNotExtended, require.NoError)
+	f(http.StatusNetworkAuthenticationRequired, require.NoError)
+
+	f(306, require.Error) // RFC 9110, 15.4.7 (Unused)
+	f(-400, require.Error)
+	f(0, require.Error)
+	f(1000, require.Error)
+}
+
+func TestReplaceAllowNonstandard(t *testing.T) {
+	f := func(code config.StatusCode, allow bool, fn require.ErrorAssertionFunc) {
+		t.Helper()
+		err := config.Validate(config.Config{
+			Resources: []config.Resource{{Effect: &config.Effect{
+				Replace: &config.Replace{StatusCode: code, AllowNonstandard: allow},
+			}}},
+		})
+		fn(t, err)
+	}
+
+	f(http.StatusOK, false, require.NoError)
+	f(http.StatusOK, true, require.NoError)
+	f(420, true, require.NoError)
+	f(499, true, require.NoError)
+	f(599, true, require.NoError)
+	f(100, true, require.NoError)
+	f(999, true, require.NoError)
+
+	f(420, false, require.Error)
+	f(499, false, require.Error)
+	f(99, true, require.Error)
+	f(1000, true, require.Error)
+	f(-400, true, require.Error)
+}
+
+func TestHeaderName(t *testing.T) {
+	f := func(input string, fn require.ErrorAssertionFunc) {
+		t.Helper()
+		err := config.HeaderName(input).Validate()
+		fn(t, err)
+	}
+
+	f("Content-Type", require.NoError)
+	f("X-Custom", require.NoError)
+
+	f("", require.Error)
+	f(" ", require.Error)
+}
+
+func TestDurRange(t *testing.T) {
+	require.NoError(t, config.DurRange{Min: 0, Max: 0}.Validate())
+	require.NoError(t, config.DurRange{Min: 1, Max: 1}.Validate())
+	require.NoError(t, config.DurRange{Min: 10, Max: 11}.Validate())
+	require.NoError(t, config.DurRange{Min: -10, Max: 1}.Validate())
+
+	require.Error(t, config.DurRange{Min: 1, Max: 0}.Validate())
+}
+
+func TestDurRangeDistribution(t *testing.T) {
+	f := func(r config.DurRange, expect error) {
+		t.Helper()
+		err := r.Validate()
+		if expect == nil {
+			require.NoError(t, err)
+			return
+		}
+		require.ErrorIs(t, err, expect)
+	}
+
+	f(config.DurRange{Distribution: config.DistributionUniform}, nil)
+	f(config.DurRange{
+		Distribution: config.DistributionNormal, Mean: 1, StdDev: 1,
+	}, nil)
+	f(config.DurRange{ // Max 0 is unbounded.
+		Distribution: config.DistributionNormal, Min: 10, Mean: 1, StdDev: 1,
+	}, nil)
+	f(config.DurRange{Distribution: config.DistributionExponential, Mean: 1}, nil)
+	f(config.DurRange{
+		Distribution: config.DistributionLogNormal, Median: 1, Sigma: 1,
+	}, nil)
+	f(config.DurRange{Distribution: config.DistributionPareto, Min: 1, Shape: 1}, nil)
+
+	f(config.DurRange{Distribution: "unknown"}, config.ErrInvalidDistribution)
+	f(config.DurRange{
+		Distribution: config.DistributionNormal, Min: 10, Max: 5,
+	}, config.ErrMinGreaterMax)
+	f(config.DurRange{
+		Distribution: config.DistributionNormal, StdDev: -1,
+	}, config.ErrInvalidDistributionParam)
+	f(config.DurRange{
+		Distribution: config.DistributionExponential,
+	}, config.ErrInvalidDistributionParam)
+	f(config.DurRange{
+		Distribution: config.DistributionLogNormal, Sigma: 1,
+	}, config.ErrInvalidDistributionParam)
+	f(config.DurRange{
+		Distribution: config.DistributionLogNormal, Median: 1, Sigma: -1,
+	}, config.ErrInvalidDistributionParam)
+	f(config.DurRange{
+		Distribution: config.DistributionPareto, Shape: 1,
+	}, config.ErrInvalidDistributionParam)
+	f(config.DurRange{
+		Distribution: config.DistributionPareto, Min: 1,
+	}, config.ErrInvalidDistributionParam)
+}
+
+func TestPercentile(t *testing.T) {
+	f := func(input string, expect config.Percentile, fn require.ErrorAssertionFunc) {
+		t.Helper()
+		var p config.Percentile
+		err := p.UnmarshalText([]byte(input))
+		fn(t, err)
+		if err == nil {
+			require.Equal(t, expect, p)
+			require.Equal(t, input, p.String())
+		}
+	}
+
+	f("p50", 50, require.NoError)
+	f("p99.9", 99.9, require.NoError)
+	f("p0.1", 0.1, require.NoError)
+
+	f("", 0, require.Error)
+	f("50", 0, require.Error)
+	f("p", 0, require.Error)
+	f("px", 0, require.Error)
+	f("p0", 0, require.Error)
+	f("p100", 0, require.Error)
+	f("p-1", 0, require.Error)
+}
+
+func TestDurRangePercentiles(t *testing.T) {
+	f := func(r config.DurRange, expect error) {
+		t.Helper()
+		r.Distribution = config.DistributionPercentiles
+		err := r.Validate()
+		if expect == nil {
+			require.NoError(t, err)
+			return
+		}
+		require.ErrorIs(t, err, expect)
+	}
+
+	f(config.DurRange{Percentiles: map[config.Percentile]time.Duration{
+		50: 1, 99: 2,
+	}}, nil)
+	f(config.DurRange{Max: 2, Percentiles: map[config.Percentile]time.Duration{
+		50: 1, 99: 2,
+	}}, nil)
+
+	f(config.DurRange{}, config.ErrInvalidDistributionParam)
+	f(config.DurRange{Percentiles: map[config.Percentile]time.Duration{
+		50: 2, 99: 1,
+	}}, config.ErrInvalidDistributionParam)
+	f(config.DurRange{Min: 3, Percentiles: map[config.Percentile]time.Duration{
+		50: 2,
+	}}, config.ErrInvalidDistributionParam)
+	f(config.DurRange{Max: 1, Percentiles: map[config.Percentile]time.Duration{
+		50: 2,
+	}}, config.ErrInvalidDistributionParam)
+	f(config.DurRange{Percentiles: map[config.Percentile]time.Duration{
+		100: 2,
+	}}, config.ErrInvalidPercentile)
+}
+
+func TestLoadFileDistribution(t *testing.T) {
+	p := TmpFile(t, `
+resources:
+  - path: /a
+    effect:
+      delay:
+        distribution: log-normal
+        median: 80ms
+        sigma: 0.6
+        max: 5s
+`)
+	c, err := config.LoadFile(p)
+	require.NoError(t, err)
+	require.Equal(t, &config.DurRange{
+		Distribution: config.DistributionLogNormal,
+		Median:       80 * time.Millisecond,
+		Sigma:        0.6,
+		Max:          5 * time.Second,
+	}, c.Resources[0].Effect.Delay)
+}
+
+func TestLoadFilePercentiles(t *testing.T) {
+	p := TmpFile(t, `
+resources:
+  - path: /a
+    effect:
+      delay:
+        distribution: percentiles
+        percentiles:
+          p50: 20ms
+          p95: 200ms
+          p99.9: 2s
+`)
+	c, err := config.LoadFile(p)
+	require.NoError(t, err)
+	require.Equal(t, map[config.Percentile]time.Duration{
+		50:   20 * time.Millisecond,
+		95:   200 * time.Millisecond,
+		99.9: 2 * time.Second,
+	}, c.Resources[0].Effect.Delay.Percentiles)
+}
+
+func TestUint64Range(t *testing.T) {
+	require.NoError(t, config.Uint64Range{Min: 0, Max: 0}.Validate())
+	require.NoError(t, config.Uint64Range{Min: 10, Max: 0}.Validate())
+	require.NoError(t, config.Uint64Range{Min: 10, Max: 11}.Validate())
+
+	require.Error(t, config.Uint64Range{Min: 2, Max: 1}.Validate())
+
+	require.True(t, config.Uint64Range{Min: 10}.Contains(10))
+	require.True(t, config.Uint64Range{Min: 10}.Contains(1<<40))
+	require.True(t, config.Uint64Range{Min: 1, Max: 2}.Contains(2))
+	require.False(t, config.Uint64Range{Min: 1, Max: 2}.Contains(3))
+	require.False(t, config.Uint64Range{Min: 1, Max: 2}.Contains(0))
+}
+
+func TestProbability(t *testing.T) {
+	require.NoError(t, config.Probability(0).Validate())
+	require.NoError(t, config.Probability(0.5).Validate())
+	require.NoError(t, config.Probability(1).Validate())
+
+	require.ErrorIs(t, config.Probability(-0.1).Validate(), config.ErrInvalidProbability)
+	require.ErrorIs(t, config.Probability(1.1).Validate(), config.ErrInvalidProbability)
+}
+
+func TestSequence(t *testing.T) {
+	require.ErrorIs(t, (&config.Sequence{}).Validate(), config.ErrEmptySequence)
+
+	s := &config.Sequence{Steps: []config.SequenceStep{{Times: 2}, {}}}
+	require.NoError(t, s.Validate())
+	require.Equal(t, uint64(3), s.Len())
+	for n, expect := range []*config.SequenceStep{
+		&s.Steps[0], &s.Steps[0], &s.Steps[1], nil,
+	} {
+		step, ok := s.Step(uint64(n))
+		require.Equal(t, expect != nil, ok)
+		require.Equal(t, expect, step)
+	}
+
+	s.Loop = true
+	step, ok := s.Step(3)
+	require.True(t, ok)
+	require.Equal(t, &s.Steps[0], step)
+
+	// Step effects are validated.
+	err := config.Validate(config.Config{Resources: []config.Resource{{
+		Sequence: &config.Sequence{Steps: []config.SequenceStep{
+			{Effect: &config.Effect{}},
+		}},
+	}}})
+	require.ErrorIs(t, err, config.ErrNoEffect)
+}
+
+func TestThroughput(t *testing.T) {
+	require.NoError(t, (&config.Throughput{BytesPerSecond: 1}).Validate())
+	require.NoError(t, (&config.Throughput{BytesPerSecond: 1, Burst: 10}).Validate())
+
+	require.ErrorIs(t, (&config.Throughput{}).Validate(), config.ErrZeroThroughput)
+	require.ErrorIs(t, (&config.Throughput{Burst: 10}).Validate(),
+		config.ErrZeroThroughput)
+}
+
+func TestDrop(t *testing.T) {
+	f := func(d config.Drop, expect error) {
+		t.Helper()
+		err := config.Validate(config.Config{
+			Resources: []config.Resource{{Effect: &config.Effect{Drop: &d}}},
+		})
+		if expect == nil {
+			require.NoError(t, err)
+			return
+		}
+		require.ErrorIs(t, err, expect)
+	}
+
+	f(config.Drop{Rate: 0.5}, nil)
+	f(config.Drop{Rate: 1, Mode: config.DropModeClose}, nil)
+	f(config.Drop{Rate: 1, Mode: config.DropModeHang}, nil)
+
+	f(config.Drop{Rate: 2}, config.ErrInvalidProbability)
+	f(config.Drop{Rate: 1, Mode: "reset"}, config.ErrInvalidDropMode)
+}
+
+func TestCDN(t *testing.T) {
+	f := func(c config.CDN, expect error) {
+		t.Helper()
+		err := config.Validate(config.Config{
+			Resources: []config.Resource{{Effect: &config.Effect{CDN: &c}}},
+		})
+		if expect == nil {
+			require.NoError(t, err)
+			return
+		}
+		require.ErrorIs(t, err, expect)
+	}
+	header := config.HeaderName("CF-Cache-Status")
+	f(config.CDN{TTL: time.Minute}, nil)
+	f(config.CDN{TTL: time.Minute, Stale: 0.1, Header: &header}, nil)
+	f(config.CDN{TTL: time.Minute, MaxStale: time.Hour, MaxEntries: 1, MaxBytes: 1}, nil)
+
+	f(config.CDN{}, config.ErrZeroDuration)
+	f(config.CDN{TTL: time.Minute, Stale: 2}, config.ErrInvalidProbability)
+	f(config.CDN{TTL: time.Minute, MaxStale: -1}, config.ErrNegativeDuration)
+	invalid := config.HeaderName("X Cache")
+	f(config.CDN{TTL: time.Minute, Header: &invalid}, config.ErrInvalidHeaderName)
+}
+
+func TestRetryStorm(t *testing.T) {
+	f := func(r config.RetryStorm, expect error) {
+		t.Helper()
+		err := config.Validate(config.Config{
+			Resources: []config.Resource{{Effect: &config.Effect{RetryStorm: &r}}},
+		})
+		if expect == nil {
+			require.NoError(t, err)
+			return
+		}
+		require.ErrorIs(t, err, expect)
+	}
+	header := config.HeaderName("X-Request-ID")
+	f(config.RetryStorm{Window: time.Second}, nil)
+	f(config.RetryStorm{
+		Window: time.Second, Threshold: 3, KeyHeader: &header,
+		RetryAfter: time.Second, MaxRetryAfter: time.Minute, DropAfter: 10,
+	}, nil)
+
+	f(config.RetryStorm{}, config.ErrZeroDuration)
+	f(config.RetryStorm{Window: time.Second, RetryAfter: -time.Second},
+		config.ErrNegativeDuration)
+	f(config.RetryStorm{Window: time.Second, MaxRetryAfter: -time.Second},
+		config.ErrNegativeDuration)
+	invalid := config.HeaderName("")
+	f(config.RetryStorm{Window: time.Second, KeyHeader: &invalid},
+		config.ErrInvalidHeaderName)
+}
+
+func TestKeepAlive(t *testing.T) {
+	f := func(k config.KeepAlive, expect error) {
+		t.Helper()
+		err := config.Validate(config.Config{
+			Resources: []config.Resource{{Effect: &config.Effect{KeepAlive: &k}}},
+		})
+		if expect == nil {
+			require.NoError(t, err)
+			return
+		}
+		require.ErrorIs(t, err, expect)
+	}
+
+	f(config.KeepAlive{Rate: 0.1}, nil)
+	f(config.KeepAlive{Rate: 1, Mode: config.KeepAliveModeHeader}, nil)
+	f(config.KeepAlive{Rate: 1, Mode: config.KeepAliveModeClose}, nil)
+
+	f(config.KeepAlive{Rate: -1}, config.ErrInvalidProbability)
+	f(config.KeepAlive{Rate: 1, Mode: "reset"}, config.ErrInvalidKeepAliveMode)
+}
+
+func TestPartialContent(t *testing.T) {
+	body := "0123456789"
+	f := func(e config.Effect, expect error) {
+		t.Helper()
+		err := config.Validate(config.Config{
+			Resources: []config.Resource{{Effect: &e}},
+		})
+		if expect == nil {
+			require.NoError(t, err)
+			return
+		}
+		require.ErrorIs(t, err, expect)
+	}
+	f(config.Effect{
+		Replace:        &config.Replace{StatusCode: 200, Body: &body},
+		PartialContent: &config.PartialContent{},
+	}, nil)
+	f(config.Effect{
+		Replace: &config.Replace{StatusCode: 200, Body: &body},
+		PartialContent: &config.PartialContent{
+			Mode: config.PartialContentUnsatisfiable,
+		},
+	}, nil)
+
+	f(config.Effect{
+		Replace:        &config.Replace{StatusCode: 200, Body: &body},
+		PartialContent: &config.PartialContent{Mode: "partial"},
+	}, config.ErrInvalidPartialContentMode)
+	f(config.Effect{
+		Replace:        &config.Replace{StatusCode: 200},
+		PartialContent: &config.PartialContent{},
+	}, config.ErrPartialContentWithoutBody)
+	f(config.Effect{
+		PartialContent: &config.PartialContent{},
+	}, config.ErrPartialContentWithoutBody)
+}
+
+func TestCORS(t *testing.T) {
+	require.NoError(t, (&config.CORS{MaxAge: time.Hour}).Validate())
+	require.ErrorIs(t, (&config.CORS{MaxAge: -1}).Validate(),
+		config.ErrNegativeDuration)
+	err := config.Validate(config.Config{
+		Resources: []config.Resource{{Effect: &config.Effect{CORS: &config.CORS{
+			AllowHeaders: []config.HeaderName{"X Token"},
+		}}}},
+	})
+	require.ErrorIs(t, err, config.ErrInvalidHeaderName)
+}
+
+func TestCacheHeaders(t *testing.T) {
+	f := func(c config.CacheHeaders, expect string) {
+		t.Helper()
+		require.NoError(t, c.Validate())
+		require.Equal(t, expect, c.CacheControl())
+	}
+	f(config.CacheHeaders{}, "")
+	f(config.CacheHeaders{MaxAge: time.Minute}, "max-age=60")
+	f(config.CacheHeaders{Profile: config.CacheProfileNoStore}, "no-store")
+	f(config.CacheHeaders{Profile: config.CacheProfileNoCache}, "no-cache")
+	f(config.CacheHeaders{Profile: config.CacheProfilePrivate}, "private, max-age=0")
+	f(config.CacheHeaders{
+		Profile: config.CacheProfilePublic, MaxAge: time.Hour,
+	}, "public, max-age=3600")
+	f(config.CacheHeaders{
+		Profile: config.CacheProfileImmutable,
+	}, "public, max-age=31536000, immutable")
+	f(config.CacheHeaders{
+		Profile: config.CacheProfileImmutable, MaxAge: time.Hour,
+	}, "public, max-age=3600, immutable")
+
+	require.ErrorIs(t, (&config.CacheHeaders{MaxAge: -1}).Validate(),
+		config.ErrNegativeDuration)
+	require.ErrorIs(t, (&config.CacheHeaders{LastModified: -1}).Validate(),
+		config.ErrNegativeDuration)
+	require.ErrorIs(t, config.CacheProfile("forever").Validate(),
+		config.ErrInvalidCacheProfile)
+}
+
+func TestConnections(t *testing.T) {
+	f := func(c config.Connections, expect error) {
+		t.Helper()
+		err := config.Validate(config.Config{Connections: &c})
+		if expect == nil {
+			require.NoError(t, err)
+			return
+		}
+		require.ErrorIs(t, err, expect)
+	}
+	f(config.Connections{Reset: &config.ConnCutoff{}}, nil)
+	f(config.Connections{HalfClose: &config.ConnCutoff{AfterBytes: 1024}}, nil)
+	f(config.Connections{Stall: &config.ConnStall{
+		Duration: config.DurRange{Min: time.Second, Max: 2 * time.Second},
+	}}, nil)
+	f(config.Connections{Throughput: &config.Throughput{BytesPerSecond: 1024}}, nil)
+	f(config.Connections{ReadThroughput: &config.Throughput{BytesPerSecond: 1024}}, nil)
+	f(config.Connections{Latency: &config.DurRange{Max: time.Millisecond}}, nil)
+	f(config.Connections{Stalls: &config.Stalls{
+		Probability: 0.01, Duration: config.DurRange{Min: time.Second, Max: time.Second},
+	}}, nil)
+
+	f(config.Connections{}, config.ErrNoConnectionFault)
+	f(config.Connections{Throughput: &config.Throughput{}}, config.ErrZeroThroughput)
+	f(config.Connections{ReadThroughput: &config.Throughput{}}, config.ErrZeroThroughput)
+}
+
+func TestDNS(t *testing.T) {
+	f := func(d config.DNS, expect error) {
+		t.Helper()
+		err := config.Validate(config.Config{
+			Resources: []config.Resource{{Effect: &config.Effect{DNS: &d}}},
+		})
+		if expect == nil {
+			require.NoError(t, err)
+			return
+		}
+		require.ErrorIs(t, err, expect)
+	}
+	f(config.DNS{Delay: &config.DurRange{Min: time.Second, Max: time.Second}}, nil)
+	f(config.DNS{Failure: config.DNSFailureNXDomain}, nil)
+	f(config.DNS{Failure: config.DNSFailureTimeout}, nil)
+	f(config.DNS{Failure: config.DNSFailureServFail}, nil)
+
+	f(config.DNS{Failover: &config.DNSFailover{
+		Every: time.Minute, Stale: 10 * time.Second,
+	}}, nil)
+
+	f(config.DNS{}, config.ErrNoDNSFault)
+	f(config.DNS{Failure: "refused"}, config.ErrInvalidDNSFailure)
+	f(config.DNS{Failover: &config.DNSFailover{Stale: time.Second}},
+		config.ErrZeroDuration)
+	f(config.DNS{Failover: &config.DNSFailover{Every: time.Second}},
+		config.ErrZeroDuration)
+	f(config.DNS{Failover: &config.DNSFailover{
+		Every: time.Second, Stale: time.Minute,
+	}}, config.ErrWindowOverlaps)
+	invalid := config.Probability(2)
+	f(config.DNS{Failover: &config.DNSFailover{
+		Every: time.Minute, Stale: time.Second, Rate: &invalid,
+	}}, config.ErrInvalidProbability)
+}
+
+func TestDNSFailoverWindow(t *testing.T) {
+	f := &config.DNSFailover{Every: time.Minute, Stale: 10 * time.Second}
+	require.Zero(t, f.Failover(0))
+	require.Zero(t, f.Failover(5*time.Second))
+	require.Equal(t, int64(1), f.Failover(time.Minute))
+	require.Equal(t, int64(1), f.Failover(time.Minute+9*time.Second))
+	require.Zero(t, f.Failover(time.Minute+10*time.Second))
+	require.Equal(t, int64(3), f.Failover(3*time.Minute))
+}
+
+func TestDial(t *testing.T) {
+	f := func(d config.Dial, expect error) {
+		t.Helper()
+		err := config.Validate(config.Config{
+			Resources: []config.Resource{{Effect: &config.Effect{Dial: &d}}},
+		})
+		if expect == nil {
+			require.NoError(t, err)
+			return
+		}
+		require.ErrorIs(t, err, expect)
+	}
+	f(config.Dial{Delay: &config.DurRange{Min: time.Second, Max: time.Second}}, nil)
+	f(config.Dial{Failure: config.DialFailureRefused}, nil)
+	f(config.Dial{Failure: config.DialFailureTimeout}, nil)
+	f(config.Dial{Failure: config.DialFailureUnreachable}, nil)
+
+	f(config.Dial{}, config.ErrNoDialFault)
+	f(config.Dial{Failure: "reset"}, config.ErrInvalidDialFailure)
+}
+
+func TestTLS(t *testing.T) {
+	f := func(c config.TLS, expect error) {
+		t.Helper()
+		err := config.Validate(config.Config{
+			Resources: []config.Resource{{Effect: &config.Effect{TLS: &c}}},
+		})
+		if expect == nil {
+			require.NoError(t, err)
+			return
+		}
+		require.ErrorIs(t, err, expect)
+	}
+	f(config.TLS{Delay: &config.DurRange{Min: time.Second, Max: time.Second}}, nil)
+	for _, failure := range []config.TLSFailure{
+		config.TLSFailureUnknownAuthority,
+		config.TLSFailureExpired,
+		config.TLSFailureHostnameMismatch,
+		config.TLSFailureProtocolVersion,
+		config.TLSFailureHandshake,
+		config.TLSFailureTimeout,
+	} {
+		f(config.TLS{Failure: failure}, nil)
+	}
+
+	f(config.TLS{}, config.ErrNoTLSFault)
+	f(config.TLS{Failure: "revoked"}, config.ErrInvalidTLSFailure)
+}
+
+func TestNotModified(t *testing.T) {
+	f := func(n config.NotModified, expect error) {
+		t.Helper()
+		if expect == nil {
+			require.NoError(t, n.Validate())
+			return
+		}
+		require.ErrorIs(t, n.Validate(), expect)
+	}
+	f(config.NotModified{Rate: 1}, nil)
+	f(config.NotModified{Rate: 1, ETag: `"v1"`}, nil)
+	f(config.NotModified{Rate: 1, ETag: `W/"v1"`}, nil)
+	f(config.NotModified{Rate: 1, ETag: `""`}, nil)
+
+	f(config.NotModified{ETag: `v1`}, config.ErrInvalidETag)
+	f(config.NotModified{ETag: `"v1`}, config.ErrInvalidETag)
+	f(config.NotModified{ETag: `w/"v1"`}, config.ErrInvalidETag)
+	f(config.NotModified{ETag: `"a b"`}, config.ErrInvalidETag)
+	f(config.NotModified{ETag: `"a"b"`}, config.ErrInvalidETag)
+}
+
+func TestMalformedKind(t *testing.T) {
+	for _, k := range []config.MalformedKind{
+		config.MalformedContentLength,
+		config.MalformedTruncatedChunked,
+		config.MalformedStatusLine,
+		config.MalformedDuplicateHeaders,
+	} {
+		require.NoError(t, k.Validate())
+	}
+	require.ErrorIs(t, config.MalformedKind("").Validate(),
+		config.ErrInvalidMalformedKind)
+	require.ErrorIs(t, config.MalformedKind("broken").Validate(),
+		config.ErrInvalidMalformedKind)
+}
+
+func TestGraphQLOperationType(t *testing.T) {
+	for _, k := range []config.GraphQLOperationType{
+		"",
+		config.GraphQLQuery,
+		config.GraphQLMutation,
+		config.GraphQLSubscription,
+	} {
+		require.NoError(t, k.Validate())
+	}
+	require.ErrorIs(t, config.GraphQLOperationType("Query").Validate(),
+		config.ErrInvalidGraphQLOperationType)
+}
+
+func TestBursts(t *testing.T) {
+	f := func(b config.Bursts, expect error) {
+		t.Helper()
+		if expect == nil {
+			require.NoError(t, b.Validate())
+			return
+		}
+		require.ErrorIs(t, b.Validate(), expect)
+	}
+
+	f(config.Bursts{Duration: time.Second}, nil)
+	f(config.Bursts{Duration: time.Second, Every: time.Second}, nil)
+	f(config.Bursts{Start: time.Minute, Duration: time.Second, Every: time.Hour}, nil)
+
+	f(config.Bursts{}, config.ErrZeroDuration)
+	f(config.Bursts{Duration: -1}, config.ErrZeroDuration)
+	f(config.Bursts{Start: -1, Duration: time.Second}, config.ErrNegativeDuration)
+	f(config.Bursts{Duration: time.Second, Every: -1}, config.ErrNegativeDuration)
+	f(config.Bursts{Duration: time.Minute, Every: time.Second},
+		config.ErrWindowOverlaps)
+}
+
+func TestMaxConcurrent(t *testing.T) {
+	require.NoError(t, (&config.MaxConcurrent{Limit: 1}).Validate())
+	require.NoError(t, (&config.MaxConcurrent{
+		Limit: 1, QueueTimeout: time.Second,
+	}).Validate())
+
+	require.ErrorIs(t, (&config.MaxConcurrent{}).Validate(), config.ErrZeroLimit)
+	require.ErrorIs(t, (&config.MaxConcurrent{
+		Limit: 1, QueueTimeout: -1,
+	}).Validate(), config.ErrNegativeDuration)
+}
+
+func TestHang(t *testing.T) {
+	require.NoError(t, (&config.Hang{}).Validate())
+	require.NoError(t, (&config.Hang{Max: time.Second}).Validate())
+	require.ErrorIs(t, (&config.Hang{Max: -1}).Validate(), config.ErrNegativeDuration)
+}
+
+func TestAbortAndReplace(t *testing.T) {
+	u, err := config.NewURL("https://staging.host.io")
+	require.NoError(t, err)
+	f := func(e config.Effect, expectFields string) {
+		t.Helper()
+		err := config.Validate(config.Config{
+			Resources: []config.Resource{{Effect: &e}},
+		})
+		if expectFields == "" {
+			require.NoError(t, err)
+			return
+		}
+		require.ErrorIs(t, err, config.ErrAbortAndReplace)
+		require.ErrorContains(t, err, expectFields+" are mutually exclusive")
+	}
+	replace := &config.Replace{StatusCode: http.StatusOK}
+	weighted := []config.WeightedReplace{{Weight: 1, Replace: *replace}}
+	redirect := &config.Redirect{StatusCode: http.StatusFound, Location: "/"}
+	proxy := &config.Proxy{URL: u}
+	hang := &config.Hang{}
+	drop := &config.Drop{Rate: 1}
+	malformed := &config.Malformed{Kind: config.MalformedContentLength}
+
+	f(config.Effect{Hang: hang, Replace: replace}, "hang and replace")
+	f(config.Effect{Hang: hang, ReplaceWeighted: weighted}, "hang and replace-weighted")
+	f(config.Effect{Hang: hang, Redirect: redirect}, "hang and redirect")
+	f(config.Effect{Hang: hang, Proxy: proxy}, "hang and proxy")
+	f(config.Effect{Drop: drop, Replace: replace}, "drop and replace")
+	f(config.Effect{Drop: drop, ReplaceWeighted: weighted}, "drop and replace-weighted")
+	f(config.Effect{Drop: drop, Redirect: redirect}, "drop and redirect")
+	f(config.Effect{Drop: drop, Proxy: proxy}, "drop and proxy")
+	f(config.Effect{Malformed: malformed, Redirect: redirect}, "malformed and redirect")
+	f(config.Effect{Malformed: malformed, Proxy: proxy}, "malformed and proxy")
+
+	// Requests that aren't dropped are responded to.
+	f(config.Effect{Drop: &config.Drop{Rate: 0.5}, Replace: replace}, "")
+	f(config.Effect{Drop: &config.Drop{Rate: 0.5}, Proxy: proxy}, "")
+	// Malformed responses are made of the replacement.
+	f(config.Effect{Malformed: malformed, Replace: replace}, "")
+	f(config.Effect{Malformed: malformed, ReplaceWeighted: weighted}, "")
+}
+
+func TestReplaceBodyFile(t *testing.T) {
+	body, file := "body", "body.json"
+	require.NoError(t, (&config.Replace{
+		StatusCode: http.StatusOK, BodyFile: &file,
+	}).Validate())
+	require.ErrorIs(t, (&config.Replace{
+		StatusCode: http.StatusOK, Body: &body, BodyFile: &file,
+	}).Validate(), config.ErrMultipleBodies)
+}
+
+func TestLoadFileBodyFileRelative(t *testing.T) {
+	dir := TmpFiles(t, map[string]string{
+		"main.yaml": `
+include: [sub/inc.yaml]
+resources:
+  - path: /a
+    effect:
+      replace:
+        status-code: 200
+        body-file: a.json
+  - path: /abs
+    effect:
+      replace:
+        status-code: 200
+        body-file: /srv/abs.json
+`,
+		"sub/inc.yaml": `
+resources:
+  - path: /b
+    effect:
+      replace:
+        status-code: 200
+        variants:
+          - content-type: application/json
+            body-file: fixtures/b.json
+`,
+	})
+	c, err := config.LoadFile(filepath.Join(dir, "main.yaml"))
+	require.NoError(t, err)
+	require.Len(t, c.Resources, 3)
+	require.Equal(t, filepath.Join(dir, "a.json"),
+		*c.Resources[0].Effect.Replace.BodyFile)
+	require.Equal(t, "/srv/abs.json", *c.Resources[1].Effect.Replace.BodyFile)
+	require.Equal(t, filepath.Join(dir, "sub", "fixtures", "b.json"),
+		*c.Resources[2].Effect.Replace.Variants[0].BodyFile)
+}
+
+func TestLoadFileBodyBase64(t *testing.T) {
+	p := TmpFile(t, `
+resources:
+  - path: /a
+    effect:
+      replace:
+        status-code: 200
+        body-base64: iVBORw0KGgo=
+`)
+	c, err := config.LoadFile(p)
+	require.NoError(t, err)
+	r := c.Resources[0].Effect.Replace
+	require.Equal(t, []byte("\x89PNG\r\n\x1a\n"), r.BodyBase64.Bytes())
+	require.Equal(t, "iVBORw0KGgo=", r.BodyBase64.String())
+	require.True(t, r.HasBody())
+}
+
+func TestLoadFileErrBodyBase64(t *testing.T) {
+	f := func(input string, expect error) {
+		t.Helper()
+		c, err := config.LoadFile(TmpFile(t, input))
+		require.Error(t, err)
+		if expect != nil {
+			require.ErrorIs(t, err, expect)
+		}
+		require.Nil(t, c)
 	}
 
-	f(http.StatusContinue, require.NoError)
-	f(http.StatusSwitchingProtocols, require.NoError)
-	f(http.StatusProcessing, require.NoError)
-	f(http.StatusEarlyHints, require.NoError)
-	f(http.StatusOK, require.NoError)
-	f(http.StatusCreated, require.NoError)
-	f(http.StatusAccepted, require.NoError)
-	f(http.StatusNonAuthoritativeInfo, require.NoError)
-	f(http.StatusNoContent, require.NoError)
-	f(http.StatusResetContent, require.NoError)
-	f(http.StatusPartialContent, require.NoError)
-	f(http.StatusMultiStatus, require.NoError)
-	f(http.StatusAlreadyReported, require.NoError)
-	f(http.StatusIMUsed, require.NoError)
-	f(http.StatusMultipleChoices, require.NoError)
-	f(http.StatusMovedPermanently, require.NoError)
-	f(http.StatusFound, require.NoError)
-	f(http.StatusSeeOther, require.NoError)
-	f(http.StatusNotModified, require.NoError)
-	f(http.StatusUseProxy, require.NoError)
-	f(http.StatusTemporaryRedirect, require.NoError)
-	f(http.StatusPermanentRedirect, require.NoError)
-	f(http.StatusBadRequest, require.NoError)
-	f(http.StatusUnauthorized, require.NoError)
-	f(http.StatusPaymentRequired, require.NoError)
-	f(http.StatusForbidden, require.NoError)
-	f(http.StatusNotFound, require.NoError)
-	f(http.StatusMethodNotAllowed, require.NoError)
-	f(http.StatusNotAcceptable, require.NoError)
-	f(http.StatusProxyAuthRequired, require.NoError)
-	f(http.StatusRequestTimeout, require.NoError)
-	f(http.StatusConflict, require.NoError)
-	f(http.StatusGone, require.NoError)
-	f(http.StatusLengthRequired, require.NoError)
-	f(http.StatusPreconditionFailed, require.NoError)
-	f(http.StatusRequestEntityTooLarge, require.NoError)
-	f(http.StatusRequestURITooLong, require.NoError)
-	f(http.StatusUnsupportedMediaType, require.NoError)
-	f(http.StatusRequestedRangeNotSatisfiable, require.NoError)
-	f(http.StatusExpectationFailed, require.NoError)
-	f(http.StatusTeapot, require.NoError)
-	f(http.StatusMisdirectedRequest, require.NoError)
-	f(http.StatusUnprocessableEntity, require.NoError)
-	f(http.StatusLocked, require.NoError)
-	f(http.StatusFailedDependency, require.NoError)
-	f(http.StatusTooEarly, require.NoError)
-	f(http.StatusUpgradeRequired, require.NoError)
-	f(http.StatusPreconditionRequired, require.NoError)
-	f(http.StatusTooManyRequests, require.NoError)
-	f(http.StatusRequestHeaderFieldsTooLarge, require.NoError)
-	f(http.StatusUnavailableForLegalReasons, require.NoError)
-	f(http.StatusInternalServerError, require.NoError)
-	f(http.StatusNotImplemented, require.NoError)
-	f(http.StatusBadGateway, require.NoError)
-	f(http.StatusServiceUnavailable, require.NoError)
-	f(http.StatusGatewayTimeout, require.NoError)
-	f(http.StatusHTTPVersionNotSupported, require.NoError)
-	f(http.StatusVariantAlsoNegotiates, require.NoError)
-	f(http.StatusInsufficientStorage, require.NoError)
-	f(http.StatusLoopDetected, require.NoError)
-	f(http.StatusNotExtended, require.NoError)
-	f(http.StatusNetworkAuthenticationRequired, require.NoError)
+	f(`
+resources:
+  - path: /a
+    effect:
+      replace:
+        status-code: 200
+        body-base64: not base64!
+`, nil)
+	f(`
+resources:
+  - path: /a
+    effect:
+      replace:
+        status-code: 200
+        body: text
+        body-base64: iVBORw0KGgo=
+`, config.ErrMultipleBodies)
+}
 
-	f(306, require.Error) // RFC 9110, 15.4.7 (Unused)
-	f(-400, require.Error)
-	f(0, require.Error)
-	f(1000, require.Error)
+func TestReplaceWeighted(t *testing.T) {
+	body := "body"
+	f := func(e config.Effect, expect error) {
+		t.Helper()
+		err := config.Validate(config.Config{
+			Resources: []config.Resource{{Effect: &e}},
+		})
+		if expect == nil {
+			require.NoError(t, err)
+			return
+		}
+		require.ErrorIs(t, err, expect)
+	}
+
+	f(config.Effect{ReplaceWeighted: []config.WeightedReplace{
+		{Weight: 1, Replace: config.Replace{StatusCode: 500}},
+		{Weight: 3, Replace: config.Replace{
+			StatusCode: 599, AllowNonstandard: true,
+		}},
+	}}, nil)
+	f(config.Effect{
+		ReplaceWeighted: []config.WeightedReplace{
+			{Weight: 1, Replace: config.Replace{StatusCode: 200, Body: &body}},
+		},
+		Stream: &config.Stream{ChunkSize: 1},
+	}, nil)
+
+	f(config.Effect{ReplaceWeighted: []config.WeightedReplace{
+		{Replace: config.Replace{StatusCode: 500}},
+	}}, config.ErrZeroWeight)
+	f(config.Effect{ReplaceWeighted: []config.WeightedReplace{
+		{Weight: 1, Replace: config.Replace{StatusCode: 599}},
+	}}, config.ErrInvalidStatusCode)
+	f(config.Effect{
+		Replace: &config.Replace{StatusCode: 500},
+		ReplaceWeighted: []config.WeightedReplace{
+			{Weight: 1, Replace: config.Replace{StatusCode: 500}},
+		},
+	}, config.ErrReplaceAndReplaceWeighted)
+	f(config.Effect{
+		ReplaceWeighted: []config.WeightedReplace{
+			{Weight: 1, Replace: config.Replace{StatusCode: 200, Body: &body}},
+			{Weight: 1, Replace: config.Replace{StatusCode: 500}},
+		},
+		Stream: &config.Stream{ChunkSize: 1},
+	}, config.ErrStreamWithoutBody)
 }
 
-func TestHeaderName(t *testing.T) {
-	f := func(input string, fn require.ErrorAssertionFunc) {
+func TestHeaderMutation(t *testing.T) {
+	require.NoError(t, (&config.HeaderMutation{
+		Remove: []config.HeaderName{"ETag"},
+	}).Validate())
+	require.NoError(t, (&config.HeaderMutation{
+		Append: map[config.HeaderName]string{"Vary": "Accept"},
+	}).Validate())
+	require.ErrorIs(t, (&config.HeaderMutation{}).Validate(),
+		config.ErrNoHeaderMutation)
+}
+
+func TestRewrite(t *testing.T) {
+	code := config.StatusCode(http.StatusOK)
+	require.NoError(t, (&config.Rewrite{StatusCode: &code}).Validate())
+	require.ErrorIs(t, (&config.Rewrite{}).Validate(), config.ErrNoRewrite)
+	require.ErrorIs(t, (&config.BodySubstitution{}).Validate(),
+		config.ErrEmptyPattern)
+}
+
+func TestLoadFileRewrite(t *testing.T) {
+	p := TmpFile(t, `
+resources:
+  - path: /a
+    effect:
+      rewrite:
+        status-code: 502
+        headers:
+          remove: [ETag]
+        body:
+          - pattern: '"status":"\w+"'
+            replacement: '"status":"degraded"'
+`)
+	c, err := config.LoadFile(p)
+	require.NoError(t, err)
+	r := c.Resources[0].Effect.Rewrite
+	require.Equal(t, config.StatusCode(502), *r.StatusCode)
+	require.Equal(t, []config.HeaderName{"ETag"}, r.Headers.Remove)
+	require.Len(t, r.Body, 1)
+	require.Equal(t, `"status":"\w+"`, r.Body[0].Pattern.String())
+	require.Equal(t, `"status":"degraded"`, r.Body[0].Replacement)
+
+	_, err = config.LoadFile(TmpFile(t, `
+resources:
+  - path: /a
+    effect:
+      rewrite:
+        status-code: 599
+`))
+	require.ErrorIs(t, err, config.ErrInvalidStatusCode)
+}
+
+func TestCompress(t *testing.T) {
+	body := "body"
+	f := func(r config.Replace, expect error) {
 		t.Helper()
-		err := config.HeaderName(input).Validate()
-		fn(t, err)
+		err := config.Validate(config.Config{
+			Resources: []config.Resource{{Effect: &config.Effect{Replace: &r}}},
+		})
+		if expect == nil {
+			require.NoError(t, err)
+			return
+		}
+		require.ErrorIs(t, err, expect)
 	}
 
-	f("Content-Type", require.NoError)
-	f("X-Custom", require.NoError)
+	f(config.Replace{StatusCode: 200, Body: &body, Compress: &config.Compress{
+		Encoding: config.ContentEncodingGzip,
+	}}, nil)
+	f(config.Replace{StatusCode: 200, Body: &body, Compress: &config.Compress{
+		Encoding: config.ContentEncodingDeflate, RespectAcceptEncoding: true,
+	}}, nil)
 
-	f("", require.Error)
-	f(" ", require.Error)
+	f(config.Replace{StatusCode: 200, Body: &body, Compress: &config.Compress{
+		Encoding: "br",
+	}}, config.ErrInvalidContentEncoding)
+	f(config.Replace{StatusCode: 200, Compress: &config.Compress{
+		Encoding: config.ContentEncodingGzip,
+	}}, config.ErrCompressWithoutBody)
 }
 
-func TestDurRange(t *testing.T) {
-	require.NoError(t, config.DurRange{Min: 0, Max: 0}.Validate())
-	require.NoError(t, config.DurRange{Min: 1, Max: 1}.Validate())
-	require.NoError(t, config.DurRange{Min: 10, Max: 11}.Validate())
-	require.NoError(t, config.DurRange{Min: -10, Max: 1}.Validate())
+func TestVariants(t *testing.T) {
+	body, file := "body", "body.html"
+	f := func(v []config.Variant, expect error) {
+		t.Helper()
+		err := config.Validate(config.Config{
+			Resources: []config.Resource{{Effect: &config.Effect{
+				Replace: &config.Replace{StatusCode: 200, Variants: v},
+			}}},
+		})
+		if expect == nil {
+			require.NoError(t, err)
+			return
+		}
+		require.ErrorIs(t, err, expect)
+	}
 
-	require.Error(t, config.DurRange{Min: 1, Max: 0}.Validate())
+	f([]config.Variant{
+		{ContentType: "application/json", Body: &body},
+		{ContentType: "text/html; charset=utf-8", BodyFile: &file},
+		{ContentType: "text/plain"},
+	}, nil)
+
+	f([]config.Variant{{ContentType: "", Body: &body}}, config.ErrInvalidMediaType)
+	f([]config.Variant{{ContentType: "json", Body: &body}}, config.ErrInvalidMediaType)
+	f([]config.Variant{{ContentType: "text/*", Body: &body}}, config.ErrInvalidMediaType)
+	f([]config.Variant{
+		{ContentType: "application/json", Body: &body, BodyFile: &file},
+	}, config.ErrMultipleBodies)
+	f([]config.Variant{
+		{ContentType: "application/json", Body: &body},
+		{ContentType: "Application/JSON; charset=utf-8", Body: &body},
+	}, config.ErrDuplicateVariant)
+
+	err := config.Validate(config.Config{
+		Resources: []config.Resource{{Effect: &config.Effect{
+			Replace: &config.Replace{StatusCode: 200, Body: &body, Variants: []config.Variant{
+				{ContentType: "application/json", Body: &body},
+			}},
+		}}},
+	})
+	require.ErrorIs(t, err, config.ErrMultipleBodies)
+}
+
+func TestCookie(t *testing.T) {
+	f := func(c config.Cookie, expect error) {
+		t.Helper()
+		err := config.Validate(config.Config{
+			Resources: []config.Resource{{Effect: &config.Effect{
+				Replace: &config.Replace{
+					StatusCode: http.StatusOK, Cookies: []config.Cookie{c},
+				},
+			}}},
+		})
+		if expect == nil {
+			require.NoError(t, err)
+			return
+		}
+		require.ErrorIs(t, err, expect)
+	}
+
+	f(config.Cookie{Name: "session", Value: "${user}"}, nil)
+	f(config.Cookie{
+		Name: "a", Path: "/", Domain: "host.io", SameSite: config.SameSiteNone,
+	}, nil)
+
+	f(config.Cookie{}, config.ErrInvalidCookie)
+	f(config.Cookie{Name: "a b"}, config.ErrInvalidCookie)
+	f(config.Cookie{Name: "a", Value: "a;b"}, config.ErrInvalidCookie)
+	f(config.Cookie{Name: "a", Path: "/;"}, config.ErrInvalidCookie)
+	f(config.Cookie{Name: "a", SameSite: "always"}, config.ErrInvalidSameSite)
+}
+
+func TestRedirect(t *testing.T) {
+	f := func(e config.Effect, expect error) {
+		t.Helper()
+		err := config.Validate(config.Config{
+			Resources: []config.Resource{{Effect: &e}},
+		})
+		if expect == nil {
+			require.NoError(t, err)
+			return
+		}
+		require.ErrorIs(t, err, expect)
+	}
+
+	for _, code := range []config.StatusCode{301, 302, 303, 307, 308} {
+		f(config.Effect{Redirect: &config.Redirect{
+			StatusCode: code, Location: "/",
+		}}, nil)
+	}
+
+	f(config.Effect{Redirect: &config.Redirect{
+		StatusCode: http.StatusOK, Location: "/",
+	}}, config.ErrInvalidRedirectStatus)
+	f(config.Effect{Redirect: &config.Redirect{
+		StatusCode: http.StatusFound,
+	}}, config.ErrEmptyLocation)
+	f(config.Effect{
+		Redirect: &config.Redirect{StatusCode: http.StatusFound, Location: "/"},
+		Replace:  &config.Replace{StatusCode: http.StatusOK},
+	}, config.ErrRedirectAndReplace)
+}
+
+func TestWebSocket(t *testing.T) {
+	reject := func(c config.StatusCode) *config.StatusCode { return &c }
+	require.NoError(t, (&config.WebSocket{Reject: reject(503)}).Validate())
+	require.NoError(t, (&config.WebSocket{
+		CloseAfter: &config.DurRange{Min: time.Second, Max: time.Second},
+	}).Validate())
+
+	require.ErrorIs(t, (&config.WebSocket{}).Validate(),
+		config.ErrNoWebSocketEffect)
+	require.ErrorIs(t, (&config.WebSocket{Reject: reject(101)}).Validate(),
+		config.ErrInvalidRejectStatus)
+}
+
+func TestPad(t *testing.T) {
+	require.NoError(t, (&config.Pad{TargetSize: 1}).Validate())
+	require.NoError(t, (&config.Pad{Append: 1, Filler: "x"}).Validate())
+	require.ErrorIs(t, (&config.Pad{}).Validate(), config.ErrNoPadding)
+	require.ErrorIs(t, (&config.Pad{TargetSize: 1, Append: 1}).Validate(),
+		config.ErrTargetSizeAndAppend)
+
+	require.Equal(t, uint64(8), (&config.Pad{TargetSize: 10}).Size(2))
+	require.Zero(t, (&config.Pad{TargetSize: 10}).Size(20))
+	require.Equal(t, uint64(5), (&config.Pad{Append: 5}).Size(20))
+}
+
+func TestEffects(t *testing.T) {
+	delay := &config.DurRange{Min: time.Second, Max: time.Second}
+	err := config.Validate(config.Config{Resources: []config.Resource{{
+		Effect:  &config.Effect{Delay: delay},
+		Effects: []config.Effect{{Delay: delay}},
+	}}})
+	require.ErrorIs(t, err, config.ErrEffectAndEffects)
+
+	err = config.Validate(config.Config{Resources: []config.Resource{{
+		Effects: []config.Effect{{Delay: delay}, {}},
+	}}})
+	require.ErrorIs(t, err, config.ErrNoEffect)
+	var errValidation *config.ErrValidation
+	require.ErrorAs(t, err, &errValidation)
+	require.Equal(t, "resources[0].effects[1]", errValidation.Path)
+
+	r := config.Resource{Effects: []config.Effect{{Delay: delay}, {Delay: delay}}}
+	require.Equal(t, []*config.Effect{&r.Effects[0], &r.Effects[1]}, r.Chain())
+	r = config.Resource{Effect: &config.Effect{Delay: delay}}
+	require.Equal(t, []*config.Effect{r.Effect}, r.Chain())
+	require.Nil(t, (&config.Resource{}).Chain())
+}
+
+func TestKeyBy(t *testing.T) {
+	header := config.HeaderName("X-API-Key")
+	require.NoError(t, (&config.KeyBy{ClientIP: true}).Validate())
+	require.NoError(t, (&config.KeyBy{Header: &header}).Validate())
+	require.NoError(t, (&config.KeyBy{Cookie: "session"}).Validate())
+
+	require.ErrorIs(t, (&config.KeyBy{}).Validate(), config.ErrInvalidKeyBy)
+	require.ErrorIs(t, (&config.KeyBy{
+		ClientIP: true, Cookie: "session",
+	}).Validate(), config.ErrInvalidKeyBy)
+
+	invalid := config.HeaderName("X API Key")
+	err := config.Validate(config.Config{Resources: []config.Resource{{
+		KeyBy: &config.KeyBy{Header: &invalid},
+	}}})
+	require.ErrorIs(t, err, config.ErrInvalidHeaderName)
+}
+
+func TestURL(t *testing.T) {
+	u, err := config.NewURL("https://staging.host.io:8443/api")
+	require.NoError(t, err)
+	require.Equal(t, "https://staging.host.io:8443/api", u.String())
+	require.Equal(t, "staging.host.io:8443", u.URL().Host)
+
+	for _, s := range []string{
+		"", "/relative", "ftp://host.io", "https://", "http://host.io/%zz",
+	} {
+		_, err := config.NewURL(s)
+		require.ErrorIs(t, err, config.ErrInvalidURL, s)
+	}
+	require.Equal(t, "", config.URL{}.String())
+}
+
+func TestProxy(t *testing.T) {
+	u, err := config.NewURL("https://staging.host.io")
+	require.NoError(t, err)
+	f := func(e config.Effect, expect error) {
+		t.Helper()
+		err := config.Validate(config.Config{
+			Resources: []config.Resource{{Effect: &e}},
+		})
+		if expect == nil {
+			require.NoError(t, err)
+			return
+		}
+		require.ErrorIs(t, err, expect)
+	}
+
+	f(config.Effect{Proxy: &config.Proxy{URL: u}}, nil)
+	f(config.Effect{Proxy: &config.Proxy{}}, config.ErrEmptyURL)
+	f(config.Effect{
+		Proxy:   &config.Proxy{URL: u},
+		Replace: &config.Replace{StatusCode: http.StatusOK},
+	}, config.ErrProxyAndReplace)
+	f(config.Effect{
+		Proxy: &config.Proxy{URL: u},
+		Redirect: &config.Redirect{
+			StatusCode: http.StatusFound, Location: "/",
+		},
+	}, config.ErrProxyAndReplace)
+}
+
+func TestGRPCStatus(t *testing.T) {
+	f := func(e config.Effect, expect error) {
+		t.Helper()
+		err := config.Validate(config.Config{
+			Resources: []config.Resource{{Effect: &e}},
+		})
+		if expect == nil {
+			require.NoError(t, err)
+			return
+		}
+		require.ErrorIs(t, err, expect)
+	}
+
+	f(config.Effect{GRPCStatus: &config.GRPCStatus{}}, nil)
+	f(config.Effect{GRPCStatus: &config.GRPCStatus{
+		Code: config.GRPCCodeUnauthenticated, Message: "denied",
+	}}, nil)
+	f(config.Effect{GRPCStatus: &config.GRPCStatus{Code: 17}},
+		config.ErrInvalidGRPCCode)
+	f(config.Effect{
+		GRPCStatus: &config.GRPCStatus{Code: config.GRPCCodeUnavailable},
+		Replace:    &config.Replace{StatusCode: http.StatusOK},
+	}, config.ErrGRPCStatusAndReplace)
+	f(config.Effect{
+		GRPCStatus: &config.GRPCStatus{Code: config.GRPCCodeUnavailable},
+		Redirect: &config.Redirect{
+			StatusCode: http.StatusFound, Location: "/",
+		},
+	}, config.ErrGRPCStatusAndReplace)
+}
+
+func TestRecordReplay(t *testing.T) {
+	f := func(e config.Effect, expect error) {
+		t.Helper()
+		err := config.Validate(config.Config{
+			Resources: []config.Resource{{Effect: &e}},
+		})
+		if expect == nil {
+			require.NoError(t, err)
+			return
+		}
+		require.ErrorIs(t, err, expect)
+	}
+
+	f(config.Effect{Record: &config.Record{Dir: "recordings"}}, nil)
+	f(config.Effect{Replay: &config.Replay{
+		Dir: "recordings", PassThroughMissing: true,
+	}}, nil)
+
+	f(config.Effect{Record: &config.Record{}}, config.ErrEmptyDir)
+	f(config.Effect{Replay: &config.Replay{}}, config.ErrEmptyDir)
+	f(config.Effect{
+		Record: &config.Record{Dir: "recordings"},
+		Replay: &config.Replay{Dir: "recordings"},
+	}, config.ErrRecordAndReplay)
+}
+
+func TestStream(t *testing.T) {
+	body := "body"
+	f := func(e config.Effect, expect error) {
+		t.Helper()
+		err := config.Validate(config.Config{
+			Resources: []config.Resource{{Effect: &e}},
+		})
+		if expect == nil {
+			require.NoError(t, err)
+			return
+		}
+		require.ErrorIs(t, err, expect)
+	}
+
+	f(config.Effect{
+		Replace: &config.Replace{StatusCode: http.StatusOK, Body: &body},
+		Stream:  &config.Stream{ChunkSize: 1},
+	}, nil)
+
+	f(config.Effect{
+		Replace: &config.Replace{StatusCode: http.StatusOK, Body: &body},
+		Stream:  &config.Stream{},
+	}, config.ErrZeroChunkSize)
+	f(config.Effect{
+		Replace: &config.Replace{StatusCode: http.StatusOK},
+		Stream:  &config.Stream{ChunkSize: 1},
+	}, config.ErrStreamWithoutBody)
+	f(config.Effect{
+		Delay:  &config.DurRange{Min: time.Second, Max: time.Second},
+		Stream: &config.Stream{ChunkSize: 1},
+	}, config.ErrStreamWithoutBody)
+}
+
+func TestSLO(t *testing.T) {
+	require.NoError(t, (&config.SLO{}).Validate())
+	require.NoError(t, (&config.SLO{Latency: time.Second, ErrorBudget: 1}).Validate())
+	require.NoError(t, (&config.SLO{ErrorBudget: 0.01}).Validate())
+
+	require.ErrorIs(t, (&config.SLO{Latency: -1}).Validate(),
+		config.ErrNegativeLatency)
+	require.ErrorIs(t, (&config.SLO{ErrorBudget: -0.1}).Validate(),
+		config.ErrInvalidErrorBudget)
+	require.ErrorIs(t, (&config.SLO{ErrorBudget: 1.1}).Validate(),
+		config.ErrInvalidErrorBudget)
 }
 
 func TestLoadFile(t *testing.T) {
@@ -165,10 +2089,17 @@ resources:
       "Content-Type": ["application/javascript"]
     query:
       "param": ["щы"]
+    header-count:
+      max: 100
+    header-bytes:
+      min: 8192
     effect:
       delay:
         min: 200ms
         max: 10s
+    slo:
+      latency: 12s
+      error-budget: 0.01
 `)
 	c, err := config.LoadFile(p)
 	require.NoError(t, err)
@@ -187,6 +2118,104 @@ resources:
 	c, err := config.LoadFile(p)
 	require.ErrorIs(t, err, config.ErrInvalidStatusCode)
 	require.Nil(t, c)
+
+	var errValidation *config.ErrValidation
+	require.ErrorAs(t, err, &errValidation)
+	require.Equal(t, 0, errValidation.ResourceIndex)
+	require.Equal(t, "resources[0].effect.replace.status-code", errValidation.Path)
+	require.Equal(t, 7, errValidation.Line)
+	require.Equal(t, 22, errValidation.Column)
+	require.Equal(t, "validating: line 7, column 22: "+
+		"at resources[0].effect.replace.status-code: "+
+		"invalid HTTP response status code: -400", err.Error())
+}
+
+func TestLoadErrValidationLocation(t *testing.T) {
+	f := func(src string, expectErr string, expectLine, expectColumn int) {
+		t.Helper()
+		_, err := config.Load(strings.NewReader(src))
+		var errValidation *config.ErrValidation
+		require.ErrorAs(t, err, &errValidation)
+		require.Equal(t, expectErr, err.Error())
+		require.Equal(t, expectLine, errValidation.Line)
+		require.Equal(t, expectColumn, errValidation.Column)
+	}
+
+	f(`
+case-insensitive-methods: true # Normalization mustn't shift locations.
+resources:
+  - name: first
+    path: /a
+    methods: [get]
+    effect:
+      hang: {}
+  - name: orders
+    path: /orders/*
+    methods: [post]
+    effect:
+      replace:
+        status-code: 200
+        headers:
+          "Invalid Name": x
+`, "validating: line 16, column 27: "+
+		`at resources[1].effect.replace.headers[Invalid Name] (resource "orders"): `+
+		`invalid header name`, 16, 27)
+
+	f(`
+resources:
+  - effect:
+      delay:
+        distribution: percentiles
+        percentiles: {p50: 2s, p99.9: 1s}
+`, "validating: line 5, column 9: at resources[0].effect.delay: "+
+		"invalid distribution parameter: "+
+		"p99.9 must not be lower than previous percentiles and min", 5, 9)
+
+	f(`
+resources:
+  - name: a
+  - name: a
+`, `validating: line 4, column 11: at resources[1].name (resource "a"): `+
+		`duplicate resource name: "a"`, 4, 11)
+}
+
+func TestLoadFileIncludeErrValidationLocation(t *testing.T) {
+	dir := TmpFiles(t, map[string]string{
+		"root.yaml": "include: [service.yaml]",
+		"service.yaml": `
+resources:
+  - effect:
+      replace:
+        status-code: 0
+`,
+	})
+	_, err := config.LoadFile(filepath.Join(dir, "root.yaml"))
+	var errValidation *config.ErrValidation
+	require.ErrorAs(t, err, &errValidation)
+	require.Equal(t, "service.yaml", filepath.Base(errValidation.File))
+	require.Equal(t, 5, errValidation.Line)
+	require.Equal(t, 22, errValidation.Column)
+	require.Equal(t, "validating: "+errValidation.File+":5:22: "+
+		"at resources[0].effect.replace.status-code: "+
+		"invalid HTTP response status code: 0", err.Error())
+}
+
+func TestValidateErrValidationHeaderName(t *testing.T) {
+	err := config.Validate(config.Config{
+		Resources: []config.Resource{
+			{},
+			{Effect: &config.Effect{Replace: &config.Replace{
+				StatusCode: http.StatusOK,
+				Headers:    map[config.HeaderName]string{"Invalid Name": "x"},
+			}}},
+		},
+	})
+	require.ErrorIs(t, err, config.ErrInvalidHeaderName)
+	var errValidation *config.ErrValidation
+	require.ErrorAs(t, err, &errValidation)
+	require.Equal(t, 1, errValidation.ResourceIndex)
+	require.Equal(t,
+		"resources[1].effect.replace.headers[Invalid Name]", errValidation.Path)
 }
 
 func TestLoadFileErrValidationNoEffect(t *testing.T) {
@@ -202,6 +2231,28 @@ resources:
 	require.Nil(t, c)
 }
 
+func TestLoadFileDelayHeadersBody(t *testing.T) {
+	p := TmpFile(t, `
+resources:
+  - path: /specific
+    effect:
+      delay-headers:
+        min: 10ms
+        max: 20ms
+      delay-body:
+        min: 1s
+        max: 2s
+`)
+	c, err := config.LoadFile(p)
+	require.NoError(t, err)
+	require.Equal(t, &config.Effect{
+		DelayHeaders: &config.DurRange{
+			Min: 10 * time.Millisecond, Max: 20 * time.Millisecond,
+		},
+		DelayBody: &config.DurRange{Min: time.Second, Max: 2 * time.Second},
+	}, c.Resources[0].Effect)
+}
+
 func TestLoadFileNoEffect(t *testing.T) {
 	p := TmpFile(t, `
 resources:
@@ -226,6 +2277,10 @@ func TestLoadFileErrNotExist(t *testing.T) {
 	require.ErrorIs(t, err, os.ErrNotExist)
 	require.True(t, strings.HasPrefix(err.Error(), "opening file: "))
 	require.Nil(t, c)
+
+	var errOpen *config.ErrOpen
+	require.ErrorAs(t, err, &errOpen)
+	require.Equal(t, p, errOpen.File)
 }
 
 func TestLoadFileErrInvalidYAML(t *testing.T) {
@@ -233,6 +2288,9 @@ func TestLoadFileErrInvalidYAML(t *testing.T) {
 	c, err := config.LoadFile(p)
 	require.True(t, strings.HasPrefix(err.Error(), "decoding YAML: "))
 	require.Nil(t, c)
+
+	var errDecode *config.ErrDecode
+	require.ErrorAs(t, err, &errDecode)
 }
 
 func TestNewGlobExpression(t *testing.T) {
@@ -252,6 +2310,45 @@ func TestNewGlobExpression(t *testing.T) {
 	}
 }
 
+func TestNewRegexp(t *testing.T) {
+	e, err := config.NewRegexp(`^/orders/(?P<id>\d+)$`)
+	require.NoError(t, err)
+	require.Equal(t, `^/orders/(?P<id>\d+)$`, e.String())
+	require.Equal(t, []string{"", "id"}, e.Regexp().SubexpNames())
+
+	_, err = config.NewRegexp(`(`)
+	require.Error(t, err)
+
+	require.Zero(t, config.Regexp{}.String())
+}
+
+func TestLoadFileRegexp(t *testing.T) {
+	p := TmpFile(t, `
+resources:
+  - path-regexp: ^/orders/(?P<id>\d+)$
+    headers-regexp:
+      X-Tenant: ^(?P<tenant>\w+)$
+    effect:
+      replace:
+        status-code: 404
+        body: '{"error":"order ${id} not found"}'
+`)
+	c, err := config.LoadFile(p)
+	require.NoError(t, err)
+	require.Equal(t, `^/orders/(?P<id>\d+)$`, c.Resources[0].PathRegexp.String())
+}
+
+func TestLoadFileErrInvalidRegexp(t *testing.T) {
+	p := TmpFile(t, `
+resources:
+  - path-regexp: "(" 
+`)
+	c, err := config.LoadFile(p)
+	var errDecode *config.ErrDecode
+	require.ErrorAs(t, err, &errDecode)
+	require.Nil(t, c)
+}
+
 func TestGlobMatchUninitialized(t *testing.T) {
 	var uninitialized config.GlobExpression
 	require.True(t, uninitialized.Match("test"))
@@ -265,6 +2362,18 @@ func TmpFile(t *testing.T, contents string) (tmpFilePath string) {
 	return p
 }
 
+// TmpFiles writes files to a temporary directory and returns its path.
+func TmpFiles(t *testing.T, files map[string]string) (dir string) {
+	t.Helper()
+	dir = t.TempDir()
+	for name, contents := range files {
+		p := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o777))
+		require.NoError(t, os.WriteFile(p, []byte(contents), 0o777))
+	}
+	return dir
+}
+
 func NewGlobExpression(t *testing.T, expr string) config.GlobExpression {
 	t.Helper()
 	e, err := config.NewGlobExpression(expr)
@@ -0,0 +1,319 @@
+package config
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode"
+
+	"github.com/gobwas/glob"
+)
+
+var ErrInvalidCurl = errors.New("invalid curl command")
+
+// curlFlags are the options of curl without an argument
+// that don't affect the request matched.
+var curlFlags = map[string]bool{
+	"-s": true, "--silent": true, "-S": true, "--show-error": true,
+	"-L": true, "--location": true, "-k": true, "--insecure": true,
+	"-v": true, "--verbose": true, "-i": true, "--include": true,
+	"-f": true, "--fail": true, "-N": true, "--no-buffer": true,
+	"-g": true, "--globoff": true, "--compressed": true,
+	"--http1.0": true, "--http1.1": true, "--http2": true, "--http3": true,
+	"-0": true, "-#": true, "--progress-bar": true,
+}
+
+// curlIgnored are the options of curl with an argument
+// that don't affect the request matched.
+var curlIgnored = map[string]bool{
+	"-o": true, "--output": true, "-m": true, "--max-time": true,
+	"--connect-timeout": true, "-x": true, "--proxy": true, "--retry": true,
+	"-w": true, "--write-out": true, "-c": true, "--cookie-jar": true,
+	"--cacert": true, "-E": true, "--cert": true, "--key": true,
+	"--resolve": true, "--connect-to": true, "--limit-rate": true,
+}
+
+// curlSkippedHeaders aren't matched since they're managed by the client.
+var curlSkippedHeaders = map[string]bool{
+	"Host":           true,
+	"Content-Length": true,
+	"Connection":     true,
+}
+
+// FromCurl converts the curl command line, such as copied from browser
+// developer tools or a support ticket, into a resource matching its
+// method, path, headers and query literally to which effects can be added.
+// Data options imply POST unless the method is set, and are added
+// to the query with -G. Line continuations and shell quoting are supported.
+// Returns an error wrapping ErrInvalidCurl if command isn't a valid curl
+// command and *ErrValidation if the result is invalid.
+func FromCurl(command string) (*Config, error) {
+	args, err := curlSplit(command)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCurl, err)
+	}
+	if len(args) < 1 || args[0] != "curl" {
+		return nil, fmt.Errorf("%w: must start with curl", ErrInvalidCurl)
+	}
+
+	var (
+		method, rawURL string
+		data           []string
+		get            bool
+		header         = http.Header{}
+		contentType    string
+	)
+	// flag applies name if it's an option without an argument.
+	flag := func(name string) bool {
+		switch name {
+		case "-G", "--get":
+			get = true
+		case "-I", "--head":
+			method = http.MethodHead
+		default:
+			return curlFlags[name]
+		}
+		return true
+	}
+	for i := 1; i < len(args); i++ {
+		name, arg := args[i], ""
+		hasArg := false
+		if short := name; len(short) > 2 && short[0] == '-' && short[1] != '-' {
+			// Short options can be combined, such as -sSL,
+			// or followed by their argument, such as -XPOST.
+			name = ""
+			for j := 1; j < len(short); j++ {
+				if !flag("-" + short[j:j+1]) {
+					name, arg, hasArg = "-"+short[j:j+1], short[j+1:], j+1 < len(short)
+					break
+				}
+			}
+			if name == "" {
+				continue // Only flags.
+			}
+		} else if flag(name) {
+			continue
+		}
+		if !strings.HasPrefix(name, "-") {
+			rawURL = name
+			continue
+		}
+		if !hasArg {
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("%w: option %s: missing argument",
+					ErrInvalidCurl, name)
+			}
+			i++
+			arg = args[i]
+		}
+		switch name {
+		case "-X", "--request":
+			method = strings.ToUpper(arg)
+		case "-H", "--header":
+			key, value, ok := strings.Cut(arg, ":")
+			if !ok {
+				if key, ok = strings.CutSuffix(arg, ";"); !ok {
+					return nil, fmt.Errorf("%w: header %q", ErrInvalidCurl, arg)
+				}
+			} else if value = strings.TrimSpace(value); value == "" {
+				continue // "Name:" removes a header curl would send.
+			}
+			header.Add(strings.TrimSpace(key), value)
+		case "-A", "--user-agent":
+			header.Set("User-Agent", arg)
+		case "-e", "--referer":
+			header.Set("Referer", arg)
+		case "-b", "--cookie":
+			if !strings.Contains(arg, "=") {
+				continue // Reads cookies from a file.
+			}
+			header.Add("Cookie", arg)
+		case "-u", "--user":
+			header.Set("Authorization",
+				"Basic "+base64.StdEncoding.EncodeToString([]byte(arg)))
+		case "-r", "--range":
+			header.Set("Range", "bytes="+arg)
+		case "-d", "--data", "--data-raw", "--data-binary", "--data-ascii",
+			"--data-urlencode":
+			data = append(data, arg)
+			contentType = "application/x-www-form-urlencoded"
+		case "--json":
+			data = append(data, arg)
+			contentType = "application/json"
+			if header.Get("Accept") == "" {
+				header.Set("Accept", "application/json")
+			}
+		case "-F", "--form":
+			data = append(data, arg)
+			contentType = "multipart/form-data*"
+		case "--url":
+			rawURL = arg
+		default:
+			if !curlIgnored[name] {
+				return nil, fmt.Errorf("%w: unsupported option %s", ErrInvalidCurl, name)
+			}
+		}
+	}
+	if rawURL == "" {
+		return nil, fmt.Errorf("%w: missing URL", ErrInvalidCurl)
+	}
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "http://" + rawURL // curl's default.
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCurl, err)
+	}
+	query := u.Query()
+	switch {
+	case get:
+		// Only -G is supported with data options other than -F.
+		for _, d := range data {
+			q, _ := url.ParseQuery(d)
+			for name, values := range q {
+				query[name] = append(query[name], values...)
+			}
+		}
+	case len(data) > 0:
+		if method == "" {
+			method = http.MethodPost
+		}
+		if header.Get("Content-Type") == "" {
+			header.Set("Content-Type", contentType)
+		}
+	}
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	r := Resource{Methods: []HTTPMethod{HTTPMethod(method)}}
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+	if r.Path, err = NewGlobExpression(glob.QuoteMeta(path)); err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		if curlSkippedHeaders[name] {
+			continue
+		}
+		exprs := make([]GlobExpression, len(values))
+		for i, v := range values {
+			expr := glob.QuoteMeta(v)
+			if name == "Content-Type" && v == "multipart/form-data*" {
+				// The multipart boundary is chosen by curl.
+				expr = strings.TrimSuffix(expr, `\*`) + "*"
+			}
+			if exprs[i], err = NewGlobExpression(expr); err != nil {
+				return nil, err
+			}
+		}
+		if err := setGlobMap(&r.Headers, glob.QuoteMeta(name), exprs); err != nil {
+			return nil, err
+		}
+	}
+	for name, values := range query {
+		exprs := make([]GlobExpression, len(values))
+		for i, v := range values {
+			if exprs[i], err = NewGlobExpression(glob.QuoteMeta(v)); err != nil {
+				return nil, err
+			}
+		}
+		if err := setGlobMap(&r.Query, glob.QuoteMeta(name), exprs); err != nil {
+			return nil, err
+		}
+		r.QueryRequired = true
+	}
+	c := &Config{Resources: []Resource{r}}
+	if err := Validate(*c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// curlSplit splits command into arguments like a POSIX shell would,
+// supporting single, double and ANSI-C ($'...') quotes as well as
+// line continuations.
+func curlSplit(command string) (args []string, err error) {
+	var b strings.Builder
+	inArg := false
+	for i := 0; i < len(command); i++ {
+		switch c := command[i]; {
+		case c == '\\':
+			if i+1 >= len(command) {
+				return nil, errors.New("trailing backslash")
+			}
+			i++
+			if command[i] != '\n' && command[i] != '\r' {
+				b.WriteByte(command[i])
+				inArg = true
+			} else if command[i] == '\r' && i+1 < len(command) && command[i+1] == '\n' {
+				i++
+			}
+		case c == '\'':
+			end := strings.IndexByte(command[i+1:], '\'')
+			if end < 0 {
+				return nil, errors.New("unterminated single quote")
+			}
+			b.WriteString(command[i+1 : i+1+end])
+			i += end + 1
+			inArg = true
+		case c == '$' && i+1 < len(command) && command[i+1] == '\'':
+			i += 2
+			for ; i < len(command) && command[i] != '\''; i++ {
+				if command[i] != '\\' || i+1 >= len(command) {
+					b.WriteByte(command[i])
+					continue
+				}
+				i++
+				switch e := command[i]; e {
+				case 'n':
+					b.WriteByte('\n')
+				case 'r':
+					b.WriteByte('\r')
+				case 't':
+					b.WriteByte('\t')
+				default:
+					b.WriteByte(e)
+				}
+			}
+			if i >= len(command) {
+				return nil, errors.New("unterminated ANSI-C quote")
+			}
+			inArg = true
+		case c == '"':
+			i++
+			for ; i < len(command) && command[i] != '"'; i++ {
+				if command[i] == '\\' && i+1 < len(command) &&
+					strings.IndexByte("\"\\$`\n", command[i+1]) >= 0 {
+					i++
+					if command[i] == '\n' {
+						continue
+					}
+				}
+				b.WriteByte(command[i])
+			}
+			if i >= len(command) {
+				return nil, errors.New("unterminated double quote")
+			}
+			inArg = true
+		case unicode.IsSpace(rune(c)):
+			if inArg {
+				args = append(args, b.String())
+				b.Reset()
+				inArg = false
+			}
+		default:
+			b.WriteByte(c)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, b.String())
+	}
+	return args, nil
+}
@@ -0,0 +1,93 @@
+package config_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim/config"
+)
+
+func TestFromCurl(t *testing.T) {
+	c, err := config.FromCurl(`curl 'https://example.com/api/orders?id=42&tag=a&tag=b' \
+  -X PATCH \
+  -H 'Authorization: Bearer abc*' \
+  -H "X-Tenant: \"acme\"" \
+  -H $'X-Note: line\'s' \
+  -H 'Content-Length: 12' \
+  -H 'Accept:' \
+  --data-raw '{"status":"paid"}' \
+  -sSL --compressed -m 5`)
+	require.NoError(t, err)
+	require.Len(t, c.Resources, 1)
+	r := c.Resources[0]
+	require.Equal(t, []config.HTTPMethod{"PATCH"}, r.Methods)
+	require.Equal(t, "/api/orders", r.Path.String())
+
+	headers := map[string][]string{}
+	for name, values := range r.Headers {
+		for _, v := range values {
+			headers[name.String()] = append(headers[name.String()], v.String())
+		}
+	}
+	require.Equal(t, map[string][]string{
+		"Authorization": {`Bearer abc\*`},
+		"X-Tenant":      {`"acme"`},
+		"X-Note":        {`line's`},
+		"Content-Type":  {`application/x-www-form-urlencoded`},
+	}, headers)
+
+	require.True(t, r.QueryRequired)
+	query := map[string][]string{}
+	for name, values := range r.Query {
+		for _, v := range values {
+			query[name.String()] = append(query[name.String()], v.String())
+		}
+	}
+	require.Equal(t, map[string][]string{"id": {"42"}, "tag": {"a", "b"}}, query)
+}
+
+func TestFromCurlMethod(t *testing.T) {
+	f := func(t *testing.T, command, expectMethod, expectPath string) {
+		t.Helper()
+		c, err := config.FromCurl(command)
+		require.NoError(t, err)
+		require.Equal(t, []config.HTTPMethod{config.HTTPMethod(expectMethod)},
+			c.Resources[0].Methods)
+		require.Equal(t, expectPath, c.Resources[0].Path.String())
+	}
+	f(t, `curl example.com`, "GET", "/")
+	f(t, `curl -XDELETE http://example.com/items/1`, "DELETE", "/items/1")
+	f(t, `curl -d a=1 http://example.com/form`, "POST", "/form")
+	f(t, `curl --json '{}' http://example.com/json`, "POST", "/json")
+	f(t, `curl -F file=@a.txt http://example.com/upload`, "POST", "/upload")
+	f(t, `curl -I http://example.com/head`, "HEAD", "/head")
+	f(t, `curl -G -d q=go --url http://example.com/search`, "GET", "/search")
+}
+
+func TestFromCurlGet(t *testing.T) {
+	c, err := config.FromCurl(`curl -G -d 'q=hello world' -d page=2 http://example.com/search`)
+	require.NoError(t, err)
+	r := c.Resources[0]
+	require.Empty(t, r.Headers)
+	query := map[string]string{}
+	for name, values := range r.Query {
+		query[name.String()] = values[0].String()
+	}
+	require.Equal(t, map[string]string{"q": "hello world", "page": "2"}, query)
+}
+
+func TestFromCurlErr(t *testing.T) {
+	f := func(t *testing.T, command string) {
+		t.Helper()
+		_, err := config.FromCurl(command)
+		require.ErrorIs(t, err, config.ErrInvalidCurl)
+	}
+	f(t, ``)
+	f(t, `wget http://example.com`)
+	f(t, `curl`)
+	f(t, `curl 'http://example.com`)
+	f(t, `curl -H`)
+	f(t, `curl -H 'invalid' http://example.com`)
+	f(t, `curl --unknown-option http://example.com`)
+}
@@ -0,0 +1,107 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Dump encodes c to YAML that Load decodes to an equivalent config.
+// Fields with zero values are omitted.
+func Dump(c Config) ([]byte, error) {
+	n, err := encodeNode(reflect.ValueOf(c))
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	e := yaml.NewEncoder(&b)
+	e.SetIndent(2)
+	if err := e.Encode(n); err != nil {
+		return nil, err
+	}
+	if err := e.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+var yamlMarshalerType = reflect.TypeFor[yaml.Marshaler]()
+
+// encodeNode encodes v omitting struct fields with zero values, unlike
+// yaml.Marshal which can only omit them for fields tagged omitempty.
+// Non-nil pointers to zero values are preserved.
+func encodeNode(v reflect.Value) (*yaml.Node, error) {
+	if v.Type().Implements(yamlMarshalerType) {
+		return encodeValue(v.Interface())
+	}
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return encodeValue(nil)
+		}
+		return encodeNode(v.Elem())
+	case reflect.Struct:
+		n := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		t := v.Type()
+		for i := range t.NumField() {
+			f := t.Field(i)
+			if !f.IsExported() || v.Field(i).IsZero() {
+				continue
+			}
+			name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+			value, err := encodeNode(v.Field(i))
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			n.Content = append(n.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: name}, value)
+		}
+		return n, nil
+	case reflect.Slice, reflect.Array:
+		n := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for i := range v.Len() {
+			item, err := encodeNode(v.Index(i))
+			if err != nil {
+				return nil, fmt.Errorf("[%d]: %w", i, err)
+			}
+			n.Content = append(n.Content, item)
+		}
+		return n, nil
+	case reflect.Map:
+		n := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		type entry struct{ key, value *yaml.Node }
+		entries := make([]entry, 0, v.Len())
+		for it := v.MapRange(); it.Next(); {
+			key, err := encodeNode(it.Key())
+			if err != nil {
+				return nil, err
+			}
+			value, err := encodeNode(it.Value())
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key.Value, err)
+			}
+			entries = append(entries, entry{key: key, value: value})
+		}
+		// Sort for deterministic output.
+		slices.SortFunc(entries, func(a, b entry) int {
+			return strings.Compare(a.key.Value, b.key.Value)
+		})
+		for _, e := range entries {
+			n.Content = append(n.Content, e.key, e.value)
+		}
+		return n, nil
+	}
+	return encodeValue(v.Interface())
+}
+
+func encodeValue(v any) (*yaml.Node, error) {
+	var n yaml.Node
+	if err := n.Encode(v); err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
@@ -0,0 +1,134 @@
+package config_test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim/config"
+)
+
+func TestDumpRoundTrip(t *testing.T) {
+	c, err := config.Load(strings.NewReader(`
+case-insensitive-methods: true
+resources:
+  - name: orders
+    path: /orders/*
+    methods: [get, "!OPTIONS"]
+    headers:
+      X-Tenant: [acme, "glob*"]
+    query:
+      page: ["*"]
+    path-regexp: ^/orders/(?P<id>\d+)$
+    effect:
+      probability: 0 # Explicitly never.
+      delay:
+        distribution: percentiles
+        percentiles: {p50: 20ms, p99.9: 2s}
+      replace:
+        status-code: 404
+        body-base64: aGVsbG8=
+        headers:
+          X-Order: ${id}
+  - path: /proxied
+    effect:
+      proxy:
+        url: https://staging.example.com/api
+  - path: /hanging
+    effect:
+      hang: {}
+`))
+	require.NoError(t, err)
+
+	b, err := config.Dump(*c)
+	require.NoError(t, err)
+	require.Equal(t, `case-insensitive-methods: true
+resources:
+  - name: orders
+    methods:
+      - GET
+      - '!OPTIONS'
+    path: /orders/*
+    headers:
+      X-Tenant:
+        - acme
+        - glob*
+    query:
+      page:
+        - '*'
+    path-regexp: ^/orders/(?P<id>\d+)$
+    effect:
+      probability: 0
+      delay:
+        distribution: percentiles
+        percentiles:
+          p50: 20ms
+          p99.9: 2s
+      replace:
+        status-code: 404
+        headers:
+          X-Order: ${id}
+        body-base64: aGVsbG8=
+  - path: /proxied
+    effect:
+      proxy:
+        url: https://staging.example.com/api
+  - path: /hanging
+    effect:
+      hang: {}
+`, string(b))
+
+	c2, err := config.Load(strings.NewReader(string(b)))
+	require.NoError(t, err)
+	b2, err := config.Dump(*c2)
+	require.NoError(t, err)
+	require.Equal(t, string(b), string(b2))
+	require.NotNil(t, c2.Resources[0].Effect.Probability)
+	require.Zero(t, *c2.Resources[0].Effect.Probability)
+}
+
+func TestDumpRoundTripInclude(t *testing.T) {
+	dir := TmpFiles(t, map[string]string{
+		"main.yaml": `
+include: [inc.yaml]
+resources:
+  - name: a
+    path: /a
+`,
+		"inc.yaml": `
+resources:
+  - name: b
+    path: /b
+`,
+	})
+	c, err := config.LoadFile(filepath.Join(dir, "main.yaml"))
+	require.NoError(t, err)
+
+	b, err := config.Dump(*c)
+	require.NoError(t, err)
+	require.NotContains(t, string(b), "include")
+
+	c2, err := config.Load(strings.NewReader(string(b)))
+	require.NoError(t, err)
+	require.Equal(t, c, c2)
+}
+
+func TestDumpBuilder(t *testing.T) {
+	c, err := config.NewBuilder().
+		Resource("slow").Path("/slow/*").Delay(time.Second, 2*time.Second).
+		Build()
+	require.NoError(t, err)
+	b, err := config.Dump(c)
+	require.NoError(t, err)
+	require.Equal(t, `resources:
+  - name: slow
+    path: /slow/*
+    effect:
+      delay:
+        min: 1s
+        max: 2s
+`, string(b))
+}
@@ -0,0 +1,112 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+)
+
+// ErrOpen is returned by LoadFile when the config file can't be opened.
+type ErrOpen struct {
+	File string
+	Err  error
+}
+
+func (e *ErrOpen) Error() string {
+	err := e.Err
+	var errPath *fs.PathError
+	if errors.As(err, &errPath) {
+		err = errPath.Err // Avoid repeating the file name.
+	}
+	return fmt.Sprintf("opening file: %s: %v", e.File, err)
+}
+
+func (e *ErrOpen) Unwrap() error { return e.Err }
+
+// ErrFetch is returned by LoadURL when the config can't be fetched.
+type ErrFetch struct {
+	URL string
+
+	// StatusCode is the unexpected response status code,
+	// or zero if the request failed.
+	StatusCode int
+
+	Err error
+}
+
+func (e *ErrFetch) Error() string {
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("fetching %s: unexpected status code: %d", e.URL, e.StatusCode)
+	}
+	return fmt.Sprintf("fetching %s: %v", e.URL, e.Err)
+}
+
+func (e *ErrFetch) Unwrap() error { return e.Err }
+
+// ErrDecode is returned by Load and LoadFile when the YAML source can't be decoded.
+type ErrDecode struct {
+	// File is the included file that failed to decode,
+	// or empty if the root config failed to decode.
+	File string
+
+	Err error
+}
+
+func (e *ErrDecode) Error() string {
+	if e.File == "" {
+		return "decoding YAML: " + e.Err.Error()
+	}
+	return fmt.Sprintf("decoding YAML: %s: %v", e.File, e.Err)
+}
+
+func (e *ErrDecode) Unwrap() error { return e.Err }
+
+// ErrValidation is returned by Validate, Load and LoadFile when
+// the configuration is invalid.
+type ErrValidation struct {
+	// File is the included file that is invalid,
+	// or empty if the error isn't specific to an included file.
+	File string
+
+	// Line and Column locate the invalid value in the YAML source
+	// if it was loaded by Load or LoadFile, otherwise they're zero.
+	Line, Column int
+
+	// ResourceIndex is the index of the invalid resource in Config.Resources,
+	// or -1 if the error isn't specific to any resource.
+	ResourceIndex int
+
+	// ResourceName is the name of the invalid resource, if any.
+	ResourceName string
+
+	// Path is the YAML path of the invalid value,
+	// such as "resources[2].effect.replace.status-code".
+	Path string
+
+	Err error
+}
+
+func (e *ErrValidation) Error() string {
+	var b strings.Builder
+	b.WriteString("validating: ")
+	switch {
+	case e.File != "" && e.Line > 0:
+		fmt.Fprintf(&b, "%s:%d:%d: ", e.File, e.Line, e.Column)
+	case e.File != "":
+		b.WriteString(e.File + ": ")
+	case e.Line > 0:
+		fmt.Fprintf(&b, "line %d, column %d: ", e.Line, e.Column)
+	}
+	if e.Path != "" {
+		b.WriteString("at " + e.Path)
+		if e.ResourceName != "" {
+			fmt.Fprintf(&b, " (resource %q)", e.ResourceName)
+		}
+		b.WriteString(": ")
+	}
+	b.WriteString(e.Err.Error())
+	return b.String()
+}
+
+func (e *ErrValidation) Unwrap() error { return e.Err }
@@ -0,0 +1,104 @@
+package config
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+	"unicode/utf8"
+)
+
+// Exchange is a recorded request and its response.
+type Exchange struct {
+	Method string
+	URL    *url.URL
+
+	StatusCode int
+	Header     http.Header
+
+	// Body is the decoded response body.
+	Body []byte
+
+	// Wait is the time the server took to respond with the header.
+	Wait time.Duration
+}
+
+// FromExchanges converts exchanges into resources replacing responses with
+// the recorded ones including status, headers, cookies and body, just like
+// FromHAR. The recorded wait time is applied as a delay. Resources match
+// the method, path and query of the recorded requests regardless of host.
+// Requests recorded multiple times respond in the recorded order with
+// the last response repeating.
+// Returns *ErrValidation if the result is invalid.
+func FromExchanges(exchanges []Exchange) (*Config, error) {
+	var rec recorded
+	for _, e := range exchanges {
+		replace := recordedReplace(e.StatusCode, e.Header)
+		switch {
+		case len(e.Body) == 0:
+		case utf8.Valid(e.Body):
+			body := string(e.Body)
+			replace.Body = &body
+		default:
+			b := NewBase64(e.Body)
+			replace.BodyBase64 = &b
+		}
+		effect := &Effect{Replace: replace}
+		if e.Wait > 0 {
+			effect.Delay = &DurRange{Min: e.Wait, Max: e.Wait}
+		}
+		if err := rec.add(e.Method, e.URL, effect); err != nil {
+			return nil, err
+		}
+	}
+	return rec.config()
+}
+
+// recorded builds the resources of recorded responses.
+type recorded struct {
+	c Config
+
+	// byRequest maps request keys to resource indexes.
+	byRequest map[string]int
+}
+
+// add adds the recorded effect of a request to method and u,
+// appending it to the sequence of the resource if the same
+// request was already recorded.
+func (r *recorded) add(method string, u *url.URL, effect *Effect) error {
+	key := method + " " + u.EscapedPath() + "?" + u.Query().Encode()
+	if index, ok := r.byRequest[key]; ok {
+		res := &r.c.Resources[index]
+		if res.Sequence == nil {
+			res.Sequence = &Sequence{Steps: []SequenceStep{{Effect: res.Effect}}}
+		}
+		res.Sequence.Steps = append(res.Sequence.Steps, SequenceStep{Effect: effect})
+		res.Effect = effect // The last response repeats.
+		return nil
+	}
+	res, err := recordedResource(method, u)
+	if err != nil {
+		return err
+	}
+	res.Effect = effect
+	if r.byRequest == nil {
+		r.byRequest = map[string]int{}
+	}
+	r.byRequest[key] = len(r.c.Resources)
+	r.c.Resources = append(r.c.Resources, res)
+	return nil
+}
+
+// config returns the validated config of the recorded resources.
+func (r *recorded) config() (*Config, error) {
+	c := r.c
+	for i := range c.Resources {
+		if s := c.Resources[i].Sequence; s != nil {
+			// The last step is covered by Effect.
+			s.Steps = s.Steps[:len(s.Steps)-1]
+		}
+	}
+	if err := Validate(c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
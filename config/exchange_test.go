@@ -0,0 +1,71 @@
+package config_test
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim/config"
+)
+
+func TestFromExchanges(t *testing.T) {
+	parse := func(s string) *url.URL {
+		u, err := url.Parse(s)
+		require.NoError(t, err)
+		return u
+	}
+	c, err := config.FromExchanges([]config.Exchange{
+		{
+			Method: http.MethodGet, URL: parse("http://localhost:9090/users?page=1"),
+			StatusCode: http.StatusOK,
+			Header: http.Header{
+				"Content-Type":   {"application/json"},
+				"Content-Length": {"10"},
+				"Set-Cookie":     {"session=abc; Path=/"},
+			},
+			Body: []byte(`[{"id":1}]`),
+			Wait: 80 * time.Millisecond,
+		},
+		{
+			Method: http.MethodGet, URL: parse("/logo.png"),
+			StatusCode: http.StatusOK, Body: []byte{0x89, 0x50, 0xff},
+		},
+		{
+			Method: http.MethodGet, URL: parse("/users?page=1"),
+			StatusCode: http.StatusServiceUnavailable,
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, c.Resources, 2)
+
+	users := c.Resources[0]
+	require.Equal(t, []config.HTTPMethod{http.MethodGet}, users.Methods)
+	require.Equal(t, "/users", users.Path.String())
+	require.True(t, users.QueryRequired)
+	require.NotNil(t, users.Sequence)
+	require.Len(t, users.Sequence.Steps, 1)
+	first := users.Sequence.Steps[0].Effect
+	require.Equal(t, config.StatusCode(http.StatusOK), first.Replace.StatusCode)
+	require.Equal(t, `[{"id":1}]`, *first.Replace.Body)
+	require.Equal(t, map[config.HeaderName]string{
+		"Content-Type": "application/json",
+	}, first.Replace.Headers)
+	require.Len(t, first.Replace.Cookies, 1)
+	require.Equal(t, "session", first.Replace.Cookies[0].Name)
+	require.Equal(t, &config.DurRange{
+		Min: 80 * time.Millisecond, Max: 80 * time.Millisecond,
+	}, first.Delay)
+	require.Equal(t,
+		config.StatusCode(http.StatusServiceUnavailable),
+		users.Effect.Replace.StatusCode)
+	require.Nil(t, users.Effect.Replace.Body)
+	require.Nil(t, users.Effect.Delay)
+
+	logo := c.Resources[1]
+	require.Nil(t, logo.Sequence)
+	require.Nil(t, logo.Effect.Replace.Body)
+	require.Equal(t, []byte{0x89, 0x50, 0xff}, logo.Effect.Replace.BodyBase64.Bytes())
+}
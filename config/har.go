@@ -0,0 +1,196 @@
+package config
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gobwas/glob"
+)
+
+// recordedSkippedHeaders aren't replayed since the recorded bodies
+// are decoded and the connection is managed by the server.
+var recordedSkippedHeaders = map[string]bool{
+	"Content-Length":    true,
+	"Content-Encoding":  true,
+	"Transfer-Encoding": true,
+	"Connection":        true,
+	"Keep-Alive":        true,
+	"Set-Cookie":        true, // Replayed as cookies.
+}
+
+// FromHAR converts the entries of the HAR (HTTP Archive) file into resources
+// replacing responses with the recorded ones including status, headers,
+// cookies and body. The recorded server wait time is applied as a delay and
+// the receive time as a body delay. Resources match the method, path and
+// query of the recorded requests regardless of host. Requests recorded
+// multiple times respond in the recorded order with the last response
+// repeating. Entries without a response (status 0) are skipped.
+// Returns *ErrOpen if file can't be read, *ErrDecode if it isn't
+// a valid HAR file and *ErrValidation if the result is invalid.
+func FromHAR(file string) (*Config, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, &ErrOpen{File: file, Err: err}
+	}
+	var har struct {
+		Log struct {
+			Entries []harEntry `json:"entries"`
+		} `json:"log"`
+	}
+	if err := json.Unmarshal(b, &har); err != nil {
+		return nil, &ErrDecode{File: file, Err: err}
+	}
+
+	var rec recorded
+	for i, e := range har.Log.Entries {
+		if e.Response.Status == 0 {
+			continue
+		}
+		u, err := url.Parse(e.Request.URL)
+		if err != nil {
+			return nil, &ErrDecode{
+				File: file, Err: fmt.Errorf("log.entries[%d].request.url: %w", i, err),
+			}
+		}
+		effect, err := e.effect()
+		if err != nil {
+			return nil, &ErrDecode{
+				File: file, Err: fmt.Errorf("log.entries[%d].response: %w", i, err),
+			}
+		}
+		if err := rec.add(e.Request.Method, u, effect); err != nil {
+			return nil, &ErrDecode{
+				File: file, Err: fmt.Errorf("log.entries[%d].request: %w", i, err),
+			}
+		}
+	}
+	return rec.config()
+}
+
+type harEntry struct {
+	Request struct {
+		Method string `json:"method"`
+		URL    string `json:"url"`
+	} `json:"request"`
+	Response struct {
+		Status  int         `json:"status"`
+		Headers []harHeader `json:"headers"`
+		Content struct {
+			Text     string `json:"text"`
+			Encoding string `json:"encoding"`
+		} `json:"content"`
+	} `json:"response"`
+	Timings struct {
+		// Wait and Receive are in milliseconds, -1 if not applicable.
+		Wait    float64 `json:"wait"`
+		Receive float64 `json:"receive"`
+	} `json:"timings"`
+}
+
+type harHeader struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+func recordedResource(method string, u *url.URL) (r Resource, err error) {
+	r.Methods = []HTTPMethod{HTTPMethod(method)}
+	if r.Path, err = NewGlobExpression(glob.QuoteMeta(u.Path)); err != nil {
+		return r, err
+	}
+	for name, values := range u.Query() {
+		exprs := make([]GlobExpression, len(values))
+		for i, v := range values {
+			if exprs[i], err = NewGlobExpression(glob.QuoteMeta(v)); err != nil {
+				return r, err
+			}
+		}
+		if err := setGlobMap(&r.Query, glob.QuoteMeta(name), exprs); err != nil {
+			return r, err
+		}
+		r.QueryRequired = true
+	}
+	return r, nil
+}
+
+func (e *harEntry) effect() (*Effect, error) {
+	header := http.Header{}
+	for _, h := range e.Response.Headers {
+		if strings.HasPrefix(h.Name, ":") {
+			continue // HTTP/2 pseudo header.
+		}
+		header.Add(h.Name, h.Value)
+	}
+	replace := recordedReplace(e.Response.Status, header)
+
+	switch content := e.Response.Content; {
+	case content.Text == "":
+	case content.Encoding == "base64":
+		data, err := base64.StdEncoding.DecodeString(content.Text)
+		if err != nil {
+			return nil, fmt.Errorf("content.text: %w", err)
+		}
+		b := NewBase64(data)
+		replace.BodyBase64 = &b
+	default:
+		text := content.Text
+		replace.Body = &text
+	}
+
+	effect := &Effect{Replace: replace}
+	if d := harDuration(e.Timings.Wait); d > 0 {
+		effect.Delay = &DurRange{Min: d, Max: d}
+	}
+	if d := harDuration(e.Timings.Receive); d > 0 {
+		effect.DelayBody = &DurRange{Min: d, Max: d}
+	}
+	return effect, nil
+}
+
+// harDuration converts HAR milliseconds to a duration, -1 to 0.
+func harDuration(ms float64) time.Duration {
+	if ms <= 0 {
+		return 0
+	}
+	return time.Duration(ms * float64(time.Millisecond))
+}
+
+// recordedReplace returns a replacement with the recorded status
+// and header, with Set-Cookie headers converted to cookies.
+func recordedReplace(status int, header http.Header) *Replace {
+	replace := &Replace{
+		StatusCode:       StatusCode(status),
+		AllowNonstandard: http.StatusText(status) == "",
+	}
+	for name, values := range header {
+		if recordedSkippedHeaders[name] {
+			continue
+		}
+		if replace.Headers == nil {
+			replace.Headers = map[HeaderName]string{}
+		}
+		replace.Headers[HeaderName(name)] = strings.Join(values, ", ")
+	}
+	for _, c := range (&http.Response{Header: header}).Cookies() {
+		cookie := Cookie{
+			Name: c.Name, Value: c.Value, Path: c.Path, Domain: c.Domain,
+			MaxAge: time.Duration(c.MaxAge) * time.Second,
+			Secure: c.Secure, HTTPOnly: c.HttpOnly,
+		}
+		switch c.SameSite {
+		case http.SameSiteLaxMode:
+			cookie.SameSite = SameSiteLax
+		case http.SameSiteStrictMode:
+			cookie.SameSite = SameSiteStrict
+		case http.SameSiteNoneMode:
+			cookie.SameSite = SameSiteNone
+		}
+		replace.Cookies = append(replace.Cookies, cookie)
+	}
+	return replace
+}
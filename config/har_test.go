@@ -0,0 +1,127 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim/config"
+)
+
+func TestFromHAR(t *testing.T) {
+	p := TmpFile(t, `{
+	"log": {
+		"version": "1.2",
+		"entries": [
+			{
+				"request": {"method": "GET", "url": "https://example.com/api/items?b=2&a=1"},
+				"response": {
+					"status": 200,
+					"headers": [
+						{"name": "content-type", "value": "application/json"},
+						{"name": "Content-Encoding", "value": "gzip"},
+						{"name": "Content-Length", "value": "42"},
+						{"name": "Vary", "value": "Accept"},
+						{"name": "Vary", "value": "Cookie"},
+						{"name": "Set-Cookie", "value": "session=abc; Path=/; HttpOnly; SameSite=Lax"},
+						{"name": ":status", "value": "200"}
+					],
+					"content": {"mimeType": "application/json", "text": "[1]"}
+				},
+				"timings": {"wait": 120.5, "receive": -1}
+			},
+			{
+				"request": {"method": "GET", "url": "https://cdn.example.com/logo.png"},
+				"response": {
+					"status": 200,
+					"headers": [],
+					"content": {"mimeType": "image/png", "text": "iVBO", "encoding": "base64"}
+				},
+				"timings": {"wait": 0, "receive": 30}
+			},
+			{
+				"request": {"method": "GET", "url": "https://example.com/aborted"},
+				"response": {"status": 0, "headers": [], "content": {}},
+				"timings": {"wait": -1, "receive": -1}
+			},
+			{
+				"request": {"method": "GET", "url": "https://example.com/api/items?a=1&b=2"},
+				"response": {"status": 304, "headers": [], "content": {}},
+				"timings": {"wait": 10, "receive": 0}
+			},
+			{
+				"request": {"method": "GET", "url": "https://example.com/api/items?a=1&b=2"},
+				"response": {"status": 599, "headers": [], "content": {"text": "down"}},
+				"timings": {"wait": 5, "receive": 0}
+			}
+		]
+	}
+}`)
+	c, err := config.FromHAR(p)
+	require.NoError(t, err)
+	require.Len(t, c.Resources, 2)
+
+	items := c.Resources[0]
+	require.Equal(t, []config.HTTPMethod{"GET"}, items.Methods)
+	require.Equal(t, "/api/items", items.Path.String())
+	require.True(t, items.QueryRequired)
+	require.Len(t, items.Query, 2)
+
+	// Repeated requests respond in order with the last response repeating.
+	require.NotNil(t, items.Sequence)
+	require.Len(t, items.Sequence.Steps, 2)
+	first := items.Sequence.Steps[0].Effect
+	require.Equal(t, config.StatusCode(200), first.Replace.StatusCode)
+	require.Equal(t, "[1]", *first.Replace.Body)
+	require.Equal(t, map[config.HeaderName]string{
+		"Content-Type": "application/json",
+		"Vary":         "Accept, Cookie",
+	}, first.Replace.Headers)
+	require.Equal(t, []config.Cookie{{
+		Name: "session", Value: "abc", Path: "/",
+		HTTPOnly: true, SameSite: config.SameSiteLax,
+	}}, first.Replace.Cookies)
+	require.Equal(t, &config.DurRange{
+		Min: 120500 * time.Microsecond, Max: 120500 * time.Microsecond,
+	}, first.Delay)
+	require.Nil(t, first.DelayBody)
+
+	second := items.Sequence.Steps[1].Effect
+	require.Equal(t, config.StatusCode(304), second.Replace.StatusCode)
+	require.Nil(t, second.Replace.Body)
+
+	last := items.Effect
+	require.Equal(t, config.StatusCode(599), last.Replace.StatusCode)
+	require.True(t, last.Replace.AllowNonstandard)
+	require.Equal(t, "down", *last.Replace.Body)
+
+	logo := c.Resources[1]
+	require.Equal(t, "/logo.png", logo.Path.String())
+	require.Nil(t, logo.Sequence)
+	require.Equal(t, []byte{0x89, 0x50, 0x4e}, logo.Effect.Replace.BodyBase64.Bytes())
+	require.Nil(t, logo.Effect.Delay)
+	require.Equal(t, &config.DurRange{
+		Min: 30 * time.Millisecond, Max: 30 * time.Millisecond,
+	}, logo.Effect.DelayBody)
+}
+
+func TestFromHARErr(t *testing.T) {
+	p := TmpFile(t, `{"log": {"entries": [{`)
+	_, err := config.FromHAR(p)
+	var d *config.ErrDecode
+	require.ErrorAs(t, err, &d)
+	require.Equal(t, p, d.File)
+
+	p = TmpFile(t, `{"log": {"entries": [{
+		"request": {"method": "GET", "url": "/"},
+		"response": {"status": 200, "content": {"text": "!", "encoding": "base64"}}
+	}]}}`)
+	_, err = config.FromHAR(p)
+	require.ErrorAs(t, err, &d)
+
+	_, err = config.FromHAR(filepath.Join(t.TempDir(), "missing.har"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
@@ -0,0 +1,53 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CurrentVersion is the version of the config format implemented
+// by this package. Configs without a version are of the current version.
+const CurrentVersion = 1
+
+var ErrUnsupportedVersion = errors.New("unsupported config version")
+
+// migrations[i] migrates the node tree of a config of version i+1
+// to version i+2 in place. A migration is added whenever the format
+// changes in a way older configs wouldn't decode or would behave
+// differently, and CurrentVersion is incremented.
+var migrations = [CurrentVersion - 1]func(root *yaml.Node) error{}
+
+// migrate migrates the document root of a config of an older version
+// to CurrentVersion and returns true if it did.
+func migrate(root *yaml.Node) (bool, error) {
+	n := mappingValue(root.Content[0], "version")
+	if n == nil {
+		return false, nil // Unversioned configs are of the current version.
+	}
+	var version uint32
+	if err := n.Decode(&version); err != nil {
+		return false, nil // Decoding errors are reported by the strict decoder.
+	}
+	if version > CurrentVersion {
+		return false, &ErrValidation{
+			ResourceIndex: -1, Path: "version", Line: n.Line, Column: n.Column,
+			Err: fmt.Errorf("%w: %d, the latest supported version is %d",
+				ErrUnsupportedVersion, version, CurrentVersion),
+		}
+	}
+	if version == 0 || version == CurrentVersion {
+		return false, nil
+	}
+	for v := version; v < CurrentVersion; v++ {
+		if err := migrations[v-1](root); err != nil {
+			return false, &ErrDecode{Err: fmt.Errorf(
+				"migrating from version %d to %d: %w", v, v+1, err,
+			)}
+		}
+	}
+	n.SetString(fmt.Sprint(CurrentVersion))
+	n.Tag = "!!int"
+	return true, nil
+}
@@ -0,0 +1,284 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gobwas/glob"
+	"gopkg.in/yaml.v3"
+)
+
+var ErrUnsupportedOpenAPI = errors.New("unsupported OpenAPI version")
+
+// openAPIMethods are the operations of a path item in the order
+// resources are generated in.
+var openAPIMethods = [...]string{
+	"get", "put", "post", "delete", "options", "head", "patch", "trace",
+}
+
+// FromOpenAPI scaffolds one resource per operation of the OpenAPI 3
+// spec file (YAML or JSON) replacing responses with the example of the
+// operation's success response, or the default response if there's none.
+// Resources are named after operation IDs and match the path template
+// prefixed with the path of the first server, the method and the request
+// content types. Concrete paths precede templated ones.
+// Returns *ErrOpen if file can't be read, *ErrDecode if it isn't
+// an OpenAPI 3 spec and *ErrValidation if the result is invalid.
+func FromOpenAPI(file string) (*Config, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, &ErrOpen{File: file, Err: err}
+	}
+	var root yaml.Node
+	if err := yaml.Unmarshal(b, &root); err != nil {
+		return nil, &ErrDecode{File: file, Err: err}
+	}
+	if len(root.Content) < 1 {
+		return nil, &ErrDecode{
+			File: file, Err: fmt.Errorf("%w: empty", ErrUnsupportedOpenAPI),
+		}
+	}
+	spec := openAPISpec{root: root.Content[0]}
+	if v := mappingValue(spec.root, "openapi"); v == nil ||
+		!strings.HasPrefix(v.Value, "3.") {
+		version := "none"
+		if v := mappingValue(spec.root, "swagger"); v != nil {
+			version = v.Value
+		} else if v := mappingValue(spec.root, "openapi"); v != nil {
+			version = v.Value
+		}
+		return nil, &ErrDecode{
+			File: file, Err: fmt.Errorf("%w: %s", ErrUnsupportedOpenAPI, version),
+		}
+	}
+
+	type pathItem struct {
+		template string
+		item     *yaml.Node
+	}
+	var items []pathItem
+	if paths := mappingValue(spec.root, "paths"); paths != nil {
+		for i := 0; i+1 < len(paths.Content); i += 2 {
+			items = append(items, pathItem{
+				template: paths.Content[i].Value,
+				item:     spec.deref(paths.Content[i+1]),
+			})
+		}
+	}
+	slices.SortStableFunc(items, func(a, b pathItem) int {
+		// Concrete paths must match before their templated counterparts.
+		return strings.Count(a.template, "{") - strings.Count(b.template, "{")
+	})
+
+	prefix := spec.basePath()
+	c := &Config{}
+	names := map[string]bool{}
+	for _, p := range items {
+		for _, method := range openAPIMethods {
+			op := spec.deref(mappingValue(p.item, method))
+			if op == nil {
+				continue
+			}
+			r, err := spec.resource(prefix+p.template, method, op)
+			if err != nil {
+				return nil, &ErrDecode{
+					File: file,
+					Err:  fmt.Errorf("paths[%s].%s: %w", p.template, method, err),
+				}
+			}
+			if id := mappingValue(op, "operationId"); id != nil && !names[id.Value] {
+				r.Name, names[id.Value] = id.Value, true
+			}
+			c.Resources = append(c.Resources, r)
+		}
+	}
+	if err := Validate(*c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+type openAPISpec struct{ root *yaml.Node }
+
+// deref returns the node referenced by n if n is a local reference
+// object such as {$ref: '#/components/responses/NotFound'}, otherwise n.
+func (s openAPISpec) deref(n *yaml.Node) *yaml.Node {
+	for range 32 { // Limits the depth to break reference cycles.
+		ref := mappingValue(n, "$ref")
+		if ref == nil || !strings.HasPrefix(ref.Value, "#/") {
+			return n
+		}
+		n = s.root
+		for _, key := range strings.Split(ref.Value[2:], "/") {
+			key = strings.NewReplacer("~1", "/", "~0", "~").Replace(key)
+			n = mappingValue(n, key)
+		}
+	}
+	return n
+}
+
+// basePath returns the path of the first server URL or ""
+// if there's none or the URL uses server variables.
+func (s openAPISpec) basePath() string {
+	servers := mappingValue(s.root, "servers")
+	if servers == nil || servers.Kind != yaml.SequenceNode || len(servers.Content) < 1 {
+		return ""
+	}
+	u := mappingValue(servers.Content[0], "url")
+	if u == nil || strings.Contains(u.Value, "{") {
+		return ""
+	}
+	parsed, err := url.Parse(u.Value)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSuffix(parsed.Path, "/")
+}
+
+func (s openAPISpec) resource(template, method string, op *yaml.Node) (Resource, error) {
+	r := Resource{Methods: []HTTPMethod{HTTPMethod(strings.ToUpper(method))}}
+
+	var path strings.Builder
+	for _, segment := range pathTemplate.FindAllString(template, -1) {
+		if segment[0] == '{' {
+			path.WriteString("*")
+		} else {
+			path.WriteString(glob.QuoteMeta(segment))
+		}
+	}
+	var err error
+	if r.Path, err = NewGlobExpression(path.String()); err != nil {
+		return r, err
+	}
+
+	body := s.deref(mappingValue(op, "requestBody"))
+	if types := mediaTypes(s.deref(mappingValue(body, "content"))); len(types) > 0 {
+		alternatives := make([]string, len(types))
+		for i, t := range types {
+			// Allow parameters such as "; charset=utf-8".
+			alternatives[i] = strings.ReplaceAll(glob.QuoteMeta(t), `\*`, "*") + "*"
+		}
+		expr := alternatives[0]
+		if len(alternatives) > 1 {
+			expr = "{" + strings.Join(alternatives, ",") + "}"
+		}
+		value, err := NewGlobExpression(expr)
+		if err != nil {
+			return r, fmt.Errorf("requestBody.content: %w", err)
+		}
+		err = setGlobMap(&r.Headers, "Content-Type", []GlobExpression{value})
+		if err != nil {
+			return r, fmt.Errorf("requestBody.content: %w", err)
+		}
+	}
+
+	replace, err := s.replace(s.deref(mappingValue(op, "responses")))
+	if err != nil {
+		return r, err
+	}
+	r.Effect = &Effect{Replace: replace}
+	return r, nil
+}
+
+// replace returns the replacement for the lowest 2xx response,
+// or the default response if there's none.
+func (s openAPISpec) replace(responses *yaml.Node) (*Replace, error) {
+	r := &Replace{StatusCode: http.StatusOK}
+	var response *yaml.Node
+	status := 0
+	for i := 0; responses != nil && i+1 < len(responses.Content); i += 2 {
+		code := responses.Content[i].Value
+		var c int
+		switch {
+		case strings.EqualFold(code, "2XX"):
+			c = http.StatusOK
+		case code == "default":
+			if response == nil {
+				response = responses.Content[i+1]
+			}
+			continue
+		default:
+			var err error
+			if c, err = strconv.Atoi(code); err != nil || c < 200 || c > 299 {
+				continue
+			}
+		}
+		if status == 0 || c < status {
+			status, response = c, responses.Content[i+1]
+		}
+	}
+	if status != 0 {
+		r.StatusCode = StatusCode(status)
+	}
+	response = s.deref(response)
+
+	content := s.deref(mappingValue(response, "content"))
+	types := mediaTypes(content)
+	if len(types) < 1 {
+		return r, nil
+	}
+	mediaType := types[0]
+	for _, t := range types {
+		if strings.Contains(t, "json") {
+			mediaType = t
+			break
+		}
+	}
+	if !strings.Contains(mediaType, "*") {
+		r.Headers = map[HeaderName]string{"Content-Type": mediaType}
+	}
+
+	example := s.example(s.deref(mappingValue(content, mediaType)))
+	if example == nil {
+		return r, nil
+	}
+	var value any
+	if err := example.Decode(&value); err != nil {
+		return nil, fmt.Errorf("example: %w", err)
+	}
+	if str, ok := value.(string); ok && !strings.Contains(mediaType, "json") {
+		r.Body = &str
+		return r, nil
+	}
+	b, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("example: %w", err)
+	}
+	body := string(b)
+	r.Body = &body
+	return r, nil
+}
+
+// example returns the example of media type object m
+// or the example of its schema, or nil if there's none.
+func (s openAPISpec) example(m *yaml.Node) *yaml.Node {
+	if e := mappingValue(m, "example"); e != nil {
+		return e
+	}
+	if examples := s.deref(mappingValue(m, "examples")); examples != nil &&
+		len(examples.Content) > 1 {
+		if e := mappingValue(s.deref(examples.Content[1]), "value"); e != nil {
+			return e
+		}
+	}
+	return mappingValue(s.deref(mappingValue(m, "schema")), "example")
+}
+
+// mediaTypes returns the keys of content in order.
+func mediaTypes(content *yaml.Node) []string {
+	if content == nil || content.Kind != yaml.MappingNode {
+		return nil
+	}
+	types := make([]string, 0, len(content.Content)/2)
+	for i := 0; i+1 < len(content.Content); i += 2 {
+		types = append(types, content.Content[i].Value)
+	}
+	return types
+}
@@ -0,0 +1,159 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim/config"
+)
+
+func TestFromOpenAPI(t *testing.T) {
+	p := TmpFile(t, `
+openapi: 3.0.3
+info: {title: Pets, version: "1"}
+servers:
+  - url: https://api.example.com/v1/
+paths:
+  /pets/{petId}:
+    $ref: '#/components/pathItems/Pet'
+  /pets:
+    get:
+      operationId: listPets
+      responses:
+        default:
+          $ref: '#/components/responses/Error'
+        "200":
+          description: OK
+          content:
+            text/plain:
+              example: ignored
+            application/json:
+              examples:
+                two:
+                  value: [{id: 1, name: Rex}, {id: 2, name: Tom}]
+    post:
+      operationId: createPet
+      requestBody:
+        content:
+          application/json: {}
+          application/x-www-form-urlencoded: {}
+      responses:
+        "201":
+          description: Created
+        "204":
+          description: Unused
+  /pets/mine:
+    get:
+      responses:
+        "404":
+          description: Not found
+components:
+  pathItems:
+    Pet:
+      delete:
+        operationId: deletePet
+        responses:
+          default:
+            $ref: '#/components/responses/Error'
+  responses:
+    Error:
+      description: Error
+      content:
+        application/problem+json:
+          schema:
+            $ref: '#/components/schemas/Problem'
+  schemas:
+    Problem:
+      type: object
+      example: {title: Internal Server Error, status: 500}
+`)
+	c, err := config.FromOpenAPI(p)
+	require.NoError(t, err)
+	require.Len(t, c.Resources, 4)
+
+	list := c.Resources[0]
+	require.Equal(t, "listPets", list.Name)
+	require.Equal(t, []config.HTTPMethod{"GET"}, list.Methods)
+	require.Equal(t, "/v1/pets", list.Path.String())
+	require.Equal(t, config.StatusCode(200), list.Effect.Replace.StatusCode)
+	require.Equal(t, `[{"id":1,"name":"Rex"},{"id":2,"name":"Tom"}]`,
+		*list.Effect.Replace.Body)
+	require.Equal(t, map[config.HeaderName]string{
+		"Content-Type": "application/json",
+	}, list.Effect.Replace.Headers)
+
+	create := c.Resources[1]
+	require.Equal(t, "createPet", create.Name)
+	require.Equal(t, []config.HTTPMethod{"POST"}, create.Methods)
+	require.Equal(t, config.StatusCode(201), create.Effect.Replace.StatusCode)
+	require.Nil(t, create.Effect.Replace.Body)
+	require.Len(t, create.Headers, 1)
+	for name, values := range create.Headers {
+		require.Equal(t, "Content-Type", name.String())
+		require.Len(t, values, 1)
+		require.True(t, values[0].Match("application/json; charset=utf-8"))
+		require.True(t, values[0].Match("application/x-www-form-urlencoded"))
+		require.False(t, values[0].Match("text/plain"))
+	}
+
+	// Concrete paths precede templated ones.
+	mine := c.Resources[2]
+	require.Empty(t, mine.Name)
+	require.Equal(t, "/v1/pets/mine", mine.Path.String())
+	require.Equal(t, config.StatusCode(200), mine.Effect.Replace.StatusCode)
+
+	del := c.Resources[3]
+	require.Equal(t, "deletePet", del.Name)
+	require.Equal(t, []config.HTTPMethod{"DELETE"}, del.Methods)
+	require.Equal(t, "/v1/pets/*", del.Path.String())
+	require.True(t, del.Path.Match("/v1/pets/42"))
+	require.Equal(t, config.StatusCode(200), del.Effect.Replace.StatusCode)
+	require.Equal(t, `{"status":500,"title":"Internal Server Error"}`,
+		*del.Effect.Replace.Body)
+	require.Equal(t, "application/problem+json",
+		del.Effect.Replace.Headers["Content-Type"])
+}
+
+func TestFromOpenAPIJSON(t *testing.T) {
+	p := TmpFile(t, `{
+	"openapi": "3.1.0",
+	"paths": {
+		"/health": {
+			"get": {
+				"responses": {
+					"2XX": {
+						"content": {"text/plain": {"example": "OK"}}
+					}
+				}
+			}
+		}
+	}
+}`)
+	c, err := config.FromOpenAPI(p)
+	require.NoError(t, err)
+	require.Len(t, c.Resources, 1)
+	require.Equal(t, "/health", c.Resources[0].Path.String())
+	require.Equal(t, "OK", *c.Resources[0].Effect.Replace.Body)
+}
+
+func TestFromOpenAPIErr(t *testing.T) {
+	f := func(src string) {
+		t.Helper()
+		p := TmpFile(t, src)
+		c, err := config.FromOpenAPI(p)
+		require.Nil(t, c)
+		require.ErrorIs(t, err, config.ErrUnsupportedOpenAPI)
+		var d *config.ErrDecode
+		require.ErrorAs(t, err, &d)
+		require.Equal(t, p, d.File)
+	}
+	f("")
+	f("swagger: '2.0'\npaths: {}")
+	f("openapi: 4.0.0")
+
+	_, err := config.FromOpenAPI(filepath.Join(t.TempDir(), "missing.yaml"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
@@ -0,0 +1,298 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gobwas/glob"
+)
+
+var ErrUnsupportedPostman = errors.New("unsupported Postman collection version")
+
+// FromPostman converts the example responses of the requests in the
+// Postman collection (format v2.0 or v2.1) file into resources replacing
+// responses with the examples including status, headers, body and
+// response time as delay. Examples of the same request sharing the same
+// method, path and query are represented by their success example,
+// or the first one if there's none. Resources match the method, path and
+// query of the example's original request regardless of host.
+// Collection variables are substituted, unresolved variables and path
+// variables such as ":id" match anything. Resources are named after
+// the folders and requests, such as "Users/Get user", and examples
+// for the same request with a different matcher, such as
+// "Users/Get user/Not found". Requests without examples are skipped.
+// Returns *ErrOpen if file can't be read, *ErrDecode if it isn't
+// a supported Postman collection and *ErrValidation if the result is invalid.
+func FromPostman(file string) (*Config, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, &ErrOpen{File: file, Err: err}
+	}
+	var collection struct {
+		Info struct {
+			Schema string `json:"schema"`
+		} `json:"info"`
+		Item     []postmanItem `json:"item"`
+		Variable []struct {
+			Key   string `json:"key"`
+			Value any    `json:"value"`
+		} `json:"variable"`
+	}
+	if err := json.Unmarshal(b, &collection); err != nil {
+		return nil, &ErrDecode{File: file, Err: err}
+	}
+	if !strings.Contains(collection.Info.Schema, "/v2.") {
+		return nil, &ErrDecode{
+			File: file,
+			Err:  fmt.Errorf("%w: %q", ErrUnsupportedPostman, collection.Info.Schema),
+		}
+	}
+	vars := make(map[string]string, len(collection.Variable))
+	for _, v := range collection.Variable {
+		vars[v.Key] = fmt.Sprint(v.Value)
+	}
+
+	c := &Config{}
+	names := map[string]bool{}
+	var walk func(prefix string, items []postmanItem) error
+	walk = func(prefix string, items []postmanItem) error {
+		for _, item := range items {
+			name := prefix + item.Name
+			if item.Item != nil {
+				if err := walk(name+"/", item.Item); err != nil {
+					return err
+				}
+				continue
+			}
+			resources, err := item.resources(vars)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			for i, r := range resources {
+				if i > 0 {
+					r.Name = name + "/" + r.Name
+				} else {
+					r.Name = name
+				}
+				if names[r.Name] {
+					r.Name = "" // Postman names aren't necessarily unique.
+				}
+				names[r.Name] = r.Name != ""
+				c.Resources = append(c.Resources, r)
+			}
+		}
+		return nil
+	}
+	if err := walk("", collection.Item); err != nil {
+		return nil, &ErrDecode{File: file, Err: err}
+	}
+	if err := Validate(*c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// postmanItem is either a folder of items or a request with examples.
+type postmanItem struct {
+	Name     string            `json:"name"`
+	Item     []postmanItem     `json:"item"`
+	Request  *postmanRequest   `json:"request"`
+	Response []postmanResponse `json:"response"`
+}
+
+type postmanRequest struct {
+	Method string     `json:"method"`
+	URL    postmanURL `json:"url"`
+}
+
+// postmanURL is either a string or an object with the raw URL.
+type postmanURL struct{ Raw string }
+
+func (u *postmanURL) UnmarshalJSON(b []byte) error {
+	if err := json.Unmarshal(b, &u.Raw); err == nil {
+		return nil
+	}
+	var obj struct {
+		Raw string `json:"raw"`
+	}
+	err := json.Unmarshal(b, &obj)
+	u.Raw = obj.Raw
+	return err
+}
+
+type postmanResponse struct {
+	Name            string          `json:"name"`
+	OriginalRequest *postmanRequest `json:"originalRequest"`
+	Code            int             `json:"code"`
+	Header          []struct {
+		Key      string `json:"key"`
+		Value    string `json:"value"`
+		Disabled bool   `json:"disabled"`
+	} `json:"header"`
+	Body         *string `json:"body"`
+	ResponseTime any     `json:"responseTime"`
+}
+
+// resources returns one resource per distinct matcher of the examples of i,
+// each named after its example.
+func (i *postmanItem) resources(vars map[string]string) ([]Resource, error) {
+	type group struct {
+		resource Resource
+		example  *postmanResponse
+	}
+	var groups []*group
+	byMatcher := map[string]*group{}
+	for j := range i.Response {
+		example := &i.Response[j]
+		req := example.OriginalRequest
+		if req == nil {
+			req = i.Request
+		}
+		if req == nil {
+			return nil, fmt.Errorf("response[%d]: no request", j)
+		}
+		r, key, err := req.resource(vars)
+		if err != nil {
+			return nil, fmt.Errorf("response[%d]: %w", j, err)
+		}
+		g, ok := byMatcher[key]
+		if !ok {
+			g = &group{resource: r, example: example}
+			byMatcher[key] = g
+			groups = append(groups, g)
+			continue
+		}
+		if !isSuccess(g.example.Code) && isSuccess(example.Code) {
+			g.example = example
+		}
+	}
+
+	resources := make([]Resource, len(groups))
+	for j, g := range groups {
+		resources[j] = g.resource
+		resources[j].Name = g.example.Name
+		resources[j].Effect = g.example.effect()
+	}
+	return resources, nil
+}
+
+func isSuccess(code int) bool { return code >= 200 && code < 300 }
+
+// postmanVariable matches {{variables}}.
+var postmanVariable = regexp.MustCompile(`\{\{[^}]*\}\}`)
+
+// resource returns a resource matching r and a key identifying the matcher.
+func (r *postmanRequest) resource(vars map[string]string) (Resource, string, error) {
+	method := r.Method
+	if method == "" {
+		method = http.MethodGet // Postman's default.
+	}
+	raw := postmanVariable.ReplaceAllStringFunc(r.URL.Raw, func(s string) string {
+		if v, ok := vars[strings.TrimSpace(s[2:len(s)-2])]; ok {
+			return v
+		}
+		return s
+	})
+	raw, _, _ = strings.Cut(raw, "#")
+	path, query, _ := strings.Cut(raw, "?")
+	if _, rest, ok := strings.Cut(path, "://"); ok {
+		path = rest
+	}
+	if !strings.HasPrefix(path, "/") {
+		// Strip the host, such as "example.com" or an unresolved "{{baseUrl}}".
+		if i := strings.IndexByte(path, '/'); i >= 0 {
+			path = path[i:]
+		} else {
+			path = "/"
+		}
+	}
+
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		if strings.HasPrefix(s, ":") {
+			segments[i] = "*" // Path variable.
+			continue
+		}
+		segments[i] = postmanGlob(s)
+	}
+	res := Resource{Methods: []HTTPMethod{HTTPMethod(strings.ToUpper(method))}}
+	var err error
+	if res.Path, err = NewGlobExpression(strings.Join(segments, "/")); err != nil {
+		return res, "", err
+	}
+
+	var params []string
+	values := map[string][]GlobExpression{}
+	for _, p := range strings.Split(query, "&") {
+		if p == "" {
+			continue
+		}
+		name, value, _ := strings.Cut(p, "=")
+		name, value = postmanUnescape(name), postmanUnescape(value)
+		expr, err := NewGlobExpression(postmanGlob(value))
+		if err != nil {
+			return res, "", err
+		}
+		if _, ok := values[name]; !ok {
+			params = append(params, name)
+		}
+		values[name] = append(values[name], expr)
+	}
+	for _, name := range params {
+		if err := setGlobMap(&res.Query, postmanGlob(name), values[name]); err != nil {
+			return res, "", err
+		}
+		res.QueryRequired = true
+	}
+	return res, string(res.Methods[0]) + " " + path + "?" + query, nil
+}
+
+// postmanUnescape returns the query unescaped s or s if it's malformed.
+func postmanUnescape(s string) string {
+	if u, err := url.QueryUnescape(s); err == nil {
+		return u
+	}
+	return s
+}
+
+// postmanGlob returns a glob matching s literally
+// with unresolved variables matching anything.
+func postmanGlob(s string) string {
+	var b strings.Builder
+	last := 0
+	for _, loc := range postmanVariable.FindAllStringIndex(s, -1) {
+		b.WriteString(glob.QuoteMeta(s[last:loc[0]]))
+		b.WriteString("*")
+		last = loc[1]
+	}
+	b.WriteString(glob.QuoteMeta(s[last:]))
+	return b.String()
+}
+
+func (e *postmanResponse) effect() *Effect {
+	header := http.Header{}
+	for _, h := range e.Header {
+		if !h.Disabled {
+			header.Add(h.Key, h.Value)
+		}
+	}
+	status := e.Code
+	if status == 0 {
+		status = http.StatusOK
+	}
+	replace := recordedReplace(status, header)
+	replace.Body = e.Body
+	effect := &Effect{Replace: replace}
+	if ms, ok := e.ResponseTime.(float64); ok && ms > 0 {
+		d := time.Duration(ms * float64(time.Millisecond))
+		effect.Delay = &DurRange{Min: d, Max: d}
+	}
+	return effect
+}
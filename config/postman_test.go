@@ -0,0 +1,140 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim/config"
+)
+
+func TestFromPostman(t *testing.T) {
+	p := TmpFile(t, `{
+	"info": {
+		"name": "Shop",
+		"schema": "https://schema.getpostman.com/json/collection/v2.1.0/collection.json"
+	},
+	"variable": [{"key": "baseUrl", "value": "https://api.example.com/v1"}],
+	"item": [
+		{
+			"name": "Users",
+			"item": [
+				{
+					"name": "Get user",
+					"request": {"method": "GET", "url": {"raw": "{{baseUrl}}/users/:id"}},
+					"response": [
+						{
+							"name": "Not found",
+							"originalRequest": {
+								"method": "GET",
+								"url": {"raw": "{{baseUrl}}/users/:id"}
+							},
+							"code": 404,
+							"header": [],
+							"body": "{\"error\": \"not found\"}"
+						},
+						{
+							"name": "OK",
+							"originalRequest": {
+								"method": "GET",
+								"url": {"raw": "{{baseUrl}}/users/:id"}
+							},
+							"code": 200,
+							"header": [
+								{"key": "Content-Type", "value": "application/json"},
+								{"key": "X-Disabled", "value": "x", "disabled": true},
+								{"key": "Set-Cookie", "value": "seen=1; Secure"}
+							],
+							"body": "{\"id\": 1}",
+							"responseTime": 85
+						},
+						{
+							"name": "Filtered",
+							"originalRequest": {
+								"method": "GET",
+								"url": "{{host}}/users/:id?fields=name%20email&token={{token}}"
+							},
+							"code": 200,
+							"body": "{\"name\": \"Alice\"}"
+						}
+					]
+				}
+			]
+		},
+		{
+			"name": "Health",
+			"request": {"url": "https://api.example.com/health"},
+			"response": [{"name": "Up", "body": "OK"}]
+		},
+		{
+			"name": "No examples",
+			"request": {"method": "POST", "url": "https://api.example.com/orders"},
+			"response": []
+		}
+	]
+}`)
+	c, err := config.FromPostman(p)
+	require.NoError(t, err)
+	require.Len(t, c.Resources, 3)
+
+	// The success example represents examples of the same matcher.
+	user := c.Resources[0]
+	require.Equal(t, "Users/Get user", user.Name)
+	require.Equal(t, []config.HTTPMethod{"GET"}, user.Methods)
+	require.Equal(t, "/v1/users/*", user.Path.String())
+	require.Equal(t, config.StatusCode(200), user.Effect.Replace.StatusCode)
+	require.Equal(t, `{"id": 1}`, *user.Effect.Replace.Body)
+	require.Equal(t, map[config.HeaderName]string{
+		"Content-Type": "application/json",
+	}, user.Effect.Replace.Headers)
+	require.Equal(t, []config.Cookie{{Name: "seen", Value: "1", Secure: true}},
+		user.Effect.Replace.Cookies)
+	require.Equal(t, &config.DurRange{
+		Min: 85 * time.Millisecond, Max: 85 * time.Millisecond,
+	}, user.Effect.Delay)
+
+	filtered := c.Resources[1]
+	require.Equal(t, "Users/Get user/Filtered", filtered.Name)
+	require.Equal(t, "/users/*", filtered.Path.String())
+	require.True(t, filtered.QueryRequired)
+	require.Len(t, filtered.Query, 2)
+	for name, values := range filtered.Query {
+		require.Len(t, values, 1)
+		switch name.String() {
+		case "fields":
+			require.True(t, values[0].Match("name email"))
+		case "token":
+			require.True(t, values[0].Match("anything"))
+		default:
+			t.Fatalf("unexpected query parameter %q", name.String())
+		}
+	}
+
+	health := c.Resources[2]
+	require.Equal(t, "Health", health.Name)
+	require.Equal(t, []config.HTTPMethod{"GET"}, health.Methods)
+	require.Equal(t, "/health", health.Path.String())
+	require.Equal(t, config.StatusCode(200), health.Effect.Replace.StatusCode)
+	require.Equal(t, "OK", *health.Effect.Replace.Body)
+	require.Nil(t, health.Effect.Delay)
+}
+
+func TestFromPostmanErr(t *testing.T) {
+	p := TmpFile(t, `{"info": {"schema": "https://schema.getpostman.com/json/collection/v1.0.0/collection.json"}}`)
+	c, err := config.FromPostman(p)
+	require.Nil(t, c)
+	require.ErrorIs(t, err, config.ErrUnsupportedPostman)
+	var d *config.ErrDecode
+	require.ErrorAs(t, err, &d)
+	require.Equal(t, p, d.File)
+
+	p = TmpFile(t, `{"info": `)
+	_, err = config.FromPostman(p)
+	require.ErrorAs(t, err, &d)
+
+	_, err = config.FromPostman(filepath.Join(t.TempDir(), "missing.json"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Attachment attaches effects to the resource of the given name
+// replacing the effects, chain and sequence the resource declares.
+// This allows for many lightweight scenario files attaching different
+// effects to resources declared once in a shared file they include.
+type Attachment struct {
+	// Resource is the name of the resource the effects are attached to.
+	Resource string `yaml:"resource"`
+
+	Effect *Effect `yaml:"effect"`
+
+	// Effects is a chain of effects, see Resource.Effects.
+	Effects []Effect `yaml:"effects"`
+
+	// Sequence is a sequence of effects, see Resource.Sequence.
+	Sequence *Sequence `yaml:"sequence"`
+}
+
+var (
+	ErrUnknownResource = errors.New("unknown resource")
+	ErrEmptyAttachment = errors.New("attachment has no effect")
+)
+
+func (a *Attachment) Validate() error {
+	if a.Effect != nil && len(a.Effects) > 0 {
+		return ErrEffectAndEffects
+	}
+	if a.Effect == nil && len(a.Effects) < 1 && a.Sequence == nil {
+		return ErrEmptyAttachment
+	}
+	return nil
+}
+
+// WithScenario returns c with the effects of its scenario attached
+// to its resources. Later attachments to the same resource replace
+// earlier ones. c isn't modified.
+func (c Config) WithScenario() Config {
+	if len(c.Scenario) < 1 {
+		return c
+	}
+	c.Resources = slices.Clone(c.Resources)
+	for _, a := range c.Scenario {
+		if a.Resource == "" {
+			continue
+		}
+		for i := range c.Resources {
+			if r := &c.Resources[i]; r.Name == a.Resource {
+				r.Effect, r.Effects, r.Sequence = a.Effect, a.Effects, a.Sequence
+			}
+		}
+	}
+	return c
+}
+
+// validateScenario returns an *ErrValidation if any attachment of c
+// references a resource that doesn't exist.
+func validateScenario(c Config) error {
+	for i, a := range c.Scenario {
+		if !slices.ContainsFunc(c.Resources, func(r Resource) bool {
+			return a.Resource != "" && r.Name == a.Resource
+		}) {
+			path := fmt.Sprintf("scenario[%d]", i)
+			if a.Resource != "" {
+				path += ".resource"
+			}
+			return &ErrValidation{
+				ResourceIndex: -1, Path: path,
+				Err: fmt.Errorf("%w: %q", ErrUnknownResource, a.Resource),
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package config_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim/config"
+)
+
+func TestLoadFileScenario(t *testing.T) {
+	dir := TmpFiles(t, map[string]string{
+		"resources.yaml": `
+resources:
+  - name: checkout
+    path: /checkout
+  - name: search
+    path: /search
+    effect:
+      delay: {min: 1s, max: 1s}
+`,
+		"scenarios/slow-checkout.yaml": `
+include: [../resources.yaml]
+defaults:
+  probability: 0.5
+scenario:
+  - resource: checkout
+    effect:
+      delay: {min: 2s, max: 2s}
+  - resource: search
+    effects:
+      - replace: {status-code: 503}
+`,
+	})
+	c, err := config.LoadFile(filepath.Join(dir, "scenarios/slow-checkout.yaml"))
+	require.NoError(t, err)
+
+	// Attachments don't modify the declared resources.
+	require.Nil(t, c.Resources[0].Effect)
+	require.NotNil(t, c.Resources[1].Effect)
+
+	s := c.WithScenario().WithDefaults()
+	require.Len(t, s.Resources, 2)
+
+	checkout := s.Resources[0]
+	require.Equal(t, "checkout", checkout.Name)
+	require.Equal(t, 2*time.Second, checkout.Effect.Delay.Min)
+	require.Equal(t, config.Probability(0.5), *checkout.Effect.Probability)
+
+	search := s.Resources[1]
+	require.Equal(t, "search", search.Name)
+	require.Nil(t, search.Effect)
+	require.Len(t, search.Effects, 1)
+	require.Equal(t, config.StatusCode(503), search.Effects[0].Replace.StatusCode)
+}
+
+func TestWithScenarioLaterAttachmentWins(t *testing.T) {
+	first := &config.Effect{Hang: &config.Hang{}}
+	second := &config.Effect{Replace: &config.Replace{StatusCode: 500}}
+	c := config.Config{
+		Resources: []config.Resource{{Name: "a"}, {}},
+		Scenario: []config.Attachment{
+			{Resource: "a", Effect: first},
+			{Resource: "a", Effect: second},
+		},
+	}
+	s := c.WithScenario()
+	require.Same(t, second, s.Resources[0].Effect)
+	require.Nil(t, s.Resources[1].Effect)
+	require.Nil(t, c.Resources[0].Effect)
+}
+
+func TestLoadFileScenarioErr(t *testing.T) {
+	f := func(src string, expectErr error, expectPath string, line, column int) {
+		t.Helper()
+		_, err := config.LoadFile(TmpFile(t, src))
+		require.ErrorIs(t, err, expectErr)
+		var e *config.ErrValidation
+		require.ErrorAs(t, err, &e)
+		require.Equal(t, expectPath, e.Path)
+		require.Equal(t, line, e.Line)
+		require.Equal(t, column, e.Column)
+	}
+	f(`
+resources:
+  - name: a
+scenario:
+  - resource: b
+    effect: {hang: {}}
+`, config.ErrUnknownResource, "scenario[0].resource", 5, 15)
+	f(`
+resources:
+  - path: /unnamed
+scenario:
+  - effect: {hang: {}}
+`, config.ErrUnknownResource, "scenario[0]", 5, 5)
+	f(`
+resources:
+  - name: a
+scenario:
+  - resource: a
+`, config.ErrEmptyAttachment, "scenario[0]", 5, 5)
+	f(`
+resources:
+  - name: a
+scenario:
+  - resource: a
+    effect: {hang: {}}
+    effects: [{hang: {}}]
+`, config.ErrEffectAndEffects, "scenario[0]", 5, 5)
+}
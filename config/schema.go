@@ -0,0 +1,147 @@
+package config
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// enums lists the values accepted by string enumeration types.
+var enums = map[reflect.Type][]string{
+	reflect.TypeFor[SameSite]():        {"", "lax", "strict", "none"},
+	reflect.TypeFor[ContentEncoding](): {"gzip", "deflate"},
+	reflect.TypeFor[DropMode]():        {"", "close", "hang"},
+	reflect.TypeFor[MalformedKind](): {
+		"wrong-content-length", "truncated-chunked",
+		"garbage-status-line", "duplicate-headers",
+	},
+	reflect.TypeFor[Distribution](): {
+		"", "uniform", "normal", "exponential",
+		"log-normal", "pareto", "percentiles",
+	},
+}
+
+// duration matches non-negative durations accepted by time.ParseDuration.
+const duration = `(0|((\d+(\.\d*)?|\.\d+)(ns|us|µs|μs|ms|s|m|h))+)`
+
+// durationPattern matches strings accepted by time.ParseDuration.
+const durationPattern = `^[-+]?` + duration + `$`
+
+// probabilityPattern matches percentages and ratios accepted by ParseProbability.
+const probabilityPattern = `^\s*(\d+(\.\d*)?|\.\d+)\s*(%|/\s*(\d+(\.\d*)?|\.\d+))?\s*$`
+
+// durRangePattern matches the shorthand syntax accepted by ParseDurRange.
+const durRangePattern = `^\s*` + duration + `\s*(-\s*` + duration + `\s*)?$`
+
+// JSONSchema returns the JSON Schema (draft 2020-12) of the YAML config format
+// for editors and CI pipelines to validate and autocomplete config files.
+// Semantic constraints, such as mutually exclusive fields, are only checked
+// by Validate.
+func JSONSchema() []byte {
+	g := schemaGenerator{defs: map[string]any{}}
+	root := g.schema(reflect.TypeFor[Config]())
+	root["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	root["$id"] = "https://github.com/romshark/httpsim/config.schema.json"
+	root["title"] = "httpsim config"
+	root["$defs"] = g.defs
+	b, err := json.MarshalIndent(root, "", "  ")
+	if err != nil {
+		panic(fmt.Errorf("marshaling JSON schema: %w", err))
+	}
+	return b
+}
+
+type schemaGenerator struct{ defs map[string]any }
+
+var textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+
+func (g schemaGenerator) schema(t reflect.Type) map[string]any {
+	switch t {
+	case reflect.TypeFor[time.Duration]():
+		g.defs["Duration"] = map[string]any{
+			"type": []string{"string", "integer"}, "pattern": durationPattern,
+		}
+		return map[string]any{"$ref": "#/$defs/Duration"}
+	case reflect.TypeFor[DurRange]():
+		return map[string]any{"anyOf": []any{
+			g.object(t),
+			map[string]any{"type": "string", "pattern": durRangePattern},
+		}}
+	case reflect.TypeFor[StatusCode]():
+		return map[string]any{"type": "integer", "minimum": 100, "maximum": 999}
+	case reflect.TypeFor[Probability]():
+		return map[string]any{"anyOf": []any{
+			map[string]any{"type": "number", "minimum": 0, "maximum": 1},
+			map[string]any{"type": "string", "pattern": probabilityPattern},
+		}}
+	case reflect.TypeFor[Percentile]():
+		return map[string]any{"type": "string", "pattern": `^p\d+(\.\d+)?$`}
+	}
+	if values, ok := enums[t]; ok {
+		return map[string]any{"type": "string", "enum": values}
+	}
+	if reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return map[string]any{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return g.schema(t.Elem())
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]any{"type": "integer"}
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer", "minimum": 0}
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{
+			"type": "object", "additionalProperties": g.schema(t.Elem()),
+		}
+	case reflect.Struct:
+		return g.object(t)
+	}
+	panic(fmt.Errorf("unsupported type: %v", t))
+}
+
+// object returns a reference to the definition of struct type t
+// creating the definition if necessary.
+func (g schemaGenerator) object(t reflect.Type) map[string]any {
+	name := t.Name()
+	ref := map[string]any{"$ref": "#/$defs/" + name}
+	if t == reflect.TypeFor[Config]() {
+		ref = nil // The root is inlined.
+	} else if _, ok := g.defs[name]; ok {
+		return ref
+	}
+	properties := map[string]any{}
+	def := map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	if ref != nil {
+		// Register before recursing to support self-referencing types.
+		g.defs[name] = def
+	}
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		properties[name] = g.schema(f.Type)
+	}
+	if ref == nil {
+		return def
+	}
+	return ref
+}
@@ -0,0 +1,81 @@
+package config_test
+
+import (
+	"encoding/json"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim/config"
+)
+
+func TestJSONSchema(t *testing.T) {
+	b := config.JSONSchema()
+	require.Equal(t, b, config.JSONSchema(), "must be deterministic")
+
+	var s struct {
+		Schema     string                     `json:"$schema"`
+		Properties map[string]json.RawMessage `json:"properties"`
+		Defs       map[string]struct {
+			Type                 any                        `json:"type"`
+			Pattern              string                     `json:"pattern"`
+			Properties           map[string]json.RawMessage `json:"properties"`
+			AdditionalProperties *bool                      `json:"additionalProperties"`
+		} `json:"$defs"`
+	}
+	require.NoError(t, json.Unmarshal(b, &s))
+	require.Equal(t, "https://json-schema.org/draft/2020-12/schema", s.Schema)
+	require.Contains(t, s.Properties, "case-insensitive-methods")
+	require.Contains(t, s.Properties, "include")
+	require.JSONEq(t, `{"type":"array","items":{"$ref":"#/$defs/Resource"}}`,
+		string(s.Properties["resources"]))
+
+	resource := s.Defs["Resource"]
+	require.Equal(t, "object", resource.Type)
+	require.False(t, *resource.AdditionalProperties)
+	require.JSONEq(t, `{"$ref":"#/$defs/Effect"}`, string(resource.Properties["effect"]))
+	require.JSONEq(t, `{"type":"string"}`, string(resource.Properties["path"]))
+	require.JSONEq(t, `{"type":"object","additionalProperties":{
+		"type":"array","items":{"type":"string"}
+	}}`, string(resource.Properties["headers"]))
+
+	effect := s.Defs["Effect"]
+	var probability struct {
+		AnyOf []map[string]any `json:"anyOf"`
+	}
+	require.NoError(t, json.Unmarshal(effect.Properties["probability"], &probability))
+	require.Len(t, probability.AnyOf, 2)
+	require.Equal(t, map[string]any{
+		"type": "number", "minimum": 0.0, "maximum": 1.0,
+	}, probability.AnyOf[0])
+	probabilityPattern := regexp.MustCompile(probability.AnyOf[1]["pattern"].(string))
+	for _, v := range []string{"5%", "0.5 %", "1/20", "0.05"} {
+		require.True(t, probabilityPattern.MatchString(v), v)
+	}
+	for _, v := range []string{"", "%", "1/", "five", "-1%"} {
+		require.False(t, probabilityPattern.MatchString(v), v)
+	}
+	var delay struct {
+		AnyOf []map[string]string `json:"anyOf"`
+	}
+	require.NoError(t, json.Unmarshal(effect.Properties["delay"], &delay))
+	require.Len(t, delay.AnyOf, 2)
+	require.Equal(t, "#/$defs/DurRange", delay.AnyOf[0]["$ref"])
+	shorthand := regexp.MustCompile(delay.AnyOf[1]["pattern"])
+	for _, v := range []string{"500ms", "200ms-2s", "1m30s - 2m", "0-1.5s"} {
+		require.True(t, shorthand.MatchString(v), v)
+	}
+	for _, v := range []string{"", "500", "-1s", "1s-", "1s-2s-3s", "fast"} {
+		require.False(t, shorthand.MatchString(v), v)
+	}
+
+	require.JSONEq(t, `{"type":"integer","minimum":100,"maximum":999}`,
+		string(s.Defs["Replace"].Properties["status-code"]))
+	require.JSONEq(t, `{"type":"string","enum":["","close","hang"]}`,
+		string(s.Defs["Drop"].Properties["mode"]))
+	require.JSONEq(t, `{"$ref":"#/$defs/Duration"}`,
+		string(s.Defs["DurRange"].Properties["min"]))
+	require.Equal(t, []any{"string", "integer"}, s.Defs["Duration"].Type)
+	require.NotEmpty(t, s.Defs["Duration"].Pattern)
+}
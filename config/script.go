@@ -0,0 +1,34 @@
+package config
+
+import (
+	"errors"
+	"strings"
+)
+
+// Script computes the effect on a request with a script for cases
+// the declarative effects can't express. Scripts are run by the script
+// engine of the middleware, which defines the language of Source and
+// what the script must define, such as the Starlark engine of
+// github.com/romshark/httpsim/starlarksim. Only the presence of Source
+// is validated unless a script validator is passed to the loader
+// using WithScriptValidator.
+type Script struct {
+	// Source is the source code of the script.
+	Source string `yaml:"source"`
+
+	// MaxSteps limits the number of execution steps per request protecting
+	// against runaway scripts. Defaults to DefaultScriptMaxSteps.
+	MaxSteps uint64 `yaml:"max-steps"`
+}
+
+// DefaultScriptMaxSteps is used if Script.MaxSteps is zero.
+const DefaultScriptMaxSteps = 1_000_000
+
+var ErrEmptyScript = errors.New("empty script")
+
+func (s *Script) Validate() error {
+	if strings.TrimSpace(s.Source) == "" {
+		return ErrEmptyScript
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package config_test
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim/config"
+)
+
+func TestScript(t *testing.T) {
+	f := func(s config.Script, expect error) {
+		t.Helper()
+		err := config.Validate(config.Config{
+			Resources: []config.Resource{{Effect: &config.Effect{Script: &s}}},
+		})
+		if expect == nil {
+			require.NoError(t, err)
+			return
+		}
+		require.ErrorIs(t, err, expect)
+	}
+	f(config.Script{Source: `
+def simulate(request):
+    if random() < 0.5:
+        return {"status": 503}
+`}, nil)
+	f(config.Script{Source: "def simulate(request): pass", MaxSteps: 100}, nil)
+	// The source is validated by the script engine.
+	f(config.Script{Source: "def simulate(request)"}, nil)
+
+	f(config.Script{}, config.ErrEmptyScript)
+	f(config.Script{Source: " \n"}, config.ErrEmptyScript)
+}
+
+func TestLoadScript(t *testing.T) {
+	c, err := config.Load(strings.NewReader(`
+resources:
+  - path: /checkout
+    effect:
+      script:
+        source: |
+          def simulate(request):
+              if request.headers.get("X-Tier") == "free":
+                  return {"delay": "2s", "status": 429}
+`))
+	require.NoError(t, err)
+	require.Contains(t, c.Resources[0].Effect.Script.Source, "def simulate(request):")
+}
+
+func TestLoadScriptValidator(t *testing.T) {
+	errInvalid := errors.New("invalid")
+	validator := config.WithScriptValidator(func(s *config.Script) error {
+		if strings.Contains(s.Source, "invalid") {
+			return errInvalid
+		}
+		return nil
+	})
+	dir := TmpFiles(t, map[string]string{
+		"main.yaml": `
+include: [inc.yaml]
+resources:
+  - path: /valid
+    effect:
+      script:
+        source: valid
+`,
+		"inc.yaml": `
+resources:
+  - path: /a
+    effect: {delay: {min: 1s, max: 1s}}
+  - path: /b
+    effects:
+      - script:
+          source: invalid
+`,
+	})
+	file := filepath.Join(dir, "main.yaml")
+	_, err := config.LoadFile(file)
+	require.NoError(t, err)
+
+	_, err = config.LoadFile(file, validator)
+	require.ErrorIs(t, err, errInvalid)
+	var e *config.ErrValidation
+	require.ErrorAs(t, err, &e)
+	require.Equal(t, filepath.Join(dir, "inc.yaml"), e.File)
+	require.Equal(t, "resources[1].effects[0].script", e.Path)
+	require.Equal(t, 8, e.Line)
+
+	_, err = config.Load(strings.NewReader(`
+resources:
+  - path: /valid
+    effect:
+      script:
+        source: valid
+`), validator)
+	require.NoError(t, err)
+}
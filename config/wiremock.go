@@ -0,0 +1,839 @@
+package config
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gobwas/glob"
+)
+
+var ErrWireMockUnsupported = errors.New("unsupported WireMock feature")
+
+// FromWireMock converts the WireMock JSON stub mappings in the mappings
+// directory of the WireMock root dir into resources replacing responses,
+// ordered by mapping priority. Body files are resolved relative to
+// the __files directory of dir. Since httpsim matches headers only if
+// they're present, stubs also match requests lacking the headers they expect.
+// Mappings using features without an equivalent, such as body patterns
+// or stateful scenarios, are rejected with ErrWireMockUnsupported.
+// Returns *ErrOpen if a file can't be read, *ErrDecode if a mapping
+// is invalid or unsupported and *ErrValidation if the result is invalid.
+func FromWireMock(dir string) (*Config, error) {
+	mappingsDir := filepath.Join(dir, "mappings")
+	var files []string
+	err := filepath.WalkDir(mappingsDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && strings.EqualFold(filepath.Ext(path), ".json") {
+			files = append(files, path) // WalkDir walks in lexical order.
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, &ErrOpen{File: mappingsDir, Err: err}
+	}
+
+	type prioritized struct {
+		priority int64
+		resource Resource
+	}
+	var resources []prioritized
+	names := map[string]bool{}
+	for _, file := range files {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, &ErrOpen{File: file, Err: err}
+		}
+		mappings, err := decodeWireMockMappings(b)
+		if err != nil {
+			return nil, &ErrDecode{File: file, Err: err}
+		}
+		for i, m := range mappings {
+			r, err := m.resource(filepath.Join(dir, "__files"))
+			if err != nil {
+				return nil, &ErrDecode{
+					File: file, Err: fmt.Errorf("mappings[%d]: %w", i, err),
+				}
+			}
+			if m.Name != "" && !names[m.Name] {
+				// WireMock names aren't necessarily unique.
+				r.Name, names[m.Name] = m.Name, true
+			}
+			p := int64(5) // WireMock's default priority.
+			if m.Priority != nil {
+				p = *m.Priority
+			}
+			resources = append(resources, prioritized{priority: p, resource: r})
+		}
+	}
+	slices.SortStableFunc(resources, func(a, b prioritized) int {
+		return cmp.Compare(a.priority, b.priority) // 1 is the highest priority.
+	})
+
+	c := &Config{Resources: make([]Resource, len(resources))}
+	for i, r := range resources {
+		c.Resources[i] = r.resource
+	}
+	if err := Validate(*c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// decodeWireMockMappings decodes a mapping file containing
+// either a single mapping or a list of mappings.
+func decodeWireMockMappings(b []byte) ([]wireMockMapping, error) {
+	var list struct {
+		Mappings []wireMockMapping `json:"mappings"`
+	}
+	if err := json.Unmarshal(b, &list); err != nil {
+		return nil, err
+	}
+	if list.Mappings != nil {
+		return list.Mappings, nil
+	}
+	var m wireMockMapping
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	return []wireMockMapping{m}, nil
+}
+
+type wireMockMapping struct {
+	Name     string           `json:"name"`
+	Priority *int64           `json:"priority"`
+	Request  wireMockRequest  `json:"request"`
+	Response wireMockResponse `json:"response"`
+
+	ScenarioName json.RawMessage `json:"scenarioName"`
+}
+
+type wireMockRequest struct {
+	Method          string                     `json:"method"`
+	URL             string                     `json:"url"`
+	URLPath         string                     `json:"urlPath"`
+	URLPattern      string                     `json:"urlPattern"`
+	URLPathPattern  string                     `json:"urlPathPattern"`
+	URLPathTemplate string                     `json:"urlPathTemplate"`
+	Headers         map[string]wireMockMatcher `json:"headers"`
+	QueryParameters map[string]wireMockMatcher `json:"queryParameters"`
+
+	BodyPatterns   json.RawMessage `json:"bodyPatterns"`
+	Cookies        json.RawMessage `json:"cookies"`
+	BasicAuth      json.RawMessage `json:"basicAuthCredentials"`
+	MultipartParts json.RawMessage `json:"multipartPatterns"`
+	PathParameters json.RawMessage `json:"pathParameters"`
+}
+
+type wireMockMatcher struct {
+	EqualTo         *string `json:"equalTo"`
+	Contains        *string `json:"contains"`
+	Matches         *string `json:"matches"`
+	CaseInsensitive bool    `json:"caseInsensitive"`
+
+	DoesNotMatch json.RawMessage `json:"doesNotMatch"`
+	Absent       json.RawMessage `json:"absent"`
+}
+
+type wireMockResponse struct {
+	Status       int                        `json:"status"`
+	Body         *string                    `json:"body"`
+	JSONBody     json.RawMessage            `json:"jsonBody"`
+	Base64Body   *string                    `json:"base64Body"`
+	BodyFileName *string                    `json:"bodyFileName"`
+	Headers      map[string]json.RawMessage `json:"headers"`
+	ProxyBaseURL string                     `json:"proxyBaseUrl"`
+	Fault        string                     `json:"fault"`
+
+	FixedDelayMilliseconds int64 `json:"fixedDelayMilliseconds"`
+	DelayDistribution      *struct {
+		Type     string  `json:"type"`
+		Lower    int64   `json:"lower"`
+		Upper    int64   `json:"upper"`
+		Median   int64   `json:"median"`
+		Sigma    float64 `json:"sigma"`
+		MaxValue int64   `json:"maxValue"`
+	} `json:"delayDistribution"`
+	ChunkedDribbleDelay *struct {
+		NumberOfChunks int64 `json:"numberOfChunks"`
+		TotalDuration  int64 `json:"totalDuration"`
+	} `json:"chunkedDribbleDelay"`
+
+	Transformers json.RawMessage `json:"transformers"`
+}
+
+func unsupported(feature string) error {
+	return fmt.Errorf("%w: %s", ErrWireMockUnsupported, feature)
+}
+
+// resource converts m into a resource resolving body files relative to filesDir.
+func (m *wireMockMapping) resource(filesDir string) (r Resource, err error) {
+	if m.ScenarioName != nil {
+		return r, unsupported("scenarioName")
+	}
+	if err := m.Request.apply(&r); err != nil {
+		return r, err
+	}
+	e, err := m.Response.effect(filesDir)
+	if err != nil {
+		return r, err
+	}
+	r.Effect = e
+	return r, nil
+}
+
+func (q *wireMockRequest) apply(r *Resource) (err error) {
+	for _, f := range [...]struct {
+		name string
+		set  bool
+	}{
+		{"request.bodyPatterns", q.BodyPatterns != nil},
+		{"request.cookies", q.Cookies != nil},
+		{"request.basicAuthCredentials", q.BasicAuth != nil},
+		{"request.multipartPatterns", q.MultipartParts != nil},
+		{"request.pathParameters", q.PathParameters != nil},
+	} {
+		if f.set {
+			return unsupported(f.name)
+		}
+	}
+	if q.Method != "" && q.Method != "ANY" {
+		r.Methods = []HTTPMethod{HTTPMethod(q.Method)}
+	}
+
+	var path string // Any path by default.
+	switch {
+	case q.URL != "":
+		u, err := url.Parse(q.URL)
+		if err != nil {
+			return fmt.Errorf("request.url: %w", err)
+		}
+		path = glob.QuoteMeta(u.Path)
+		if err := q.applyExactQuery(r, u.Query()); err != nil {
+			return err
+		}
+	case q.URLPath != "":
+		path = glob.QuoteMeta(q.URLPath)
+	case q.URLPathTemplate != "":
+		path = pathTemplate.ReplaceAllStringFunc(q.URLPathTemplate,
+			func(s string) string {
+				if s[0] == '{' {
+					return "*"
+				}
+				return glob.QuoteMeta(s)
+			})
+	case q.URLPattern != "" || q.URLPathPattern != "":
+		// WireMock matches urlPattern against the path and query,
+		// but httpsim matches path regexps against the path only.
+		expr := q.URLPathPattern
+		if expr == "" {
+			expr = q.URLPattern
+		}
+		if err := r.PathRegexp.UnmarshalText([]byte("^(?:" + expr + ")$")); err != nil {
+			return fmt.Errorf("request.urlPattern: %w", err)
+		}
+	}
+	if path != "" {
+		if r.Path, err = NewGlobExpression(path); err != nil {
+			return fmt.Errorf("request.url: %w", err)
+		}
+	}
+
+	for name, m := range q.Headers {
+		feature := "request.headers." + name
+		name = glob.QuoteMeta(http.CanonicalHeaderKey(name))
+		if m.Matches != nil {
+			var re Regexp
+			if err := re.UnmarshalText([]byte("^(?:" + *m.Matches + ")$")); err != nil {
+				return fmt.Errorf("%s: %w", feature, err)
+			}
+			if err := setGlobMap(&r.HeadersRegexp, name, re); err != nil {
+				return fmt.Errorf("%s: %w", feature, err)
+			}
+			continue
+		}
+		value, err := m.glob(feature)
+		if err != nil {
+			return err
+		}
+		if err := setGlobMap(&r.Headers, name, []GlobExpression{value}); err != nil {
+			return fmt.Errorf("%s: %w", feature, err)
+		}
+	}
+	for name, m := range q.QueryParameters {
+		feature := "request.queryParameters." + name
+		if m.Matches != nil {
+			return unsupported(feature + ".matches")
+		}
+		value, err := m.glob(feature)
+		if err != nil {
+			return err
+		}
+		err = setGlobMap(&r.Query, glob.QuoteMeta(name), []GlobExpression{value})
+		if err != nil {
+			return fmt.Errorf("%s: %w", feature, err)
+		}
+		r.QueryRequired = true
+	}
+	return nil
+}
+
+// pathTemplate matches the variables of path templates
+// and the literal segments between them.
+var pathTemplate = regexp.MustCompile(`\{[^}]*\}|[^{]+`)
+
+func (q *wireMockRequest) applyExactQuery(r *Resource, query url.Values) error {
+	for name, values := range query {
+		exprs := make([]GlobExpression, len(values))
+		for i, v := range values {
+			var err error
+			if exprs[i], err = NewGlobExpression(glob.QuoteMeta(v)); err != nil {
+				return fmt.Errorf("request.url: %w", err)
+			}
+		}
+		if err := setGlobMap(&r.Query, glob.QuoteMeta(name), exprs); err != nil {
+			return fmt.Errorf("request.url: %w", err)
+		}
+		r.QueryRequired = true
+	}
+	return nil
+}
+
+// glob returns the glob equivalent of a value matcher.
+func (m *wireMockMatcher) glob(feature string) (GlobExpression, error) {
+	switch {
+	case m.DoesNotMatch != nil:
+		return GlobExpression{}, unsupported(feature + ".doesNotMatch")
+	case m.Absent != nil:
+		return GlobExpression{}, unsupported(feature + ".absent")
+	case m.CaseInsensitive:
+		return GlobExpression{}, unsupported(feature + ".caseInsensitive")
+	case m.EqualTo != nil:
+		return NewGlobExpression(glob.QuoteMeta(*m.EqualTo))
+	case m.Contains != nil:
+		return NewGlobExpression("*" + glob.QuoteMeta(*m.Contains) + "*")
+	}
+	return GlobExpression{}, unsupported(feature)
+}
+
+func setGlobMap[T any](m *GlobMap[T], expression string, value T) error {
+	key, err := NewGlobExpression(expression)
+	if err != nil {
+		return err
+	}
+	if *m == nil {
+		*m = GlobMap[T]{}
+	}
+	(*m)[key] = value
+	return nil
+}
+
+func (s *wireMockResponse) effect(filesDir string) (*Effect, error) {
+	if s.Transformers != nil {
+		return nil, unsupported("response.transformers")
+	}
+	e := &Effect{}
+	if s.ProxyBaseURL != "" {
+		var u URL
+		if err := u.UnmarshalText([]byte(s.ProxyBaseURL)); err != nil {
+			return nil, fmt.Errorf("response.proxyBaseUrl: %w", err)
+		}
+		e.Proxy = &Proxy{URL: u}
+	} else {
+		replace, err := s.replace(filesDir)
+		if err != nil {
+			return nil, err
+		}
+		e.Replace = replace
+	}
+
+	if s.FixedDelayMilliseconds > 0 {
+		d := time.Duration(s.FixedDelayMilliseconds) * time.Millisecond
+		e.Delay = &DurRange{Min: d, Max: d}
+	}
+	if d := s.DelayDistribution; d != nil {
+		var r DurRange
+		switch d.Type {
+		case "uniform":
+			r.Min = time.Duration(d.Lower) * time.Millisecond
+			r.Max = time.Duration(d.Upper) * time.Millisecond
+		case "lognormal":
+			r.Distribution = DistributionLogNormal
+			r.Median = time.Duration(d.Median) * time.Millisecond
+			r.Sigma = d.Sigma
+			r.Max = time.Duration(d.MaxValue) * time.Millisecond
+		default:
+			return nil, unsupported("response.delayDistribution.type " + d.Type)
+		}
+		if e.Delay == nil {
+			e.Delay = &r
+		} else {
+			// WireMock adds up both delays, so does delaying the headers.
+			e.DelayHeaders = &r
+		}
+	}
+	if d := s.ChunkedDribbleDelay; d != nil && d.NumberOfChunks > 0 {
+		total := time.Duration(d.TotalDuration) * time.Millisecond
+		if size := e.Replace.bodySize(); size > 0 {
+			chunk := time.Duration(int64(total) / d.NumberOfChunks)
+			e.Stream = &Stream{
+				ChunkSize:  uint64((size + d.NumberOfChunks - 1) / d.NumberOfChunks),
+				ChunkDelay: DurRange{Min: chunk, Max: chunk},
+			}
+		} else {
+			e.DelayBody = &DurRange{Min: total, Max: total}
+		}
+	}
+
+	switch s.Fault {
+	case "":
+	case "CONNECTION_RESET_BY_PEER", "EMPTY_RESPONSE":
+		e.Drop = &Drop{Rate: 1, Mode: DropModeClose}
+		e.Replace, e.Stream, e.DelayBody = nil, nil, nil // Never written.
+	case "MALFORMED_RESPONSE_CHUNK":
+		e.Malformed = &Malformed{Kind: MalformedTruncatedChunked}
+	case "RANDOM_DATA_THEN_CLOSE":
+		e.Malformed = &Malformed{Kind: MalformedStatusLine}
+	default:
+		return nil, unsupported("response.fault " + s.Fault)
+	}
+	return e, nil
+}
+
+func (s *wireMockResponse) replace(filesDir string) (*Replace, error) {
+	r := &Replace{StatusCode: http.StatusOK}
+	if s.Status != 0 {
+		r.StatusCode = StatusCode(s.Status)
+	}
+	switch {
+	case s.Body != nil:
+		r.Body = s.Body
+	case s.JSONBody != nil:
+		var b bytes.Buffer
+		if err := json.Compact(&b, s.JSONBody); err != nil {
+			return nil, fmt.Errorf("response.jsonBody: %w", err)
+		}
+		body := b.String()
+		r.Body = &body
+	case s.Base64Body != nil:
+		data, err := base64.StdEncoding.DecodeString(*s.Base64Body)
+		if err != nil {
+			return nil, fmt.Errorf("response.base64Body: %w", err)
+		}
+		b := NewBase64(data)
+		r.BodyBase64 = &b
+	case s.BodyFileName != nil:
+		file := *s.BodyFileName
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(filesDir, file)
+		}
+		r.BodyFile = &file
+	}
+	for name, raw := range s.Headers {
+		var values []string
+		if err := json.Unmarshal(raw, &values); err != nil {
+			var value string
+			if err := json.Unmarshal(raw, &value); err != nil {
+				return nil, fmt.Errorf("response.headers.%s: %w", name, err)
+			}
+			values = []string{value}
+		}
+		if r.Headers == nil {
+			r.Headers = map[HeaderName]string{}
+		}
+		r.Headers[HeaderName(name)] = strings.Join(values, ", ")
+	}
+	return r, nil
+}
+
+// bodySize returns the size of the inline body of r or 0 if it has none.
+func (r *Replace) bodySize() int64 {
+	switch {
+	case r == nil:
+		return 0
+	case r.Body != nil:
+		return int64(len(*r.Body))
+	case r.BodyBase64 != nil:
+		return int64(len(r.BodyBase64.data))
+	}
+	return 0
+}
+
+// ToWireMock exports the resources of c, with its scenario and defaults
+// applied, as WireMock stub mappings in the JSON format of the
+// WireMock mappings directory. Mapping priorities preserve the order
+// resources are matched in. Resources with multiple methods are exported
+// as one mapping per method. Resources without effects are skipped since
+// there's no upstream to pass requests through to. Since httpsim matches
+// headers and optional query parameters only if they're present, the
+// stubs are stricter, requiring them to be present.
+// Returns an error wrapping ErrWireMockUnsupported if c uses features
+// without an equivalent, such as hangs, sequences or probabilities.
+func ToWireMock(c Config) ([]byte, error) {
+	if c.GlobalEffect != nil {
+		return nil, wireMockExportErr("global-effect", "")
+	}
+	if c.Connections != nil {
+		return nil, wireMockExportErr("connections", "")
+	}
+	c = c.WithScenario().WithDefaults()
+	mappings := []map[string]any{}
+	for i := range c.Resources {
+		r := &c.Resources[i]
+		path := fmt.Sprintf("resources[%d]", i)
+		if r.Effect == nil && len(r.Effects) < 1 && r.Sequence == nil {
+			continue
+		}
+		request, err := wireMockExportRequest(path, r)
+		if err != nil {
+			return nil, err
+		}
+		response, err := wireMockExportResponse(path, r)
+		if err != nil {
+			return nil, err
+		}
+		methods := []string{"ANY"}
+		if len(r.Methods) > 0 {
+			methods = methods[:0]
+		}
+		for j, m := range r.Methods {
+			name, negated := m.Negated()
+			if negated || name == MethodAny {
+				return nil, wireMockExportErr(fmt.Sprintf("%s.methods[%d]", path, j), "")
+			}
+			methods = append(methods, string(name))
+		}
+		for _, method := range methods {
+			req := maps.Clone(request)
+			req["method"] = method
+			m := map[string]any{
+				"priority": len(mappings) + 1,
+				"request":  req,
+				"response": response,
+			}
+			if r.Name != "" {
+				m["name"] = r.Name
+			}
+			mappings = append(mappings, m)
+		}
+	}
+	return json.MarshalIndent(map[string]any{"mappings": mappings}, "", "  ")
+}
+
+func wireMockExportErr(path, feature string) error {
+	if feature != "" {
+		path += "." + feature
+	}
+	return fmt.Errorf("at %s: %w", path, ErrWireMockUnsupported)
+}
+
+func wireMockExportRequest(path string, r *Resource) (map[string]any, error) {
+	switch {
+	case r.Host.String() != "":
+		return nil, wireMockExportErr(path, "host")
+	case r.HeaderCount != nil:
+		return nil, wireMockExportErr(path, "header-count")
+	case r.HeaderBytes != nil:
+		return nil, wireMockExportErr(path, "header-bytes")
+	case r.GraphQL != nil:
+		return nil, wireMockExportErr(path, "graphql")
+	case r.GRPC != nil:
+		return nil, wireMockExportErr(path, "grpc")
+	case r.RangeRequest != nil:
+		return nil, wireMockExportErr(path, "range-request")
+	case r.Conditional != nil:
+		return nil, wireMockExportErr(path, "conditional")
+	case r.KeyBy != nil:
+		return nil, wireMockExportErr(path, "key-by")
+	case r.Sequence != nil:
+		return nil, wireMockExportErr(path, "sequence")
+	case len(r.Effects) > 1:
+		return nil, wireMockExportErr(path, "effects")
+	}
+	request := map[string]any{}
+	if p := r.Path.String(); p != "" {
+		if re, literal := globRegexp(p); literal {
+			request["urlPath"] = unquoteGlob(p)
+		} else {
+			request["urlPathPattern"] = re
+		}
+	}
+	if re := r.PathRegexp.Regexp(); re != nil {
+		if len(request) > 0 {
+			// WireMock doesn't support matching both.
+			return nil, wireMockExportErr(path, "path-regexp")
+		}
+		request["urlPathPattern"] = ".*(?:" + re.String() + ").*"
+	}
+
+	headers := map[string]any{}
+	for name, values := range r.Headers {
+		p := fmt.Sprintf("%s.headers[%s]", path, name)
+		if _, literal := globRegexp(name.String()); !literal || len(values) != 1 {
+			return nil, wireMockExportErr(p, "")
+		}
+		headers[unquoteGlob(name.String())] = wireMockValueMatcher(values[0])
+	}
+	for name, re := range r.HeadersRegexp {
+		key := unquoteGlob(name.String())
+		if _, literal := globRegexp(name.String()); !literal || headers[key] != nil {
+			return nil, wireMockExportErr(fmt.Sprintf("%s.headers-regexp[%s]", path, name), "")
+		}
+		headers[key] = map[string]string{"matches": ".*(?:" + re.String() + ").*"}
+	}
+	if len(headers) > 0 {
+		request["headers"] = headers
+	}
+
+	query := map[string]any{}
+	for name, values := range r.Query {
+		p := fmt.Sprintf("%s.query[%s]", path, name)
+		if _, literal := globRegexp(name.String()); !literal || len(values) != 1 {
+			return nil, wireMockExportErr(p, "")
+		}
+		query[unquoteGlob(name.String())] = wireMockValueMatcher(values[0])
+	}
+	if len(query) > 0 {
+		request["queryParameters"] = query
+	}
+	return request, nil
+}
+
+// wireMockValueMatcher returns the WireMock matcher equivalent to value.
+func wireMockValueMatcher(value GlobExpression) map[string]string {
+	if re, literal := globRegexp(value.String()); !literal {
+		return map[string]string{"matches": re}
+	}
+	return map[string]string{"equalTo": unquoteGlob(value.String())}
+}
+
+func wireMockExportResponse(path string, r *Resource) (map[string]any, error) {
+	e := r.Effect
+	if e == nil {
+		e = &r.Effects[0]
+		path += ".effects[0]"
+	} else {
+		path += ".effect"
+	}
+	for _, f := range [...]struct {
+		name string
+		set  bool
+	}{
+		{"probability", e.Probability != nil && *e.Probability < 1},
+		{"after-matches", e.AfterMatches > 0},
+		{"bursts", e.Bursts != nil},
+		{"delay-headers", e.DelayHeaders != nil && e.Delay != nil},
+		{"delay-body", e.DelayBody != nil && e.DelayAfterHeaders != nil},
+		{"replace-weighted", e.ReplaceWeighted != nil},
+		{"grpc-status", e.GRPCStatus != nil},
+		{"not-modified", e.NotModified != nil},
+		{"partial-content", e.PartialContent != nil},
+		{"record", e.Record != nil},
+		{"replay", e.Replay != nil},
+		{"websocket", e.WebSocket != nil},
+		{"cors", e.CORS != nil},
+		{"keep-alive", e.KeepAlive != nil},
+		{"cdn", e.CDN != nil},
+		{"retry-storm", e.RetryStorm != nil},
+		{"script", e.Script != nil},
+		{"dns", e.DNS != nil},
+		{"dial", e.Dial != nil},
+		{"tls", e.TLS != nil},
+		{"response-headers", e.ResponseHeaders != nil},
+		{"rewrite", e.Rewrite != nil},
+		{"pad", e.Pad != nil},
+		{"throughput", e.Throughput != nil},
+		{"stream", e.Stream != nil},
+		{"hang", e.Hang != nil},
+		{"reset", e.Reset != nil},
+		{"max-concurrent", e.MaxConcurrent != nil},
+		{"drop", e.Drop != nil && (e.Drop.Rate < 1 || e.Drop.Mode == DropModeHang)},
+	} {
+		if f.set {
+			return nil, wireMockExportErr(path, f.name)
+		}
+	}
+
+	response := map[string]any{"status": http.StatusOK}
+	headers := map[string]any{}
+	switch {
+	case e.Proxy != nil:
+		response = map[string]any{"proxyBaseUrl": e.Proxy.URL.String()}
+	case e.Redirect != nil:
+		response["status"] = int(e.Redirect.StatusCode)
+		headers["Location"] = e.Redirect.Location
+	case e.Replace != nil:
+		s := e.Replace
+		if s.Compress != nil {
+			return nil, wireMockExportErr(path, "replace.compress")
+		}
+		if s.CacheHeaders != nil {
+			return nil, wireMockExportErr(path, "replace.cache-headers")
+		}
+		if len(s.Variants) > 0 {
+			return nil, wireMockExportErr(path, "replace.variants")
+		}
+		response["status"] = int(s.StatusCode)
+		switch {
+		case s.Body != nil:
+			response["body"] = *s.Body
+		case s.BodyBase64 != nil:
+			response["base64Body"] = s.BodyBase64.String()
+		case s.BodyFile != nil:
+			response["bodyFileName"] = *s.BodyFile
+		}
+		for name, value := range s.Headers {
+			headers[string(name)] = value
+		}
+		var cookies []string
+		for _, c := range s.Cookies {
+			cookies = append(cookies, c.HTTPCookie(nil).String())
+		}
+		if len(cookies) > 0 {
+			headers["Set-Cookie"] = cookies
+		}
+	}
+	if len(headers) > 0 {
+		response["headers"] = headers
+	}
+
+	delay := e.Delay
+	if delay == nil {
+		delay = e.DelayHeaders
+	}
+	if delay != nil {
+		uniform := delay.Distribution == "" || delay.Distribution == DistributionUniform
+		switch {
+		case uniform && delay.Min == delay.Max:
+			response["fixedDelayMilliseconds"] = delay.Min.Milliseconds()
+		case uniform:
+			response["delayDistribution"] = map[string]any{
+				"type":  "uniform",
+				"lower": delay.Min.Milliseconds(),
+				"upper": delay.Max.Milliseconds(),
+			}
+		case delay.Distribution == DistributionLogNormal:
+			d := map[string]any{
+				"type":   "lognormal",
+				"median": delay.Median.Milliseconds(),
+				"sigma":  delay.Sigma,
+			}
+			if delay.Max > 0 {
+				d["maxValue"] = delay.Max.Milliseconds()
+			}
+			response["delayDistribution"] = d
+		default:
+			return nil, wireMockExportErr(path, "delay.distribution")
+		}
+	}
+	if body := e.DelayBody; body != nil || e.DelayAfterHeaders != nil {
+		if body == nil {
+			body = e.DelayAfterHeaders
+		}
+		if body.Min != body.Max ||
+			body.Distribution != "" && body.Distribution != DistributionUniform {
+			return nil, wireMockExportErr(path, "delay-body")
+		}
+		response["chunkedDribbleDelay"] = map[string]any{
+			"numberOfChunks": 1, "totalDuration": body.Min.Milliseconds(),
+		}
+	}
+
+	switch {
+	case e.Drop != nil:
+		response["fault"] = "CONNECTION_RESET_BY_PEER"
+	case e.Malformed == nil:
+	case e.Malformed.Kind == MalformedTruncatedChunked:
+		response["fault"] = "MALFORMED_RESPONSE_CHUNK"
+	case e.Malformed.Kind == MalformedStatusLine:
+		response["fault"] = "RANDOM_DATA_THEN_CLOSE"
+	default:
+		return nil, wireMockExportErr(path, "malformed.kind")
+	}
+	return response, nil
+}
+
+// globRegexp converts glob expression to an equivalent regular expression
+// matching the whole input and returns true if expression has no wildcards.
+func globRegexp(expression string) (re string, literal bool) {
+	var b strings.Builder
+	literal, braces := true, 0
+	for i := 0; i < len(expression); i++ {
+		switch expression[i] {
+		case '\\':
+			if i+1 < len(expression) {
+				i++
+				b.WriteString(regexp.QuoteMeta(expression[i : i+1]))
+			}
+		case '*':
+			literal = false
+			b.WriteString(".*")
+			for i+1 < len(expression) && expression[i+1] == '*' {
+				i++
+			}
+		case '?':
+			literal = false
+			b.WriteString(".")
+		case '[':
+			end := strings.IndexByte(expression[i:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			literal = false
+			class := expression[i+1 : i+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i += end
+		case '{':
+			literal = false
+			braces++
+			b.WriteString("(?:")
+		case ',':
+			if braces > 0 {
+				b.WriteString("|")
+			} else {
+				b.WriteString(",")
+			}
+		case '}':
+			if braces > 0 {
+				braces--
+				b.WriteString(")")
+			} else {
+				b.WriteString(`\}`)
+			}
+		default:
+			b.WriteString(regexp.QuoteMeta(expression[i : i+1]))
+		}
+	}
+	return b.String(), literal
+}
+
+// unquoteGlob returns literal glob expression without escapes.
+func unquoteGlob(expression string) string {
+	var b strings.Builder
+	for i := 0; i < len(expression); i++ {
+		if expression[i] == '\\' && i+1 < len(expression) {
+			i++
+		}
+		b.WriteByte(expression[i])
+	}
+	return b.String()
+}
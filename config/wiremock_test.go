@@ -0,0 +1,334 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim/config"
+)
+
+func TestFromWireMock(t *testing.T) {
+	dir := TmpFiles(t, map[string]string{
+		"mappings/users.json": `{
+			"mappings": [
+				{
+					"name": "get-user",
+					"request": {
+						"method": "GET",
+						"urlPathTemplate": "/users/{id}",
+						"headers": {
+							"accept": {"equalTo": "application/json"},
+							"Authorization": {"matches": "Bearer .+"}
+						}
+					},
+					"response": {
+						"status": 200,
+						"jsonBody": {"id": 1, "name": "Alice"},
+						"headers": {
+							"Content-Type": "application/json",
+							"Vary": ["Accept", "Authorization"]
+						},
+						"delayDistribution": {"type": "uniform", "lower": 10, "upper": 20}
+					}
+				},
+				{
+					"request": {"method": "ANY", "url": "/search?q=a*b"},
+					"response": {"fault": "CONNECTION_RESET_BY_PEER"}
+				}
+			]
+		}`,
+		"mappings/nested/priority.json": `{
+			"priority": 1,
+			"request": {"urlPathPattern": "/api/v[0-9]+/.*"},
+			"response": {
+				"status": 503,
+				"body": "0123456789",
+				"fixedDelayMilliseconds": 500,
+				"chunkedDribbleDelay": {"numberOfChunks": 5, "totalDuration": 1000}
+			}
+		}`,
+		"mappings/file.json": `{
+			"request": {"urlPath": "/download"},
+			"response": {"bodyFileName": "report.csv"}
+		}`,
+		"mappings/ignored.txt": "not a mapping",
+		"__files/report.csv":   "a,b\n",
+	})
+	c, err := config.FromWireMock(dir)
+	require.NoError(t, err)
+	require.Len(t, c.Resources, 4)
+
+	// Sorted by priority first and lexical file order second.
+	pattern := c.Resources[0]
+	require.Equal(t, "^(?:/api/v[0-9]+/.*)$", pattern.PathRegexp.String())
+	require.Equal(t, config.StatusCode(503), pattern.Effect.Replace.StatusCode)
+	require.Equal(t, "0123456789", *pattern.Effect.Replace.Body)
+	require.Equal(t, &config.DurRange{
+		Min: 500 * time.Millisecond, Max: 500 * time.Millisecond,
+	}, pattern.Effect.Delay)
+	require.Equal(t, &config.Stream{
+		ChunkSize: 2,
+		ChunkDelay: config.DurRange{
+			Min: 200 * time.Millisecond, Max: 200 * time.Millisecond,
+		},
+	}, pattern.Effect.Stream)
+
+	file := c.Resources[1]
+	require.Equal(t, "/download", file.Path.String())
+	require.Equal(t, filepath.Join(dir, "__files", "report.csv"),
+		*file.Effect.Replace.BodyFile)
+	require.Equal(t, config.StatusCode(200), file.Effect.Replace.StatusCode)
+
+	user := c.Resources[2]
+	require.Equal(t, "get-user", user.Name)
+	require.Equal(t, []config.HTTPMethod{"GET"}, user.Methods)
+	require.Equal(t, "/users/*", user.Path.String())
+	require.True(t, user.Path.Match("/users/42"))
+	require.Len(t, user.Headers, 1)
+	for name, values := range user.Headers {
+		require.Equal(t, "Accept", name.String())
+		require.Len(t, values, 1)
+		require.Equal(t, "application/json", values[0].String())
+	}
+	require.Len(t, user.HeadersRegexp, 1)
+	for name, re := range user.HeadersRegexp {
+		require.Equal(t, "Authorization", name.String())
+		require.Equal(t, "^(?:Bearer .+)$", re.String())
+	}
+	require.Equal(t, `{"id":1,"name":"Alice"}`, *user.Effect.Replace.Body)
+	require.Equal(t, map[config.HeaderName]string{
+		"Content-Type": "application/json",
+		"Vary":         "Accept, Authorization",
+	}, user.Effect.Replace.Headers)
+	require.Equal(t, &config.DurRange{
+		Min: 10 * time.Millisecond, Max: 20 * time.Millisecond,
+	}, user.Effect.Delay)
+
+	search := c.Resources[3]
+	require.Empty(t, search.Methods)
+	require.Equal(t, "/search", search.Path.String())
+	require.True(t, search.QueryRequired)
+	require.Len(t, search.Query, 1)
+	for name, values := range search.Query {
+		require.Equal(t, "q", name.String())
+		require.Len(t, values, 1)
+		require.True(t, values[0].Match("a*b"))
+		require.False(t, values[0].Match("axxb"))
+	}
+	require.Equal(t, &config.Drop{Rate: 1, Mode: config.DropModeClose},
+		search.Effect.Drop)
+}
+
+func TestFromWireMockErr(t *testing.T) {
+	f := func(mapping string, check func(*testing.T, error)) {
+		t.Helper()
+		dir := TmpFiles(t, map[string]string{"mappings/m.json": mapping})
+		c, err := config.FromWireMock(dir)
+		require.Nil(t, c)
+		check(t, err)
+	}
+	unsupported := func(t *testing.T, err error) {
+		t.Helper()
+		require.ErrorIs(t, err, config.ErrWireMockUnsupported)
+		var d *config.ErrDecode
+		require.ErrorAs(t, err, &d)
+		require.Equal(t, "m.json", filepath.Base(d.File))
+	}
+
+	f(`{"request": {"bodyPatterns": [{"contains": "x"}]}, "response": {}}`,
+		unsupported)
+	f(`{"scenarioName": "s", "request": {}, "response": {}}`, unsupported)
+	f(`{"request": {"headers": {"X": {"absent": true}}}, "response": {}}`,
+		unsupported)
+	f(`{"request": {"queryParameters": {"q": {"matches": ".*"}}}, "response": {}}`,
+		unsupported)
+	f(`{"request": {}, "response": {"fault": "UNKNOWN"}}`, unsupported)
+	f(`{"request": {}, "response": {"transformers": ["response-template"]}}`,
+		unsupported)
+	f(`{"request": {}, "response": {"status": 42}}`, func(t *testing.T, err error) {
+		require.ErrorIs(t, err, config.ErrInvalidStatusCode)
+		var v *config.ErrValidation
+		require.ErrorAs(t, err, &v)
+		require.Equal(t, "resources[0].effect.replace.status-code", v.Path)
+	})
+	f(`{invalid`, func(t *testing.T, err error) {
+		var d *config.ErrDecode
+		require.ErrorAs(t, err, &d)
+	})
+
+	_, err := config.FromWireMock(t.TempDir())
+	require.ErrorIs(t, err, os.ErrNotExist)
+	var o *config.ErrOpen
+	require.ErrorAs(t, err, &o)
+}
+
+func TestToWireMock(t *testing.T) {
+	c, err := config.Load(strings.NewReader(`
+defaults:
+  replace-headers:
+    X-Simulated: "true"
+resources:
+  - name: users
+    methods: [GET, HEAD]
+    path: /users/{a,b}*
+    headers:
+      Accept: [application/json]
+    query:
+      page: ["1"]
+    effect:
+      delay: 10ms-20ms
+      replace:
+        status-code: 200
+        body: '{"id":1}'
+        cookies:
+          - name: session
+            value: x
+  - path: /passthrough
+  - path: /reset\*
+    effect:
+      delay: 5ms
+      drop: {rate: 1}
+`))
+	require.NoError(t, err)
+	b, err := config.ToWireMock(*c)
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"mappings": [
+			{
+				"name": "users",
+				"priority": 1,
+				"request": {
+					"method": "GET",
+					"urlPathPattern": "/users/(?:a|b).*",
+					"headers": {"Accept": {"equalTo": "application/json"}},
+					"queryParameters": {"page": {"equalTo": "1"}}
+				},
+				"response": {
+					"status": 200,
+					"body": "{\"id\":1}",
+					"headers": {
+						"X-Simulated": "true",
+						"Set-Cookie": ["session=x"]
+					},
+					"delayDistribution": {"type": "uniform", "lower": 10, "upper": 20}
+				}
+			},
+			{
+				"name": "users",
+				"priority": 2,
+				"request": {
+					"method": "HEAD",
+					"urlPathPattern": "/users/(?:a|b).*",
+					"headers": {"Accept": {"equalTo": "application/json"}},
+					"queryParameters": {"page": {"equalTo": "1"}}
+				},
+				"response": {
+					"status": 200,
+					"body": "{\"id\":1}",
+					"headers": {
+						"X-Simulated": "true",
+						"Set-Cookie": ["session=x"]
+					},
+					"delayDistribution": {"type": "uniform", "lower": 10, "upper": 20}
+				}
+			},
+			{
+				"priority": 3,
+				"request": {"method": "ANY", "urlPath": "/reset*"},
+				"response": {
+					"status": 200,
+					"fixedDelayMilliseconds": 5,
+					"fault": "CONNECTION_RESET_BY_PEER"
+				}
+			}
+		]
+	}`, string(b))
+
+	// The export can be imported again.
+	dir := TmpFiles(t, map[string]string{"mappings/export.json": string(b)})
+	imported, err := config.FromWireMock(dir)
+	require.NoError(t, err)
+	require.Len(t, imported.Resources, 3)
+	require.Equal(t, "^(?:/users/(?:a|b).*)$", imported.Resources[0].PathRegexp.String())
+	require.True(t, imported.Resources[2].Path.Match("/reset*"))
+	require.False(t, imported.Resources[2].Path.Match("/reset/x"))
+}
+
+func TestToWireMockErrUnsupported(t *testing.T) {
+	f := func(src, expectPath string) {
+		t.Helper()
+		c, err := config.Load(strings.NewReader(src))
+		require.NoError(t, err)
+		_, err = config.ToWireMock(*c)
+		require.ErrorIs(t, err, config.ErrWireMockUnsupported)
+		require.ErrorContains(t, err, "at "+expectPath+":")
+	}
+	f(`
+resources:
+  - effect: {hang: {}}
+`, "resources[0].effect.hang")
+	f(`
+resources:
+  - path: /a
+  - effect: {probability: 0.5, delay: 1s}
+`, "resources[1].effect.probability")
+	f(`
+resources:
+  - methods: ["!GET"]
+    effect: {delay: 1s}
+`, "resources[0].methods[0]")
+	f(`
+resources:
+  - headers: {"X-*": [a]}
+    effect: {delay: 1s}
+`, "resources[0].headers[X-*]")
+	f(`
+resources:
+  - graphql: {operation-type: mutation}
+    effect: {delay: 1s}
+`, "resources[0].graphql")
+	f(`
+resources:
+  - host: api.example.com
+    effect: {delay: 1s}
+`, "resources[0].host")
+	f(`
+resources:
+  - effect: {dns: {failure: nxdomain}}
+`, "resources[0].effect.dns")
+	f(`
+resources:
+  - effect: {dial: {failure: refused}}
+`, "resources[0].effect.dial")
+	f(`
+resources:
+  - effect: {tls: {failure: expired}}
+`, "resources[0].effect.tls")
+	f(`
+resources:
+  - effect: {keep-alive: {rate: 0.5}}
+`, "resources[0].effect.keep-alive")
+	f(`
+resources:
+  - effect: {cdn: {ttl: 1m}}
+`, "resources[0].effect.cdn")
+	f(`
+resources:
+  - effect: {retry-storm: {window: 10s}}
+`, "resources[0].effect.retry-storm")
+	f(`
+resources:
+  - effect: {script: {source: "def simulate(request): pass"}}
+`, "resources[0].effect.script")
+	f(`
+global-effect: {delay: 1s}
+`, "global-effect")
+	f(`
+connections: {reset: {after-bytes: 10}}
+`, "connections")
+}
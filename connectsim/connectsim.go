@@ -0,0 +1,110 @@
+// Package connectsim simulates faults in Connect servers using httpsim
+// configs.
+//
+// Every call is matched by the config like its HTTP request: a request
+// to the path /package.Service/Method with the request headers and the
+// query parameters of GET requests. Delays are injected before the handler
+// is invoked, replaced responses fail the call with the code of the
+// grpc-status effect or the Connect code corresponding to the HTTP status
+// code, and dropped connections and hangs fail it with unavailable or
+// the error of the context. Effects on the responses of calls passed
+// through, such as throttling, don't apply.
+package connectsim
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+
+	"connectrpc.com/connect"
+
+	"github.com/romshark/httpsim"
+	"github.com/romshark/httpsim/internal/intercept"
+)
+
+// Interceptor is a connect.Interceptor simulating the calls of handlers.
+// Clients are unaffected.
+type Interceptor struct{ m *httpsim.Middleware }
+
+var _ connect.Interceptor = Interceptor{}
+
+// NewInterceptor returns an interceptor simulating calls by m.
+// The handlers receive a context carrying the httpsim.CtxInfo,
+// see httpsim.CtxInfoValue.
+// m may be created with a nil next handler.
+func NewInterceptor(m *httpsim.Middleware) Interceptor { return Interceptor{m: m} }
+
+func (i Interceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		if req.Spec().IsClient {
+			return next(ctx, req)
+		}
+		ctx, err := i.simulate(ctx, req.Spec(), req.Peer(), req.HTTPMethod(), req.Header())
+		switch {
+		case err != nil:
+			return nil, err
+		case ctx == nil:
+			// Unary calls must respond with a message.
+			return nil, connect.NewError(connect.CodeInternal,
+				errors.New("httpsim: grpc-status 0 without a response message"))
+		}
+		return next(ctx, req)
+	}
+}
+
+func (i Interceptor) WrapStreamingClient(
+	next connect.StreamingClientFunc,
+) connect.StreamingClientFunc {
+	return next
+}
+
+func (i Interceptor) WrapStreamingHandler(
+	next connect.StreamingHandlerFunc,
+) connect.StreamingHandlerFunc {
+	return func(ctx context.Context, conn connect.StreamingHandlerConn) error {
+		ctx, err := i.simulate(
+			ctx, conn.Spec(), conn.Peer(), http.MethodPost, conn.RequestHeader(),
+		)
+		if err != nil || ctx == nil {
+			return err
+		}
+		return next(ctx, conn)
+	}
+}
+
+// simulate serves the request of the call by m and returns the context
+// the call continues with or the error the call fails with.
+// Both are nil if the call was replaced with the OK status,
+// ending it without messages.
+func (i Interceptor) simulate(
+	ctx context.Context, spec connect.Spec, peer connect.Peer,
+	method string, header http.Header,
+) (context.Context, error) {
+	r := &http.Request{
+		Method:     method,
+		URL:        &url.URL{Path: spec.Procedure, RawQuery: peer.Query.Encode()},
+		RequestURI: spec.Procedure,
+		Header:     header.Clone(),
+		Body:       http.NoBody,
+		RemoteAddr: peer.Addr,
+	}
+	if r.URL.RawQuery != "" {
+		r.RequestURI += "?" + r.URL.RawQuery
+	}
+	res := intercept.Serve(i.m, r.WithContext(ctx))
+	switch {
+	case res.Next != nil:
+		return res.Next, nil
+	case res.StatusCode != 0:
+		code, msg := res.Status()
+		if code == 0 {
+			return nil, nil
+		}
+		return nil, connect.NewError(connect.Code(code), errors.New(msg))
+	case ctx.Err() != nil:
+		return nil, ctx.Err() // Translated to canceled or deadline_exceeded.
+	}
+	return nil, connect.NewError(connect.CodeUnavailable,
+		errors.New("httpsim: connection dropped"))
+}
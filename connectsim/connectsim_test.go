@@ -0,0 +1,135 @@
+package connectsim_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/romshark/httpsim"
+	"github.com/romshark/httpsim/connectsim"
+)
+
+const (
+	procedureEcho   = "/test.v1.EchoService/Echo"
+	procedureStream = "/test.v1.EchoService/Stream"
+)
+
+func NewServer(t *testing.T, conf string, info chan<- httpsim.CtxInfo) string {
+	t.Helper()
+	c, err := httpsim.LoadConfig(strings.NewReader(conf))
+	require.NoError(t, err)
+	m := httpsim.NewMiddleware(nil, *c, httpsim.DefaultSleep, nil)
+	interceptors := connect.WithInterceptors(connectsim.NewInterceptor(m))
+
+	mux := http.NewServeMux()
+	mux.Handle(procedureEcho, connect.NewUnaryHandler(procedureEcho,
+		func(
+			ctx context.Context, r *connect.Request[wrapperspb.StringValue],
+		) (*connect.Response[wrapperspb.StringValue], error) {
+			info <- httpsim.CtxInfoValue(ctx)
+			return connect.NewResponse(r.Msg), nil
+		}, interceptors))
+	mux.Handle(procedureStream, connect.NewServerStreamHandler(procedureStream,
+		func(
+			ctx context.Context, r *connect.Request[wrapperspb.StringValue],
+			s *connect.ServerStream[wrapperspb.StringValue],
+		) error {
+			info <- httpsim.CtxInfoValue(ctx)
+			return s.Send(r.Msg)
+		}, interceptors))
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func TestInterceptorUnary(t *testing.T) {
+	info := make(chan httpsim.CtxInfo, 1)
+	url := NewServer(t, `
+resources:
+  - path: /test.v1.EchoService/*
+    headers:
+      X-Fail: ["grpc"]
+    effect:
+      grpc-status:
+        code: 8
+        message: quota exceeded
+  - path: /test.v1.EchoService/*
+    headers:
+      X-Fail: ["http"]
+    effect:
+      replace:
+        status-code: 503
+  - path: /test.v1.EchoService/Echo
+    effect:
+      delay: 20ms
+`, info)
+	client := connect.NewClient[wrapperspb.StringValue, wrapperspb.StringValue](
+		http.DefaultClient, url+procedureEcho,
+	)
+	call := func(fail string) (*connect.Response[wrapperspb.StringValue], error) {
+		req := connect.NewRequest(wrapperspb.String("hello"))
+		req.Header().Set("X-Fail", fail)
+		return client.CallUnary(context.Background(), req)
+	}
+
+	_, err := call("grpc")
+	var connectErr *connect.Error
+	require.True(t, errors.As(err, &connectErr))
+	require.Equal(t, connect.CodeResourceExhausted, connectErr.Code())
+	require.Equal(t, "quota exceeded", connectErr.Message())
+
+	_, err = call("http")
+	require.Equal(t, connect.CodeUnavailable, connect.CodeOf(err))
+
+	start := time.Now()
+	resp, err := call("none")
+	require.NoError(t, err)
+	require.Equal(t, "hello", resp.Msg.GetValue())
+	require.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+	require.Equal(t, 2, (<-info).MatchedResourceIndex)
+}
+
+func TestInterceptorStream(t *testing.T) {
+	info := make(chan httpsim.CtxInfo, 1)
+	url := NewServer(t, `
+resources:
+  - path: /test.v1.EchoService/Stream
+    headers:
+      X-Fail: ["1"]
+    effect:
+      replace:
+        status-code: 403
+  - path: /test.v1.EchoService/Stream
+`, info)
+	client := connect.NewClient[wrapperspb.StringValue, wrapperspb.StringValue](
+		http.DefaultClient, url+procedureStream,
+	)
+	stream := func(fail string) ([]string, error) {
+		req := connect.NewRequest(wrapperspb.String("hello"))
+		req.Header().Set("X-Fail", fail)
+		s, err := client.CallServerStream(context.Background(), req)
+		require.NoError(t, err)
+		defer func() { _ = s.Close() }()
+		var msgs []string
+		for s.Receive() {
+			msgs = append(msgs, s.Msg().GetValue())
+		}
+		return msgs, s.Err()
+	}
+
+	_, err := stream("1")
+	require.Equal(t, connect.CodePermissionDenied, connect.CodeOf(err))
+
+	msgs, err := stream("0")
+	require.NoError(t, err)
+	require.Equal(t, []string{"hello"}, msgs)
+	require.Equal(t, 1, (<-info).MatchedResourceIndex)
+}
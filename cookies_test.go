@@ -0,0 +1,46 @@
+package httpsim_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim/config"
+)
+
+func TestReplaceCookies(t *testing.T) {
+	_, s := NewSimulator(t, config.Config{
+		Resources: []config.Resource{{
+			PathRegexp: NewRegexp(t, `^/login/(?P<user>\w+)$`),
+			Effect: &config.Effect{Replace: &config.Replace{
+				StatusCode: http.StatusOK,
+				Cookies: []config.Cookie{
+					{
+						Name:     "session",
+						Value:    "user-${user}",
+						Path:     "/",
+						MaxAge:   time.Hour,
+						Secure:   true,
+						HTTPOnly: true,
+						SameSite: config.SameSiteStrict,
+					},
+					{Name: "theme", Value: "dark", Domain: "host.io"},
+					{Name: "legacy", MaxAge: -1, SameSite: config.SameSiteLax},
+				},
+			}},
+		}},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("next handler must not be invoked")
+	})
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, NewRequest(t, http.MethodGet, "https://host.io/login/bob", http.NoBody))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, []string{
+		"session=user-bob; Path=/; Max-Age=3600; HttpOnly; Secure; SameSite=Strict",
+		"theme=dark; Domain=host.io",
+		"legacy=; Max-Age=0; SameSite=Lax",
+	}, rec.Header().Values("Set-Cookie"))
+}
@@ -0,0 +1,87 @@
+package httpsim
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/romshark/httpsim/config"
+)
+
+// IsCORSPreflight returns true if r is a CORS preflight request.
+func IsCORSPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions &&
+		r.Header.Get("Origin") != "" &&
+		r.Header.Get("Access-Control-Request-Method") != ""
+}
+
+// cors applies the CORS policy c and returns true
+// if r was a preflight request that was responded to.
+func cors(w http.ResponseWriter, r *http.Request, c *config.CORS) bool {
+	origin := r.Header.Get("Origin")
+	allowed := origin != "" && !c.Deny && corsOriginAllowed(c, origin)
+	if !IsCORSPreflight(r) {
+		if allowed {
+			setCORSOrigin(w.Header(), c, origin)
+			if len(c.ExposeHeaders) > 0 {
+				w.Header().Set("Access-Control-Expose-Headers", joinHeaderNames(c.ExposeHeaders))
+			}
+		}
+		return false
+	}
+	if !allowed {
+		w.WriteHeader(http.StatusForbidden)
+		return true
+	}
+	h := w.Header()
+	setCORSOrigin(h, c, origin)
+	if len(c.AllowMethods) > 0 {
+		h.Set("Access-Control-Allow-Methods", strings.Join(c.AllowMethods, ", "))
+	} else {
+		h.Set("Access-Control-Allow-Methods", r.Header.Get("Access-Control-Request-Method"))
+	}
+	if len(c.AllowHeaders) > 0 {
+		h.Set("Access-Control-Allow-Headers", joinHeaderNames(c.AllowHeaders))
+	} else if v := r.Header.Get("Access-Control-Request-Headers"); v != "" {
+		h.Set("Access-Control-Allow-Headers", v)
+	}
+	if c.MaxAge > 0 {
+		h.Set("Access-Control-Max-Age", strconv.FormatInt(int64(c.MaxAge.Seconds()), 10))
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return true
+}
+
+func corsOriginAllowed(c *config.CORS, origin string) bool {
+	if len(c.AllowOrigins) < 1 {
+		return true
+	}
+	for i := range c.AllowOrigins {
+		if c.AllowOrigins[i].Match(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// setCORSOrigin sets Access-Control-Allow-Origin and -Credentials.
+func setCORSOrigin(h http.Header, c *config.CORS, origin string) {
+	if len(c.AllowOrigins) < 1 && !c.AllowCredentials {
+		h.Set("Access-Control-Allow-Origin", "*")
+		return
+	}
+	// Credentialed and restricted responses must name the origin.
+	h.Set("Access-Control-Allow-Origin", origin)
+	h.Add("Vary", "Origin")
+	if c.AllowCredentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+func joinHeaderNames(names []config.HeaderName) string {
+	s := make([]string, len(names))
+	for i, n := range names {
+		s[i] = string(n)
+	}
+	return strings.Join(s, ", ")
+}
@@ -0,0 +1,107 @@
+package httpsim_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim"
+	"github.com/romshark/httpsim/config"
+)
+
+func TestIsCORSPreflight(t *testing.T) {
+	r := NewRequest(t, http.MethodOptions, "https://api.host.io/", http.NoBody)
+	require.False(t, httpsim.IsCORSPreflight(r))
+	r.Header.Set("Origin", "https://app.host.io")
+	require.False(t, httpsim.IsCORSPreflight(r))
+	r.Header.Set("Access-Control-Request-Method", "PUT")
+	require.True(t, httpsim.IsCORSPreflight(r))
+	r.Method = http.MethodGet
+	require.False(t, httpsim.IsCORSPreflight(r))
+}
+
+func TestCORS(t *testing.T) {
+	f := func(t *testing.T, c config.CORS, r *http.Request,
+		expectStatus int, expectHeader http.Header,
+	) {
+		t.Helper()
+		conf := config.Config{
+			Resources: []config.Resource{{Effect: &config.Effect{CORS: &c}}},
+		}
+		_, s := NewSimulator(t, conf, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, r)
+		require.Equal(t, expectStatus, rec.Code)
+		require.Equal(t, expectHeader, rec.Header())
+	}
+	request := func(method, origin string, header ...string) *http.Request {
+		r := NewRequest(t, method, "https://api.host.io/items", http.NoBody)
+		if origin != "" {
+			r.Header.Set("Origin", origin)
+		}
+		for i := 0; i+1 < len(header); i += 2 {
+			r.Header.Set(header[i], header[i+1])
+		}
+		return r
+	}
+	preflight := func(origin string) *http.Request {
+		return request(http.MethodOptions, origin,
+			"Access-Control-Request-Method", "PUT",
+			"Access-Control-Request-Headers", "X-Token")
+	}
+	strict := config.CORS{
+		AllowOrigins:     []config.GlobExpression{NewGlobExpression(t, "https://*.host.io")},
+		AllowMethods:     []string{"GET", "POST"},
+		AllowHeaders:     []config.HeaderName{"Content-Type", "Authorization"},
+		ExposeHeaders:    []config.HeaderName{"X-Request-Id"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	}
+
+	// Permissive policy echoing the request.
+	f(t, config.CORS{}, preflight("https://app.host.io"),
+		http.StatusNoContent, http.Header{
+			"Access-Control-Allow-Origin":  {"*"},
+			"Access-Control-Allow-Methods": {"PUT"},
+			"Access-Control-Allow-Headers": {"X-Token"},
+		})
+	f(t, config.CORS{}, request(http.MethodGet, "https://app.host.io"),
+		http.StatusOK, http.Header{"Access-Control-Allow-Origin": {"*"}})
+
+	f(t, strict, preflight("https://app.host.io"),
+		http.StatusNoContent, http.Header{
+			"Access-Control-Allow-Origin":      {"https://app.host.io"},
+			"Access-Control-Allow-Credentials": {"true"},
+			"Access-Control-Allow-Methods":     {"GET, POST"},
+			"Access-Control-Allow-Headers":     {"Content-Type, Authorization"},
+			"Access-Control-Max-Age":           {"600"},
+			"Vary":                             {"Origin"},
+		})
+	f(t, strict, request(http.MethodPost, "https://app.host.io"),
+		http.StatusOK, http.Header{
+			"Access-Control-Allow-Origin":      {"https://app.host.io"},
+			"Access-Control-Allow-Credentials": {"true"},
+			"Access-Control-Expose-Headers":    {"X-Request-Id"},
+			"Vary":                             {"Origin"},
+		})
+
+	// Disallowed origins.
+	f(t, strict, preflight("https://evil.io"), http.StatusForbidden, http.Header{})
+	f(t, strict, request(http.MethodGet, "https://evil.io"), http.StatusOK, http.Header{})
+
+	// Denial.
+	f(t, config.CORS{Deny: true}, preflight("https://app.host.io"),
+		http.StatusForbidden, http.Header{})
+	f(t, config.CORS{Deny: true}, request(http.MethodGet, "https://app.host.io"),
+		http.StatusOK, http.Header{})
+
+	// Same-origin and non-preflight OPTIONS requests pass through.
+	f(t, strict, request(http.MethodGet, ""), http.StatusOK, http.Header{})
+	f(t, config.CORS{Deny: true}, request(http.MethodOptions, ""),
+		http.StatusOK, http.Header{})
+}
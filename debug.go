@@ -0,0 +1,50 @@
+package httpsim
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// debugHeaderWriter annotates the response with the decisions
+// of the middleware once the header is written.
+type debugHeaderWriter struct {
+	http.ResponseWriter
+	info *CtxInfo
+
+	// resource is the name or index of the matched resource,
+	// or "global" if only the global effect matched.
+	resource string
+
+	// passedThrough is true once the next handler is invoked,
+	// anything written before is written by the simulator.
+	passedThrough bool
+	wroteHeader   bool
+}
+
+func (w *debugHeaderWriter) WriteHeader(statusCode int) {
+	if !w.wroteHeader && statusCode >= 200 {
+		w.wroteHeader = true
+		h := w.Header()
+		h.Set("X-Httpsim-Resource", w.resource)
+		h.Set("X-Httpsim-Delay", w.info.Delay.String())
+		h.Set("X-Httpsim-Replaced", strconv.FormatBool(!w.passedThrough))
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *debugHeaderWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap allows http.ResponseController to access the underlying writer.
+func (w *debugHeaderWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+// finish writes the header if the next handler wrote nothing.
+func (w *debugHeaderWriter) finish() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+}
@@ -0,0 +1,67 @@
+package httpsim_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim/config"
+)
+
+func TestDebugHeaders(t *testing.T) {
+	body := "replaced"
+	_, s := NewSimulator(t, config.Config{
+		DebugHeaders: true,
+		GlobalEffect: &config.Effect{
+			Delay: &config.DurRange{Min: time.Second, Max: time.Second},
+		},
+		Resources: []config.Resource{
+			{
+				Name: "checkout",
+				Path: NewGlobExpression(t, "/checkout"),
+				Effect: &config.Effect{
+					Delay: &config.DurRange{Min: time.Second, Max: time.Second},
+					Replace: &config.Replace{
+						StatusCode: http.StatusServiceUnavailable, Body: &body,
+					},
+				},
+			},
+			{
+				Path: NewGlobExpression(t, "/silent"),
+				Effect: &config.Effect{
+					Delay: &config.DurRange{Min: time.Second, Max: time.Second},
+				},
+			},
+		},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/silent" {
+			return // Write nothing.
+		}
+		_, _ = w.Write([]byte("ok"))
+	})
+	f := func(path, expectResource, expectDelay, expectReplaced string) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, NewRequest(t, http.MethodGet, "https://host.io"+path, http.NoBody))
+		require.Equal(t, expectResource, rec.Header().Get("X-Httpsim-Resource"))
+		require.Equal(t, expectDelay, rec.Header().Get("X-Httpsim-Delay"))
+		require.Equal(t, expectReplaced, rec.Header().Get("X-Httpsim-Replaced"))
+	}
+	f("/checkout", "checkout", "2s", "true")
+	f("/silent", "1", "2s", "false")
+	f("/other", "global", "1s", "false")
+}
+
+func TestDebugHeadersDisabled(t *testing.T) {
+	_, s := NewSimulator(t, config.Config{
+		Resources: []config.Resource{{Effect: &config.Effect{
+			Delay: &config.DurRange{Min: time.Second, Max: time.Second},
+		}}},
+	}, func(w http.ResponseWriter, r *http.Request) {})
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody))
+	require.Empty(t, rec.Header().Get("X-Httpsim-Resource"))
+}
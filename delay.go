@@ -0,0 +1,123 @@
+package httpsim
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// delayBodyChunks is the number of chunks a body delay is spread across
+// when the size of the body is known.
+const delayBodyChunks = 10
+
+// delayWriter delays writing the status and headers by headers,
+// flushes the headers and delays by afterHeaders if set,
+// and spreads the body delay across the body writes.
+// If the size of the body is unknown (no Content-Length header)
+// then the body delay is applied before the first body write.
+// All delays are added to info once elapsed.
+type delayWriter struct {
+	http.ResponseWriter
+	ctx          context.Context
+	m            *Middleware
+	info         *CtxInfo
+	headers      time.Duration
+	afterHeaders time.Duration
+	body         time.Duration
+
+	wroteHeader bool
+	size        int64 // Expected body size, -1 if unknown.
+	written     int64
+	slept       time.Duration
+
+	// err is the error of the header delays, which is returned
+	// by all subsequent writes.
+	err error
+}
+
+func (w *delayWriter) WriteHeader(statusCode int) {
+	if w.wroteHeader || statusCode < 200 {
+		w.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+	w.wroteHeader = true
+	w.size = -1
+	if cl := w.Header().Get("Content-Length"); cl != "" {
+		if n, err := strconv.ParseInt(cl, 10, 64); err == nil && n > 0 {
+			w.size = n
+		}
+	}
+	if w.err = w.sleep(w.headers); w.err != nil {
+		return // The request is canceled, the header isn't needed anymore.
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+	if w.afterHeaders > 0 {
+		_ = http.NewResponseController(w.ResponseWriter).Flush()
+		w.err = w.sleep(w.afterHeaders)
+	}
+}
+
+// sleep sleeps for d and adds it to the recorded delay of the request.
+func (w *delayWriter) sleep(d time.Duration) error {
+	if err := w.m.sleep(w.ctx, d); err != nil {
+		return err
+	}
+	w.info.Delay += d
+	return nil
+}
+
+func (w *delayWriter) Write(b []byte) (written int, err error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+	if w.body <= w.slept {
+		return w.ResponseWriter.Write(b)
+	}
+	if w.size < 0 {
+		if err := w.sleep(w.body); err != nil {
+			return 0, err
+		}
+		w.slept = w.body
+		return w.ResponseWriter.Write(b)
+	}
+
+	chunk := max(w.size/delayBodyChunks, 1)
+	for len(b) > 0 {
+		n := min(int64(len(b)), chunk-w.written%chunk)
+		// Sleep for the share of the body delay of this chunk.
+		target := time.Duration(float64(w.body) * float64(w.written+n) / float64(w.size))
+		if target := min(target, w.body); target > w.slept {
+			if err := w.sleep(target - w.slept); err != nil {
+				return written, err
+			}
+			w.slept = target
+		}
+		nw, err := w.ResponseWriter.Write(b[:n])
+		written += nw
+		w.written += int64(nw)
+		if err != nil {
+			return written, err
+		}
+		_ = http.NewResponseController(w.ResponseWriter).Flush()
+		b = b[n:]
+	}
+	return written, nil
+}
+
+// FlushError makes sure flushing doesn't bypass the header delay.
+func (w *delayWriter) FlushError() error {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.err != nil {
+		return w.err
+	}
+	return http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap allows http.ResponseController to access the underlying writer.
+func (w *delayWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
@@ -0,0 +1,204 @@
+package httpsim_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim"
+	"github.com/romshark/httpsim/config"
+	"github.com/romshark/httpsim/internal/rand"
+)
+
+// SeqSleep records every sleep.
+type SeqSleep struct{ Sleeps []time.Duration }
+
+func (s *SeqSleep) Sleep(d time.Duration) { s.Sleeps = append(s.Sleeps, d) }
+
+func NewSeqSimulator(
+	t *testing.T, conf config.Config, handlerFunc http.HandlerFunc,
+) (*SeqSleep, *httpsim.Middleware) {
+	t.Helper()
+	require.NoError(t, config.Validate(conf))
+	sleep := new(SeqSleep)
+	seed := httpsim.NewSeed("fedcba9876543210fedcba9876543210")
+	rnd := rand.NewSourceChaCha8(rand.Seed(seed))
+	return sleep, httpsim.NewMiddleware(handlerFunc, conf, sleep, rnd)
+}
+
+func TestDelayHeadersAndBodyReplace(t *testing.T) {
+	body := strings.Repeat("x", 100)
+	conf := config.Config{
+		Resources: []config.Resource{
+			{Effect: &config.Effect{
+				DelayHeaders: &config.DurRange{Min: time.Second, Max: time.Second},
+				DelayBody:    &config.DurRange{Min: 10 * time.Second, Max: 10 * time.Second},
+				Replace:      &config.Replace{StatusCode: http.StatusOK, Body: &body},
+			}},
+		},
+	}
+	sleep, s := NewSeqSimulator(t, conf, func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("next handler must not be invoked")
+	})
+	rec := NewEventRecorder()
+	s.ServeHTTP(rec, NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody))
+
+	require.Equal(t, []time.Duration{
+		time.Second, // Headers.
+		time.Second, time.Second, time.Second, time.Second, time.Second,
+		time.Second, time.Second, time.Second, time.Second, time.Second,
+	}, sleep.Sleeps)
+	chunk := strings.Repeat("x", 10)
+	expectEvents := []string{"header:200"}
+	for range 10 {
+		expectEvents = append(expectEvents, "write:"+chunk, "flush")
+	}
+	require.Equal(t, expectEvents, rec.Events)
+	require.Equal(t, "100", rec.Header().Get("Content-Length"))
+	require.Equal(t, body, rec.Body.String())
+}
+
+func TestDelayBodyDownstream(t *testing.T) {
+	conf := config.Config{
+		Resources: []config.Resource{
+			{Effect: &config.Effect{
+				DelayBody: &config.DurRange{Min: 4 * time.Second, Max: 4 * time.Second},
+			}},
+		},
+	}
+	sleep, s := NewSeqSimulator(t, conf, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte("abc"))
+		_, _ = w.Write([]byte("def"))
+	})
+	rec := NewEventRecorder()
+	s.ServeHTTP(rec, NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody))
+
+	// The body size is unknown, hence the delay is applied before the first write.
+	require.Equal(t, []time.Duration{0, 4 * time.Second}, sleep.Sleeps)
+	require.Equal(t, http.StatusAccepted, rec.Code)
+	require.Equal(t, "abcdef", rec.Body.String())
+}
+
+func TestDelayBodyDownstreamContentLength(t *testing.T) {
+	conf := config.Config{
+		Resources: []config.Resource{
+			{Effect: &config.Effect{
+				DelayBody: &config.DurRange{Min: 2 * time.Second, Max: 2 * time.Second},
+			}},
+		},
+	}
+	sleep, s := NewSeqSimulator(t, conf, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "20")
+		_, _ = w.Write([]byte(strings.Repeat("x", 20)))
+	})
+	rec := NewEventRecorder()
+	s.ServeHTTP(rec, NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody))
+
+	require.Len(t, sleep.Sleeps, 11)
+	var total time.Duration
+	for _, d := range sleep.Sleeps {
+		total += d
+	}
+	require.Equal(t, 2*time.Second, total)
+	require.Equal(t, strings.Repeat("x", 20), rec.Body.String())
+}
+
+func TestDelayHeadersFlush(t *testing.T) {
+	conf := config.Config{
+		Resources: []config.Resource{
+			{Effect: &config.Effect{
+				DelayHeaders: &config.DurRange{Min: time.Second, Max: time.Second},
+			}},
+		},
+	}
+	sleep, s := NewSeqSimulator(t, conf, func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, http.NewResponseController(w).Flush())
+	})
+	rec := NewEventRecorder()
+	s.ServeHTTP(rec, NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody))
+	require.Equal(t, []time.Duration{time.Second}, sleep.Sleeps)
+	require.Equal(t, []string{"header:200", "flush"}, rec.Events)
+}
+
+func TestDelayAfterHeaders(t *testing.T) {
+	body := "body"
+	f := func(t *testing.T, conf config.Config, handler http.HandlerFunc) {
+		t.Helper()
+		sleep, s := NewSeqSimulator(t, conf, handler)
+		rec := NewEventRecorder()
+		s.ServeHTTP(rec, NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody))
+		require.Equal(t, []time.Duration{0, 3 * time.Second}, sleep.Sleeps)
+		require.Equal(t, []string{"header:201", "flush", "write:body"}, rec.Events)
+	}
+	delay := &config.DurRange{Min: 3 * time.Second, Max: 3 * time.Second}
+
+	t.Run("replace", func(t *testing.T) {
+		f(t, config.Config{Resources: []config.Resource{{Effect: &config.Effect{
+			DelayAfterHeaders: delay,
+			Replace:           &config.Replace{StatusCode: http.StatusCreated, Body: &body},
+		}}}}, func(w http.ResponseWriter, r *http.Request) {
+			t.Fatal("next handler must not be invoked")
+		})
+	})
+	t.Run("pass-through", func(t *testing.T) {
+		f(t, config.Config{Resources: []config.Resource{{Effect: &config.Effect{
+			DelayAfterHeaders: delay,
+		}}}}, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(body))
+		})
+	})
+}
+
+func TestDelayRecorded(t *testing.T) {
+	body := strings.Repeat("x", 10)
+	conf := config.Config{
+		DebugHeaders: true,
+		Resources: []config.Resource{{Effect: &config.Effect{
+			DelayHeaders:      &config.DurRange{Min: time.Second, Max: time.Second},
+			DelayAfterHeaders: &config.DurRange{Min: 2 * time.Second, Max: 2 * time.Second},
+			DelayBody:         &config.DurRange{Min: 4 * time.Second, Max: 4 * time.Second},
+			Replace:           &config.Replace{StatusCode: http.StatusOK, Body: &body},
+		}}},
+	}
+	_, s := NewSeqSimulator(t, conf, func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("next handler must not be invoked")
+	})
+	rec := NewEventRecorder()
+	s.ServeHTTP(rec, NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody))
+	require.Equal(t, body, rec.Body.String())
+
+	// Only the header delay elapsed before the header was written.
+	require.Equal(t, "1s", rec.Header().Get("X-Httpsim-Delay"))
+	metrics := s.Metrics()
+	require.Equal(t, uint64(1), metrics[0].DelayCount)
+	require.Equal(t, 7*time.Second, metrics[0].DelaySum)
+}
+
+func TestDelayHeadersCanceled(t *testing.T) {
+	conf := config.Config{
+		Resources: []config.Resource{{Effect: &config.Effect{
+			DelayHeaders: &config.DurRange{Min: time.Second, Max: time.Second},
+		}}},
+	}
+	var writeErr, flushErr error
+	_, s := NewSeqSimulator(t, conf, func(w http.ResponseWriter, r *http.Request) {
+		_, writeErr = w.Write([]byte("body"))
+		flushErr = http.NewResponseController(w).Flush()
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r := NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody).WithContext(ctx)
+	rec := NewEventRecorder()
+	s.ServeHTTP(rec, r)
+
+	require.ErrorIs(t, writeErr, context.Canceled)
+	require.ErrorIs(t, flushErr, context.Canceled)
+	require.Empty(t, rec.Events)
+	require.Zero(t, s.Metrics()[0].DelaySum)
+}
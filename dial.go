@@ -0,0 +1,58 @@
+package httpsim
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+
+	"github.com/romshark/httpsim/config"
+)
+
+// dialConn simulates establishing a connection to the host of r and returns
+// the error the connection attempt fails with, if any, like net.Dialer does.
+func (m *Middleware) dialConn(r *http.Request, c *config.Dial, info *CtxInfo) error {
+	if c.Delay != nil {
+		d := SampleDur(m.rand, c.Delay)
+		info.Delay += d
+		if err := m.sleep(r.Context(), d); err != nil {
+			return err
+		}
+	}
+	e := &net.OpError{Op: "dial", Net: "tcp", Addr: newDialAddr(r)}
+	switch c.Failure {
+	case config.DialFailureRefused:
+		e.Err = os.NewSyscallError("connect", syscall.ECONNREFUSED)
+	case config.DialFailureUnreachable:
+		e.Err = os.NewSyscallError("connect", syscall.EHOSTUNREACH)
+	case config.DialFailureTimeout:
+		if c.Delay == nil {
+			<-r.Context().Done()
+			e.Err = r.Context().Err()
+			return e
+		}
+		e.Err = os.ErrDeadlineExceeded
+	default:
+		return nil
+	}
+	return e
+}
+
+// dialAddr is the address of the host of a request.
+type dialAddr string
+
+var _ net.Addr = dialAddr("")
+
+func (dialAddr) Network() string  { return "tcp" }
+func (a dialAddr) String() string { return string(a) }
+
+func newDialAddr(r *http.Request) dialAddr {
+	port := r.URL.Port()
+	if port == "" {
+		port = "80"
+		if r.URL.Scheme == "https" {
+			port = "443"
+		}
+	}
+	return dialAddr(net.JoinHostPort(Hostname(r), port))
+}
@@ -0,0 +1,91 @@
+package httpsim_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim/config"
+)
+
+func TestDial(t *testing.T) {
+	delay := 3 * time.Second
+	mockSleep, rt := NewRoundTripper(t, config.Config{
+		Resources: []config.Resource{
+			{
+				Host: NewGlobExpression(t, "slow.host.io"),
+				Effect: &config.Effect{Dial: &config.Dial{
+					Delay: &config.DurRange{Min: delay, Max: delay},
+				}},
+			},
+			{
+				Host: NewGlobExpression(t, "down.host.io"),
+				Effect: &config.Effect{Dial: &config.Dial{
+					Failure: config.DialFailureRefused,
+				}},
+			},
+			{
+				Host: NewGlobExpression(t, "gone.host.io"),
+				Effect: &config.Effect{Dial: &config.Dial{
+					Failure: config.DialFailureUnreachable,
+				}},
+			},
+			{
+				Host: NewGlobExpression(t, "blackhole.host.io"),
+				Effect: &config.Effect{Dial: &config.Dial{
+					Delay:   &config.DurRange{Min: delay, Max: delay},
+					Failure: config.DialFailureTimeout,
+				}},
+			},
+			{
+				Host: NewGlobExpression(t, "stall.host.io"),
+				Effect: &config.Effect{Dial: &config.Dial{
+					Failure: config.DialFailureTimeout,
+				}},
+			},
+		},
+	}, upstream(t))
+	client := &http.Client{Transport: rt}
+
+	resp, err := client.Get("https://slow.host.io/a")
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "upstream:/a", string(body))
+	require.Equal(t, delay, mockSleep.Cumulative)
+
+	_, err = client.Get("http://down.host.io:8080/")
+	require.ErrorIs(t, err, syscall.ECONNREFUSED)
+	require.ErrorContains(t, err,
+		"dial tcp down.host.io:8080: connect: connection refused")
+	var opErr *net.OpError
+	require.True(t, errors.As(err, &opErr))
+	require.Equal(t, "dial", opErr.Op)
+
+	_, err = client.Get("https://gone.host.io/")
+	require.ErrorIs(t, err, syscall.EHOSTUNREACH)
+	require.ErrorContains(t, err, "dial tcp gone.host.io:443: connect:")
+
+	mockSleep.Cumulative = 0
+	_, err = client.Get("https://blackhole.host.io/")
+	require.ErrorIs(t, err, os.ErrDeadlineExceeded)
+	var netErr net.Error
+	require.True(t, errors.As(err, &netErr))
+	require.True(t, netErr.Timeout())
+	require.Equal(t, delay, mockSleep.Cumulative)
+
+	// Without a delay the dial stalls until the request is canceled.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	r := NewRequest(t, http.MethodGet, "https://stall.host.io/", http.NoBody)
+	_, err = rt.RoundTrip(r.WithContext(ctx))
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -0,0 +1,61 @@
+package httpsim
+
+import (
+	"math"
+	"time"
+
+	"github.com/romshark/httpsim/config"
+)
+
+// SampleDur returns a random duration from r's distribution
+// clamped to r's bounds.
+func SampleDur(rnd RandProvider, r *config.DurRange) time.Duration {
+	var v float64
+	switch r.Distribution {
+	case config.DistributionNormal:
+		v = float64(r.Mean) + rnd.NormFloat64()*float64(r.StdDev)
+	case config.DistributionExponential:
+		v = float64(r.Min) + rnd.ExpFloat64()*float64(r.Mean)
+	case config.DistributionLogNormal:
+		v = float64(r.Median) * math.Exp(rnd.NormFloat64()*r.Sigma)
+	case config.DistributionPareto:
+		// Inverse transform sampling, 1-Float64 is in (0,1].
+		v = float64(r.Min) / math.Pow(1-rnd.Float64(), 1/r.Shape)
+	case config.DistributionPercentiles:
+		v = samplePercentiles(rnd.Float64()*100, r)
+	default:
+		return rnd.Dur(r.Min, r.Max)
+	}
+	if v < float64(r.Min) {
+		return r.Min
+	}
+	if r.Max != 0 && v > float64(r.Max) {
+		return r.Max
+	}
+	if v >= math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return time.Duration(v)
+}
+
+// samplePercentiles returns the duration at percentile p linearly
+// interpolated between the points of r's percentile profile.
+func samplePercentiles(p float64, r *config.DurRange) float64 {
+	sorted := r.SortedPercentiles()
+	last := sorted[len(sorted)-1].Duration
+	if r.Max != 0 {
+		last = r.Max
+	}
+	sorted = append(sorted, config.PercentileDuration{Percentile: 100, Duration: last})
+
+	prev := config.PercentileDuration{Percentile: 0, Duration: r.Min}
+	for _, next := range sorted {
+		if p <= float64(next.Percentile) {
+			f := (p - float64(prev.Percentile)) /
+				float64(next.Percentile-prev.Percentile)
+			return float64(prev.Duration) + f*float64(next.Duration-prev.Duration)
+		}
+		prev = next
+	}
+	return float64(last)
+}
@@ -0,0 +1,116 @@
+package httpsim_test
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim"
+	"github.com/romshark/httpsim/config"
+	"github.com/romshark/httpsim/internal/rand"
+)
+
+func TestSampleDur(t *testing.T) {
+	const n = 10_000
+	sample := func(r config.DurRange) (samples []time.Duration) {
+		t.Helper()
+		require.NoError(t, r.Validate())
+		rnd := rand.NewSourceChaCha8(rand.NewSeed("0123456789abcdef0123456789abcdef"))
+		samples = make([]time.Duration, n)
+		for i := range samples {
+			samples[i] = httpsim.SampleDur(rnd, &r)
+		}
+		slices.Sort(samples)
+		return samples
+	}
+	mean := func(s []time.Duration) time.Duration {
+		var sum time.Duration
+		for _, d := range s {
+			sum += d
+		}
+		return sum / time.Duration(len(s))
+	}
+	median := func(s []time.Duration) time.Duration { return s[len(s)/2] }
+
+	t.Run("uniform", func(t *testing.T) {
+		s := sample(config.DurRange{Min: time.Second, Max: 2 * time.Second})
+		require.GreaterOrEqual(t, s[0], time.Second)
+		require.Less(t, s[n-1], 2*time.Second)
+		require.InDelta(t, 1500*time.Millisecond, mean(s), float64(50*time.Millisecond))
+	})
+
+	t.Run("normal", func(t *testing.T) {
+		s := sample(config.DurRange{
+			Distribution: config.DistributionNormal,
+			Mean:         100 * time.Millisecond,
+			StdDev:       10 * time.Millisecond,
+		})
+		require.InDelta(t, 100*time.Millisecond, mean(s), float64(time.Millisecond))
+	})
+
+	t.Run("normal_clamped", func(t *testing.T) {
+		s := sample(config.DurRange{
+			Distribution: config.DistributionNormal,
+			Min:          90 * time.Millisecond,
+			Max:          110 * time.Millisecond,
+			Mean:         100 * time.Millisecond,
+			StdDev:       time.Second,
+		})
+		require.Equal(t, 90*time.Millisecond, s[0])
+		require.Equal(t, 110*time.Millisecond, s[n-1])
+	})
+
+	t.Run("exponential", func(t *testing.T) {
+		s := sample(config.DurRange{
+			Distribution: config.DistributionExponential,
+			Min:          10 * time.Millisecond,
+			Mean:         50 * time.Millisecond,
+		})
+		require.GreaterOrEqual(t, s[0], 10*time.Millisecond)
+		require.InDelta(t, 60*time.Millisecond, mean(s), float64(2*time.Millisecond))
+	})
+
+	t.Run("log-normal", func(t *testing.T) {
+		s := sample(config.DurRange{
+			Distribution: config.DistributionLogNormal,
+			Median:       200 * time.Millisecond,
+			Sigma:        0.5,
+		})
+		require.Greater(t, s[0], time.Duration(0))
+		require.InDelta(t, 200*time.Millisecond, median(s), float64(10*time.Millisecond))
+	})
+
+	t.Run("percentiles", func(t *testing.T) {
+		s := sample(config.DurRange{
+			Distribution: config.DistributionPercentiles,
+			Min:          5 * time.Millisecond,
+			Percentiles: map[config.Percentile]time.Duration{
+				50: 20 * time.Millisecond,
+				95: 200 * time.Millisecond,
+				99: 2 * time.Second,
+			},
+		})
+		percentile := func(p float64) time.Duration { return s[int(p*n/100)] }
+		require.GreaterOrEqual(t, s[0], 5*time.Millisecond)
+		require.InDelta(t, 20*time.Millisecond, percentile(50), float64(time.Millisecond))
+		require.InDelta(t, 200*time.Millisecond, percentile(95),
+			float64(20*time.Millisecond))
+		require.InDelta(t, 2*time.Second, percentile(99), float64(200*time.Millisecond))
+		require.Equal(t, 2*time.Second, s[n-1])
+	})
+
+	t.Run("pareto", func(t *testing.T) {
+		s := sample(config.DurRange{
+			Distribution: config.DistributionPareto,
+			Min:          20 * time.Millisecond,
+			Max:          10 * time.Second,
+			Shape:        3,
+		})
+		require.GreaterOrEqual(t, s[0], 20*time.Millisecond)
+		require.LessOrEqual(t, s[n-1], 10*time.Second)
+		// Mean of Pareto is alpha*xm/(alpha-1) = 30ms.
+		require.InDelta(t, 30*time.Millisecond, mean(s), float64(2*time.Millisecond))
+	})
+}
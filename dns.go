@@ -0,0 +1,78 @@
+package httpsim
+
+import (
+	"net"
+	"net/http"
+	"os"
+
+	"github.com/romshark/httpsim/config"
+)
+
+// Hostname returns the host name of r without the port.
+func Hostname(r *http.Request) string {
+	host := r.Host
+	if host == "" && r.URL != nil {
+		host = r.URL.Host
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return h
+	}
+	return host
+}
+
+// dns simulates the resolution of the host name of r and returns
+// the error the dial fails with, if any, like net.Dialer does.
+func (m *Middleware) dns(r *http.Request, c *config.DNS, info *CtxInfo) error {
+	if c.Delay != nil {
+		d := SampleDur(m.rand, c.Delay)
+		info.Delay += d
+		if err := m.sleep(r.Context(), d); err != nil {
+			return err
+		}
+	}
+	e := &net.DNSError{Name: Hostname(r)}
+	switch c.Failure {
+	case config.DNSFailureNXDomain:
+		e.Err, e.IsNotFound = "no such host", true
+	case config.DNSFailureTimeout:
+		e.Err, e.IsTimeout, e.IsTemporary = "i/o timeout", true, true
+	case config.DNSFailureServFail:
+		e.Err, e.IsTemporary = "server misbehaving", true
+	default:
+		return m.staleRecord(r, c.Failover, info)
+	}
+	return &net.OpError{Op: "dial", Net: "tcp", Err: e}
+}
+
+// staleRecord simulates connecting to the dead IP a stale record of
+// the host name of r resolves to during a failover and returns the error
+// the dial fails with, if any.
+func (m *Middleware) staleRecord(
+	r *http.Request, c *config.DNSFailover, info *CtxInfo,
+) error {
+	if c == nil {
+		return nil
+	}
+	failover := c.Failover(m.now().Sub(m.state.Load().start))
+	if failover == 0 || (c.Rate != nil && m.rand.Float64() >= float64(*c.Rate)) {
+		return nil
+	}
+	// Each failover leaves behind a different IP of TEST-NET-1.
+	ip := net.IPv4(192, 0, 2, byte(1+(failover-1)%254))
+	_, port, _ := net.SplitHostPort(string(newDialAddr(r)))
+	e := &net.OpError{
+		Op: "dial", Net: "tcp", Addr: dialAddr(net.JoinHostPort(ip.String(), port)),
+	}
+	if c.Timeout == nil {
+		<-r.Context().Done() // Blackholed.
+		e.Err = r.Context().Err()
+		return e
+	}
+	d := SampleDur(m.rand, c.Timeout)
+	info.Delay += d
+	if err := m.sleep(r.Context(), d); err != nil {
+		return err
+	}
+	e.Err = os.ErrDeadlineExceeded
+	return e
+}
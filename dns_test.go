@@ -0,0 +1,185 @@
+package httpsim_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim"
+	"github.com/romshark/httpsim/config"
+	"github.com/romshark/httpsim/internal/rand"
+)
+
+func TestHostname(t *testing.T) {
+	f := func(host, expect string) {
+		t.Helper()
+		r := NewRequest(t, http.MethodGet, "https://fallback.io/", http.NoBody)
+		r.Host = host
+		require.Equal(t, expect, httpsim.Hostname(r))
+	}
+	f("api.host.io", "api.host.io")
+	f("api.host.io:8080", "api.host.io")
+	f("[::1]:8080", "::1")
+	f("", "fallback.io")
+}
+
+func TestMatchResourceHost(t *testing.T) {
+	c := &config.Resource{Host: NewGlobExpression(t, "*.host.io")}
+	f := func(url string, expect bool) {
+		t.Helper()
+		r := NewRequest(t, http.MethodGet, url, http.NoBody)
+		require.Equal(t, expect, httpsim.MatchResource(r, c))
+	}
+	f("https://api.host.io/", true)
+	f("https://api.host.io:8443/", true)
+	f("https://host.io/", false)
+	f("https://api.other.io/", false)
+}
+
+func TestDNS(t *testing.T) {
+	delay := 2 * time.Second
+	mockSleep, rt := NewRoundTripper(t, config.Config{
+		Resources: []config.Resource{
+			{
+				Host: NewGlobExpression(t, "slow.host.io"),
+				Effect: &config.Effect{DNS: &config.DNS{
+					Delay: &config.DurRange{Min: delay, Max: delay},
+				}},
+			},
+			{
+				Host: NewGlobExpression(t, "gone.host.io"),
+				Effect: &config.Effect{DNS: &config.DNS{
+					Delay:   &config.DurRange{Min: delay, Max: delay},
+					Failure: config.DNSFailureNXDomain,
+				}},
+			},
+			{
+				Host: NewGlobExpression(t, "timeout.host.io"),
+				Effect: &config.Effect{DNS: &config.DNS{
+					Failure: config.DNSFailureTimeout,
+				}},
+			},
+			{
+				Host: NewGlobExpression(t, "flaky.host.io"),
+				Effect: &config.Effect{DNS: &config.DNS{
+					Failure: config.DNSFailureServFail,
+				}},
+			},
+		},
+	}, upstream(t))
+	client := &http.Client{Transport: rt}
+
+	resp, err := client.Get("https://slow.host.io/a")
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "upstream:/a", string(body))
+	require.Equal(t, delay, mockSleep.Cumulative)
+
+	lookup := func(url string) *net.DNSError {
+		t.Helper()
+		_, err := client.Get(url)
+		require.Error(t, err)
+		var dnsErr *net.DNSError
+		require.True(t, errors.As(err, &dnsErr))
+		return dnsErr
+	}
+
+	mockSleep.Cumulative = 0
+	dnsErr := lookup("https://gone.host.io:8443/")
+	require.Equal(t, "gone.host.io", dnsErr.Name)
+	require.True(t, dnsErr.IsNotFound)
+	require.Equal(t, delay, mockSleep.Cumulative)
+	_, err = client.Get("https://gone.host.io/")
+	require.ErrorContains(t, err, "dial tcp: lookup gone.host.io: no such host")
+
+	dnsErr = lookup("https://timeout.host.io/")
+	require.True(t, dnsErr.Timeout())
+
+	dnsErr = lookup("https://flaky.host.io/")
+	require.True(t, dnsErr.Temporary())
+	require.False(t, dnsErr.IsNotFound)
+}
+
+func TestDNSIgnoredByMiddleware(t *testing.T) {
+	conf := config.Config{
+		Resources: []config.Resource{{Effect: &config.Effect{
+			DNS: &config.DNS{Failure: config.DNSFailureNXDomain},
+		}}},
+	}
+	nextInvoked := false
+	_, s := NewSimulator(t, conf, func(w http.ResponseWriter, r *http.Request) {
+		nextInvoked = true
+	})
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody))
+	require.True(t, nextInvoked)
+	require.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestDNSFailover(t *testing.T) {
+	timeout := 3 * time.Second
+	conf := config.Config{
+		Resources: []config.Resource{{Effect: &config.Effect{DNS: &config.DNS{
+			Failover: &config.DNSFailover{
+				Every:   time.Minute,
+				Stale:   10 * time.Second,
+				Timeout: &config.DurRange{Min: timeout, Max: timeout},
+			},
+		}}}},
+	}
+	require.NoError(t, config.Validate(conf))
+	clock := new(ClockSleep)
+	rnd := rand.NewSourceChaCha8(rand.NewSeed("fedcba9876543210fedcba9876543210"))
+	client := &http.Client{Transport: httpsim.NewRoundTripper(upstream(t), conf, clock, rnd)}
+
+	f := func(at time.Duration, expectAddr string) {
+		t.Helper()
+		clock.Cumulative = at
+		resp, err := client.Get("https://api.host.io/")
+		if expectAddr == "" {
+			require.NoError(t, err)
+			require.NoError(t, resp.Body.Close())
+			require.Equal(t, at, clock.Cumulative)
+			return
+		}
+		var opErr *net.OpError
+		require.True(t, errors.As(err, &opErr))
+		require.True(t, opErr.Timeout())
+		require.Equal(t, expectAddr, opErr.Addr.String())
+		require.Equal(t, at+timeout, clock.Cumulative)
+	}
+	f(0, "")
+	f(59*time.Second, "")
+	f(time.Minute, "192.0.2.1:443")
+	f(time.Minute+9*time.Second, "192.0.2.1:443")
+	f(time.Minute+10*time.Second, "")
+	f(2*time.Minute+5*time.Second, "192.0.2.2:443")
+}
+
+func TestDNSFailoverBlackhole(t *testing.T) {
+	conf := config.Config{
+		Resources: []config.Resource{{Effect: &config.Effect{DNS: &config.DNS{
+			Failover: &config.DNSFailover{Every: time.Minute, Stale: time.Minute},
+		}}}},
+	}
+	require.NoError(t, config.Validate(conf))
+	clock := new(ClockSleep)
+	rnd := rand.NewSourceChaCha8(rand.NewSeed("fedcba9876543210fedcba9876543210"))
+	rt := httpsim.NewRoundTripper(upstream(t), conf, clock, rnd)
+	clock.Cumulative = time.Minute
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	r := NewRequest(t, http.MethodGet, "http://api.host.io/", http.NoBody)
+	_, err := rt.RoundTrip(r.WithContext(ctx))
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.ErrorContains(t, err, "dial tcp 192.0.2.1:80")
+}
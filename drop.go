@@ -0,0 +1,21 @@
+package httpsim
+
+import (
+	"net/http"
+
+	"github.com/romshark/httpsim/config"
+)
+
+// drop discards the request without writing a response.
+func (m *Middleware) drop(w http.ResponseWriter, r *http.Request, c *config.Drop) {
+	if c.Mode == config.DropModeHang {
+		m.hang(r.Context(), &config.Hang{})
+	}
+	conn, _, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		// Hijacking isn't supported (for example by HTTP/2),
+		// abort the response instead.
+		panic(http.ErrAbortHandler)
+	}
+	_ = conn.Close()
+}
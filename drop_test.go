@@ -0,0 +1,100 @@
+package httpsim_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim/config"
+)
+
+func TestDropClose(t *testing.T) {
+	conf := config.Config{
+		Resources: []config.Resource{
+			{Effect: &config.Effect{Drop: &config.Drop{Rate: 1}}},
+		},
+	}
+	_, s := NewSimulator(t, conf, func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("next handler must not be invoked")
+	})
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if resp != nil {
+		_ = resp.Body.Close()
+	}
+	require.ErrorIs(t, err, io.EOF)
+}
+
+func TestDropCloseUnsupported(t *testing.T) {
+	conf := config.Config{
+		Resources: []config.Resource{
+			{Effect: &config.Effect{Drop: &config.Drop{Rate: 1}}},
+		},
+	}
+	_, s := NewSimulator(t, conf, func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("next handler must not be invoked")
+	})
+	// httptest.ResponseRecorder doesn't support hijacking.
+	rec := httptest.NewRecorder()
+	require.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		s.ServeHTTP(rec, NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody))
+	})
+	require.Zero(t, rec.Body.Len())
+}
+
+func TestDropHang(t *testing.T) {
+	conf := config.Config{
+		Resources: []config.Resource{
+			{Effect: &config.Effect{Drop: &config.Drop{
+				Rate: 1, Mode: config.DropModeHang,
+			}}},
+		},
+	}
+	_, s := NewSimulator(t, conf, func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("next handler must not be invoked")
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	req := NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody)
+	req = req.WithContext(ctx)
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	rec := httptest.NewRecorder()
+	require.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		s.ServeHTTP(rec, req)
+	})
+	require.Zero(t, rec.Body.Len())
+}
+
+func TestDropRate(t *testing.T) {
+	conf := config.Config{
+		Resources: []config.Resource{
+			{Effect: &config.Effect{Drop: &config.Drop{Rate: 0.3}}},
+		},
+	}
+	passedThrough := 0
+	_, s := NewSimulator(t, conf, func(w http.ResponseWriter, r *http.Request) {
+		passedThrough++
+	})
+	dropped := 0
+	for range 1000 {
+		func() {
+			defer func() {
+				if recover() == http.ErrAbortHandler {
+					dropped++
+				}
+			}()
+			s.ServeHTTP(httptest.NewRecorder(),
+				NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody))
+		}()
+	}
+	require.Equal(t, 1000, dropped+passedThrough)
+	require.GreaterOrEqual(t, dropped, 250)
+	require.LessOrEqual(t, dropped, 350)
+}
@@ -0,0 +1,80 @@
+package httpsim
+
+import (
+	"net/http"
+
+	"github.com/romshark/httpsim/config"
+)
+
+// dryRun records in info what applying c to r would have done
+// without applying it. Returns true if subsequent effects wouldn't
+// have been applied. Max-concurrent and CDN effects aren't accounted for
+// since they depend on the state applied effects build up.
+func (m *Middleware) dryRun(r *http.Request, c *config.Effect, info *CtxInfo) bool {
+	// Response delays apply to replaced responses too.
+	for _, d := range [...]*config.DurRange{
+		c.DelayHeaders, c.DelayAfterHeaders, c.DelayBody,
+	} {
+		if d != nil {
+			info.Delay += SampleDur(m.rand, d)
+		}
+	}
+	if s := c.RetryStorm; s != nil {
+		// Retries are tracked since tracking doesn't affect requests.
+		if n := m.retries(r, s, c); n > s.Threshold {
+			if s.DropAfter != 0 && n > s.DropAfter {
+				info.Dropped = true
+			} else {
+				info.Replaced = true
+			}
+			return true
+		}
+	}
+	if c.Delay != nil {
+		info.Delay += SampleDur(m.rand, c.Delay)
+	}
+	if c.WebSocket != nil && IsWebSocketUpgrade(r) {
+		if c.WebSocket.UpgradeDelay != nil {
+			info.Delay += SampleDur(m.rand, c.WebSocket.UpgradeDelay)
+		}
+		if c.WebSocket.Reject != nil {
+			info.Replaced = true
+			return true
+		}
+	}
+	if c.Drop != nil && m.rand.Float64() < float64(c.Drop.Rate) {
+		info.Dropped = true
+		return true
+	}
+	if c.Hang != nil || c.Malformed != nil {
+		return true // The request would have been aborted.
+	}
+	if c.CORS != nil && IsCORSPreflight(r) {
+		info.Replaced = true // Preflights are answered by the effect.
+		return true
+	}
+	if c.NotModified != nil && IsConditional(r) &&
+		m.rand.Float64() < float64(c.NotModified.Rate) {
+		info.Replaced = true
+		return true
+	}
+	if c.Script != nil {
+		// Scripts are free of side effects except for consuming randomness.
+		res, err := m.runScript(r, c.Script, info.Captures)
+		if err != nil {
+			m.observe().OnError(r, err)
+		}
+		info.Delay += res.Delay
+		if err != nil || res.Replace {
+			info.Replaced = true
+			return true
+		}
+	}
+	if c.Replace != nil || len(c.ReplaceWeighted) > 0 || c.GRPCStatus != nil ||
+		c.Redirect != nil || c.Proxy != nil ||
+		(c.Replay != nil && !c.Replay.PassThroughMissing) {
+		info.Replaced = true
+		return true
+	}
+	return false
+}
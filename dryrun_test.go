@@ -0,0 +1,157 @@
+package httpsim_test
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim"
+	"github.com/romshark/httpsim/config"
+)
+
+func TestDryRun(t *testing.T) {
+	body := "replaced"
+	var infos []httpsim.CtxInfo
+	mockSleep, s := NewSimulator(t, config.Config{
+		DryRun: true,
+		Resources: []config.Resource{
+			{
+				Path: NewGlobExpression(t, "/replaced"),
+				Effects: []config.Effect{
+					{
+						Delay: &config.DurRange{Min: time.Second, Max: time.Second},
+						Replace: &config.Replace{
+							StatusCode: http.StatusServiceUnavailable, Body: &body,
+						},
+					},
+					{Delay: &config.DurRange{Min: time.Second, Max: time.Second}},
+				},
+			},
+			{
+				Path:   NewGlobExpression(t, "/dropped"),
+				Effect: &config.Effect{Drop: &config.Drop{Rate: 1}},
+			},
+			{
+				Path: NewGlobExpression(t, "/headers"),
+				Effect: &config.Effect{ResponseHeaders: &config.HeaderMutation{
+					Set: map[config.HeaderName]string{"X-Injected": "yes"},
+				}},
+			},
+		},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		infos = append(infos, httpsim.CtxInfoValue(r.Context()))
+		w.WriteHeader(http.StatusOK)
+	})
+	var logs bytes.Buffer
+	s.SetLogger(slog.New(slog.NewTextHandler(&logs, nil)), httpsim.DefaultLogLevels)
+
+	for _, path := range []string{"/replaced", "/dropped", "/headers"} {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, NewRequest(t, http.MethodGet, "https://host.io"+path, http.NoBody))
+		require.Equal(t, http.StatusOK, rec.Code, path)
+		require.Empty(t, rec.Header().Get("X-Injected"))
+	}
+	require.Zero(t, mockSleep.Cumulative)
+	require.Equal(t, []httpsim.CtxInfo{
+		{MatchedResourceIndex: 0, Delay: time.Second, Replaced: true, DryRun: true},
+		{MatchedResourceIndex: 1, Dropped: true, DryRun: true},
+		{MatchedResourceIndex: 2, DryRun: true},
+	}, infos)
+	require.Equal(t, []httpsim.ResourceStats{
+		{Matches: 1, Replacements: 1, Delay: time.Second},
+		{Matches: 1, Drops: 1},
+		{Matches: 1},
+	}, s.Stats().Resources)
+	require.Contains(t, logs.String(), "path=/dropped")
+	require.Contains(t, logs.String(), "dropped=true dry_run=true")
+}
+
+func TestDryRunEffects(t *testing.T) {
+	f := func(
+		e *config.Effect, prepare func(r *http.Request), expect httpsim.CtxInfo,
+	) {
+		t.Helper()
+		var info httpsim.CtxInfo
+		mockSleep, s := NewSimulator(t, config.Config{
+			DryRun:    true,
+			Resources: []config.Resource{{Effect: e}},
+		}, func(w http.ResponseWriter, r *http.Request) {
+			info = httpsim.CtxInfoValue(r.Context())
+			w.WriteHeader(http.StatusOK)
+		})
+		serve := func() {
+			t.Helper()
+			r := NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody)
+			if prepare != nil {
+				prepare(r)
+			}
+			rec := httptest.NewRecorder()
+			s.ServeHTTP(rec, r)
+			require.Equal(t, http.StatusOK, rec.Code)
+		}
+		// Effects depending on previous requests, such as retry storms,
+		// are accounted for on the second request.
+		serve()
+		serve()
+		require.Zero(t, mockSleep.Cumulative)
+		expect.DryRun = true
+		require.Equal(t, expect, info)
+	}
+	second := &config.DurRange{Min: time.Second, Max: time.Second}
+	websocket := func(r *http.Request) {
+		r.Header.Set("Connection", "Upgrade")
+		r.Header.Set("Upgrade", "websocket")
+	}
+	reject := config.StatusCode(http.StatusForbidden)
+
+	f(&config.Effect{DelayHeaders: second}, nil,
+		httpsim.CtxInfo{Delay: time.Second})
+	f(&config.Effect{DelayAfterHeaders: second, DelayBody: second}, nil,
+		httpsim.CtxInfo{Delay: 2 * time.Second})
+	f(&config.Effect{RetryStorm: &config.RetryStorm{Window: time.Minute}}, nil,
+		httpsim.CtxInfo{Replaced: true})
+	f(&config.Effect{WebSocket: &config.WebSocket{
+		UpgradeDelay: second, Reject: &reject,
+	}}, websocket, httpsim.CtxInfo{Delay: time.Second, Replaced: true})
+	f(&config.Effect{WebSocket: &config.WebSocket{Reject: &reject}}, nil,
+		httpsim.CtxInfo{})
+	f(&config.Effect{CORS: &config.CORS{}}, func(r *http.Request) {
+		r.Method = http.MethodOptions
+		r.Header.Set("Origin", "https://app.io")
+		r.Header.Set("Access-Control-Request-Method", http.MethodPut)
+	}, httpsim.CtxInfo{Replaced: true})
+	f(&config.Effect{CORS: &config.CORS{}}, nil, httpsim.CtxInfo{})
+
+	// Max-concurrent and CDN effects depend on the state applied effects
+	// build up and aren't accounted for.
+	f(&config.Effect{MaxConcurrent: &config.MaxConcurrent{Limit: 1}}, nil,
+		httpsim.CtxInfo{})
+	f(&config.Effect{CDN: &config.CDN{TTL: time.Minute}}, nil,
+		httpsim.CtxInfo{})
+}
+
+func TestDryRunRetryStormDrop(t *testing.T) {
+	var infos []httpsim.CtxInfo
+	_, s := NewSimulator(t, config.Config{
+		DryRun: true,
+		Resources: []config.Resource{{Effect: &config.Effect{
+			RetryStorm: &config.RetryStorm{Window: time.Minute, DropAfter: 1},
+		}}},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		infos = append(infos, httpsim.CtxInfoValue(r.Context()))
+	})
+	for range 3 {
+		s.ServeHTTP(httptest.NewRecorder(),
+			NewRequest(t, http.MethodPost, "https://host.io/", http.NoBody))
+	}
+	require.Equal(t, []httpsim.CtxInfo{
+		{DryRun: true},
+		{Replaced: true, DryRun: true},
+		{Dropped: true, DryRun: true},
+	}, infos)
+}
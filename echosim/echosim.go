@@ -0,0 +1,40 @@
+// Package echosim adapts httpsim.Middleware to echo.
+package echosim
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/romshark/httpsim"
+)
+
+// Middleware returns an echo middleware simulating requests by m.
+// If the simulator responds instead of the handler the handler isn't
+// invoked, otherwise it receives the request with the httpsim.CtxInfo,
+// see Info, and writes its response through the simulator such that
+// response effects apply to it. Errors returned by the handler are
+// handled by echo.Context.Error such that the error responses are written
+// through the simulator as well.
+// m may be created with a nil next handler.
+func Middleware(m *httpsim.Middleware) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			m.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				original := c.Response()
+				c.SetRequest(r)
+				c.SetResponse(echo.NewResponse(w, c.Echo()))
+				defer c.SetResponse(original)
+				if err := next(c); err != nil {
+					c.Error(err)
+				}
+			})).ServeHTTP(c.Response(), c.Request())
+			return nil
+		}
+	}
+}
+
+// Info returns the httpsim.CtxInfo of the request of c.
+func Info(c echo.Context) httpsim.CtxInfo {
+	return httpsim.CtxInfoValue(c.Request().Context())
+}
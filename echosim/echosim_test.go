@@ -0,0 +1,65 @@
+package echosim_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim"
+	"github.com/romshark/httpsim/echosim"
+)
+
+func TestMiddleware(t *testing.T) {
+	c, err := httpsim.LoadConfig(strings.NewReader(`
+resources:
+  - path: /fail
+    effect:
+      replace:
+        status-code: 503
+  - path: /*
+    effect:
+      response-headers:
+        set:
+          X-Simulated: "1"
+`))
+	require.NoError(t, err)
+	m := httpsim.NewMiddleware(nil, *c, httpsim.DefaultSleep, nil)
+
+	e := echo.New()
+	e.Use(echosim.Middleware(m))
+	var info httpsim.CtxInfo
+	e.GET("/fail", func(c echo.Context) error {
+		t.Fatal("handler must not be invoked")
+		return nil
+	})
+	e.GET("/ok", func(c echo.Context) error {
+		info = echosim.Info(c)
+		return c.String(http.StatusCreated, "ok")
+	})
+	e.GET("/error", func(c echo.Context) error {
+		return echo.NewHTTPError(http.StatusTeapot)
+	})
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/fail", http.NoBody))
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ok", http.NoBody))
+	require.Equal(t, http.StatusCreated, rec.Code)
+	require.Equal(t, "ok", rec.Body.String())
+	require.Equal(t, "1", rec.Header().Get("X-Simulated"))
+	require.Equal(t, 1, info.MatchedResourceIndex)
+
+	// Error responses are written through the simulator.
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/error", http.NoBody))
+	require.Equal(t, http.StatusTeapot, rec.Code)
+	require.Equal(t, "1", rec.Header().Get("X-Simulated"))
+
+	require.Equal(t, []uint64{1, 2}, m.MatchCounts())
+}
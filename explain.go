@@ -0,0 +1,68 @@
+package httpsim
+
+import (
+	"net/http"
+
+	"github.com/romshark/httpsim/config"
+)
+
+// MatcherResult is the result of a single matcher of a resource.
+type MatcherResult struct {
+	// Matcher is the name of the matcher as in the config,
+	// such as "path" or "headers".
+	Matcher string
+
+	Matched bool
+
+	// Reason explains a mismatch.
+	Reason string
+}
+
+// Explanation explains why a resource did or didn't match a request.
+type Explanation struct {
+	// Index is the index of the resource in Config.Resources.
+	Index int
+
+	// Name is the name of the resource, if any.
+	Name string
+
+	// Matched is true if all matchers matched.
+	Matched bool
+
+	// Matchers are the results of the matchers set on the resource,
+	// which is matched by any request if there are none.
+	Matchers []MatcherResult
+}
+
+// Explain evaluates every matcher of every resource of c against r.
+// Of the resources that matched, the first one is the one Match selects.
+// If the body of r is read by GraphQL matchers it's replaced
+// with an equivalent reader.
+func Explain(r *http.Request, c *config.Config) []Explanation {
+	g := &graphQLRequest{r: r}
+	explanations := make([]Explanation, len(c.Resources))
+	for i := range c.Resources {
+		var ex explanation
+		_, ok := matchResource(r, &c.Resources[i], g, &ex)
+		explanations[i] = Explanation{
+			Index: i, Name: c.Resources[i].Name, Matched: ok, Matchers: ex.matchers,
+		}
+	}
+	return explanations
+}
+
+// explanation records the results of matchers. Recording
+// on a nil *explanation is a no-op.
+type explanation struct{ matchers []MatcherResult }
+
+func (e *explanation) pass(matcher string) {
+	if e != nil {
+		e.matchers = append(e.matchers, MatcherResult{Matcher: matcher, Matched: true})
+	}
+}
+
+func (e *explanation) fail(matcher, reason string) {
+	if e != nil {
+		e.matchers = append(e.matchers, MatcherResult{Matcher: matcher, Reason: reason})
+	}
+}
@@ -0,0 +1,69 @@
+package httpsim_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim"
+)
+
+func TestExplain(t *testing.T) {
+	c, err := httpsim.LoadConfig(strings.NewReader(`
+resources:
+  - name: create-order
+    methods: [POST]
+    path: /orders
+    headers:
+      X-Tenant: [acme]
+  - path: /orders/*
+    host: "*.example.com"
+    query:
+      page: ["[0-9]"]
+    query-required: true
+    path-regexp: ^/orders/(?P<id>\d+)$
+    conditional: false
+  - {}
+`))
+	require.NoError(t, err)
+
+	r := NewRequest(t, http.MethodGet, "https://shop.example.com/orders/x?page=1", nil)
+	r.Header.Set("X-Tenant", "other")
+	ex := httpsim.Explain(r, c)
+	require.Equal(t, []httpsim.Explanation{
+		{
+			Index: 0, Name: "create-order", Matched: false,
+			Matchers: []httpsim.MatcherResult{
+				{Matcher: "methods", Reason: "GET isn't any of [POST]"},
+				{Matcher: "path", Reason: `"/orders/x" doesn't match "/orders"`},
+				{Matcher: "headers", Reason: `values of header X-Tenant ["other"] don't match`},
+			},
+		},
+		{
+			Index: 1, Matched: false,
+			Matchers: []httpsim.MatcherResult{
+				{Matcher: "path", Matched: true},
+				{Matcher: "host", Matched: true},
+				{Matcher: "query", Matched: true},
+				{Matcher: "path-regexp", Reason: `"/orders/x" doesn't match "^/orders/(?P<id>\\d+)$"`},
+				{Matcher: "conditional", Matched: true},
+			},
+		},
+		{Index: 2, Matched: true},
+	}, ex)
+	require.Equal(t, 2, httpsim.Match(r, c))
+
+	r = NewRequest(t, http.MethodGet, "https://shop.example.com/orders/1", nil)
+	ex = httpsim.Explain(r, c)
+	require.False(t, ex[1].Matched)
+	require.Equal(t, httpsim.MatcherResult{
+		Matcher: "query", Reason: "required parameter page is missing",
+	}, ex[1].Matchers[2])
+
+	r = NewRequest(t, http.MethodGet, "https://shop.example.com/orders/1?page=1", nil)
+	ex = httpsim.Explain(r, c)
+	require.True(t, ex[1].Matched)
+	require.Equal(t, 1, httpsim.Match(r, c))
+}
@@ -0,0 +1,90 @@
+package httpsim
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"expvar"
+
+	"github.com/romshark/httpsim/config"
+)
+
+// Seeder is a RandProvider exposing its seed, such as DefaultRand.
+type Seeder interface {
+	RandProvider
+	Seed() Seed
+}
+
+var _ Seeder = DefaultRand
+
+// Expvar returns a map of expvar variables reporting the runtime state of m,
+// which are evaluated whenever the map is read:
+//
+//   - config-digest: hex-encoded SHA-256 of the config as set.
+//   - seed: hex-encoded seed if the RandProvider is a Seeder, otherwise null.
+//   - resources: the stats of each resource, see Stats.
+//   - global: the stats of the requests matched by no resource
+//     but the global effect.
+//
+// Nothing is published by default, publish the map to expose it
+// on /debug/vars:
+//
+//	expvar.Publish("httpsim", m.Expvar())
+func (m *Middleware) Expvar() *expvar.Map {
+	v := new(expvar.Map)
+	v.Set("config-digest", expvar.Func(func() any {
+		return ConfigDigest(m.Config())
+	}))
+	v.Set("seed", expvar.Func(func() any {
+		if s, ok := m.rand.(Seeder); ok {
+			seed := s.Seed()
+			return hex.EncodeToString(seed[:])
+		}
+		return nil
+	}))
+	v.Set("resources", expvar.Func(func() any {
+		s := m.Stats()
+		names := m.state.Load().conf.Resources
+		resources := make([]expvarStats, len(s.Resources))
+		for i, r := range s.Resources {
+			resources[i] = newExpvarStats(r)
+			if i < len(names) {
+				resources[i].Name = names[i].Name
+			}
+		}
+		return resources
+	}))
+	v.Set("global", expvar.Func(func() any {
+		return newExpvarStats(m.Stats().Global)
+	}))
+	return v
+}
+
+// ConfigDigest returns the hex-encoded SHA-256 of the YAML encoding of c,
+// which identifies the config a simulator runs with.
+// Returns an empty string if c can't be encoded.
+func ConfigDigest(c config.Config) string {
+	b, err := config.Dump(c)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// expvarStats is the JSON encoding of ResourceStats.
+type expvarStats struct {
+	Name         string  `json:"name,omitempty"`
+	Matches      uint64  `json:"matches"`
+	Replacements uint64  `json:"replacements"`
+	Drops        uint64  `json:"drops"`
+	DelaySeconds float64 `json:"delay-seconds"`
+}
+
+func newExpvarStats(s ResourceStats) expvarStats {
+	return expvarStats{
+		Matches:      s.Matches,
+		Replacements: s.Replacements,
+		Drops:        s.Drops,
+		DelaySeconds: s.Delay.Seconds(),
+	}
+}
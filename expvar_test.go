@@ -0,0 +1,58 @@
+package httpsim_test
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim"
+	"github.com/romshark/httpsim/config"
+)
+
+func TestExpvar(t *testing.T) {
+	conf := config.Config{
+		Resources: []config.Resource{{
+			Name: "checkout",
+			Effect: &config.Effect{
+				Replace: &config.Replace{StatusCode: http.StatusServiceUnavailable},
+			},
+		}},
+	}
+	require.NoError(t, config.Validate(conf))
+	s := httpsim.NewMiddleware(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {},
+	), conf, httpsim.DefaultSleep, httpsim.DefaultRand)
+	s.ServeHTTP(httptest.NewRecorder(),
+		NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody))
+
+	var actual map[string]any
+	require.NoError(t, json.Unmarshal([]byte(s.Expvar().String()), &actual))
+	seed := httpsim.DefaultRand.Seed()
+	require.Equal(t, map[string]any{
+		"config-digest": httpsim.ConfigDigest(conf),
+		"seed":          hex.EncodeToString(seed[:]),
+		"resources": []any{map[string]any{
+			"name": "checkout", "matches": 1.0, "replacements": 1.0,
+			"drops": 0.0, "delay-seconds": 0.0,
+		}},
+		"global": map[string]any{
+			"matches": 0.0, "replacements": 0.0, "drops": 0.0, "delay-seconds": 0.0,
+		},
+	}, actual)
+
+	// Random providers that aren't seeders don't report a seed.
+	_, s = NewSimulator(t, conf, func(w http.ResponseWriter, r *http.Request) {})
+	require.Equal(t, "null", s.Expvar().Get("seed").String())
+}
+
+func TestConfigDigest(t *testing.T) {
+	a := config.Config{Resources: []config.Resource{{Name: "a"}}}
+	b := config.Config{Resources: []config.Resource{{Name: "b"}}}
+	require.Len(t, httpsim.ConfigDigest(a), 64)
+	require.Equal(t, httpsim.ConfigDigest(a), httpsim.ConfigDigest(a))
+	require.NotEqual(t, httpsim.ConfigDigest(a), httpsim.ConfigDigest(b))
+}
@@ -0,0 +1,111 @@
+// Package ginsim adapts httpsim.Middleware to gin.
+package ginsim
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/romshark/httpsim"
+)
+
+// Middleware returns a gin middleware simulating requests by m.
+// If the simulator responds instead of the handlers the chain is aborted,
+// otherwise the remaining handlers receive the request with
+// the httpsim.CtxInfo, see Info, and write their responses through
+// the simulator such that response effects apply to them.
+// m may be created with a nil next handler.
+// Use it before gin.Recovery, which would otherwise turn dropped
+// connections and hangs into 500 responses.
+func Middleware(m *httpsim.Middleware) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		passedThrough := false
+		m.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			passedThrough = true
+			original := c.Writer
+			rw := &responseWriter{
+				ResponseWriter: w, original: original,
+				status: http.StatusOK, size: -1,
+			}
+			c.Request, c.Writer = r, rw
+			defer func() { c.Writer = original }()
+			c.Next()
+			rw.WriteHeaderNow()
+		})).ServeHTTP(c.Writer, c.Request)
+		if !passedThrough {
+			c.Abort()
+		}
+	}
+}
+
+// Info returns the httpsim.CtxInfo of the request of c.
+func Info(c *gin.Context) httpsim.CtxInfo {
+	return httpsim.CtxInfoValue(c.Request.Context())
+}
+
+// responseWriter is a gin.ResponseWriter writing to the writer
+// of the simulator. Like gin's own writer it defers writing
+// the status code until the body is written or WriteHeaderNow is called
+// such that headers can be set after the status.
+type responseWriter struct {
+	http.ResponseWriter
+	original gin.ResponseWriter
+	status   int
+	size     int
+}
+
+var _ gin.ResponseWriter = new(responseWriter)
+
+func (w *responseWriter) WriteHeader(code int) {
+	if code > 0 && !w.Written() {
+		w.status = code
+	}
+}
+
+func (w *responseWriter) WriteHeaderNow() {
+	if !w.Written() {
+		w.size = 0
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.WriteHeaderNow()
+	n, err := w.ResponseWriter.Write(b)
+	w.size += n
+	return n, err
+}
+
+func (w *responseWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *responseWriter) Status() int   { return w.status }
+func (w *responseWriter) Size() int     { return w.size }
+func (w *responseWriter) Written() bool { return w.size != -1 }
+
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if w.size < 0 {
+		w.size = 0
+	}
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
+
+func (w *responseWriter) Flush() {
+	w.WriteHeaderNow()
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *responseWriter) CloseNotify() <-chan bool { return w.original.CloseNotify() }
+
+func (w *responseWriter) Pusher() http.Pusher {
+	if p, ok := w.ResponseWriter.(http.Pusher); ok {
+		return p
+	}
+	return nil
+}
+
+// Unwrap allows http.ResponseController to access the underlying writer.
+func (w *responseWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
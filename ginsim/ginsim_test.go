@@ -0,0 +1,57 @@
+package ginsim_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim"
+	"github.com/romshark/httpsim/ginsim"
+)
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, err := httpsim.LoadConfig(strings.NewReader(`
+resources:
+  - path: /fail
+    effect:
+      replace:
+        status-code: 503
+  - path: /ok
+    effect:
+      response-headers:
+        set:
+          X-Simulated: "1"
+`))
+	require.NoError(t, err)
+	m := httpsim.NewMiddleware(nil, *c, httpsim.DefaultSleep, nil)
+
+	e := gin.New()
+	e.Use(ginsim.Middleware(m))
+	var info httpsim.CtxInfo
+	handler := func(c *gin.Context) {
+		info = ginsim.Info(c)
+		c.JSON(http.StatusCreated, gin.H{"ok": true})
+	}
+	e.GET("/fail", func(c *gin.Context) { t.Fatal("handler must not be invoked") })
+	e.GET("/ok", handler)
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/fail", http.NoBody))
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ok", http.NoBody))
+	require.Equal(t, http.StatusCreated, rec.Code)
+	require.JSONEq(t, `{"ok":true}`, rec.Body.String())
+	// Headers set after the status are written.
+	require.Equal(t, "application/json; charset=utf-8", rec.Header().Get("Content-Type"))
+	require.Equal(t, "1", rec.Header().Get("X-Simulated"))
+	require.Equal(t, 1, info.MatchedResourceIndex)
+
+	require.Equal(t, []uint64{1, 1}, m.MatchCounts())
+}
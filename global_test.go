@@ -0,0 +1,92 @@
+package httpsim_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim"
+	"github.com/romshark/httpsim/config"
+)
+
+func TestGlobalEffect(t *testing.T) {
+	conf := config.Config{
+		GlobalEffect: &config.Effect{
+			Delay: &config.DurRange{Min: 20 * time.Millisecond, Max: 20 * time.Millisecond},
+			ResponseHeaders: &config.HeaderMutation{
+				Set: map[config.HeaderName]string{"X-Global": "true"},
+			},
+		},
+		Resources: []config.Resource{{
+			Path: NewGlobExpression(t, "/matched"),
+			Effect: &config.Effect{
+				Delay:   &config.DurRange{Min: time.Second, Max: time.Second},
+				Replace: &config.Replace{StatusCode: http.StatusNotFound},
+			},
+		}},
+	}
+	var nextInfo httpsim.CtxInfo
+	mockSleep, s := NewSimulator(t, conf, func(w http.ResponseWriter, r *http.Request) {
+		nextInfo = httpsim.CtxInfoValue(r.Context())
+		w.WriteHeader(http.StatusOK)
+	})
+
+	// Unmatched requests are affected by the global effect only.
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, NewRequest(t, http.MethodGet, "https://host.io/other", http.NoBody))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "true", rec.Header().Get("X-Global"))
+	require.Equal(t, 20*time.Millisecond, mockSleep.Cumulative)
+	require.Equal(t, -1, nextInfo.MatchedResourceIndex)
+	require.Equal(t, 20*time.Millisecond, nextInfo.Delay)
+
+	// Matched requests compose the global and the resource effect.
+	mockSleep.Cumulative = 0
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, NewRequest(t, http.MethodGet, "https://host.io/matched", http.NoBody))
+	require.Equal(t, http.StatusNotFound, rec.Code)
+	require.Equal(t, "true", rec.Header().Get("X-Global"))
+	require.Equal(t, time.Second+20*time.Millisecond, mockSleep.Cumulative)
+}
+
+func TestGlobalEffectReplaced(t *testing.T) {
+	_, s := NewSimulator(t, config.Config{
+		GlobalEffect: &config.Effect{
+			Replace: &config.Replace{StatusCode: http.StatusServiceUnavailable},
+		},
+		Resources: []config.Resource{{Effect: &config.Effect{
+			Replace: &config.Replace{StatusCode: http.StatusNotFound},
+		}}},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("next handler must not be invoked")
+	})
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody))
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+}
+
+func TestGlobalEffectAfterMatches(t *testing.T) {
+	_, s := NewSimulator(t, config.Config{
+		GlobalEffect: &config.Effect{
+			AfterMatches: 2,
+			Replace:      &config.Replace{StatusCode: http.StatusServiceUnavailable},
+		},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	codes := make([]int, 0, 3)
+	for range 3 {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody))
+		codes = append(codes, rec.Code)
+	}
+	require.Equal(t, []int{200, 200, 503}, codes)
+
+	s.ResetMatchCounts()
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody))
+	require.Equal(t, http.StatusOK, rec.Code)
+}
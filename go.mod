@@ -3,6 +3,7 @@ module github.com/romshark/httpsim
 go 1.22.6
 
 require (
+	github.com/fsnotify/fsnotify v1.7.0
 	github.com/gobwas/glob v0.2.3
 	github.com/romshark/yamagiconf v1.0.0
 	github.com/stretchr/testify v1.9.0
@@ -0,0 +1,329 @@
+package httpsim
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/romshark/httpsim/config"
+)
+
+// graphQLMaxBodyBytes is the maximum size of request bodies
+// parsed for GraphQL matching.
+const graphQLMaxBodyBytes = 1 << 20
+
+// graphQLOperation is the operation a GraphQL request executes.
+type graphQLOperation struct {
+	name   string
+	typ    config.GraphQLOperationType
+	fields []string // Top-level fields.
+}
+
+// graphQLRequest lazily parses the GraphQL operation of a request
+// once for all resources it's matched against.
+type graphQLRequest struct {
+	r      *http.Request
+	parsed bool
+	op     *graphQLOperation
+}
+
+// match returns true if the request executes an operation matching c.
+func (g *graphQLRequest) match(c *config.GraphQL) bool {
+	if !g.parsed {
+		g.parsed, g.op = true, parseGraphQLRequest(g.r)
+	}
+	if g.op == nil {
+		return false
+	}
+	if !c.OperationName.Match(g.op.name) {
+		return false
+	}
+	if c.OperationType != "" && c.OperationType != g.op.typ {
+		return false
+	}
+	if len(c.Fields) < 1 {
+		return true
+	}
+	for _, f := range g.op.fields {
+		for i := range c.Fields {
+			if c.Fields[i].Match(f) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// parseGraphQLRequest returns the operation r executes or nil if r isn't
+// a valid GraphQL request. The body is replaced with an equivalent reader.
+func parseGraphQLRequest(r *http.Request) *graphQLOperation {
+	var req struct {
+		Query         string `json:"query"`
+		OperationName string `json:"operationName"`
+	}
+	switch r.Method {
+	case http.MethodGet:
+		q := r.URL.Query()
+		req.Query, req.OperationName = q.Get("query"), q.Get("operationName")
+	case http.MethodPost:
+		if r.Body == nil || r.Body == http.NoBody {
+			return nil
+		}
+		body, err := io.ReadAll(io.LimitReader(r.Body, graphQLMaxBodyBytes+1))
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+		if err != nil || len(body) > graphQLMaxBodyBytes {
+			return nil
+		}
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if mediaType == "application/graphql" {
+			req.Query, req.OperationName = string(body), r.URL.Query().Get("operationName")
+		} else if err := json.Unmarshal(body, &req); err != nil {
+			return nil
+		}
+	default:
+		return nil
+	}
+	if req.Query == "" {
+		return nil
+	}
+	return parseGraphQLDocument(req.Query, req.OperationName)
+}
+
+// parseGraphQLDocument returns the operation named operationName,
+// or the only operation if operationName is empty, or nil if there's
+// no such operation or the document is malformed.
+func parseGraphQLDocument(document, operationName string) *graphQLOperation {
+	p := graphQLParser{tokens: graphQLTokens(document)}
+	fragments := map[string]int{} // Name -> index of the selection set.
+	var ops []graphQLOperation
+	var opSets []int
+	for p.i < len(p.tokens) {
+		switch t := p.next(); t {
+		case "{": // Query shorthand.
+			p.i--
+			set := p.skipToSelectionSet()
+			if set < 0 {
+				return nil
+			}
+			ops = append(ops, graphQLOperation{typ: config.GraphQLQuery})
+			opSets = append(opSets, set)
+		case "query", "mutation", "subscription":
+			op := graphQLOperation{typ: config.GraphQLOperationType(t)}
+			if isGraphQLName(p.peek()) {
+				op.name = p.next()
+			}
+			set := p.skipToSelectionSet()
+			if set < 0 {
+				return nil
+			}
+			ops, opSets = append(ops, op), append(opSets, set)
+		case "fragment":
+			name := p.next()
+			set := p.skipToSelectionSet()
+			if set < 0 {
+				return nil
+			}
+			fragments[name] = set
+		default:
+			return nil
+		}
+	}
+
+	index := -1
+	for i, op := range ops {
+		if op.name == operationName || (operationName == "" && len(ops) == 1) {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return nil
+	}
+	op := ops[index]
+	op.fields = p.fields(opSets[index], fragments, map[string]bool{})
+	return &op
+}
+
+type graphQLParser struct {
+	tokens []string
+	i      int
+}
+
+func (p *graphQLParser) peek() string {
+	if p.i < len(p.tokens) {
+		return p.tokens[p.i]
+	}
+	return ""
+}
+
+func (p *graphQLParser) next() string {
+	t := p.peek()
+	p.i++
+	return t
+}
+
+// skipBalanced skips tokens from open to the matching close
+// and returns false if there's none.
+func (p *graphQLParser) skipBalanced(open, close string) bool {
+	depth := 0
+	for p.i < len(p.tokens) {
+		switch p.next() {
+		case open:
+			depth++
+		case close:
+			if depth--; depth == 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// skipToSelectionSet skips variables, type conditions and directives
+// up to and including the following selection set and returns
+// its index, or -1 if there's none.
+func (p *graphQLParser) skipToSelectionSet() int {
+	for p.i < len(p.tokens) {
+		switch p.peek() {
+		case "{":
+			set := p.i
+			if !p.skipBalanced("{", "}") {
+				return -1
+			}
+			return set
+		case "(":
+			if !p.skipBalanced("(", ")") {
+				return -1
+			}
+		default:
+			p.i++
+		}
+	}
+	return -1
+}
+
+// fields returns the names of the fields of the selection set at index set
+// including the fields of spread fragments.
+func (p *graphQLParser) fields(
+	set int, fragments map[string]int, visited map[string]bool,
+) (fields []string) {
+	p.i = set + 1
+	for p.i < len(p.tokens) {
+		switch t := p.next(); {
+		case t == "}":
+			return fields
+		case t == "...":
+			if p.peek() == "on" || p.peek() == "@" || p.peek() == "{" {
+				inner := p.skipToSelectionSet() // Inline fragment.
+				if inner < 0 {
+					return fields
+				}
+				end := p.i
+				fields = append(fields, p.fields(inner, fragments, visited)...)
+				p.i = end
+				continue
+			}
+			name := p.next()
+			end := p.i
+			if s, ok := fragments[name]; ok && !visited[name] {
+				visited[name] = true
+				fields = append(fields, p.fields(s, fragments, visited)...)
+			}
+			p.i = end
+		case isGraphQLName(t):
+			if p.peek() == ":" { // Alias.
+				p.i++
+				t = p.next()
+			}
+			fields = append(fields, t)
+			if p.peek() == "(" && !p.skipBalanced("(", ")") {
+				return fields
+			}
+			for p.peek() == "@" { // Directives.
+				p.i += 2
+				if p.peek() == "(" && !p.skipBalanced("(", ")") {
+					return fields
+				}
+			}
+			if p.peek() == "{" && !p.skipBalanced("{", "}") {
+				return fields
+			}
+		}
+	}
+	return fields
+}
+
+func isGraphQLName(t string) bool {
+	if t == "" {
+		return false
+	}
+	for i, c := range t {
+		if c != '_' && (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') &&
+			(i == 0 || c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// graphQLTokens splits document into names, numbers and punctuators
+// ignoring whitespace, commas, comments and the contents of strings,
+// which are returned as `"`.
+func graphQLTokens(document string) (tokens []string) {
+	for i := 0; i < len(document); {
+		switch c := document[i]; {
+		case c == '#':
+			for i < len(document) && document[i] != '\n' && document[i] != '\r' {
+				i++
+			}
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			i++
+		case strings.HasPrefix(document[i:], `"""`):
+			end := strings.Index(document[i+3:], `"""`)
+			for end >= 0 && strings.HasSuffix(document[:i+3+end], `\`) {
+				// Escaped triple quote.
+				next := strings.Index(document[i+3+end+3:], `"""`)
+				if next < 0 {
+					end = -1
+					break
+				}
+				end += 3 + next
+			}
+			if end < 0 {
+				return tokens
+			}
+			tokens, i = append(tokens, `"`), i+3+end+3
+		case c == '"':
+			i++
+			for i < len(document) && document[i] != '"' {
+				if document[i] == '\\' {
+					i++
+				}
+				i++
+			}
+			tokens, i = append(tokens, `"`), i+1
+		case strings.HasPrefix(document[i:], "..."):
+			tokens, i = append(tokens, "..."), i+3
+		case strings.IndexByte("{}()[]:!$@=|&", c) >= 0:
+			tokens, i = append(tokens, document[i:i+1]), i+1
+		default:
+			start := i
+			for i < len(document) &&
+				strings.IndexByte(" \t\n\r,#\"{}()[]:!$@=|&.", document[i]) < 0 {
+				i++
+			}
+			if i == start {
+				i++ // Skip unexpected characters such as a lone ".".
+				continue
+			}
+			tokens = append(tokens, document[start:i])
+		}
+	}
+	return tokens
+}
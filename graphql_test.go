@@ -0,0 +1,149 @@
+package httpsim_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim"
+	"github.com/romshark/httpsim/config"
+)
+
+func TestMatchResourceGraphQL(t *testing.T) {
+	const document = `
+		# Comments { are ignored.
+		query GetOrder($id: ID!, $opts: Opts = {deep: true}) @cached {
+			order: orderByID(id: $id, note: "}") {
+				id
+				items { sku }
+			}
+			...Viewer
+			... on Query @include(if: true) { stats { total } }
+		}
+		mutation CreateOrder { createOrder(input: {sku: "a"}) { id } }
+		fragment Viewer on Query { viewer { name } }
+	`
+	post := func(t *testing.T, body string) *http.Request {
+		t.Helper()
+		r := NewRequest(t, http.MethodPost, "https://host.io/graphql",
+			strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		return r
+	}
+	f := func(t *testing.T, r *http.Request, c config.GraphQL, expect bool) {
+		t.Helper()
+		resource := config.Resource{GraphQL: &c}
+		require.Equal(t, expect, httpsim.MatchResource(r, &resource))
+	}
+	doc := strings.NewReplacer("\n", `\n`, "\t", `\t`, `"`, `\"`).Replace(document)
+	getOrder := `{"query":"` + doc + `","operationName":"GetOrder"}`
+	createOrder := `{"query":"` + doc + `","operationName":"CreateOrder"}`
+
+	f(t, post(t, getOrder), config.GraphQL{}, true)
+	f(t, post(t, getOrder), config.GraphQL{
+		OperationName: NewGlobExpression(t, "GetOrder"),
+	}, true)
+	f(t, post(t, getOrder), config.GraphQL{
+		OperationName: NewGlobExpression(t, "Create*"),
+	}, false)
+	f(t, post(t, getOrder), config.GraphQL{
+		OperationType: config.GraphQLQuery,
+	}, true)
+	f(t, post(t, createOrder), config.GraphQL{
+		OperationType: config.GraphQLQuery,
+	}, false)
+	f(t, post(t, createOrder), config.GraphQL{
+		OperationType: config.GraphQLMutation,
+	}, true)
+
+	// Top-level fields, including those of fragments but not aliases.
+	for _, field := range []string{"orderByID", "viewer", "stats"} {
+		f(t, post(t, getOrder), config.GraphQL{
+			Fields: []config.GlobExpression{NewGlobExpression(t, field)},
+		}, true)
+	}
+	for _, field := range []string{"order", "id", "items", "name", "createOrder"} {
+		f(t, post(t, getOrder), config.GraphQL{
+			Fields: []config.GlobExpression{NewGlobExpression(t, field)},
+		}, false)
+	}
+	f(t, post(t, createOrder), config.GraphQL{
+		Fields: []config.GlobExpression{
+			NewGlobExpression(t, "orderByID"), NewGlobExpression(t, "create*"),
+		},
+	}, true)
+
+	// Anonymous operations.
+	f(t, post(t, `{"query":"{ products { id } }"}`), config.GraphQL{
+		OperationName: NewGlobExpression(t, ""),
+		OperationType: config.GraphQLQuery,
+		Fields:        []config.GlobExpression{NewGlobExpression(t, "products")},
+	}, true)
+
+	// GET requests and application/graphql bodies.
+	f(t, NewRequest(t, http.MethodGet, "https://host.io/graphql?query="+
+		url.QueryEscape("query Me { me { id } }"), http.NoBody),
+		config.GraphQL{OperationName: NewGlobExpression(t, "Me")}, true)
+	r := NewRequest(t, http.MethodPost, "https://host.io/graphql",
+		strings.NewReader("mutation Logout { logout }"))
+	r.Header.Set("Content-Type", "application/graphql")
+	f(t, r, config.GraphQL{OperationType: config.GraphQLMutation}, true)
+
+	// Requests that aren't valid GraphQL requests don't match.
+	f(t, post(t, `{"query":"`+doc+`"}`), config.GraphQL{}, false) // Ambiguous.
+	f(t, post(t, `{"query":"`+doc+`","operationName":"Unknown"}`), config.GraphQL{}, false)
+	f(t, post(t, `[{"query":"{ a }"}]`), config.GraphQL{}, false)
+	f(t, post(t, `not json`), config.GraphQL{}, false)
+	f(t, post(t, `{"query":"query { a "}`), config.GraphQL{}, false)
+	f(t, NewRequest(t, http.MethodPut, "https://host.io/graphql",
+		strings.NewReader(getOrder)), config.GraphQL{}, false)
+}
+
+func TestGraphQL(t *testing.T) {
+	body := `{"errors":[{"message":"simulated"}]}`
+	conf := config.Config{
+		Resources: []config.Resource{{
+			Path: NewGlobExpression(t, "/graphql"),
+			GraphQL: &config.GraphQL{
+				OperationType: config.GraphQLMutation,
+				Fields:        []config.GlobExpression{NewGlobExpression(t, "checkout")},
+			},
+			Effect: &config.Effect{
+				Delay: &config.DurRange{Min: time.Second, Max: time.Second},
+				Replace: &config.Replace{
+					StatusCode: http.StatusOK,
+					Body:       &body,
+				},
+			},
+		}},
+	}
+	var received string
+	mockSleep, s := NewSimulator(t, conf, func(w http.ResponseWriter, r *http.Request) {
+		b, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		received = string(b)
+		w.WriteHeader(http.StatusOK)
+	})
+
+	const query = `{"query":"query { products { id } }"}`
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, NewRequest(t, http.MethodPost, "https://host.io/graphql",
+		strings.NewReader(query)))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Zero(t, mockSleep.Cumulative)
+	// The body read for matching is passed on to the next handler.
+	require.Equal(t, query, received)
+
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, NewRequest(t, http.MethodPost, "https://host.io/graphql",
+		strings.NewReader(`{"query":"mutation { checkout(cart: 1) { id } }"}`)))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, body, rec.Body.String())
+	require.Equal(t, time.Second, mockSleep.Cumulative)
+}
@@ -0,0 +1,77 @@
+package httpsim
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/romshark/httpsim/config"
+)
+
+// IsGRPC returns true if r is a gRPC request.
+func IsGRPC(r *http.Request) bool {
+	if r.Method != http.MethodPost {
+		return false
+	}
+	t := r.Header.Get("Content-Type")
+	rest, ok := strings.CutPrefix(t, "application/grpc")
+	return ok && (rest == "" || rest[0] == '+' || rest[0] == ';')
+}
+
+// matchGRPC returns true if r is a gRPC request calling a method matching c.
+func matchGRPC(r *http.Request, c *config.GRPC) bool {
+	if !IsGRPC(r) {
+		return false
+	}
+	service, method, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if !ok || service == "" || method == "" || strings.Contains(method, "/") {
+		return false
+	}
+	return c.Service.Match(service) && c.Method.Match(method)
+}
+
+// grpcStatus writes a gRPC response without messages carrying
+// the status in the trailers.
+func grpcStatus(w http.ResponseWriter, c *config.GRPCStatus, captures map[string]string) {
+	h := w.Header()
+	h.Set("Content-Type", "application/grpc")
+	h.Set("Trailer", "Grpc-Status, Grpc-Message")
+	w.WriteHeader(http.StatusOK)
+	h.Set("Grpc-Status", strconv.FormatUint(uint64(c.Code), 10))
+	if c.Message != "" {
+		h.Set("Grpc-Message", grpcEncodeMessage(ExpandTemplate(c.Message, captures)))
+	}
+}
+
+// grpcEncodeMessage percent-encodes s as required for grpc-message.
+func grpcEncodeMessage(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c < ' ' || c > '~' || c == '%' {
+			fmt.Fprintf(&b, "%%%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// GRPCCodeFromHTTP returns the gRPC status code gRPC clients translate
+// the HTTP status code of a response without grpc-status to.
+func GRPCCodeFromHTTP(statusCode int) config.GRPCCode {
+	switch statusCode {
+	case http.StatusBadRequest:
+		return config.GRPCCodeInternal
+	case http.StatusUnauthorized:
+		return config.GRPCCodeUnauthenticated
+	case http.StatusForbidden:
+		return config.GRPCCodePermissionDenied
+	case http.StatusNotFound:
+		return config.GRPCCodeUnimplemented
+	case http.StatusTooManyRequests, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return config.GRPCCodeUnavailable
+	}
+	return config.GRPCCodeUnknown
+}
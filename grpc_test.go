@@ -0,0 +1,111 @@
+package httpsim_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim"
+	"github.com/romshark/httpsim/config"
+)
+
+func TestIsGRPC(t *testing.T) {
+	f := func(t *testing.T, method, contentType string, expect bool) {
+		t.Helper()
+		r := NewRequest(t, method, "https://host.io/shop.v1.Orders/Get", http.NoBody)
+		r.Header.Set("Content-Type", contentType)
+		require.Equal(t, expect, httpsim.IsGRPC(r))
+	}
+	f(t, http.MethodPost, "application/grpc", true)
+	f(t, http.MethodPost, "application/grpc+proto", true)
+	f(t, http.MethodPost, "application/grpc;charset=utf-8", true)
+	f(t, http.MethodPost, "application/grpc-web", false)
+	f(t, http.MethodPost, "application/json", false)
+	f(t, http.MethodGet, "application/grpc", false)
+}
+
+func TestMatchResourceGRPC(t *testing.T) {
+	f := func(t *testing.T, path string, c config.GRPC, expect bool) {
+		t.Helper()
+		r := NewRequest(t, http.MethodPost, "https://host.io"+path, http.NoBody)
+		r.Header.Set("Content-Type", "application/grpc")
+		resource := config.Resource{GRPC: &c}
+		require.Equal(t, expect, httpsim.MatchResource(r, &resource))
+	}
+	f(t, "/shop.v1.Orders/Create", config.GRPC{}, true)
+	f(t, "/shop.v1.Orders/Create", config.GRPC{
+		Service: NewGlobExpression(t, "shop.v1.Orders"),
+		Method:  NewGlobExpression(t, "Create"),
+	}, true)
+	f(t, "/shop.v1.Orders/Create", config.GRPC{
+		Service: NewGlobExpression(t, "shop.*"),
+	}, true)
+	f(t, "/shop.v1.Orders/Create", config.GRPC{
+		Method: NewGlobExpression(t, "Get*"),
+	}, false)
+	f(t, "/shop.v1.Orders/Create", config.GRPC{
+		Service: NewGlobExpression(t, "shop.v1.Users"),
+	}, false)
+	f(t, "/shop.v1.Orders", config.GRPC{}, false)
+	f(t, "/shop.v1.Orders/Create/x", config.GRPC{}, false)
+
+	// Requests that aren't gRPC requests don't match.
+	r := NewRequest(t, http.MethodPost, "https://host.io/shop.v1.Orders/Create", http.NoBody)
+	require.False(t, httpsim.MatchResource(r, &config.Resource{GRPC: &config.GRPC{}}))
+}
+
+func TestGRPCStatus(t *testing.T) {
+	conf := config.Config{
+		Resources: []config.Resource{{
+			PathRegexp: NewRegexp(t, `^/shop\.v1\.Orders/(?P<method>\w+)$`),
+			GRPC: &config.GRPC{
+				Service: NewGlobExpression(t, "shop.v1.Orders"),
+			},
+			Effect: &config.Effect{GRPCStatus: &config.GRPCStatus{
+				Code:    config.GRPCCodeUnavailable,
+				Message: "${method} is down: 100% broken ✗",
+			}},
+		}},
+	}
+	_, s := NewSimulator(t, conf, func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("next handler must not be invoked")
+	})
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+
+	req := NewRequest(t, http.MethodPost, srv.URL+"/shop.v1.Orders/Create",
+		strings.NewReader("\x00\x00\x00\x00\x00"))
+	req.Header.Set("Content-Type", "application/grpc")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body) // Trailers are read after the body.
+	require.NoError(t, err)
+	require.Empty(t, body)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "application/grpc", resp.Header.Get("Content-Type"))
+	require.Equal(t, "14", resp.Trailer.Get("Grpc-Status"))
+	require.Equal(t, "Create is down: 100%25 broken %E2%9C%97",
+		resp.Trailer.Get("Grpc-Message"))
+}
+
+func TestGRPCCodeFromHTTP(t *testing.T) {
+	f := func(statusCode int, expect config.GRPCCode) {
+		t.Helper()
+		require.Equal(t, expect, httpsim.GRPCCodeFromHTTP(statusCode))
+	}
+	f(http.StatusBadRequest, config.GRPCCodeInternal)
+	f(http.StatusUnauthorized, config.GRPCCodeUnauthenticated)
+	f(http.StatusForbidden, config.GRPCCodePermissionDenied)
+	f(http.StatusNotFound, config.GRPCCodeUnimplemented)
+	f(http.StatusTooManyRequests, config.GRPCCodeUnavailable)
+	f(http.StatusBadGateway, config.GRPCCodeUnavailable)
+	f(http.StatusServiceUnavailable, config.GRPCCodeUnavailable)
+	f(http.StatusGatewayTimeout, config.GRPCCodeUnavailable)
+	f(http.StatusInternalServerError, config.GRPCCodeUnknown)
+	f(http.StatusOK, config.GRPCCodeUnknown)
+}
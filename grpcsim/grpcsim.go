@@ -0,0 +1,128 @@
+// Package grpcsim simulates faults in gRPC servers using httpsim configs.
+//
+// Every call is matched by the config like the equivalent gRPC over HTTP/2
+// request: a POST request with Content-Type application/grpc to the path
+// /package.Service/Method with the incoming metadata as headers,
+// such that resources with a grpc matcher apply to it.
+// Delays are injected before the handler is invoked, replaced responses
+// fail the call with the status of the grpc-status effect or the gRPC
+// status corresponding to the HTTP status code, and dropped connections and
+// hangs fail it with Unavailable or the error of the context.
+// Effects on the responses of calls passed through, such as throttling,
+// don't apply.
+package grpcsim
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/romshark/httpsim"
+	"github.com/romshark/httpsim/internal/intercept"
+)
+
+// UnaryServerInterceptor returns an interceptor simulating the unary calls
+// of a server by m. The handler receives a context carrying
+// the httpsim.CtxInfo, see httpsim.CtxInfoValue.
+// m may be created with a nil next handler.
+func UnaryServerInterceptor(m *httpsim.Middleware) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context, req any,
+		info *grpc.UnaryServerInfo, handler grpc.UnaryHandler,
+	) (any, error) {
+		ctx, err := simulate(ctx, m, info.FullMethod)
+		switch {
+		case err != nil:
+			return nil, err
+		case ctx == nil:
+			// Unary calls must respond with a message.
+			return nil, status.Error(codes.Internal,
+				"httpsim: grpc-status 0 without a response message")
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns an interceptor simulating the streaming
+// calls of a server by m. The context of the stream passed to the handler
+// carries the httpsim.CtxInfo, see httpsim.CtxInfoValue.
+// m may be created with a nil next handler.
+func StreamServerInterceptor(m *httpsim.Middleware) grpc.StreamServerInterceptor {
+	return func(
+		srv any, ss grpc.ServerStream,
+		info *grpc.StreamServerInfo, handler grpc.StreamHandler,
+	) error {
+		ctx, err := simulate(ss.Context(), m, info.FullMethod)
+		if err != nil || ctx == nil {
+			return err
+		}
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// serverStream is a grpc.ServerStream with a different context.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context { return s.ctx }
+
+// simulate serves the request equivalent to the call of fullMethod by m
+// and returns the context the call continues with or the error
+// the call fails with. Both are nil if the call was replaced
+// with the OK status, ending it without messages.
+func simulate(
+	ctx context.Context, m *httpsim.Middleware, fullMethod string,
+) (context.Context, error) {
+	res := intercept.Serve(m, newRequest(ctx, fullMethod))
+	switch {
+	case res.Next != nil:
+		return res.Next, nil
+	case res.StatusCode != 0:
+		code, msg := res.Status()
+		return nil, status.Error(codes.Code(code), msg)
+	case ctx.Err() != nil:
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+	return nil, status.Error(codes.Unavailable, "httpsim: connection dropped")
+}
+
+// newRequest returns the request equivalent to the call of fullMethod.
+func newRequest(ctx context.Context, fullMethod string) *http.Request {
+	r := &http.Request{
+		Method:     http.MethodPost,
+		URL:        &url.URL{Path: fullMethod},
+		RequestURI: fullMethod,
+		Proto:      "HTTP/2.0",
+		ProtoMajor: 2,
+		Header:     http.Header{},
+		Body:       http.NoBody,
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for k, v := range md {
+		if strings.HasPrefix(k, ":") {
+			continue // Pseudo-headers.
+		}
+		for _, v := range v {
+			r.Header.Add(k, v)
+		}
+	}
+	if !httpsim.IsGRPC(r) {
+		r.Header.Set("Content-Type", "application/grpc")
+	}
+	if v := md.Get(":authority"); len(v) > 0 {
+		r.Host = v[0]
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		r.RemoteAddr = p.Addr.String()
+	}
+	return r.WithContext(ctx)
+}
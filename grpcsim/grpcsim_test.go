@@ -0,0 +1,160 @@
+package grpcsim_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/romshark/httpsim"
+	"github.com/romshark/httpsim/grpcsim"
+)
+
+// infoServer is a health server reporting the httpsim.CtxInfo
+// of the last call.
+type infoServer struct {
+	*health.Server
+	info chan httpsim.CtxInfo
+}
+
+func (s *infoServer) Check(
+	ctx context.Context, r *healthpb.HealthCheckRequest,
+) (*healthpb.HealthCheckResponse, error) {
+	s.info <- httpsim.CtxInfoValue(ctx)
+	return s.Server.Check(ctx, r)
+}
+
+func (s *infoServer) Watch(
+	r *healthpb.HealthCheckRequest, ss healthpb.Health_WatchServer,
+) error {
+	s.info <- httpsim.CtxInfoValue(ss.Context())
+	return nil
+}
+
+func NewClient(t *testing.T, conf string) (healthpb.HealthClient, *infoServer) {
+	t.Helper()
+	c, err := httpsim.LoadConfig(strings.NewReader(conf))
+	require.NoError(t, err)
+	m := httpsim.NewMiddleware(nil, *c, httpsim.DefaultSleep, nil)
+
+	l := bufconn.Listen(1 << 20)
+	s := grpc.NewServer(
+		grpc.UnaryInterceptor(grpcsim.UnaryServerInterceptor(m)),
+		grpc.StreamInterceptor(grpcsim.StreamServerInterceptor(m)),
+	)
+	srv := &infoServer{Server: health.NewServer(), info: make(chan httpsim.CtxInfo, 1)}
+	healthpb.RegisterHealthServer(s, srv)
+	go func() { _ = s.Serve(l) }()
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return l.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return healthpb.NewHealthClient(conn), srv
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	client, srv := NewClient(t, `
+resources:
+  - grpc:
+      service: grpc.health.v1.Health
+      method: Check
+    headers:
+      X-Fail: ["grpc"]
+    effect:
+      grpc-status:
+        code: 14
+        message: "backend unavailable: 100%"
+  - grpc:
+      service: grpc.health.v1.Health
+    headers:
+      X-Fail: ["http"]
+    effect:
+      replace:
+        status-code: 403
+  - grpc:
+      service: grpc.health.v1.Health
+    effect:
+      delay: 20ms
+`)
+	call := func(fail string) error {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "x-fail", fail)
+		_, err := client.Check(ctx, &healthpb.HealthCheckRequest{})
+		return err
+	}
+
+	err := call("grpc")
+	require.Equal(t, codes.Unavailable, status.Code(err))
+	require.Equal(t, "backend unavailable: 100%", status.Convert(err).Message())
+
+	err = call("http")
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	start := time.Now()
+	require.NoError(t, call("none"))
+	require.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+	info := <-srv.info
+	require.Equal(t, 2, info.MatchedResourceIndex)
+	require.Equal(t, 20*time.Millisecond, info.Delay)
+}
+
+func TestUnaryServerInterceptorDrop(t *testing.T) {
+	client, _ := NewClient(t, `
+resources:
+  - grpc:
+      service: grpc.health.v1.Health
+    effect:
+      drop:
+        rate: 1
+`)
+	_, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+	require.Equal(t, codes.Unavailable, status.Code(err))
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	client, srv := NewClient(t, `
+resources:
+  - grpc:
+      service: grpc.health.v1.Health
+      method: Watch
+    headers:
+      X-Fail: ["1"]
+    effect:
+      grpc-status:
+        code: 8
+  - grpc:
+      service: grpc.health.v1.Health
+      method: Watch
+`)
+	watch := func(fail string) error {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "x-fail", fail)
+		s, err := client.Watch(ctx, &healthpb.HealthCheckRequest{})
+		require.NoError(t, err)
+		_, err = s.Recv()
+		return err
+	}
+
+	err := watch("1")
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	err = watch("0")
+	require.ErrorIs(t, err, io.EOF)
+	require.Equal(t, 1, (<-srv.info).MatchedResourceIndex)
+}
@@ -0,0 +1,83 @@
+package httpsim_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim/config"
+)
+
+func TestHangUntilDisconnect(t *testing.T) {
+	conf := config.Config{
+		Resources: []config.Resource{
+			{Effect: &config.Effect{Hang: &config.Hang{}}},
+		},
+	}
+	_, s := NewSimulator(t, conf, func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("next handler must not be invoked")
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	req := NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody)
+	req = req.WithContext(ctx)
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	rec := httptest.NewRecorder()
+	require.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		s.ServeHTTP(rec, req)
+	})
+	require.False(t, rec.Flushed)
+	require.Zero(t, rec.Body.Len())
+}
+
+func TestHangMaxIgnoreDisconnect(t *testing.T) {
+	conf := config.Config{
+		Resources: []config.Resource{
+			{Effect: &config.Effect{Hang: &config.Hang{
+				Max:              20 * time.Millisecond,
+				IgnoreDisconnect: true,
+			}}},
+		},
+	}
+	_, s := NewSimulator(t, conf, func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("next handler must not be invoked")
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel() // The client is gone already.
+	req := NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody)
+	req = req.WithContext(ctx)
+
+	start := time.Now()
+	require.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		s.ServeHTTP(httptest.NewRecorder(), req)
+	})
+	require.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+}
+
+func TestHangClientTimeout(t *testing.T) {
+	conf := config.Config{
+		Resources: []config.Resource{
+			{Effect: &config.Effect{Hang: &config.Hang{Max: time.Minute}}},
+		},
+	}
+	_, s := NewSimulator(t, conf, func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("next handler must not be invoked")
+	})
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	c := &http.Client{Timeout: 50 * time.Millisecond}
+	resp, err := c.Get(srv.URL)
+	if resp != nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}
+	var netErr interface{ Timeout() bool }
+	require.True(t, errors.As(err, &netErr) && netErr.Timeout())
+}
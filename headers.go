@@ -0,0 +1,68 @@
+package httpsim
+
+import (
+	"net/http"
+
+	"github.com/romshark/httpsim/config"
+)
+
+// headerWriter applies a header mutation right before
+// the status and headers are written.
+type headerWriter struct {
+	http.ResponseWriter
+	mutation *config.HeaderMutation
+	captures map[string]string
+
+	wroteHeader bool
+}
+
+func (w *headerWriter) WriteHeader(statusCode int) {
+	if !w.wroteHeader && statusCode >= 200 {
+		w.wroteHeader = true
+		mutateHeader(w.Header(), w.mutation, w.captures)
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *headerWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// FlushError makes sure flushing doesn't bypass the mutation.
+func (w *headerWriter) FlushError() error {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap allows http.ResponseController to access the underlying writer.
+func (w *headerWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+// finish makes sure the headers are mutated even if nothing was written
+// before net/http writes them implicitly.
+func (w *headerWriter) finish() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func mutateHeader(h http.Header, m *config.HeaderMutation, captures map[string]string) {
+	for _, name := range m.Remove {
+		h.Del(string(name))
+	}
+	for name, value := range m.Set {
+		h.Set(string(name), ExpandTemplate(value, captures))
+	}
+	for name, value := range m.Add {
+		if _, ok := h[http.CanonicalHeaderKey(string(name))]; !ok {
+			h.Set(string(name), ExpandTemplate(value, captures))
+		}
+	}
+	for name, value := range m.Append {
+		h.Add(string(name), ExpandTemplate(value, captures))
+	}
+}
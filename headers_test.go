@@ -0,0 +1,94 @@
+package httpsim_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim/config"
+)
+
+func TestResponseHeaders(t *testing.T) {
+	f := func(
+		m config.HeaderMutation, next http.HandlerFunc,
+		expectCode int, expectHeader http.Header, expectBody string,
+	) {
+		t.Helper()
+		_, s := NewSimulator(t, config.Config{
+			Resources: []config.Resource{{
+				PathRegexp: NewRegexp(t, `^/(?P<region>\w+)$`),
+				Effect:     &config.Effect{ResponseHeaders: &m},
+			}},
+		}, next)
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, NewRequest(t, http.MethodGet, "https://host.io/eu", http.NoBody))
+		require.Equal(t, expectCode, rec.Code)
+		require.Equal(t, expectHeader, rec.Header())
+		require.Equal(t, expectBody, rec.Body.String())
+	}
+
+	f(config.HeaderMutation{
+		Remove: []config.HeaderName{"ETag"},
+		Set:    map[config.HeaderName]string{"X-Cache": "MISS"},
+		Add: map[config.HeaderName]string{
+			"Cache-Control": "no-store",
+			"X-Region":      "${region}",
+		},
+		Append: map[config.HeaderName]string{"Vary": "Accept-Encoding"},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"abc"`)
+		w.Header().Set("X-Cache", "HIT")
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Vary", "Accept")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = io.WriteString(w, "created")
+	}, http.StatusCreated, http.Header{
+		"X-Cache":       {"MISS"},
+		"Cache-Control": {"max-age=60"},
+		"X-Region":      {"eu"},
+		"Vary":          {"Accept", "Accept-Encoding"},
+	}, "created")
+
+	// Implicit status.
+	f(config.HeaderMutation{
+		Set: map[config.HeaderName]string{"X-Cache": "MISS"},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "ok")
+	}, http.StatusOK, http.Header{"X-Cache": {"MISS"}}, "ok")
+
+	// Nothing written.
+	f(config.HeaderMutation{
+		Set: map[config.HeaderName]string{"X-Cache": "MISS"},
+	}, func(w http.ResponseWriter, r *http.Request) {},
+		http.StatusOK, http.Header{"X-Cache": {"MISS"}}, "")
+
+	// Flushed.
+	f(config.HeaderMutation{
+		Set: map[config.HeaderName]string{"X-Cache": "MISS"},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, http.NewResponseController(w).Flush())
+	}, http.StatusOK, http.Header{"X-Cache": {"MISS"}}, "")
+}
+
+func TestResponseHeadersReplaced(t *testing.T) {
+	_, s := NewSimulator(t, config.Config{
+		Resources: []config.Resource{{Effect: &config.Effect{
+			Replace: &config.Replace{
+				StatusCode: http.StatusNotFound,
+				Headers:    map[config.HeaderName]string{"X-Replaced": "yes"},
+			},
+			ResponseHeaders: &config.HeaderMutation{
+				Append: map[config.HeaderName]string{"X-Replaced": "mutated"},
+			},
+		}}},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("next handler must not be invoked")
+	})
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody))
+	require.Equal(t, http.StatusNotFound, rec.Code)
+	require.Equal(t, []string{"yes", "mutated"}, rec.Header().Values("X-Replaced"))
+}
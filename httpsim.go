@@ -5,9 +5,14 @@ package httpsim
 
 import (
 	"context"
+	"fmt"
 	"io"
 	"net/http"
-	"slices"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -19,10 +24,14 @@ import (
 type Config = config.Config
 
 // LoadConfig loads config from arbitrary reader.
-func LoadConfig(src io.Reader) (*Config, error) { return config.Load(src) }
+func LoadConfig(src io.Reader, opts ...config.LoadOption) (*Config, error) {
+	return config.Load(src, opts...)
+}
 
 // LoadConfigFile loads config from file.
-func LoadConfigFile(file string) (*Config, error) { return config.LoadFile(file) }
+func LoadConfigFile(file string, opts ...config.LoadOption) (*Config, error) {
+	return config.LoadFile(file, opts...)
+}
 
 // CtxKey is a context.Context key type.
 type CtxKey int8
@@ -43,6 +52,20 @@ type CtxInfo struct {
 	MatchedResourceIndex int
 	Delay                time.Duration
 	Replaced             bool
+
+	// Captures holds the values of named capture groups
+	// of the matched resource's regular expressions.
+	Captures map[string]string
+
+	// Dropped is true if the connection would have been dropped
+	// in dry-run mode. The next handler isn't invoked otherwise.
+	Dropped bool
+
+	// DryRun is true if the effects weren't applied because
+	// the config is in dry-run mode, in which case Delay, Replaced
+	// and Dropped reflect what would have been done, except for
+	// max-concurrent and CDN effects, which aren't accounted for.
+	DryRun bool
 }
 
 // RandProvider is a random values generator.
@@ -51,6 +74,13 @@ type RandProvider interface {
 	Dur(min, max time.Duration) time.Duration
 	// Bool returns a random boolean value.
 	Bool() bool
+	// Float64 returns a random float64 in the half-open interval [0.0,1.0).
+	Float64() float64
+	// NormFloat64 returns a normally distributed float64
+	// with mean 0 and standard deviation 1.
+	NormFloat64() float64
+	// ExpFloat64 returns an exponentially distributed float64 with rate 1.
+	ExpFloat64() float64
 }
 
 // Seed is a randomness seed.
@@ -81,7 +111,10 @@ func (defaultRand) Seed() Seed { return Seed(defaultSeed) }
 func (defaultRand) Dur(min, max time.Duration) time.Duration {
 	return defaultRnd.Dur(min, max)
 }
-func (defaultRand) Bool() bool { return defaultRnd.Bool() }
+func (defaultRand) Bool() bool           { return defaultRnd.Bool() }
+func (defaultRand) Float64() float64     { return defaultRnd.Float64() }
+func (defaultRand) NormFloat64() float64 { return defaultRnd.NormFloat64() }
+func (defaultRand) ExpFloat64() float64  { return defaultRnd.ExpFloat64() }
 
 // Sleeper is an abstract sleep. Use `DefaultSleep` for `time.Sleep`.
 type Sleeper interface{ Sleep(time.Duration) }
@@ -91,30 +124,253 @@ type defaultSleep int8
 // DefaultSleep is the default system sleep `time.Sleep`.
 const DefaultSleep defaultSleep = 1
 
-var _ Sleeper = DefaultSleep
+var _ CtxSleeper = DefaultSleep
 
 func (defaultSleep) Sleep(d time.Duration) { time.Sleep(d) }
 
+func (defaultSleep) SleepCtx(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// CtxSleeper is a Sleeper that can be interrupted.
+// If the Sleeper passed to NewMiddleware implements CtxSleeper then
+// delays are aborted as soon as the client disconnects.
+type CtxSleeper interface {
+	Sleeper
+	// SleepCtx sleeps for d or until ctx is canceled,
+	// in which case it returns ctx.Err().
+	SleepCtx(ctx context.Context, d time.Duration) error
+}
+
+// Clock is a Sleeper that provides the current time.
+// If the Sleeper passed to NewMiddleware implements Clock then it's used
+// as the time source of time-dependent effects such as bursts,
+// otherwise time.Now is used.
+type Clock interface {
+	Sleeper
+	Now() time.Time
+}
+
+func (m *Middleware) now() time.Time {
+	if c, ok := m.sleeper.(Clock); ok {
+		return c.Now()
+	}
+	return time.Now()
+}
+
+// sleep sleeps for d and returns ctx.Err() if ctx was canceled.
+// The sleep ends early without error if m is closed.
+// If the sleeper doesn't implement CtxSleeper the sleep isn't interrupted.
+func (m *Middleware) sleep(ctx context.Context, d time.Duration) error {
+	if s, ok := m.sleeper.(CtxSleeper); ok {
+		sctx, cancel := m.closing(ctx)
+		defer cancel()
+		if s.SleepCtx(sctx, d) != nil {
+			return ctx.Err()
+		}
+		return nil
+	}
+	m.sleeper.Sleep(d)
+	return ctx.Err()
+}
+
 // Middleware implements the http.Handler interface.
 type Middleware struct {
-	rand    RandProvider
-	config  atomic.Value
-	sleeper Sleeper
-	next    http.Handler
+	rand     RandProvider
+	state    atomic.Pointer[state]
+	sleeper  Sleeper
+	next     http.Handler
+	files    *fileCache
+	logger   atomic.Pointer[logger]
+	observer atomic.Pointer[Observer]
+	disabled bool
+
+	scriptEngine atomic.Pointer[ScriptEngine]
+
+	// closed is canceled by close when the middleware is closed.
+	closed         context.Context
+	close          context.CancelFunc
+	background     sync.WaitGroup
+	backgroundLock sync.Mutex
+}
+
+// state is the configuration and the runtime state of its resources.
+type state struct {
+	// source is the config as set, conf is the effective config.
+	source    config.Config
+	conf      *config.Config
+	resources []resourceState
+
+	// global is the state of the global effect.
+	global *counters
+
+	// globalMetrics are the metrics of the requests
+	// matched by no resource but the global effect.
+	globalMetrics metrics
+
+	// cdn maps the CDN effects to their *cdnCache.
+	cdn sync.Map
+
+	// retries maps the RetryStorm effects to their *retryTracker.
+	retries sync.Map
+
+	// scripts maps scriptKey to its *compiledScript.
+	scripts sync.Map
+
+	// start is the time time-dependent effects, such as bursts, are relative to.
+	start time.Time
+}
+
+func newState(c *config.Config, start time.Time) *state {
+	source := *c
+	effective := c.WithScenario().WithDefaults()
+	c = &effective
+	s := &state{
+		source:    source,
+		conf:      c,
+		resources: make([]resourceState, len(c.Resources)),
+		global:    newCounters(&config.Resource{Effect: c.GlobalEffect}),
+		start:     start,
+	}
+	for i := range c.Resources {
+		s.resources[i].global = newCounters(&c.Resources[i])
+	}
+	return s
 }
 
-// SetConfig changes the configuration of the middleware.
+// resourceState is the runtime state of a single resource.
+type resourceState struct {
+	sloRequests        atomic.Uint64
+	sloLatencyBreaches atomic.Uint64
+	sloErrors          atomic.Uint64
+	matches            atomic.Uint64
+	metrics            metrics
+
+	// global is used unless the resource is keyed by client.
+	global *counters
+
+	// clients holds the counters of the clients if keyed by client.
+	clients clientCounters
+}
+
+// counters is the state of a resource tracked either globally
+// or per client if the resource is keyed by client.
+type counters struct {
+	sequence atomic.Uint64
+	matches  atomic.Uint64
+
+	// semaphores holds the in-flight request slots of every effect
+	// that limits concurrency.
+	semaphores map[*config.Effect]chan struct{}
+}
+
+func newCounters(r *config.Resource) *counters {
+	return &counters{semaphores: newSemaphores(r)}
+}
+
+// counters returns the counters of the client r belongs to
+// or the global counters if c isn't keyed by client.
+func (s *resourceState) counters(c *config.Resource, r *http.Request) *counters {
+	if c.KeyBy == nil {
+		return s.global
+	}
+	max := int(c.KeyBy.MaxClients)
+	if max == 0 {
+		max = config.DefaultMaxClients
+	}
+	return s.clients.get(ClientKey(r, c.KeyBy), max,
+		func() *counters { return newCounters(c) })
+}
+
+// eachCounters calls fn for the global and all client counters.
+func (s *resourceState) eachCounters(fn func(*counters)) {
+	fn(s.global)
+	s.clients.each(fn)
+}
+
+// SetConfig changes the configuration of the middleware
+// and resets the runtime state of all resources.
+// Time-dependent effects, such as bursts, continue where they are.
 // SetConfig is safe for concurrent use at runtime.
-func (m *Middleware) SetConfig(c config.Config) { m.config.Store(&c) }
+func (m *Middleware) SetConfig(c config.Config) {
+	m.state.Store(newState(&c, m.state.Load().start))
+}
+
+// Reset resets the entire runtime state keeping the config: sequences,
+// match counts, per-client state, concurrency slots, CDN caches,
+// retry tracking, metrics and stats. Time-dependent effects, such as bursts
+// and DNS failovers, restart from the beginning. This allows reusing one
+// middleware across test cases without them affecting each other.
+// Requests in flight during Reset are accounted to the previous state.
+// Reset is safe for concurrent use at runtime.
+func (m *Middleware) Reset() {
+	m.state.Store(newState(&m.state.Load().source, m.now()))
+}
+
+// Config returns the current configuration as set.
+// Config is safe for concurrent use at runtime.
+func (m *Middleware) Config() config.Config { return m.state.Load().source }
+
+// ResetSequences restarts the sequences of all resources.
+// ResetSequences is safe for concurrent use at runtime.
+func (m *Middleware) ResetSequences() {
+	s := m.state.Load()
+	for i := range s.resources {
+		s.resources[i].eachCounters(func(c *counters) { c.sequence.Store(0) })
+	}
+}
+
+// MatchCounts returns the number of requests matched by each resource
+// since the configuration was set or the counts were last reset,
+// regardless of the clients resources are keyed by.
+// MatchCounts is safe for concurrent use at runtime.
+func (m *Middleware) MatchCounts() []uint64 {
+	s := m.state.Load()
+	c := make([]uint64, len(s.resources))
+	for i := range s.resources {
+		c[i] = s.resources[i].matches.Load()
+	}
+	return c
+}
+
+// ResetMatchCounts resets the match counts of all resources,
+// which effects with a match threshold depend on.
+// ResetMatchCounts is safe for concurrent use at runtime.
+func (m *Middleware) ResetMatchCounts() {
+	s := m.state.Load()
+	s.global.matches.Store(0)
+	for i := range s.resources {
+		s.resources[i].matches.Store(0)
+		s.resources[i].eachCounters(func(c *counters) { c.matches.Store(0) })
+	}
+}
 
 var _ http.Handler = new(Middleware)
 
+// EnvDisabled is the environment variable that, if set to a true value
+// such as "1" or "true", disables all middleware created by NewMiddleware
+// turning them into pure pass-throughs regardless of their config.
+const EnvDisabled = "HTTPSIM_DISABLED"
+
+// Option configures the middleware on creation.
+type Option func(*Middleware)
+
 // NewMiddleware creates a new middleware instance.
+// The middleware passes all requests through if it's disabled by EnvDisabled.
 // Use `DefaultSleep` for sleeper
 // (other implementations of Sleeper should only be used for testing purposes).
 // Use `DefaultRand` for rnd if not sure.
+// next may be nil if the middleware is only used through Wrap.
 func NewMiddleware(
 	next http.Handler, c config.Config, sleeper Sleeper, rnd RandProvider,
+	opts ...Option,
 ) *Middleware {
 	if sleeper == nil {
 		sleeper = DefaultSleep
@@ -122,103 +378,782 @@ func NewMiddleware(
 	if rnd == nil {
 		rnd = DefaultRand
 	}
-	m := &Middleware{rand: rnd, sleeper: sleeper, next: next}
-	m.config.Store(&c)
+	disabled, _ := strconv.ParseBool(os.Getenv(EnvDisabled))
+	m := &Middleware{
+		rand: rnd, sleeper: sleeper, next: next, files: newFileCache(),
+		disabled: disabled,
+	}
+	m.closed, m.close = context.WithCancel(context.Background())
+	m.state.Store(newState(&c, m.now()))
+	for _, opt := range opts {
+		opt(m)
+	}
 	return m
 }
 
 func (m *Middleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	conf := m.config.Load().(*config.Config)
-	matchedResourceIndex := Match(r, conf)
+	m.serve(w, r, m.next)
+}
+
+// Wrap returns a handler passing requests through to next instead of
+// the handler the middleware was created with, which allows using the
+// middleware with routers that chain func(http.Handler) http.Handler,
+// such as chi. All handlers returned by Wrap share the config
+// and the runtime state of m.
+func (m *Middleware) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.serve(w, r, next)
+	})
+}
+
+func (m *Middleware) serve(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	s := m.state.Load()
+	if m.disabled || !s.conf.IsEnabled() || m.isClosed() {
+		next.ServeHTTP(w, r)
+		return
+	}
+	matchedResourceIndex, captures := match(r, s.conf)
+	if matchedResourceIndex == -1 && s.conf.GlobalEffect == nil {
+		m.log(r, &CtxInfo{MatchedResourceIndex: -1}, false)
+		m.observe().OnPassThrough(r)
+		next.ServeHTTP(w, r)
+		return
+	}
+	ctxInfo := CtxInfo{
+		MatchedResourceIndex: matchedResourceIndex,
+		Captures:             captures,
+		DryRun:               s.conf.DryRun,
+	}
+	metrics := &s.globalMetrics
 	if matchedResourceIndex != -1 {
-		ctxInfo := CtxInfo{MatchedResourceIndex: matchedResourceIndex}
-		ctx := r.Context()
-		effect := conf.Resources[matchedResourceIndex].Effect
-		if effect != nil {
-			ctxInfo.Delay, ctxInfo.Replaced = m.apply(
-				w, conf.Resources[matchedResourceIndex].Effect,
-			)
-		}
-		ctx = context.WithValue(ctx, CtxKeyInfo, ctxInfo)
-		r = r.WithContext(ctx)
-		if ctxInfo.Replaced {
+		metrics = &s.resources[matchedResourceIndex].metrics
+	}
+	metrics.matches.Add(1)
+	o := m.observe()
+	o.OnMatch(r, matchedResourceIndex)
+	defer func(r *http.Request) {
+		metrics.observe(&ctxInfo)
+		m.log(r, &ctxInfo, true)
+	}(r)
+
+	var effects []gatedEffect
+	if e := s.conf.GlobalEffect; e != nil {
+		effects = append(effects, gatedEffect{
+			effect: e, counters: s.global, matches: s.global.matches.Add(1),
+		})
+	}
+
+	var debug *debugHeaderWriter
+	if s.conf.DebugHeaders {
+		debug = &debugHeaderWriter{
+			ResponseWriter: w, info: &ctxInfo, resource: "global",
+		}
+		if matchedResourceIndex != -1 {
+			debug.resource = s.conf.Resources[matchedResourceIndex].Name
+			if debug.resource == "" {
+				debug.resource = strconv.Itoa(matchedResourceIndex)
+			}
+		}
+		w = debug
+	}
+
+	var resource *config.Resource
+	var rec *statusRecorder
+	if matchedResourceIndex != -1 {
+		resource = &s.conf.Resources[matchedResourceIndex]
+		if resource.SLO != nil {
+			rec = &statusRecorder{ResponseWriter: w}
+			w = rec
+		}
+
+		s.resources[matchedResourceIndex].matches.Add(1)
+		counters := s.resources[matchedResourceIndex].counters(resource, r)
+		matches := counters.matches.Add(1)
+		chain := resource.Chain()
+		if resource.Sequence != nil {
+			n := counters.sequence.Add(1) - 1
+			if step, ok := resource.Sequence.Step(n); ok {
+				chain = nil
+				if step.Effect != nil {
+					chain = []*config.Effect{step.Effect}
+				}
+			}
+		}
+		for _, e := range chain {
+			effects = append(effects, gatedEffect{
+				effect: e, counters: counters, matches: matches,
+			})
+		}
+	}
+
+	var reset bool
+	for _, g := range effects {
+		effect := g.effect
+		if g.matches <= effect.AfterMatches {
+			continue // The threshold isn't reached yet.
+		}
+		if effect.Bursts != nil && !effect.Bursts.Active(m.now().Sub(s.start)) {
+			continue // Outside of the outage windows.
+		}
+		if effect.Probability != nil &&
+			m.rand.Float64() >= float64(*effect.Probability) {
+			continue // The effect doesn't fire this time.
+		}
+		if ctxInfo.DryRun {
+			if m.dryRun(r, effect, &ctxInfo) {
+				break
+			}
+			continue
+		}
+		if effect.MaxConcurrent != nil {
+			sem := g.counters.semaphores[effect]
+			if !acquire(r.Context(), sem, effect.MaxConcurrent) {
+				http.Error(w, http.StatusText(http.StatusServiceUnavailable),
+					http.StatusServiceUnavailable)
+				ctxInfo.Replaced = true
+				break
+			}
+			defer func() { <-sem }()
+		}
+		w = m.wrapWriter(w, r, effect, captures, &ctxInfo)
+		if m.apply(w, r, effect, captures, &ctxInfo) {
 			return
 		}
+		reset = reset || effect.Reset != nil
+		if ctxInfo.Replaced {
+			break // Subsequent effects don't apply to replaced responses.
+		}
+	}
+	if ctxInfo.Delay > 0 {
+		o.OnDelay(r, ctxInfo.Delay)
+	}
+	var downstream time.Duration
+	if ctxInfo.Replaced {
+		o.OnReplace(r, matchedResourceIndex)
+	}
+	if !ctxInfo.Replaced || ctxInfo.DryRun {
+		o.OnPassThrough(r)
+		if debug != nil {
+			debug.passedThrough = true
+		}
+		r = r.WithContext(context.WithValue(r.Context(), CtxKeyInfo, ctxInfo))
+		start, delay := time.Now(), ctxInfo.Delay
+		next.ServeHTTP(w, r)
+		// Response delays are part of ctxInfo.Delay already.
+		downstream = max(time.Since(start)-(ctxInfo.Delay-delay), 0)
+	}
+	finish(w)
+
+	if rec != nil {
+		s.resources[matchedResourceIndex].observeSLO(
+			resource.SLO, ctxInfo.Delay+downstream, rec.status(),
+		)
+	}
+	if reset {
+		resetResponse(w)
 	}
-	m.next.ServeHTTP(w, r)
+}
+
+// gatedEffect is an effect with the counters its gates are evaluated against.
+type gatedEffect struct {
+	effect   *config.Effect
+	counters *counters
+	matches  uint64
 }
 
 // Match returns the index of the matched resource, otherwise returns -1.
 func Match(r *http.Request, c *config.Config) int {
-	for i, res := range c.Resources {
-		if MatchResource(r, &res) {
-			return i
+	i, _ := match(r, c)
+	return i
+}
+
+func match(r *http.Request, c *config.Config) (int, map[string]string) {
+	g := &graphQLRequest{r: r}
+	for i := range c.Resources {
+		if captures, ok := matchResource(r, &c.Resources[i], g, nil); ok {
+			return i, captures
 		}
 	}
-	return -1
+	return -1, nil
 }
 
 // MatchResource returns true if r matches resource c, otherwise returns false.
 func MatchResource(r *http.Request, c *config.Resource) bool {
-	if len(c.Methods) > 0 && !slices.Contains(c.Methods, config.HTTPMethod(r.Method)) {
-		return false
+	_, ok := matchResource(r, c, &graphQLRequest{r: r}, nil)
+	return ok
+}
+
+// matchResource returns true if r matches resource c and the values
+// of the named capture groups of all regular expressions of c.
+// If ex is not nil, all matchers are evaluated and their results
+// are recorded in ex instead of stopping at the first mismatch.
+func matchResource(
+	r *http.Request, c *config.Resource, g *graphQLRequest, ex *explanation,
+) (captures map[string]string, ok bool) {
+	ok = true
+	mismatch := func(matcher, reason string) {
+		ok = false
+		ex.fail(matcher, reason)
+	}
+	if len(c.Methods) > 0 {
+		if !config.MatchMethod(c.Methods, r.Method) {
+			if ex == nil {
+				return nil, false
+			}
+			mismatch("methods", fmt.Sprintf("%s isn't any of %v", r.Method, c.Methods))
+		} else {
+			ex.pass("methods")
+		}
 	}
 	if !(*config.GlobExpression)(&c.Path).Match(r.URL.Path) {
-		return false
+		if ex == nil {
+			return nil, false
+		}
+		mismatch("path", fmt.Sprintf("%q doesn't match %q", r.URL.Path, c.Path.String()))
+	} else if c.Path.String() != "" {
+		ex.pass("path")
+	}
+	if host := Hostname(r); !c.Host.Match(host) {
+		if ex == nil {
+			return nil, false
+		}
+		mismatch("host", fmt.Sprintf("%q doesn't match %q", host, c.Host.String()))
+	} else if c.Host.String() != "" {
+		ex.pass("host")
 	}
-	for name, values := range c.Headers {
-		for header, val := range r.Header {
-			if !name.Match(header) {
-				continue // This header isn't mentioned in the config.
+	if len(c.Headers) > 0 {
+		if header, ok := matchGlobMap(c.Headers, r.Header, false); !ok {
+			if ex == nil {
+				return nil, false
 			}
-			// This header is mentioned, make sure the value matches.
-			if len(val) != len(values) {
-				return false // Header values mismatch.
+			mismatch("headers", fmt.Sprintf("values of header %s %q don't match",
+				header, r.Header.Values(header)))
+		} else {
+			ex.pass("headers")
+		}
+	}
+	if c.HeaderCount != nil || c.HeaderBytes != nil {
+		count, size := HeaderStats(r.Header)
+		if c.HeaderCount != nil {
+			if !c.HeaderCount.Contains(count) {
+				if ex == nil {
+					return nil, false
+				}
+				mismatch("header-count", fmt.Sprintf("%d header values aren't within %d-%d",
+					count, c.HeaderCount.Min, c.HeaderCount.Max))
+			} else {
+				ex.pass("header-count")
 			}
-			for i, val := range val {
-				if !values[i].Match(val) {
-					return false // Header value mismatch.
+		}
+		if c.HeaderBytes != nil {
+			if !c.HeaderBytes.Contains(size) {
+				if ex == nil {
+					return nil, false
 				}
+				mismatch("header-bytes", fmt.Sprintf("%d header bytes aren't within %d-%d",
+					size, c.HeaderBytes.Min, c.HeaderBytes.Max))
+			} else {
+				ex.pass("header-bytes")
 			}
 		}
 	}
-	for name, values := range c.Query {
-		for parameter, val := range r.URL.Query() {
-			if !name.Match(parameter) {
-				continue // This parameter isn't mentioned in the config.
+	if len(c.Query) > 0 {
+		query := r.URL.Query()
+		if parameter, ok := matchGlobMap(c.Query, query, c.QueryRequired); !ok {
+			if ex == nil {
+				return nil, false
+			}
+			if _, present := query[parameter]; present {
+				mismatch("query", fmt.Sprintf("values of parameter %s %q don't match",
+					parameter, query[parameter]))
+			} else {
+				mismatch("query", fmt.Sprintf("required parameter %s is missing", parameter))
 			}
-			// This query parameter is mentioned, make sure the value matches.
+		} else {
+			ex.pass("query")
+		}
+	}
+	if re := c.PathRegexp.Regexp(); re != nil {
+		if !matchRegexp(re, r.URL.Path, &captures) {
+			if ex == nil {
+				return nil, false
+			}
+			mismatch("path-regexp", fmt.Sprintf("%q doesn't match %q", r.URL.Path, re))
+		} else {
+			ex.pass("path-regexp")
+		}
+	}
+	if len(c.HeadersRegexp) > 0 {
+		headersOK := true
+		for name, expr := range c.HeadersRegexp {
+			for header, val := range r.Header {
+				if !name.Match(header) {
+					continue // This header isn't mentioned in the config.
+				}
+				for _, val := range val {
+					if !matchRegexp(expr.Regexp(), val, &captures) {
+						if ex == nil {
+							return nil, false // Header value mismatch.
+						}
+						headersOK = false
+						mismatch("headers-regexp", fmt.Sprintf(
+							"value of header %s %q doesn't match %q", header, val, expr.Regexp()))
+					}
+				}
+			}
+		}
+		if headersOK {
+			ex.pass("headers-regexp")
+		}
+	}
+	if c.GraphQL != nil {
+		if !g.match(c.GraphQL) {
+			if ex == nil {
+				return nil, false
+			}
+			mismatch("graphql", "the request isn't a matching GraphQL operation")
+		} else {
+			ex.pass("graphql")
+		}
+	}
+	if c.GRPC != nil {
+		if !matchGRPC(r, c.GRPC) {
+			if ex == nil {
+				return nil, false
+			}
+			mismatch("grpc", "the request isn't a matching gRPC call")
+		} else {
+			ex.pass("grpc")
+		}
+	}
+	if c.RangeRequest != nil {
+		if isRange := r.Header.Get("Range") != ""; *c.RangeRequest != isRange {
+			if ex == nil {
+				return nil, false
+			}
+			mismatch("range-request", fmt.Sprintf("range request is %t", isRange))
+		} else {
+			ex.pass("range-request")
+		}
+	}
+	if c.Conditional != nil {
+		if isConditional := IsConditional(r); *c.Conditional != isConditional {
+			if ex == nil {
+				return nil, false
+			}
+			mismatch("conditional", fmt.Sprintf("conditional is %t", isConditional))
+		} else {
+			ex.pass("conditional")
+		}
+	}
+	if !ok {
+		return nil, false
+	}
+	return captures, true
+}
+
+// matchGlobMap returns true if the values of all keys of v matched
+// by the globs of m match the globs they're mapped to. If required
+// is true, every glob of m must match at least one key of v.
+// Otherwise returns false and the mismatching key, or the glob
+// expression matching no key if required.
+func matchGlobMap(
+	m config.GlobMap[[]config.GlobExpression], v map[string][]string, required bool,
+) (string, bool) {
+	for name, values := range m {
+		present := false
+		for key, val := range v {
+			if !name.Match(key) {
+				continue // This key isn't mentioned in the config.
+			}
+			present = true
+			// This key is mentioned, make sure the value matches.
 			if len(val) != len(values) {
-				return false // Query parameter values mismatch.
+				return key, false // Values mismatch.
 			}
 			for i, val := range val {
 				if !values[i].Match(val) {
-					return false // Query parameter value mismatch.
+					return key, false // Value mismatch.
 				}
 			}
 		}
+		if required && !present {
+			return name.String(), false // Required key is missing.
+		}
+	}
+	return "", true
+}
+
+// matchRegexp returns true if re matches s and writes the values
+// of all named capture groups to captures.
+// Unmatched groups are written as empty strings.
+func matchRegexp(re *regexp.Regexp, s string, captures *map[string]string) bool {
+	if re == nil {
+		return true
+	}
+	m := re.FindStringSubmatch(s)
+	if m == nil {
+		return false
+	}
+	for i, name := range re.SubexpNames() {
+		if name == "" {
+			continue
+		}
+		if *captures == nil {
+			*captures = make(map[string]string)
+		}
+		(*captures)[name] = m[i]
 	}
 	return true
 }
 
+// ExpandTemplate replaces all occurrences of `${name}` in s with the value
+// of the capture group name. Placeholders of unknown groups are left as is.
+func ExpandTemplate(s string, captures map[string]string) string {
+	if len(captures) == 0 || !strings.Contains(s, "${") {
+		return s
+	}
+	var b strings.Builder
+	for {
+		start := strings.Index(s, "${")
+		if start == -1 {
+			break
+		}
+		end := strings.IndexByte(s[start:], '}')
+		if end == -1 {
+			break
+		}
+		end += start
+		v, ok := captures[s[start+2:end]]
+		if !ok {
+			b.WriteString(s[:end+1])
+			s = s[end+1:]
+			continue
+		}
+		b.WriteString(s[:start])
+		b.WriteString(v)
+		s = s[end+1:]
+	}
+	b.WriteString(s)
+	return b.String()
+}
+
+// HeaderStats returns the total number of header values in h and
+// the cumulative size of all header names and values in bytes.
+func HeaderStats(h http.Header) (count, size uint64) {
+	for name, values := range h {
+		for _, v := range values {
+			count++
+			size += uint64(len(name) + len(v))
+		}
+	}
+	return count, size
+}
+
+// wrapWriter wraps w with the writers implementing the response effects of c.
+// Delays applied while writing the response are added to info.
+func (m *Middleware) wrapWriter(
+	w http.ResponseWriter, r *http.Request,
+	c *config.Effect, captures map[string]string, info *CtxInfo,
+) http.ResponseWriter {
+	if k := c.KeepAlive; k != nil && m.rand.Float64() < float64(k.Rate) {
+		if k.Mode == config.KeepAliveModeClose && r.ProtoMajor < 2 {
+			// Innermost to close the connection after everything is written.
+			w = &keepAliveWriter{ResponseWriter: w, r: r}
+		} else {
+			w.Header().Set("Connection", "close")
+		}
+	}
+	if t := c.Throughput; t != nil {
+		w = newThrottledWriter(w, r.Context(), m, t.BytesPerSecond, t.Burst)
+	}
+	if c.DelayHeaders != nil || c.DelayBody != nil || c.DelayAfterHeaders != nil {
+		dw := &delayWriter{ResponseWriter: w, ctx: r.Context(), m: m, info: info}
+		if c.DelayHeaders != nil {
+			dw.headers = SampleDur(m.rand, c.DelayHeaders)
+		}
+		if c.DelayAfterHeaders != nil {
+			dw.afterHeaders = SampleDur(m.rand, c.DelayAfterHeaders)
+		}
+		if c.DelayBody != nil {
+			dw.body = SampleDur(m.rand, c.DelayBody)
+		}
+		w = dw
+	}
+	if c.Reset != nil {
+		if c.Reset.GoAway {
+			// HTTP/2 servers translate this into GOAWAY.
+			w.Header().Set("Connection", "close")
+		}
+		w = &resetWriter{ResponseWriter: w, limit: c.Reset.AfterBytes}
+	}
+	if c.Pad != nil && r.Method != http.MethodHead {
+		w = &padWriter{ResponseWriter: w, c: c.Pad}
+	}
+	if c.Rewrite != nil {
+		w = newRewriteWriter(w, c.Rewrite)
+	}
+	if c.ResponseHeaders != nil {
+		w = &headerWriter{
+			ResponseWriter: w, mutation: c.ResponseHeaders, captures: captures,
+		}
+	}
+	if ws := c.WebSocket; ws != nil && ws.CloseAfter != nil && IsWebSocketUpgrade(r) {
+		w = &closeAfterWriter{
+			ResponseWriter: w, m: m, after: SampleDur(m.rand, ws.CloseAfter),
+		}
+	}
+	if c.CDN != nil {
+		w = m.newCDNWriter(w, r, c.CDN, c, &m.state.Load().cdn)
+	}
+	if c.Record != nil {
+		// Outermost to capture the response as written by the next handler.
+		w = newRecordWriter(w, r, c.Record, func(err error) {
+			m.observe().OnError(r, err)
+		})
+	}
+	return w
+}
+
 // apply returns true if the request is handled and no further handling should be done,
-// otherwise returns false.
-func (m *Middleware) apply(w http.ResponseWriter, c *config.Effect) (
-	delay time.Duration, replaced bool,
-) {
+// otherwise returns false. apply records the applied effects in info.
+func (m *Middleware) apply(
+	w http.ResponseWriter, r *http.Request,
+	c *config.Effect, captures map[string]string, info *CtxInfo,
+) bool {
+	if t, ok := r.Context().Value(roundTripCtxKey{}).(*roundTripWriter); ok {
+		if err := m.dial(r, c, info); err != nil {
+			t.fail(err)
+		}
+	}
+	if s := c.RetryStorm; s != nil {
+		if n := m.retries(r, s, c); n > s.Threshold {
+			if s.DropAfter != 0 && n > s.DropAfter {
+				info.Dropped = true
+				m.drop(w, r, &config.Drop{})
+				return true
+			}
+			rejectRetry(w, s, n)
+			info.Replaced = true
+			return false
+		}
+	}
+	if c.CDN != nil {
+		if cw, ok := cdnWriterOf(w); ok && cw.serve() {
+			info.Replaced = true
+			return false // The backend isn't reached.
+		}
+	}
 	if c.Delay != nil {
-		delay = m.rand.Dur(c.Delay.Min, c.Delay.Max)
-		m.sleeper.Sleep(delay)
+		d := SampleDur(m.rand, c.Delay)
+		info.Delay += d
+		if m.sleep(r.Context(), d) != nil {
+			return true // The client is gone.
+		}
+	}
+	if c.Hang != nil {
+		m.hang(r.Context(), c.Hang)
+	}
+	if c.WebSocket != nil && IsWebSocketUpgrade(r) {
+		if c.WebSocket.UpgradeDelay != nil {
+			d := SampleDur(m.rand, c.WebSocket.UpgradeDelay)
+			info.Delay += d
+			if m.sleep(r.Context(), d) != nil {
+				return true // The client is gone.
+			}
+		}
+		if code := c.WebSocket.Reject; code != nil {
+			http.Error(w, http.StatusText(int(*code)), int(*code))
+			info.Replaced = true
+			return false
+		}
+	}
+	if c.Drop != nil && m.rand.Float64() < float64(c.Drop.Rate) {
+		info.Dropped = true
+		m.drop(w, r, c.Drop)
+		return true
+	}
+	if c.CORS != nil && cors(w, r, c.CORS) {
+		info.Replaced = true
+		return false
+	}
+	if c.NotModified != nil && IsConditional(r) &&
+		m.rand.Float64() < float64(c.NotModified.Rate) {
+		notModified(w, r, c.NotModified)
+		info.Replaced = true
+		return false
+	}
+	if c.Script != nil {
+		res, err := m.runScript(r, c.Script, captures)
+		if r.Context().Err() != nil {
+			return true // The client is gone.
+		}
+		if err != nil {
+			m.observe().OnError(r, err)
+			http.Error(w, "httpsim: "+err.Error(), http.StatusInternalServerError)
+			info.Replaced = true
+			return false
+		}
+		info.Delay += res.Delay
+		if m.sleep(r.Context(), res.Delay) != nil {
+			return true // The client is gone.
+		}
+		if res.Replace {
+			for name, values := range res.Header {
+				w.Header()[name] = values
+			}
+			w.WriteHeader(res.StatusCode)
+			_, _ = io.WriteString(w, res.Body)
+			info.Replaced = true
+			return false
+		}
+	}
+	replace := c.Replace
+	if len(c.ReplaceWeighted) > 0 {
+		replace = pickReplace(m.rand, c.ReplaceWeighted)
+	}
+	var body []byte
+	status := http.StatusOK
+	if replace != nil {
+		var variant *config.Variant
+		if len(replace.Variants) > 0 {
+			variant = negotiate(r.Header.Get("Accept"), replace.Variants)
+		}
+		var err error
+		if body, err = m.replaceBody(replace, variant, captures); err != nil {
+			m.observe().OnError(r, err)
+			http.Error(w, "httpsim: "+err.Error(), http.StatusInternalServerError)
+			info.Replaced = true
+			return false
+		}
+		for header, value := range replace.Headers {
+			w.Header().Set(string(header), ExpandTemplate(value, captures))
+		}
+		for i := range replace.Cookies {
+			http.SetCookie(w, replace.Cookies[i].HTTPCookie(func(s string) string {
+				return ExpandTemplate(s, captures)
+			}))
+		}
+		if variant != nil {
+			w.Header().Set("Content-Type", string(variant.ContentType))
+			w.Header().Add("Vary", "Accept")
+		}
+		if replace.Compress != nil {
+			if body, err = compress(w.Header(), r, replace.Compress, body); err != nil {
+				m.observe().OnError(r, err)
+				http.Error(w, "httpsim: "+err.Error(), http.StatusInternalServerError)
+				info.Replaced = true
+				return false
+			}
+		}
+		status = int(replace.StatusCode)
+		if replace.CacheHeaders != nil &&
+			m.cacheHeaders(w.Header(), r, replace.CacheHeaders, status, body) {
+			w.Header().Del("Content-Length")
+			w.WriteHeader(http.StatusNotModified)
+			info.Replaced = true
+			return false
+		}
+		if c.PartialContent != nil {
+			status, body = partialContent(w.Header(), r, c.PartialContent, status, body)
+		}
+	}
+	if c.Malformed != nil {
+		malformed(w, c.Malformed, status, body)
+		return true
+	}
+	if c.GRPCStatus != nil {
+		grpcStatus(w, c.GRPCStatus, captures)
+		info.Replaced = true
+		return false
+	}
+	if c.Redirect != nil {
+		redirect(w, r, c.Redirect, captures)
+		info.Replaced = true
+		return false
+	}
+	if c.Proxy != nil {
+		proxy(w, r, c.Proxy)
+		info.Replaced = true
+		return false
+	}
+	if c.Replay != nil && replay(w, r, c.Replay, func(err error) {
+		m.observe().OnError(r, err)
+	}) {
+		info.Replaced = true
+		return false
+	}
+	if replace != nil {
+		if replace.HasBody() &&
+			c.DelayBody != nil && w.Header().Get("Content-Length") == "" {
+			// Allows spreading the body delay across the body.
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		}
+		w.WriteHeader(status)
+		if replace.HasBody() {
+			if c.Stream != nil {
+				m.stream(r.Context(), w, body, c.Stream)
+			} else {
+				_, _ = w.Write(body)
+			}
+		}
+		info.Replaced = true
+	}
+	return false
+}
+
+// pickReplace picks one of the candidates with a probability
+// proportional to its weight.
+func pickReplace(rnd RandProvider, candidates []config.WeightedReplace) *config.Replace {
+	var total uint64
+	for _, c := range candidates {
+		total += uint64(c.Weight)
+	}
+	n := uint64(rnd.Float64() * float64(total))
+	for i := range candidates {
+		if w := uint64(candidates[i].Weight); n >= w {
+			n -= w
+			continue
+		}
+		return &candidates[i].Replace
 	}
-	if c.Replace != nil {
-		w.WriteHeader(int(c.Replace.StatusCode))
-		for header, value := range c.Replace.Headers {
-			w.Header().Set(string(header), value)
+	return &candidates[len(candidates)-1].Replace
+}
+
+// stream writes body in chunks, flushing the headers before the first
+// and each chunk after it's written.
+func (m *Middleware) stream(
+	ctx context.Context, w http.ResponseWriter, body []byte, c *config.Stream,
+) {
+	rc := http.NewResponseController(w)
+	_ = rc.Flush()
+	for len(body) > 0 {
+		if m.sleep(ctx, SampleDur(m.rand, &c.ChunkDelay)) != nil {
+			return // The client is gone.
 		}
-		if c.Replace.Body != nil {
-			_, _ = w.Write([]byte(*c.Replace.Body))
+		n := min(uint64(len(body)), c.ChunkSize)
+		if _, err := w.Write(body[:n]); err != nil {
+			return
 		}
-		return delay, true
+		_ = rc.Flush()
+		body = body[n:]
+	}
+}
+
+// hang blocks until ctx is canceled, unless disconnects are ignored,
+// the cap elapses or m is closed and then aborts the handler
+// without writing a response.
+func (m *Middleware) hang(ctx context.Context, c *config.Hang) {
+	if c.IgnoreDisconnect {
+		ctx = context.WithoutCancel(ctx)
+	}
+	ctx, cancel := m.closing(ctx)
+	defer cancel()
+	if c.Max > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Max)
+		defer cancel()
 	}
-	return delay, false
+	<-ctx.Done()
+	panic(http.ErrAbortHandler)
 }
@@ -38,6 +38,11 @@ func TestDefaultRand(t *testing.T) {
 	require.Equal(t, time.Second, p.Dur(time.Second, time.Second))
 	// We don't care about the result values, just make sure we can call it.
 	_ = p.Bool()
+	_ = p.NormFloat64()
+	require.GreaterOrEqual(t, p.ExpFloat64(), 0.0)
+	f := p.Float64()
+	require.GreaterOrEqual(t, f, 0.0)
+	require.Less(t, f, 1.0)
 }
 
 func TestDefaultSleep(t *testing.T) {
@@ -45,6 +50,62 @@ func TestDefaultSleep(t *testing.T) {
 	s.Sleep(0) // We just want to make sure we can call it.
 }
 
+func TestDefaultSleepCtx(t *testing.T) {
+	var s httpsim.CtxSleeper = httpsim.DefaultSleep
+	require.NoError(t, s.SleepCtx(context.Background(), 0))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start := time.Now()
+	err := s.SleepCtx(ctx, time.Hour)
+	require.ErrorIs(t, err, context.Canceled)
+	require.Less(t, time.Since(start), time.Second)
+}
+
+func TestHandleDelayCanceled(t *testing.T) {
+	conf := config.Config{
+		Resources: []config.Resource{
+			{Effect: &config.Effect{
+				Delay: &config.DurRange{Min: time.Hour, Max: time.Hour},
+			}},
+		},
+	}
+	s := httpsim.NewMiddleware(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			t.Fatal("next handler must not be invoked")
+		}),
+		conf, httpsim.DefaultSleep, httpsim.DefaultRand,
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	req := NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody)
+	req = req.WithContext(ctx)
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	start := time.Now()
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	require.Less(t, time.Since(start), time.Minute)
+	require.Zero(t, rec.Body.Len())
+}
+
+func TestHandleDelayCanceledPlainSleeper(t *testing.T) {
+	conf := config.Config{
+		Resources: []config.Resource{
+			{Effect: &config.Effect{
+				Delay: &config.DurRange{Min: time.Second, Max: time.Second},
+			}},
+		},
+	}
+	mockSleep, s := NewSimulator(t, conf, func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("next handler must not be invoked")
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody)
+	s.ServeHTTP(httptest.NewRecorder(), req.WithContext(ctx))
+	require.Equal(t, time.Second, mockSleep.Cumulative)
+}
+
 func TestNewSeedRand(t *testing.T) {
 	s := httpsim.NewSeedRand()
 	require.NotZero(t, s)
@@ -123,6 +184,45 @@ func TestMatchResource(t *testing.T) {
 		}(),
 		true,
 	)
+	f( // Required query parameter present.
+		config.Resource{
+			Query: config.GlobMap[[]config.GlobExpression]{
+				NewGlobExpression(t, "simulate"): []config.GlobExpression{
+					NewGlobExpression(t, "1"),
+				},
+			},
+			QueryRequired: true,
+		},
+		NewRequest(t, http.MethodGet, "https://host.io/?simulate=1", http.NoBody),
+		true,
+	)
+	f( // Any method.
+		config.Resource{
+			Methods: []config.HTTPMethod{config.MethodAny},
+		},
+		NewRequest(t, http.MethodPatch, "https://host.io/x", http.NoBody),
+		true,
+	)
+	f( // Method not excluded.
+		config.Resource{
+			Methods: []config.HTTPMethod{"!OPTIONS"},
+		},
+		NewRequest(t, http.MethodGet, "https://host.io/x", http.NoBody),
+		true,
+	)
+	f( // Header count and size within range.
+		config.Resource{
+			HeaderCount: &config.Uint64Range{Min: 2, Max: 2},
+			HeaderBytes: &config.Uint64Range{Min: 10},
+		},
+		func() *http.Request {
+			r := NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody)
+			r.Header.Set("X-Foo", "foo")
+			r.Header.Set("X-Bar", "bar")
+			return r
+		}(),
+		true,
+	)
 
 	/*** No match ***/
 
@@ -141,6 +241,25 @@ func TestMatchResource(t *testing.T) {
 		NewRequest(t, http.MethodDelete, "https://host.io/", http.NoBody),
 		false,
 	)
+	f( // Required query parameter missing.
+		config.Resource{
+			Query: config.GlobMap[[]config.GlobExpression]{
+				NewGlobExpression(t, "simulate"): []config.GlobExpression{
+					NewGlobExpression(t, "1"),
+				},
+			},
+			QueryRequired: true,
+		},
+		NewRequest(t, http.MethodGet, "https://host.io/?other=1", http.NoBody),
+		false,
+	)
+	f( // Method excluded.
+		config.Resource{
+			Methods: []config.HTTPMethod{config.MethodAny, "!OPTIONS"},
+		},
+		NewRequest(t, http.MethodOptions, "https://host.io/", http.NoBody),
+		false,
+	)
 	f( // Header value mismatch.
 		config.Resource{
 			Headers: map[config.GlobExpression][]config.GlobExpression{
@@ -207,6 +326,39 @@ func TestMatchResource(t *testing.T) {
 		}(),
 		false,
 	)
+	f( // Header count exceeds max.
+		config.Resource{
+			HeaderCount: &config.Uint64Range{Max: 1},
+		},
+		func() *http.Request {
+			r := NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody)
+			r.Header.Set("X-Foo", "foo")
+			r.Header.Set("X-Bar", "bar")
+			return r
+		}(),
+		false,
+	)
+	f( // Header bytes below min.
+		config.Resource{
+			HeaderBytes: &config.Uint64Range{Min: 8192},
+		},
+		func() *http.Request {
+			r := NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody)
+			r.Header.Set("X-Foo", "foo")
+			return r
+		}(),
+		false,
+	)
+}
+
+func TestHeaderStats(t *testing.T) {
+	h := http.Header{}
+	h.Add("X-Foo", "foo")
+	h.Add("X-Foo", "foo2")
+	h.Set("X-Bar", "")
+	count, size := httpsim.HeaderStats(h)
+	require.Equal(t, uint64(3), count)
+	require.Equal(t, uint64(5+3+5+4+5), size)
 }
 
 func TestMatch(t *testing.T) {
@@ -240,6 +392,13 @@ func NewGlobExpression(t *testing.T, expression string) config.GlobExpression {
 	return g
 }
 
+func NewRegexp(t *testing.T, expression string) config.Regexp {
+	t.Helper()
+	e, err := config.NewRegexp(expression)
+	require.NoError(t, err)
+	return e
+}
+
 func NewRequest(t *testing.T, method, url string, body io.Reader) *http.Request {
 	t.Helper()
 	r, err := http.NewRequest(method, url, body)
@@ -261,6 +420,204 @@ func NewDuration(t *testing.T, s string) time.Duration {
 	return d
 }
 
+func TestDefaults(t *testing.T) {
+	conf := config.Config{
+		Defaults: &config.Defaults{
+			Delay: &config.DurRange{Min: time.Second, Max: time.Second},
+			ReplaceHeaders: map[config.HeaderName]string{
+				"Content-Type": "application/json",
+			},
+		},
+		Resources: []config.Resource{{Effect: &config.Effect{
+			Replace: &config.Replace{StatusCode: http.StatusNotFound},
+		}}},
+	}
+	mockSleep, s := NewSimulator(t, conf, func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("next handler must not be invoked")
+	})
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody))
+	require.Equal(t, http.StatusNotFound, rec.Code)
+	require.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	require.Equal(t, time.Second, mockSleep.Cumulative)
+
+	// The config passed to the middleware isn't modified.
+	require.Nil(t, conf.Resources[0].Effect.Delay)
+	require.Nil(t, conf.Resources[0].Effect.Replace.Headers)
+}
+
+func TestScenario(t *testing.T) {
+	conf := config.Config{
+		Defaults: &config.Defaults{
+			Delay: &config.DurRange{Min: time.Second, Max: time.Second},
+		},
+		Resources: []config.Resource{{
+			Name: "checkout",
+			Path: NewGlobExpression(t, "/checkout"),
+		}},
+		Scenario: []config.Attachment{{
+			Resource: "checkout",
+			Effect: &config.Effect{
+				Replace: &config.Replace{StatusCode: http.StatusServiceUnavailable},
+			},
+		}},
+	}
+	mockSleep, s := NewSimulator(t, conf, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, NewRequest(t, http.MethodGet, "https://host.io/checkout", http.NoBody))
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	require.Equal(t, time.Second, mockSleep.Cumulative)
+
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, NewRequest(t, http.MethodGet, "https://host.io/other", http.NoBody))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	// The config passed to the middleware isn't modified.
+	require.Nil(t, conf.Resources[0].Effect)
+}
+
+func TestConfig(t *testing.T) {
+	conf := config.Config{
+		Resources: []config.Resource{{Name: "checkout"}},
+		Scenario: []config.Attachment{{
+			Resource: "checkout",
+			Effect: &config.Effect{
+				Replace: &config.Replace{StatusCode: http.StatusServiceUnavailable},
+			},
+		}},
+	}
+	_, s := NewSimulator(t, conf, func(w http.ResponseWriter, r *http.Request) {})
+	// The config is returned as set, without the scenario applied.
+	require.Equal(t, conf, s.Config())
+
+	s.SetConfig(config.Config{})
+	require.Equal(t, config.Config{}, s.Config())
+}
+
+func TestWrap(t *testing.T) {
+	conf := config.Config{
+		Resources: []config.Resource{
+			{Path: NewGlobExpression(t, "/fail"), Effect: &config.Effect{
+				Replace: &config.Replace{StatusCode: http.StatusServiceUnavailable},
+			}},
+			{Path: NewGlobExpression(t, "/*")},
+		},
+	}
+	require.NoError(t, config.Validate(conf))
+	s := httpsim.NewMiddleware(nil, conf, httpsim.DefaultSleep, nil)
+	handler := func(body string) http.Handler {
+		return s.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, 1, httpsim.CtxInfoValue(r.Context()).MatchedResourceIndex)
+			_, _ = w.Write([]byte(body))
+		}))
+	}
+	a, b := handler("a"), handler("b")
+	serve := func(h http.Handler, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, NewRequest(t, http.MethodGet, "https://host.io"+path, http.NoBody))
+		return rec
+	}
+
+	require.Equal(t, "a", serve(a, "/x").Body.String())
+	require.Equal(t, "b", serve(b, "/x").Body.String())
+	require.Equal(t, http.StatusServiceUnavailable, serve(b, "/fail").Code)
+	// The wrapped handlers share the runtime state.
+	require.Equal(t, []uint64{1, 2}, s.MatchCounts())
+}
+
+func TestReset(t *testing.T) {
+	header := config.HeaderName("X-Client")
+	conf := config.Config{
+		Resources: []config.Resource{
+			{
+				Path:  NewGlobExpression(t, "/sequence"),
+				KeyBy: &config.KeyBy{Header: &header},
+				Sequence: &config.Sequence{Steps: []config.SequenceStep{{
+					Effect: &config.Effect{
+						Replace: &config.Replace{StatusCode: http.StatusServiceUnavailable},
+					},
+				}}},
+			},
+			{
+				Path: NewGlobExpression(t, "/bursts"),
+				Effect: &config.Effect{
+					Bursts: &config.Bursts{Duration: time.Minute, Every: time.Hour},
+					Replace: &config.Replace{
+						StatusCode: http.StatusInternalServerError,
+					},
+				},
+			},
+		},
+	}
+	require.NoError(t, config.Validate(conf))
+	clock := new(ClockSleep)
+	s := httpsim.NewMiddleware(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {},
+	), conf, clock, nil)
+
+	serve := func(path, client string) int {
+		t.Helper()
+		r := NewRequest(t, http.MethodGet, "https://host.io"+path, http.NoBody)
+		r.Header.Set("X-Client", client)
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, r)
+		return rec.Code
+	}
+
+	require.Equal(t, http.StatusServiceUnavailable, serve("/sequence", "a"))
+	require.Equal(t, http.StatusOK, serve("/sequence", "a"))
+	require.Equal(t, http.StatusServiceUnavailable, serve("/sequence", "b"))
+	clock.Cumulative = 2 * time.Minute
+	require.Equal(t, http.StatusOK, serve("/bursts", "a"))
+	require.Equal(t, []uint64{3, 1}, s.MatchCounts())
+
+	s.Reset()
+	require.Equal(t, []uint64{0, 0}, s.MatchCounts())
+	require.Equal(t, httpsim.Stats{Resources: make([]httpsim.ResourceStats, 2)}, s.Stats())
+	require.Equal(t, conf, s.Config())
+
+	// Sequences of all clients and bursts restart.
+	require.Equal(t, http.StatusServiceUnavailable, serve("/sequence", "a"))
+	require.Equal(t, http.StatusServiceUnavailable, serve("/sequence", "b"))
+	require.Equal(t, http.StatusInternalServerError, serve("/bursts", "a"))
+}
+
+func TestDisabled(t *testing.T) {
+	f := func(t *testing.T, enabled *bool, env string, expectStatus int) {
+		t.Helper()
+		t.Setenv(httpsim.EnvDisabled, env)
+		_, s := NewSimulator(t, config.Config{
+			Enabled: enabled,
+			Resources: []config.Resource{{Effect: &config.Effect{
+				Replace: &config.Replace{StatusCode: http.StatusServiceUnavailable},
+			}}},
+		}, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody))
+		require.Equal(t, expectStatus, rec.Code)
+	}
+	enabled, disabled := true, false
+
+	t.Run("enabled", func(t *testing.T) {
+		f(t, nil, "", http.StatusServiceUnavailable)
+		f(t, &enabled, "", http.StatusServiceUnavailable)
+		f(t, nil, "0", http.StatusServiceUnavailable)
+		f(t, nil, "false", http.StatusServiceUnavailable)
+		f(t, nil, "invalid", http.StatusServiceUnavailable)
+	})
+	t.Run("disabled", func(t *testing.T) {
+		f(t, &disabled, "", http.StatusOK)
+		f(t, nil, "1", http.StatusOK)
+		f(t, nil, "true", http.StatusOK)
+		f(t, &enabled, "1", http.StatusOK)
+	})
+}
+
 type MockSleep struct{ Cumulative time.Duration }
 
 func (s *MockSleep) Sleep(d time.Duration) { s.Cumulative += d }
@@ -361,6 +718,27 @@ func TestHandleReplaceStatusCodeOnly(t *testing.T) {
 	require.Zero(t, rec.Body.String())
 }
 
+func TestHandleReplaceNonstandardStatusCode(t *testing.T) {
+	conf := config.Config{
+		Resources: []config.Resource{
+			{Effect: &config.Effect{
+				Replace: &config.Replace{
+					StatusCode:       499,
+					AllowNonstandard: true,
+				},
+			}},
+		},
+	}
+	_, s := NewSimulator(t, conf, func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("next handler must not be invoked")
+	})
+	rec := httptest.NewRecorder()
+	req := NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody)
+
+	s.ServeHTTP(rec, req)
+	require.Equal(t, 499, rec.Code)
+}
+
 func TestHandleNoMatch(t *testing.T) {
 	replacedBody := "replaced body"
 	conf := config.Config{
@@ -401,6 +779,79 @@ func TestHandleNoMatch(t *testing.T) {
 	require.Zero(t, rec.Body.String())
 }
 
+func TestHandleReplaceCaptures(t *testing.T) {
+	body := `{"error":"order ${id} not found","tenant":"${tenant}","x":"${x}"}`
+	conf := config.Config{
+		Resources: []config.Resource{
+			{
+				PathRegexp: NewRegexp(t, `^/orders/(?P<id>\d+)$`),
+				HeadersRegexp: config.GlobMap[config.Regexp]{
+					NewGlobExpression(t, "X-Tenant"): NewRegexp(t, `^(?P<tenant>\w+)$`),
+				},
+				Effect: &config.Effect{
+					Replace: &config.Replace{
+						StatusCode: http.StatusNotFound,
+						Body:       &body,
+						Headers: map[config.HeaderName]string{
+							"X-Order": "${id}",
+						},
+					},
+				},
+			},
+		},
+	}
+	_, s := NewSimulator(t, conf, func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("next handler must not be invoked")
+	})
+	rec := httptest.NewRecorder()
+	req := NewRequest(t, http.MethodGet, "https://host.io/orders/42", http.NoBody)
+	req.Header.Set("X-Tenant", "acme")
+
+	s.ServeHTTP(rec, req)
+
+	res := rec.Result()
+	require.Equal(t, http.StatusNotFound, res.StatusCode)
+	require.Equal(t, "42", res.Header.Get("X-Order"))
+	require.Equal(t,
+		`{"error":"order 42 not found","tenant":"acme","x":"${x}"}`,
+		rec.Body.String())
+}
+
+func TestMatchResourceRegexp(t *testing.T) {
+	r := config.Resource{
+		PathRegexp: NewRegexp(t, `^/orders/(?P<id>\d+)$`),
+		HeadersRegexp: config.GlobMap[config.Regexp]{
+			NewGlobExpression(t, "X-Tenant"): NewRegexp(t, `^acme$`),
+		},
+	}
+	require.True(t, httpsim.MatchResource(
+		NewRequest(t, http.MethodGet, "https://host.io/orders/1", http.NoBody), &r,
+	))
+	require.False(t, httpsim.MatchResource(
+		NewRequest(t, http.MethodGet, "https://host.io/orders/x", http.NoBody), &r,
+	))
+
+	req := NewRequest(t, http.MethodGet, "https://host.io/orders/1", http.NoBody)
+	req.Header.Set("X-Tenant", "other")
+	require.False(t, httpsim.MatchResource(req, &r))
+}
+
+func TestExpandTemplate(t *testing.T) {
+	f := func(input string, captures map[string]string, expect string) {
+		t.Helper()
+		require.Equal(t, expect, httpsim.ExpandTemplate(input, captures))
+	}
+
+	f("", nil, "")
+	f("${id}", nil, "${id}")
+	f("${id}", map[string]string{"id": "42"}, "42")
+	f("a${id}b${id}c", map[string]string{"id": "42"}, "a42b42c")
+	f("${unknown}/${id}", map[string]string{"id": "42"}, "${unknown}/42")
+	f("${id", map[string]string{"id": "42"}, "${id")
+	f("$id", map[string]string{"id": "42"}, "$id")
+	f("${empty}", map[string]string{"empty": ""}, "")
+}
+
 func TestNewSeedPanic(t *testing.T) {
 	require.Panics(t, func() { httpsim.NewSeed("") })
 	require.Panics(t, func() { httpsim.NewSeed("too short") })
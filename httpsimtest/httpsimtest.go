@@ -0,0 +1,81 @@
+// Package httpsimtest provides helpers for asserting against
+// an httpsim.Middleware in tests like against a mock.
+package httpsimtest
+
+import (
+	"testing"
+
+	"github.com/romshark/httpsim"
+)
+
+// ResourceIndex returns the index of the resource called name
+// in the config of m. Fails t if there's no such resource.
+func ResourceIndex(t testing.TB, m *httpsim.Middleware, name string) int {
+	t.Helper()
+	c := m.Config()
+	for i := range c.Resources {
+		if c.Resources[i].Name == name {
+			return i
+		}
+	}
+	t.Fatalf("httpsimtest: no resource %q", name)
+	return -1
+}
+
+// Stats returns the stats of the resource called name.
+// Fails t if there's no such resource.
+func Stats(t testing.TB, m *httpsim.Middleware, name string) httpsim.ResourceStats {
+	t.Helper()
+	i := ResourceIndex(t, m, name)
+	return m.Stats().Resources[i]
+}
+
+// RequireMatched fails t if the resource called name
+// wasn't matched exactly times times.
+func RequireMatched(t testing.TB, m *httpsim.Middleware, name string, times uint64) {
+	t.Helper()
+	if actual := Stats(t, m, name).Matches; actual != times {
+		t.Fatalf("httpsimtest: resource %q matched %d times, expected %d",
+			name, actual, times)
+	}
+}
+
+// RequireReplaced fails t if the responses to the requests matched by
+// the resource called name weren't replaced exactly times times.
+func RequireReplaced(t testing.TB, m *httpsim.Middleware, name string, times uint64) {
+	t.Helper()
+	if actual := Stats(t, m, name).Replacements; actual != times {
+		t.Fatalf("httpsimtest: resource %q replaced %d responses, expected %d",
+			name, actual, times)
+	}
+}
+
+// RequireDropped fails t if the connections of the requests matched by
+// the resource called name weren't dropped exactly times times.
+func RequireDropped(t testing.TB, m *httpsim.Middleware, name string, times uint64) {
+	t.Helper()
+	if actual := Stats(t, m, name).Drops; actual != times {
+		t.Fatalf("httpsimtest: resource %q dropped %d connections, expected %d",
+			name, actual, times)
+	}
+}
+
+// Isolate resets the runtime state of m, see httpsim.Middleware.Reset,
+// and resets it again once t and its subtests finished,
+// such that tests sharing m don't affect each other.
+// Tests sharing m mustn't run in parallel.
+func Isolate(t testing.TB, m *httpsim.Middleware) {
+	t.Helper()
+	m.Reset()
+	t.Cleanup(m.Reset)
+}
+
+// Run runs f as subtest name of t with the runtime state of m isolated,
+// see Isolate.
+func Run(t *testing.T, m *httpsim.Middleware, name string, f func(t *testing.T)) bool {
+	t.Helper()
+	return t.Run(name, func(t *testing.T) {
+		Isolate(t, m)
+		f(t)
+	})
+}
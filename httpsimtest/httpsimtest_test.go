@@ -0,0 +1,98 @@
+package httpsimtest_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim"
+	"github.com/romshark/httpsim/config"
+	"github.com/romshark/httpsim/httpsimtest"
+)
+
+// MockTB records failures instead of failing the test.
+type MockTB struct {
+	testing.TB
+	Failure string
+}
+
+func (t *MockTB) Helper() {}
+
+func (t *MockTB) Fatalf(format string, args ...any) {
+	t.Failure = format
+}
+
+func NewMiddleware(t *testing.T) *httpsim.Middleware {
+	t.Helper()
+	conf, err := config.Load(strings.NewReader(`
+resources:
+  - name: orders
+    path: /orders
+    sequence:
+      steps:
+        - effect:
+            replace:
+              status-code: 503
+              body: unavailable
+  - name: lossy
+    path: /lossy
+    effect:
+      drop:
+        rate: 1
+`))
+	require.NoError(t, err)
+	return httpsim.NewMiddleware(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {},
+	), *conf, httpsim.DefaultSleep, nil)
+}
+
+func Get(m *httpsim.Middleware, path string) int {
+	rec := httptest.NewRecorder()
+	func() {
+		defer func() { _ = recover() }() // Drops abort the handler.
+		m.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, http.NoBody))
+	}()
+	return rec.Code
+}
+
+func TestRequire(t *testing.T) {
+	m := NewMiddleware(t)
+	Get(m, "/orders")
+	Get(m, "/orders")
+	Get(m, "/lossy")
+
+	httpsimtest.RequireMatched(t, m, "orders", 2)
+	httpsimtest.RequireReplaced(t, m, "orders", 1)
+	httpsimtest.RequireDropped(t, m, "orders", 0)
+	httpsimtest.RequireMatched(t, m, "lossy", 1)
+	httpsimtest.RequireDropped(t, m, "lossy", 1)
+	require.Equal(t, 1, httpsimtest.ResourceIndex(t, m, "lossy"))
+
+	mt := new(MockTB)
+	httpsimtest.RequireMatched(mt, m, "orders", 3)
+	require.Contains(t, mt.Failure, "matched %d times, expected %d")
+
+	mt = new(MockTB)
+	httpsimtest.RequireReplaced(mt, m, "orders", 2)
+	require.Contains(t, mt.Failure, "replaced %d responses, expected %d")
+
+	mt = new(MockTB)
+	require.Equal(t, -1, httpsimtest.ResourceIndex(mt, m, "missing"))
+	require.Contains(t, mt.Failure, "no resource")
+}
+
+func TestRun(t *testing.T) {
+	m := NewMiddleware(t)
+	// Every subtest starts with a fresh sequence.
+	for _, name := range []string{"first", "second"} {
+		httpsimtest.Run(t, m, name, func(t *testing.T) {
+			require.Equal(t, http.StatusServiceUnavailable, Get(m, "/orders"))
+			require.Equal(t, http.StatusOK, Get(m, "/orders"))
+			httpsimtest.RequireMatched(t, m, "orders", 2)
+		})
+	}
+	httpsimtest.RequireMatched(t, m, "orders", 0)
+}
@@ -0,0 +1,87 @@
+// Package intercept serves the requests equivalent to RPCs
+// by an httpsim.Middleware on behalf of RPC interceptors.
+package intercept
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/romshark/httpsim"
+	"github.com/romshark/httpsim/config"
+)
+
+// Result is the outcome of serving a request by the simulator.
+type Result struct {
+	// Next is the context the call continues with if the request
+	// was passed through, otherwise nil.
+	Next context.Context
+
+	// StatusCode and Header are those of the response written by
+	// the simulator. StatusCode is zero if the simulator aborted
+	// the request without responding, such as when dropping it.
+	StatusCode int
+	Header     http.Header
+}
+
+// Serve serves r by m discarding the body of the response.
+func Serve(m *httpsim.Middleware, r *http.Request) (res Result) {
+	w := &responseWriter{header: http.Header{}}
+	defer func() {
+		if v := recover(); v != nil {
+			if v != http.ErrAbortHandler {
+				panic(v)
+			}
+			res = Result{}
+		}
+	}()
+	m.Wrap(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		res.Next = r.Context()
+	})).ServeHTTP(w, r)
+	res.StatusCode, res.Header = w.code, w.header
+	return res
+}
+
+// Status returns the gRPC status code and message of the response,
+// which are either those of the grpc-status effect or the code
+// gRPC clients translate the HTTP status code to.
+func (r *Result) Status() (config.GRPCCode, string) {
+	if v := r.Header.Get("Grpc-Status"); v != "" {
+		code, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return config.GRPCCodeUnknown, fmt.Sprintf("httpsim: invalid grpc-status %q", v)
+		}
+		msg, err := url.PathUnescape(r.Header.Get("Grpc-Message"))
+		if err != nil {
+			msg = r.Header.Get("Grpc-Message")
+		}
+		return config.GRPCCode(code), msg
+	}
+	return httpsim.GRPCCodeFromHTTP(r.StatusCode), fmt.Sprintf(
+		"httpsim: HTTP status code %d (%s)", r.StatusCode, http.StatusText(r.StatusCode),
+	)
+}
+
+// responseWriter records the status and the headers
+// of the response discarding its body.
+type responseWriter struct {
+	header http.Header
+	code   int
+}
+
+var _ http.ResponseWriter = new(responseWriter)
+
+func (w *responseWriter) Header() http.Header { return w.header }
+
+func (w *responseWriter) WriteHeader(code int) {
+	if w.code == 0 {
+		w.code = code
+	}
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return len(b), nil
+}
@@ -0,0 +1,59 @@
+package intercept_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim"
+	"github.com/romshark/httpsim/config"
+	"github.com/romshark/httpsim/internal/intercept"
+)
+
+func TestServe(t *testing.T) {
+	c, err := httpsim.LoadConfig(strings.NewReader(`
+resources:
+  - path: /grpc
+    effect:
+      grpc-status:
+        code: 14
+        message: "100% down"
+  - path: /http
+    effect:
+      replace:
+        status-code: 404
+  - path: /drop
+    effect:
+      drop:
+        rate: 1
+  - path: /pass
+    effect:
+      delay: 1ms
+`))
+	require.NoError(t, err)
+	m := httpsim.NewMiddleware(nil, *c, httpsim.DefaultSleep, nil)
+	serve := func(path string) intercept.Result {
+		return intercept.Serve(m, httptest.NewRequest(http.MethodPost, path, http.NoBody))
+	}
+
+	res := serve("/grpc")
+	require.Nil(t, res.Next)
+	code, msg := res.Status()
+	require.Equal(t, config.GRPCCodeUnavailable, code)
+	require.Equal(t, "100% down", msg)
+
+	res = serve("/http")
+	require.Equal(t, http.StatusNotFound, res.StatusCode)
+	code, _ = res.Status()
+	require.Equal(t, config.GRPCCodeUnimplemented, code)
+
+	res = serve("/drop")
+	require.Equal(t, intercept.Result{}, res)
+
+	res = serve("/pass")
+	require.NotNil(t, res.Next)
+	require.Equal(t, 3, httpsim.CtxInfoValue(res.Next).MatchedResourceIndex)
+}
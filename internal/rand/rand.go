@@ -5,6 +5,7 @@ import (
 	cryptorand "crypto/rand"
 	"fmt"
 	"math/rand/v2"
+	"sync"
 	"time"
 )
 
@@ -32,12 +33,15 @@ func NewSeedRand() Seed {
 	return Seed(seed)
 }
 
-// Source is a randomness source.
-type Source struct{ r *rand.Rand }
+// Source is a randomness source safe for concurrent use.
+type Source struct {
+	lock *sync.Mutex
+	r    *rand.Rand
+}
 
 // NewSourceChaCha8 returns a chacha8 based randomness source.
 func NewSourceChaCha8(seed Seed) Source {
-	return Source{r: rand.New(rand.NewChaCha8(seed))}
+	return Source{lock: new(sync.Mutex), r: rand.New(rand.NewChaCha8(seed))}
 }
 
 // Dur returns a random duration within the given min and max range.
@@ -46,8 +50,36 @@ func (s Source) Dur(min, max time.Duration) time.Duration {
 		return min
 	}
 	delta := max - min
+	s.lock.Lock()
+	defer s.lock.Unlock()
 	return min + time.Duration(s.r.Int64N(int64(delta)))
 }
 
 // Bool returns a random boolean value.
-func (s Source) Bool() bool { return s.r.IntN(2) == 1 }
+func (s Source) Bool() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.r.IntN(2) == 1
+}
+
+// Float64 returns a random float64 in the half-open interval [0.0,1.0).
+func (s Source) Float64() float64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.r.Float64()
+}
+
+// NormFloat64 returns a normally distributed float64
+// with mean 0 and standard deviation 1.
+func (s Source) NormFloat64() float64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.r.NormFloat64()
+}
+
+// ExpFloat64 returns an exponentially distributed float64 with rate 1.
+func (s Source) ExpFloat64() float64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.r.ExpFloat64()
+}
@@ -1,6 +1,7 @@
 package rand_test
 
 import (
+	"sync"
 	"testing"
 	"time"
 
@@ -29,6 +30,17 @@ func TestSource(t *testing.T) {
 	require.Equal(t, Dur(t, "47m55.022499822s"), s.Dur(0, 1*time.Hour))
 }
 
+func TestSourceFloat64(t *testing.T) {
+	s := rand.NewSourceChaCha8(rand.NewSeed("0123456789abcdef0123456789abcdef"))
+	for range 1000 {
+		f := s.Float64()
+		require.GreaterOrEqual(t, f, 0.0)
+		require.Less(t, f, 1.0)
+		require.GreaterOrEqual(t, s.ExpFloat64(), 0.0)
+		_ = s.NormFloat64()
+	}
+}
+
 func Dur(t *testing.T, s string) time.Duration {
 	t.Helper()
 	d, err := time.ParseDuration(s)
@@ -43,3 +55,22 @@ func TestNewSeedPanic(t *testing.T) {
 		rand.NewSeed("0123456789abcdef0123456789abcdef too long")
 	})
 }
+
+func TestSourceConcurrent(t *testing.T) {
+	s := rand.NewSourceChaCha8(rand.NewSeed("0123456789abcdef0123456789abcdef"))
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 1000 {
+				_ = s.Dur(0, time.Second)
+				_ = s.Bool()
+				_ = s.Float64()
+				_ = s.NormFloat64()
+				_ = s.ExpFloat64()
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,53 @@
+package httpsim
+
+import "net/http"
+
+// keepAliveWriter closes the connection once the response is finished
+// without announcing it with Connection: close.
+type keepAliveWriter struct {
+	http.ResponseWriter
+	r       *http.Request
+	status  int // Zero until the status is written.
+	chunked bool
+}
+
+func (w *keepAliveWriter) WriteHeader(statusCode int) {
+	if w.status == 0 && statusCode >= 200 {
+		w.status = statusCode
+		h := w.Header()
+		// The server uses the chunked encoding for HTTP/1.1 responses of
+		// unknown length while the handler is running.
+		w.chunked = w.r.ProtoAtLeast(1, 1) && w.r.Method != http.MethodHead &&
+			statusCode != http.StatusNoContent && statusCode != http.StatusNotModified &&
+			h.Get("Content-Length") == "" && h.Get("Transfer-Encoding") == ""
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *keepAliveWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap allows http.ResponseController to access the underlying writer.
+func (w *keepAliveWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+func (w *keepAliveWriter) finish() {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	rc := http.NewResponseController(w.ResponseWriter)
+	_ = rc.Flush()
+	conn, bw, err := rc.Hijack()
+	if err != nil {
+		return // Hijacking isn't supported, let the server finish.
+	}
+	defer func() { _ = conn.Close() }()
+	if w.chunked {
+		// Terminate the body the server would otherwise terminate.
+		_, _ = bw.WriteString("0\r\n\r\n")
+		_ = bw.Flush()
+	}
+}
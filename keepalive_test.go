@@ -0,0 +1,112 @@
+package httpsim_test
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim/config"
+)
+
+// ServeKeepAlive sends two sequential requests to handler wrapped in
+// a simulator with keep-alive effect k over one HTTP/1.1 client
+// and returns the number of connections opened.
+func ServeKeepAlive(
+	t *testing.T, k *config.KeepAlive, handler http.HandlerFunc,
+) (conns int64) {
+	t.Helper()
+	_, s := NewSimulator(t, config.Config{
+		Resources: []config.Resource{{Effect: &config.Effect{KeepAlive: k}}},
+	}, handler)
+	var opened atomic.Int64
+	srv := httptest.NewUnstartedServer(s)
+	srv.Config.ConnState = func(_ net.Conn, s http.ConnState) {
+		if s == http.StateNew {
+			opened.Add(1)
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	client := srv.Client()
+	for range 2 {
+		resp, err := client.Get(srv.URL)
+		require.NoError(t, err)
+		b, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, "ok", string(b))
+	}
+	return opened.Load()
+}
+
+func TestKeepAliveHeader(t *testing.T) {
+	_, s := NewSimulator(t, config.Config{
+		Resources: []config.Resource{{Effect: &config.Effect{
+			KeepAlive: &config.KeepAlive{Rate: 1},
+		}}},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	})
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody))
+	require.Equal(t, "close", rec.Header().Get("Connection"))
+	require.Equal(t, "ok", rec.Body.String())
+}
+
+func TestKeepAliveRate(t *testing.T) {
+	write := func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}
+	require.Equal(t, int64(1), ServeKeepAlive(t, &config.KeepAlive{Rate: 0}, write))
+	require.Equal(t, int64(2), ServeKeepAlive(t, &config.KeepAlive{Rate: 1}, write))
+}
+
+func TestKeepAliveClose(t *testing.T) {
+	f := func(handler http.HandlerFunc) {
+		t.Helper()
+		require.Equal(t, int64(2), ServeKeepAlive(t, &config.KeepAlive{
+			Rate: 1, Mode: config.KeepAliveModeClose,
+		}, handler))
+	}
+	// Chunked.
+	f(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("o"))
+		http.NewResponseController(w).Flush()
+		_, _ = w.Write([]byte("k"))
+	})
+	// Content-Length.
+	f(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "2")
+		_, _ = w.Write([]byte("ok"))
+	})
+	// Buffered by the server.
+	f(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(w, strings.NewReader("ok"))
+	})
+}
+
+func TestKeepAliveCloseNotAnnounced(t *testing.T) {
+	_, s := NewSimulator(t, config.Config{
+		Resources: []config.Resource{{Effect: &config.Effect{
+			KeepAlive: &config.KeepAlive{Rate: 1, Mode: config.KeepAliveModeClose},
+		}}},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	})
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	require.Empty(t, resp.Header.Get("Connection"))
+	require.False(t, resp.Close)
+}
@@ -0,0 +1,73 @@
+package httpsim
+
+import (
+	"container/list"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/romshark/httpsim/config"
+)
+
+// ClientKey returns the identity of the client r belongs to as defined by c,
+// or an empty string if r lacks the identity.
+func ClientKey(r *http.Request, c *config.KeyBy) string {
+	switch {
+	case c.ClientIP:
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			return r.RemoteAddr
+		}
+		return host
+	case c.Header != nil:
+		return r.Header.Get(string(*c.Header))
+	case c.Cookie != "":
+		if cookie, err := r.Cookie(c.Cookie); err == nil {
+			return cookie.Value
+		}
+	}
+	return ""
+}
+
+// clientCounters holds the counters of the most recently seen clients.
+type clientCounters struct {
+	lock  sync.Mutex
+	order list.List // Of *clientEntry, most recently seen first.
+	byKey map[string]*list.Element
+}
+
+type clientEntry struct {
+	key      string
+	counters *counters
+}
+
+// get returns the counters of the client identified by key, creating them
+// if necessary and evicting the least recently seen client beyond max.
+func (c *clientCounters) get(key string, max int, create func() *counters) *counters {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if e, ok := c.byKey[key]; ok {
+		c.order.MoveToFront(e)
+		return e.Value.(*clientEntry).counters
+	}
+	if c.byKey == nil {
+		c.byKey = map[string]*list.Element{}
+	}
+	for c.order.Len() >= max {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.byKey, oldest.Value.(*clientEntry).key)
+	}
+	e := &clientEntry{key: key, counters: create()}
+	c.byKey[key] = c.order.PushFront(e)
+	return e.counters
+}
+
+// each calls fn for the counters of all tracked clients.
+func (c *clientCounters) each(fn func(*counters)) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for e := c.order.Front(); e != nil; e = e.Next() {
+		fn(e.Value.(*clientEntry).counters)
+	}
+}
@@ -0,0 +1,133 @@
+package httpsim_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim"
+	"github.com/romshark/httpsim/config"
+)
+
+func TestClientKey(t *testing.T) {
+	header := config.HeaderName("X-API-Key")
+	r := NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody)
+	r.RemoteAddr = "192.0.2.1:1234"
+	r.Header.Set("X-API-Key", "key-a")
+	r.AddCookie(&http.Cookie{Name: "session", Value: "s1"})
+
+	require.Equal(t, "192.0.2.1", httpsim.ClientKey(r, &config.KeyBy{ClientIP: true}))
+	require.Equal(t, "key-a", httpsim.ClientKey(r, &config.KeyBy{Header: &header}))
+	require.Equal(t, "s1", httpsim.ClientKey(r, &config.KeyBy{Cookie: "session"}))
+	require.Equal(t, "", httpsim.ClientKey(r, &config.KeyBy{Cookie: "missing"}))
+
+	r.RemoteAddr = "[2001:db8::1]:443"
+	require.Equal(t, "2001:db8::1", httpsim.ClientKey(r, &config.KeyBy{ClientIP: true}))
+}
+
+func TestKeyBySequence(t *testing.T) {
+	header := config.HeaderName("X-API-Key")
+	_, s := NewSimulator(t, config.Config{
+		Resources: []config.Resource{{
+			KeyBy: &config.KeyBy{Header: &header},
+			Sequence: &config.Sequence{Steps: []config.SequenceStep{
+				{Times: 2, Effect: &config.Effect{Replace: &config.Replace{
+					StatusCode: http.StatusServiceUnavailable,
+				}}},
+			}},
+		}},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	do := func(key string) int {
+		req := NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody)
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// Clients don't interfere with each other.
+	require.Equal(t, 503, do("a"))
+	require.Equal(t, 503, do("b"))
+	require.Equal(t, 503, do("a"))
+	require.Equal(t, 200, do("a"))
+	require.Equal(t, 503, do("b"))
+	require.Equal(t, 200, do("b"))
+	require.Equal(t, 503, do("c"))
+	require.Equal(t, 503, do("")) // Anonymous.
+	require.Equal(t, []uint64{8}, s.MatchCounts())
+
+	s.ResetSequences()
+	require.Equal(t, 503, do("a"))
+	require.Equal(t, 503, do("b"))
+}
+
+func TestKeyByMaxClients(t *testing.T) {
+	header := config.HeaderName("X-API-Key")
+	_, s := NewSimulator(t, config.Config{
+		Resources: []config.Resource{{
+			KeyBy: &config.KeyBy{Header: &header, MaxClients: 2},
+			Sequence: &config.Sequence{Steps: []config.SequenceStep{
+				{Times: 1, Effect: &config.Effect{Replace: &config.Replace{
+					StatusCode: http.StatusServiceUnavailable,
+				}}},
+			}},
+		}},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	do := func(key string) int {
+		req := NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody)
+		req.Header.Set("X-API-Key", key)
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	require.Equal(t, 503, do("a"))
+	require.Equal(t, 503, do("b"))
+	require.Equal(t, 200, do("a"))
+	require.Equal(t, 503, do("c")) // Evicts b, the least recently seen.
+	require.Equal(t, 200, do("a"))
+	require.Equal(t, 503, do("b")) // Starts over, evicts c.
+	require.Equal(t, 200, do("a"))
+	require.Equal(t, 503, do("c"))
+}
+
+func TestKeyByAfterMatches(t *testing.T) {
+	_, s := NewSimulator(t, config.Config{
+		Resources: []config.Resource{{
+			KeyBy: &config.KeyBy{ClientIP: true},
+			Effect: &config.Effect{
+				AfterMatches: 1,
+				Replace: &config.Replace{
+					StatusCode: http.StatusTooManyRequests,
+				},
+			},
+		}},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	do := func(remoteAddr string) int {
+		req := NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody)
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	require.Equal(t, 200, do("192.0.2.1:1000"))
+	require.Equal(t, 429, do("192.0.2.1:1001")) // Same IP, different port.
+	require.Equal(t, 200, do("192.0.2.2:1000"))
+	require.Equal(t, 429, do("192.0.2.2:1000"))
+
+	s.ResetMatchCounts()
+	require.Equal(t, []uint64{0}, s.MatchCounts())
+	require.Equal(t, 200, do("192.0.2.1:1000"))
+	require.Equal(t, 200, do("192.0.2.2:1000"))
+}
@@ -0,0 +1,174 @@
+package httpsim
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/romshark/httpsim/config"
+	"github.com/romshark/httpsim/netfault"
+)
+
+// Listener is a net.Listener degrading the connections it accepts
+// according to the connections section of the config.
+type Listener struct {
+	net.Listener
+	conf     atomic.Pointer[config.Connections]
+	rand     RandProvider
+	sleeper  Sleeper
+	disabled bool
+}
+
+var _ net.Listener = new(Listener)
+
+// WrapListener wraps l such that the accepted connections are degraded
+// according to c.Connections. Connections are passed through untouched
+// if c is disabled or in dry-run mode or the listener is disabled
+// by EnvDisabled.
+func WrapListener(l net.Listener, c config.Config) *Listener {
+	disabled, _ := strconv.ParseBool(os.Getenv(EnvDisabled))
+	w := &Listener{
+		Listener: l, rand: DefaultRand, sleeper: DefaultSleep, disabled: disabled,
+	}
+	w.SetConfig(c)
+	return w
+}
+
+// SetConfig changes the configuration of the listener.
+// Connections accepted before keep their configuration.
+// SetConfig is safe for concurrent use at runtime.
+func (l *Listener) SetConfig(c config.Config) {
+	if !c.IsEnabled() || c.DryRun {
+		c.Connections = nil
+	}
+	l.conf.Store(c.Connections)
+}
+
+// Accept waits for and returns the next connection.
+func (l *Listener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	c := l.conf.Load()
+	if l.disabled || c == nil ||
+		(c.Probability != nil && l.rand.Float64() >= float64(*c.Probability)) {
+		return conn, nil
+	}
+	return &faultyConn{
+		Conn: netfault.NewConn(conn, l.netfaultConfig(c)), raw: conn, l: l, c: c,
+	}, nil
+}
+
+// netfaultConfig returns the netfault config of c.
+func (l *Listener) netfaultConfig(c *config.Connections) netfault.Config {
+	conf := netfault.Config{Sleep: l.sleeper.Sleep}
+	if t := c.Throughput; t != nil {
+		conf.WriteBytesPerSecond, conf.WriteBurst = t.BytesPerSecond, t.Burst
+	}
+	if t := c.ReadThroughput; t != nil {
+		conf.ReadBytesPerSecond, conf.ReadBurst = t.BytesPerSecond, t.Burst
+	}
+	if d := c.Latency; d != nil {
+		conf.Latency = func() time.Duration { return SampleDur(l.rand, d) }
+	}
+	if s := c.Stalls; s != nil {
+		conf.Stall = func() time.Duration {
+			if l.rand.Float64() < float64(s.Probability) {
+				return SampleDur(l.rand, &s.Duration)
+			}
+			return 0
+		}
+	}
+	return conf
+}
+
+// faultyConn is a connection degraded according to its config.
+// Rates, latency and stalls are applied by the netfault.Conn it wraps,
+// the byte thresholds of the cutoffs by faultyConn itself.
+type faultyConn struct {
+	net.Conn
+	raw net.Conn // The accepted connection.
+	l   *Listener
+	c   *config.Connections
+
+	lock    sync.Mutex
+	written uint64
+	stalled bool
+	closed  bool
+}
+
+func (c *faultyConn) Write(b []byte) (written int, err error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for {
+		if err := c.cutoff(); err != nil {
+			return written, err
+		}
+		if len(b) < 1 {
+			return written, nil
+		}
+		n := uint64(len(b))
+		for _, t := range [...]*uint64{
+			c.stallThreshold(), threshold(c.c.HalfClose), threshold(c.c.Reset),
+		} {
+			if t != nil && *t > c.written {
+				n = min(n, *t-c.written)
+			}
+		}
+		nw, err := c.Conn.Write(b[:n])
+		written += nw
+		c.written += uint64(nw)
+		if err != nil {
+			return written, err
+		}
+		b = b[n:]
+	}
+}
+
+// cutoff applies the faults whose byte thresholds were reached
+// and returns an error if the connection can no longer be written to.
+func (c *faultyConn) cutoff() error {
+	if c.closed {
+		return net.ErrClosed
+	}
+	if s := c.c.Stall; s != nil && !c.stalled && c.written >= s.AfterBytes {
+		c.stalled = true
+		c.l.sleeper.Sleep(SampleDur(c.l.rand, &s.Duration))
+	}
+	if r := c.c.Reset; r != nil && c.written >= r.AfterBytes {
+		c.closed = true
+		if tc, ok := c.raw.(*net.TCPConn); ok {
+			_ = tc.SetLinger(0) // Send RST instead of FIN.
+		}
+		_ = c.raw.Close()
+		return net.ErrClosed
+	}
+	if h := c.c.HalfClose; h != nil && c.written >= h.AfterBytes {
+		c.closed = true
+		if cw, ok := c.raw.(interface{ CloseWrite() error }); ok {
+			_ = cw.CloseWrite()
+		} else {
+			_ = c.raw.Close()
+		}
+		return net.ErrClosed
+	}
+	return nil
+}
+
+func (c *faultyConn) stallThreshold() *uint64 {
+	if c.c.Stall == nil || c.stalled {
+		return nil
+	}
+	return &c.c.Stall.AfterBytes
+}
+
+func threshold(c *config.ConnCutoff) *uint64 {
+	if c == nil {
+		return nil
+	}
+	return &c.AfterBytes
+}
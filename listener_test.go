@@ -0,0 +1,169 @@
+package httpsim_test
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim"
+	"github.com/romshark/httpsim/config"
+)
+
+// ServeConn dials a listener wrapped with c and returns the client side
+// of the connection and a channel receiving what the server side reads
+// after writing data to it.
+func ServeConn(t *testing.T, c config.Config, data string) (net.Conn, <-chan string) {
+	t.Helper()
+	require.NoError(t, config.Validate(c))
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	wl := httpsim.WrapListener(l, c)
+	t.Cleanup(func() { _ = wl.Close() })
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := wl.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		_, _ = conn.Write([]byte(data))
+		b, _ := io.ReadAll(conn)
+		received <- string(b)
+	}()
+	return conn, received
+}
+
+func TestListenerReset(t *testing.T) {
+	conn, _ := ServeConn(t, config.Config{
+		Connections: &config.Connections{Reset: &config.ConnCutoff{AfterBytes: 5}},
+	}, "hello world")
+	b, err := io.ReadAll(conn)
+	require.ErrorIs(t, err, syscall.ECONNRESET)
+	require.Equal(t, "hello", string(b))
+}
+
+func TestListenerHalfClose(t *testing.T) {
+	conn, received := ServeConn(t, config.Config{
+		Connections: &config.Connections{HalfClose: &config.ConnCutoff{AfterBytes: 5}},
+	}, "hello world")
+	b, err := io.ReadAll(conn)
+	require.NoError(t, err) // EOF.
+	require.Equal(t, "hello", string(b))
+
+	// The connection can still be read from.
+	_, err = conn.Write([]byte("ping"))
+	require.NoError(t, err)
+	require.NoError(t, conn.(*net.TCPConn).CloseWrite())
+	require.Equal(t, "ping", <-received)
+}
+
+func TestListenerStall(t *testing.T) {
+	stall := 100 * time.Millisecond
+	start := time.Now()
+	conn, _ := ServeConn(t, config.Config{
+		Connections: &config.Connections{Stall: &config.ConnStall{
+			AfterBytes: 2, Duration: config.DurRange{Min: stall, Max: stall},
+		}},
+	}, "hello")
+	b := make([]byte, 2)
+	_, err := io.ReadFull(conn, b)
+	require.NoError(t, err)
+	require.Less(t, time.Since(start), stall)
+	b = make([]byte, 3)
+	_, err = io.ReadFull(conn, b)
+	require.NoError(t, err)
+	require.Equal(t, "llo", string(b))
+	require.GreaterOrEqual(t, time.Since(start), stall)
+}
+
+func TestListenerThroughput(t *testing.T) {
+	data := strings.Repeat("x", 2000)
+	start := time.Now()
+	conn, _ := ServeConn(t, config.Config{
+		Connections: &config.Connections{Throughput: &config.Throughput{
+			BytesPerSecond: 10_000, Burst: 1000,
+		}},
+	}, data)
+	b := make([]byte, len(data))
+	_, err := io.ReadFull(conn, b)
+	require.NoError(t, err)
+	require.Equal(t, data, string(b))
+	// The first 1000 bytes are a burst, the rest takes 100ms.
+	require.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+}
+
+func TestListenerLatency(t *testing.T) {
+	latency := 50 * time.Millisecond
+	start := time.Now()
+	conn, received := ServeConn(t, config.Config{
+		Connections: &config.Connections{
+			Latency: &config.DurRange{Min: latency, Max: latency},
+		},
+	}, "hello")
+	b := make([]byte, 5)
+	_, err := io.ReadFull(conn, b)
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(b))
+	require.GreaterOrEqual(t, time.Since(start), latency)
+
+	// Reads are delayed as well.
+	_, err = conn.Write([]byte("ping"))
+	require.NoError(t, err)
+	require.NoError(t, conn.(*net.TCPConn).CloseWrite())
+	require.Equal(t, "ping", <-received)
+	require.GreaterOrEqual(t, time.Since(start), 2*latency)
+}
+
+func TestListenerPassThrough(t *testing.T) {
+	zero, disabled := config.Probability(0), false
+	f := func(c config.Config) {
+		t.Helper()
+		conn, _ := ServeConn(t, c, "hello world")
+		require.NoError(t, conn.(*net.TCPConn).CloseWrite())
+		b, err := io.ReadAll(conn)
+		require.NoError(t, err)
+		require.Equal(t, "hello world", string(b))
+	}
+	reset := &config.ConnCutoff{AfterBytes: 5}
+	f(config.Config{})
+	f(config.Config{Connections: &config.Connections{
+		Probability: &zero, Reset: reset,
+	}})
+	f(config.Config{Enabled: &disabled, Connections: &config.Connections{Reset: reset}})
+	f(config.Config{DryRun: true, Connections: &config.Connections{Reset: reset}})
+}
+
+func TestListenerHTTP(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	wl := httpsim.WrapListener(l, config.Config{
+		Connections: &config.Connections{Reset: &config.ConnCutoff{}},
+	})
+	s := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	})}
+	go func() { _ = s.Serve(wl) }()
+	t.Cleanup(func() { _ = s.Close() })
+
+	_, err = http.Get("http://" + l.Addr().String())
+	require.Error(t, err)
+
+	// Connections accepted after disabling the faults are healthy.
+	wl.SetConfig(config.Config{})
+	resp, err := http.Get("http://" + l.Addr().String())
+	require.NoError(t, err)
+	b, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "ok", string(b))
+}
@@ -0,0 +1,81 @@
+package httpsim
+
+import (
+	"log/slog"
+	"net/http"
+)
+
+// LogLevels are the levels the decisions of the middleware are logged at.
+type LogLevels struct {
+	// NoMatch is the level of requests matched by no resource.
+	NoMatch slog.Level
+
+	// Match is the level of matched requests that weren't delayed,
+	// replaced or dropped.
+	Match slog.Level
+
+	// Effect is the level of matched requests that were delayed,
+	// replaced or dropped.
+	Effect slog.Level
+}
+
+// DefaultLogLevels logs requests affected by the simulator
+// at info level and all other requests at debug level.
+var DefaultLogLevels = LogLevels{
+	NoMatch: slog.LevelDebug,
+	Match:   slog.LevelDebug,
+	Effect:  slog.LevelInfo,
+}
+
+// logger is a logger with the levels to log at.
+type logger struct {
+	*slog.Logger
+	levels LogLevels
+}
+
+// SetLogger makes the middleware log every request it handles to l
+// at the given levels, including the matched resource, the injected delay
+// and whether the response was replaced or the connection dropped.
+// A nil l disables logging, which is the default.
+// SetLogger is safe for concurrent use at runtime.
+func (m *Middleware) SetLogger(l *slog.Logger, levels LogLevels) {
+	if l == nil {
+		m.logger.Store(nil)
+		return
+	}
+	m.logger.Store(&logger{Logger: l, levels: levels})
+}
+
+// log logs the decisions made for r recorded in info. matched is false
+// if r is matched by neither a resource nor the global effect.
+func (m *Middleware) log(r *http.Request, info *CtxInfo, matched bool) {
+	l := m.logger.Load()
+	if l == nil {
+		return
+	}
+	level, msg := l.levels.Effect, "httpsim: request affected"
+	switch {
+	case !matched:
+		level, msg = l.levels.NoMatch, "httpsim: request not matched"
+	case info.Delay == 0 && !info.Replaced && !info.Dropped:
+		level, msg = l.levels.Match, "httpsim: request matched"
+	}
+	ctx := r.Context()
+	if !l.Enabled(ctx, level) {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.String("method", r.Method),
+		slog.String("host", r.Host),
+		slog.String("path", r.URL.Path),
+		slog.String("remote_addr", r.RemoteAddr),
+		slog.Int("resource", info.MatchedResourceIndex),
+		slog.Duration("delay", info.Delay),
+		slog.Bool("replaced", info.Replaced),
+		slog.Bool("dropped", info.Dropped),
+	}
+	if info.DryRun {
+		attrs = append(attrs, slog.Bool("dry_run", true))
+	}
+	l.LogAttrs(ctx, level, msg, attrs...)
+}
@@ -0,0 +1,97 @@
+package httpsim_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim"
+	"github.com/romshark/httpsim/config"
+)
+
+func TestSetLogger(t *testing.T) {
+	body := "replaced"
+	_, s := NewSimulator(t, config.Config{
+		Resources: []config.Resource{
+			{
+				Path: NewGlobExpression(t, "/slow"),
+				Effect: &config.Effect{
+					Delay: &config.DurRange{Min: time.Second, Max: time.Second},
+				},
+			},
+			{
+				Path: NewGlobExpression(t, "/replaced"),
+				Effect: &config.Effect{Replace: &config.Replace{
+					StatusCode: http.StatusBadGateway, Body: &body,
+				}},
+			},
+			{
+				Path: NewGlobExpression(t, "/passed"),
+				Effect: &config.Effect{
+					Probability: new(config.Probability),
+					Delay:       &config.DurRange{Min: time.Second, Max: time.Second},
+				},
+			},
+		},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	var buf bytes.Buffer
+	s.SetLogger(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})), httpsim.DefaultLogLevels)
+
+	f := func(path string, expect map[string]any) {
+		t.Helper()
+		buf.Reset()
+		r := NewRequest(t, http.MethodGet, "https://host.io"+path, http.NoBody)
+		r.RemoteAddr = "10.0.0.1:1234"
+		s.ServeHTTP(httptest.NewRecorder(), r)
+		var actual map[string]any
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &actual))
+		require.Equal(t, expect, actual)
+	}
+	record := func(
+		level, msg, path string, resource, delay float64, replaced bool,
+	) map[string]any {
+		return map[string]any{
+			"level": level, "msg": msg, "method": "GET", "host": "host.io",
+			"path": path, "remote_addr": "10.0.0.1:1234", "resource": resource,
+			"delay": delay, "replaced": replaced, "dropped": false,
+		}
+	}
+	f("/slow", record("INFO", "httpsim: request affected", "/slow", 0, 1e9, false))
+	f("/replaced", record("INFO", "httpsim: request affected", "/replaced", 1, 0, true))
+	f("/passed", record("DEBUG", "httpsim: request matched", "/passed", 2, 0, false))
+	f("/other", record("DEBUG", "httpsim: request not matched", "/other", -1, 0, false))
+
+	// Levels below the handler's are discarded.
+	s.SetLogger(slog.New(slog.NewTextHandler(&buf, nil)), httpsim.DefaultLogLevels)
+	buf.Reset()
+	s.ServeHTTP(httptest.NewRecorder(),
+		NewRequest(t, http.MethodGet, "https://host.io/other", http.NoBody))
+	s.ServeHTTP(httptest.NewRecorder(),
+		NewRequest(t, http.MethodGet, "https://host.io/replaced", http.NoBody))
+	require.Equal(t, 1, strings.Count(buf.String(), "\n"))
+	require.Contains(t, buf.String(), "resource=1")
+
+	// Disabled.
+	s.SetLogger(nil, httpsim.DefaultLogLevels)
+	buf.Reset()
+	s.ServeHTTP(httptest.NewRecorder(),
+		NewRequest(t, http.MethodGet, "https://host.io/replaced", http.NoBody))
+	require.Zero(t, buf.Len())
+}
@@ -0,0 +1,67 @@
+package httpsim
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/romshark/httpsim/config"
+)
+
+// malformed writes a broken HTTP/1.1 response of the kind defined by
+// c directly to the hijacked connection and closes it
+// using the headers set on w so far.
+func malformed(w http.ResponseWriter, c *config.Malformed, status int, body []byte) {
+	header := w.Header().Clone()
+	if len(body) < 2 {
+		// Truncating requires at least two bytes of body.
+		body = []byte("malformed")
+	}
+
+	conn, bw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		// Hijacking isn't supported (for example by HTTP/2),
+		// abort the response instead.
+		panic(http.ErrAbortHandler)
+	}
+	defer func() { _ = conn.Close() }()
+
+	writeHead := func(statusLine string) {
+		_, _ = bw.WriteString(statusLine + "\r\n")
+		names := make([]string, 0, len(header))
+		for name := range header {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		for _, name := range names {
+			for _, v := range header[name] {
+				_, _ = fmt.Fprintf(bw, "%s: %s\r\n", name, v)
+			}
+		}
+		_, _ = bw.WriteString("\r\n")
+	}
+	statusLine := fmt.Sprintf("HTTP/1.1 %03d %s", status, http.StatusText(status))
+
+	switch c.Kind {
+	case config.MalformedContentLength:
+		header.Set("Content-Length", fmt.Sprint(len(body)*2))
+		writeHead(statusLine)
+		_, _ = bw.Write(body)
+	case config.MalformedTruncatedChunked:
+		header.Set("Transfer-Encoding", "chunked")
+		writeHead(statusLine)
+		_, _ = fmt.Fprintf(bw, "%x\r\n", len(body))
+		_, _ = bw.Write(body[:len(body)/2])
+	case config.MalformedStatusLine:
+		header.Set("Content-Length", fmt.Sprint(len(body)))
+		writeHead("HTXP/one-point-one \x00\x7f garbage")
+		_, _ = bw.Write(body)
+	case config.MalformedDuplicateHeaders:
+		header["Content-Length"] = []string{
+			fmt.Sprint(len(body)), fmt.Sprint(len(body) + 1),
+		}
+		writeHead(statusLine)
+		_, _ = bw.Write(body)
+	}
+	_ = bw.Flush()
+}
@@ -0,0 +1,77 @@
+package httpsim_test
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim/config"
+)
+
+func TestMalformed(t *testing.T) {
+	body := "0123456789"
+	f := func(kind config.MalformedKind, expectRaw string) {
+		t.Helper()
+		conf := config.Config{
+			Resources: []config.Resource{{Effect: &config.Effect{
+				Malformed: &config.Malformed{Kind: kind},
+				Replace: &config.Replace{
+					StatusCode: http.StatusOK,
+					Body:       &body,
+					Headers:    map[config.HeaderName]string{"X-Test": "test"},
+				},
+			}}},
+		}
+		_, s := NewSimulator(t, conf, func(w http.ResponseWriter, r *http.Request) {
+			t.Fatal("next handler must not be invoked")
+		})
+		srv := httptest.NewServer(s)
+		defer srv.Close()
+
+		// The standard client must reject the response.
+		resp, err := http.Get(srv.URL)
+		if err == nil {
+			_, err = io.ReadAll(resp.Body)
+			_ = resp.Body.Close()
+		}
+		require.Error(t, err)
+
+		conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+		require.NoError(t, err)
+		defer func() { _ = conn.Close() }()
+		_, err = io.WriteString(conn, "GET / HTTP/1.1\r\nHost: host.io\r\n\r\n")
+		require.NoError(t, err)
+		raw, err := io.ReadAll(bufio.NewReader(conn))
+		require.NoError(t, err)
+		require.Equal(t, expectRaw, string(raw))
+	}
+
+	f(config.MalformedContentLength, "HTTP/1.1 200 OK\r\n"+
+		"Content-Length: 20\r\nX-Test: test\r\n\r\n0123456789")
+	f(config.MalformedTruncatedChunked, "HTTP/1.1 200 OK\r\n"+
+		"Transfer-Encoding: chunked\r\nX-Test: test\r\n\r\na\r\n01234")
+	f(config.MalformedStatusLine, "HTXP/one-point-one \x00\x7f garbage\r\n"+
+		"Content-Length: 10\r\nX-Test: test\r\n\r\n0123456789")
+	f(config.MalformedDuplicateHeaders, "HTTP/1.1 200 OK\r\n"+
+		"Content-Length: 10\r\nContent-Length: 11\r\nX-Test: test\r\n\r\n0123456789")
+}
+
+func TestMalformedUnsupported(t *testing.T) {
+	conf := config.Config{
+		Resources: []config.Resource{{Effect: &config.Effect{
+			Malformed: &config.Malformed{Kind: config.MalformedStatusLine},
+		}}},
+	}
+	_, s := NewSimulator(t, conf, func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("next handler must not be invoked")
+	})
+	rec := httptest.NewRecorder()
+	require.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		s.ServeHTTP(rec, NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody))
+	})
+}
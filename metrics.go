@@ -0,0 +1,106 @@
+package httpsim
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// DelayBuckets are the upper bounds of the buckets of
+// the injected delay histogram of ResourceMetrics.
+var DelayBuckets = [...]time.Duration{
+	5 * time.Millisecond, 10 * time.Millisecond, 25 * time.Millisecond,
+	50 * time.Millisecond, 100 * time.Millisecond, 250 * time.Millisecond,
+	500 * time.Millisecond, time.Second, 2500 * time.Millisecond,
+	5 * time.Second, 10 * time.Second, 30 * time.Second, time.Minute,
+}
+
+// ResourceMetrics is a snapshot of what the simulator did
+// to the requests matched by a resource.
+type ResourceMetrics struct {
+	// ResourceIndex is the index of the resource in Config.Resources
+	// or -1 for requests matched by no resource but the global effect.
+	ResourceIndex int
+
+	// Matches is the total number of matched requests.
+	Matches uint64
+
+	// Replacements is the number of responses written by the simulator
+	// instead of the next handler.
+	Replacements uint64
+
+	// Drops is the number of requests whose connection was dropped.
+	Drops uint64
+
+	// DelayBuckets holds the cumulative number of requests whose
+	// injected delay was less than or equal to the DelayBuckets bound
+	// of the same index.
+	DelayBuckets [len(DelayBuckets)]uint64
+
+	// DelayCount is the number of observed delays including
+	// the ones exceeding all DelayBuckets bounds.
+	DelayCount uint64
+
+	// DelaySum is the sum of all injected delays.
+	DelaySum time.Duration
+}
+
+// Metrics returns a snapshot of the metrics of all resources
+// in the current configuration preceded by the metrics of
+// the global effect if any. The metrics are reset by SetConfig.
+// Metrics is safe for concurrent use at runtime.
+func (m *Middleware) Metrics() []ResourceMetrics {
+	s := m.state.Load()
+	metrics := make([]ResourceMetrics, 0, len(s.resources)+1)
+	if s.conf.GlobalEffect != nil {
+		metrics = append(metrics, s.globalMetrics.snapshot(-1))
+	}
+	for i := range s.resources {
+		metrics = append(metrics, s.resources[i].metrics.snapshot(i))
+	}
+	return metrics
+}
+
+// metrics are the counters of a resource.
+type metrics struct {
+	matches      atomic.Uint64
+	replacements atomic.Uint64
+	drops        atomic.Uint64
+
+	// delayBuckets holds the non-cumulative number of observations
+	// of each bucket followed by the number of the ones exceeding all.
+	delayBuckets [len(DelayBuckets) + 1]atomic.Uint64
+	delaySum     atomic.Int64
+}
+
+// observe records the outcome of a matched request.
+func (m *metrics) observe(info *CtxInfo) {
+	if info.Replaced {
+		m.replacements.Add(1)
+	}
+	if info.Dropped {
+		m.drops.Add(1)
+	}
+	i := 0
+	for i < len(DelayBuckets) && info.Delay > DelayBuckets[i] {
+		i++
+	}
+	m.delayBuckets[i].Add(1)
+	m.delaySum.Add(int64(info.Delay))
+}
+
+func (m *metrics) snapshot(resourceIndex int) ResourceMetrics {
+	r := ResourceMetrics{
+		ResourceIndex: resourceIndex,
+		Matches:       m.matches.Load(),
+		Replacements:  m.replacements.Load(),
+		Drops:         m.drops.Load(),
+		DelaySum:      time.Duration(m.delaySum.Load()),
+	}
+	for i := range m.delayBuckets {
+		r.DelayCount += m.delayBuckets[i].Load()
+		if i < len(r.DelayBuckets) {
+			r.DelayBuckets[i] = r.DelayCount
+		}
+	}
+	return r
+}
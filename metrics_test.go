@@ -0,0 +1,93 @@
+package httpsim_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim"
+	"github.com/romshark/httpsim/config"
+)
+
+func TestMetrics(t *testing.T) {
+	body := "replaced"
+	delay := 200 * time.Millisecond
+	_, s := NewSimulator(t, config.Config{
+		GlobalEffect: &config.Effect{
+			Delay: &config.DurRange{Min: time.Second, Max: time.Second},
+		},
+		Resources: []config.Resource{
+			{
+				Path: NewGlobExpression(t, "/slow"),
+				Effect: &config.Effect{
+					Delay: &config.DurRange{Min: delay, Max: delay},
+				},
+			},
+			{
+				Path: NewGlobExpression(t, "/replaced"),
+				Effect: &config.Effect{Replace: &config.Replace{
+					StatusCode: http.StatusInternalServerError, Body: &body,
+				}},
+			},
+			{
+				Path:   NewGlobExpression(t, "/dropped"),
+				Effect: &config.Effect{Drop: &config.Drop{Rate: 1}},
+			},
+		},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	serve := func(path string) {
+		t.Helper()
+		r := NewRequest(t, http.MethodGet, "https://host.io"+path, http.NoBody)
+		rec := httptest.NewRecorder()
+		defer func() { _ = recover() }() // Drops abort the handler.
+		s.ServeHTTP(rec, r)
+	}
+	serve("/slow")
+	serve("/slow")
+	serve("/replaced")
+	serve("/dropped")
+	serve("/other")
+
+	metrics := s.Metrics()
+	require.Len(t, metrics, 4)
+
+	// Global delay of 1s for all requests.
+	global := metrics[0]
+	require.Equal(t, -1, global.ResourceIndex)
+	require.Equal(t, uint64(1), global.Matches)
+	require.Equal(t, uint64(1), global.DelayCount)
+	require.Equal(t, time.Second, global.DelaySum)
+
+	slow := metrics[1]
+	require.Equal(t, 0, slow.ResourceIndex)
+	require.Equal(t, uint64(2), slow.Matches)
+	require.Zero(t, slow.Replacements)
+	require.Equal(t, uint64(2), slow.DelayCount)
+	require.Equal(t, 2*(time.Second+delay), slow.DelaySum)
+	for i, bound := range httpsim.DelayBuckets {
+		expect := uint64(0)
+		if bound >= time.Second+delay {
+			expect = 2
+		}
+		require.Equal(t, expect, slow.DelayBuckets[i], "bucket %s", bound)
+	}
+
+	replaced := metrics[2]
+	require.Equal(t, uint64(1), replaced.Matches)
+	require.Equal(t, uint64(1), replaced.Replacements)
+	require.Zero(t, replaced.Drops)
+
+	dropped := metrics[3]
+	require.Equal(t, uint64(1), dropped.Matches)
+	require.Zero(t, dropped.Replacements)
+	require.Equal(t, uint64(1), dropped.Drops)
+
+	// SetConfig resets the metrics.
+	s.SetConfig(config.Config{Resources: []config.Resource{{}}})
+	require.Equal(t, []httpsim.ResourceMetrics{{ResourceIndex: 0}}, s.Metrics())
+}
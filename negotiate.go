@@ -0,0 +1,80 @@
+package httpsim
+
+import (
+	"mime"
+	"strconv"
+	"strings"
+
+	"github.com/romshark/httpsim/config"
+)
+
+// negotiate returns the variant most preferred by the Accept header value.
+// Ties are resolved in favor of the earlier variant. Returns the first
+// variant if accept is empty or none of the variants is acceptable.
+func negotiate(accept string, variants []config.Variant) *config.Variant {
+	if accept == "" {
+		return &variants[0]
+	}
+	ranges := parseAccept(accept)
+	best, bestQ := 0, 0.0
+	for i := range variants {
+		q := acceptQuality(ranges, variants[i].ContentType.Essence())
+		if q > bestQ {
+			best, bestQ = i, q
+		}
+	}
+	return &variants[best]
+}
+
+type mediaRange struct {
+	typ, subtype string
+	q            float64
+}
+
+// parseAccept parses the media ranges of an Accept header value
+// skipping malformed ones.
+func parseAccept(accept string) []mediaRange {
+	var ranges []mediaRange
+	for _, s := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(s))
+		if err != nil {
+			continue
+		}
+		typ, subtype, ok := strings.Cut(mediaType, "/")
+		if !ok {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil || q < 0 || q > 1 {
+				continue
+			}
+		}
+		ranges = append(ranges, mediaRange{typ: typ, subtype: subtype, q: q})
+	}
+	return ranges
+}
+
+// acceptQuality returns the quality of the most specific
+// range in ranges matching mediaType, or 0 if none match.
+func acceptQuality(ranges []mediaRange, mediaType string) float64 {
+	typ, subtype, _ := strings.Cut(mediaType, "/")
+	q, specificity := 0.0, -1
+	for _, r := range ranges {
+		var s int
+		switch {
+		case r.typ == typ && r.subtype == subtype:
+			s = 2
+		case r.typ == typ && r.subtype == "*":
+			s = 1
+		case r.typ == "*" && r.subtype == "*":
+			s = 0
+		default:
+			continue
+		}
+		if s > specificity {
+			q, specificity = r.q, s
+		}
+	}
+	return q
+}
@@ -0,0 +1,63 @@
+package httpsim_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim/config"
+)
+
+func TestVariants(t *testing.T) {
+	jsonBody, xmlBody, htmlBody := `{"error":"not found"}`,
+		`<error>not found</error>`, `<h1>Not Found</h1>`
+	conf := config.Config{
+		Resources: []config.Resource{{Effect: &config.Effect{
+			Replace: &config.Replace{
+				StatusCode: http.StatusNotFound,
+				Variants: []config.Variant{
+					{ContentType: "application/json", Body: &jsonBody},
+					{ContentType: "application/xml", Body: &xmlBody},
+					{ContentType: "text/html; charset=utf-8", Body: &htmlBody},
+				},
+			},
+		}}},
+	}
+	_, s := NewSimulator(t, conf, func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("next handler must not be invoked")
+	})
+	f := func(t *testing.T, accept, expectContentType, expectBody string) {
+		t.Helper()
+		r := NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody)
+		if accept != "" {
+			r.Header.Set("Accept", accept)
+		}
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, r)
+		require.Equal(t, http.StatusNotFound, rec.Code)
+		require.Equal(t, expectContentType, rec.Header().Get("Content-Type"))
+		require.Equal(t, "Accept", rec.Header().Get("Vary"))
+		require.Equal(t, expectBody, rec.Body.String())
+	}
+	const json, xml, html = "application/json",
+		"application/xml", "text/html; charset=utf-8"
+
+	// The first variant is the default.
+	f(t, "", json, jsonBody)
+	f(t, "*/*", json, jsonBody)
+	f(t, "image/png", json, jsonBody)
+	f(t, "image/png, application/xml;q=0", json, jsonBody)
+
+	f(t, "application/xml", xml, xmlBody)
+	f(t, "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8",
+		html, htmlBody)
+	f(t, "application/*;q=0.5, text/*;q=0.6", html, htmlBody)
+	f(t, "application/json;q=0.1, application/xml;q=0.2", xml, xmlBody)
+	f(t, "*/*;q=0.5, application/json;q=0", xml, xmlBody)
+	f(t, "TEXT/HTML", html, htmlBody)
+
+	// Malformed media ranges are ignored.
+	f(t, "application/xml;q=2, text/html;q=x, foo, text/html;q=0.3", html, htmlBody)
+}
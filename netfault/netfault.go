@@ -0,0 +1,166 @@
+// Package netfault degrades net.Conn connections by limiting their
+// read and write rates and injecting latency and random stalls.
+// Use it with httpsim.WrapListener on the server side or with
+// DialContext on the client side.
+package netfault
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+// throttleInterval is the pacing granularity of rate limits.
+const throttleInterval = 100 * time.Millisecond
+
+// Config defines the degradation of a connection.
+// The zero value doesn't degrade the connection at all.
+type Config struct {
+	// ReadBytesPerSecond limits the rate at which data is read.
+	// Zero means unlimited.
+	ReadBytesPerSecond uint64
+
+	// ReadBurst is the number of bytes read before throttling begins.
+	ReadBurst uint64
+
+	// WriteBytesPerSecond limits the rate at which data is written.
+	// Zero means unlimited.
+	WriteBytesPerSecond uint64
+
+	// WriteBurst is the number of bytes written before throttling begins.
+	WriteBurst uint64
+
+	// Latency, if not nil, returns the delay of each read and write,
+	// which are the packets of the connection.
+	Latency func() time.Duration
+
+	// Stall, if not nil, returns the duration the next read or write
+	// stalls for before it's performed, zero for no stall.
+	Stall func() time.Duration
+
+	// Sleep sleeps for the given duration. Defaults to time.Sleep.
+	Sleep func(time.Duration)
+}
+
+// Conn is a degraded net.Conn. Reads and writes may be performed
+// concurrently, but concurrent reads and concurrent writes are serialized.
+type Conn struct {
+	net.Conn
+	conf  Config
+	read  bucket
+	write bucket
+}
+
+var _ net.Conn = new(Conn)
+
+// NewConn wraps c degrading it according to conf.
+func NewConn(c net.Conn, conf Config) *Conn {
+	if conf.Sleep == nil {
+		conf.Sleep = time.Sleep
+	}
+	return &Conn{
+		Conn:  c,
+		conf:  conf,
+		read:  newBucket(conf.ReadBytesPerSecond, conf.ReadBurst),
+		write: newBucket(conf.WriteBytesPerSecond, conf.WriteBurst),
+	}
+}
+
+// Read reads at most as many bytes as the read rate permits.
+func (c *Conn) Read(b []byte) (int, error) {
+	c.read.lock.Lock()
+	defer c.read.lock.Unlock()
+	c.delay()
+	if len(b) > 0 {
+		b = b[:c.read.take(uint64(len(b)), c.conf.Sleep)]
+	}
+	n, err := c.Conn.Read(b)
+	c.read.giveBack(uint64(len(b) - n))
+	return n, err
+}
+
+// Write writes b in chunks paced by the write rate.
+func (c *Conn) Write(b []byte) (written int, err error) {
+	c.write.lock.Lock()
+	defer c.write.lock.Unlock()
+	c.delay()
+	for len(b) > 0 {
+		n := c.write.take(uint64(len(b)), c.conf.Sleep)
+		nw, err := c.Conn.Write(b[:n])
+		written += nw
+		if err != nil {
+			return written, err
+		}
+		b = b[n:]
+	}
+	return written, nil
+}
+
+// delay sleeps for the stall and latency of the next read or write.
+func (c *Conn) delay() {
+	var d time.Duration
+	if c.conf.Stall != nil {
+		d += c.conf.Stall()
+	}
+	if c.conf.Latency != nil {
+		d += c.conf.Latency()
+	}
+	if d > 0 {
+		c.conf.Sleep(d)
+	}
+}
+
+// bucket is a token bucket limiting the transfer rate.
+type bucket struct {
+	lock   sync.Mutex
+	rate   uint64 // Bytes per second, zero is unlimited.
+	chunk  uint64 // Bytes per throttleInterval.
+	tokens uint64 // Bytes that can be transferred without sleeping.
+}
+
+func newBucket(bytesPerSecond, burst uint64) bucket {
+	chunk := bytesPerSecond / uint64(time.Second/throttleInterval)
+	return bucket{rate: bytesPerSecond, chunk: max(chunk, 1), tokens: burst}
+}
+
+// take returns the number of bytes up to n that can be transferred,
+// sleeping if the bucket is empty.
+func (b *bucket) take(n uint64, sleep func(time.Duration)) uint64 {
+	if b.rate == 0 {
+		return n
+	}
+	if b.tokens == 0 {
+		sleep(time.Duration(b.chunk * uint64(time.Second) / b.rate))
+		b.tokens = b.chunk
+	}
+	n = min(n, b.tokens)
+	b.tokens -= n
+	return n
+}
+
+// giveBack returns n unused bytes to the bucket.
+func (b *bucket) giveBack(n uint64) {
+	if b.rate != 0 {
+		b.tokens += n
+	}
+}
+
+// DialContextFunc is the signature of net.Dialer.DialContext.
+type DialContextFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// DialContext returns a dial function degrading the connections
+// established by dial according to conf, such as for the DialContext
+// of http.Transport. Uses net.Dialer if dial is nil.
+func DialContext(dial DialContextFunc, conf Config) DialContextFunc {
+	if dial == nil {
+		dial = new(net.Dialer).DialContext
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		c, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return NewConn(c, conf), nil
+	}
+}
@@ -0,0 +1,162 @@
+package netfault_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim/netfault"
+)
+
+type MockSleep struct {
+	lock   sync.Mutex
+	Sleeps []time.Duration
+}
+
+func (s *MockSleep) Sleep(d time.Duration) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.Sleeps = append(s.Sleeps, d)
+}
+
+func (s *MockSleep) Cumulative() (c time.Duration) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, d := range s.Sleeps {
+		c += d
+	}
+	return c
+}
+
+// NewPipe returns both ends of a synchronous in-memory connection
+// with the first end wrapped using conf.
+func NewPipe(t *testing.T, conf netfault.Config) (*netfault.Conn, net.Conn) {
+	t.Helper()
+	a, b := net.Pipe()
+	t.Cleanup(func() { _ = a.Close(); _ = b.Close() })
+	return netfault.NewConn(a, conf), b
+}
+
+func TestWriteThroughput(t *testing.T) {
+	s := new(MockSleep)
+	c, peer := NewPipe(t, netfault.Config{
+		WriteBytesPerSecond: 10_000, WriteBurst: 500, Sleep: s.Sleep,
+	})
+	data := strings.Repeat("x", 2500)
+	go func() {
+		_, _ = c.Write([]byte(data))
+		_ = c.Close()
+	}()
+	b, err := io.ReadAll(peer)
+	require.NoError(t, err)
+	require.Equal(t, data, string(b))
+	// The burst of 500 bytes is followed by chunks of 1000 bytes per 100ms.
+	require.Equal(t, []time.Duration{
+		100 * time.Millisecond, 100 * time.Millisecond,
+	}, s.Sleeps)
+}
+
+func TestReadThroughput(t *testing.T) {
+	s := new(MockSleep)
+	c, peer := NewPipe(t, netfault.Config{
+		ReadBytesPerSecond: 10_000, Sleep: s.Sleep,
+	})
+	data := strings.Repeat("x", 2500)
+	go func() {
+		_, _ = peer.Write([]byte(data))
+		_ = peer.Close()
+	}()
+
+	b := make([]byte, len(data))
+	n, err := c.Read(b)
+	require.NoError(t, err)
+	require.Equal(t, 1000, n)
+	require.Equal(t, []time.Duration{100 * time.Millisecond}, s.Sleeps)
+
+	rest, err := io.ReadAll(c)
+	require.NoError(t, err)
+	require.Len(t, rest, 1500)
+	require.Equal(t, 300*time.Millisecond, s.Cumulative())
+}
+
+func TestLatencyAndStalls(t *testing.T) {
+	s := new(MockSleep)
+	calls := 0
+	c, peer := NewPipe(t, netfault.Config{
+		Latency: func() time.Duration { return 10 * time.Millisecond },
+		Stall: func() time.Duration {
+			calls++
+			if calls == 2 {
+				return time.Second
+			}
+			return 0
+		},
+		Sleep: s.Sleep,
+	})
+	go func() {
+		b := make([]byte, 4)
+		for {
+			if _, err := peer.Read(b); err != nil {
+				return
+			}
+		}
+	}()
+	for range 3 {
+		_, err := c.Write([]byte("ping"))
+		require.NoError(t, err)
+	}
+	require.Equal(t, []time.Duration{
+		10 * time.Millisecond,
+		time.Second + 10*time.Millisecond,
+		10 * time.Millisecond,
+	}, s.Sleeps)
+}
+
+func TestZeroConfig(t *testing.T) {
+	c, peer := NewPipe(t, netfault.Config{})
+	go func() {
+		_, _ = c.Write([]byte("hello"))
+		_ = c.Close()
+	}()
+	b, err := io.ReadAll(peer)
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(b))
+}
+
+func TestDialContext(t *testing.T) {
+	s := new(MockSleep)
+	var peer net.Conn
+	errDial := errors.New("dial failed")
+	dial := netfault.DialContext(
+		func(ctx context.Context, network, addr string) (net.Conn, error) {
+			if addr == "down:80" {
+				return nil, errDial
+			}
+			var c net.Conn
+			c, peer = net.Pipe()
+			return c, nil
+		},
+		netfault.Config{
+			Latency: func() time.Duration { return time.Second }, Sleep: s.Sleep,
+		},
+	)
+
+	_, err := dial(context.Background(), "tcp", "down:80")
+	require.ErrorIs(t, err, errDial)
+
+	c, err := dial(context.Background(), "tcp", "up:80")
+	require.NoError(t, err)
+	require.IsType(t, new(netfault.Conn), c)
+	t.Cleanup(func() { _ = c.Close() })
+	go func() { _, _ = peer.Write([]byte("x")) }()
+	_, err = c.Read(make([]byte, 1))
+	require.NoError(t, err)
+	require.Equal(t, time.Second, s.Cumulative())
+}
@@ -0,0 +1,42 @@
+package httpsim
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/romshark/httpsim/config"
+)
+
+// IsConditional returns true if r carries an If-None-Match
+// or If-Modified-Since header.
+func IsConditional(r *http.Request) bool {
+	return r.Header.Get("If-None-Match") != "" ||
+		r.Header.Get("If-Modified-Since") != ""
+}
+
+// notModified responds with 304 Not Modified and the validators
+// of c or r.
+func notModified(w http.ResponseWriter, r *http.Request, c *config.NotModified) {
+	etag := c.ETag
+	if etag == "" {
+		etag = firstETag(r.Header.Get("If-None-Match"))
+	}
+	if etag != "" {
+		w.Header().Set("ETag", etag)
+	}
+	if v := r.Header.Get("If-Modified-Since"); v != "" {
+		w.Header().Set("Last-Modified", v)
+	}
+	w.WriteHeader(http.StatusNotModified)
+}
+
+// firstETag returns the first entity tag of an If-None-Match value
+// or "" if there's none.
+func firstETag(ifNoneMatch string) string {
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" && tag != "*" {
+			return tag
+		}
+	}
+	return ""
+}
@@ -0,0 +1,96 @@
+package httpsim_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim"
+	"github.com/romshark/httpsim/config"
+)
+
+func TestIsConditional(t *testing.T) {
+	r := NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody)
+	require.False(t, httpsim.IsConditional(r))
+	r.Header.Set("If-None-Match", `"v1"`)
+	require.True(t, httpsim.IsConditional(r))
+	r.Header.Del("If-None-Match")
+	r.Header.Set("If-Modified-Since", "Wed, 21 Oct 2015 07:28:00 GMT")
+	require.True(t, httpsim.IsConditional(r))
+}
+
+func TestMatchResourceConditional(t *testing.T) {
+	conditional, unconditional := true, false
+	r := NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody)
+	require.False(t, httpsim.MatchResource(r, &config.Resource{Conditional: &conditional}))
+	require.True(t, httpsim.MatchResource(r, &config.Resource{Conditional: &unconditional}))
+	r.Header.Set("If-None-Match", `"v1"`)
+	require.True(t, httpsim.MatchResource(r, &config.Resource{Conditional: &conditional}))
+	require.False(t, httpsim.MatchResource(r, &config.Resource{Conditional: &unconditional}))
+}
+
+func TestNotModified(t *testing.T) {
+	f := func(t *testing.T, c config.NotModified, header http.Header,
+		expectStatus int, expectHeader http.Header,
+	) {
+		t.Helper()
+		conf := config.Config{
+			Resources: []config.Resource{{Effect: &config.Effect{NotModified: &c}}},
+		}
+		_, s := NewSimulator(t, conf, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("ETag", `"fresh"`)
+			w.WriteHeader(http.StatusOK)
+		})
+		r := NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody)
+		r.Header = header
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, r)
+		require.Equal(t, expectStatus, rec.Code)
+		require.Equal(t, expectHeader, rec.Header())
+		if expectStatus == http.StatusNotModified {
+			require.Zero(t, rec.Body.Len())
+		}
+	}
+	const date = "Wed, 21 Oct 2015 07:28:00 GMT"
+
+	f(t, config.NotModified{Rate: 1}, http.Header{
+		"If-None-Match": {`W/"a", "b"`},
+	}, http.StatusNotModified, http.Header{"Etag": {`W/"a"`}})
+	f(t, config.NotModified{Rate: 1}, http.Header{
+		"If-None-Match": {`*`}, "If-Modified-Since": {date},
+	}, http.StatusNotModified, http.Header{"Last-Modified": {date}})
+	f(t, config.NotModified{Rate: 1, ETag: `"v2"`}, http.Header{
+		"If-None-Match": {`"v1"`},
+	}, http.StatusNotModified, http.Header{"Etag": {`"v2"`}})
+
+	// Unconditional requests pass through.
+	f(t, config.NotModified{Rate: 1}, http.Header{},
+		http.StatusOK, http.Header{"Etag": {`"fresh"`}})
+	f(t, config.NotModified{Rate: 0}, http.Header{"If-None-Match": {`"v1"`}},
+		http.StatusOK, http.Header{"Etag": {`"fresh"`}})
+}
+
+func TestNotModifiedRate(t *testing.T) {
+	conf := config.Config{
+		Resources: []config.Resource{
+			{Effect: &config.Effect{NotModified: &config.NotModified{Rate: 0.3}}},
+		},
+	}
+	_, s := NewSimulator(t, conf, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	notModified := 0
+	for range 1000 {
+		r := NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody)
+		r.Header.Set("If-None-Match", `"v1"`)
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, r)
+		if rec.Code == http.StatusNotModified {
+			notModified++
+		}
+	}
+	require.GreaterOrEqual(t, notModified, 250)
+	require.LessOrEqual(t, notModified, 350)
+}
@@ -0,0 +1,68 @@
+package httpsim
+
+import (
+	"net/http"
+	"time"
+)
+
+// Observer is notified of the decisions of the middleware, which allows
+// integrating custom telemetry and assertions. Its methods are called
+// synchronously by the goroutine serving the request and must be safe
+// for concurrent use. Embed NopObserver to implement only some of them.
+type Observer interface {
+	// OnMatch is called when r is matched by the resource of the given index
+	// or by no resource but the global effect, in which case
+	// resourceIndex is -1.
+	OnMatch(r *http.Request, resourceIndex int)
+
+	// OnDelay is called with the total delay injected before
+	// the response to r is written or r is passed through.
+	OnDelay(r *http.Request, d time.Duration)
+
+	// OnReplace is called when the response to r was written
+	// by the simulator instead of the next handler.
+	OnReplace(r *http.Request, resourceIndex int)
+
+	// OnPassThrough is called before r is passed to the next handler.
+	OnPassThrough(r *http.Request)
+
+	// OnError is called when the simulator fails to apply an effect to r,
+	// such as when a body file can't be read or a recording can't be saved.
+	OnError(r *http.Request, err error)
+}
+
+// NopObserver is an Observer that does nothing.
+type NopObserver struct{}
+
+var _ Observer = NopObserver{}
+
+func (NopObserver) OnMatch(*http.Request, int)           {}
+func (NopObserver) OnDelay(*http.Request, time.Duration) {}
+func (NopObserver) OnReplace(*http.Request, int)         {}
+func (NopObserver) OnPassThrough(*http.Request)          {}
+func (NopObserver) OnError(*http.Request, error)         {}
+
+// WithObserver makes the middleware notify o of its decisions
+// from the first request on, see SetObserver.
+func WithObserver(o Observer) Option {
+	return func(m *Middleware) { m.SetObserver(o) }
+}
+
+// SetObserver makes the middleware notify o of its decisions.
+// A nil o removes the observer, which is the default.
+// SetObserver is safe for concurrent use at runtime.
+func (m *Middleware) SetObserver(o Observer) {
+	if o == nil {
+		m.observer.Store(nil)
+		return
+	}
+	m.observer.Store(&o)
+}
+
+// observe returns the observer, which is NopObserver if there's none.
+func (m *Middleware) observe() Observer {
+	if o := m.observer.Load(); o != nil {
+		return *o
+	}
+	return NopObserver{}
+}
@@ -0,0 +1,134 @@
+package httpsim_test
+
+import (
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim"
+	"github.com/romshark/httpsim/config"
+)
+
+// RecordingObserver records the calls to its methods.
+type RecordingObserver struct {
+	Calls  []string
+	Errors []error
+}
+
+var _ httpsim.Observer = new(RecordingObserver)
+
+func (o *RecordingObserver) OnMatch(r *http.Request, resourceIndex int) {
+	o.Calls = append(o.Calls, fmt.Sprintf("match %s %d", r.URL.Path, resourceIndex))
+}
+
+func (o *RecordingObserver) OnDelay(r *http.Request, d time.Duration) {
+	o.Calls = append(o.Calls, fmt.Sprintf("delay %s %s", r.URL.Path, d))
+}
+
+func (o *RecordingObserver) OnReplace(r *http.Request, resourceIndex int) {
+	o.Calls = append(o.Calls, fmt.Sprintf("replace %s %d", r.URL.Path, resourceIndex))
+}
+
+func (o *RecordingObserver) OnPassThrough(r *http.Request) {
+	o.Calls = append(o.Calls, "pass "+r.URL.Path)
+}
+
+func (o *RecordingObserver) OnError(r *http.Request, err error) {
+	o.Calls = append(o.Calls, "error "+r.URL.Path)
+	o.Errors = append(o.Errors, err)
+}
+
+func TestObserver(t *testing.T) {
+	body := "replaced"
+	missing := filepath.Join(t.TempDir(), "missing.json")
+	_, s := NewSimulator(t, config.Config{
+		Resources: []config.Resource{
+			{
+				Path: NewGlobExpression(t, "/slow"),
+				Effect: &config.Effect{
+					Delay: &config.DurRange{Min: time.Second, Max: time.Second},
+				},
+			},
+			{
+				Path: NewGlobExpression(t, "/replaced"),
+				Effect: &config.Effect{
+					Delay: &config.DurRange{Min: time.Second, Max: time.Second},
+					Replace: &config.Replace{
+						StatusCode: http.StatusBadGateway, Body: &body,
+					},
+				},
+			},
+			{
+				Path: NewGlobExpression(t, "/broken"),
+				Effect: &config.Effect{Replace: &config.Replace{
+					StatusCode: http.StatusOK, BodyFile: &missing,
+				}},
+			},
+		},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	o := new(RecordingObserver)
+	s.SetObserver(o)
+
+	for _, path := range []string{"/slow", "/replaced", "/broken", "/other"} {
+		r := NewRequest(t, http.MethodGet, "https://host.io"+path, http.NoBody)
+		s.ServeHTTP(httptest.NewRecorder(), r)
+	}
+	require.Equal(t, []string{
+		"match /slow 0", "delay /slow 1s", "pass /slow",
+		"match /replaced 1", "delay /replaced 1s", "replace /replaced 1",
+		"match /broken 2", "error /broken", "replace /broken 2",
+		"pass /other",
+	}, o.Calls)
+	require.Len(t, o.Errors, 1)
+	require.ErrorIs(t, o.Errors[0], fs.ErrNotExist)
+
+	// Removed.
+	o.Calls = nil
+	s.SetObserver(nil)
+	r := NewRequest(t, http.MethodGet, "https://host.io/slow", http.NoBody)
+	s.ServeHTTP(httptest.NewRecorder(), r)
+	require.Empty(t, o.Calls)
+}
+
+// MatchObserver embeds NopObserver to only implement OnMatch.
+type MatchObserver struct {
+	httpsim.NopObserver
+	Matches int
+}
+
+func (o *MatchObserver) OnMatch(*http.Request, int) { o.Matches++ }
+
+func TestNopObserver(t *testing.T) {
+	o := new(MatchObserver)
+	_, s := NewSimulator(t, config.Config{
+		Resources: []config.Resource{{Effect: &config.Effect{
+			Delay: &config.DurRange{Min: time.Second, Max: time.Second},
+		}}},
+	}, func(w http.ResponseWriter, r *http.Request) {})
+	s.SetObserver(o)
+	s.ServeHTTP(httptest.NewRecorder(),
+		NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody))
+	require.Equal(t, 1, o.Matches)
+}
+
+func TestWithObserver(t *testing.T) {
+	o := new(MatchObserver)
+	s := httpsim.NewMiddleware(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {},
+	), config.Config{
+		Resources: []config.Resource{{Effect: &config.Effect{
+			Delay: &config.DurRange{Min: time.Second, Max: time.Second},
+		}}},
+	}, new(MockSleep), nil, httpsim.WithObserver(o))
+	s.ServeHTTP(httptest.NewRecorder(),
+		NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody))
+	require.Equal(t, 1, o.Matches)
+}
@@ -0,0 +1,75 @@
+package httpsim
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/romshark/httpsim/config"
+)
+
+// padWriter pads the body once the response is finished.
+type padWriter struct {
+	http.ResponseWriter
+	c *config.Pad
+
+	status  int // Zero until the status is written.
+	written uint64
+}
+
+func (w *padWriter) WriteHeader(statusCode int) {
+	if w.status != 0 || statusCode < 200 {
+		w.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+	w.status = statusCode
+	if cl := w.Header().Get("Content-Length"); cl != "" && w.hasBody() {
+		if n, err := strconv.ParseUint(cl, 10, 64); err == nil {
+			w.Header().Set("Content-Length",
+				strconv.FormatUint(n+w.c.Size(n), 10))
+		}
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *padWriter) hasBody() bool {
+	return w.status != http.StatusNoContent && w.status != http.StatusNotModified
+}
+
+func (w *padWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.written += uint64(n)
+	return n, err
+}
+
+// Unwrap allows http.ResponseController to access the underlying writer.
+func (w *padWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+// padChunkSize is the maximum size of a single padding write.
+const padChunkSize = 32 * 1024
+
+// finish writes the padding.
+func (w *padWriter) finish() {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.hasBody() {
+		return
+	}
+	size := w.c.Size(w.written)
+	filler := w.c.Filler
+	if filler == "" {
+		filler = " "
+	}
+	chunk := []byte(strings.Repeat(filler, max(padChunkSize/len(filler), 1)))
+	for size > 0 {
+		n := min(size, uint64(len(chunk)))
+		if _, err := w.ResponseWriter.Write(chunk[:n]); err != nil {
+			return
+		}
+		size -= n
+	}
+}
@@ -0,0 +1,75 @@
+package httpsim_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim/config"
+)
+
+func TestPad(t *testing.T) {
+	f := func(
+		c config.Pad, method string, next http.HandlerFunc,
+		expectContentLength, expectBody string,
+	) {
+		t.Helper()
+		_, s := NewSimulator(t, config.Config{
+			Resources: []config.Resource{{Effect: &config.Effect{Pad: &c}}},
+		}, next)
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, NewRequest(t, method, "https://host.io/", http.NoBody))
+		require.Equal(t, expectContentLength, rec.Header().Get("Content-Length"))
+		require.Equal(t, expectBody, rec.Body.String())
+	}
+	write := func(body string, contentLength bool) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if contentLength {
+				w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			}
+			_, _ = io.WriteString(w, body)
+		}
+	}
+
+	f(config.Pad{TargetSize: 8}, http.MethodGet, write("{}", false),
+		"", "{}      ")
+	f(config.Pad{TargetSize: 8}, http.MethodGet, write("{}", true),
+		"8", "{}      ")
+	f(config.Pad{TargetSize: 1}, http.MethodGet, write("{}", true),
+		"2", "{}")
+	f(config.Pad{Append: 5, Filler: "ab"}, http.MethodGet, write("{}", true),
+		"7", "{}ababa")
+	f(config.Pad{Append: 3}, http.MethodGet,
+		func(w http.ResponseWriter, r *http.Request) {}, "", "   ")
+
+	// No body.
+	f(config.Pad{Append: 3}, http.MethodHead, write("", false), "", "")
+	f(config.Pad{Append: 3}, http.MethodGet,
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}, "", "")
+
+	// Large padding.
+	f(config.Pad{TargetSize: 100_000, Filler: "0123456789"}, http.MethodGet,
+		write("", false), "", strings.Repeat("0123456789", 10_000))
+}
+
+func TestPadReplaced(t *testing.T) {
+	body := "hello"
+	_, s := NewSimulator(t, config.Config{
+		Resources: []config.Resource{{Effect: &config.Effect{
+			Replace: &config.Replace{StatusCode: http.StatusOK, Body: &body},
+			Pad:     &config.Pad{TargetSize: 10, Filler: "."},
+		}}},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("next handler must not be invoked")
+	})
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody))
+	require.Equal(t, "hello.....", rec.Body.String())
+}
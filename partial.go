@@ -0,0 +1,78 @@
+package httpsim
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/romshark/httpsim/config"
+)
+
+// partialContent returns the status and body of the response to
+// the Range header of r given the replacement status and body.
+func partialContent(
+	h http.Header, r *http.Request, c *config.PartialContent, status int, body []byte,
+) (int, []byte) {
+	if status != http.StatusOK {
+		return status, body
+	}
+	if c.Mode != config.PartialContentIgnore {
+		h.Set("Accept-Ranges", "bytes")
+	}
+	header := r.Header.Get("Range")
+	if header == "" || c.Mode == config.PartialContentIgnore {
+		return status, body
+	}
+	start, end, ok := parseRange(header, int64(len(body)))
+	if !ok {
+		return status, body // Invalid or multiple ranges.
+	}
+	if c.Mode == config.PartialContentUnsatisfiable || start < 0 {
+		h.Set("Content-Range", fmt.Sprintf("bytes */%d", len(body)))
+		h.Del("Content-Length")
+		return http.StatusRequestedRangeNotSatisfiable, nil
+	}
+	h.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(body)))
+	h.Del("Content-Length")
+	return http.StatusPartialContent, body[start : end+1]
+}
+
+// parseRange returns the inclusive bounds of the single byte range
+// of header within size, start < 0 if it's unsatisfiable,
+// or false if header is invalid or has multiple ranges.
+func parseRange(header string, size int64) (start, end int64, ok bool) {
+	spec, ok := strings.CutPrefix(header, "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return 0, 0, false
+	}
+	first, last, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return 0, 0, false
+	}
+	if first == "" { // Suffix range, such as "-500".
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n < 0 {
+			return 0, 0, false
+		}
+		if n == 0 || size == 0 {
+			return -1, 0, true
+		}
+		return max(size-n, 0), size - 1, true
+	}
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 {
+		return 0, 0, false
+	}
+	end = size - 1
+	if last != "" {
+		if end, err = strconv.ParseInt(last, 10, 64); err != nil || end < start {
+			return 0, 0, false
+		}
+		end = min(end, size-1)
+	}
+	if start >= size {
+		return -1, 0, true
+	}
+	return start, end, true
+}
@@ -0,0 +1,78 @@
+package httpsim_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim"
+	"github.com/romshark/httpsim/config"
+)
+
+func TestMatchResourceRangeRequest(t *testing.T) {
+	ranged, unranged := true, false
+	r := NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody)
+	require.False(t, httpsim.MatchResource(r, &config.Resource{RangeRequest: &ranged}))
+	require.True(t, httpsim.MatchResource(r, &config.Resource{RangeRequest: &unranged}))
+	r.Header.Set("Range", "bytes=0-")
+	require.True(t, httpsim.MatchResource(r, &config.Resource{RangeRequest: &ranged}))
+	require.False(t, httpsim.MatchResource(r, &config.Resource{RangeRequest: &unranged}))
+}
+
+func TestPartialContent(t *testing.T) {
+	f := func(t *testing.T,
+		mode config.PartialContentMode, status config.StatusCode, rangeHeader string,
+		expectStatus int, expectContentRange, expectBody string,
+	) {
+		t.Helper()
+		body := "0123456789"
+		conf := config.Config{
+			Resources: []config.Resource{{Effect: &config.Effect{
+				Replace: &config.Replace{
+					StatusCode: status,
+					Body:       &body,
+					Headers:    map[config.HeaderName]string{"Content-Length": "10"},
+				},
+				PartialContent: &config.PartialContent{Mode: mode},
+			}}},
+		}
+		_, s := NewSimulator(t, conf, func(w http.ResponseWriter, r *http.Request) {
+			t.Fatal("next handler must not be invoked")
+		})
+		r := NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody)
+		if rangeHeader != "" {
+			r.Header.Set("Range", rangeHeader)
+		}
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, r)
+		require.Equal(t, expectStatus, rec.Code)
+		require.Equal(t, expectContentRange, rec.Header().Get("Content-Range"))
+		require.Equal(t, expectBody, rec.Body.String())
+	}
+	const ok, partial, unsatisfiable = http.StatusOK,
+		http.StatusPartialContent, http.StatusRequestedRangeNotSatisfiable
+
+	f(t, "", ok, "", ok, "", "0123456789")
+	f(t, "", ok, "bytes=2-4", partial, "bytes 2-4/10", "234")
+	f(t, config.PartialContentServe, ok, "bytes=7-", partial, "bytes 7-9/10", "789")
+	f(t, "", ok, "bytes=-3", partial, "bytes 7-9/10", "789")
+	f(t, "", ok, "bytes=-30", partial, "bytes 0-9/10", "0123456789")
+	f(t, "", ok, "bytes=5-100", partial, "bytes 5-9/10", "56789")
+	f(t, "", ok, "bytes=10-", unsatisfiable, "bytes */10", "")
+	f(t, "", ok, "bytes=-0", unsatisfiable, "bytes */10", "")
+
+	// Invalid and multiple ranges are ignored.
+	f(t, "", ok, "bytes=0-1,3-4", ok, "", "0123456789")
+	f(t, "", ok, "bytes=4-2", ok, "", "0123456789")
+	f(t, "", ok, "items=0-1", ok, "", "0123456789")
+
+	f(t, config.PartialContentIgnore, ok, "bytes=2-4", ok, "", "0123456789")
+	f(t, config.PartialContentUnsatisfiable, ok, "bytes=2-4",
+		unsatisfiable, "bytes */10", "")
+	f(t, config.PartialContentUnsatisfiable, ok, "", ok, "", "0123456789")
+
+	// Only 200 replacements are served partially.
+	f(t, "", http.StatusNotFound, "bytes=2-4", http.StatusNotFound, "", "0123456789")
+}
@@ -0,0 +1,46 @@
+package httpsim_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim/config"
+)
+
+func TestEffectProbability(t *testing.T) {
+	f := func(p config.Probability, requests int, expectMin, expectMax int) {
+		t.Helper()
+		conf := config.Config{
+			Resources: []config.Resource{
+				{Effect: &config.Effect{
+					Probability: &p,
+					Replace: &config.Replace{
+						StatusCode: http.StatusServiceUnavailable,
+					},
+				}},
+			},
+		}
+		passedThrough := 0
+		_, s := NewSimulator(t, conf, func(w http.ResponseWriter, r *http.Request) {
+			passedThrough++
+		})
+		replaced := 0
+		for range requests {
+			rec := httptest.NewRecorder()
+			s.ServeHTTP(rec, NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody))
+			if rec.Code == http.StatusServiceUnavailable {
+				replaced++
+			}
+		}
+		require.Equal(t, requests, replaced+passedThrough)
+		require.GreaterOrEqual(t, replaced, expectMin)
+		require.LessOrEqual(t, replaced, expectMax)
+	}
+
+	f(0, 100, 0, 0)
+	f(1, 100, 100, 100)
+	f(0.3, 1000, 250, 350)
+}
@@ -0,0 +1,72 @@
+// Package prom exports the metrics of an httpsim.Middleware to Prometheus.
+package prom
+
+import (
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/romshark/httpsim"
+)
+
+// Collector is a prometheus.Collector exporting the metrics
+// of a middleware labeled by resource. The resource label is the index
+// of the resource in the config or "global" for requests matched
+// by no resource but the global effect.
+type Collector struct {
+	m            *httpsim.Middleware
+	matches      *prometheus.Desc
+	replacements *prometheus.Desc
+	drops        *prometheus.Desc
+	delay        *prometheus.Desc
+}
+
+var _ prometheus.Collector = new(Collector)
+
+// NewCollector creates a new collector of the metrics of m.
+func NewCollector(m *httpsim.Middleware) *Collector {
+	labels := []string{"resource"}
+	return &Collector{
+		m: m,
+		matches: prometheus.NewDesc("httpsim_matches_total",
+			"Number of requests matched by the simulator.", labels, nil),
+		replacements: prometheus.NewDesc("httpsim_replacements_total",
+			"Number of responses written by the simulator instead of the next handler.",
+			labels, nil),
+		drops: prometheus.NewDesc("httpsim_drops_total",
+			"Number of requests whose connection was dropped by the simulator.",
+			labels, nil),
+		delay: prometheus.NewDesc("httpsim_injected_delay_seconds",
+			"Delay injected by the simulator into matched requests.", labels, nil),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.matches
+	ch <- c.replacements
+	ch <- c.drops
+	ch <- c.delay
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	for _, r := range c.m.Metrics() {
+		resource := "global"
+		if r.ResourceIndex != -1 {
+			resource = strconv.Itoa(r.ResourceIndex)
+		}
+		ch <- prometheus.MustNewConstMetric(c.matches,
+			prometheus.CounterValue, float64(r.Matches), resource)
+		ch <- prometheus.MustNewConstMetric(c.replacements,
+			prometheus.CounterValue, float64(r.Replacements), resource)
+		ch <- prometheus.MustNewConstMetric(c.drops,
+			prometheus.CounterValue, float64(r.Drops), resource)
+		buckets := make(map[float64]uint64, len(r.DelayBuckets))
+		for i, n := range r.DelayBuckets {
+			buckets[httpsim.DelayBuckets[i].Seconds()] = n
+		}
+		ch <- prometheus.MustNewConstHistogram(c.delay,
+			r.DelayCount, r.DelaySum.Seconds(), buckets, resource)
+	}
+}
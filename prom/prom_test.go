@@ -0,0 +1,98 @@
+package prom_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim"
+	"github.com/romshark/httpsim/config"
+	"github.com/romshark/httpsim/prom"
+)
+
+type NoSleep struct{}
+
+func (NoSleep) Sleep(time.Duration) {}
+
+func TestCollector(t *testing.T) {
+	conf, err := config.Load(strings.NewReader(`
+resources:
+  - path: /slow
+    effect:
+      delay: 1s
+  - path: /replaced
+    effect:
+      replace:
+        status-code: 503
+        body: replaced
+`))
+	require.NoError(t, err)
+	m := httpsim.NewMiddleware(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {},
+	), *conf, NoSleep{}, nil)
+	for _, path := range []string{"/slow", "/replaced", "/replaced"} {
+		r := httptest.NewRequest(http.MethodGet, "https://host.io"+path, http.NoBody)
+		m.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	c := prom.NewCollector(m)
+	require.NoError(t, testutil.CollectAndCompare(c, strings.NewReader(`
+# HELP httpsim_matches_total Number of requests matched by the simulator.
+# TYPE httpsim_matches_total counter
+httpsim_matches_total{resource="0"} 1
+httpsim_matches_total{resource="1"} 2
+# HELP httpsim_replacements_total Number of responses written by the simulator instead of the next handler.
+# TYPE httpsim_replacements_total counter
+httpsim_replacements_total{resource="0"} 0
+httpsim_replacements_total{resource="1"} 2
+`), "httpsim_matches_total", "httpsim_replacements_total"))
+
+	require.NoError(t, testutil.CollectAndCompare(c, strings.NewReader(`
+# HELP httpsim_injected_delay_seconds Delay injected by the simulator into matched requests.
+# TYPE httpsim_injected_delay_seconds histogram
+httpsim_injected_delay_seconds_bucket{resource="0",le="0.005"} 0
+httpsim_injected_delay_seconds_bucket{resource="0",le="0.01"} 0
+httpsim_injected_delay_seconds_bucket{resource="0",le="0.025"} 0
+httpsim_injected_delay_seconds_bucket{resource="0",le="0.05"} 0
+httpsim_injected_delay_seconds_bucket{resource="0",le="0.1"} 0
+httpsim_injected_delay_seconds_bucket{resource="0",le="0.25"} 0
+httpsim_injected_delay_seconds_bucket{resource="0",le="0.5"} 0
+httpsim_injected_delay_seconds_bucket{resource="0",le="1"} 1
+httpsim_injected_delay_seconds_bucket{resource="0",le="2.5"} 1
+httpsim_injected_delay_seconds_bucket{resource="0",le="5"} 1
+httpsim_injected_delay_seconds_bucket{resource="0",le="10"} 1
+httpsim_injected_delay_seconds_bucket{resource="0",le="30"} 1
+httpsim_injected_delay_seconds_bucket{resource="0",le="60"} 1
+httpsim_injected_delay_seconds_bucket{resource="0",le="+Inf"} 1
+httpsim_injected_delay_seconds_sum{resource="0"} 1
+httpsim_injected_delay_seconds_count{resource="0"} 1
+httpsim_injected_delay_seconds_bucket{resource="1",le="0.005"} 2
+httpsim_injected_delay_seconds_bucket{resource="1",le="0.01"} 2
+httpsim_injected_delay_seconds_bucket{resource="1",le="0.025"} 2
+httpsim_injected_delay_seconds_bucket{resource="1",le="0.05"} 2
+httpsim_injected_delay_seconds_bucket{resource="1",le="0.1"} 2
+httpsim_injected_delay_seconds_bucket{resource="1",le="0.25"} 2
+httpsim_injected_delay_seconds_bucket{resource="1",le="0.5"} 2
+httpsim_injected_delay_seconds_bucket{resource="1",le="1"} 2
+httpsim_injected_delay_seconds_bucket{resource="1",le="2.5"} 2
+httpsim_injected_delay_seconds_bucket{resource="1",le="5"} 2
+httpsim_injected_delay_seconds_bucket{resource="1",le="10"} 2
+httpsim_injected_delay_seconds_bucket{resource="1",le="30"} 2
+httpsim_injected_delay_seconds_bucket{resource="1",le="60"} 2
+httpsim_injected_delay_seconds_bucket{resource="1",le="+Inf"} 2
+httpsim_injected_delay_seconds_sum{resource="1"} 0
+httpsim_injected_delay_seconds_count{resource="1"} 2
+`), "httpsim_injected_delay_seconds"))
+
+	require.NoError(t, testutil.CollectAndCompare(c, strings.NewReader(`
+# HELP httpsim_drops_total Number of requests whose connection was dropped by the simulator.
+# TYPE httpsim_drops_total counter
+httpsim_drops_total{resource="0"} 0
+httpsim_drops_total{resource="1"} 0
+`), "httpsim_drops_total"))
+}
@@ -0,0 +1,22 @@
+package httpsim
+
+import (
+	"net/http"
+	"net/http/httputil"
+
+	"github.com/romshark/httpsim/config"
+)
+
+// proxy forwards r to the upstream defined by c.
+func proxy(w http.ResponseWriter, r *http.Request, c *config.Proxy) {
+	p := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(c.URL.URL())
+			pr.SetXForwarded()
+			if c.PreserveHost {
+				pr.Out.Host = pr.In.Host
+			}
+		},
+	}
+	p.ServeHTTP(w, r)
+}
@@ -0,0 +1,76 @@
+package httpsim_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim/config"
+)
+
+func TestProxy(t *testing.T) {
+	f := func(preserveHost bool, expectHost func(upstream string) string) {
+		t.Helper()
+		var upstreamReq *http.Request
+		upstream := httptest.NewServer(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				upstreamReq = r
+				w.Header().Set("X-Upstream", "staging")
+				w.WriteHeader(http.StatusAccepted)
+				_, _ = io.WriteString(w, "from staging")
+			},
+		))
+		defer upstream.Close()
+
+		u, err := config.NewURL(upstream.URL + "/api")
+		require.NoError(t, err)
+		_, s := NewSimulator(t, config.Config{
+			Resources: []config.Resource{{Effect: &config.Effect{
+				Proxy: &config.Proxy{URL: u, PreserveHost: preserveHost},
+				ResponseHeaders: &config.HeaderMutation{
+					Set: map[config.HeaderName]string{"X-Simulated": "true"},
+				},
+			}}},
+		}, func(w http.ResponseWriter, r *http.Request) {
+			t.Fatal("next handler must not be invoked")
+		})
+
+		req := NewRequest(t, http.MethodGet, "https://host.io/users?page=2", http.NoBody)
+		req.RemoteAddr = "192.0.2.1:1234"
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusAccepted, rec.Code)
+		require.Equal(t, "from staging", rec.Body.String())
+		require.Equal(t, "staging", rec.Header().Get("X-Upstream"))
+		require.Equal(t, "true", rec.Header().Get("X-Simulated"))
+
+		require.NotNil(t, upstreamReq)
+		require.Equal(t, "/api/users", upstreamReq.URL.Path)
+		require.Equal(t, "page=2", upstreamReq.URL.RawQuery)
+		require.Equal(t, expectHost(upstream.Listener.Addr().String()), upstreamReq.Host)
+		require.Equal(t, "192.0.2.1", upstreamReq.Header.Get("X-Forwarded-For"))
+		require.Equal(t, "host.io", upstreamReq.Header.Get("X-Forwarded-Host"))
+	}
+
+	f(false, func(upstream string) string { return upstream })
+	f(true, func(string) string { return "host.io" })
+}
+
+func TestProxyUnreachable(t *testing.T) {
+	u, err := config.NewURL("http://127.0.0.1:1")
+	require.NoError(t, err)
+	_, s := NewSimulator(t, config.Config{
+		Resources: []config.Resource{{Effect: &config.Effect{
+			Proxy: &config.Proxy{URL: u},
+		}}},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("next handler must not be invoked")
+	})
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody))
+	require.Equal(t, http.StatusBadGateway, rec.Code)
+}
@@ -0,0 +1,191 @@
+package httpsim
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/romshark/httpsim/config"
+)
+
+// Recording is a recorded response.
+type Recording struct {
+	Method     string      `json:"method"`
+	URL        string      `json:"url"`
+	StatusCode int         `json:"status-code"`
+	Header     http.Header `json:"header"`
+	Body       []byte      `json:"body"`
+}
+
+// RequestFingerprint returns the fingerprint identifying r.
+// If the body is part of the fingerprint then it's read
+// and replaced with an equivalent reader.
+func RequestFingerprint(r *http.Request, c *config.Fingerprint) (string, error) {
+	h := sha256.New()
+	_, _ = fmt.Fprintf(h, "%s\n%s\n%s\n", r.Method, r.URL.Path, r.URL.Query().Encode())
+	names := make([]string, len(c.Headers))
+	for i, n := range c.Headers {
+		names[i] = http.CanonicalHeaderKey(string(n))
+	}
+	slices.Sort(names)
+	for _, n := range names {
+		_, _ = fmt.Fprintf(h, "%s:%q\n", n, r.Header.Values(n))
+	}
+	if c.Body && r.Body != nil {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			return "", fmt.Errorf("reading request body: %w", err)
+		}
+		_ = r.Body.Close()
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		_, _ = h.Write(body)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// recordingPath returns the path of the recording file of fingerprint in dir.
+func recordingPath(dir, fingerprint string) string {
+	return filepath.Join(dir, fingerprint+".json")
+}
+
+// LoadRecording reads the recording of fingerprint from dir.
+// Returns an error satisfying errors.Is(err, fs.ErrNotExist)
+// if there's no such recording.
+func LoadRecording(dir, fingerprint string) (*Recording, error) {
+	b, err := os.ReadFile(recordingPath(dir, fingerprint))
+	if err != nil {
+		return nil, err
+	}
+	var rec Recording
+	if err := json.Unmarshal(b, &rec); err != nil {
+		return nil, fmt.Errorf("decoding recording: %w", err)
+	}
+	return &rec, nil
+}
+
+// SaveRecording writes rec to dir replacing the previous recording
+// of fingerprint if any.
+func SaveRecording(dir, fingerprint string, rec *Recording) error {
+	b, err := json.MarshalIndent(rec, "", "\t")
+	if err != nil {
+		return fmt.Errorf("encoding recording: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	// Write to a temporary file first to never leave partial recordings.
+	f, err := os.CreateTemp(dir, ".recording-*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+	if _, err := f.Write(b); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), recordingPath(dir, fingerprint))
+}
+
+// replay writes the recorded response of r and returns true if a recording
+// exists or it responded with 404 otherwise, unless the request
+// should pass through. Errors are reported to onError.
+func replay(
+	w http.ResponseWriter, r *http.Request, c *config.Replay, onError func(error),
+) bool {
+	fingerprint, err := RequestFingerprint(r, &c.Fingerprint)
+	if err != nil {
+		onError(err)
+		http.Error(w, "httpsim: "+err.Error(), http.StatusBadRequest)
+		return true
+	}
+	rec, err := LoadRecording(c.Dir, fingerprint)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		if c.PassThroughMissing {
+			return false
+		}
+		http.Error(w, "httpsim: no recording", http.StatusNotFound)
+		return true
+	case err != nil:
+		onError(err)
+		http.Error(w, "httpsim: "+err.Error(), http.StatusInternalServerError)
+		return true
+	}
+	for name, values := range rec.Header {
+		w.Header()[name] = slices.Clone(values)
+	}
+	w.WriteHeader(rec.StatusCode)
+	_, _ = w.Write(rec.Body)
+	return true
+}
+
+// recordWriter captures the response and saves it once finished.
+type recordWriter struct {
+	http.ResponseWriter
+	c           *config.Record
+	fingerprint string
+	rec         Recording
+	wroteHeader bool
+	err         error
+	onError     func(error)
+}
+
+func newRecordWriter(
+	w http.ResponseWriter, r *http.Request, c *config.Record, onError func(error),
+) *recordWriter {
+	rw := &recordWriter{
+		ResponseWriter: w,
+		c:              c,
+		rec:            Recording{Method: r.Method, URL: r.URL.String()},
+		onError:        onError,
+	}
+	rw.fingerprint, rw.err = RequestFingerprint(r, &c.Fingerprint)
+	return rw
+}
+
+func (w *recordWriter) WriteHeader(statusCode int) {
+	if !w.wroteHeader && statusCode >= 200 {
+		w.wroteHeader = true
+		w.rec.StatusCode = statusCode
+		w.rec.Header = w.Header().Clone()
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *recordWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	w.rec.Body = append(w.rec.Body, b...)
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap allows http.ResponseController to access the underlying writer.
+func (w *recordWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+// finish saves the recording.
+func (w *recordWriter) finish() {
+	if w.err != nil {
+		w.onError(w.err) // The request couldn't be fingerprinted.
+		return
+	}
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	// Failing to save a recording must not affect the response.
+	if err := SaveRecording(w.c.Dir, w.fingerprint, &w.rec); err != nil {
+		w.onError(err)
+	}
+}
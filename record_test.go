@@ -0,0 +1,146 @@
+package httpsim_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim"
+	"github.com/romshark/httpsim/config"
+)
+
+func TestRecordReplay(t *testing.T) {
+	dir := t.TempDir()
+	fingerprint := config.Fingerprint{
+		Headers: []config.HeaderName{"X-Tenant"}, Body: true,
+	}
+
+	calls := 0
+	_, recorder := NewSimulator(t, config.Config{
+		Resources: []config.Resource{{Effect: &config.Effect{
+			Record: &config.Record{Dir: dir, Fingerprint: fingerprint},
+		}}},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		w.Header().Set("X-Upstream", "real")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = io.WriteString(w, "created "+string(body)+" for "+r.Header.Get("X-Tenant"))
+	})
+
+	newRequest := func(tenant, body string) *http.Request {
+		req := NewRequest(t, http.MethodPost, "https://host.io/items?b=2&a=1",
+			strings.NewReader(body))
+		req.Header.Set("X-Tenant", tenant)
+		return req
+	}
+
+	rec := httptest.NewRecorder()
+	recorder.ServeHTTP(rec, newRequest("acme", "first"))
+	require.Equal(t, http.StatusCreated, rec.Code)
+	require.Equal(t, "created first for acme", rec.Body.String())
+	rec = httptest.NewRecorder()
+	recorder.ServeHTTP(rec, newRequest("globex", "second"))
+	require.Equal(t, "created second for globex", rec.Body.String())
+	require.Equal(t, 2, calls)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	_, replayer := NewSimulator(t, config.Config{
+		Resources: []config.Resource{{Effect: &config.Effect{
+			Replay: &config.Replay{Dir: dir, Fingerprint: fingerprint},
+		}}},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("next handler must not be invoked")
+	})
+
+	rec = httptest.NewRecorder()
+	replayer.ServeHTTP(rec, newRequest("acme", "first"))
+	require.Equal(t, http.StatusCreated, rec.Code)
+	require.Equal(t, "created first for acme", rec.Body.String())
+	require.Equal(t, "real", rec.Header().Get("X-Upstream"))
+
+	rec = httptest.NewRecorder()
+	replayer.ServeHTTP(rec, newRequest("globex", "second"))
+	require.Equal(t, "created second for globex", rec.Body.String())
+
+	// Query parameter order doesn't affect the fingerprint.
+	req := newRequest("acme", "first")
+	req.URL.RawQuery = "a=1&b=2"
+	rec = httptest.NewRecorder()
+	replayer.ServeHTTP(rec, req)
+	require.Equal(t, "created first for acme", rec.Body.String())
+
+	// A different body has no recording.
+	rec = httptest.NewRecorder()
+	replayer.ServeHTTP(rec, newRequest("acme", "third"))
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestReplayPassThroughMissing(t *testing.T) {
+	_, s := NewSimulator(t, config.Config{
+		Resources: []config.Resource{{Effect: &config.Effect{
+			Replay: &config.Replay{Dir: t.TempDir(), PassThroughMissing: true},
+		}}},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "live")
+	})
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "live", rec.Body.String())
+}
+
+func TestRequestFingerprint(t *testing.T) {
+	f := func(a, b *http.Request, c config.Fingerprint, expectEqual bool) {
+		t.Helper()
+		fa, err := httpsim.RequestFingerprint(a, &c)
+		require.NoError(t, err)
+		fb, err := httpsim.RequestFingerprint(b, &c)
+		require.NoError(t, err)
+		if expectEqual {
+			require.Equal(t, fa, fb)
+		} else {
+			require.NotEqual(t, fa, fb)
+		}
+	}
+	req := func(method, url, body string, header ...string) *http.Request {
+		r := NewRequest(t, method, url, strings.NewReader(body))
+		for i := 0; i < len(header); i += 2 {
+			r.Header.Add(header[i], header[i+1])
+		}
+		return r
+	}
+
+	f(req("GET", "https://a.io/x?a=1&b=2", ""),
+		req("GET", "https://b.io/x?b=2&a=1", ""), config.Fingerprint{}, true)
+	f(req("GET", "https://a.io/x", ""),
+		req("POST", "https://a.io/x", ""), config.Fingerprint{}, false)
+	f(req("GET", "https://a.io/x", ""),
+		req("GET", "https://a.io/y", ""), config.Fingerprint{}, false)
+	f(req("GET", "https://a.io/x", "", "X-A", "1"),
+		req("GET", "https://a.io/x", "", "X-A", "2"), config.Fingerprint{}, true)
+	f(req("GET", "https://a.io/x", "", "X-A", "1"),
+		req("GET", "https://a.io/x", "", "X-A", "2"),
+		config.Fingerprint{Headers: []config.HeaderName{"x-a"}}, false)
+	f(req("POST", "https://a.io/x", "1"),
+		req("POST", "https://a.io/x", "2"), config.Fingerprint{}, true)
+	f(req("POST", "https://a.io/x", "1"),
+		req("POST", "https://a.io/x", "2"), config.Fingerprint{Body: true}, false)
+
+	// The body remains readable.
+	r := req("POST", "https://a.io/x", "body")
+	_, err := httpsim.RequestFingerprint(r, &config.Fingerprint{Body: true})
+	require.NoError(t, err)
+	b, err := io.ReadAll(r.Body)
+	require.NoError(t, err)
+	require.Equal(t, "body", string(b))
+}
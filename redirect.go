@@ -0,0 +1,21 @@
+package httpsim
+
+import (
+	"maps"
+	"net/http"
+
+	"github.com/romshark/httpsim/config"
+)
+
+// redirect redirects to the location of c with the placeholders expanded.
+func redirect(
+	w http.ResponseWriter, r *http.Request,
+	c *config.Redirect, captures map[string]string,
+) {
+	vars := make(map[string]string, len(captures)+3)
+	maps.Copy(vars, captures)
+	vars["request.path"] = r.URL.Path
+	vars["request.query"] = r.URL.RawQuery
+	vars["request.uri"] = r.URL.RequestURI()
+	http.Redirect(w, r, ExpandTemplate(c.Location, vars), int(c.StatusCode))
+}
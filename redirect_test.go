@@ -0,0 +1,68 @@
+package httpsim_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim/config"
+)
+
+func TestRedirect(t *testing.T) {
+	f := func(c config.Redirect, url, expectLocation string) {
+		t.Helper()
+		_, s := NewSimulator(t, config.Config{
+			Resources: []config.Resource{{
+				PathRegexp: NewRegexp(t, `^/(?P<version>v\d)/(?P<rest>.*)$`),
+				Effect:     &config.Effect{Redirect: &c},
+			}},
+		}, func(w http.ResponseWriter, r *http.Request) {
+			t.Fatal("next handler must not be invoked")
+		})
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, NewRequest(t, http.MethodGet, url, http.NoBody))
+		require.Equal(t, int(c.StatusCode), rec.Code)
+		require.Equal(t, expectLocation, rec.Header().Get("Location"))
+	}
+
+	f(config.Redirect{
+		StatusCode: http.StatusMovedPermanently,
+		Location:   "https://new.host.io${request.uri}",
+	}, "https://host.io/v1/users?page=2", "https://new.host.io/v1/users?page=2")
+	f(config.Redirect{
+		StatusCode: http.StatusFound,
+		Location:   "/v2/${rest}?${request.query}",
+	}, "https://host.io/v1/users?page=2", "/v2/users?page=2")
+	f(config.Redirect{
+		StatusCode: http.StatusTemporaryRedirect,
+		Location:   "${request.path}/",
+	}, "https://host.io/v1/users", "/v1/users/")
+	f(config.Redirect{
+		StatusCode: http.StatusPermanentRedirect,
+		Location:   "login", // Relative to the request path.
+	}, "https://host.io/v1/users", "/v1/login")
+}
+
+func TestRedirectLoop(t *testing.T) {
+	_, s := NewSimulator(t, config.Config{
+		Resources: []config.Resource{{
+			Path: NewGlobExpression(t, "/loop"),
+			Effect: &config.Effect{Redirect: &config.Redirect{
+				StatusCode: http.StatusFound,
+				Location:   "${request.uri}",
+			}},
+		}},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("next handler must not be invoked")
+	})
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/loop")
+	if resp != nil {
+		_ = resp.Body.Close()
+	}
+	require.ErrorContains(t, err, "stopped after 10 redirects")
+}
@@ -0,0 +1,37 @@
+package httpsim
+
+import "net/http"
+
+// resetWriter aborts the response once limit bytes of the body are written.
+type resetWriter struct {
+	http.ResponseWriter
+	limit   uint64
+	written uint64
+}
+
+func (w *resetWriter) Write(b []byte) (int, error) {
+	left := w.limit - w.written
+	if uint64(len(b)) < left {
+		n, err := w.ResponseWriter.Write(b)
+		w.written += uint64(n)
+		return n, err
+	}
+	if left > 0 {
+		n, err := w.ResponseWriter.Write(b[:left])
+		w.written += uint64(n)
+		if err != nil {
+			return n, err
+		}
+	}
+	resetResponse(w.ResponseWriter)
+	return 0, nil // Unreachable.
+}
+
+// Unwrap allows http.ResponseController to access the underlying writer.
+func (w *resetWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+// resetResponse flushes everything written so far and aborts the response.
+func resetResponse(w http.ResponseWriter) {
+	_ = http.NewResponseController(w).Flush()
+	panic(http.ErrAbortHandler)
+}
@@ -0,0 +1,94 @@
+package httpsim_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim/config"
+)
+
+func NewH2Server(t *testing.T, h http.Handler) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(h)
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestResetH2(t *testing.T) {
+	body := "0123456789"
+	f := func(c config.Reset, replace bool, expectBody string, expectReused bool) {
+		t.Helper()
+		e := &config.Effect{Reset: &c}
+		if replace {
+			e.Replace = &config.Replace{StatusCode: http.StatusOK, Body: &body}
+		}
+		_, s := NewSimulator(t, config.Config{
+			Resources: []config.Resource{{Path: NewGlobExpression(t, "/reset"), Effect: e}},
+		}, func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.WriteString(w, body)
+		})
+		srv := NewH2Server(t, s)
+		client := srv.Client()
+
+		resp, err := client.Get(srv.URL + "/reset")
+		require.NoError(t, err)
+		require.Equal(t, "HTTP/2.0", resp.Proto)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		b, err := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		require.ErrorContains(t, err, "INTERNAL_ERROR")
+		require.Equal(t, expectBody, string(b))
+
+		// Check whether the connection survived the reset.
+		var reused bool
+		req := NewRequest(t, http.MethodGet, srv.URL+"/", http.NoBody)
+		req = req.WithContext(httptrace.WithClientTrace(req.Context(),
+			&httptrace.ClientTrace{GotConn: func(i httptrace.GotConnInfo) {
+				reused = i.Reused
+			}}))
+		resp, err = client.Do(req)
+		require.NoError(t, err)
+		b, err = io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		require.NoError(t, err)
+		require.Equal(t, body, string(b))
+		require.Equal(t, expectReused, reused)
+	}
+
+	f(config.Reset{}, false, "", true)
+	f(config.Reset{AfterBytes: 4}, false, "0123", true)
+	f(config.Reset{AfterBytes: 4}, true, "0123", true)
+	f(config.Reset{AfterBytes: 100}, true, body, true)
+	f(config.Reset{AfterBytes: 4, GoAway: true}, false, "0123", false)
+}
+
+func TestResetHTTP1(t *testing.T) {
+	body := "0123456789"
+	_, s := NewSimulator(t, config.Config{
+		Resources: []config.Resource{{Effect: &config.Effect{
+			Reset: &config.Reset{AfterBytes: 4},
+			Replace: &config.Replace{
+				StatusCode: http.StatusOK,
+				Body:       &body,
+			},
+		}}},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("next handler must not be invoked")
+	})
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	require.NoError(t, err)
+	b, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	require.Equal(t, "0123", string(b))
+}
@@ -0,0 +1,108 @@
+package httpsim
+
+import (
+	"container/list"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/romshark/httpsim/config"
+)
+
+// retryTracker tracks the requests of a RetryStorm effect
+// retried within its window.
+type retryTracker struct {
+	lock  sync.Mutex
+	order list.List // Of *retryEntry, most recently seen first.
+	byKey map[string]*list.Element
+}
+
+// retryEntry tracks the retries of a request.
+type retryEntry struct {
+	key     string
+	last    time.Time
+	retries uint32
+}
+
+// retries returns the number of retries of r preceding it including r,
+// or 0 if r isn't a retry of the request of effect.
+func (m *Middleware) retries(
+	r *http.Request, c *config.RetryStorm, effect *config.Effect,
+) uint32 {
+	keyHeader := "Idempotency-Key"
+	if c.KeyHeader != nil {
+		keyHeader = string(*c.KeyHeader)
+	}
+	max := int(c.MaxKeys)
+	if max == 0 {
+		max = config.DefaultMaxRetryKeys
+	}
+	v, _ := m.state.Load().retries.LoadOrStore(effect, new(retryTracker))
+	return v.(*retryTracker).seen(
+		r.Method+" "+r.URL.Path+" "+r.Header.Get(keyHeader),
+		m.now(), c.Window, max,
+	)
+}
+
+// seen records the request identified by key at now and returns the number
+// of its retries. Requests not retried within window are forgotten and
+// the least recently seen request is evicted beyond max.
+func (t *retryTracker) seen(key string, now time.Time, window time.Duration, max int) uint32 {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for e := t.order.Back(); e != nil; e = t.order.Back() {
+		if now.Sub(e.Value.(*retryEntry).last) <= window {
+			break
+		}
+		t.remove(e)
+	}
+	if e, ok := t.byKey[key]; ok {
+		entry := e.Value.(*retryEntry)
+		if entry.retries < ^uint32(0) {
+			entry.retries++
+		}
+		entry.last = now
+		t.order.MoveToFront(e)
+		return entry.retries
+	}
+	if t.byKey == nil {
+		t.byKey = map[string]*list.Element{}
+	}
+	for t.order.Len() >= max {
+		t.remove(t.order.Back())
+	}
+	t.byKey[key] = t.order.PushFront(&retryEntry{key: key, last: now})
+	return 0
+}
+
+func (t *retryTracker) remove(e *list.Element) {
+	t.order.Remove(e)
+	delete(t.byKey, e.Value.(*retryEntry).key)
+}
+
+// rejectRetry responds with 503 Service Unavailable and a Retry-After
+// doubling with every retry beyond the threshold of c.
+func rejectRetry(w http.ResponseWriter, c *config.RetryStorm, retries uint32) {
+	retryAfter := c.RetryAfter
+	if retryAfter == 0 {
+		retryAfter = time.Second
+	}
+	for i := c.Threshold + 1; i < retries; i++ {
+		if c.MaxRetryAfter != 0 && retryAfter >= c.MaxRetryAfter {
+			break
+		}
+		if retryAfter > time.Duration(1<<62) {
+			break // Prevent overflowing.
+		}
+		retryAfter *= 2
+	}
+	if c.MaxRetryAfter != 0 {
+		retryAfter = min(retryAfter, c.MaxRetryAfter)
+	}
+	// Retry-After is in whole seconds, round up.
+	seconds := (retryAfter + time.Second - 1) / time.Second
+	w.Header().Set("Retry-After", strconv.FormatInt(int64(seconds), 10))
+	http.Error(w, http.StatusText(http.StatusServiceUnavailable),
+		http.StatusServiceUnavailable)
+}
@@ -0,0 +1,133 @@
+package httpsim_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim"
+	"github.com/romshark/httpsim/config"
+	"github.com/romshark/httpsim/internal/rand"
+)
+
+func TestRetryStorm(t *testing.T) {
+	conf := config.Config{Resources: []config.Resource{{Effect: &config.Effect{
+		RetryStorm: &config.RetryStorm{
+			Window:        10 * time.Second,
+			Threshold:     2,
+			RetryAfter:    2 * time.Second,
+			MaxRetryAfter: 10 * time.Second,
+		},
+	}}}}
+	require.NoError(t, config.Validate(conf))
+	clock := new(ClockSleep)
+	rnd := rand.NewSourceChaCha8(rand.NewSeed("fedcba9876543210fedcba9876543210"))
+	s := httpsim.NewMiddleware(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		},
+	), conf, clock, rnd)
+
+	f := func(at time.Duration, method, url, key string, expectRetryAfter string) {
+		t.Helper()
+		clock.Cumulative = at
+		r := NewRequest(t, method, url, http.NoBody)
+		if key != "" {
+			r.Header.Set("Idempotency-Key", key)
+		}
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, r)
+		if expectRetryAfter == "" {
+			require.Equal(t, http.StatusOK, rec.Code)
+			require.Empty(t, rec.Header().Get("Retry-After"))
+			return
+		}
+		require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		require.Equal(t, expectRetryAfter, rec.Header().Get("Retry-After"))
+	}
+
+	url := "https://host.io/orders"
+	f(0, "POST", url, "k1", "")
+	f(time.Second, "POST", url, "k1", "")   // First retry.
+	f(2*time.Second, "POST", url, "k1", "") // Second retry.
+	f(3*time.Second, "POST", url, "k1", "2")
+	f(4*time.Second, "POST", url, "k1", "4")
+	f(5*time.Second, "POST", url, "k1", "8")
+	f(6*time.Second, "POST", url, "k1", "10") // Capped.
+
+	// Different method, path or idempotency key aren't retries.
+	f(7*time.Second, "POST", url, "k2", "")
+	f(7*time.Second, "PUT", url, "k1", "")
+	f(7*time.Second, "POST", url+"/1", "k1", "")
+
+	// Retries must follow within the window.
+	f(17*time.Second, "POST", url, "k1", "")
+	f(20*time.Second, "POST", url, "k1", "")
+}
+
+func TestRetryStormDrop(t *testing.T) {
+	header := config.HeaderName("X-Request-Key")
+	_, s := NewSimulator(t, config.Config{
+		Resources: []config.Resource{{Effect: &config.Effect{
+			RetryStorm: &config.RetryStorm{
+				Window: time.Minute, KeyHeader: &header, DropAfter: 1,
+			},
+		}}},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	get := func() (*http.Response, error) {
+		r := NewRequest(t, http.MethodGet, srv.URL, http.NoBody)
+		r.Header.Set("X-Request-Key", "abc")
+		resp, err := http.DefaultClient.Do(r)
+		if err == nil {
+			_ = resp.Body.Close()
+		}
+		return resp, err
+	}
+	resp, err := get()
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, err = get()
+	require.NoError(t, err)
+	require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	require.Equal(t, "1", resp.Header.Get("Retry-After"))
+
+	_, err = get()
+	require.ErrorIs(t, err, io.EOF)
+}
+
+func TestRetryStormMaxKeys(t *testing.T) {
+	_, s := NewSimulator(t, config.Config{
+		Resources: []config.Resource{{Effect: &config.Effect{
+			RetryStorm: &config.RetryStorm{Window: time.Minute, MaxKeys: 2},
+		}}},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	f := func(key string, expectCode int) {
+		t.Helper()
+		r := NewRequest(t, http.MethodPost, "https://host.io/orders", http.NoBody)
+		r.Header.Set("Idempotency-Key", key)
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, r)
+		require.Equal(t, expectCode, rec.Code)
+	}
+	f("k1", http.StatusOK)
+	f("k2", http.StatusOK)
+	f("k1", http.StatusServiceUnavailable)
+
+	// k2 is the least recently seen request and is forgotten.
+	f("k3", http.StatusOK)
+	f("k1", http.StatusServiceUnavailable)
+	f("k2", http.StatusOK)
+	f("k2", http.StatusServiceUnavailable)
+}
@@ -0,0 +1,114 @@
+package httpsim
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+
+	"github.com/romshark/httpsim/config"
+)
+
+// finisher is implemented by writers that need to complete
+// the response after the handler returned.
+type finisher interface{ finish() }
+
+// finish completes the response of all writers in the chain of w
+// starting with the outermost one.
+func finish(w http.ResponseWriter) {
+	for {
+		if f, ok := w.(finisher); ok {
+			f.finish()
+		}
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return
+		}
+		w = u.Unwrap()
+	}
+}
+
+// rewriteWriter rewrites the status code and headers when they're written.
+// If the body is rewritten then the entire response is buffered and
+// written once finished.
+type rewriteWriter struct {
+	http.ResponseWriter
+	c *config.Rewrite
+
+	status int // Zero until the status is written.
+	buffer *bytes.Buffer
+}
+
+func newRewriteWriter(w http.ResponseWriter, c *config.Rewrite) *rewriteWriter {
+	rw := &rewriteWriter{ResponseWriter: w, c: c}
+	if len(c.Body) > 0 {
+		rw.buffer = new(bytes.Buffer)
+	}
+	return rw
+}
+
+func (w *rewriteWriter) WriteHeader(statusCode int) {
+	if w.status != 0 || statusCode < 200 {
+		w.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+	w.status = statusCode
+	if w.buffer == nil {
+		w.writeHeader()
+	}
+}
+
+// writeHeader writes the rewritten status and headers.
+func (w *rewriteWriter) writeHeader() {
+	status := w.status
+	if w.c.StatusCode != nil {
+		status = int(*w.c.StatusCode)
+	}
+	if w.c.Headers != nil {
+		mutateHeader(w.Header(), w.c.Headers, nil)
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *rewriteWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.buffer != nil {
+		return w.buffer.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// FlushError flushes only if the response isn't buffered.
+func (w *rewriteWriter) FlushError() error {
+	if w.buffer != nil {
+		return nil
+	}
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	return http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap allows http.ResponseController to access the underlying writer.
+func (w *rewriteWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+// finish writes the buffered response with the body rewritten.
+func (w *rewriteWriter) finish() {
+	if w.buffer == nil {
+		return
+	}
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	body := w.buffer.Bytes()
+	for _, s := range w.c.Body {
+		body = s.Pattern.Regexp().ReplaceAll(body, []byte(s.Replacement))
+	}
+	w.buffer = nil
+	if w.Header().Get("Content-Length") != "" {
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	}
+	w.writeHeader()
+	_, _ = w.ResponseWriter.Write(body)
+}
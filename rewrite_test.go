@@ -0,0 +1,99 @@
+package httpsim_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim/config"
+)
+
+func TestRewrite(t *testing.T) {
+	f := func(
+		c config.Rewrite, next http.HandlerFunc,
+		expectCode int, expectHeader http.Header, expectBody string,
+	) {
+		t.Helper()
+		_, s := NewSimulator(t, config.Config{
+			Resources: []config.Resource{{Effect: &config.Effect{Rewrite: &c}}},
+		}, next)
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody))
+		require.Equal(t, expectCode, rec.Code)
+		require.Equal(t, expectHeader, rec.Header())
+		require.Equal(t, expectBody, rec.Body.String())
+	}
+	statusCode := func(c config.StatusCode) *config.StatusCode { return &c }
+	body := `{"id":42,"status":"active","tags":["a","b"]}`
+	next := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.Header().Set("ETag", `"v1"`)
+		w.WriteHeader(http.StatusOK)
+		_, _ = io.WriteString(w, body[:10])
+		require.NoError(t, http.NewResponseController(w).Flush())
+		_, _ = io.WriteString(w, body[10:])
+	}
+
+	// Status and headers only.
+	f(config.Rewrite{
+		StatusCode: statusCode(http.StatusAccepted),
+		Headers: &config.HeaderMutation{
+			Remove: []config.HeaderName{"ETag"},
+		},
+	}, next, http.StatusAccepted, http.Header{
+		"Content-Type":   {"application/json"},
+		"Content-Length": {strconv.Itoa(len(body))},
+	}, body)
+
+	// Body substitutions.
+	f(config.Rewrite{
+		Body: []config.BodySubstitution{
+			{
+				Pattern:     NewRegexp(t, `"status":"(\w+)"`),
+				Replacement: `"status":"in$1"`,
+			},
+			{
+				Pattern:     NewRegexp(t, `"id":(?P<id>\d+)`),
+				Replacement: `"id":"${id}"`,
+			},
+		},
+	}, next, http.StatusOK, http.Header{
+		"Content-Type":   {"application/json"},
+		"Content-Length": {"48"},
+		"Etag":           {`"v1"`},
+	}, `{"id":"42","status":"inactive","tags":["a","b"]}`)
+
+	// Body and status with an implicit status code.
+	f(config.Rewrite{
+		StatusCode: statusCode(http.StatusInternalServerError),
+		Body: []config.BodySubstitution{
+			{Pattern: NewRegexp(t, `ok`), Replacement: `error`},
+		},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "ok")
+	}, http.StatusInternalServerError, http.Header{}, "error")
+}
+
+func TestRewriteBuffered(t *testing.T) {
+	rewrite := &config.Rewrite{
+		Body: []config.BodySubstitution{
+			{Pattern: NewRegexp(t, `a`), Replacement: `b`},
+		},
+	}
+	_, s := NewSimulator(t, config.Config{
+		Resources: []config.Resource{{Effect: &config.Effect{Rewrite: rewrite}}},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "aa")
+		require.NoError(t, http.NewResponseController(w).Flush())
+		_, _ = io.WriteString(w, "aa")
+	})
+	rec := NewEventRecorder()
+	s.ServeHTTP(rec, NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody))
+	// Nothing is written before the handler returns.
+	require.Equal(t, []string{"header:200", "write:bbbb"}, rec.Events)
+}
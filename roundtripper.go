@@ -0,0 +1,247 @@
+package httpsim
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/romshark/httpsim/config"
+)
+
+// ErrConnectionDropped is returned by RoundTripper when a simulated effect
+// such as drop, hang or reset aborts the response before its headers
+// were written.
+var ErrConnectionDropped = errors.New("httpsim: connection dropped")
+
+// RoundTripper is an http.RoundTripper applying the matching and effects
+// of the middleware to outgoing requests, which allows simulating faults
+// of dependencies on the client side without a proxy server.
+// Effects that abort the connection fail the round trip with
+// ErrConnectionDropped, or abort reading the body with io.ErrUnexpectedEOF
+// if the response headers were already received.
+type RoundTripper struct {
+	m    *Middleware
+	next http.RoundTripper
+}
+
+var _ http.RoundTripper = new(RoundTripper)
+
+// NewRoundTripper creates a new round tripper sending requests that aren't
+// responded to by the simulator to next. Uses http.DefaultTransport if next
+// is nil. Use `DefaultSleep` for sleeper and `DefaultRand` for rnd
+// if not sure, see NewMiddleware.
+func NewRoundTripper(
+	next http.RoundTripper, c config.Config, sleeper Sleeper, rnd RandProvider,
+) *RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	t := &RoundTripper{next: next}
+	t.m = NewMiddleware(http.HandlerFunc(t.forward), c, sleeper, rnd)
+	return t
+}
+
+// Middleware returns the underlying middleware, which can be used
+// to change the configuration and reset the runtime state at runtime.
+func (t *RoundTripper) Middleware() *Middleware { return t.m }
+
+type roundTripCtxKey struct{}
+
+// RoundTrip implements http.RoundTripper.
+func (t *RoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	pr, pw := io.Pipe()
+	w := &roundTripWriter{
+		req: req, header: http.Header{}, body: pw,
+		response: make(chan *http.Response, 1),
+	}
+	r := req.Clone(req.Context())
+	if r.Host == "" {
+		r.Host = r.URL.Host
+	}
+	if r.RequestURI == "" {
+		r.RequestURI = r.URL.RequestURI()
+	}
+	r = r.WithContext(context.WithValue(r.Context(), roundTripCtxKey{}, w))
+
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if v := recover(); v != nil {
+				if v != http.ErrAbortHandler {
+					panic(v)
+				}
+				done <- w.abort(ErrConnectionDropped)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+			w.finishTrailer()
+			_ = pw.Close()
+			done <- nil
+		}()
+		t.m.ServeHTTP(w, r)
+	}()
+
+	select {
+	case resp := <-w.response:
+		resp.Body = pr
+		return resp, nil
+	case err := <-done:
+		select {
+		case resp := <-w.response:
+			resp.Body = pr
+			return resp, nil
+		default:
+			// The handler was aborted before the headers were written.
+			return nil, err
+		}
+	}
+}
+
+// forward sends r to the next round tripper and copies
+// the response to the writer of the round trip.
+func (t *RoundTripper) forward(w http.ResponseWriter, r *http.Request) {
+	rw := r.Context().Value(roundTripCtxKey{}).(*roundTripWriter)
+	out := r.Clone(r.Context())
+	out.RequestURI = "" // Must not be set on client requests.
+	resp, err := t.next.RoundTrip(out)
+	if err != nil {
+		rw.fail(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	for name, values := range resp.Header {
+		w.Header()[name] = values
+	}
+	for name := range resp.Trailer {
+		w.Header().Add("Trailer", name)
+	}
+	w.WriteHeader(resp.StatusCode)
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		rw.fail(err)
+	}
+	for name, values := range resp.Trailer {
+		w.Header()[name] = values
+	}
+}
+
+// dial simulates the connection-level effects of c for the round trip r
+// in the order a connection is established and returns the error
+// the round trip fails with, if any.
+func (m *Middleware) dial(r *http.Request, c *config.Effect, info *CtxInfo) error {
+	if c.DNS != nil {
+		if err := m.dns(r, c.DNS, info); err != nil {
+			return err
+		}
+	}
+	if c.Dial != nil {
+		if err := m.dialConn(r, c.Dial, info); err != nil {
+			return err
+		}
+	}
+	if c.TLS != nil && r.URL.Scheme == "https" {
+		if err := m.tlsHandshake(r, c.TLS, info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// roundTripWriter is the http.ResponseWriter the middleware writes
+// the response of a round trip to. The body is streamed through a pipe.
+type roundTripWriter struct {
+	req      *http.Request
+	header   http.Header
+	body     *io.PipeWriter
+	response chan *http.Response
+	trailer  http.Header
+
+	// err is the error of the next round tripper, if any.
+	err error
+
+	lock        sync.Mutex
+	wroteHeader bool
+}
+
+func (w *roundTripWriter) Header() http.Header { return w.header }
+
+func (w *roundTripWriter) WriteHeader(status int) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.wroteHeader || (status >= 100 && status < 200 &&
+		status != http.StatusSwitchingProtocols) {
+		return // Informational responses aren't exposed.
+	}
+	w.wroteHeader = true
+	header := w.header.Clone()
+	contentLength := int64(-1)
+	if v, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64); err == nil {
+		contentLength = v
+	}
+	for _, v := range header.Values("Trailer") {
+		for _, name := range strings.Split(v, ",") {
+			name = strings.TrimSpace(name)
+			if w.trailer == nil {
+				w.trailer = http.Header{}
+			}
+			w.trailer[http.CanonicalHeaderKey(name)] = nil
+		}
+	}
+	header.Del("Trailer")
+	w.response <- &http.Response{
+		Status:        strconv.Itoa(status) + " " + http.StatusText(status),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		ContentLength: contentLength,
+		Trailer:       w.trailer,
+		Request:       w.req,
+	}
+}
+
+func (w *roundTripWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	if w.req.Method == http.MethodHead {
+		return len(b), nil
+	}
+	return w.body.Write(b)
+}
+
+// Flush is a no-op since writes block until the body is read.
+func (w *roundTripWriter) Flush() {}
+
+// finishTrailer sets the values of the declared trailers.
+// Must be called before the body is closed.
+func (w *roundTripWriter) finishTrailer() {
+	for name := range w.trailer {
+		w.trailer[name] = w.header.Values(name)
+	}
+}
+
+// fail aborts the handler failing the round trip with err.
+func (w *roundTripWriter) fail(err error) {
+	w.err = err
+	panic(http.ErrAbortHandler)
+}
+
+// abort aborts the body and returns the error the round trip fails with
+// if the headers haven't been written yet.
+func (w *roundTripWriter) abort(err error) error {
+	if w.err != nil {
+		err = w.err
+	}
+	w.lock.Lock()
+	wroteHeader := w.wroteHeader
+	w.lock.Unlock()
+	if wroteHeader {
+		if w.err == nil {
+			err = io.ErrUnexpectedEOF
+		}
+		_ = w.body.CloseWithError(err)
+	}
+	return err
+}
@@ -0,0 +1,174 @@
+package httpsim_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim"
+	"github.com/romshark/httpsim/config"
+	"github.com/romshark/httpsim/internal/rand"
+)
+
+type RoundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f RoundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func NewRoundTripper(
+	t *testing.T, conf config.Config, next RoundTripperFunc,
+) (*MockSleep, *httpsim.RoundTripper) {
+	t.Helper()
+	require.NoError(t, config.Validate(conf))
+	mockSleep := new(MockSleep)
+	seed := httpsim.NewSeed("fedcba9876543210fedcba9876543210")
+	rnd := rand.NewSourceChaCha8(rand.Seed(seed))
+	return mockSleep, httpsim.NewRoundTripper(next, conf, mockSleep, rnd)
+}
+
+func upstream(t *testing.T) RoundTripperFunc {
+	return func(r *http.Request) (*http.Response, error) {
+		require.Empty(t, r.RequestURI)
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"X-Upstream": {"yes"}},
+			Body:       io.NopCloser(strings.NewReader("upstream:" + r.URL.Path)),
+			Trailer:    http.Header{"X-Checksum": {"abc"}},
+		}, nil
+	}
+}
+
+func TestRoundTripperPassThrough(t *testing.T) {
+	delay := time.Second
+	mockSleep, rt := NewRoundTripper(t, config.Config{
+		Resources: []config.Resource{{
+			Path:   NewGlobExpression(t, "/slow"),
+			Effect: &config.Effect{Delay: &config.DurRange{Min: delay, Max: delay}},
+		}},
+	}, upstream(t))
+	client := &http.Client{Transport: rt}
+
+	resp, err := client.Get("https://api.host.io/fast")
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "200 OK", resp.Status)
+	require.Equal(t, "yes", resp.Header.Get("X-Upstream"))
+	require.Equal(t, "upstream:/fast", string(body))
+	require.Equal(t, http.Header{"X-Checksum": {"abc"}}, resp.Trailer)
+	require.Zero(t, mockSleep.Cumulative)
+
+	resp, err = client.Get("https://api.host.io/slow")
+	require.NoError(t, err)
+	body, err = io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "upstream:/slow", string(body))
+	require.Equal(t, delay, mockSleep.Cumulative)
+}
+
+func TestRoundTripperReplace(t *testing.T) {
+	body := `{"error":"unavailable"}`
+	_, rt := NewRoundTripper(t, config.Config{
+		Resources: []config.Resource{{
+			Methods: []config.HTTPMethod{"POST"},
+			Path:    NewGlobExpression(t, "/orders"),
+			Effect: &config.Effect{Replace: &config.Replace{
+				StatusCode: http.StatusServiceUnavailable,
+				Headers:    map[config.HeaderName]string{"Retry-After": "5"},
+				Body:       &body,
+			}},
+		}},
+	}, func(r *http.Request) (*http.Response, error) {
+		t.Fatal("next round tripper must not be invoked")
+		return nil, nil
+	})
+	client := &http.Client{Transport: rt}
+
+	resp, err := client.Post("https://api.host.io/orders", "application/json",
+		strings.NewReader(`{}`))
+	require.NoError(t, err)
+	b, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	require.Equal(t, "5", resp.Header.Get("Retry-After"))
+	require.Equal(t, body, string(b))
+}
+
+func TestRoundTripperGRPCTrailers(t *testing.T) {
+	_, rt := NewRoundTripper(t, config.Config{
+		Resources: []config.Resource{{Effect: &config.Effect{
+			GRPCStatus: &config.GRPCStatus{
+				Code: config.GRPCCodeUnavailable, Message: "down",
+			},
+		}}},
+	}, upstream(t))
+	r := NewRequest(t, http.MethodPost, "https://api.host.io/shop.v1.Orders/Create",
+		http.NoBody)
+	r.Header.Set("Content-Type", "application/grpc")
+	resp, err := rt.RoundTrip(r)
+	require.NoError(t, err)
+	_, err = io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "14", resp.Trailer.Get("Grpc-Status"))
+	require.Equal(t, "down", resp.Trailer.Get("Grpc-Message"))
+}
+
+func TestRoundTripperAbort(t *testing.T) {
+	_, rt := NewRoundTripper(t, config.Config{
+		Resources: []config.Resource{
+			{
+				Path:   NewGlobExpression(t, "/drop"),
+				Effect: &config.Effect{Drop: &config.Drop{Rate: 1}},
+			},
+			{
+				Path:   NewGlobExpression(t, "/reset"),
+				Effect: &config.Effect{Reset: &config.Reset{AfterBytes: 4}},
+			},
+		},
+	}, upstream(t))
+	client := &http.Client{Transport: rt}
+
+	_, err := client.Get("https://api.host.io/drop")
+	require.ErrorIs(t, err, httpsim.ErrConnectionDropped)
+
+	resp, err := client.Get("https://api.host.io/reset")
+	require.NoError(t, err)
+	b, err := io.ReadAll(resp.Body)
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	require.Equal(t, "upst", string(b))
+}
+
+func TestRoundTripperNextErr(t *testing.T) {
+	errDial := errors.New("dial failed")
+	_, rt := NewRoundTripper(t, config.Config{}, func(*http.Request) (*http.Response, error) {
+		return nil, errDial
+	})
+	r := NewRequest(t, http.MethodGet, "https://api.host.io/", http.NoBody)
+	_, err := rt.RoundTrip(r)
+	require.ErrorIs(t, err, errDial)
+}
+
+func TestRoundTripperSetConfig(t *testing.T) {
+	_, rt := NewRoundTripper(t, config.Config{}, upstream(t))
+	body := "replaced"
+	rt.Middleware().SetConfig(config.Config{
+		Resources: []config.Resource{{Effect: &config.Effect{
+			Replace: &config.Replace{StatusCode: http.StatusTeapot, Body: &body},
+		}}},
+	})
+	r := NewRequest(t, http.MethodGet, "https://api.host.io/", http.NoBody)
+	resp, err := rt.RoundTrip(r)
+	require.NoError(t, err)
+	b, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusTeapot, resp.StatusCode)
+	require.Equal(t, body, string(b))
+}
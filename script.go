@@ -0,0 +1,84 @@
+package httpsim
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/romshark/httpsim/config"
+)
+
+// ScriptEngine runs the scripts of script effects, such as the Starlark
+// engine of github.com/romshark/httpsim/starlarksim.
+type ScriptEngine interface {
+	// Compile compiles s into the function computing its effect on requests.
+	// rnd is the random provider of the middleware.
+	// Compile is called once per script and config.
+	Compile(s *config.Script, rnd RandProvider) (ScriptFunc, error)
+}
+
+// ScriptFunc computes the effect of a script on r. captures are the values
+// of the named capture groups of the matched resource. ScriptFunc must be
+// safe for concurrent use and should return once r is canceled.
+type ScriptFunc func(r *http.Request, captures map[string]string) (ScriptResult, error)
+
+// ScriptResult is the effect computed by a script.
+// The zero value applies the other effects.
+type ScriptResult struct {
+	// Delay is injected before the response is written.
+	Delay time.Duration
+
+	// Replace is true if the response is replaced
+	// with StatusCode, Header and Body.
+	Replace    bool
+	StatusCode int
+	Header     http.Header
+	Body       string
+}
+
+// ErrNoScriptEngine is reported by script effects if the middleware
+// has no script engine.
+var ErrNoScriptEngine = errors.New("script: no script engine set")
+
+// SetScriptEngine makes the middleware run scripts using e.
+// A nil e removes the engine, which is the default, in which case
+// script effects fail with ErrNoScriptEngine.
+// SetScriptEngine is safe for concurrent use at runtime.
+func (m *Middleware) SetScriptEngine(e ScriptEngine) {
+	if e == nil {
+		m.scriptEngine.Store(nil)
+		return
+	}
+	m.scriptEngine.Store(&e)
+}
+
+// scriptKey identifies a script compiled by an engine.
+type scriptKey struct {
+	engine *ScriptEngine
+	script *config.Script
+}
+
+// compiledScript is a script compiled on first use.
+type compiledScript struct {
+	once sync.Once
+	run  ScriptFunc
+	err  error
+}
+
+// runScript runs the script c with r.
+func (m *Middleware) runScript(
+	r *http.Request, c *config.Script, captures map[string]string,
+) (ScriptResult, error) {
+	e := m.scriptEngine.Load()
+	if e == nil {
+		return ScriptResult{}, ErrNoScriptEngine
+	}
+	v, _ := m.state.Load().scripts.LoadOrStore(scriptKey{e, c}, new(compiledScript))
+	s := v.(*compiledScript)
+	s.once.Do(func() { s.run, s.err = (*e).Compile(c, m.rand) })
+	if s.err != nil {
+		return ScriptResult{}, s.err
+	}
+	return s.run(r, captures)
+}
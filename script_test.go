@@ -0,0 +1,154 @@
+package httpsim_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim"
+	"github.com/romshark/httpsim/config"
+)
+
+// HeaderScriptEngine compiles scripts into functions taking the effect
+// from the X-Delay, X-Status and X-Body request headers.
+type HeaderScriptEngine struct{ Compiled atomic.Int64 }
+
+func (e *HeaderScriptEngine) Compile(
+	s *config.Script, _ httpsim.RandProvider,
+) (httpsim.ScriptFunc, error) {
+	e.Compiled.Add(1)
+	if s.Source == "invalid" {
+		return nil, errors.New("invalid script")
+	}
+	return func(r *http.Request, captures map[string]string) (httpsim.ScriptResult, error) {
+		var res httpsim.ScriptResult
+		if v := r.Header.Get("X-Delay"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return res, err
+			}
+			res.Delay = d
+		}
+		if v := r.Header.Get("X-Status"); v != "" {
+			res.Replace = true
+			res.StatusCode, _ = strconv.Atoi(v)
+			res.Header = http.Header{"X-Source": {s.Source}}
+			res.Body = r.Header.Get("X-Body") + captures["id"]
+		}
+		return res, nil
+	}, nil
+}
+
+type ErrorObserver struct {
+	httpsim.NopObserver
+	Errs []error
+}
+
+func (o *ErrorObserver) OnError(_ *http.Request, err error) { o.Errs = append(o.Errs, err) }
+
+func TestScript(t *testing.T) {
+	conf := config.Config{
+		Resources: []config.Resource{{
+			PathRegexp: NewRegexp(t, `^/users/(?P<id>\d+)$`),
+			Effect:     &config.Effect{Script: &config.Script{Source: "source"}},
+		}},
+	}
+	mockSleep, s := NewSimulator(t, conf, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("passed through"))
+	})
+	engine := new(HeaderScriptEngine)
+	s.SetScriptEngine(engine)
+	serve := func(header http.Header) *httptest.ResponseRecorder {
+		t.Helper()
+		r := NewRequest(t, http.MethodGet, "https://host.io/users/42", http.NoBody)
+		r.Header = header
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, r)
+		return rec
+	}
+
+	rec := serve(http.Header{
+		"X-Delay": {"2s"}, "X-Status": {"429"}, "X-Body": {"slow down "},
+	})
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	require.Equal(t, "source", rec.Header().Get("X-Source"))
+	require.Equal(t, "slow down 42", rec.Body.String())
+	require.Equal(t, 2*time.Second, mockSleep.Cumulative)
+
+	mockSleep.Cumulative = 0
+	rec = serve(http.Header{"X-Delay": {"1500ms"}})
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "passed through", rec.Body.String())
+	require.Equal(t, 1500*time.Millisecond, mockSleep.Cumulative)
+
+	rec = serve(http.Header{})
+	require.Equal(t, "passed through", rec.Body.String())
+
+	// Scripts are compiled once per engine and config.
+	require.Equal(t, int64(1), engine.Compiled.Load())
+	other := new(HeaderScriptEngine)
+	s.SetScriptEngine(other)
+	serve(http.Header{})
+	require.Equal(t, int64(1), other.Compiled.Load())
+	s.SetConfig(conf)
+	serve(http.Header{})
+	require.Equal(t, int64(2), other.Compiled.Load())
+	require.Equal(t, int64(1), engine.Compiled.Load())
+}
+
+func TestScriptError(t *testing.T) {
+	f := func(engine httpsim.ScriptEngine, source string, header http.Header, expect string) {
+		t.Helper()
+		conf := config.Config{
+			Resources: []config.Resource{{Effect: &config.Effect{
+				Script: &config.Script{Source: source},
+			}}},
+		}
+		_, s := NewSimulator(t, conf, func(w http.ResponseWriter, r *http.Request) {
+			t.Fatal("next handler must not be invoked")
+		})
+		s.SetScriptEngine(engine)
+		o := new(ErrorObserver)
+		s.SetObserver(o)
+		r := NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody)
+		r.Header = header
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, r)
+		require.Equal(t, http.StatusInternalServerError, rec.Code)
+		require.Len(t, o.Errs, 1)
+		require.ErrorContains(t, o.Errs[0], expect)
+	}
+	f(nil, "source", http.Header{}, httpsim.ErrNoScriptEngine.Error())
+	f(new(HeaderScriptEngine), "invalid", http.Header{}, "invalid script")
+	f(new(HeaderScriptEngine), "source", http.Header{"X-Delay": {"soon"}},
+		`invalid duration "soon"`)
+}
+
+func TestScriptDryRun(t *testing.T) {
+	conf := config.Config{
+		DryRun: true,
+		Resources: []config.Resource{{Effect: &config.Effect{
+			Script: &config.Script{Source: "source"},
+		}}},
+	}
+	var info httpsim.CtxInfo
+	mockSleep, s := NewSimulator(t, conf, func(w http.ResponseWriter, r *http.Request) {
+		info = httpsim.CtxInfoValue(r.Context())
+	})
+	s.SetScriptEngine(new(HeaderScriptEngine))
+	r := NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody)
+	r.Header.Set("X-Delay", "1s")
+	r.Header.Set("X-Status", "503")
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, r)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Zero(t, mockSleep.Cumulative)
+	require.True(t, info.Replaced)
+	require.Equal(t, time.Second, info.Delay)
+}
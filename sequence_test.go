@@ -0,0 +1,63 @@
+package httpsim_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim/config"
+)
+
+func TestSequence(t *testing.T) {
+	replace := func(code config.StatusCode) *config.Effect {
+		return &config.Effect{Replace: &config.Replace{StatusCode: code}}
+	}
+	f := func(resource config.Resource, expect ...int) {
+		t.Helper()
+		_, s := NewSimulator(t, config.Config{
+			Resources: []config.Resource{resource},
+		}, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})
+		check := func() {
+			t.Helper()
+			actual := make([]int, len(expect))
+			for i := range expect {
+				rec := httptest.NewRecorder()
+				s.ServeHTTP(rec, NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody))
+				actual[i] = rec.Code
+			}
+			require.Equal(t, expect, actual)
+		}
+		check()
+		s.ResetSequences()
+		check() // Same results after reset.
+	}
+
+	// Fail twice, then pass through.
+	f(config.Resource{
+		Sequence: &config.Sequence{Steps: []config.SequenceStep{
+			{Times: 2, Effect: replace(http.StatusServiceUnavailable)},
+		}},
+	}, 503, 503, 200, 200)
+
+	// Fall back to the resource effect once exhausted.
+	f(config.Resource{
+		Effect: replace(http.StatusTeapot),
+		Sequence: &config.Sequence{Steps: []config.SequenceStep{
+			{Effect: replace(http.StatusBadGateway)},
+			{}, // Pass through.
+		}},
+	}, 502, 200, 418, 418)
+
+	// Loop.
+	f(config.Resource{
+		Effect: replace(http.StatusTeapot), // Never applied.
+		Sequence: &config.Sequence{Loop: true, Steps: []config.SequenceStep{
+			{Times: 1, Effect: replace(http.StatusBadGateway)},
+			{Times: 2},
+		}},
+	}, 502, 200, 200, 502, 200, 200, 502)
+}
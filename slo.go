@@ -0,0 +1,101 @@
+package httpsim
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/romshark/httpsim/config"
+)
+
+// SLOReport is a snapshot of the service level objective breaches of a resource.
+type SLOReport struct {
+	// ResourceIndex is the index of the resource in Config.Resources.
+	ResourceIndex int
+
+	// Requests is the total number of matched requests.
+	Requests uint64
+
+	// LatencyBreaches is the number of requests that took longer than
+	// the expected latency including the injected delay.
+	LatencyBreaches uint64
+
+	// Errors is the number of responses with a status code of 500 or higher.
+	Errors uint64
+
+	// ErrorBudgetExceeded is true when the fraction of errors
+	// is greater than the error budget.
+	ErrorBudgetExceeded bool
+}
+
+// Breached returns true if the SLO was breached at least once.
+func (r SLOReport) Breached() bool {
+	return r.LatencyBreaches > 0 || r.ErrorBudgetExceeded
+}
+
+// SLOReports returns a snapshot of the SLO breach counters of
+// all resources that declare an SLO in the current configuration.
+// The counters are reset by SetConfig.
+func (m *Middleware) SLOReports() []SLOReport {
+	s := m.state.Load()
+	var reports []SLOReport
+	for i := range s.conf.Resources {
+		slo := s.conf.Resources[i].SLO
+		if slo == nil {
+			continue
+		}
+		rs := &s.resources[i]
+		r := SLOReport{
+			ResourceIndex:   i,
+			Requests:        rs.sloRequests.Load(),
+			LatencyBreaches: rs.sloLatencyBreaches.Load(),
+			Errors:          rs.sloErrors.Load(),
+		}
+		r.ErrorBudgetExceeded = r.Requests > 0 &&
+			float64(r.Errors)/float64(r.Requests) > float64(slo.ErrorBudget)
+		reports = append(reports, r)
+	}
+	return reports
+}
+
+func (s *resourceState) observeSLO(
+	slo *config.SLO, latency time.Duration, statusCode int,
+) {
+	s.sloRequests.Add(1)
+	if slo.Latency != 0 && latency > slo.Latency {
+		s.sloLatencyBreaches.Add(1)
+	}
+	if statusCode >= 500 {
+		s.sloErrors.Add(1)
+	}
+}
+
+// statusRecorder records the status code written to the underlying writer.
+type statusRecorder struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+func (r *statusRecorder) WriteHeader(statusCode int) {
+	if r.statusCode == 0 && statusCode >= 200 {
+		r.statusCode = statusCode
+	}
+	r.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.statusCode == 0 {
+		r.statusCode = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// Unwrap allows http.ResponseController to access the underlying writer.
+func (r *statusRecorder) Unwrap() http.ResponseWriter { return r.ResponseWriter }
+
+// status returns the written status code, which defaults to 200.
+func (r *statusRecorder) status() int {
+	if r.statusCode == 0 {
+		return http.StatusOK
+	}
+	return r.statusCode
+}
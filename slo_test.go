@@ -0,0 +1,100 @@
+package httpsim_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim"
+	"github.com/romshark/httpsim/config"
+)
+
+func TestSLOReports(t *testing.T) {
+	conf := config.Config{
+		Resources: []config.Resource{
+			{
+				Path: NewGlobExpression(t, "/slow"),
+				Effect: &config.Effect{
+					Delay: &config.DurRange{Min: time.Second, Max: 2 * time.Second},
+				},
+				SLO: &config.SLO{Latency: 500 * time.Millisecond, ErrorBudget: 1},
+			},
+			{
+				Path: NewGlobExpression(t, "/flaky"),
+				SLO:  &config.SLO{ErrorBudget: 0.5},
+			},
+			{
+				Path: NewGlobExpression(t, "/no-slo"),
+			},
+		},
+	}
+	_, s := NewSimulator(t, conf, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("fail") != "" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	})
+	serve := func(url string) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, NewRequest(t, http.MethodGet, url, http.NoBody))
+	}
+
+	require.Equal(t, []httpsim.SLOReport{
+		{ResourceIndex: 0},
+		{ResourceIndex: 1},
+	}, s.SLOReports())
+
+	serve("https://host.io/slow")
+	serve("https://host.io/flaky")
+	serve("https://host.io/flaky?fail=1")
+	serve("https://host.io/no-slo")
+
+	r := s.SLOReports()
+	require.Equal(t, []httpsim.SLOReport{
+		{ResourceIndex: 0, Requests: 1, LatencyBreaches: 1},
+		{ResourceIndex: 1, Requests: 2, Errors: 1},
+	}, r)
+	require.True(t, r[0].Breached())
+	require.False(t, r[1].Breached())
+
+	serve("https://host.io/flaky?fail=1")
+	r = s.SLOReports()
+	require.Equal(t, httpsim.SLOReport{
+		ResourceIndex: 1, Requests: 3, Errors: 2, ErrorBudgetExceeded: true,
+	}, r[1])
+	require.True(t, r[1].Breached())
+
+	// SetConfig resets the counters.
+	s.SetConfig(conf)
+	require.Equal(t, []httpsim.SLOReport{
+		{ResourceIndex: 0},
+		{ResourceIndex: 1},
+	}, s.SLOReports())
+}
+
+func TestSLOReportsReplaced(t *testing.T) {
+	conf := config.Config{
+		Resources: []config.Resource{
+			{
+				Effect: &config.Effect{
+					Replace: &config.Replace{StatusCode: http.StatusBadGateway},
+				},
+				SLO: &config.SLO{ErrorBudget: 0},
+			},
+		},
+	}
+	_, s := NewSimulator(t, conf, func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("next handler must not be invoked")
+	})
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody))
+	require.Equal(t, http.StatusBadGateway, rec.Code)
+	require.Equal(t, []httpsim.SLOReport{
+		{ResourceIndex: 0, Requests: 1, Errors: 1, ErrorBudgetExceeded: true},
+	}, s.SLOReports())
+}
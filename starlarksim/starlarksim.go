@@ -0,0 +1,243 @@
+// Package starlarksim runs the scripts of script effects written
+// in Starlark. Set it as the script engine of the middleware:
+//
+//	m.SetScriptEngine(starlarksim.Engine{})
+//
+// A script must define a function simulate(request) receiving a struct
+// with the fields method, host, path, query, headers, cookies, captures
+// (dicts of strings) and body (string) and returning either None,
+// in which case the other effects apply, or a dict with the optional keys:
+//
+//   - delay: the delay to inject, either a duration string such as
+//     "150ms" or a number of seconds.
+//   - status: the status code of the replacement response.
+//   - headers: a dict of the headers of the replacement response.
+//   - body: the body of the replacement response.
+//
+// The response is replaced if any of status, headers and body are set,
+// the status code defaults to 200. The function random() returns
+// a random number in [0.0, 1.0) drawn from the random provider
+// of the middleware.
+package starlarksim
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+	"go.starlark.net/syntax"
+
+	"github.com/romshark/httpsim"
+	"github.com/romshark/httpsim/config"
+)
+
+// Builtins are the names of the functions predeclared
+// for scripts in addition to the Starlark built-ins.
+var Builtins = []string{"random"}
+
+// FileOptions are the Starlark dialect options of scripts.
+var FileOptions = &syntax.FileOptions{
+	Set: true, While: true, TopLevelControl: true, Recursion: true,
+}
+
+var ErrInvalidScript = errors.New("invalid script")
+
+// Validate returns an error wrapping ErrInvalidScript if the source of s
+// can't be compiled. Pass it to the loader to reject invalid scripts
+// when loading configs:
+//
+//	c, err := config.LoadFile(file, config.WithScriptValidator(starlarksim.Validate))
+func Validate(s *config.Script) error {
+	_, err := compile(s)
+	return err
+}
+
+// compile compiles the source of s.
+func compile(s *config.Script) (*starlark.Program, error) {
+	_, p, err := starlark.SourceProgramOptions(
+		FileOptions, "script.star", s.Source,
+		func(name string) bool { return slices.Contains(Builtins, name) },
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidScript, err)
+	}
+	return p, nil
+}
+
+// Engine is the Starlark httpsim.ScriptEngine.
+type Engine struct{}
+
+var _ httpsim.ScriptEngine = Engine{}
+
+// Compile compiles s, executes its top level and returns
+// its simulate function.
+func (Engine) Compile(s *config.Script, rnd httpsim.RandProvider) (httpsim.ScriptFunc, error) {
+	p, err := compile(s)
+	if err != nil {
+		return nil, err
+	}
+	globals, err := p.Init(newThread(s), starlark.StringDict{
+		"random": starlark.NewBuiltin("random", func(
+			_ *starlark.Thread, b *starlark.Builtin,
+			args starlark.Tuple, kwargs []starlark.Tuple,
+		) (starlark.Value, error) {
+			if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 0); err != nil {
+				return nil, err
+			}
+			return starlark.Float(rnd.Float64()), nil
+		}),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("script: %w", err)
+	}
+	globals.Freeze() // Allows calling simulate concurrently.
+	simulate, ok := globals["simulate"].(starlark.Callable)
+	if !ok {
+		return nil, errors.New("script: simulate(request) isn't defined")
+	}
+	return func(r *http.Request, captures map[string]string) (httpsim.ScriptResult, error) {
+		req, err := request(r, captures)
+		if err != nil {
+			return httpsim.ScriptResult{}, err
+		}
+		thread := newThread(s)
+		stop := context.AfterFunc(r.Context(), func() { thread.Cancel("request canceled") })
+		defer stop()
+		res, err := starlark.Call(thread, simulate, starlark.Tuple{req}, nil)
+		if err != nil {
+			return httpsim.ScriptResult{}, fmt.Errorf("script: %w", err)
+		}
+		return parseResult(res)
+	}, nil
+}
+
+func newThread(s *config.Script) *starlark.Thread {
+	t := &starlark.Thread{Name: "httpsim"}
+	maxSteps := s.MaxSteps
+	if maxSteps == 0 {
+		maxSteps = config.DefaultScriptMaxSteps
+	}
+	t.SetMaxExecutionSteps(maxSteps)
+	return t
+}
+
+// request returns the request struct passed to scripts.
+// The body of r is read and replaced with an equivalent reader.
+func request(r *http.Request, captures map[string]string) (starlark.Value, error) {
+	var body []byte
+	if r.Body != nil && r.Body != http.NoBody {
+		var err error
+		if body, err = io.ReadAll(r.Body); err != nil {
+			return nil, fmt.Errorf("reading request body: %w", err)
+		}
+		_ = r.Body.Close()
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	headers := make(map[string]string, len(r.Header))
+	for name, values := range r.Header {
+		headers[name] = strings.Join(values, ", ")
+	}
+	query := map[string]string{}
+	for name, values := range r.URL.Query() {
+		query[name] = values[0]
+	}
+	cookies := map[string]string{}
+	for _, c := range r.Cookies() {
+		cookies[c.Name] = c.Value
+	}
+	return starlarkstruct.FromStringDict(starlarkstruct.Default, starlark.StringDict{
+		"method":   starlark.String(r.Method),
+		"host":     starlark.String(httpsim.Hostname(r)),
+		"path":     starlark.String(r.URL.Path),
+		"query":    stringDict(query),
+		"headers":  stringDict(headers),
+		"cookies":  stringDict(cookies),
+		"captures": stringDict(captures),
+		"body":     starlark.String(body),
+	}), nil
+}
+
+func stringDict(m map[string]string) *starlark.Dict {
+	d := starlark.NewDict(len(m))
+	for k, v := range m {
+		_ = d.SetKey(starlark.String(k), starlark.String(v))
+	}
+	return d
+}
+
+// parseResult parses the value returned by simulate.
+func parseResult(v starlark.Value) (res httpsim.ScriptResult, err error) {
+	if v == starlark.None {
+		return res, nil
+	}
+	d, ok := v.(*starlark.Dict)
+	if !ok {
+		return res, fmt.Errorf("script: simulate returned %s, want dict or None", v.Type())
+	}
+	res.StatusCode = http.StatusOK
+	for _, item := range d.Items() {
+		key, _ := starlark.AsString(item[0])
+		switch value := item[1]; key {
+		case "delay":
+			if res.Delay, err = duration(value); err != nil {
+				return res, err
+			}
+		case "status":
+			if res.StatusCode, err = starlark.AsInt32(value); err != nil ||
+				res.StatusCode < 100 || res.StatusCode > 999 {
+				return res, fmt.Errorf("script: invalid status: %s", value)
+			}
+			res.Replace = true
+		case "headers":
+			h, ok := value.(*starlark.Dict)
+			if !ok {
+				return res, fmt.Errorf("script: headers is %s, want dict", value.Type())
+			}
+			res.Header = http.Header{}
+			for _, item := range h.Items() {
+				name, ok1 := starlark.AsString(item[0])
+				value, ok2 := starlark.AsString(item[1])
+				if !ok1 || !ok2 {
+					return res, fmt.Errorf("script: invalid header: %s: %s", item[0], item[1])
+				}
+				res.Header.Set(name, value)
+			}
+			res.Replace = true
+		case "body":
+			if res.Body, ok = starlark.AsString(value); !ok {
+				return res, fmt.Errorf("script: body is %s, want string", value.Type())
+			}
+			res.Replace = true
+		default:
+			return res, fmt.Errorf("script: unknown key: %s", item[0])
+		}
+	}
+	return res, nil
+}
+
+// duration parses v as a duration string or a number of seconds.
+func duration(v starlark.Value) (time.Duration, error) {
+	var d time.Duration
+	if s, ok := starlark.AsString(v); ok {
+		var err error
+		if d, err = time.ParseDuration(s); err != nil {
+			return 0, fmt.Errorf("script: invalid delay: %w", err)
+		}
+	} else if f, ok := starlark.AsFloat(v); ok {
+		d = time.Duration(f * float64(time.Second))
+	} else {
+		return 0, fmt.Errorf("script: delay is %s, want string or number", v.Type())
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("script: negative delay: %s", v)
+	}
+	return d, nil
+}
@@ -0,0 +1,225 @@
+package starlarksim_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim"
+	"github.com/romshark/httpsim/config"
+	"github.com/romshark/httpsim/starlarksim"
+)
+
+// MockSleep records the cumulative sleep duration.
+type MockSleep struct{ Cumulative time.Duration }
+
+func (s *MockSleep) Sleep(d time.Duration) { s.Cumulative += d }
+
+// AlternatingRand returns 0.25 and 0.75 alternately from Float64.
+type AlternatingRand struct {
+	httpsim.RandProvider
+	n int
+}
+
+func (r *AlternatingRand) Float64() float64 {
+	r.n++
+	return float64(r.n%2)*0.5 + 0.25
+}
+
+type ErrorObserver struct {
+	httpsim.NopObserver
+	Errs []error
+}
+
+func (o *ErrorObserver) OnError(_ *http.Request, err error) { o.Errs = append(o.Errs, err) }
+
+func NewSimulator(
+	t *testing.T, conf config.Config, rnd httpsim.RandProvider, handler http.HandlerFunc,
+) (*MockSleep, *httpsim.Middleware) {
+	t.Helper()
+	require.NoError(t, config.Validate(conf))
+	sleep := new(MockSleep)
+	m := httpsim.NewMiddleware(handler, conf, sleep, rnd)
+	m.SetScriptEngine(starlarksim.Engine{})
+	return sleep, m
+}
+
+func NewRequest(t *testing.T, method, url string, body string) *http.Request {
+	t.Helper()
+	r, err := http.NewRequest(method, url, strings.NewReader(body))
+	require.NoError(t, err)
+	return r
+}
+
+func TestScript(t *testing.T) {
+	path, err := config.NewRegexp(`^/users/(?P<id>\d+)$`)
+	require.NoError(t, err)
+	conf := config.Config{
+		Resources: []config.Resource{{
+			PathRegexp: path,
+			Effect: &config.Effect{Script: &config.Script{Source: `
+def simulate(request):
+    tier = request.headers.get("X-Tier", "")
+    if tier == "free":
+        return {
+            "delay": "2s",
+            "status": 429,
+            "headers": {"Retry-After": "1"},
+            "body": "slow down " + request.captures["id"],
+        }
+    if tier == "echo":
+        return {"status": 201, "body": request.method + " " + request.body}
+    if tier == "slow":
+        return {"delay": 1.5}
+    return None
+`}},
+		}},
+	}
+	mockSleep, s := NewSimulator(t, conf, nil, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("passed through"))
+	})
+	serve := func(method, tier, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		r := NewRequest(t, method, "https://host.io/users/42", body)
+		r.Header.Set("X-Tier", tier)
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, r)
+		return rec
+	}
+
+	rec := serve(http.MethodGet, "free", "")
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	require.Equal(t, "1", rec.Header().Get("Retry-After"))
+	require.Equal(t, "slow down 42", rec.Body.String())
+	require.Equal(t, 2*time.Second, mockSleep.Cumulative)
+
+	rec = serve(http.MethodPost, "echo", "payload")
+	require.Equal(t, http.StatusCreated, rec.Code)
+	require.Equal(t, "POST payload", rec.Body.String())
+
+	mockSleep.Cumulative = 0
+	rec = serve(http.MethodGet, "slow", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "passed through", rec.Body.String())
+	require.Equal(t, 1500*time.Millisecond, mockSleep.Cumulative)
+
+	rec = serve(http.MethodGet, "paid", "")
+	require.Equal(t, "passed through", rec.Body.String())
+}
+
+func TestScriptError(t *testing.T) {
+	f := func(source string, maxSteps uint64, expect string) {
+		t.Helper()
+		conf := config.Config{
+			Resources: []config.Resource{{Effect: &config.Effect{
+				Script: &config.Script{Source: source, MaxSteps: maxSteps},
+			}}},
+		}
+		_, s := NewSimulator(t, conf, nil, func(w http.ResponseWriter, r *http.Request) {
+			t.Fatal("next handler must not be invoked")
+		})
+		o := new(ErrorObserver)
+		s.SetObserver(o)
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, NewRequest(t, http.MethodGet, "https://host.io/", ""))
+		require.Equal(t, http.StatusInternalServerError, rec.Code)
+		require.Len(t, o.Errs, 1)
+		require.ErrorContains(t, o.Errs[0], expect)
+	}
+	f("def simulate(request)", 0, "invalid script")
+	f("x = 1", 0, "simulate(request) isn't defined")
+	f("fail('init')", 0, "init")
+	f("def simulate(request): return 42", 0, "want dict or None")
+	f(`def simulate(request): return {"status": 42}`, 0, "invalid status")
+	f(`def simulate(request): return {"delay": "-1s"}`, 0, "negative delay")
+	f(`def simulate(request): return {"delay": "soon"}`, 0, "invalid delay")
+	f(`def simulate(request): return {"code": 503}`, 0, "unknown key")
+	f(`def simulate(request): return {"headers": {"X": 1}}`, 0, "invalid header")
+	f(`
+def simulate(request):
+    while True:
+        pass
+`, 1000, "too many steps")
+}
+
+func TestScriptRandom(t *testing.T) {
+	conf := config.Config{
+		Resources: []config.Resource{{Effect: &config.Effect{
+			Script: &config.Script{Source: `
+def simulate(request):
+    if random() < 0.5:
+        return {"status": 503}
+`},
+		}}},
+	}
+	_, s := NewSimulator(t, conf, new(AlternatingRand),
+		func(w http.ResponseWriter, r *http.Request) {})
+	codes := map[int]int{}
+	for range 10 {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, NewRequest(t, http.MethodGet, "https://host.io/", ""))
+		codes[rec.Code]++
+	}
+	require.Equal(t, map[int]int{
+		http.StatusOK: 5, http.StatusServiceUnavailable: 5,
+	}, codes)
+}
+
+func TestScriptDryRun(t *testing.T) {
+	conf := config.Config{
+		DryRun: true,
+		Resources: []config.Resource{{Effect: &config.Effect{
+			Script: &config.Script{Source: `
+def simulate(request):
+    return {"delay": "1s", "status": 503}
+`},
+		}}},
+	}
+	var info httpsim.CtxInfo
+	mockSleep, s := NewSimulator(t, conf, nil, func(w http.ResponseWriter, r *http.Request) {
+		info = httpsim.CtxInfoValue(r.Context())
+	})
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, NewRequest(t, http.MethodGet, "https://host.io/", ""))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Zero(t, mockSleep.Cumulative)
+	require.True(t, info.Replaced)
+	require.Equal(t, time.Second, info.Delay)
+}
+
+func TestValidate(t *testing.T) {
+	f := func(source string, expect error) {
+		t.Helper()
+		err := starlarksim.Validate(&config.Script{Source: source})
+		if expect == nil {
+			require.NoError(t, err)
+			return
+		}
+		require.ErrorIs(t, err, expect)
+	}
+	f(`
+def simulate(request):
+    if random() < 0.5:
+        return {"status": 503}
+`, nil)
+	f("def simulate(request): pass", nil)
+	f("def simulate(request)", starlarksim.ErrInvalidScript)
+	f("def simulate(request): return undefined()", starlarksim.ErrInvalidScript)
+}
+
+func TestLoadWithScriptValidator(t *testing.T) {
+	_, err := config.Load(strings.NewReader(`
+resources:
+  - effect:
+      script:
+        source: "def simulate(request)"
+`), config.WithScriptValidator(starlarksim.Validate))
+	require.ErrorIs(t, err, starlarksim.ErrInvalidScript)
+	var e *config.ErrValidation
+	require.ErrorAs(t, err, &e)
+	require.Equal(t, "resources[0].effect.script", e.Path)
+}
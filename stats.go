@@ -0,0 +1,55 @@
+package httpsim
+
+import "time"
+
+// Stats is a snapshot of what the simulator did since the configuration
+// was set, which allows tests to assert that faults were exercised.
+type Stats struct {
+	// Resources holds the stats of each resource in Config.Resources
+	// at the same index.
+	Resources []ResourceStats
+
+	// Global holds the stats of the requests matched by no resource
+	// but the global effect.
+	Global ResourceStats
+}
+
+// ResourceStats are the counters of a resource.
+type ResourceStats struct {
+	// Matches is the number of matched requests.
+	Matches uint64
+
+	// Replacements is the number of responses written by the simulator
+	// instead of the next handler.
+	Replacements uint64
+
+	// Drops is the number of requests whose connection was dropped.
+	Drops uint64
+
+	// Delay is the cumulative injected delay.
+	Delay time.Duration
+}
+
+// Stats returns a snapshot of the counters of all resources.
+// The counters are reset by SetConfig.
+// Stats is safe for concurrent use at runtime.
+func (m *Middleware) Stats() Stats {
+	s := m.state.Load()
+	stats := Stats{
+		Resources: make([]ResourceStats, len(s.resources)),
+		Global:    s.globalMetrics.stats(),
+	}
+	for i := range s.resources {
+		stats.Resources[i] = s.resources[i].metrics.stats()
+	}
+	return stats
+}
+
+func (m *metrics) stats() ResourceStats {
+	return ResourceStats{
+		Matches:      m.matches.Load(),
+		Replacements: m.replacements.Load(),
+		Drops:        m.drops.Load(),
+		Delay:        time.Duration(m.delaySum.Load()),
+	}
+}
@@ -0,0 +1,54 @@
+package httpsim_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim"
+	"github.com/romshark/httpsim/config"
+)
+
+func TestStats(t *testing.T) {
+	body := "replaced"
+	_, s := NewSimulator(t, config.Config{
+		GlobalEffect: &config.Effect{
+			Delay: &config.DurRange{Min: time.Second, Max: time.Second},
+		},
+		Resources: []config.Resource{
+			{
+				Path: NewGlobExpression(t, "/replaced"),
+				Effect: &config.Effect{Replace: &config.Replace{
+					StatusCode: http.StatusServiceUnavailable, Body: &body,
+				}},
+			},
+			{Path: NewGlobExpression(t, "/unused"), Effect: &config.Effect{
+				Delay: &config.DurRange{Min: time.Second, Max: time.Second},
+			}},
+		},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	require.Equal(t, httpsim.Stats{
+		Resources: make([]httpsim.ResourceStats, 2),
+	}, s.Stats())
+
+	for range 10 {
+		for _, path := range []string{"/replaced", "/other"} {
+			r := NewRequest(t, http.MethodGet, "https://host.io"+path, http.NoBody)
+			s.ServeHTTP(httptest.NewRecorder(), r)
+		}
+	}
+
+	// The delay of the global effect counts towards the matched resource.
+	require.Equal(t, httpsim.Stats{
+		Resources: []httpsim.ResourceStats{
+			{Matches: 10, Replacements: 10, Delay: 10 * time.Second},
+			{},
+		},
+		Global: httpsim.ResourceStats{Matches: 10, Delay: 10 * time.Second},
+	}, s.Stats())
+}
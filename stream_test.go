@@ -0,0 +1,68 @@
+package httpsim_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim/config"
+)
+
+// EventRecorder records status codes, writes and flushes in order.
+type EventRecorder struct {
+	*httptest.ResponseRecorder
+	Events []string
+}
+
+func NewEventRecorder() *EventRecorder {
+	return &EventRecorder{ResponseRecorder: httptest.NewRecorder()}
+}
+
+func (r *EventRecorder) WriteHeader(statusCode int) {
+	r.Events = append(r.Events, "header:"+strconv.Itoa(statusCode))
+	r.ResponseRecorder.WriteHeader(statusCode)
+}
+
+func (r *EventRecorder) Write(b []byte) (int, error) {
+	r.Events = append(r.Events, "write:"+string(b))
+	return r.ResponseRecorder.Write(b)
+}
+
+func (r *EventRecorder) Flush() {
+	r.Events = append(r.Events, "flush")
+	r.ResponseRecorder.Flush()
+}
+
+func TestStream(t *testing.T) {
+	body := "abcdefg"
+	conf := config.Config{
+		Resources: []config.Resource{
+			{Effect: &config.Effect{
+				Replace: &config.Replace{StatusCode: http.StatusOK, Body: &body},
+				Stream: &config.Stream{
+					ChunkSize:  3,
+					ChunkDelay: config.DurRange{Min: time.Second, Max: time.Second},
+				},
+			}},
+		},
+	}
+	mockSleep, s := NewSimulator(t, conf, func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("next handler must not be invoked")
+	})
+	rec := NewEventRecorder()
+	s.ServeHTTP(rec, NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody))
+
+	require.Equal(t, 3*time.Second, mockSleep.Cumulative)
+	require.Equal(t, []string{
+		"header:200",
+		"flush",
+		"write:abc", "flush",
+		"write:def", "flush",
+		"write:g", "flush",
+	}, rec.Events)
+	require.Equal(t, body, rec.Body.String())
+}
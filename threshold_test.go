@@ -0,0 +1,52 @@
+package httpsim_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim/config"
+)
+
+func TestAfterMatches(t *testing.T) {
+	_, s := NewSimulator(t, config.Config{
+		Resources: []config.Resource{
+			{
+				Path: NewGlobExpression(t, "/a"),
+				Effect: &config.Effect{
+					AfterMatches: 3,
+					Replace: &config.Replace{
+						StatusCode: http.StatusInternalServerError,
+					},
+				},
+			},
+			{Path: NewGlobExpression(t, "/b")},
+		},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	do := func(path string) int {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, NewRequest(t, http.MethodGet, "https://host.io"+path, http.NoBody))
+		return rec.Code
+	}
+	check := func() {
+		t.Helper()
+		codes := make([]int, 5)
+		for i := range codes {
+			codes[i] = do("/a")
+		}
+		require.Equal(t, []int{200, 200, 200, 500, 500}, codes)
+	}
+
+	check()
+	require.Equal(t, 200, do("/b"))
+	require.Equal(t, 200, do("/unmatched"))
+	require.Equal(t, []uint64{5, 1}, s.MatchCounts())
+
+	s.ResetMatchCounts()
+	require.Equal(t, []uint64{0, 0}, s.MatchCounts())
+	check()
+}
@@ -0,0 +1,62 @@
+package httpsim
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// throttleInterval is the pacing granularity of throttledWriter.
+const throttleInterval = 100 * time.Millisecond
+
+// throttledWriter paces writes to the underlying writer
+// to not exceed the configured rate, flushing after each chunk.
+// Time spent between writes isn't taken into account.
+type throttledWriter struct {
+	http.ResponseWriter
+	ctx    context.Context
+	m      *Middleware
+	rate   uint64 // Bytes per second.
+	chunk  uint64 // Bytes per throttleInterval.
+	tokens uint64 // Bytes that can be written without sleeping.
+}
+
+func newThrottledWriter(
+	w http.ResponseWriter, ctx context.Context, m *Middleware,
+	bytesPerSecond, burst uint64,
+) *throttledWriter {
+	chunk := bytesPerSecond / uint64(time.Second/throttleInterval)
+	return &throttledWriter{
+		ResponseWriter: w,
+		ctx:            ctx,
+		m:              m,
+		rate:           bytesPerSecond,
+		chunk:          max(chunk, 1),
+		tokens:         burst,
+	}
+}
+
+func (w *throttledWriter) Write(b []byte) (written int, err error) {
+	for len(b) > 0 {
+		if w.tokens == 0 {
+			d := time.Duration(w.chunk * uint64(time.Second) / w.rate)
+			if err := w.m.sleep(w.ctx, d); err != nil {
+				return written, err // The client is gone.
+			}
+			w.tokens = w.chunk
+		}
+		n := min(uint64(len(b)), w.tokens)
+		nw, err := w.ResponseWriter.Write(b[:n])
+		written += nw
+		if err != nil {
+			return written, err
+		}
+		_ = http.NewResponseController(w.ResponseWriter).Flush()
+		w.tokens -= n
+		b = b[n:]
+	}
+	return written, nil
+}
+
+// Unwrap allows http.ResponseController to access the underlying writer.
+func (w *throttledWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
@@ -0,0 +1,58 @@
+package httpsim_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim/config"
+)
+
+func TestThroughputReplace(t *testing.T) {
+	body := strings.Repeat("x", 50)
+	conf := config.Config{
+		Resources: []config.Resource{
+			{Effect: &config.Effect{
+				Throughput: &config.Throughput{BytesPerSecond: 100, Burst: 10},
+				Replace: &config.Replace{
+					StatusCode: http.StatusOK,
+					Body:       &body,
+				},
+			}},
+		},
+	}
+	mockSleep, s := NewSimulator(t, conf, func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("next handler must not be invoked")
+	})
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody))
+
+	// The first 10 bytes are the burst, the remaining 40 bytes
+	// are written in 10 byte chunks every 100ms.
+	require.Equal(t, 400*time.Millisecond, mockSleep.Cumulative)
+	require.Equal(t, body, rec.Body.String())
+	require.True(t, rec.Flushed)
+}
+
+func TestThroughputDownstream(t *testing.T) {
+	conf := config.Config{
+		Resources: []config.Resource{
+			{Effect: &config.Effect{
+				Throughput: &config.Throughput{BytesPerSecond: 1},
+			}},
+		},
+	}
+	mockSleep, s := NewSimulator(t, conf, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("abc"))
+		_, _ = w.Write([]byte("de"))
+	})
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody))
+
+	require.Equal(t, 5*time.Second, mockSleep.Cumulative)
+	require.Equal(t, "abcde", rec.Body.String())
+}
@@ -0,0 +1,54 @@
+package httpsim
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+
+	"github.com/romshark/httpsim/config"
+)
+
+// tlsHandshake simulates the TLS handshake with the host of r and returns
+// the error the handshake fails with, if any, like crypto/tls does.
+func (m *Middleware) tlsHandshake(r *http.Request, c *config.TLS, info *CtxInfo) error {
+	if c.Delay != nil {
+		d := SampleDur(m.rand, c.Delay)
+		info.Delay += d
+		if err := m.sleep(r.Context(), d); err != nil {
+			return err
+		}
+	}
+	var verifyErr error
+	switch c.Failure {
+	case config.TLSFailureUnknownAuthority:
+		verifyErr = x509.UnknownAuthorityError{}
+	case config.TLSFailureExpired:
+		verifyErr = x509.CertificateInvalidError{
+			Cert: &x509.Certificate{}, Reason: x509.Expired,
+		}
+	case config.TLSFailureHostnameMismatch:
+		verifyErr = x509.HostnameError{
+			Certificate: &x509.Certificate{}, Host: Hostname(r),
+		}
+	case config.TLSFailureProtocolVersion:
+		return &net.OpError{Op: "remote error", Err: tls.AlertError(70)}
+	case config.TLSFailureHandshake:
+		return &net.OpError{Op: "remote error", Err: tls.AlertError(40)}
+	case config.TLSFailureTimeout:
+		return tlsHandshakeTimeoutError{}
+	default:
+		return nil
+	}
+	return &tls.CertificateVerificationError{Err: verifyErr}
+}
+
+// tlsHandshakeTimeoutError mirrors the error of http.Transport
+// when TLSHandshakeTimeout elapses.
+type tlsHandshakeTimeoutError struct{}
+
+var _ net.Error = tlsHandshakeTimeoutError{}
+
+func (tlsHandshakeTimeoutError) Timeout() bool   { return true }
+func (tlsHandshakeTimeoutError) Temporary() bool { return true }
+func (tlsHandshakeTimeoutError) Error() string   { return "net/http: TLS handshake timeout" }
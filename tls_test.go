@@ -0,0 +1,90 @@
+package httpsim_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim/config"
+)
+
+func TestTLS(t *testing.T) {
+	delay := time.Second
+	resource := func(host string, failure config.TLSFailure) config.Resource {
+		return config.Resource{
+			Host: NewGlobExpression(t, host),
+			Effect: &config.Effect{TLS: &config.TLS{
+				Delay:   &config.DurRange{Min: delay, Max: delay},
+				Failure: failure,
+			}},
+		}
+	}
+	mockSleep, rt := NewRoundTripper(t, config.Config{
+		Resources: []config.Resource{
+			resource("slow.host.io", ""),
+			resource("ca.host.io", config.TLSFailureUnknownAuthority),
+			resource("expired.host.io", config.TLSFailureExpired),
+			resource("mismatch.host.io", config.TLSFailureHostnameMismatch),
+			resource("version.host.io", config.TLSFailureProtocolVersion),
+			resource("handshake.host.io", config.TLSFailureHandshake),
+			resource("timeout.host.io", config.TLSFailureTimeout),
+		},
+	}, upstream(t))
+	client := &http.Client{Transport: rt}
+
+	resp, err := client.Get("https://slow.host.io/a")
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "upstream:/a", string(body))
+	require.Equal(t, delay, mockSleep.Cumulative)
+
+	// Plain HTTP requests don't perform handshakes.
+	mockSleep.Cumulative = 0
+	resp, err = client.Get("http://ca.host.io/a")
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Zero(t, mockSleep.Cumulative)
+
+	f := func(url, expectMsg string) error {
+		t.Helper()
+		_, err := client.Get(url)
+		require.ErrorContains(t, err, expectMsg)
+		return err
+	}
+
+	err = f("https://ca.host.io/",
+		"tls: failed to verify certificate: x509: certificate signed by unknown authority")
+	var verifyErr *tls.CertificateVerificationError
+	require.True(t, errors.As(err, &verifyErr))
+	var authorityErr x509.UnknownAuthorityError
+	require.True(t, errors.As(err, &authorityErr))
+
+	err = f("https://expired.host.io/", "x509: certificate has expired")
+	var invalidErr x509.CertificateInvalidError
+	require.True(t, errors.As(err, &invalidErr))
+	require.Equal(t, x509.Expired, invalidErr.Reason)
+
+	err = f("https://mismatch.host.io/", "mismatch.host.io")
+	var hostnameErr x509.HostnameError
+	require.True(t, errors.As(err, &hostnameErr))
+
+	err = f("https://version.host.io/", "remote error: tls: protocol version not supported")
+	var alert tls.AlertError
+	require.True(t, errors.As(err, &alert))
+	require.Equal(t, tls.AlertError(70), alert)
+
+	f("https://handshake.host.io/", "remote error: tls: handshake failure")
+
+	err = f("https://timeout.host.io/", "net/http: TLS handshake timeout")
+	var netErr net.Error
+	require.True(t, errors.As(err, &netErr))
+	require.True(t, netErr.Timeout())
+}
@@ -0,0 +1,567 @@
+package httpsim
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/romshark/httpsim/config"
+)
+
+// ToxiproxyAPI implements the HTTP API of Toxiproxy
+// (https://github.com/Shopify/toxiproxy) on top of a middleware,
+// such that existing Toxiproxy clients and tooling can inject faults.
+// A Toxiproxy proxy represents the resource of the same name and its
+// toxics are appended to the effects of the resource. The listen and
+// upstream addresses of proxies are kept but have no meaning.
+//
+// Supported toxics and their httpsim equivalents:
+//
+//   - latency: delay (upstream) or delay-headers (downstream)
+//     of latency±jitter milliseconds.
+//   - bandwidth: throughput of rate KB/s (downstream only).
+//   - timeout: hang for timeout milliseconds, 0 hangs until removed.
+//   - reset_peer: delay of timeout milliseconds followed by a drop.
+//   - limit_data: reset after bytes (downstream only).
+//
+// Toxicity is applied as the effect's probability.
+// Any change sets the config of the middleware to the base config
+// with all toxics applied, overwriting configs set by other means.
+type ToxiproxyAPI struct {
+	m    *Middleware
+	base config.Config
+	mux  *http.ServeMux
+
+	lock    sync.Mutex
+	proxies map[string]*toxiproxyProxy
+}
+
+var _ http.Handler = new(ToxiproxyAPI)
+
+// NewToxiproxyAPI creates a Toxiproxy API for m applying toxics to base,
+// which should be the config m was created with.
+func NewToxiproxyAPI(m *Middleware, base config.Config) *ToxiproxyAPI {
+	a := &ToxiproxyAPI{
+		m: m, base: base, mux: http.NewServeMux(),
+		proxies: map[string]*toxiproxyProxy{},
+	}
+	a.mux.HandleFunc("GET /version", a.handleVersion)
+	a.mux.HandleFunc("POST /reset", a.handleReset)
+	a.mux.HandleFunc("POST /populate", a.handlePopulate)
+	a.mux.HandleFunc("GET /proxies", a.handleListProxies)
+	a.mux.HandleFunc("POST /proxies", a.handleCreateProxy)
+	a.mux.HandleFunc("GET /proxies/{proxy}", a.handleGetProxy)
+	a.mux.HandleFunc("POST /proxies/{proxy}", a.handleUpdateProxy)
+	a.mux.HandleFunc("DELETE /proxies/{proxy}", a.handleDeleteProxy)
+	a.mux.HandleFunc("GET /proxies/{proxy}/toxics", a.handleListToxics)
+	a.mux.HandleFunc("POST /proxies/{proxy}/toxics", a.handleCreateToxic)
+	a.mux.HandleFunc("GET /proxies/{proxy}/toxics/{toxic}", a.handleGetToxic)
+	a.mux.HandleFunc("POST /proxies/{proxy}/toxics/{toxic}", a.handleUpdateToxic)
+	a.mux.HandleFunc("DELETE /proxies/{proxy}/toxics/{toxic}", a.handleDeleteToxic)
+	return a
+}
+
+func (a *ToxiproxyAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.mux.ServeHTTP(w, r)
+}
+
+type toxiproxyProxy struct {
+	Name     string           `json:"name"`
+	Listen   string           `json:"listen"`
+	Upstream string           `json:"upstream"`
+	Enabled  bool             `json:"enabled"`
+	Toxics   []toxiproxyToxic `json:"toxics"`
+}
+
+type toxiproxyToxic struct {
+	Name       string             `json:"name"`
+	Type       string             `json:"type"`
+	Stream     string             `json:"stream"`
+	Toxicity   float64            `json:"toxicity"`
+	Attributes map[string]float64 `json:"attributes"`
+}
+
+// toxiproxyError is an error responded with its status code.
+type toxiproxyError struct {
+	status int
+	msg    string
+}
+
+func (e *toxiproxyError) Error() string { return e.msg }
+
+func errToxiproxy(status int, format string, v ...any) error {
+	return &toxiproxyError{status: status, msg: fmt.Sprintf(format, v...)}
+}
+
+func respondToxiproxy(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func respondToxiproxyErr(w http.ResponseWriter, err error) {
+	status := http.StatusBadRequest
+	var e *toxiproxyError
+	if errors.As(err, &e) {
+		status = e.status
+	}
+	respondToxiproxy(w, status, map[string]any{
+		"error": err.Error(), "status": status,
+	})
+}
+
+// update applies fn to a copy of the proxies and sets the config
+// of the middleware, the proxies are left unchanged if either fails.
+func (a *ToxiproxyAPI) update(fn func(proxies map[string]*toxiproxyProxy) error) error {
+	proxies := make(map[string]*toxiproxyProxy, len(a.proxies))
+	for name, p := range a.proxies {
+		c := *p
+		c.Toxics = slices.Clone(p.Toxics)
+		proxies[name] = &c
+	}
+	if err := fn(proxies); err != nil {
+		return err
+	}
+	c, err := a.config(proxies)
+	if err != nil {
+		return err
+	}
+	a.proxies = proxies
+	a.m.SetConfig(c)
+	return nil
+}
+
+// config returns the base config with the toxics of all enabled proxies
+// appended to the effects of their resources.
+func (a *ToxiproxyAPI) config(proxies map[string]*toxiproxyProxy) (config.Config, error) {
+	c := a.base
+	c.Scenario = slices.Clone(c.Scenario)
+	resolved := a.base.WithScenario()
+	names := make([]string, 0, len(proxies))
+	for name := range proxies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		p := proxies[name]
+		if !p.Enabled || len(p.Toxics) < 1 {
+			continue
+		}
+		i := slices.IndexFunc(resolved.Resources, func(r config.Resource) bool {
+			return r.Name == name
+		})
+		r := &resolved.Resources[i]
+		var effects []config.Effect
+		for _, e := range r.Chain() {
+			effects = append(effects, *e)
+		}
+		for _, t := range p.Toxics {
+			e, err := t.effect()
+			if err != nil {
+				return c, err
+			}
+			effects = append(effects, *e)
+		}
+		c.Scenario = append(c.Scenario, config.Attachment{
+			Resource: name, Effects: effects, Sequence: r.Sequence,
+		})
+	}
+	if err := config.Validate(c); err != nil {
+		return c, err
+	}
+	return c, nil
+}
+
+// effect returns the httpsim equivalent of t.
+func (t *toxiproxyToxic) effect() (*config.Effect, error) {
+	ms := func(attribute string) time.Duration {
+		return time.Duration(t.Attributes[attribute] * float64(time.Millisecond))
+	}
+	e := &config.Effect{}
+	if t.Toxicity < 1 {
+		p := config.Probability(t.Toxicity)
+		e.Probability = &p
+	}
+	downstreamOnly := func() error {
+		if t.Stream != "downstream" {
+			return errToxiproxy(http.StatusBadRequest,
+				"toxic %s is only supported downstream", t.Type)
+		}
+		return nil
+	}
+	switch t.Type {
+	case "latency":
+		latency, jitter := ms("latency"), ms("jitter")
+		d := &config.DurRange{Min: max(latency-jitter, 0), Max: latency + jitter}
+		if t.Stream == "upstream" {
+			e.Delay = d
+		} else {
+			e.DelayHeaders = d
+		}
+	case "bandwidth":
+		if err := downstreamOnly(); err != nil {
+			return nil, err
+		}
+		e.Throughput = &config.Throughput{
+			BytesPerSecond: uint64(t.Attributes["rate"] * 1000),
+		}
+	case "timeout":
+		e.Hang = &config.Hang{Max: ms("timeout")}
+	case "reset_peer":
+		if d := ms("timeout"); d > 0 {
+			e.Delay = &config.DurRange{Min: d, Max: d}
+		}
+		e.Drop = &config.Drop{Rate: 1, Mode: config.DropModeClose}
+	case "limit_data":
+		if err := downstreamOnly(); err != nil {
+			return nil, err
+		}
+		e.Reset = &config.Reset{AfterBytes: uint64(t.Attributes["bytes"])}
+	default:
+		return nil, errToxiproxy(http.StatusBadRequest,
+			"unsupported toxic type: %q", t.Type)
+	}
+	return e, nil
+}
+
+func (a *ToxiproxyAPI) handleVersion(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	_, _ = w.Write([]byte("httpsim"))
+}
+
+func (a *ToxiproxyAPI) handleReset(w http.ResponseWriter, r *http.Request) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	err := a.update(func(proxies map[string]*toxiproxyProxy) error {
+		for _, p := range proxies {
+			p.Enabled, p.Toxics = true, nil
+		}
+		return nil
+	})
+	if err != nil {
+		respondToxiproxyErr(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// toxiproxyProxyInput is a proxy to create or update.
+type toxiproxyProxyInput struct {
+	Name     string `json:"name"`
+	Listen   string `json:"listen"`
+	Upstream string `json:"upstream"`
+	Enabled  *bool  `json:"enabled"`
+}
+
+// create adds the proxy to proxies replacing an existing one if replace is true.
+func (a *ToxiproxyAPI) create(
+	proxies map[string]*toxiproxyProxy, in toxiproxyProxyInput, replace bool,
+) (*toxiproxyProxy, error) {
+	if in.Name == "" {
+		return nil, errToxiproxy(http.StatusBadRequest, "missing required field: name")
+	}
+	if _, ok := proxies[in.Name]; ok && !replace {
+		return nil, errToxiproxy(http.StatusConflict, "proxy already exists")
+	}
+	if !slices.ContainsFunc(a.base.Resources, func(r config.Resource) bool {
+		return r.Name == in.Name
+	}) {
+		return nil, errToxiproxy(http.StatusNotFound,
+			"no resource named %q to proxy", in.Name)
+	}
+	p := &toxiproxyProxy{
+		Name: in.Name, Listen: in.Listen, Upstream: in.Upstream,
+		Enabled: in.Enabled == nil || *in.Enabled, Toxics: []toxiproxyToxic{},
+	}
+	proxies[in.Name] = p
+	return p, nil
+}
+
+func (a *ToxiproxyAPI) handleCreateProxy(w http.ResponseWriter, r *http.Request) {
+	var in toxiproxyProxyInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		respondToxiproxyErr(w, err)
+		return
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	var p *toxiproxyProxy
+	err := a.update(func(proxies map[string]*toxiproxyProxy) (err error) {
+		p, err = a.create(proxies, in, false)
+		return err
+	})
+	if err != nil {
+		respondToxiproxyErr(w, err)
+		return
+	}
+	respondToxiproxy(w, http.StatusCreated, p)
+}
+
+func (a *ToxiproxyAPI) handlePopulate(w http.ResponseWriter, r *http.Request) {
+	var in []toxiproxyProxyInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		respondToxiproxyErr(w, err)
+		return
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	created := make([]*toxiproxyProxy, 0, len(in))
+	err := a.update(func(proxies map[string]*toxiproxyProxy) error {
+		for _, in := range in {
+			p, err := a.create(proxies, in, true)
+			if err != nil {
+				return err
+			}
+			created = append(created, p)
+		}
+		return nil
+	})
+	if err != nil {
+		respondToxiproxyErr(w, err)
+		return
+	}
+	respondToxiproxy(w, http.StatusCreated, map[string]any{"proxies": created})
+}
+
+func (a *ToxiproxyAPI) handleListProxies(w http.ResponseWriter, r *http.Request) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	respondToxiproxy(w, http.StatusOK, a.proxies)
+}
+
+// toxiproxyFind returns the proxy named by the request path.
+func toxiproxyFind(proxies map[string]*toxiproxyProxy, r *http.Request) (*toxiproxyProxy, error) {
+	p, ok := proxies[r.PathValue("proxy")]
+	if !ok {
+		return nil, errToxiproxy(http.StatusNotFound, "proxy not found")
+	}
+	return p, nil
+}
+
+func (a *ToxiproxyAPI) handleGetProxy(w http.ResponseWriter, r *http.Request) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	p, err := toxiproxyFind(a.proxies, r)
+	if err != nil {
+		respondToxiproxyErr(w, err)
+		return
+	}
+	respondToxiproxy(w, http.StatusOK, p)
+}
+
+func (a *ToxiproxyAPI) handleUpdateProxy(w http.ResponseWriter, r *http.Request) {
+	var in toxiproxyProxyInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		respondToxiproxyErr(w, err)
+		return
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	var p *toxiproxyProxy
+	err := a.update(func(proxies map[string]*toxiproxyProxy) (err error) {
+		if p, err = toxiproxyFind(proxies, r); err != nil {
+			return err
+		}
+		if in.Listen != "" {
+			p.Listen = in.Listen
+		}
+		if in.Upstream != "" {
+			p.Upstream = in.Upstream
+		}
+		if in.Enabled != nil {
+			p.Enabled = *in.Enabled
+		}
+		return nil
+	})
+	if err != nil {
+		respondToxiproxyErr(w, err)
+		return
+	}
+	respondToxiproxy(w, http.StatusOK, p)
+}
+
+func (a *ToxiproxyAPI) handleDeleteProxy(w http.ResponseWriter, r *http.Request) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	err := a.update(func(proxies map[string]*toxiproxyProxy) error {
+		p, err := toxiproxyFind(proxies, r)
+		if err != nil {
+			return err
+		}
+		delete(proxies, p.Name)
+		return nil
+	})
+	if err != nil {
+		respondToxiproxyErr(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *ToxiproxyAPI) handleListToxics(w http.ResponseWriter, r *http.Request) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	p, err := toxiproxyFind(a.proxies, r)
+	if err != nil {
+		respondToxiproxyErr(w, err)
+		return
+	}
+	respondToxiproxy(w, http.StatusOK, p.Toxics)
+}
+
+// toxiproxyToxicInput is a toxic to create or update.
+type toxiproxyToxicInput struct {
+	Name       string             `json:"name"`
+	Type       string             `json:"type"`
+	Stream     string             `json:"stream"`
+	Toxicity   *float64           `json:"toxicity"`
+	Attributes map[string]float64 `json:"attributes"`
+}
+
+// toxiproxyFindToxic returns the index of the toxic named by the request path.
+func toxiproxyFindToxic(p *toxiproxyProxy, r *http.Request) (int, error) {
+	i := slices.IndexFunc(p.Toxics, func(t toxiproxyToxic) bool {
+		return t.Name == r.PathValue("toxic")
+	})
+	if i < 0 {
+		return i, errToxiproxy(http.StatusNotFound, "toxic not found")
+	}
+	return i, nil
+}
+
+func (a *ToxiproxyAPI) handleCreateToxic(w http.ResponseWriter, r *http.Request) {
+	var in toxiproxyToxicInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		respondToxiproxyErr(w, err)
+		return
+	}
+	t := toxiproxyToxic{
+		Name: in.Name, Type: in.Type, Stream: in.Stream,
+		Toxicity: 1, Attributes: in.Attributes,
+	}
+	if t.Stream == "" {
+		t.Stream = "downstream"
+	}
+	if t.Stream != "downstream" && t.Stream != "upstream" {
+		respondToxiproxyErr(w, errToxiproxy(http.StatusBadRequest,
+			"stream was invalid, can be either upstream or downstream"))
+		return
+	}
+	if t.Name == "" {
+		t.Name = t.Type + "_" + t.Stream
+	}
+	if in.Toxicity != nil {
+		t.Toxicity = *in.Toxicity
+	}
+	if t.Attributes == nil {
+		t.Attributes = map[string]float64{}
+	}
+	if _, err := t.effect(); err != nil {
+		respondToxiproxyErr(w, err)
+		return
+	}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	err := a.update(func(proxies map[string]*toxiproxyProxy) error {
+		p, err := toxiproxyFind(proxies, r)
+		if err != nil {
+			return err
+		}
+		if slices.ContainsFunc(p.Toxics, func(e toxiproxyToxic) bool {
+			return e.Name == t.Name
+		}) {
+			return errToxiproxy(http.StatusConflict, "toxic already exists")
+		}
+		p.Toxics = append(p.Toxics, t)
+		return nil
+	})
+	if err != nil {
+		respondToxiproxyErr(w, err)
+		return
+	}
+	respondToxiproxy(w, http.StatusOK, t)
+}
+
+func (a *ToxiproxyAPI) handleGetToxic(w http.ResponseWriter, r *http.Request) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	p, err := toxiproxyFind(a.proxies, r)
+	if err != nil {
+		respondToxiproxyErr(w, err)
+		return
+	}
+	i, err := toxiproxyFindToxic(p, r)
+	if err != nil {
+		respondToxiproxyErr(w, err)
+		return
+	}
+	respondToxiproxy(w, http.StatusOK, p.Toxics[i])
+}
+
+func (a *ToxiproxyAPI) handleUpdateToxic(w http.ResponseWriter, r *http.Request) {
+	var in toxiproxyToxicInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		respondToxiproxyErr(w, err)
+		return
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	var t toxiproxyToxic
+	err := a.update(func(proxies map[string]*toxiproxyProxy) error {
+		p, err := toxiproxyFind(proxies, r)
+		if err != nil {
+			return err
+		}
+		i, err := toxiproxyFindToxic(p, r)
+		if err != nil {
+			return err
+		}
+		t = p.Toxics[i]
+		if in.Toxicity != nil {
+			t.Toxicity = *in.Toxicity
+		}
+		attributes := make(map[string]float64, len(t.Attributes))
+		for k, v := range t.Attributes {
+			attributes[k] = v
+		}
+		for k, v := range in.Attributes {
+			attributes[k] = v
+		}
+		t.Attributes = attributes
+		p.Toxics[i] = t
+		return nil
+	})
+	if err != nil {
+		respondToxiproxyErr(w, err)
+		return
+	}
+	respondToxiproxy(w, http.StatusOK, t)
+}
+
+func (a *ToxiproxyAPI) handleDeleteToxic(w http.ResponseWriter, r *http.Request) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	err := a.update(func(proxies map[string]*toxiproxyProxy) error {
+		p, err := toxiproxyFind(proxies, r)
+		if err != nil {
+			return err
+		}
+		i, err := toxiproxyFindToxic(p, r)
+		if err != nil {
+			return err
+		}
+		p.Toxics = slices.Delete(p.Toxics, i, i+1)
+		return nil
+	})
+	if err != nil {
+		respondToxiproxyErr(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,161 @@
+package httpsim_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/romshark/httpsim"
+	"github.com/romshark/httpsim/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToxiproxyAPI(t *testing.T) {
+	conf := config.Config{
+		Resources: []config.Resource{{
+			Name: "checkout",
+			Path: NewGlobExpression(t, "/checkout"),
+		}},
+	}
+	mockSleep, s := NewSimulator(t, conf, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	api := httptest.NewServer(httpsim.NewToxiproxyAPI(s, conf))
+	t.Cleanup(api.Close)
+
+	call := func(t *testing.T, method, path, body string, expectStatus int) string {
+		t.Helper()
+		req := NewRequest(t, method, api.URL+path, strings.NewReader(body))
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var b strings.Builder
+		_, err = io.Copy(&b, resp.Body)
+		require.NoError(t, err)
+		require.Equal(t, expectStatus, resp.StatusCode, b.String())
+		return b.String()
+	}
+	request := func(t *testing.T, expectStatus int) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, NewRequest(t, http.MethodGet, "https://host.io/checkout", http.NoBody))
+		require.Equal(t, expectStatus, rec.Code)
+	}
+
+	call(t, http.MethodPost, "/proxies",
+		`{"name":"unknown","listen":"localhost:0","upstream":"x:1"}`, http.StatusNotFound)
+	call(t, http.MethodPost, "/proxies",
+		`{"name":"checkout","listen":"localhost:0","upstream":"x:1"}`, http.StatusCreated)
+	call(t, http.MethodPost, "/proxies", `{"name":"checkout"}`, http.StatusConflict)
+
+	var toxic map[string]any
+	require.NoError(t, json.Unmarshal([]byte(call(t, http.MethodPost,
+		"/proxies/checkout/toxics",
+		`{"type":"latency","attributes":{"latency":1000}}`, http.StatusOK,
+	)), &toxic))
+	require.Equal(t, "latency_downstream", toxic["name"])
+	require.Equal(t, "downstream", toxic["stream"])
+	require.Equal(t, 1.0, toxic["toxicity"])
+	call(t, http.MethodPost, "/proxies/checkout/toxics",
+		`{"type":"latency"}`, http.StatusConflict)
+	call(t, http.MethodPost, "/proxies/checkout/toxics",
+		`{"type":"slicer"}`, http.StatusBadRequest)
+	call(t, http.MethodPost, "/proxies/checkout/toxics",
+		`{"type":"bandwidth","stream":"upstream"}`, http.StatusBadRequest)
+
+	request(t, http.StatusOK)
+	require.Equal(t, time.Second, mockSleep.Cumulative)
+
+	call(t, http.MethodPost, "/proxies/checkout/toxics/latency_downstream",
+		`{"attributes":{"latency":2000}}`, http.StatusOK)
+	mockSleep.Cumulative = 0
+	request(t, http.StatusOK)
+	require.Equal(t, 2*time.Second, mockSleep.Cumulative)
+
+	// Disabled proxies have no toxics applied.
+	call(t, http.MethodPost, "/proxies/checkout", `{"enabled":false}`, http.StatusOK)
+	mockSleep.Cumulative = 0
+	request(t, http.StatusOK)
+	require.Zero(t, mockSleep.Cumulative)
+	call(t, http.MethodPost, "/proxies/checkout", `{"enabled":true}`, http.StatusOK)
+
+	var proxies map[string]struct {
+		Enabled bool             `json:"enabled"`
+		Toxics  []map[string]any `json:"toxics"`
+	}
+	require.NoError(t, json.Unmarshal(
+		[]byte(call(t, http.MethodGet, "/proxies", "", http.StatusOK)), &proxies,
+	))
+	require.Len(t, proxies, 1)
+	require.True(t, proxies["checkout"].Enabled)
+	require.Len(t, proxies["checkout"].Toxics, 1)
+
+	call(t, http.MethodDelete, "/proxies/checkout/toxics/latency_downstream",
+		"", http.StatusNoContent)
+	call(t, http.MethodDelete, "/proxies/checkout/toxics/latency_downstream",
+		"", http.StatusNotFound)
+	mockSleep.Cumulative = 0
+	request(t, http.StatusOK)
+	require.Zero(t, mockSleep.Cumulative)
+
+	// reset_peer drops the connection.
+	call(t, http.MethodPost, "/proxies/checkout/toxics",
+		`{"type":"reset_peer"}`, http.StatusOK)
+	require.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		s.ServeHTTP(httptest.NewRecorder(),
+			NewRequest(t, http.MethodGet, "https://host.io/checkout", http.NoBody))
+	})
+
+	call(t, http.MethodPost, "/reset", "", http.StatusNoContent)
+	request(t, http.StatusOK)
+
+	call(t, http.MethodDelete, "/proxies/checkout", "", http.StatusNoContent)
+	call(t, http.MethodGet, "/proxies/checkout", "", http.StatusNotFound)
+}
+
+func TestToxiproxyAPIPopulate(t *testing.T) {
+	conf := config.Config{
+		Resources: []config.Resource{
+			{Name: "a", Path: NewGlobExpression(t, "/a")},
+			{Name: "b", Path: NewGlobExpression(t, "/b")},
+		},
+	}
+	_, s := NewSimulator(t, conf, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	api := httpsim.NewToxiproxyAPI(s, conf)
+
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, NewRequest(t, http.MethodPost, "/populate",
+		strings.NewReader(`[{"name":"a"},{"name":"b","enabled":false}]`)))
+	require.Equal(t, http.StatusCreated, rec.Code)
+	var resp struct {
+		Proxies []struct {
+			Name    string `json:"name"`
+			Enabled bool   `json:"enabled"`
+		} `json:"proxies"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Len(t, resp.Proxies, 2)
+	require.Equal(t, "a", resp.Proxies[0].Name)
+	require.True(t, resp.Proxies[0].Enabled)
+	require.Equal(t, "b", resp.Proxies[1].Name)
+	require.False(t, resp.Proxies[1].Enabled)
+
+	// Populating with an unknown resource changes nothing.
+	rec = httptest.NewRecorder()
+	api.ServeHTTP(rec, NewRequest(t, http.MethodPost, "/populate",
+		strings.NewReader(`[{"name":"c"}]`)))
+	require.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = httptest.NewRecorder()
+	api.ServeHTTP(rec, NewRequest(t, http.MethodGet, "/proxies", http.NoBody))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var proxies map[string]any
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &proxies))
+	require.Len(t, proxies, 2)
+}
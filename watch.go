@@ -0,0 +1,213 @@
+package httpsim
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/romshark/httpsim/config"
+)
+
+// DefaultWatchInterval is used by WatchConfigURL if no interval is specified.
+const DefaultWatchInterval = time.Second
+
+// watchDelay is how long WatchConfigFile waits after a change before
+// reloading such that files written in multiple steps are likely complete.
+// Changes made during the delay are loaded together.
+const watchDelay = 50 * time.Millisecond
+
+// WatchConfigFile watches file using fsnotify and loads and sets it
+// as the config of m whenever it changes. The directory of file is watched
+// instead of file itself such that atomic saves, which rename a temporary
+// file over file, are noticed too. If the changed file fails to load
+// the previous config is kept and onError, if not nil, is invoked,
+// as it is for errors reported by the watcher.
+// Files included by file aren't watched. opts are passed to the loader.
+// WatchConfigFile blocks until ctx is canceled and returns ctx.Err()
+// or m is closed and returns ErrClosed. Returns an error immediately
+// if the directory of file can't be watched.
+func WatchConfigFile(
+	ctx context.Context, file string, m *Middleware, onError func(error),
+	opts ...config.LoadOption,
+) error {
+	file, err := filepath.Abs(file)
+	if err != nil {
+		return err
+	}
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = w.Close() }()
+	if err := w.Add(filepath.Dir(file)); err != nil {
+		return err
+	}
+	reload := time.NewTimer(0)
+	if !reload.Stop() {
+		<-reload.C
+	}
+	pending := false
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-m.closed.Done():
+			return ErrClosed
+		case err := <-w.Errors:
+			if onError != nil {
+				onError(err)
+			}
+		case e := <-w.Events:
+			if filepath.Clean(e.Name) == file &&
+				(e.Has(fsnotify.Write) || e.Has(fsnotify.Create)) && !pending {
+				pending = true
+				reload.Reset(watchDelay)
+			}
+		case <-reload.C:
+			pending = false
+			c, err := LoadConfigFile(file, opts...)
+			if err != nil {
+				var errOpen *config.ErrOpen
+				if errors.As(err, &errOpen) && errOpen.File == file &&
+					errors.Is(err, fs.ErrNotExist) {
+					// Temporarily missing while an editor replaces it.
+					continue
+				}
+				if onError != nil {
+					onError(err)
+				}
+				continue
+			}
+			m.SetConfig(*c)
+		}
+	}
+}
+
+// ReloadConfigOnSignal loads and sets file as the config of m whenever
+// the process receives any of signals (SIGHUP if none are specified).
+// If the file fails to load the previous config is kept and onError,
+// if not nil, is invoked. opts are passed to the loader.
+// ReloadConfigOnSignal blocks until ctx is canceled and returns ctx.Err()
+// or m is closed and returns ErrClosed.
+func ReloadConfigOnSignal(
+	ctx context.Context, file string, m *Middleware,
+	onError func(error), signals []os.Signal, opts ...config.LoadOption,
+) error {
+	if len(signals) < 1 {
+		signals = []os.Signal{syscall.SIGHUP}
+	}
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, signals...)
+	defer signal.Stop(ch)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-m.closed.Done():
+			return ErrClosed
+		case <-ch:
+		}
+		c, err := LoadConfigFile(file, opts...)
+		if err != nil {
+			if onError != nil {
+				onError(err)
+			}
+			continue
+		}
+		m.SetConfig(*c)
+	}
+}
+
+// WatchConfigURL fetches the config from url immediately and then every
+// interval (DefaultWatchInterval if interval <= 0) and sets it as the config
+// of m whenever it changed. Requests are conditional using the ETag and
+// Last-Modified headers of the previous response, if any, such that
+// unchanged configs aren't transferred or set again.
+// Fetches time out after config.FetchTimeout and are aborted once m is closed.
+// If the config fails to load the previous config is kept and onError,
+// if not nil, is invoked with the error, which is *config.ErrFetch if
+// the fetch itself failed. opts are passed to the loader, the MaxSourceBytes
+// limit bounds the fetched configs.
+// WatchConfigURL blocks until ctx is canceled and returns ctx.Err()
+// or m is closed and returns ErrClosed.
+func WatchConfigURL(
+	ctx context.Context, url string, m *Middleware,
+	interval time.Duration, onError func(error), opts ...config.LoadOption,
+) error {
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+	fetchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	defer context.AfterFunc(m.closed, cancel)()
+
+	var etag, lastModified string
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		c, err := fetchConfig(fetchCtx, url, &etag, &lastModified, opts)
+		switch {
+		case ctx.Err() != nil:
+			return ctx.Err()
+		case m.closed.Err() != nil:
+			return ErrClosed
+		case err != nil:
+			if onError != nil {
+				onError(err)
+			}
+		case c != nil:
+			m.SetConfig(*c)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-m.closed.Done():
+			return ErrClosed
+		case <-t.C:
+		}
+	}
+}
+
+var fetchClient = &http.Client{Timeout: config.FetchTimeout}
+
+// fetchConfig fetches and loads the config from url unless it wasn't
+// modified since the response with etag and lastModified, in which case
+// it returns nil. etag and lastModified are updated on every 200 response
+// such that invalid configs are reported only once.
+func fetchConfig(
+	ctx context.Context, url string, etag, lastModified *string,
+	opts []config.LoadOption,
+) (*Config, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, http.NoBody)
+	if err != nil {
+		return nil, &config.ErrFetch{URL: url, Err: err}
+	}
+	if *etag != "" {
+		req.Header.Set("If-None-Match", *etag)
+	}
+	if *lastModified != "" {
+		req.Header.Set("If-Modified-Since", *lastModified)
+	}
+	resp, err := fetchClient.Do(req)
+	if err != nil {
+		return nil, &config.ErrFetch{URL: url, Err: err}
+	}
+	defer func() { _ = resp.Body.Close() }()
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return nil, nil
+	case http.StatusOK:
+	default:
+		return nil, &config.ErrFetch{URL: url, StatusCode: resp.StatusCode}
+	}
+	*etag, *lastModified = resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+	return LoadConfig(resp.Body, opts...)
+}
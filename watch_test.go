@@ -0,0 +1,343 @@
+package httpsim_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim"
+	"github.com/romshark/httpsim/config"
+)
+
+func TestWatchConfigFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "httpsim.yaml")
+	write := func(contents string) {
+		t.Helper()
+		require.NoError(t, os.WriteFile(file, []byte(contents), 0o644))
+	}
+	write(`
+resources:
+  - path: /*
+    effect:
+      replace:
+        status-code: 503
+`)
+	c, err := httpsim.LoadConfigFile(file)
+	require.NoError(t, err)
+	_, s := NewSimulator(t, *c, func(w http.ResponseWriter, r *http.Request) {})
+
+	statusCode := func() int {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody))
+		return rec.Code
+	}
+
+	var lock sync.Mutex
+	var errs []error
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- httpsim.WatchConfigFile(ctx, file, s,
+			func(err error) {
+				lock.Lock()
+				defer lock.Unlock()
+				errs = append(errs, err)
+			})
+	}()
+
+	require.Equal(t, http.StatusServiceUnavailable, statusCode())
+
+	require.Eventually(t, func() bool {
+		// Keep writing since the watcher may not have started yet.
+		write(`
+resources:
+  - path: /*
+    effect:
+      replace:
+        status-code: 404
+`)
+		time.Sleep(10 * time.Millisecond)
+		return statusCode() == http.StatusNotFound
+	}, 5*time.Second, time.Millisecond)
+
+	// Invalid configs are reported and the previous config is kept.
+	write(`
+resources:
+  - path: /*
+    effect: {}
+`)
+	require.Eventually(t, func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return len(errs) > 0
+	}, 5*time.Second, time.Millisecond)
+	lock.Lock()
+	require.ErrorIs(t, errs[0], config.ErrNoEffect)
+	lock.Unlock()
+	require.Equal(t, http.StatusNotFound, statusCode())
+
+	// Atomic saves replace the file by renaming a temporary file over it.
+	tmp := filepath.Join(filepath.Dir(file), ".httpsim.yaml.tmp")
+	require.NoError(t, os.WriteFile(tmp, []byte(`
+resources:
+  - path: /*
+    effect:
+      replace:
+        status-code: 410
+`), 0o644))
+	require.NoError(t, os.Rename(tmp, file))
+	require.Eventually(t, func() bool {
+		return statusCode() == http.StatusGone
+	}, 5*time.Second, time.Millisecond)
+
+	cancel()
+	require.ErrorIs(t, <-done, context.Canceled)
+}
+
+func TestWatchConfigFileNoDir(t *testing.T) {
+	_, s := NewSimulator(t, config.Config{}, func(w http.ResponseWriter, r *http.Request) {})
+	file := filepath.Join(t.TempDir(), "missing", "httpsim.yaml")
+	err := httpsim.WatchConfigFile(context.Background(), file, s, nil)
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestReloadConfigOnSignal(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("signals can't be sent to the own process on windows")
+	}
+	file := filepath.Join(t.TempDir(), "httpsim.yaml")
+	write := func(statusCode string) {
+		t.Helper()
+		// Replace atomically, pending signals may reload the file any time.
+		tmp := file + ".tmp"
+		require.NoError(t, os.WriteFile(tmp, []byte(`
+resources:
+  - path: /*
+    effect:
+      replace:
+        status-code: `+statusCode+`
+  - path: /other
+    effect:
+      replace:
+        status-code: 500`), 0o644))
+		require.NoError(t, os.Rename(tmp, file))
+	}
+	write("503")
+	c, err := httpsim.LoadConfigFile(file)
+	require.NoError(t, err)
+	_, s := NewSimulator(t, *c, func(w http.ResponseWriter, r *http.Request) {})
+
+	statusCode := func() int {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody))
+		return rec.Code
+	}
+
+	// Prevent SIGHUP from terminating the test before the handler is installed.
+	ignored := make(chan os.Signal, 16)
+	signal.Notify(ignored, syscall.SIGHUP)
+	defer signal.Stop(ignored)
+
+	errs := make(chan error, 16)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- httpsim.ReloadConfigOnSignal(ctx, file, s,
+			func(err error) { errs <- err }, nil)
+	}()
+	sendSIGHUP := func() {
+		t.Helper()
+		p, err := os.FindProcess(os.Getpid())
+		require.NoError(t, err)
+		require.NoError(t, p.Signal(syscall.SIGHUP))
+	}
+
+	write("404")
+	require.Equal(t, http.StatusServiceUnavailable, statusCode(),
+		"must not reload without a signal")
+	require.Eventually(t, func() bool {
+		// Keep signaling since the handler may not have been installed yet.
+		sendSIGHUP()
+		return statusCode() == http.StatusNotFound
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Invalid configs are reported and the previous config is kept.
+	write("0")
+	sendSIGHUP()
+	require.ErrorIs(t, <-errs, config.ErrInvalidStatusCode)
+	require.Equal(t, http.StatusNotFound, statusCode())
+
+	cancel()
+	require.ErrorIs(t, <-done, context.Canceled)
+
+	// Options are passed to the loader.
+	limits := config.DefaultLimits
+	limits.MaxResources = 1
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		done <- httpsim.ReloadConfigOnSignal(ctx, file, s,
+			func(err error) { errs <- err },
+			[]os.Signal{syscall.SIGHUP}, config.WithLimits(limits))
+	}()
+	write("503")
+	require.Eventually(t, func() bool {
+		sendSIGHUP()
+		select {
+		case err := <-errs:
+			// Errors of pending reloads of the invalid config may precede.
+			return errors.Is(err, config.ErrTooManyResources)
+		default:
+			return false
+		}
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, http.StatusNotFound, statusCode())
+}
+
+func TestWatchConfigURL(t *testing.T) {
+	var lock sync.Mutex
+	version, body := 1, "status-code: 503"
+	var requests, notModified int
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			lock.Lock()
+			defer lock.Unlock()
+			requests++
+			etag := fmt.Sprintf(`"v%d"`, version)
+			if r.Header.Get("If-None-Match") == etag {
+				notModified++
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", etag)
+			_, _ = io.WriteString(w, "resources:\n  - effect:\n      replace:\n        "+body)
+		},
+	))
+	defer server.Close()
+	update := func(b string) {
+		lock.Lock()
+		defer lock.Unlock()
+		version++
+		body = b
+	}
+
+	_, s := NewSimulator(t, config.Config{}, func(w http.ResponseWriter, r *http.Request) {})
+	statusCode := func() int {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody))
+		return rec.Code
+	}
+
+	errs := make(chan error, 16)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- httpsim.WatchConfigURL(ctx, server.URL, s, time.Millisecond,
+			func(err error) { errs <- err })
+	}()
+
+	require.Eventually(t, func() bool {
+		return statusCode() == http.StatusServiceUnavailable
+	}, 5*time.Second, time.Millisecond)
+	require.Eventually(t, func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return notModified > 2
+	}, 5*time.Second, time.Millisecond, "unchanged configs must not be transferred")
+
+	update("status-code: 404")
+	require.Eventually(t, func() bool {
+		return statusCode() == http.StatusNotFound
+	}, 5*time.Second, time.Millisecond)
+
+	// Invalid configs are reported once and the previous config is kept.
+	update("status-code: 0")
+	require.ErrorIs(t, <-errs, config.ErrInvalidStatusCode)
+	require.Equal(t, http.StatusNotFound, statusCode())
+	lock.Lock()
+	n := requests
+	lock.Unlock()
+	require.Eventually(t, func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return requests > n+2
+	}, 5*time.Second, time.Millisecond)
+	require.Empty(t, errs)
+
+	cancel()
+	require.ErrorIs(t, <-done, context.Canceled)
+}
+
+func TestWatchConfigURLErrFetch(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+	_, s := NewSimulator(t, config.Config{}, func(w http.ResponseWriter, r *http.Request) {})
+
+	errs := make(chan error, 16)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = httpsim.WatchConfigURL(ctx, server.URL, s, time.Hour,
+			func(err error) { errs <- err })
+	}()
+	var errFetch *config.ErrFetch
+	require.ErrorAs(t, <-errs, &errFetch)
+	require.Equal(t, http.StatusNotFound, errFetch.StatusCode)
+	require.Equal(t, server.URL, errFetch.URL)
+}
+
+func TestWatchConfigURLClose(t *testing.T) {
+	started, canceled := make(chan struct{}), make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-r.Context().Done()
+			close(canceled)
+		},
+	))
+	defer server.Close()
+	_, s := NewSimulator(t, config.Config{}, func(w http.ResponseWriter, r *http.Request) {})
+
+	done := make(chan error, 1)
+	go func() {
+		done <- httpsim.WatchConfigURL(context.Background(), server.URL, s, time.Hour,
+			func(err error) { t.Errorf("unexpected error: %v", err) })
+	}()
+	<-started
+	require.NoError(t, s.Close())
+	require.ErrorIs(t, <-done, httpsim.ErrClosed)
+	<-canceled // The pending fetch is aborted.
+}
+
+func TestWatchConfigURLLimits(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.WriteString(w, "resources:\n  - effect: {hang: {}}\n")
+		},
+	))
+	defer server.Close()
+	_, s := NewSimulator(t, config.Config{}, func(w http.ResponseWriter, r *http.Request) {})
+
+	errs := make(chan error, 16)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = httpsim.WatchConfigURL(ctx, server.URL, s, time.Hour,
+			func(err error) { errs <- err },
+			config.WithLimits(config.Limits{MaxSourceBytes: 16}))
+	}()
+	require.ErrorIs(t, <-errs, config.ErrSourceTooLarge)
+}
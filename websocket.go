@@ -0,0 +1,52 @@
+package httpsim
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// IsWebSocketUpgrade returns true if r is a WebSocket handshake request.
+func IsWebSocketUpgrade(r *http.Request) bool {
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return false
+	}
+	for _, v := range r.Header.Values("Connection") {
+		for _, token := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// closeAfterWriter closes the hijacked connection once after elapsed
+// or when the middleware is closed.
+type closeAfterWriter struct {
+	http.ResponseWriter
+	m     *Middleware
+	after time.Duration
+}
+
+// Hijack must be implemented directly since WebSocket libraries
+// usually type assert http.Hijacker instead of using http.ResponseController.
+var _ http.Hijacker = new(closeAfterWriter)
+
+func (w *closeAfterWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	w.m.goBackground(func() {
+		_ = w.m.sleep(context.Background(), w.after)
+		_ = conn.Close()
+	})
+	return conn, rw, nil
+}
+
+// Unwrap allows http.ResponseController to access the underlying writer.
+func (w *closeAfterWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
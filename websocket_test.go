@@ -0,0 +1,119 @@
+package httpsim_test
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim"
+	"github.com/romshark/httpsim/config"
+	"github.com/romshark/httpsim/internal/rand"
+)
+
+// upgrade is a minimal WebSocket handshake handler that keeps
+// the connection open until the client closes it.
+func upgrade(w http.ResponseWriter, r *http.Request) {
+	conn, rw, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		panic(err)
+	}
+	defer func() { _ = conn.Close() }()
+	_, _ = rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+	_ = rw.Flush()
+	_, _ = io.Copy(io.Discard, rw)
+}
+
+func NewWebSocketUpgradeRequest(t *testing.T, url string) *http.Request {
+	t.Helper()
+	r := NewRequest(t, http.MethodGet, url, http.NoBody)
+	r.Header.Set("Connection", "keep-alive, Upgrade")
+	r.Header.Set("Upgrade", "websocket")
+	r.Header.Set("Sec-WebSocket-Version", "13")
+	r.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	return r
+}
+
+func TestIsWebSocketUpgrade(t *testing.T) {
+	r := NewWebSocketUpgradeRequest(t, "https://host.io/")
+	require.True(t, httpsim.IsWebSocketUpgrade(r))
+	r.Header.Set("Upgrade", "WebSocket")
+	r.Header.Set("Connection", "upgrade")
+	require.True(t, httpsim.IsWebSocketUpgrade(r))
+
+	r.Header.Set("Connection", "keep-alive")
+	require.False(t, httpsim.IsWebSocketUpgrade(r))
+	r.Header.Set("Connection", "upgrade")
+	r.Header.Set("Upgrade", "h2c")
+	require.False(t, httpsim.IsWebSocketUpgrade(r))
+	require.False(t, httpsim.IsWebSocketUpgrade(
+		NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody),
+	))
+}
+
+func TestWebSocketReject(t *testing.T) {
+	reject := config.StatusCode(http.StatusServiceUnavailable)
+	mockSleep, s := NewSimulator(t, config.Config{
+		Resources: []config.Resource{{Effect: &config.Effect{
+			WebSocket: &config.WebSocket{
+				UpgradeDelay: &config.DurRange{Min: time.Second, Max: time.Second},
+				Reject:       &reject,
+			},
+		}}},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, NewWebSocketUpgradeRequest(t, "https://host.io/"))
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	require.Equal(t, time.Second, mockSleep.Cumulative)
+
+	// Regular requests aren't affected.
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, time.Second, mockSleep.Cumulative)
+}
+
+func TestWebSocketCloseAfter(t *testing.T) {
+	conf := config.Config{
+		Resources: []config.Resource{{Effect: &config.Effect{
+			WebSocket: &config.WebSocket{
+				CloseAfter: &config.DurRange{
+					Min: 50 * time.Millisecond, Max: 50 * time.Millisecond,
+				},
+			},
+		}}},
+	}
+	require.NoError(t, config.Validate(conf))
+	rnd := rand.NewSourceChaCha8(rand.NewSeed("fedcba9876543210fedcba9876543210"))
+	s := httpsim.NewMiddleware(
+		http.HandlerFunc(upgrade), conf, httpsim.DefaultSleep, rnd,
+	)
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	require.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+	req := NewWebSocketUpgradeRequest(t, srv.URL)
+	require.NoError(t, req.Write(conn))
+
+	start := time.Now()
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+
+	// The server closes the connection after the delay.
+	_, err = io.ReadAll(br)
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+}
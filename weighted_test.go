@@ -0,0 +1,51 @@
+package httpsim_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/romshark/httpsim/config"
+)
+
+func TestReplaceWeighted(t *testing.T) {
+	body502 := "bad gateway"
+	conf := config.Config{
+		Resources: []config.Resource{{Effect: &config.Effect{
+			ReplaceWeighted: []config.WeightedReplace{
+				{Weight: 1, Replace: config.Replace{
+					StatusCode: http.StatusInternalServerError,
+				}},
+				{Weight: 2, Replace: config.Replace{
+					StatusCode: http.StatusBadGateway,
+					Body:       &body502,
+				}},
+				{Weight: 1, Replace: config.Replace{
+					StatusCode: http.StatusServiceUnavailable,
+					Headers:    map[config.HeaderName]string{"Retry-After": "5"},
+				}},
+			},
+		}}},
+	}
+	_, s := NewSimulator(t, conf, func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("next handler must not be invoked")
+	})
+	counts := map[int]int{}
+	for range 4000 {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, NewRequest(t, http.MethodGet, "https://host.io/", http.NoBody))
+		counts[rec.Code]++
+		switch rec.Code {
+		case http.StatusBadGateway:
+			require.Equal(t, body502, rec.Body.String())
+		case http.StatusServiceUnavailable:
+			require.Equal(t, "5", rec.Header().Get("Retry-After"))
+		}
+	}
+	require.Len(t, counts, 3)
+	require.InDelta(t, 1000, counts[http.StatusInternalServerError], 100)
+	require.InDelta(t, 2000, counts[http.StatusBadGateway], 100)
+	require.InDelta(t, 1000, counts[http.StatusServiceUnavailable], 100)
+}